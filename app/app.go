@@ -2,7 +2,11 @@ package app
 
 import (
 	"avengers-clinic/config"
+	"avengers-clinic/migrations"
 	"avengers-clinic/model/dto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/migration"
 	"avengers-clinic/router"
 	"database/sql"
 	"errors"
@@ -10,6 +14,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -32,6 +37,101 @@ func initEnv() (dto.ConfigData, error) {
 		configData.AppConfig.Port = port
 	}
 
+	// optional, without it only allergies matching the medicine name are checked
+	configData.AppConfig.DrugKnowledgeFile = os.Getenv("DRUG_KNOWLEDGE_FILE")
+
+	// optional, without it low stock alerts are only written to the log
+	configData.AppConfig.LowStockWebhookURL = os.Getenv("LOW_STOCK_WEBHOOK_URL")
+
+	// how long unpaid medical records hold their medicines, defaults to a day
+	configData.AppConfig.ReservationTTL = "24h"
+	if reservationTTL := os.Getenv("RESERVATION_TTL"); reservationTTL != "" {
+		configData.AppConfig.ReservationTTL = reservationTTL
+	}
+
+	// how long responses are kept for retries with the same Idempotency-Key, defaults to a day
+	configData.AppConfig.IdempotencyKeyTTL = "24h"
+	if idempotencyKeyTTL := os.Getenv("IDEMPOTENCY_KEY_TTL"); idempotencyKeyTTL != "" {
+		configData.AppConfig.IdempotencyKeyTTL = idempotencyKeyTTL
+	}
+
+	// how long a request holds its Idempotency-Key before a retry may take it
+	// over, longer than any request runs, defaults to five minutes
+	configData.AppConfig.IdempotencyLockTTL = "5m"
+	if idempotencyLockTTL := os.Getenv("IDEMPOTENCY_LOCK_TTL"); idempotencyLockTTL != "" {
+		configData.AppConfig.IdempotencyLockTTL = idempotencyLockTTL
+	}
+
+	// charged for doctors without their own consultation fee
+	if consultationFee := os.Getenv("CONSULTATION_FEE"); consultationFee != "" {
+		fee, err := strconv.Atoi(consultationFee)
+		if err != nil {
+			return dto.ConfigData{}, err
+		}
+		configData.AppConfig.ConsultationFee = fee
+	}
+
+	// PPN in percent added to new invoices, defaults to 11
+	configData.AppConfig.TaxRate = 11
+	if taxRate := os.Getenv("PPN_RATE"); taxRate != "" {
+		rate, err := strconv.ParseFloat(taxRate, 64)
+		if err != nil {
+			return dto.ConfigData{}, err
+		}
+		configData.AppConfig.TaxRate = rate
+	}
+
+	// optional, without a provider online payments are turned off
+	configData.AppConfig.PaymentProvider = os.Getenv("PAYMENT_PROVIDER")
+	configData.AppConfig.PaymentChargeURL = os.Getenv("PAYMENT_CHARGE_URL")
+	configData.AppConfig.PaymentStatusURL = os.Getenv("PAYMENT_STATUS_URL")
+	configData.AppConfig.PaymentServerKey = os.Getenv("PAYMENT_SERVER_KEY")
+
+	// how long online payment pages stay valid, defaults to an hour
+	configData.AppConfig.PaymentChargeTTL = "1h"
+	if chargeTTL := os.Getenv("PAYMENT_CHARGE_TTL"); chargeTTL != "" {
+		configData.AppConfig.PaymentChargeTTL = chargeTTL
+	}
+
+	// printed in the header of generated documents
+	configData.AppConfig.ClinicName = os.Getenv("CLINIC_NAME")
+	configData.AppConfig.ClinicAddress = os.Getenv("CLINIC_ADDRESS")
+	configData.AppConfig.ClinicPhone = os.Getenv("CLINIC_PHONE")
+	configData.AppConfig.ClinicLogoFile = os.Getenv("CLINIC_LOGO_FILE")
+
+	// public address of the verification endpoint the QR code of documents links to
+	configData.AppConfig.VerifyURL = "http://localhost:" + configData.AppConfig.Port + "/api/v1/verify"
+	if verifyURL := os.Getenv("VERIFY_URL"); verifyURL != "" {
+		configData.AppConfig.VerifyURL = strings.TrimSuffix(verifyURL, "/")
+	}
+
+	// public base URL of the FHIR endpoints, which exported bundles address their entries by
+	configData.AppConfig.FhirBaseURL = "http://localhost:" + configData.AppConfig.Port + "/api/v1/fhir"
+	if fhirBaseURL := os.Getenv("FHIR_BASE_URL"); fhirBaseURL != "" {
+		configData.AppConfig.FhirBaseURL = strings.TrimSuffix(fhirBaseURL, "/")
+	}
+
+	// how long a request may run before its queries are canceled, defaults to 30 seconds
+	configData.AppConfig.RequestTimeout = "30s"
+	if requestTimeout := os.Getenv("REQUEST_TIMEOUT"); requestTimeout != "" {
+		configData.AppConfig.RequestTimeout = requestTimeout
+	}
+
+	// optional, timeouts of their own for slow routes, as in GET /api/v1/reports/*=2m,POST /api/v1/medicines/import=5m
+	configData.AppConfig.RouteTimeouts = os.Getenv("ROUTE_TIMEOUTS")
+
+	// signs generated documents so that changes to them are detected on verification,
+	// without it documents are issued unsigned and verify as such
+	configData.AppConfig.DocumentSigningKey = os.Getenv("DOCUMENT_SIGNING_KEY")
+
+	if err := initDbEnv(&configData); err != nil {
+		return dto.ConfigData{}, err
+	}
+
+	return configData, nil
+}
+
+func initDbEnv(configData *dto.ConfigData) error {
 	dbHost := os.Getenv("DB_HOST")
 	dbPort := os.Getenv("DB_PORT")
 	dbUser := os.Getenv("DB_USER")
@@ -43,22 +143,22 @@ func initEnv() (dto.ConfigData, error) {
 	dbLogMode := os.Getenv("LOG_MODE")
 
 	if dbHost == "" || dbPort == "" || dbUser == "" || dbPass == "" || dbName == "" || dbMaxIdle == "" || dbMaxConn == "" || dbMaxLifeTime == "" || dbLogMode == "" {
-		return dto.ConfigData{}, errors.New("DB config is not set")
+		return errors.New("DB config is not set")
 	}
 
 	maxIdle, err := strconv.Atoi(dbMaxIdle);
 	if err != nil {
-		return dto.ConfigData{}, err
+		return err
 	}
 
 	maxConn, err := strconv.Atoi(dbMaxIdle);
 	if err != nil {
-		return dto.ConfigData{}, err
+		return err
 	}
 
 	logMode, err := strconv.Atoi(dbLogMode);
 	if err != nil {
-		return dto.ConfigData{}, err
+		return err
 	}
 
 	configData.DbConfig.Host = dbHost
@@ -72,7 +172,7 @@ func initEnv() (dto.ConfigData, error) {
 	configData.DbConfig.MaxLifeTime = dbMaxLifeTime
 	configData.DbConfig.LogMode = logMode
 
-	return configData, nil
+	return nil
 }
 
 func RunService() {
@@ -87,7 +187,10 @@ func RunService() {
 		log.Error().Msg(err.Error())
 		return
 	}
-	log.Info().Msg(fmt.Sprintf("config data %v", configData))
+	log.Info().Msg(fmt.Sprintf("config data %v", configData.Redacted()))
+	if configData.AppConfig.DocumentSigningKey == "" {
+		log.Warn().Msg("DOCUMENT_SIGNING_KEY is not set, documents are issued unsigned")
+	}
 
 	conn, err := config.ConnectDB(configData, log.Logger)
 	if err != nil {
@@ -111,6 +214,17 @@ func RunService() {
 		}
 	}()
 
+	// refuse to serve a database the migrations have not caught up with
+	migrator, err := migration.New(conn, migrations.Files)
+	if err != nil {
+		log.Error().Msg("RunService.migration.err : " + err.Error())
+		return
+	}
+	if err := migrator.Check(); err != nil {
+		log.Error().Msg("RunService.migration.err : " + err.Error())
+		return
+	}
+
 	// setup timezone
 	time.Local = time.FixedZone("Asia/Jakarta", 7*60*60)
 	r := gin.New()
@@ -118,8 +232,8 @@ func RunService() {
 		AllowAllOrigins: false,
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders: []string{"Content-Length"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Authorization", constants.RequestIDHeader, constants.IfMatchHeader, constants.IdempotencyKeyHeader},
+		ExposeHeaders: []string{"Content-Length", constants.RequestIDHeader, constants.ETagHeader, constants.IdempotentReplayedHeader},
 		AllowCredentials: true,
 		MaxAge: 120 * time.Second,
 	}))
@@ -154,7 +268,13 @@ func RunService() {
 	// gin recovery for handle panic
 	r.Use(gin.Recovery())
 
-	initializeDomainModule(r, conn)
+	// ties the audit entries of a request to it
+	r.Use(middleware.RequestID())
+
+	if err := initializeDomainModule(r, conn, configData); err != nil {
+		log.Error().Msg("RunService.initializeDomainModule.err : " + err.Error())
+		return
+	}
 
 	version := "0.0.1"
 	log.Info().Msg(fmt.Sprintf("Service Running version %s", version))
@@ -165,9 +285,9 @@ func RunService() {
 	}
 }
 
-func initializeDomainModule(r *gin.Engine, db *sql.DB) {
+func initializeDomainModule(r *gin.Engine, db *sql.DB, configData dto.ConfigData) error {
 	apiGroup := r.Group("/api")
 	v1Group := apiGroup.Group("/v1")
 
-	router.InitRoute(v1Group, db)
+	return router.InitRoute(v1Group, db, configData)
 }
\ No newline at end of file
@@ -0,0 +1,471 @@
+package app
+
+import (
+	"avengers-clinic/config"
+	"avengers-clinic/migrations"
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/model/dto/userDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/migration"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/action/actionRepository"
+	"avengers-clinic/src/action/actionUsecase"
+	"avengers-clinic/src/booking/bookingRepository"
+	"avengers-clinic/src/doctorSchedule/doctorScheduleRepository"
+	"avengers-clinic/src/doctorSchedule/doctorScheduleUsecase"
+	"avengers-clinic/src/medicine/medicineRepository"
+	"avengers-clinic/src/medicine/medicineUsecase"
+	"avengers-clinic/src/report/reportRepository"
+	"avengers-clinic/src/report/reportUsecase"
+	"avengers-clinic/src/user/userRepository"
+	"avengers-clinic/src/user/userUsecase"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	seedUsage              = "usage: seed [name]"
+	createAdminUsage       = "usage: create-admin -username <username> [-password <password>]"
+	resetPasswordUsage     = "usage: reset-password -username <username> [-password <password>]"
+	importMedicinesUsage   = "usage: import-medicines -file <file.csv|file.xlsx> [-dry-run]"
+	importActionsUsage     = "usage: import-actions -file <file.csv|file.xlsx> [-dry-run]"
+	generateSchedulesUsage = "usage: generate-schedules -doctor <id> -from <date> -to <date> -start <slot> -end <slot> [-days mon,tue,wed,thu,fri]"
+	purgeUsage             = "usage: purge [-days 365]"
+	reportUsage            = "usage: report revenue | outstanding | closings [-from <date>] [-to <date>] [-group day|week|month] [-date <date>] [-cashier <id>] [-format csv|xlsx|json] [-out <file>]"
+)
+
+// command is a subcommand of the binary for the people running the clinic.
+// Commands work through the usecases, so the rules of the API apply to them
+// as well.
+type command struct {
+	name  string
+	usage string
+	run   func(db *sql.DB, args []string) error
+}
+
+var commands = []command{
+	{"migrate", migrateUsage, migrate},
+	{"seed", seedUsage, seed},
+	{"create-admin", createAdminUsage, createAdmin},
+	{"reset-password", resetPasswordUsage, resetPassword},
+	{"import-medicines", importMedicinesUsage, importMedicines},
+	{"import-actions", importActionsUsage, importActions},
+	{"generate-schedules", generateSchedulesUsage, generateSchedules},
+	{"purge", purgeUsage, purge},
+	{"report", reportUsage, report},
+}
+
+// RunCommand runs the command named by the first argument. Without arguments,
+// or with serve, it runs the service.
+func RunCommand(args []string) {
+	if len(args) == 0 || args[0] == "serve" {
+		RunService()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			if err := runCommand(cmd, args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: avengers-clinic [serve]")
+	for _, cmd := range commands {
+		fmt.Fprintln(os.Stderr, "       "+strings.TrimPrefix(cmd.usage, "usage: "))
+	}
+	if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+		os.Exit(2)
+	}
+}
+
+func runCommand(cmd command, args []string) error {
+	// the environment may come from the shell alone, as in the Docker image
+	godotenv.Load()
+	var configData dto.ConfigData
+	if err := initDbEnv(&configData); err != nil {
+		return err
+	}
+
+	db, err := config.ConnectDB(configData, log.Logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// the other commands need the schema the usecases were written for
+	if cmd.name != "migrate" {
+		migrator, err := migration.New(db, migrations.Files)
+		if err != nil {
+			return err
+		}
+		if err := migrator.Check(); err != nil {
+			return err
+		}
+	}
+
+	time.Local = time.FixedZone("Asia/Jakarta", 7*60*60)
+	return cmd.run(db, args)
+}
+
+// parseFlags parses the flags of a command, printing the usage of the command
+// when they are wrong.
+func parseFlags(flags *flag.FlagSet, usage string, args []string) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%s\n%s", err.Error(), usage)
+	}
+	return nil
+}
+
+func seed(db *sql.DB, args []string) error {
+	name := "demo"
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	script, err := migrations.Seeds.ReadFile("seeds/" + name + ".sql")
+	if err != nil {
+		return fmt.Errorf("there is no seed named %s", name)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(string(script)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf("seeded %s\n", name)
+	return nil
+}
+
+func createAdmin(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "", "")
+	password := flags.String("password", "", "")
+	if err := parseFlags(flags, createAdminUsage, args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New(createAdminUsage)
+	}
+	if err := readPassword(password); err != nil {
+		return err
+	}
+
+	userUC := userUsecase.NewUserUsecase(userRepository.NewUserRepository(db))
+	admin, err := userUC.UserRegister(context.Background(), userDto.RegisterRequest{Username: *username, Password: *password, Role: "ADMIN"})
+	if err != nil {
+		if err.Error() == "1" {
+			return errors.New("the username is already registered")
+		}
+		return err
+	}
+
+	fmt.Printf("created admin %s with id %s\n", admin.Username, admin.ID)
+	return nil
+}
+
+func resetPassword(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	username := flags.String("username", "", "")
+	password := flags.String("password", "", "")
+	if err := parseFlags(flags, resetPasswordUsage, args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New(resetPasswordUsage)
+	}
+	if err := readPassword(password); err != nil {
+		return err
+	}
+
+	userUC := userUsecase.NewUserUsecase(userRepository.NewUserRepository(db))
+	if err := userUC.ResetPassword(context.Background(), *username, *password); err != nil {
+		if err.Error() == "1" {
+			return errors.New("there is no user with that username")
+		}
+		return err
+	}
+
+	fmt.Printf("reset the password of %s\n", *username)
+	return nil
+}
+
+// readPassword asks for the password when it was not given as a flag, so it
+// does not have to end up in the shell history.
+func readPassword(password *string) error {
+	if *password != "" {
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	*password = strings.TrimSpace(line)
+	if *password == "" {
+		return errors.New("the password is required")
+	}
+	return nil
+}
+
+func importMedicines(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("import-medicines", flag.ContinueOnError)
+	file := flags.String("file", "", "")
+	dryRun := flags.Bool("dry-run", false, "")
+	if err := parseFlags(flags, importMedicinesUsage, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New(importMedicinesUsage)
+	}
+
+	medicineUC := medicineUsecase.NewMedicineUsecase(medicineRepository.NewMedicineRepository(db))
+	return importSheet("medicines", *file, func(records []sheetDto.Record) (sheetDto.ImportResult, error) {
+		return medicineUC.Import(context.Background(), records, "", *dryRun)
+	})
+}
+
+func importActions(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("import-actions", flag.ContinueOnError)
+	file := flags.String("file", "", "")
+	dryRun := flags.Bool("dry-run", false, "")
+	if err := parseFlags(flags, importActionsUsage, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New(importActionsUsage)
+	}
+
+	actionUC := actionUsecase.NewActionUsecase(actionRepository.NewActionRepository(db))
+	return importSheet("actions", *file, func(records []sheetDto.Record) (sheetDto.ImportResult, error) {
+		return actionUC.Import(context.Background(), records, "", *dryRun)
+	})
+}
+
+// importSheet imports a CSV or XLSX file and lists the lines that fail with
+// their reason.
+func importSheet(name, fileName string, run func(records []sheetDto.Record) (sheetDto.ImportResult, error)) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records, err := utils.ReadSheet(fileName, file)
+	if err != nil {
+		return err
+	}
+
+	result, err := run(records)
+	if err != nil {
+		return err
+	}
+	for _, field := range utils.ImportErrors(result) {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", field.FieldName, field.Message)
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d lines are invalid, nothing was imported", result.Failed, len(result.Rows))
+	}
+
+	verb := "imported"
+	if result.DryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %s: %d created, %d updated\n", verb, name, result.Created, result.Updated)
+	return nil
+}
+
+func validated(s interface{}) error {
+	fields := utils.Validated(s)
+	if fields == nil {
+		return nil
+	}
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.FieldName + " " + field.Message
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
+
+func generateSchedules(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("generate-schedules", flag.ContinueOnError)
+	doctor := flags.String("doctor", "", "")
+	from := flags.String("from", "", "")
+	to := flags.String("to", "", "")
+	days := flags.String("days", "mon,tue,wed,thu,fri", "")
+	start := flags.Int("start", 0, "")
+	end := flags.Int("end", 0, "")
+	if err := parseFlags(flags, generateSchedulesUsage, args); err != nil {
+		return err
+	}
+
+	doctorID, err := uuid.Parse(*doctor)
+	if err != nil {
+		return errors.New(generateSchedulesUsage)
+	}
+	startDate, errStart := time.Parse("2006-01-02", *from)
+	endDate, errEnd := time.Parse("2006-01-02", *to)
+	if errStart != nil || errEnd != nil {
+		return errors.New(constants.ErrDateFormat)
+	}
+	if endDate.Before(startDate) {
+		return errors.New(constants.ErrInvalidDateRange)
+	}
+
+	weekdays := map[string]bool{}
+	for _, day := range strings.Split(strings.ToLower(*days), ",") {
+		weekdays[strings.TrimSpace(day)] = true
+	}
+
+	input := dto.CreateDoctorSchedule{DoctorID: doctorID}
+	for date := startDate; !date.After(endDate); date = date.AddDate(0, 0, 1) {
+		if weekdays[strings.ToLower(date.Weekday().String()[:3])] {
+			input.ScheduleDetail = append(input.ScheduleDetail, dto.DoctorScheduleDetail{ScheduleDate: date.Format("2006-01-02"), StartAt: *start, EndAt: *end})
+		}
+	}
+	if len(input.ScheduleDetail) == 0 {
+		return errors.New("no date in the range falls on the given days")
+	}
+	if err := validated(input.ScheduleDetail[0]); err != nil {
+		return err
+	}
+
+	scheduleUC := doctorScheduleUsecase.NewDoctorScheduleUsecase(doctorScheduleRepository.NewDoctorScheduleRepo(db), bookingRepository.NewBookingRepository(db))
+	schedules, err := scheduleUC.CreateSchedule(context.Background(), input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %d schedules from %s to %s\n", len(schedules), *from, *to)
+	return nil
+}
+
+func purge(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	days := flags.Int("days", 365, "")
+	if err := parseFlags(flags, purgeUsage, args); err != nil {
+		return err
+	}
+
+	actionUC := actionUsecase.NewActionUsecase(actionRepository.NewActionRepository(db))
+	medicineUC := medicineUsecase.NewMedicineUsecase(medicineRepository.NewMedicineRepository(db))
+	scheduleUC := doctorScheduleUsecase.NewDoctorScheduleUsecase(doctorScheduleRepository.NewDoctorScheduleRepo(db), bookingRepository.NewBookingRepository(db))
+	userUC := userUsecase.NewUserUsecase(userRepository.NewUserRepository(db))
+
+	// users last, the actions, medicines and schedules they created may be purged first
+	purges := []struct {
+		name  string
+		purge func(ctx context.Context, retentionDays int) (dto.Purged, error)
+	}{
+		{"actions", actionUC.PurgeTrash},
+		{"medicines", medicineUC.PurgeTrash},
+		{"doctor schedules", scheduleUC.PurgeTrash},
+		{"users", userUC.PurgeTrash},
+	}
+	for _, p := range purges {
+		purged, err := p.purge(context.Background(), *days)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("purged %d %s deleted more than %d days ago\n", purged.Deleted, p.name, *days)
+
+		// Rows other records still point to, the stock history of a medicine
+		// or the bookings of a patient, are kept in the trash
+		tables := make([]string, 0, len(purged.Kept))
+		for table := range purged.Kept {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			fmt.Printf("  kept %d still referenced by %s\n", purged.Kept[table], table)
+		}
+	}
+	return nil
+}
+
+func report(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(reportUsage)
+	}
+
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	from := flags.String("from", "", "")
+	to := flags.String("to", "", "")
+	group := flags.String("group", "", "")
+	date := flags.String("date", "", "")
+	cashier := flags.String("cashier", "", "")
+	format := flags.String("format", constants.ReportFormatCSV, "")
+	out := flags.String("out", "", "")
+	if err := parseFlags(flags, reportUsage, args[1:]); err != nil {
+		return err
+	}
+
+	reportUC := reportUsecase.NewReportUsecase(reportRepository.NewReportRepository(db))
+	var name string
+	var data interface{}
+	var err error
+	switch args[0] {
+	case "revenue":
+		name = "revenue"
+		data, err = reportUC.GetRevenue(context.Background(), reportDto.RevenueFilter{GroupBy: *group, StartDate: *from, EndDate: *to})
+	case "outstanding":
+		name = "outstanding"
+		data, err = reportUC.GetOutstanding(context.Background())
+	case "closings":
+		name = "cashier-closing"
+		data, err = reportUC.GetCashierClosings(context.Background(), reportDto.ClosingFilter{BusinessDate: *date, CashierID: *cashier})
+	default:
+		return errors.New(reportUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if strings.ToLower(*format) == constants.ReportFormatJSON {
+		content, err = json.MarshalIndent(data, "", "  ")
+		content = append(content, '\n')
+	} else {
+		var export reportDto.Export
+		export, err = reportUC.Export(context.Background(), name, *format, data)
+		content = export.Content
+	}
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return os.WriteFile(*out, content, 0644)
+}
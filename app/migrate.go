@@ -0,0 +1,72 @@
+package app
+
+import (
+	"avengers-clinic/migrations"
+	"avengers-clinic/pkg/migration"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const migrateUsage = "usage: migrate up | down | status | to <version> | baseline <version>"
+
+func migrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migration.New(db, migrations.Files)
+	if err != nil {
+		return err
+	}
+
+	var run []migration.Migration
+	switch args[0] {
+	case "up":
+		run, err = migrator.Up()
+	case "down":
+		run, err = migrator.Down()
+	case "to", "baseline":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, errV := strconv.Atoi(args[1])
+		if errV != nil {
+			return errors.New(migrateUsage)
+		}
+		if args[0] == "baseline" {
+			err = migrator.Baseline(version)
+		} else {
+			run, err = migrator.To(version)
+		}
+	case "status":
+		return printStatus(migrator)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	for _, m := range run {
+		fmt.Printf("%04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	return printStatus(migrator)
+}
+
+func printStatus(migrator *migration.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		appliedAt := status.AppliedAt
+		if appliedAt == "" {
+			appliedAt = "pending"
+		}
+		fmt.Printf("%04d  %-24s %s\n", status.Version, status.Name, appliedAt)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package main
 
-import "avengers-clinic/app"
+import (
+	"avengers-clinic/app"
+	"os"
+)
 
 func main() {
-	app.RunService()
-}
\ No newline at end of file
+	app.RunCommand(os.Args[1:])
+}
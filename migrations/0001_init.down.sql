@@ -0,0 +1,13 @@
+DROP TABLE medical_record_action_details;
+DROP TABLE medical_record_medicine_details;
+DROP TABLE medical_records;
+DROP TABLE bookings;
+DROP TABLE doctor_schedules;
+DROP TABLE mst_schedule_time;
+DROP TABLE actions;
+DROP TABLE medicines;
+DROP TABLE users;
+
+DROP TYPE medicine_type;
+DROP TYPE booking_status;
+DROP TYPE user_role;
@@ -1,5 +1,3 @@
-CREATE DATABASE IF NOT EXISTS avenger_clinic_db;
-
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
 CREATE TYPE user_role AS ENUM ('ADMIN', 'DOCTOR', 'PATIENT');
@@ -0,0 +1 @@
+DELETE FROM mst_schedule_time WHERE id BETWEEN 1 AND 14;
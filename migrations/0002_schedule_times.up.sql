@@ -0,0 +1,16 @@
+INSERT INTO mst_schedule_time(id, start_at, end_at, created_at, updated_at) 
+VALUES
+     (1, '08:00:30', '08:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (2, '08:30:30', '09:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (3, '09:00:30', '09:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (4, '09:30:30', '10:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (5, '10:00:30', '10:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (6, '10:30:30', '11:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (7, '11:00:30', '11:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (8, '11:30:30', '12:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (9, '13:00:30', '13:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (10, '13:30:30', '14:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (11, '14:00:30', '14:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (12, '14:30:30', '15:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (13, '15:00:30', '15:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+     (14, '15:30:30', '16:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
@@ -0,0 +1,4 @@
+DROP TABLE medical_record_prescription_overrides;
+DROP TABLE patient_allergies;
+
+DROP TYPE allergy_severity;
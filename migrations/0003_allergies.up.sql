@@ -0,0 +1,21 @@
+CREATE TYPE allergy_severity AS ENUM('MILD', 'MODERATE', 'SEVERE');
+
+CREATE TABLE patient_allergies (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  patient_id uuid NOT NULL REFERENCES users (id),
+  allergen VARCHAR NOT NULL,
+  reaction text,
+  severity allergy_severity NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE medical_record_prescription_overrides (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  medical_record_id uuid NOT NULL REFERENCES medical_records (id),
+  warning_code VARCHAR NOT NULL,
+  reason text NOT NULL,
+  acknowledged_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
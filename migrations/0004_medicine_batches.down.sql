@@ -0,0 +1 @@
+DROP TABLE medicine_batches;
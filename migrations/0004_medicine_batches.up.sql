@@ -0,0 +1,15 @@
+CREATE TABLE medicine_batches (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  medicine_id uuid NOT NULL REFERENCES medicines (id),
+  lot_number VARCHAR NOT NULL,
+  expiry_date DATE NOT NULL,
+  supplier VARCHAR,
+  purchase_cost INT NOT NULL DEFAULT 0,
+  initial_quantity INT NOT NULL,
+  quantity INT NOT NULL CHECK (quantity >= 0),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+CREATE INDEX medicine_batches_fefo_idx ON medicine_batches (medicine_id, expiry_date) WHERE deleted_at IS NULL;
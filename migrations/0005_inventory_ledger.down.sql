@@ -0,0 +1,10 @@
+DROP TABLE stock_opname_items;
+DROP TABLE stock_opnames;
+DROP TYPE stock_opname_status;
+
+DROP TABLE stock_movements;
+DROP FUNCTION stock_movements_append_only();
+DROP TYPE stock_movement_type;
+
+ALTER TABLE medicine_batches DROP COLUMN goods_receipt_id;
+DROP TABLE goods_receipts;
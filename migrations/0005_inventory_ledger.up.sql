@@ -0,0 +1,64 @@
+CREATE TABLE goods_receipts (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  receipt_number VARCHAR NOT NULL UNIQUE,
+  supplier VARCHAR NOT NULL,
+  note text,
+  received_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE medicine_batches ADD COLUMN goods_receipt_id uuid REFERENCES goods_receipts (id);
+
+CREATE TYPE stock_movement_type AS ENUM('OPENING_BALANCE', 'RECEIPT', 'DISPENSE', 'RETURN', 'ADJUSTMENT', 'EXPIRY_WRITE_OFF', 'OPNAME_CORRECTION');
+
+CREATE TABLE stock_movements (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  medicine_id uuid NOT NULL REFERENCES medicines (id),
+  batch_id uuid REFERENCES medicine_batches (id),
+  movement_type stock_movement_type NOT NULL,
+  quantity INT NOT NULL CHECK (quantity <> 0),
+  balance_after INT NOT NULL,
+  reference_type VARCHAR,
+  reference_id uuid,
+  note text,
+  created_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX stock_movements_medicine_idx ON stock_movements (medicine_id, created_at);
+
+CREATE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only BEFORE UPDATE OR DELETE ON stock_movements
+  FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
+
+INSERT INTO stock_movements (medicine_id, movement_type, quantity, balance_after, note)
+  SELECT id, 'OPENING_BALANCE', stock, stock, 'stock before the ledger was introduced' FROM medicines WHERE COALESCE(stock, 0) <> 0;
+
+CREATE TYPE stock_opname_status AS ENUM('OPEN', 'COMPLETED');
+
+CREATE TABLE stock_opnames (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  status stock_opname_status NOT NULL DEFAULT 'OPEN',
+  note text,
+  started_by uuid REFERENCES users (id),
+  completed_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  completed_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX stock_opnames_single_open_idx ON stock_opnames (status) WHERE status = 'OPEN';
+
+CREATE TABLE stock_opname_items (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  stock_opname_id uuid NOT NULL REFERENCES stock_opnames (id),
+  medicine_id uuid NOT NULL REFERENCES medicines (id),
+  price INT NOT NULL DEFAULT 0,
+  system_quantity INT NOT NULL,
+  counted_quantity INT CHECK (counted_quantity >= 0),
+  UNIQUE (stock_opname_id, medicine_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE medicines
+  DROP COLUMN min_stock,
+  DROP COLUMN reorder_quantity,
+  DROP COLUMN low_stock_alerted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE medicines
+  ADD COLUMN min_stock INT NOT NULL DEFAULT 0 CHECK (min_stock >= 0),
+  ADD COLUMN reorder_quantity INT NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0),
+  ADD COLUMN low_stock_alerted_at TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE stock_reservations;
+
+DROP TYPE stock_reservation_status;
@@ -0,0 +1,16 @@
+CREATE TYPE stock_reservation_status AS ENUM('RESERVED', 'CONSUMED', 'RELEASED');
+
+CREATE TABLE stock_reservations (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  medical_record_id uuid NOT NULL REFERENCES medical_records (id),
+  medicine_detail_id uuid NOT NULL UNIQUE REFERENCES medical_record_medicine_details (id),
+  medicine_id uuid NOT NULL REFERENCES medicines (id),
+  quantity INT NOT NULL CHECK (quantity > 0),
+  status stock_reservation_status NOT NULL DEFAULT 'RESERVED',
+  expires_at TIMESTAMP NOT NULL,
+  created_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX stock_reservations_active_idx ON stock_reservations (medicine_id, expires_at) WHERE status = 'RESERVED';
@@ -0,0 +1,7 @@
+DROP TABLE invoice_items;
+DROP TABLE invoices;
+DROP TABLE invoice_sequences;
+DROP TABLE consultation_fees;
+
+DROP TYPE invoice_item_type;
+DROP TYPE invoice_status;
@@ -0,0 +1,52 @@
+CREATE TABLE consultation_fees (
+  doctor_id uuid PRIMARY KEY REFERENCES users (id),
+  fee INT NOT NULL CHECK (fee >= 0),
+  updated_by uuid REFERENCES users (id),
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TYPE invoice_status AS ENUM('DRAFT', 'ISSUED', 'PARTIALLY_PAID', 'PAID', 'VOID');
+
+CREATE TYPE invoice_item_type AS ENUM('CONSULTATION', 'MEDICINE', 'ACTION');
+
+CREATE TABLE invoice_sequences (
+  period VARCHAR(6) PRIMARY KEY,
+  last_number INT NOT NULL
+);
+
+CREATE TABLE invoices (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  invoice_number VARCHAR UNIQUE,
+  medical_record_id uuid NOT NULL REFERENCES medical_records (id),
+  status invoice_status NOT NULL DEFAULT 'DRAFT',
+  subtotal INT NOT NULL DEFAULT 0,
+  discount_percent NUMERIC(5,2) NOT NULL DEFAULT 0,
+  discount_amount INT NOT NULL DEFAULT 0 CHECK (discount_amount >= 0),
+  tax_rate NUMERIC(5,2) NOT NULL DEFAULT 0,
+  tax_amount INT NOT NULL DEFAULT 0,
+  total INT NOT NULL DEFAULT 0,
+  paid_amount INT NOT NULL DEFAULT 0,
+  note text,
+  created_by uuid REFERENCES users (id),
+  issued_by uuid REFERENCES users (id),
+  voided_by uuid REFERENCES users (id),
+  void_reason text,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP,
+  issued_at TIMESTAMP,
+  paid_at TIMESTAMP,
+  voided_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX invoices_active_medical_record_idx ON invoices (medical_record_id) WHERE status <> 'VOID';
+
+CREATE TABLE invoice_items (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  invoice_id uuid NOT NULL REFERENCES invoices (id),
+  item_type invoice_item_type NOT NULL,
+  reference_id uuid,
+  description VARCHAR NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  unit_price INT NOT NULL,
+  amount INT NOT NULL
+);
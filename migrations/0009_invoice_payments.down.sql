@@ -0,0 +1,11 @@
+-- invoice_status keeps the REFUNDED value, Postgres cannot drop a value of an enum
+DROP TABLE invoice_events;
+DROP FUNCTION append_only();
+DROP TYPE invoice_event_type;
+
+DROP TABLE invoice_refunds;
+DROP TABLE invoice_payments;
+DROP TYPE invoice_payment_status;
+DROP TYPE payment_method;
+
+ALTER TABLE invoices DROP COLUMN refunded_amount;
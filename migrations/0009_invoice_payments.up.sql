@@ -0,0 +1,64 @@
+ALTER TYPE invoice_status ADD VALUE IF NOT EXISTS 'REFUNDED';
+
+ALTER TABLE invoices ADD COLUMN refunded_amount INT NOT NULL DEFAULT 0;
+
+CREATE TYPE payment_method AS ENUM('CASH', 'DEBIT', 'QRIS', 'TRANSFER', 'INSURANCE');
+
+CREATE TYPE invoice_payment_status AS ENUM('COMPLETED', 'VOID');
+
+CREATE TABLE invoice_payments (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  invoice_id uuid NOT NULL REFERENCES invoices (id),
+  method payment_method NOT NULL,
+  amount INT NOT NULL CHECK (amount > 0),
+  tendered_amount INT NOT NULL,
+  change_amount INT NOT NULL DEFAULT 0 CHECK (change_amount >= 0),
+  reference VARCHAR,
+  status invoice_payment_status NOT NULL DEFAULT 'COMPLETED',
+  cashier_id uuid REFERENCES users (id),
+  void_reason text,
+  voided_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  voided_at TIMESTAMP
+);
+
+CREATE INDEX invoice_payments_invoice_idx ON invoice_payments (invoice_id);
+
+CREATE TABLE invoice_refunds (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  invoice_id uuid NOT NULL REFERENCES invoices (id),
+  method payment_method NOT NULL,
+  amount INT NOT NULL CHECK (amount > 0),
+  reference VARCHAR,
+  reason text NOT NULL,
+  created_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TYPE invoice_event_type AS ENUM('PAYMENT', 'PAYMENT_VOID', 'REFUND');
+
+CREATE TABLE invoice_events (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  seq BIGSERIAL NOT NULL,
+  invoice_id uuid NOT NULL REFERENCES invoices (id),
+  event_type invoice_event_type NOT NULL,
+  payment_id uuid REFERENCES invoice_payments (id),
+  refund_id uuid REFERENCES invoice_refunds (id),
+  amount INT NOT NULL,
+  paid_amount_after INT NOT NULL,
+  status_after invoice_status NOT NULL,
+  note text,
+  created_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX invoice_events_invoice_idx ON invoice_events (invoice_id, seq);
+
+CREATE FUNCTION append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER invoice_events_append_only BEFORE UPDATE OR DELETE ON invoice_events
+  FOR EACH ROW EXECUTE FUNCTION append_only();
@@ -0,0 +1,5 @@
+-- payment_method keeps the ONLINE value, Postgres cannot drop a value of an enum
+DROP TABLE online_payment_callbacks;
+DROP TABLE online_charges;
+
+DROP TYPE online_charge_status;
@@ -0,0 +1,35 @@
+ALTER TYPE payment_method ADD VALUE IF NOT EXISTS 'ONLINE';
+
+CREATE TYPE online_charge_status AS ENUM('PENDING', 'PAID', 'FAILED', 'EXPIRED', 'UNAPPLIED');
+
+CREATE TABLE online_charges (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  invoice_id uuid NOT NULL REFERENCES invoices (id),
+  provider VARCHAR NOT NULL,
+  amount INT NOT NULL CHECK (amount > 0),
+  status online_charge_status NOT NULL DEFAULT 'PENDING',
+  provider_ref VARCHAR,
+  payment_url VARCHAR,
+  method VARCHAR,
+  note text,
+  created_by uuid REFERENCES users (id),
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP,
+  paid_at TIMESTAMP
+);
+
+CREATE INDEX online_charges_pending_idx ON online_charges (created_at) WHERE status = 'PENDING';
+
+CREATE TABLE online_payment_callbacks (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  provider VARCHAR NOT NULL,
+  event_key VARCHAR NOT NULL,
+  order_id VARCHAR NOT NULL,
+  status VARCHAR NOT NULL,
+  amount INT NOT NULL,
+  source VARCHAR NOT NULL,
+  payload jsonb,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (provider, event_key)
+);
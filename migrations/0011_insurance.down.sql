@@ -0,0 +1,18 @@
+DROP TABLE insurance_claims;
+DROP TABLE claim_batches;
+DROP TABLE claim_batch_sequences;
+DROP TYPE claim_batch_status;
+DROP TYPE claim_status;
+
+ALTER TABLE invoice_items DROP COLUMN covered_amount;
+ALTER TABLE invoices
+  DROP COLUMN payer_id,
+  DROP COLUMN member_number,
+  DROP COLUMN covered_amount;
+
+DROP TABLE payer_coverage_rules;
+DROP TABLE patient_payers;
+DROP TABLE payers;
+
+DROP TYPE claim_format;
+DROP TYPE payer_type;
@@ -0,0 +1,88 @@
+CREATE TYPE payer_type AS ENUM('BPJS', 'PRIVATE');
+
+CREATE TYPE claim_format AS ENUM('CSV', 'JSON');
+
+CREATE TABLE payers (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  code VARCHAR NOT NULL UNIQUE,
+  name VARCHAR NOT NULL,
+  payer_type payer_type NOT NULL,
+  claim_format claim_format NOT NULL DEFAULT 'CSV',
+  claim_fields text,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE patient_payers (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  patient_id uuid NOT NULL REFERENCES users (id),
+  payer_id uuid NOT NULL REFERENCES payers (id),
+  member_number VARCHAR NOT NULL,
+  valid_until DATE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX patient_payers_active_idx ON patient_payers (patient_id, payer_id) WHERE deleted_at IS NULL;
+
+-- A rule without a reference covers every item of its type
+CREATE TABLE payer_coverage_rules (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  payer_id uuid NOT NULL REFERENCES payers (id),
+  item_type invoice_item_type NOT NULL,
+  reference_id uuid,
+  coverage_percent NUMERIC(5,2) NOT NULL CHECK (coverage_percent BETWEEN 0 AND 100),
+  max_amount INT NOT NULL DEFAULT 0 CHECK (max_amount >= 0),
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX payer_coverage_rules_item_idx ON payer_coverage_rules (payer_id, item_type, COALESCE(reference_id, '00000000-0000-0000-0000-000000000000'));
+
+ALTER TABLE invoices
+  ADD COLUMN payer_id uuid REFERENCES payers (id),
+  ADD COLUMN member_number VARCHAR,
+  ADD COLUMN covered_amount INT NOT NULL DEFAULT 0 CHECK (covered_amount >= 0);
+
+ALTER TABLE invoice_items ADD COLUMN covered_amount INT NOT NULL DEFAULT 0;
+
+CREATE TYPE claim_status AS ENUM('PENDING', 'SUBMITTED', 'APPROVED', 'REJECTED', 'CANCELED');
+
+CREATE TYPE claim_batch_status AS ENUM('SUBMITTED', 'APPROVED', 'PARTIALLY_APPROVED', 'REJECTED');
+
+CREATE TABLE claim_batch_sequences (
+  payer_id uuid NOT NULL REFERENCES payers (id),
+  period VARCHAR(6) NOT NULL,
+  last_number INT NOT NULL,
+  PRIMARY KEY (payer_id, period)
+);
+
+CREATE TABLE claim_batches (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  batch_number VARCHAR NOT NULL UNIQUE,
+  payer_id uuid NOT NULL REFERENCES payers (id),
+  status claim_batch_status NOT NULL DEFAULT 'SUBMITTED',
+  total_claimed INT NOT NULL,
+  total_approved INT NOT NULL DEFAULT 0,
+  submitted_by uuid REFERENCES users (id),
+  submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  decided_at TIMESTAMP
+);
+
+CREATE TABLE insurance_claims (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  invoice_id uuid NOT NULL REFERENCES invoices (id),
+  payer_id uuid NOT NULL REFERENCES payers (id),
+  member_number VARCHAR NOT NULL,
+  payment_id uuid NOT NULL REFERENCES invoice_payments (id),
+  batch_id uuid REFERENCES claim_batches (id),
+  status claim_status NOT NULL DEFAULT 'PENDING',
+  claimed_amount INT NOT NULL CHECK (claimed_amount > 0),
+  approved_amount INT NOT NULL DEFAULT 0,
+  rejection_reason text,
+  decided_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  decided_at TIMESTAMP
+);
+
+CREATE INDEX insurance_claims_pending_idx ON insurance_claims (payer_id) WHERE status = 'PENDING';
@@ -0,0 +1,2 @@
+DROP TABLE action_prices;
+DROP TABLE medicine_prices;
@@ -0,0 +1,27 @@
+-- medicines.price and actions.price keep the price an item was created with;
+-- the price in effect at a given time comes from its price list
+CREATE TABLE medicine_prices (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  medicine_id uuid NOT NULL REFERENCES medicines (id) ON DELETE CASCADE,
+  price INT NOT NULL CHECK (price > 0),
+  effective_from TIMESTAMP NOT NULL,
+  created_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (medicine_id, effective_from)
+);
+
+CREATE TABLE action_prices (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  action_id uuid NOT NULL REFERENCES actions (id) ON DELETE CASCADE,
+  price INT NOT NULL CHECK (price > 0),
+  effective_from TIMESTAMP NOT NULL,
+  created_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (action_id, effective_from)
+);
+
+INSERT INTO medicine_prices (medicine_id, price, effective_from, created_at)
+SELECT id, price, COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(created_at, CURRENT_TIMESTAMP) FROM medicines;
+
+INSERT INTO action_prices (action_id, price, effective_from, created_at)
+SELECT id, price, COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(created_at, CURRENT_TIMESTAMP) FROM actions;
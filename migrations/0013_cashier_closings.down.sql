@@ -0,0 +1 @@
+DROP TABLE cashier_closings;
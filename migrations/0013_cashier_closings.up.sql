@@ -0,0 +1,12 @@
+CREATE TABLE cashier_closings (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  cashier_id uuid NOT NULL REFERENCES users (id),
+  business_date DATE NOT NULL,
+  expected_cash INT NOT NULL,
+  counted_cash INT NOT NULL CHECK (counted_cash >= 0),
+  difference INT NOT NULL,
+  note text,
+  closed_by uuid REFERENCES users (id),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (cashier_id, business_date)
+);
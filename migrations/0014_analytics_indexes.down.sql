@@ -0,0 +1,3 @@
+DROP INDEX medical_records_created_idx;
+DROP INDEX bookings_schedule_idx;
+DROP INDEX doctor_schedules_date_idx;
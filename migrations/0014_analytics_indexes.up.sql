@@ -0,0 +1,5 @@
+CREATE INDEX doctor_schedules_date_idx ON doctor_schedules (schedule_date) WHERE deleted_at IS NULL;
+
+CREATE INDEX bookings_schedule_idx ON bookings (doctor_schedule_id) WHERE deleted_at IS NULL;
+
+CREATE INDEX medical_records_created_idx ON medical_records (created_at) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE documents;
+
+DROP TYPE document_type;
@@ -0,0 +1,14 @@
+CREATE TYPE document_type AS ENUM('INVOICE', 'PRESCRIPTION', 'SICK_LEAVE', 'VISIT_SUMMARY');
+
+-- every printed document, so that its QR code can be verified later
+CREATE TABLE documents (
+  id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+  code VARCHAR NOT NULL UNIQUE,
+  document_type document_type NOT NULL,
+  reference_id uuid NOT NULL,
+  doctor_name VARCHAR,
+  valid_from DATE,
+  valid_until DATE,
+  issued_by uuid REFERENCES users (id),
+  issued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE documents
+  DROP COLUMN signature,
+  DROP COLUMN revoked_at,
+  DROP COLUMN revoked_by,
+  DROP COLUMN revoke_reason;
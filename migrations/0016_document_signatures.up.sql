@@ -0,0 +1,7 @@
+-- Documents printed before signing keep a NULL signature and verify as
+-- unsigned, every document issued from now on is signed
+ALTER TABLE documents
+  ADD COLUMN signature VARCHAR,
+  ADD COLUMN revoked_at TIMESTAMP,
+  ADD COLUMN revoked_by uuid REFERENCES users (id),
+  ADD COLUMN revoke_reason text;
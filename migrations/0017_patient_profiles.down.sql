@@ -0,0 +1,3 @@
+DROP TABLE patient_profiles;
+
+DROP TYPE patient_gender;
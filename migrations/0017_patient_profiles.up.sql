@@ -0,0 +1,14 @@
+CREATE TYPE patient_gender AS ENUM('male', 'female', 'other', 'unknown');
+
+-- demographics of patients, filled in when they are imported from FHIR
+CREATE TABLE patient_profiles (
+  user_id uuid PRIMARY KEY REFERENCES users (id),
+  nik VARCHAR(16) UNIQUE,
+  full_name VARCHAR,
+  gender patient_gender NOT NULL DEFAULT 'unknown',
+  birth_date DATE,
+  phone VARCHAR,
+  address text,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP
+);
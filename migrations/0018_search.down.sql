@@ -0,0 +1,11 @@
+ALTER TABLE medical_records DROP COLUMN search_vector;
+ALTER TABLE actions DROP COLUMN search_vector;
+ALTER TABLE medicines DROP COLUMN search_vector;
+ALTER TABLE patient_profiles DROP COLUMN search_vector;
+ALTER TABLE users DROP COLUMN search_vector;
+
+DROP INDEX medical_records_diagnosis_trgm_idx;
+DROP INDEX actions_name_trgm_idx;
+DROP INDEX medicines_name_trgm_idx;
+DROP INDEX patient_profiles_full_name_trgm_idx;
+DROP INDEX users_username_trgm_idx;
@@ -0,0 +1,31 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- full-text documents and trigram indexes of everything the front desk searches
+ALTER TABLE users ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', username), 'A') ||
+  setweight(to_tsvector('simple', COALESCE(specialization, '')), 'B')) STORED;
+CREATE INDEX users_search_idx ON users USING GIN (search_vector);
+CREATE INDEX users_username_trgm_idx ON users USING GIN (username gin_trgm_ops);
+
+ALTER TABLE patient_profiles ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', COALESCE(full_name, '')), 'A') ||
+  setweight(to_tsvector('simple', COALESCE(nik, '') || ' ' || COALESCE(phone, '')), 'B')) STORED;
+CREATE INDEX patient_profiles_search_idx ON patient_profiles USING GIN (search_vector);
+CREATE INDEX patient_profiles_full_name_trgm_idx ON patient_profiles USING GIN (full_name gin_trgm_ops);
+
+ALTER TABLE medicines ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', name), 'A') ||
+  setweight(to_tsvector('simple', COALESCE(description, '')), 'B')) STORED;
+CREATE INDEX medicines_search_idx ON medicines USING GIN (search_vector);
+CREATE INDEX medicines_name_trgm_idx ON medicines USING GIN (name gin_trgm_ops);
+
+ALTER TABLE actions ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', name), 'A') ||
+  setweight(to_tsvector('simple', COALESCE(description, '')), 'B')) STORED;
+CREATE INDEX actions_search_idx ON actions USING GIN (search_vector);
+CREATE INDEX actions_name_trgm_idx ON actions USING GIN (name gin_trgm_ops);
+
+ALTER TABLE medical_records ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  to_tsvector('simple', diagnosis_results)) STORED;
+CREATE INDEX medical_records_search_idx ON medical_records USING GIN (search_vector);
+CREATE INDEX medical_records_diagnosis_trgm_idx ON medical_records USING GIN (diagnosis_results gin_trgm_ops);
@@ -0,0 +1,36 @@
+CREATE TABLE audit_logs (
+  id BIGSERIAL PRIMARY KEY,
+  actor_id VARCHAR NOT NULL,
+  actor_username VARCHAR NOT NULL,
+  actor_role VARCHAR NOT NULL,
+  action VARCHAR NOT NULL,
+  route VARCHAR NOT NULL,
+  entity VARCHAR NOT NULL,
+  entity_id VARCHAR NOT NULL,
+  before JSONB,
+  after JSONB,
+  diff JSONB,
+  status INT NOT NULL,
+  ip VARCHAR NOT NULL,
+  request_id VARCHAR NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  prev_hash VARCHAR NOT NULL,
+  hash VARCHAR NOT NULL
+);
+
+CREATE INDEX audit_logs_entity_idx ON audit_logs (entity, entity_id);
+CREATE INDEX audit_logs_actor_idx ON audit_logs (actor_id);
+CREATE INDEX audit_logs_created_at_idx ON audit_logs (created_at);
+
+-- entries are only ever appended, rewriting one would break the hash chain anyway
+CREATE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+  FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+
+CREATE TRIGGER audit_logs_no_truncate BEFORE TRUNCATE ON audit_logs
+  FOR EACH STATEMENT EXECUTE FUNCTION audit_logs_append_only();
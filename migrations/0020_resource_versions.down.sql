@@ -0,0 +1,2 @@
+ALTER TABLE doctor_schedules DROP COLUMN IF EXISTS version;
+ALTER TABLE medicines DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- Every change of a row increases its version, updates and deletes expecting
+-- an older version are refused.
+ALTER TABLE medicines ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE doctor_schedules ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,17 @@
+-- Requests sent with an Idempotency-Key, kept with their response so that a
+-- retry gets the same response instead of running again. The response is NULL
+-- while the first request is still running.
+CREATE TABLE idempotency_keys (
+  actor_id VARCHAR NOT NULL,
+  key VARCHAR NOT NULL,
+  route VARCHAR NOT NULL,
+  request_hash VARCHAR NOT NULL,
+  status INT,
+  content_type VARCHAR,
+  body BYTEA,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (actor_id, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
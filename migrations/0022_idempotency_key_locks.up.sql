@@ -0,0 +1,8 @@
+-- A request holds its key until locked_until while it runs. A key left without
+-- a response past it, e.g. when the server stopped mid-request, is taken over
+-- by a retry of the same request instead of being refused until it expires.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMP;
+
+UPDATE idempotency_keys SET locked_until = created_at;
+
+ALTER TABLE idempotency_keys ALTER COLUMN locked_until SET NOT NULL;
@@ -0,0 +1,2 @@
+-- Actions are versioned like medicines, their price feeds the invoices.
+ALTER TABLE actions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Users are versioned like actions, so admins do not overwrite each other.
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
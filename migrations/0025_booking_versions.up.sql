@@ -0,0 +1,2 @@
+-- Bookings are versioned like users, a booking moved or canceled meanwhile is not overwritten.
+ALTER TABLE bookings ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
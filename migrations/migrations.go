@@ -0,0 +1,13 @@
+// Package migrations holds the numbered schema migrations, embedded in the
+// binary and run by the migrate command.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var Files embed.FS
+
+// Seeds are sample data scripts run by the seed command, after migrating.
+//
+//go:embed seeds/*.sql
+var Seeds embed.FS
@@ -4,24 +4,6 @@ VALUES
     ('67b65471-eb1f-46ec-a043-959a5cc85778', 'Budi', '$2a$10$bmiD3Nuo3R7CXHTiQcsLFeEhGhNkx6vLfcN50gKgNu6/v.qlWDSZm', 'PATIENT', NULL, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
     ('5bc18dd0-58cb-4612-8dc3-5fc2419b7f29', 'Joko', '$2a$10$bmiD3Nuo3R7CXHTiQcsLFeEhGhNkx6vLfcN50gKgNu6/v.qlWDSZm', 'DOCTOR', 'Ortopedi', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
 
-INSERT INTO mst_schedule_time(id, start_at, end_at, created_at, updated_at) 
-VALUES
-     (1, '08:00:30', '08:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (2, '08:30:30', '09:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (3, '09:00:30', '09:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (4, '09:30:30', '10:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (5, '10:00:30', '10:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (6, '10:30:30', '11:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (7, '11:00:30', '11:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (8, '11:30:30', '12:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (9, '13:00:30', '13:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (10, '13:30:30', '14:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (11, '14:00:30', '14:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (12, '14:30:30', '15:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (13, '15:00:30', '15:30:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
-     (14, '15:30:30', '16:00:00', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
-
-
 INSERT INTO doctor_schedules(id, doctor_id, schedule_date, start_at, end_at, created_at, updated_at) 
 VALUES
     ('74d93144-6f2e-4bbc-9f89-973c62d3ac54', '5bc18dd0-58cb-4612-8dc3-5fc2419b7f29', '2024-03-14', 1, 8, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
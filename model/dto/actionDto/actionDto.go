@@ -5,9 +5,11 @@ type Action struct {
 	Name        string      `json:"name,omitempty"`
 	Price       int         `json:"price,omitempty"`
 	Description interface{} `json:"description,omitempty"`
+	UpdatedBy   string      `json:"-"`
 	CreatedAt   string      `json:"created_at,omitempty"`
 	UpdatedAt   string      `json:"updated_at,omitempty"`
 	DeletedAt   string      `json:"deleted_at,omitempty"`
+	Version     int         `json:"version,omitempty"`
 }
 
 type CreateRequest struct {
@@ -21,4 +23,7 @@ type UpdateRequest struct {
 	Name        string `json:"name"`
 	Price       int    `json:"price"`
 	Description string `json:"description"`
+	UpdatedBy   string `json:"-"`
+	// Version the action is expected to be at, 0 updates any version
+	Version int `json:"-"`
 }
\ No newline at end of file
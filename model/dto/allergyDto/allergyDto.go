@@ -0,0 +1,58 @@
+package allergyDto
+
+type Allergy struct {
+	ID        string      `json:"id,omitempty"`
+	PatientID string      `json:"patient_id,omitempty"`
+	Allergen  string      `json:"allergen,omitempty"`
+	Reaction  interface{} `json:"reaction,omitempty"`
+	Severity  string      `json:"severity,omitempty"`
+	CreatedAt string      `json:"created_at,omitempty"`
+	UpdatedAt string      `json:"updated_at,omitempty"`
+	DeletedAt string      `json:"deleted_at,omitempty"`
+}
+
+type CreateRequest struct {
+	PatientID string      `json:"patient_id" validate:"required,uuid"`
+	Allergen  string      `json:"allergen" validate:"required"`
+	Reaction  interface{} `json:"reaction"`
+	Severity  string      `json:"severity" validate:"required,enum=MILD MODERATE SEVERE"`
+}
+
+type CheckRequest struct {
+	BookingID   string   `json:"booking_id" validate:"required"`
+	MedicineIDs []string `json:"medicine_ids" validate:"required"`
+}
+
+type Medicine struct {
+	ID   string
+	Name string
+}
+
+type Acknowledgement struct {
+	WarningCode string `json:"warning_code" validate:"required"`
+	Reason      string `json:"reason" validate:"required"`
+}
+
+type Issue struct {
+	Code        string   `json:"code"`
+	Kind        string   `json:"kind"`
+	Severity    string   `json:"severity"`
+	MedicineIDs []string `json:"medicine_ids"`
+	Message     string   `json:"message"`
+}
+
+type CheckResult struct {
+	Errors   []Issue `json:"errors"`
+	Warnings []Issue `json:"warnings"`
+}
+
+// PrescriptionError is returned when a prescription has blocking issues or
+// warnings the doctor has not acknowledged yet.
+type PrescriptionError struct {
+	Message string
+	Issues  []Issue
+}
+
+func (e PrescriptionError) Error() string {
+	return e.Message
+}
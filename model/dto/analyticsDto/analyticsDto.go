@@ -0,0 +1,73 @@
+package analyticsDto
+
+// Filter limits the analytics to visits between StartDate and EndDate, both
+// inclusive.
+type Filter struct {
+	StartDate string
+	EndDate   string
+	DoctorID  string
+	GroupBy   string
+	Limit     int
+}
+
+// BookingCount counts the bookings of a day, a doctor or a status. A booking
+// still waiting after the day of its schedule is counted as a no-show.
+type BookingCount struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Total    int    `json:"total"`
+	Waiting  int    `json:"waiting"`
+	Done     int    `json:"done"`
+	Canceled int    `json:"canceled"`
+	NoShow   int    `json:"no_show"`
+}
+
+// BookingRates are the share of bookings canceled and not shown up for, in
+// percent of all bookings.
+type BookingRates struct {
+	Total            int     `json:"total"`
+	Done             int     `json:"done"`
+	Canceled         int     `json:"canceled"`
+	NoShow           int     `json:"no_show"`
+	CancellationRate float64 `json:"cancellation_rate"`
+	NoShowRate       float64 `json:"no_show_rate"`
+}
+
+// LeadTime is the time between making a booking and the start of its slot.
+type LeadTime struct {
+	DoctorID     string  `json:"doctor_id,omitempty"`
+	DoctorName   string  `json:"doctor_name,omitempty"`
+	Bookings     int     `json:"bookings"`
+	AverageHours float64 `json:"average_hours"`
+}
+
+type LeadTimes struct {
+	Overall LeadTime   `json:"overall"`
+	Doctors []LeadTime `json:"doctors"`
+}
+
+// SlotUtilization is how many of the slots of a doctor schedule are taken by
+// bookings that were not canceled.
+type SlotUtilization struct {
+	ScheduleID   string  `json:"schedule_id"`
+	DoctorID     string  `json:"doctor_id"`
+	DoctorName   string  `json:"doctor_name"`
+	ScheduleDate string  `json:"schedule_date"`
+	Slots        int     `json:"slots"`
+	Booked       int     `json:"booked"`
+	Utilization  float64 `json:"utilization"`
+}
+
+// Diagnosis counts the medical records with the same diagnosis, ignoring case
+// and surrounding spaces.
+type Diagnosis struct {
+	Diagnosis string `json:"diagnosis"`
+	Records   int    `json:"records"`
+}
+
+type PrescribedMedicine struct {
+	MedicineID string `json:"medicine_id"`
+	Name       string `json:"name"`
+	Records    int    `json:"records"`
+	Quantity   int    `json:"quantity"`
+}
@@ -0,0 +1,43 @@
+package auditDto
+
+import "encoding/json"
+
+// Entry is who did what to which record, and when. Before and After are the
+// record around a change and Diff the fields it changed, each as
+// {"from": ..., "to": ...}. Reads only name the record. Hash covers the entry
+// and the hash of the one before it, so changing or removing an entry breaks
+// every hash after it.
+type Entry struct {
+	ID            int64           `json:"id"`
+	ActorID       string          `json:"actor_id"`
+	ActorUsername string          `json:"actor_username"`
+	ActorRole     string          `json:"actor_role"`
+	Action        string          `json:"action"`
+	Route         string          `json:"route"`
+	Entity        string          `json:"entity"`
+	EntityID      string          `json:"entity_id"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	Diff          json.RawMessage `json:"diff,omitempty"`
+	Status        int             `json:"status"`
+	IP            string          `json:"ip"`
+	RequestID     string          `json:"request_id"`
+	CreatedAt     string          `json:"created_at"`
+	PrevHash      string          `json:"prev_hash"`
+	Hash          string          `json:"hash"`
+}
+
+// Change is how one field of a record changed.
+type Change struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// Verification is the result of checking the hash chain. BrokenAt is the
+// first entry that does not match, when the chain is not valid.
+type Verification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
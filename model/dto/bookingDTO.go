@@ -15,6 +15,8 @@ type (
 	UpdateBookingSchedule struct {
 		DoctorScheduleID uuid.UUID `json:"doctor_schedule_id"`
 		MstScheduleID    int       `json:"mst_schedule_id"` //refer to mst_schedule id
+		// Version the booking is expected to be at, 0 updates any version
+		Version int `json:"-"`
 		// ScheduleID uuid.UUID `json:"schedule_id" validate:"required"`
 		// PatientID  uuid.UUID `json:"patient_id" validate:"required"`
 		// // StartAt    string    `json:"start_at" validate:"required,regex=^((07|08|09|1[0-6]):[0-2][0-9]:[0-5][0-9]|16:30:00)$"`
@@ -7,6 +7,21 @@ type ConfigData struct {
 	AppConfig appConfig
 }
 
+// Redacted is a copy of the config with its secrets blanked, safe to log.
+func (c ConfigData) Redacted() ConfigData {
+	c.DbConfig.Pass = redacted(c.DbConfig.Pass)
+	c.AppConfig.PaymentServerKey = redacted(c.AppConfig.PaymentServerKey)
+	c.AppConfig.DocumentSigningKey = redacted(c.AppConfig.DocumentSigningKey)
+	return c
+}
+
+func redacted(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "*****"
+}
+
 type dbConfig struct {
 	Host string
 	Port string
@@ -20,7 +35,28 @@ type dbConfig struct {
 }
 
 type appConfig struct {
-	Port string
+	Port               string
+	DrugKnowledgeFile  string
+	LowStockWebhookURL string
+	ReservationTTL     string
+	IdempotencyKeyTTL  string
+	IdempotencyLockTTL string
+	ConsultationFee    int
+	TaxRate            float64
+	PaymentProvider    string
+	PaymentChargeURL   string
+	PaymentStatusURL   string
+	PaymentServerKey   string
+	PaymentChargeTTL   string
+	ClinicName         string
+	ClinicAddress      string
+	ClinicPhone        string
+	ClinicLogoFile     string
+	VerifyURL          string
+	FhirBaseURL        string
+	DocumentSigningKey string
+	RequestTimeout     string
+	RouteTimeouts      string
 }
 
 type Db struct {
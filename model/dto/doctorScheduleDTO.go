@@ -20,5 +20,7 @@ type (
 		ScheduleDate string `json:"schedule_date"`
 		StartAt      int    `json:"start_at"`
 		EndAt        int    `json:"end_at"`
+		// Version the schedule is expected to be at, 0 updates any version
+		Version int `json:"-"`
 	}
 )
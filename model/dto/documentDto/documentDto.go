@@ -0,0 +1,76 @@
+package documentDto
+
+// Clinic is printed in the header of every document. The QR code of a
+// document links to VerifyURL and its facts are signed with SigningKey.
+type Clinic struct {
+	Name       string
+	Address    string
+	Phone      string
+	LogoFile   string
+	VerifyURL  string
+	SigningKey string
+}
+
+// Document is a printed document. Code is the unguessable part of the link in
+// its QR code and Signature signs the facts stored about it. ValidFrom and
+// ValidUntil are only set on sick-leave letters.
+type Document struct {
+	ID           string `json:"id"`
+	Code         string `json:"code"`
+	DocumentType string `json:"document_type"`
+	ReferenceID  string `json:"reference_id"`
+	DoctorName   string `json:"doctor_name,omitempty"`
+	ValidFrom    string `json:"valid_from,omitempty"`
+	ValidUntil   string `json:"valid_until,omitempty"`
+	Signature    string `json:"signature"`
+	IssuedBy     string `json:"issued_by,omitempty"`
+	IssuedAt     string `json:"issued_at"`
+	RevokedAt    string `json:"revoked_at,omitempty"`
+	RevokedBy    string `json:"revoked_by,omitempty"`
+	RevokeReason string `json:"revoke_reason,omitempty"`
+}
+
+// Verification is what anyone holding a document may learn about it. It
+// leaves out the patient and everything about the visit.
+type Verification struct {
+	Code         string `json:"code"`
+	Status       string `json:"status"`
+	DocumentType string `json:"document_type,omitempty"`
+	IssuedOn     string `json:"issued_on,omitempty"`
+	DoctorName   string `json:"doctor_name,omitempty"`
+	ValidFrom    string `json:"valid_from,omitempty"`
+	ValidUntil   string `json:"valid_until,omitempty"`
+	RevokedOn    string `json:"revoked_on,omitempty"`
+}
+
+type RevokeRequest struct {
+	Reason    string `json:"reason" validate:"required"`
+	RevokedBy string `json:"-"`
+}
+
+// Visit is the visit a medical record was written for.
+type Visit struct {
+	MedicalRecordID      string
+	PatientName          string
+	DoctorName           string
+	DoctorSpecialization string
+	VisitDate            string
+	Complaint            string
+	Diagnosis            string
+}
+
+// SickLeaveRequest asks the doctor's statement that the patient needs rest
+// for a number of days. Without StartDate the rest starts on the visit date.
+type SickLeaveRequest struct {
+	MedicalRecordID string `json:"-"`
+	StartDate       string `json:"start_date"`
+	Days            int    `json:"days" validate:"required,min=1,max=30"`
+	Note            string `json:"note"`
+	IssuedBy        string `json:"-"`
+}
+
+// File is a generated PDF.
+type File struct {
+	FileName string
+	Content  []byte
+}
@@ -0,0 +1,130 @@
+package fhirDto
+
+import "encoding/json"
+
+// Filter holds the search parameters the FHIR endpoints support. Patient,
+// Practitioner and Encounter are ids, Identifier is a NIK and Name matches
+// part of a patient's name.
+type Filter struct {
+	ID           string
+	Patient      string
+	Practitioner string
+	Encounter    string
+	Identifier   string
+	Name         string
+}
+
+// PatientRow is a patient user with the demographics imported for them, if any.
+type PatientRow struct {
+	ID        string
+	Username  string
+	NIK       string
+	FullName  string
+	Gender    string
+	BirthDate string
+	Phone     string
+	Address   string
+	UpdatedAt string
+}
+
+type PractitionerRow struct {
+	ID             string
+	Username       string
+	Specialization string
+	UpdatedAt      string
+}
+
+// AppointmentRow is a booking with the date and slot it was booked for.
+type AppointmentRow struct {
+	ID           string
+	PatientID    string
+	PatientName  string
+	DoctorID     string
+	DoctorName   string
+	ScheduleDate string
+	StartAt      string
+	EndAt        string
+	Status       string
+	Complaint    string
+	CreatedAt    string
+}
+
+// EncounterRow is a medical record with the visit it was written for. It is
+// exported both as an Encounter and as the Condition diagnosed in it.
+type EncounterRow struct {
+	ID           string
+	BookingID    string
+	PatientID    string
+	PatientName  string
+	DoctorID     string
+	DoctorName   string
+	ScheduleDate string
+	StartAt      string
+	EndAt        string
+	Diagnosis    string
+	CreatedAt    string
+}
+
+type MedicationRow struct {
+	ID              string
+	MedicalRecordID string
+	PatientID       string
+	DoctorID        string
+	MedicineName    string
+	MedicineType    string
+	Quantity        int
+	Paid            bool
+	CreatedAt       string
+}
+
+type ProcedureRow struct {
+	ID              string
+	MedicalRecordID string
+	PatientID       string
+	DoctorID        string
+	ActionName      string
+	CreatedAt       string
+}
+
+// IncomingBundle is a bundle posted to the server. Its resources stay raw
+// until their resourceType is checked.
+type IncomingBundle struct {
+	ResourceType string          `json:"resourceType"`
+	Type         string          `json:"type"`
+	Entry        []IncomingEntry `json:"entry"`
+}
+
+type IncomingEntry struct {
+	FullURL  string          `json:"fullUrl"`
+	Resource json.RawMessage `json:"resource"`
+}
+
+// PatientProfile is an imported patient. Username and Password are only used
+// when no patient with the same NIK exists yet.
+type PatientProfile struct {
+	Username  string
+	Password  string
+	NIK       string
+	FullName  string
+	Gender    string
+	BirthDate string
+	Phone     string
+	Address   string
+}
+
+// ImportResult tells whether an imported patient was created or matched an
+// existing one by NIK.
+type ImportResult struct {
+	ID      string
+	Created bool
+}
+
+// ValidationError lists what makes posted resources invalid. It is returned
+// to the client as an OperationOutcome.
+type ValidationError struct {
+	Issues []OperationOutcomeIssue
+}
+
+func (e *ValidationError) Error() string {
+	return "the bundle contains invalid resources"
+}
@@ -0,0 +1,18 @@
+package idempotencyDto
+
+// Record is a request sent with an Idempotency-Key by an actor and, once it
+// has run, its response. RequestHash covers the method, path and body of the
+// request, and Status is 0 while the request is still running. A request
+// holds the key until LockedUntil, past it a retry may take the key over.
+type Record struct {
+	ActorID     string
+	Key         string
+	Route       string
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   string
+	ExpiresAt   string
+	LockedUntil string
+}
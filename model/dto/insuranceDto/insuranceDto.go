@@ -0,0 +1,162 @@
+package insuranceDto
+
+import "avengers-clinic/model/dto/invoiceDto"
+
+// Payer pays part of the bills of its members, e.g. BPJS Kesehatan or a
+// private insurer. Claims are exported in ClaimFormat with ClaimFields as the
+// columns, in that order.
+type Payer struct {
+	ID          string   `json:"id,omitempty"`
+	Code        string   `json:"code,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	PayerType   string   `json:"payer_type,omitempty"`
+	ClaimFormat string   `json:"claim_format,omitempty"`
+	ClaimFields []string `json:"claim_fields,omitempty"`
+	CreatedAt   string   `json:"created_at,omitempty"`
+	UpdatedAt   string   `json:"updated_at,omitempty"`
+}
+
+type PayerRequest struct {
+	ID          string   `json:"-"`
+	Code        string   `json:"code" validate:"required"`
+	Name        string   `json:"name" validate:"required"`
+	PayerType   string   `json:"payer_type" validate:"required,enum=BPJS PRIVATE"`
+	ClaimFormat string   `json:"claim_format" validate:"required,enum=CSV JSON"`
+	ClaimFields []string `json:"claim_fields"`
+}
+
+// Membership links a patient to a payer under the member number the payer
+// knows them by.
+type Membership struct {
+	ID           string `json:"id,omitempty"`
+	PatientID    string `json:"patient_id,omitempty"`
+	PayerID      string `json:"payer_id,omitempty"`
+	PayerName    string `json:"payer_name,omitempty"`
+	MemberNumber string `json:"member_number,omitempty"`
+	ValidUntil   string `json:"valid_until,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+}
+
+type MembershipRequest struct {
+	PatientID    string `json:"patient_id" validate:"required,uuid"`
+	PayerID      string `json:"payer_id" validate:"required,uuid"`
+	MemberNumber string `json:"member_number" validate:"required"`
+	ValidUntil   string `json:"valid_until"`
+}
+
+// CoverageRule is the share of an item the payer pays, capped at MaxAmount
+// per item when it is above zero. A rule without a reference applies to every
+// item of its type that has no rule of its own. Consultations are referenced by
+// the doctor giving them.
+type CoverageRule struct {
+	ID              string  `json:"id,omitempty"`
+	PayerID         string  `json:"payer_id,omitempty"`
+	ItemType        string  `json:"item_type,omitempty"`
+	ReferenceID     string  `json:"reference_id,omitempty"`
+	CoveragePercent float64 `json:"coverage_percent"`
+	MaxAmount       int     `json:"max_amount"`
+	UpdatedAt       string  `json:"updated_at,omitempty"`
+}
+
+type CoverageRuleRequest struct {
+	PayerID         string  `json:"-"`
+	ItemType        string  `json:"item_type" validate:"required,enum=CONSULTATION MEDICINE ACTION"`
+	ReferenceID     string  `json:"reference_id" validate:"omitempty,uuid"`
+	CoveragePercent float64 `json:"coverage_percent" validate:"min=0,max=100"`
+	MaxAmount       int     `json:"max_amount" validate:"min=0"`
+}
+
+// CoverableInvoice is what applying a coverage needs to know of an invoice.
+type CoverableInvoice struct {
+	ID        string
+	Status    string
+	PatientID string
+	Total     int
+	Items     []invoiceDto.Item
+}
+
+type CoverageRequest struct {
+	PayerID string `json:"payer_id" validate:"required,uuid"`
+}
+
+// Coverage splits an invoice between the payer and the patient.
+type Coverage struct {
+	InvoiceID     string            `json:"invoice_id,omitempty"`
+	PayerID       string            `json:"payer_id,omitempty"`
+	MemberNumber  string            `json:"member_number,omitempty"`
+	Total         int               `json:"total"`
+	CoveredAmount int               `json:"covered_amount"`
+	PatientAmount int               `json:"patient_amount"`
+	Items         []invoiceDto.Item `json:"items,omitempty"`
+}
+
+// Claim asks the payer for the covered part of an invoice. It is opened when
+// the invoice is issued and sent to the payer in a batch.
+type Claim struct {
+	ID              string `json:"id,omitempty"`
+	InvoiceID       string `json:"invoice_id,omitempty"`
+	InvoiceNumber   string `json:"invoice_number,omitempty"`
+	PayerID         string `json:"payer_id,omitempty"`
+	BatchID         string `json:"batch_id,omitempty"`
+	PaymentID       string `json:"payment_id,omitempty"`
+	MemberNumber    string `json:"member_number,omitempty"`
+	PatientName     string `json:"patient_name,omitempty"`
+	DoctorName      string `json:"doctor_name,omitempty"`
+	ServiceDate     string `json:"service_date,omitempty"`
+	Diagnosis       string `json:"diagnosis,omitempty"`
+	Status          string `json:"status,omitempty"`
+	ClaimedAmount   int    `json:"claimed_amount"`
+	ApprovedAmount  int    `json:"approved_amount"`
+	RejectionReason string `json:"rejection_reason,omitempty"`
+	DecidedBy       string `json:"decided_by,omitempty"`
+	CreatedAt       string `json:"created_at,omitempty"`
+	DecidedAt       string `json:"decided_at,omitempty"`
+}
+
+type ClaimFilter struct {
+	Status  string
+	PayerID string
+	BatchID string
+}
+
+// Batch is a set of claims submitted to a payer at once.
+type Batch struct {
+	ID            string  `json:"id,omitempty"`
+	BatchNumber   string  `json:"batch_number,omitempty"`
+	PayerID       string  `json:"payer_id,omitempty"`
+	PayerName     string  `json:"payer_name,omitempty"`
+	Status        string  `json:"status,omitempty"`
+	TotalClaimed  int     `json:"total_claimed"`
+	TotalApproved int     `json:"total_approved"`
+	SubmittedBy   string  `json:"submitted_by,omitempty"`
+	SubmittedAt   string  `json:"submitted_at,omitempty"`
+	DecidedAt     string  `json:"decided_at,omitempty"`
+	Claims        []Claim `json:"claims,omitempty"`
+}
+
+type BatchRequest struct {
+	PayerID     string `json:"payer_id" validate:"required,uuid"`
+	SubmittedBy string `json:"-"`
+}
+
+// DecisionRequest records the answer of the payer on claims of a batch.
+type DecisionRequest struct {
+	Decisions []ClaimDecision `json:"decisions" validate:"required,min=1,dive"`
+	DecidedBy string          `json:"-"`
+}
+
+// ClaimDecision approves a claim for ApprovedAmount, the full amount claimed
+// when it is zero, or rejects it.
+type ClaimDecision struct {
+	ClaimID        string `json:"claim_id" validate:"required,uuid"`
+	Status         string `json:"status" validate:"required,enum=APPROVED REJECTED"`
+	ApprovedAmount int    `json:"approved_amount" validate:"min=0"`
+	Reason         string `json:"reason"`
+}
+
+// Export is a claim file ready to be submitted to the payer.
+type Export struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
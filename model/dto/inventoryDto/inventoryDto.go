@@ -0,0 +1,140 @@
+package inventoryDto
+
+import "avengers-clinic/model/dto/medicineBatchDto"
+
+// Movement is a single entry of the append-only stock ledger. Quantity is
+// signed: receipts and returns are positive, dispenses and write-offs negative.
+type Movement struct {
+	ID            string `json:"id,omitempty"`
+	MedicineID    string `json:"medicine_id,omitempty"`
+	MedicineName  string `json:"medicine_name,omitempty"`
+	BatchID       string `json:"batch_id,omitempty"`
+	MovementType  string `json:"movement_type,omitempty"`
+	Quantity      int    `json:"quantity"`
+	BalanceAfter  int    `json:"balance_after"`
+	ReferenceType string `json:"reference_type,omitempty"`
+	ReferenceID   string `json:"reference_id,omitempty"`
+	Note          string `json:"note,omitempty"`
+	CreatedBy     string `json:"created_by,omitempty"`
+	CreatedAt     string `json:"created_at,omitempty"`
+}
+
+type MovementFilter struct {
+	MedicineID   string
+	MovementType string
+	StartDate    string
+	EndDate      string
+}
+
+type ReceiptItemRequest struct {
+	MedicineID   string `json:"medicine_id" validate:"required,uuid"`
+	LotNumber    string `json:"lot_number" validate:"required"`
+	ExpiryDate   string `json:"expiry_date" validate:"required"`
+	PurchaseCost int    `json:"purchase_cost" validate:"min=0"`
+	Quantity     int    `json:"quantity" validate:"required,min=1"`
+}
+
+type ReceiptRequest struct {
+	ReceiptNumber string               `json:"receipt_number" validate:"required"`
+	Supplier      string               `json:"supplier" validate:"required"`
+	Note          string               `json:"note"`
+	Items         []ReceiptItemRequest `json:"items" validate:"required,min=1,dive"`
+	ReceivedBy    string               `json:"-"`
+}
+
+type Receipt struct {
+	ID            string                   `json:"id,omitempty"`
+	ReceiptNumber string                   `json:"receipt_number,omitempty"`
+	Supplier      string                   `json:"supplier,omitempty"`
+	Note          string                   `json:"note,omitempty"`
+	ReceivedBy    string                   `json:"received_by,omitempty"`
+	Items         []medicineBatchDto.Batch `json:"items,omitempty"`
+	CreatedAt     string                   `json:"created_at,omitempty"`
+}
+
+// AdjustmentRequest covers manual movements: returns always add stock, while
+// adjustments carry the sign of the correction and need a note.
+type AdjustmentRequest struct {
+	MedicineID  string `json:"medicine_id" validate:"required,uuid"`
+	BatchID     string `json:"batch_id" validate:"omitempty,uuid"`
+	Quantity    int    `json:"quantity" validate:"required"`
+	ReferenceID string `json:"reference_id"`
+	Note        string `json:"note"`
+	CreatedBy   string `json:"-"`
+}
+
+type Reconciliation struct {
+	MedicineID   string `json:"medicine_id"`
+	MedicineName string `json:"medicine_name"`
+	Stock        int    `json:"stock"`
+	LedgerStock  int    `json:"ledger_stock"`
+	Difference   int    `json:"difference"`
+}
+
+type OpnameRequest struct {
+	Note      string `json:"note"`
+	StartedBy string `json:"-"`
+}
+
+type OpnameItem struct {
+	MedicineID      string `json:"medicine_id"`
+	MedicineName    string `json:"medicine_name,omitempty"`
+	Price           int    `json:"price"`
+	SystemQuantity  int    `json:"system_quantity"`
+	CountedQuantity *int   `json:"counted_quantity"`
+	Variance        int    `json:"variance"`
+	VarianceValue   int    `json:"variance_value"`
+}
+
+type Opname struct {
+	ID          string       `json:"id"`
+	Status      string       `json:"status"`
+	Note        string       `json:"note,omitempty"`
+	StartedBy   string       `json:"started_by,omitempty"`
+	CompletedBy string       `json:"completed_by,omitempty"`
+	CreatedAt   string       `json:"created_at,omitempty"`
+	CompletedAt string       `json:"completed_at,omitempty"`
+	Items       []OpnameItem `json:"items,omitempty"`
+}
+
+type CountRequest struct {
+	MedicineID      string `json:"medicine_id" validate:"required,uuid"`
+	CountedQuantity int    `json:"counted_quantity" validate:"min=0"`
+}
+
+type OpnameCountRequest struct {
+	Items []CountRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type VarianceReport struct {
+	OpnameID           string       `json:"opname_id"`
+	Status             string       `json:"status"`
+	Items              []OpnameItem `json:"items"`
+	TotalVariance      int          `json:"total_variance"`
+	TotalVarianceValue int          `json:"total_variance_value"`
+}
+
+type ThresholdRequest struct {
+	MedicineID      string `json:"-"`
+	MinStock        int    `json:"min_stock" validate:"min=0"`
+	ReorderQuantity int    `json:"reorder_quantity" validate:"min=0"`
+}
+
+type StockLevel struct {
+	MedicineID      string `json:"medicine_id"`
+	MedicineName    string `json:"medicine_name"`
+	Stock           int    `json:"stock"`
+	MinStock        int    `json:"min_stock"`
+	ReorderQuantity int    `json:"reorder_quantity"`
+	Alerted         bool   `json:"alerted"`
+}
+
+// ReorderSuggestion is a stock level with its recent consumption. DaysOfCover
+// is left out for medicines that were not dispensed in the window.
+type ReorderSuggestion struct {
+	StockLevel
+	Consumed          int      `json:"consumed"`
+	AverageDailyUsage float64  `json:"average_daily_usage"`
+	DaysOfCover       *float64 `json:"days_of_cover,omitempty"`
+	SuggestedQuantity int      `json:"suggested_quantity"`
+}
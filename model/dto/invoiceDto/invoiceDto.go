@@ -0,0 +1,154 @@
+package invoiceDto
+
+// Invoice bills a medical record. Amounts are in rupiah; the tax is charged on
+// the subtotal after the discount. PaidAmount is what has been collected net of
+// voided payments and refunds. CoveredAmount is the part claimed from the payer
+// of the patient, PatientAmount what is left for the patient.
+type Invoice struct {
+	ID              string    `json:"id,omitempty"`
+	InvoiceNumber   string    `json:"invoice_number,omitempty"`
+	MedicalRecordID string    `json:"medical_record_id,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	Items           []Item    `json:"items,omitempty"`
+	Subtotal        int       `json:"subtotal"`
+	DiscountPercent float64   `json:"discount_percent"`
+	DiscountAmount  int       `json:"discount_amount"`
+	TaxRate         float64   `json:"tax_rate"`
+	TaxAmount       int       `json:"tax_amount"`
+	Total           int       `json:"total"`
+	PaidAmount      int       `json:"paid_amount"`
+	RefundedAmount  int       `json:"refunded_amount"`
+	Balance         int       `json:"balance"`
+	PayerID         string    `json:"payer_id,omitempty"`
+	MemberNumber    string    `json:"member_number,omitempty"`
+	CoveredAmount   int       `json:"covered_amount"`
+	PatientAmount   int       `json:"patient_amount"`
+	Payments        []Payment `json:"payments,omitempty"`
+	Refunds         []Refund  `json:"refunds,omitempty"`
+	Note            string    `json:"note,omitempty"`
+	CreatedBy       string    `json:"created_by,omitempty"`
+	IssuedBy        string    `json:"issued_by,omitempty"`
+	VoidedBy        string    `json:"voided_by,omitempty"`
+	VoidReason      string    `json:"void_reason,omitempty"`
+	CreatedAt       string    `json:"created_at,omitempty"`
+	UpdatedAt       string    `json:"updated_at,omitempty"`
+	IssuedAt        string    `json:"issued_at,omitempty"`
+	PaidAt          string    `json:"paid_at,omitempty"`
+	VoidedAt        string    `json:"voided_at,omitempty"`
+}
+
+type Item struct {
+	ID            string `json:"id,omitempty"`
+	ItemType      string `json:"item_type,omitempty"`
+	ReferenceID   string `json:"reference_id,omitempty"`
+	Description   string `json:"description,omitempty"`
+	Quantity      int    `json:"quantity"`
+	UnitPrice     int    `json:"unit_price"`
+	Amount        int    `json:"amount"`
+	CoveredAmount int    `json:"covered_amount"`
+}
+
+// Payment is one tender towards an invoice. For cash the tendered amount may be
+// more than the amount, the difference is given back as change.
+type Payment struct {
+	ID             string `json:"id,omitempty"`
+	InvoiceID      string `json:"invoice_id,omitempty"`
+	Method         string `json:"method,omitempty"`
+	Amount         int    `json:"amount"`
+	TenderedAmount int    `json:"tendered_amount"`
+	ChangeAmount   int    `json:"change_amount"`
+	Reference      string `json:"reference,omitempty"`
+	Status         string `json:"status,omitempty"`
+	CashierID      string `json:"cashier_id,omitempty"`
+	VoidReason     string `json:"void_reason,omitempty"`
+	VoidedBy       string `json:"voided_by,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty"`
+	VoidedAt       string `json:"voided_at,omitempty"`
+}
+
+type Refund struct {
+	ID        string `json:"id,omitempty"`
+	InvoiceID string `json:"invoice_id,omitempty"`
+	Method    string `json:"method,omitempty"`
+	Amount    int    `json:"amount"`
+	Reference string `json:"reference,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// Event is an entry of the append-only audit trail of the money collected on
+// an invoice.
+type Event struct {
+	ID              string `json:"id,omitempty"`
+	InvoiceID       string `json:"invoice_id,omitempty"`
+	EventType       string `json:"event_type,omitempty"`
+	PaymentID       string `json:"payment_id,omitempty"`
+	RefundID        string `json:"refund_id,omitempty"`
+	Amount          int    `json:"amount"`
+	PaidAmountAfter int    `json:"paid_amount_after"`
+	StatusAfter     string `json:"status_after,omitempty"`
+	Note            string `json:"note,omitempty"`
+	CreatedBy       string `json:"created_by,omitempty"`
+	CreatedAt       string `json:"created_at,omitempty"`
+}
+
+type InvoiceFilter struct {
+	Status          string
+	MedicalRecordID string
+}
+
+type CreateRequest struct {
+	MedicalRecordID string `json:"medical_record_id" validate:"required,uuid"`
+	Note            string `json:"note"`
+	CreatedBy       string `json:"-"`
+}
+
+// DiscountRequest sets either a percentage or a fixed amount off the subtotal,
+// the amount wins when both are given.
+type DiscountRequest struct {
+	DiscountPercent float64 `json:"discount_percent" validate:"min=0,max=100"`
+	DiscountAmount  int     `json:"discount_amount" validate:"min=0"`
+	Note            string  `json:"note"`
+}
+
+type VoidRequest struct {
+	Reason   string `json:"reason" validate:"required"`
+	VoidedBy string `json:"-"`
+}
+
+type ConsultationFee struct {
+	DoctorID   string `json:"doctor_id,omitempty"`
+	DoctorName string `json:"doctor_name,omitempty"`
+	Fee        int    `json:"fee"`
+	UpdatedBy  string `json:"updated_by,omitempty"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+}
+
+type ConsultationFeeRequest struct {
+	DoctorID  string `json:"-"`
+	Fee       int    `json:"fee" validate:"min=0"`
+	UpdatedBy string `json:"-"`
+}
+
+// PaymentRequest pays an invoice with one or more methods at once, e.g. part
+// in cash and the rest by debit card.
+type PaymentRequest struct {
+	Payments  []PaymentLineRequest `json:"payments" validate:"required,min=1,dive"`
+	CashierID string               `json:"-"`
+}
+
+type PaymentLineRequest struct {
+	Method         string `json:"method" validate:"required,enum=CASH DEBIT QRIS TRANSFER INSURANCE"`
+	Amount         int    `json:"amount" validate:"required,min=1"`
+	TenderedAmount int    `json:"tendered_amount" validate:"min=0"`
+	Reference      string `json:"reference"`
+}
+
+type RefundRequest struct {
+	Method    string `json:"method" validate:"required,enum=CASH DEBIT QRIS TRANSFER INSURANCE"`
+	Amount    int    `json:"amount" validate:"required,min=1"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason" validate:"required"`
+	CreatedBy string `json:"-"`
+}
@@ -41,6 +41,16 @@ func NewResponseSuccess(c *gin.Context, result interface{}, message, serviceCode
 	})
 }
 
+// NewResponsePaging answers with one page of a list.
+func NewResponsePaging(c *gin.Context, result interface{}, paging interface{}, message, serviceCode, responseCode string) {
+	c.JSON(http.StatusOK, jsonResponse{
+		Code:    "200" + serviceCode + responseCode,
+		Message: message,
+		Data:    result,
+		Paging:  paging,
+	})
+}
+
 func NewResponseCreated(c *gin.Context, result interface{}, message, serviceCode, responseCode string) {
 	c.JSON(http.StatusCreated, jsonResponse{
 		Code:    "201" + serviceCode + responseCode,
@@ -84,4 +94,40 @@ func NewResponseNotFound(c *gin.Context, message, serviceCode, errorCode string)
 		Code:    "404" + serviceCode + errorCode,
 		Message: message,
 	})
-}
\ No newline at end of file
+}
+
+// NewResponsePreconditionFailed answers a change made against a version of the
+// resource that is no longer current.
+func NewResponsePreconditionFailed(c *gin.Context, message, serviceCode, errorCode string) {
+	c.JSON(http.StatusPreconditionFailed, jsonResponse{
+		Code:    "412" + serviceCode + errorCode,
+		Message: message,
+	})
+}
+
+// NewResponsePreconditionRequired answers a change that does not say which
+// version of the resource it was made against.
+func NewResponsePreconditionRequired(c *gin.Context, message, serviceCode, errorCode string) {
+	c.JSON(http.StatusPreconditionRequired, jsonResponse{
+		Code:    "428" + serviceCode + errorCode,
+		Message: message,
+	})
+}
+
+// NewResponseConflict answers a request that clashes with another one still
+// running.
+func NewResponseConflict(c *gin.Context, message, serviceCode, errorCode string) {
+	c.JSON(http.StatusConflict, jsonResponse{
+		Code:    "409" + serviceCode + errorCode,
+		Message: message,
+	})
+}
+
+// NewResponseUnprocessableEntity answers a request that is well formed but
+// cannot be carried out as it is.
+func NewResponseUnprocessableEntity(c *gin.Context, message, serviceCode, errorCode string) {
+	c.JSON(http.StatusUnprocessableEntity, jsonResponse{
+		Code:    "422" + serviceCode + errorCode,
+		Message: message,
+	})
+}
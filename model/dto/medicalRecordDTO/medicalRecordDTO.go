@@ -1,5 +1,7 @@
 package medicalRecordDTO
 
+import "avengers-clinic/model/dto/allergyDto"
+
 type (
 	Medical_Record struct {
 		ID               string                            `json:"id,omitempty"`
@@ -17,13 +19,16 @@ type (
 	}
 
 	Medical_Record_Request struct {
-		Booking_ID       string                     `json:"booking_id" validate:"required"`
-		Diagnosis_Result string                     `json:"diagnosis_result" validate:"required"`
-		Payment_Status   bool                       `json:"payment_status,omitempty"`
-		Medicine_Details []Medicine_Details_Request `json:"medicine_details" validate:"dive"`
-		Action_Details   []Action_Details_Request   `json:"action_details" validate:"dive"`
-		Created_At       string                     `json:"created_at,omitempty"`
-		Updated_At       string                     `json:"updated_at,omitempty"`
+		Booking_ID       string                       `json:"booking_id" validate:"required"`
+		Diagnosis_Result string                       `json:"diagnosis_result" validate:"required"`
+		Payment_Status   bool                         `json:"payment_status,omitempty"`
+		Medicine_Details []Medicine_Details_Request   `json:"medicine_details" validate:"dive"`
+		Action_Details   []Action_Details_Request     `json:"action_details" validate:"dive"`
+		Acknowledgements []allergyDto.Acknowledgement `json:"acknowledgements" validate:"dive"`
+		Created_By       string                       `json:"-"`
+		Reserved_Until   string                       `json:"-"`
+		Created_At       string                       `json:"created_at,omitempty"`
+		Updated_At       string                       `json:"updated_at,omitempty"`
 	}
 
 	Medical_Record_Medicine_Details struct {
@@ -7,9 +7,11 @@ type MedicineRequest struct {
 	Price        int         `json:"price" validate:"required"`
 	Stock        int         `json:"stock"`
 	Description  interface{} `json:"description"`
+	CreatedBy    string      `json:"-"`
 	CreatedAt    string      `json:"created_ad"`
 	UpdatedAt    string      `json:"updated_ad"`
 	DeletedAt    string      `json:"deleted_ad"`
+	Version      int         `json:"-"`
 }
 
 type UpdateRequest struct {
@@ -17,11 +19,14 @@ type UpdateRequest struct {
 	Name         string      `json:"name"`
 	MedicineType string      `json:"medicine_type" validate:"enum=TABLET KAPSUL OLES CAIR TETES "`
 	Price        int         `json:"price"`
-	Stock        int         `json:"stock"`
 	Description  interface{} `json:"description"`
+	Stock        *int        `json:"stock"` // only read to refuse it, stock moves through the inventory ledger
+	UpdatedBy    string      `json:"-"`
 	CreatedAt    string      `json:"created_ad"`
 	UpdatedAt    string      `json:"updated_ad"`
 	DeletedAt    string      `json:"deleted_ad"`
+	// Version the medicine is expected to be at, 0 updates any version
+	Version int `json:"-"`
 }
 
 type MedicineResponse struct {
@@ -34,4 +39,5 @@ type MedicineResponse struct {
 	CreatedAt    string      `json:"created_ad,omitempty"`
 	UpdatedAt    string      `json:"updated_ad,omitempty"`
 	DeletedAt    string      `json:"deleted_ad,omitempty"`
+	Version      int         `json:"version,omitempty"`
 }
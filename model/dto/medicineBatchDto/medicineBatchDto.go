@@ -0,0 +1,33 @@
+package medicineBatchDto
+
+type Batch struct {
+	ID              string      `json:"id,omitempty"`
+	MedicineID      string      `json:"medicine_id,omitempty"`
+	MedicineName    string      `json:"medicine_name,omitempty"`
+	LotNumber       string      `json:"lot_number,omitempty"`
+	ExpiryDate      string      `json:"expiry_date,omitempty"`
+	Supplier        interface{} `json:"supplier,omitempty"`
+	PurchaseCost    int         `json:"purchase_cost"`
+	InitialQuantity int         `json:"initial_quantity"`
+	Quantity        int         `json:"quantity"`
+	CreatedBy       string      `json:"created_by,omitempty"`
+	CreatedAt       string      `json:"created_at,omitempty"`
+	UpdatedAt       string      `json:"updated_at,omitempty"`
+	DeletedAt       string      `json:"deleted_at,omitempty"`
+}
+
+type CreateRequest struct {
+	MedicineID   string      `json:"medicine_id" validate:"required,uuid"`
+	LotNumber    string      `json:"lot_number" validate:"required"`
+	ExpiryDate   string      `json:"expiry_date" validate:"required"`
+	Supplier     interface{} `json:"supplier"`
+	PurchaseCost int         `json:"purchase_cost" validate:"min=0"`
+	Quantity     int         `json:"quantity" validate:"required,min=1"`
+	ReceivedBy   string      `json:"-"`
+}
+
+type ExpiringBatch struct {
+	Batch
+	DaysLeft int  `json:"days_left"`
+	Expired  bool `json:"expired"`
+}
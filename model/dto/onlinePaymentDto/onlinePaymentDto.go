@@ -0,0 +1,42 @@
+package onlinePaymentDto
+
+// Charge is a request to pay the balance of an invoice through the payment
+// gateway. Its id is the order id the provider knows the transaction by.
+type Charge struct {
+	ID          string `json:"id,omitempty"`
+	InvoiceID   string `json:"invoice_id,omitempty"`
+	Provider    string `json:"provider,omitempty"`
+	Amount      int    `json:"amount"`
+	Status      string `json:"status,omitempty"`
+	ProviderRef string `json:"provider_ref,omitempty"`
+	PaymentURL  string `json:"payment_url,omitempty"`
+	Method      string `json:"method,omitempty"`
+	Note        string `json:"note,omitempty"`
+	CreatedBy   string `json:"created_by,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+	PaidAt      string `json:"paid_at,omitempty"`
+	PatientID   string `json:"-"`
+}
+
+// PayableInvoice is what a charge needs to know about the invoice it pays.
+type PayableInvoice struct {
+	ID            string
+	InvoiceNumber string
+	Status        string
+	Balance       int
+	PatientID     string
+	PatientName   string
+}
+
+type ChargeRequest struct {
+	InvoiceID string `json:"invoice_id" validate:"required,uuid"`
+	CreatedBy string `json:"-"`
+	Role      string `json:"-"`
+}
+
+type ReconcileResult struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+}
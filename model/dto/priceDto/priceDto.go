@@ -0,0 +1,23 @@
+package priceDto
+
+// Price is an entry of the price list of a medicine or an action. It applies
+// from EffectiveFrom until the next entry takes effect.
+type Price struct {
+	ID            string `json:"id"`
+	Price         int    `json:"price"`
+	EffectiveFrom string `json:"effective_from"`
+	Status        string `json:"status"`
+	CreatedBy     string `json:"created_by,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// PriceRequest schedules a price change. Without EffectiveFrom the price
+// applies immediately.
+type PriceRequest struct {
+	ItemID        string `json:"-"`
+	Price         int    `json:"price" validate:"required,min=1"`
+	EffectiveFrom string `json:"effective_from"`
+	CreatedBy     string `json:"-"`
+	// Version the medicine or action is expected to be at, 0 changes any version
+	Version int `json:"-"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// Purged is what purging the trash of a table did. Rows still referenced by
+// another table cannot be deleted, Kept counts them by that table.
+type Purged struct {
+	Deleted int
+	Kept    map[string]int
+}
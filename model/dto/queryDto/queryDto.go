@@ -0,0 +1,43 @@
+package queryDto
+
+// Query is the page of a list a client asked for, with the filters and sort
+// order to apply. It is parsed once in the delivery layer and turned into SQL
+// by the repository.
+type Query struct {
+	Page      int
+	Size      int
+	Sort      []Sort
+	Filters   []Filter
+	StartDate string
+	EndDate   string
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+type Filter struct {
+	Field string
+	Value string
+}
+
+// Paging tells the client where the page it got is in the whole list.
+type Paging struct {
+	Page       int `json:"page"`
+	Size       int `json:"size"`
+	TotalRows  int `json:"total_rows"`
+	TotalPages int `json:"total_pages"`
+}
+
+// Resource is what a list can be filtered and sorted by. Filters and Sorts
+// map the names clients use to SQL columns, and only those names are
+// accepted. DateColumn is what start_date and end_date bound, if anything. Key
+// is a unique column that breaks ties so that pages never overlap.
+type Resource struct {
+	Filters     map[string]string
+	Sorts       map[string]string
+	DateColumn  string
+	DefaultSort []Sort
+	Key         string
+}
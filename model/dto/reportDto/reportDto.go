@@ -0,0 +1,92 @@
+package reportDto
+
+// MethodSummary is what a cashier took and gave back with one payment method
+// during a day.
+type MethodSummary struct {
+	Method   string `json:"method"`
+	Payments int    `json:"payments"`
+	Paid     int    `json:"paid"`
+	Refunds  int    `json:"refunds"`
+	Refunded int    `json:"refunded"`
+	Net      int    `json:"net"`
+}
+
+// CashierClosing sums up the day of a cashier. The cash expected in the
+// drawer is what was paid in cash minus what was refunded in cash. Closing is
+// empty until the cashier has counted the drawer.
+type CashierClosing struct {
+	CashierID     string          `json:"cashier_id"`
+	CashierName   string          `json:"cashier_name"`
+	BusinessDate  string          `json:"business_date"`
+	Methods       []MethodSummary `json:"methods"`
+	TotalPaid     int             `json:"total_paid"`
+	TotalRefunded int             `json:"total_refunded"`
+	ExpectedCash  int             `json:"expected_cash"`
+	Closing       *Closing        `json:"closing"`
+}
+
+type Closing struct {
+	ID           string `json:"id"`
+	CashierID    string `json:"cashier_id"`
+	BusinessDate string `json:"business_date"`
+	ExpectedCash int    `json:"expected_cash"`
+	CountedCash  int    `json:"counted_cash"`
+	Difference   int    `json:"difference"`
+	Note         string `json:"note"`
+	ClosedBy     string `json:"closed_by"`
+	ClosedAt     string `json:"closed_at"`
+}
+
+type ClosingFilter struct {
+	BusinessDate string
+	CashierID    string
+}
+
+// ClosingRequest records the cash counted in the drawer of a cashier at the
+// end of a day. Without BusinessDate the closing is for today.
+type ClosingRequest struct {
+	CashierID    string `json:"cashier_id" validate:"required,uuid"`
+	BusinessDate string `json:"business_date"`
+	CountedCash  int    `json:"counted_cash" validate:"min=0"`
+	Note         string `json:"note"`
+	ClosedBy     string `json:"-"`
+}
+
+// Revenue is the revenue of paid medical records for one doctor, action,
+// medicine or period. Records counts the medical records involved and
+// Quantity the actions performed or the medicines given.
+type Revenue struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Records  int    `json:"records"`
+	Quantity int    `json:"quantity"`
+	Amount   int    `json:"amount"`
+}
+
+// RevenueFilter limits the revenue to medical records created between
+// StartDate and EndDate, both inclusive.
+type RevenueFilter struct {
+	GroupBy   string
+	StartDate string
+	EndDate   string
+}
+
+// Outstanding is a medical record that has not been paid yet.
+type Outstanding struct {
+	MedicalRecordID string `json:"medical_record_id"`
+	PatientName     string `json:"patient_name"`
+	DoctorName      string `json:"doctor_name"`
+	CreatedAt       string `json:"created_at"`
+	InvoiceNumber   string `json:"invoice_number"`
+	InvoiceStatus   string `json:"invoice_status"`
+	Amount          int    `json:"amount"`
+	Paid            int    `json:"paid"`
+	Balance         int    `json:"balance"`
+}
+
+// Export is a report rendered as a downloadable file.
+type Export struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
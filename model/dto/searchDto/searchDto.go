@@ -0,0 +1,39 @@
+package searchDto
+
+// Request is a search of the caller, who is known from the token.
+type Request struct {
+	Query  string
+	Types  []string
+	Limit  int
+	UserID string
+	Role   string
+}
+
+// Criteria is what the repository looks for. TsQuery matches the prefixes of
+// every word of the search and Term is the search as typed, matched by
+// trigrams to survive typos. Empty restrictions are not applied.
+type Criteria struct {
+	TsQuery   string
+	Term      string
+	Limit     int
+	Role      string
+	PatientID string
+	DoctorID  string
+}
+
+// Result is one match, with the matching words of Highlight wrapped in
+// <mark> tags.
+type Result struct {
+	Type      string  `json:"type"`
+	ID        string  `json:"id"`
+	Title     string  `json:"title"`
+	Detail    string  `json:"detail,omitempty"`
+	Highlight string  `json:"highlight"`
+	Rank      float64 `json:"rank"`
+}
+
+type Results struct {
+	Query   string   `json:"query"`
+	Total   int      `json:"total"`
+	Results []Result `json:"results"`
+}
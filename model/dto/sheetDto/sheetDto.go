@@ -0,0 +1,37 @@
+package sheetDto
+
+import "avengers-clinic/model/dto/json"
+
+// Record is a line of an imported file, keyed by the lower cased column names
+// of its header.
+type Record struct {
+	Line   int
+	Values map[string]string
+}
+
+// File is a generated CSV or XLSX file.
+type File struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// ImportResult tells what an import did, or would do on a dry run. Nothing is
+// written while one of the rows fails.
+type ImportResult struct {
+	DryRun  bool        `json:"dry_run"`
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// ImportRow is a line of the file and whether it creates or updates the item
+// with its name.
+type ImportRow struct {
+	Line   int                    `json:"line"`
+	Name   string                 `json:"name"`
+	Action string                 `json:"action,omitempty"`
+	ID     string                 `json:"id,omitempty"`
+	Errors []json.ValidationField `json:"errors,omitempty"`
+}
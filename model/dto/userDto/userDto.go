@@ -9,6 +9,7 @@ type User struct {
 	CreatedAt      string      `json:"created_at,omitempty"`
 	UpdatedAt      string      `json:"updated_at,omitempty"`
 	DeletedAt      interface{} `json:"deleted_at,omitempty"`
+	Version        int         `json:"version,omitempty"`
 }
 
 type AuthRequest struct {
@@ -27,6 +28,8 @@ type UpdateRequest struct {
 	ID             string      `json:"id"`
 	Username       string      `json:"username"`
 	Specialization interface{} `json:"specialization"`
+	// Version the user is expected to be at, 0 updates any version
+	Version int `json:"-"`
 }
 
 type UpdatePasswordRequest struct {
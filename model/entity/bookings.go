@@ -15,6 +15,7 @@ type Bookings struct {
 	UpdatedAt        string      `json:"updated_at,omitempty"`
 	DeletedAt        string      `json:"deleted_at,omitempty"`
 	ScheduleTime     MstSchedule `json:"time,omitempty"`
+	Version          int         `json:"version,omitempty"`
 }
 
 type MstSchedule struct {
@@ -13,5 +13,6 @@ type DoctorSchedule struct {
 	CreatedAt    string     `json:"created_at,omitempty"`
 	UpdatedAt    *string    `json:"updated_at,omitempty"`
 	DeletedAt    *string    `json:"deleted_at,omitempty"`
+	Version      int        `json:"version,omitempty"`
 	Schedules    []Bookings `json:"schedule,omitempty"`
 }
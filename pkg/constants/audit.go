@@ -0,0 +1,21 @@
+package constants
+
+const (
+	AuditRead   = "read"
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditLogin  = "login"
+)
+
+// RequestIDHeader carries the ID that ties a request to its log lines and
+// audit entries. One given by the client is kept.
+const RequestIDHeader = "X-Request-ID"
+
+// AuditGenesisHash is the previous hash of the first audit entry.
+const AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+const (
+	AuditReasonUnchained = "the entry does not follow the one before it, entries were removed or reordered"
+	AuditReasonTampered  = "the entry was changed after it was written"
+)
@@ -7,4 +7,16 @@ const (
 	DoctorScheduleService = "04"
 	BookingService        = "05"
 	MedicalRecordService  = "06"
+	AllergyService        = "07"
+	MedicineBatchService  = "08"
+	InventoryService      = "09"
+	InvoiceService        = "10"
+	OnlinePaymentService  = "11"
+	InsuranceService      = "12"
+	ReportService         = "13"
+	AnalyticsService      = "14"
+	DocumentService       = "15"
+	SearchService         = "16"
+	AuditService          = "17"
+	IdempotencyService    = "18"
 )
@@ -0,0 +1,15 @@
+package constants
+
+const (
+	DocumentInvoice      = "INVOICE"
+	DocumentPrescription = "PRESCRIPTION"
+	DocumentSickLeave    = "SICK_LEAVE"
+	DocumentVisitSummary = "VISIT_SUMMARY"
+)
+
+const (
+	DocumentValid    = "VALID"
+	DocumentRevoked  = "REVOKED"
+	DocumentTampered = "TAMPERED"
+	DocumentUnsigned = "UNSIGNED"
+)
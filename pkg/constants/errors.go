@@ -5,8 +5,83 @@ const (
 	ErrScheduleNotMatch         = "your booking isn't match with doctor's schedule"
 	ErrScheduleDateExist        = "the schedule for that date already exists"
 	ErrDateFormat               = "invalid date format"
+	ErrDateTimeFormat           = "invalid date time format, use YYYY-MM-DD or YYYY-MM-DD HH:MM:SS"
 	ErrDocSchedNotExist         = "doctor schedule is not exist"
 	ErrPaymentAlreadyTrue       = "the payment has already been set to true"
 	ErrQuantityGreaterThanStock = "quantity amount is greater than the stock available"
 	ErrNoStockAvailable         = "no stock available for this item"
+	ErrPrescriptionBlocked      = "prescription is blocked by allergy or interaction"
+	ErrPrescriptionNeedsAck     = "prescription warnings must be acknowledged with a reason"
+	ErrUnknownAcknowledgement   = "acknowledged warnings must be warnings of the prescription"
+	ErrExpiredStock             = "the remaining batches for this item have expired"
+	ErrBatchAlreadyExpired      = "the batch expiry date has already passed"
+	ErrMedicineNotExist         = "medicine is not exist"
+	ErrInvalidDays              = "days must be a positive number"
+	ErrNegativeStock            = "the movement would make the stock negative"
+	ErrZeroQuantity             = "quantity cannot be zero"
+	ErrBatchNotMatch            = "batch does not belong to the medicine"
+	ErrOpnameNotOpen            = "the stock opname is no longer open"
+	ErrOpnameItemNotExist       = "medicine is not part of the stock opname"
+	ErrOpnameNotCounted         = "every item must be counted before completing the stock opname"
+	ErrAdjustmentNoteRequired   = "a note is required for stock adjustments"
+	ErrStockNotUpdatable        = "the stock is changed through POST /api/v1/inventory/adjustments"
+	ErrMedicalRecordNotExist    = "medical record is not exist"
+	ErrDoctorNotExist           = "doctor is not exist"
+	ErrInvoiceAlreadyExist      = "the medical record already has an active invoice"
+	ErrMedicalRecordInvoiced    = "the medical record is billed by an invoice, pay the invoice instead"
+	ErrMedicalRecordAlreadyPaid = "the medical record was already paid without an invoice"
+	ErrInvoiceNotDraft          = "only draft invoices can be changed"
+	ErrInvoiceNotPayable        = "the invoice must be issued and unpaid"
+	ErrInvoiceNotVoidable       = "only unpaid invoices can be voided"
+	ErrInvalidDiscount          = "the discount cannot exceed the subtotal"
+	ErrPaymentExceedsBalance    = "the payment exceeds the invoice balance"
+	ErrInsufficientTender       = "the cash tendered is less than the amount paid"
+	ErrPaymentReferenceRequired = "a reference is required for non-cash payments"
+	ErrPaymentNotExist          = "payment is not exist"
+	ErrPaymentNotVoidable       = "the payment is already void or has been refunded"
+	ErrInvoiceNotRefundable     = "only paid invoices can be refunded"
+	ErrRefundExceedsPaid        = "the refund exceeds the amount paid"
+	ErrUnknownPaymentProvider   = "the payment provider is not configured"
+	ErrPayerNotExist            = "payer is not exist"
+	ErrPayerCodeExist           = "a payer with that code already exists"
+	ErrInvalidClaimField        = "unknown claim export field"
+	ErrMembershipNotExist       = "the patient has no valid membership with the payer"
+	ErrPaymentClaimSubmitted    = "the payment is claimed from a payer and cannot be voided"
+	ErrNoClaimsToSubmit         = "the payer has no pending claims"
+	ErrClaimNotDecidable        = "the claim is not waiting for a decision in this batch"
+	ErrApprovedExceedsClaim     = "the approved amount exceeds the amount claimed"
+	ErrRejectionReasonRequired  = "a reason is required to reject a claim"
+	ErrPriceEffectiveInPast     = "a price change cannot take effect in the past"
+	ErrPriceNotScheduled        = "only price changes that have not taken effect can be canceled"
+	ErrInvalidReportFormat      = "the report format must be json, csv or xlsx"
+	ErrInvalidRevenueGroup      = "revenue can be grouped by doctor, action, medicine, day or month"
+	ErrCashierAlreadyClosed     = "the cashier is already closed for that day"
+	ErrInvalidDateRange         = "the start date must not be after the end date"
+	ErrInvalidBookingGroup      = "bookings can be grouped by day, doctor or status"
+	ErrInvalidLimit             = "the limit must be a number between 1 and 100"
+	ErrInvoiceNotPrintable      = "a draft invoice cannot be printed"
+	ErrNothingPrescribed        = "no medicines were prescribed in the medical record"
+	ErrDocumentNotExist         = "document is not exist"
+	ErrDocumentRevoked          = "the document is already revoked"
+	ErrFhirResourceNotSupported = "the resource type is not supported"
+	ErrFhirInvalidBundle        = "the body must be a Bundle of type transaction, batch or collection"
+	ErrInvalidPage              = "the page must be a positive number"
+	ErrInvalidPageSize          = "the size must be a number between 1 and 100"
+	ErrInvalidSortField         = "the list cannot be sorted by that field"
+	ErrSearchQueryTooShort      = "the search needs at least 2 letters or digits"
+	ErrInvalidSearchType        = "the type must be user, medicine, action or diagnosis"
+	ErrSearchTypeForbidden      = "the type cannot be searched with your role"
+	ErrInvalidRetention         = "the retention must be at least 1 day"
+	ErrInvalidFileFormat        = "the file must be a csv or xlsx file"
+	ErrSheetNumber              = "must be a whole number"
+	ErrImportDuplicateName      = "the name is on an earlier line as well"
+	ErrMedicineNameAmbiguous    = "more than one medicine has that name"
+	ErrActionNameInTrash        = "an action with that name is in the trash, restore it first"
+	ErrAuditLogNotExist         = "audit log is not exist"
+	ErrVersionConflict          = "the resource was changed by someone else, get it again and retry"
+	ErrIfMatchRequired          = "the If-Match header with the ETag of the resource is required"
+	ErrInvalidIfMatch           = "the If-Match header must be an ETag given by the server"
+	ErrInvalidIdempotencyKey    = "the Idempotency-Key header must be at most 255 characters"
+	ErrIdempotencyKeyReused     = "the Idempotency-Key was already used for a different request"
+	ErrIdempotencyKeyInProgress = "a request with the same Idempotency-Key is still running, retry later"
 )
@@ -0,0 +1,23 @@
+package constants
+
+const (
+	FhirPatient           = "Patient"
+	FhirPractitioner      = "Practitioner"
+	FhirAppointment       = "Appointment"
+	FhirEncounter         = "Encounter"
+	FhirCondition         = "Condition"
+	FhirMedicationRequest = "MedicationRequest"
+	FhirProcedure         = "Procedure"
+	FhirBundle            = "Bundle"
+	FhirOperationOutcome  = "OperationOutcome"
+)
+
+// Identifier and code systems used in exported resources.
+const (
+	FhirPatientSystem      = "urn:avengers-clinic:patient"
+	FhirPractitionerSystem = "urn:avengers-clinic:practitioner"
+	FhirNIKSystem          = "https://fhir.kemkes.go.id/id/nik"
+	FhirActCodeSystem      = "http://terminology.hl7.org/CodeSystem/v3-ActCode"
+	FhirConditionCategory  = "http://terminology.hl7.org/CodeSystem/condition-category"
+	FhirConditionVerStatus = "http://terminology.hl7.org/CodeSystem/condition-ver-status"
+)
@@ -0,0 +1,11 @@
+package constants
+
+// IdempotencyKeyHeader names a request so that retries of it are answered
+// with the response of the first one instead of running again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses replayed for a retry.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+const MaxIdempotencyKeyLength = 255
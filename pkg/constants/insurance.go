@@ -0,0 +1,26 @@
+package constants
+
+const (
+	PayerBPJS    = "BPJS"
+	PayerPrivate = "PRIVATE"
+)
+
+const (
+	ClaimFormatCSV  = "CSV"
+	ClaimFormatJSON = "JSON"
+)
+
+const (
+	ClaimPending   = "PENDING"
+	ClaimSubmitted = "SUBMITTED"
+	ClaimApproved  = "APPROVED"
+	ClaimRejected  = "REJECTED"
+	ClaimCanceled  = "CANCELED"
+)
+
+const (
+	ClaimBatchSubmitted         = "SUBMITTED"
+	ClaimBatchApproved          = "APPROVED"
+	ClaimBatchPartiallyApproved = "PARTIALLY_APPROVED"
+	ClaimBatchRejected          = "REJECTED"
+)
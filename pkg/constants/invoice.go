@@ -0,0 +1,35 @@
+package constants
+
+const (
+	InvoiceDraft         = "DRAFT"
+	InvoiceIssued        = "ISSUED"
+	InvoicePartiallyPaid = "PARTIALLY_PAID"
+	InvoicePaid          = "PAID"
+	InvoiceVoid          = "VOID"
+	InvoiceRefunded      = "REFUNDED"
+)
+
+const (
+	InvoiceItemConsultation = "CONSULTATION"
+	InvoiceItemMedicine     = "MEDICINE"
+	InvoiceItemAction       = "ACTION"
+)
+
+const (
+	PaymentCash      = "CASH"
+	PaymentDebit     = "DEBIT"
+	PaymentQRIS      = "QRIS"
+	PaymentTransfer  = "TRANSFER"
+	PaymentInsurance = "INSURANCE"
+)
+
+const (
+	PaymentCompleted = "COMPLETED"
+	PaymentVoid      = "VOID"
+)
+
+const (
+	InvoiceEventPayment     = "PAYMENT"
+	InvoiceEventPaymentVoid = "PAYMENT_VOID"
+	InvoiceEventRefund      = "REFUND"
+)
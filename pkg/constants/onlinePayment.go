@@ -0,0 +1,16 @@
+package constants
+
+const PaymentOnline = "ONLINE"
+
+const (
+	ChargePending   = "PENDING"
+	ChargePaid      = "PAID"
+	ChargeFailed    = "FAILED"
+	ChargeExpired   = "EXPIRED"
+	ChargeUnapplied = "UNAPPLIED"
+)
+
+const (
+	CallbackSourceWebhook        = "WEBHOOK"
+	CallbackSourceReconciliation = "RECONCILIATION"
+)
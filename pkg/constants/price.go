@@ -0,0 +1,7 @@
+package constants
+
+const (
+	PricePast      = "PAST"
+	PriceCurrent   = "CURRENT"
+	PriceScheduled = "SCHEDULED"
+)
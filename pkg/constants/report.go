@@ -0,0 +1,26 @@
+package constants
+
+const (
+	ReportFormatJSON = "json"
+	ReportFormatCSV  = "csv"
+	ReportFormatXLSX = "xlsx"
+)
+
+const (
+	RevenueByDoctor   = "doctor"
+	RevenueByAction   = "action"
+	RevenueByMedicine = "medicine"
+	RevenueByDay      = "day"
+	RevenueByMonth    = "month"
+)
+
+const (
+	BookingsByDay    = "day"
+	BookingsByDoctor = "doctor"
+	BookingsByStatus = "status"
+)
+
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+)
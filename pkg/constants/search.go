@@ -0,0 +1,8 @@
+package constants
+
+const (
+	SearchUser      = "user"
+	SearchMedicine  = "medicine"
+	SearchAction    = "action"
+	SearchDiagnosis = "diagnosis"
+)
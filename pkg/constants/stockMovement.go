@@ -0,0 +1,22 @@
+package constants
+
+const (
+	MovementOpeningBalance   = "OPENING_BALANCE"
+	MovementReceipt          = "RECEIPT"
+	MovementDispense         = "DISPENSE"
+	MovementReturn           = "RETURN"
+	MovementAdjustment       = "ADJUSTMENT"
+	MovementExpiryWriteOff   = "EXPIRY_WRITE_OFF"
+	MovementOpnameCorrection = "OPNAME_CORRECTION"
+)
+
+const (
+	OpnameOpen      = "OPEN"
+	OpnameCompleted = "COMPLETED"
+)
+
+const (
+	ReservationReserved = "RESERVED"
+	ReservationConsumed = "CONSUMED"
+	ReservationReleased = "RELEASED"
+)
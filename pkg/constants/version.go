@@ -0,0 +1,8 @@
+package constants
+
+// Versioned resources answer GET with their version in the ETag header and
+// only accept changes carrying it back in If-Match.
+const (
+	ETagHeader    = "ETag"
+	IfMatchHeader = "If-Match"
+)
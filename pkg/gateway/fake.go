@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FakeMidtrans is an in-memory Midtrans server for tests. Charges start
+// pending; SetStatus moves them along and Callback returns the signed
+// notification Midtrans would send for the current state.
+type FakeMidtrans struct {
+	*httptest.Server
+	provider *Midtrans
+
+	mu           sync.Mutex
+	transactions map[string]*midtransTransaction
+	created      int
+}
+
+func NewFakeMidtrans(serverKey string) *FakeMidtrans {
+	fake := &FakeMidtrans{transactions: map[string]*midtransTransaction{}}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	fake.provider = NewMidtrans(fake.URL, fake.URL, serverKey)
+	return fake
+}
+
+// Provider returns a Midtrans adapter pointing at the fake server.
+func (f *FakeMidtrans) Provider() *Midtrans {
+	return f.provider
+}
+
+// SetStatus changes the transaction status of an order, e.g. "settlement" or
+// "expire". paymentType is recorded as the method the customer used.
+func (f *FakeMidtrans) SetStatus(orderID, transactionStatus, paymentType string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	transaction, ok := f.transactions[orderID]
+	if !ok {
+		return
+	}
+	transaction.TransactionStatus = transactionStatus
+	transaction.PaymentType = paymentType
+	transaction.StatusCode = "201"
+	if transactionStatus == "settlement" || transactionStatus == "capture" {
+		transaction.StatusCode = "200"
+	}
+	if transaction.TransactionID == "" {
+		transaction.TransactionID = fmt.Sprintf("trx-%s", orderID)
+	}
+	transaction.SignatureKey = f.provider.Signature(transaction.OrderID, transaction.StatusCode, transaction.GrossAmount)
+}
+
+// Callback returns the signed notification body for the order.
+func (f *FakeMidtrans) Callback(orderID string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := json.Marshal(f.transactions[orderID])
+	return body
+}
+
+// Created returns how many charges were created.
+func (f *FakeMidtrans) Created() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created
+}
+
+func (f *FakeMidtrans) handle(w http.ResponseWriter, r *http.Request) {
+	if user, _, _ := r.BasicAuth(); user != f.provider.serverKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/snap/v1/transactions":
+		var payload struct {
+			TransactionDetails struct {
+				OrderID     string `json:"order_id"`
+				GrossAmount int    `json:"gross_amount"`
+			} `json:"transaction_details"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		orderID := payload.TransactionDetails.OrderID
+		f.created++
+		f.transactions[orderID] = &midtransTransaction{
+			OrderID:     orderID,
+			GrossAmount: fmt.Sprintf("%d.00", payload.TransactionDetails.GrossAmount),
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"token":        "token-" + orderID,
+			"redirect_url": f.URL + "/snap/v2/vtweb/token-" + orderID,
+		})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v2/") && strings.HasSuffix(r.URL.Path, "/status"):
+		orderID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/"), "/status")
+		transaction, ok := f.transactions[orderID]
+		if !ok || transaction.TransactionStatus == "" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"status_code": "404", "status_message": "Transaction doesn't exist."})
+			return
+		}
+		json.NewEncoder(w).Encode(transaction)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
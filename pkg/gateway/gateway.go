@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Statuses every provider reports its transactions in.
+const (
+	StatusPending = "PENDING"
+	StatusPaid    = "PAID"
+	StatusFailed  = "FAILED"
+	StatusExpired = "EXPIRED"
+)
+
+var (
+	ErrDisabled         = errors.New("gateway: no payment provider is configured")
+	ErrInvalidSignature = errors.New("gateway: invalid callback signature")
+)
+
+type (
+	// ChargeRequest asks the provider to collect the amount under an order id
+	// chosen by the clinic, so callbacks can be matched back to the charge.
+	ChargeRequest struct {
+		OrderID      string
+		Amount       int
+		Description  string
+		CustomerName string
+		ExpiresIn    time.Duration
+	}
+
+	Charge struct {
+		OrderID     string
+		ProviderRef string
+		PaymentURL  string
+		Status      string
+	}
+
+	// Notification is the state of a transaction as told by the provider,
+	// either through a callback or a status query. EventKey identifies the
+	// state change, the same change always carries the same key.
+	Notification struct {
+		OrderID     string
+		ProviderRef string
+		Status      string
+		Amount      int
+		Method      string
+		EventKey    string
+		Payload     []byte
+	}
+
+	// Provider is a payment gateway. Implementations must be safe for
+	// concurrent use.
+	Provider interface {
+		Name() string
+		CreateCharge(req ChargeRequest) (Charge, error)
+		Status(orderID string) (Notification, error)
+		// ParseCallback verifies and decodes a callback sent by the provider.
+		ParseCallback(header http.Header, body []byte) (Notification, error)
+	}
+)
+
+// Config selects the provider. ChargeURL is where payment pages are created
+// and StatusURL where transactions are looked up, many providers serve both
+// from the same host.
+type Config struct {
+	Provider  string
+	ChargeURL string
+	StatusURL string
+	ServerKey string
+}
+
+// New returns the configured provider. Without a provider online payments are
+// turned off and every call fails with ErrDisabled.
+func New(config Config) (Provider, error) {
+	if config.StatusURL == "" {
+		config.StatusURL = config.ChargeURL
+	}
+
+	switch config.Provider {
+	case "":
+		return Disabled{}, nil
+	case "midtrans":
+		return NewMidtrans(config.ChargeURL, config.StatusURL, config.ServerKey), nil
+	}
+	return nil, fmt.Errorf("gateway: unknown payment provider %q", config.Provider)
+}
+
+type Disabled struct{}
+
+func (Disabled) Name() string {
+	return ""
+}
+
+func (Disabled) CreateCharge(ChargeRequest) (Charge, error) {
+	return Charge{}, ErrDisabled
+}
+
+func (Disabled) Status(string) (Notification, error) {
+	return Notification{}, ErrDisabled
+}
+
+func (Disabled) ParseCallback(http.Header, []byte) (Notification, error) {
+	return Notification{}, ErrDisabled
+}
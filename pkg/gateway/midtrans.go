@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Midtrans talks to the Snap API to create charges and to the core API for the
+// transaction status. Callbacks are signed with SHA-512 over the order id,
+// status code, gross amount and the server key.
+type Midtrans struct {
+	snapURL   string
+	apiURL    string
+	serverKey string
+	client    *http.Client
+}
+
+func NewMidtrans(snapURL, apiURL, serverKey string) *Midtrans {
+	return &Midtrans{snapURL: snapURL, apiURL: apiURL, serverKey: serverKey, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+type midtransTransaction struct {
+	TransactionID     string `json:"transaction_id"`
+	OrderID           string `json:"order_id"`
+	GrossAmount       string `json:"gross_amount"`
+	PaymentType       string `json:"payment_type"`
+	TransactionStatus string `json:"transaction_status"`
+	FraudStatus       string `json:"fraud_status"`
+	StatusCode        string `json:"status_code"`
+	SignatureKey      string `json:"signature_key"`
+}
+
+func (m *Midtrans) Name() string {
+	return "midtrans"
+}
+
+func (m *Midtrans) CreateCharge(req ChargeRequest) (Charge, error) {
+	payload := map[string]interface{}{
+		"transaction_details": map[string]interface{}{
+			"order_id":     req.OrderID,
+			"gross_amount": req.Amount,
+		},
+		"customer_details": map[string]interface{}{
+			"first_name": req.CustomerName,
+		},
+		"item_details": []map[string]interface{}{{
+			"id":       req.OrderID,
+			"name":     req.Description,
+			"price":    req.Amount,
+			"quantity": 1,
+		}},
+	}
+	if req.ExpiresIn > 0 {
+		payload["expiry"] = map[string]interface{}{
+			"unit":     "minutes",
+			"duration": int(math.Ceil(req.ExpiresIn.Minutes())),
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Charge{}, err
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		RedirectURL string `json:"redirect_url"`
+	}
+	if _, err := m.do(http.MethodPost, m.snapURL+"/snap/v1/transactions", body, &result); err != nil {
+		return Charge{}, err
+	}
+
+	return Charge{OrderID: req.OrderID, ProviderRef: result.Token, PaymentURL: result.RedirectURL, Status: StatusPending}, nil
+}
+
+// Status queries the transaction of an order. An order the customer has not
+// picked a payment method for yet is unknown to Midtrans and still pending.
+func (m *Midtrans) Status(orderID string) (Notification, error) {
+	var transaction midtransTransaction
+	raw, err := m.do(http.MethodGet, m.apiURL+"/v2/"+orderID+"/status", nil, &transaction)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	if transaction.StatusCode == "404" {
+		return Notification{OrderID: orderID, Status: StatusPending}, nil
+	}
+	return m.notification(transaction, raw)
+}
+
+func (m *Midtrans) ParseCallback(header http.Header, body []byte) (Notification, error) {
+	var transaction midtransTransaction
+	if err := json.Unmarshal(body, &transaction); err != nil {
+		return Notification{}, err
+	}
+
+	expected := m.Signature(transaction.OrderID, transaction.StatusCode, transaction.GrossAmount)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(transaction.SignatureKey)) != 1 {
+		return Notification{}, ErrInvalidSignature
+	}
+	return m.notification(transaction, body)
+}
+
+// Signature is the signature_key Midtrans puts on its notifications.
+func (m *Midtrans) Signature(orderID, statusCode, grossAmount string) string {
+	sum := sha512.Sum512([]byte(orderID + statusCode + grossAmount + m.serverKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *Midtrans) notification(transaction midtransTransaction, raw []byte) (Notification, error) {
+	amount, err := strconv.ParseFloat(transaction.GrossAmount, 64)
+	if err != nil {
+		return Notification{}, fmt.Errorf("gateway: invalid gross amount %q", transaction.GrossAmount)
+	}
+
+	status := StatusPending
+	switch transaction.TransactionStatus {
+	case "settlement":
+		status = StatusPaid
+	case "capture":
+		if transaction.FraudStatus == "" || transaction.FraudStatus == "accept" {
+			status = StatusPaid
+		}
+	case "deny", "cancel", "failure":
+		status = StatusFailed
+	case "expire":
+		status = StatusExpired
+	}
+
+	return Notification{
+		OrderID:     transaction.OrderID,
+		ProviderRef: transaction.TransactionID,
+		Status:      status,
+		Amount:      int(math.Round(amount)),
+		Method:      transaction.PaymentType,
+		EventKey:    transaction.OrderID + ":" + transaction.TransactionID + ":" + transaction.TransactionStatus,
+		Payload:     raw,
+	}, nil
+}
+
+func (m *Midtrans) do(method, url string, body []byte, result interface{}) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(m.serverKey, "")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown orders come back as 404 with a status body
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("gateway: midtrans responded with status %d", resp.StatusCode)
+	}
+	return raw, json.Unmarshal(raw, result)
+}
@@ -0,0 +1,214 @@
+package interaction
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	Contraindicated = "CONTRAINDICATED"
+	Major           = "MAJOR"
+	Moderate        = "MODERATE"
+	Minor           = "MINOR"
+)
+
+type (
+	// Rule is an interaction between two medicines or ingredients. Its ID
+	// defaults to both subjects in order, joined by a plus.
+	Rule struct {
+		ID          string `json:"id"`
+		A           string `json:"a"`
+		B           string `json:"b"`
+		Severity    string `json:"severity"`
+		Description string `json:"description"`
+	}
+
+	knowledgeFile struct {
+		Ingredients  map[string][]string `json:"ingredients"`
+		Interactions []Rule              `json:"interactions"`
+	}
+
+	// KnowledgeBase keeps the medicine to active ingredient mapping and the
+	// interaction rules. Every key is stored in lower case.
+	KnowledgeBase struct {
+		ingredients map[string][]string
+		rules       []Rule
+	}
+)
+
+func NewKnowledgeBase() *KnowledgeBase {
+	return &KnowledgeBase{ingredients: map[string][]string{}}
+}
+
+// LoadKnowledgeBase reads a .json or .csv knowledge file. An empty path
+// returns an empty knowledge base so only allergy checks by name are run.
+func LoadKnowledgeBase(path string) (*KnowledgeBase, error) {
+	kb := NewKnowledgeBase()
+	if path == "" {
+		return kb, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = kb.readJSON(file)
+	case ".csv":
+		err = kb.readCSV(file)
+	default:
+		err = errors.New("unsupported knowledge file format")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return kb, nil
+}
+
+func (kb *KnowledgeBase) readJSON(r io.Reader) error {
+	var data knowledgeFile
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+
+	for medicine, ingredients := range data.Ingredients {
+		kb.AddIngredients(medicine, ingredients...)
+	}
+	for _, rule := range data.Interactions {
+		if err := kb.AddRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCSV expects the header kind,subject,object,severity,description,id where
+// kind is INGREDIENT (subject = medicine, object = ingredient) or INTERACTION.
+// The id column is optional.
+func (kb *KnowledgeBase) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], "kind") {
+			continue
+		}
+		if len(record) < 3 {
+			return errors.New("invalid knowledge file row")
+		}
+
+		switch strings.ToUpper(strings.TrimSpace(record[0])) {
+		case "INGREDIENT":
+			kb.AddIngredients(record[1], record[2])
+		case "INTERACTION":
+			rule := Rule{A: record[1], B: record[2]}
+			if len(record) > 3 {
+				rule.Severity = record[3]
+			}
+			if len(record) > 4 {
+				rule.Description = record[4]
+			}
+			if len(record) > 5 {
+				rule.ID = record[5]
+			}
+			if err := kb.AddRule(rule); err != nil {
+				return err
+			}
+		default:
+			return errors.New("invalid knowledge file row kind")
+		}
+	}
+	return nil
+}
+
+func (kb *KnowledgeBase) AddIngredients(medicine string, ingredients ...string) {
+	key := normalize(medicine)
+	for _, ingredient := range ingredients {
+		kb.ingredients[key] = append(kb.ingredients[key], normalize(ingredient))
+	}
+}
+
+func (kb *KnowledgeBase) AddRule(rule Rule) error {
+	rule.A, rule.B = normalize(rule.A), normalize(rule.B)
+	rule.Severity = strings.ToUpper(strings.TrimSpace(rule.Severity))
+	if rule.A == "" || rule.B == "" {
+		return errors.New("interaction rule must have two subjects")
+	}
+
+	switch rule.Severity {
+	case Contraindicated, Major, Moderate, Minor:
+	default:
+		return errors.New("invalid interaction severity " + rule.Severity)
+	}
+
+	rule.ID = strings.TrimSpace(rule.ID)
+	if rule.ID == "" {
+		subjects := []string{rule.A, rule.B}
+		sort.Strings(subjects)
+		rule.ID = strings.Join(subjects, "+")
+	}
+	for _, existing := range kb.rules {
+		if existing.ID == rule.ID {
+			return errors.New("duplicate interaction rule " + rule.ID)
+		}
+	}
+
+	kb.rules = append(kb.rules, rule)
+	return nil
+}
+
+// Keys returns the terms a medicine is known by: its own name and every
+// active ingredient listed for it.
+func (kb *KnowledgeBase) Keys(medicine string) []string {
+	key := normalize(medicine)
+	return append([]string{key}, kb.ingredients[key]...)
+}
+
+// Interactions returns every rule matching a pair of medicines.
+func (kb *KnowledgeBase) Interactions(medicineA, medicineB string) []Rule {
+	var rules []Rule
+	keysA, keysB := kb.Keys(medicineA), kb.Keys(medicineB)
+	for _, rule := range kb.rules {
+		if (contains(keysA, rule.A) && contains(keysB, rule.B)) || (contains(keysA, rule.B) && contains(keysB, rule.A)) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Matches reports whether an allergen refers to the medicine itself or one
+// of its active ingredients.
+func (kb *KnowledgeBase) Matches(medicine, allergen string) bool {
+	return contains(kb.Keys(medicine), normalize(allergen))
+}
+
+func IsBlocking(severity string) bool {
+	return severity == Contraindicated
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"avengers-clinic/pkg/constants"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestID gives every request an ID, the one sent by the client if there is
+// one, and returns it in the response header. Handlers read it with
+// c.GetString(constants.RequestIDHeader).
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(constants.RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(constants.RequestIDHeader, requestID)
+		c.Header(constants.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
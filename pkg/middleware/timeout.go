@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseRouteTimeouts reads timeouts of routes written as
+// "GET /api/v1/reports/*=2m,POST /api/v1/medicines/import=5m". A route ending
+// in * covers every route under it, and a timeout of 0 turns the timeout off.
+func ParseRouteTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath {
+			return nil, fmt.Errorf("route timeout %q is not like GET /api/v1/reports/*=2m", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("route timeout %q: %w", entry, err)
+		}
+		timeouts[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = timeout
+	}
+	return timeouts, nil
+}
+
+// Timeout cancels the context of a request when it runs longer than its route
+// allows, which cancels its queries and rolls its transaction back. Routes
+// without a timeout of their own get defaultTimeout, of the most specific
+// route ending in * that covers them first.
+func Timeout(defaultTimeout time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := routeTimeout(c.Request.Method+" "+c.FullPath(), defaultTimeout, routes)
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func routeTimeout(route string, defaultTimeout time.Duration, routes map[string]time.Duration) time.Duration {
+	if timeout, ok := routes[route]; ok {
+		return timeout
+	}
+
+	timeout, longest := defaultTimeout, -1
+	for pattern, patternTimeout := range routes {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(route, prefix) && len(prefix) > longest {
+			timeout, longest = patternTimeout, len(prefix)
+		}
+	}
+	return timeout
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+type timeoutTestSuite struct {
+	suite.Suite
+}
+
+func (suite *timeoutTestSuite) SetupSuite() {
+	gin.SetMode(gin.TestMode)
+}
+
+func (suite *timeoutTestSuite) TestParseRouteTimeouts() {
+	tests := []struct {
+		name     string
+		spec     string
+		expected map[string]time.Duration
+	}{
+		{name: "empty", spec: "", expected: map[string]time.Duration{}},
+		{name: "single route", spec: "POST /api/v1/medicines/import=5m", expected: map[string]time.Duration{"POST /api/v1/medicines/import": 5 * time.Minute}},
+		{
+			name: "spaces, empty entries and lower case methods",
+			spec: " get /api/v1/reports/* = 2m ,, GET /api/v1/reports/daily=0 ",
+			expected: map[string]time.Duration{
+				"GET /api/v1/reports/*":     2 * time.Minute,
+				"GET /api/v1/reports/daily": 0,
+			},
+		},
+		{name: "last one wins", spec: "GET /api/v1/users=1s,GET /api/v1/users=3s", expected: map[string]time.Duration{"GET /api/v1/users": 3 * time.Second}},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			actual, err := ParseRouteTimeouts(test.spec)
+
+			suite.Nil(err)
+			suite.Equal(test.expected, actual)
+		})
+	}
+}
+
+func (suite *timeoutTestSuite) TestParseRouteTimeoutsMalformed() {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "no timeout", spec: "GET /api/v1/reports/*"},
+		{name: "no method", spec: "/api/v1/reports/*=2m"},
+		{name: "no route", spec: "=2m"},
+		{name: "no unit", spec: "GET /api/v1/reports/*=120"},
+		{name: "not a duration", spec: "GET /api/v1/reports/*=two minutes"},
+		{name: "empty timeout", spec: "GET /api/v1/reports/*="},
+		{name: "one bad entry among good ones", spec: "GET /api/v1/users=1s,POST /api/v1/medicines/import"},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			actual, err := ParseRouteTimeouts(test.spec)
+
+			suite.Error(err)
+			suite.Nil(actual)
+		})
+	}
+}
+
+func (suite *timeoutTestSuite) TestTimeoutPerRoute() {
+	routes := map[string]time.Duration{
+		"GET /api/v1/reports/*":         2 * time.Minute,
+		"GET /api/v1/reports/monthly/*": 5 * time.Minute,
+		"GET /api/v1/reports/daily":     time.Minute,
+		"GET /api/v1/fhir/*":            0,
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		defaultTimeout time.Duration
+		expected       time.Duration
+	}{
+		{name: "exact route over its prefix", method: http.MethodGet, target: "/api/v1/reports/daily", defaultTimeout: 30 * time.Second, expected: time.Minute},
+		{name: "prefix over the default", method: http.MethodGet, target: "/api/v1/reports/yearly", defaultTimeout: 30 * time.Second, expected: 2 * time.Minute},
+		{name: "most specific prefix", method: http.MethodGet, target: "/api/v1/reports/monthly/3", defaultTimeout: 30 * time.Second, expected: 5 * time.Minute},
+		{name: "unknown route gets the default", method: http.MethodGet, target: "/api/v1/users", defaultTimeout: 30 * time.Second, expected: 30 * time.Second},
+		{name: "other method gets the default", method: http.MethodPost, target: "/api/v1/reports/daily", defaultTimeout: 30 * time.Second, expected: 30 * time.Second},
+		{name: "route timeout of 0 turns it off", method: http.MethodGet, target: "/api/v1/fhir/Patient", defaultTimeout: 30 * time.Second, expected: 0},
+		{name: "default of 0 turns it off", method: http.MethodGet, target: "/api/v1/users", defaultTimeout: 0, expected: 0},
+	}
+
+	for _, test := range tests {
+		suite.Run(test.name, func() {
+			var deadline time.Time
+			var hasDeadline bool
+			handler := func(c *gin.Context) {
+				deadline, hasDeadline = c.Request.Context().Deadline()
+				c.Status(http.StatusOK)
+			}
+
+			router := gin.New()
+			v1Group := router.Group("/api/v1", Timeout(test.defaultTimeout, routes))
+			v1Group.GET("/reports/daily", handler)
+			v1Group.POST("/reports/daily", handler)
+			v1Group.GET("/reports/yearly", handler)
+			v1Group.GET("/reports/monthly/:id", handler)
+			v1Group.GET("/users", handler)
+			v1Group.GET("/fhir/Patient", handler)
+
+			req, _ := http.NewRequest(test.method, test.target, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if test.expected == 0 {
+				suite.False(hasDeadline)
+				return
+			}
+			suite.True(hasDeadline)
+			remaining := time.Until(deadline)
+			suite.LessOrEqual(remaining, test.expected)
+			suite.Greater(remaining, test.expected-time.Second)
+		})
+	}
+}
+
+func (suite *timeoutTestSuite) TestTimeoutCancelsContext() {
+	var handlerErr error
+	router := gin.New()
+	router.GET("/api/v1/reports/slow", Timeout(time.Minute, map[string]time.Duration{"GET /api/v1/reports/*": 10 * time.Millisecond}), func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			handlerErr = c.Request.Context().Err()
+		case <-time.After(time.Second):
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/reports/slow", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	suite.Equal(context.DeadlineExceeded, handlerErr)
+}
+
+func TestTimeoutSuite(t *testing.T) {
+	suite.Run(t, new(timeoutTestSuite))
+}
@@ -0,0 +1,319 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// lockKey is the advisory lock held while migrating, so two instances started
+// at once do not both apply the same migration.
+const lockKey = 4207731
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name VARCHAR NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	ErrUnknownVersion = errors.New("migration: there is no migration with that version")
+	ErrNothingApplied = errors.New("migration: no migration is applied")
+)
+
+// Migration changes the schema from the previous version to Version with Up
+// and back with Down.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, AppliedAt is empty while it
+// is pending.
+type Status struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+// BehindError is returned by Check when the database misses migrations.
+type BehindError struct {
+	Current int
+	Latest  int
+}
+
+func (e BehindError) Error() string {
+	return fmt.Sprintf("migration: the schema is at version %d but %d is required, run the migrate command", e.Current, e.Latest)
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New reads the migrations in the root of files, named like 0001_init.up.sql
+// and 0001_init.down.sql. Every version needs both files.
+func New(db *sql.DB, files fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(files, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration: version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration: version %d needs an up and a down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return &Migrator{db, migrations}, nil
+}
+
+// Latest is the version the schema has after every migration is applied.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Check fails with a BehindError when migrations are pending.
+func (m *Migrator) Check() error {
+	current, err := m.current(m.db)
+	if err != nil {
+		return err
+	}
+	if current < m.Latest() {
+		return BehindError{Current: current, Latest: m.Latest()}
+	}
+	return nil
+}
+
+// Status lists every migration, applied or not.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		statuses = append(statuses, Status{Version: migration.Version, Name: migration.Name, AppliedAt: applied[migration.Version]})
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.To(m.Latest())
+}
+
+// Down rolls the last applied migration back.
+func (m *Migrator) Down() ([]Migration, error) {
+	current, err := m.current(m.db)
+	if err != nil {
+		return nil, err
+	}
+	if current == 0 {
+		return nil, ErrNothingApplied
+	}
+
+	previous := 0
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			previous = migration.Version
+		}
+	}
+	return m.To(previous)
+}
+
+// To applies or rolls back migrations, one transaction each, until the schema
+// is at version. Version 0 rolls every migration back. It returns the
+// migrations that were run.
+func (m *Migrator) To(version int) ([]Migration, error) {
+	if version != 0 && m.index(version) < 0 {
+		return nil, ErrUnknownVersion
+	}
+
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1);", lockKey); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1);", lockKey)
+
+	current, err := m.current(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var run []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > current && migration.Version <= version {
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return run, err
+			}
+			run = append(run, migration)
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= current && migration.Version > version {
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return run, err
+			}
+			run = append(run, migration)
+		}
+	}
+	return run, nil
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases created before the migrations existed.
+func (m *Migrator) Baseline(version int) error {
+	if m.index(version) < 0 {
+		return ErrUnknownVersion
+	}
+	if _, err := m.db.Exec(createTable); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, migration := range m.migrations[:m.index(version)+1] {
+		_, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING;", migration.Version, migration.Name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	script, record := migration.Down, "DELETE FROM schema_migrations WHERE version = $1;"
+	if up {
+		script, record = migration.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2);"
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration: %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	args := []interface{}{migration.Version}
+	if up {
+		args = append(args, migration.Name)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (m *Migrator) applied(db queryer) (map[int]string, error) {
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, TO_CHAR(applied_at, 'YYYY-MM-DD HH24:MI:SS') FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// current is the highest applied version, 0 on a new database.
+func (m *Migrator) current(db queryer) (int, error) {
+	applied, err := m.applied(db)
+	if err != nil {
+		return 0, err
+	}
+
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+func (m *Migrator) index(version int) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
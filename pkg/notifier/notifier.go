@@ -0,0 +1,68 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Alert is an event worth telling the clinic staff about, such as a medicine
+// falling below its minimum stock.
+type Alert struct {
+	Type      string      `json:"type"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data,omitempty"`
+	CreatedAt string      `json:"created_at"`
+}
+
+// Notifier delivers alerts. Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// New returns a notifier posting to the webhook when an URL is configured and
+// one writing to the application log otherwise.
+func New(webhookURL string) Notifier {
+	if webhookURL == "" {
+		return LogNotifier{}
+	}
+	return NewWebhookNotifier(webhookURL)
+}
+
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(alert Alert) error {
+	log.Warn().Str("type", alert.Type).Interface("data", alert.Data).Msg(alert.Message)
+	return nil
+}
+
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *WebhookNotifier) Notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("notifier: webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+type txKey struct{}
+
+type committedKey struct{}
+
+// Manager runs a unit of work inside one database transaction. Repositories
+// called with the context given to the work join its transaction instead of
+// using their own connection.
+type Manager interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Querier is what a repository needs to run its statements, either the
+// database itself or the transaction of a unit of work.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type manager struct {
+	db *sql.DB
+}
+
+func NewManager(db *sql.DB) Manager {
+	return &manager{db}
+}
+
+func (m *manager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return Run(ctx, m.db, fn)
+}
+
+// Run runs fn inside the transaction ctx carries. Without one it begins a new
+// transaction, commits it when fn succeeds and rolls it back when fn fails or
+// panics. A nested Run leaves the commit and rollback to the outermost one.
+func Run(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := FromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Deferred so a panic in fn releases the connection as well
+	done := false
+	defer func() {
+		if !done {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	done = true
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if committed, ok := ctx.Value(committedKey{}).(*atomic.Bool); ok {
+		committed.Store(true)
+	}
+	return nil
+}
+
+// Track returns a context whose units of work note their commit, and a func
+// telling whether any of them committed. Callers use it to know whether a
+// request changed anything even though it failed afterwards.
+func Track(ctx context.Context) (context.Context, func() bool) {
+	committed := new(atomic.Bool)
+	return context.WithValue(ctx, committedKey{}, committed), committed.Load
+}
+
+// FromContext returns the transaction of the unit of work ctx belongs to.
+func FromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// Tx returns the transaction ctx carries, it must only be called within Run.
+func Tx(ctx context.Context) *sql.Tx {
+	tx, _ := FromContext(ctx)
+	return tx
+}
+
+// Executor returns the transaction ctx carries, or db outside of a unit of work.
+func Executor(ctx context.Context, db *sql.DB) Querier {
+	if tx, ok := FromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
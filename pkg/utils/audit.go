@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+)
+
+// AuditTimeFormat is how the time of audit entries is hashed, to the
+// microsecond that Postgres keeps.
+const AuditTimeFormat = "2006-01-02 15:04:05.000000"
+
+// CanonicalJSON writes a JSON value with sorted keys and no spaces, so that a
+// value hashes the same after a round trip through a JSONB column. Numbers are
+// kept as they are written. Empty values stay empty.
+func CanonicalJSON(value json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(value)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+// AuditHash is the SHA-256 of an audit entry and the hash of the entry before
+// it. Its JSON values must be canonical.
+func AuditHash(entry auditDto.Entry) string {
+	fields := []string{
+		entry.PrevHash,
+		strconv.FormatInt(entry.ID, 10),
+		entry.ActorID,
+		entry.ActorUsername,
+		entry.ActorRole,
+		entry.Action,
+		entry.Route,
+		entry.Entity,
+		entry.EntityID,
+		string(entry.Before),
+		string(entry.After),
+		string(entry.Diff),
+		strconv.Itoa(entry.Status),
+		entry.IP,
+		entry.RequestID,
+		entry.CreatedAt,
+	}
+	// encoded as a JSON array so that no field can run into the next
+	encoded, _ := json.Marshal(fields)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
@@ -26,3 +26,38 @@ func GetDefaultEndDate() string {
 func GetNow() string {
 	return time.Now().Format("2006-01-02 15:04:05")
 }
+
+// FormatDateTime accepts a date or a date with time and returns it with time.
+// A date alone means the start of that day.
+func FormatDateTime(value string) (string, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if d, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return d.Format("2006-01-02 15:04:05"), nil
+		}
+	}
+	return "", fmt.Errorf(constants.ErrDateTimeFormat)
+}
+
+// DateRange validates an inclusive range of dates. Without a start date the
+// range starts on the first day of the current month, and without an end date
+// it ends today.
+func DateRange(startDate, endDate string) (string, string, error) {
+	now := time.Now()
+	var err error
+	if startDate == "" {
+		startDate = now.AddDate(0, 0, 1-now.Day()).Format("2006-01-02")
+	} else if startDate, err = FormatDate(startDate); err != nil {
+		return "", "", err
+	}
+
+	if endDate == "" {
+		endDate = now.Format("2006-01-02")
+	} else if endDate, err = FormatDate(endDate); err != nil {
+		return "", "", err
+	}
+
+	if startDate > endDate {
+		return "", "", fmt.Errorf(constants.ErrInvalidDateRange)
+	}
+	return startDate, endDate, nil
+}
@@ -43,4 +43,18 @@ func GetJWT(c *gin.Context) *dto.JWTClams {
 	token, _ := VerifyJWT(tokenString)
 	claims := token.Claims.(*dto.JWTClams)
 	return claims
-}
\ No newline at end of file
+}
+
+// Actor is who sent the request, nil when the token is missing or invalid.
+func Actor(c *gin.Context) *dto.JWTClams {
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return nil
+	}
+
+	token, err := VerifyJWT(tokenString)
+	if err != nil || !token.Valid {
+		return nil
+	}
+	return token.Claims.(*dto.JWTClams)
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/pkg/constants"
+	"errors"
+)
+
+// MarkPriceStatus labels a price list ordered from the latest entry: entries
+// after now are scheduled, the latest one before it is current.
+func MarkPriceStatus(prices []priceDto.Price, now string) {
+	current := false
+	for i := range prices {
+		switch {
+		case prices[i].EffectiveFrom > now:
+			prices[i].Status = constants.PriceScheduled
+		case !current:
+			prices[i].Status, current = constants.PriceCurrent, true
+		default:
+			prices[i].Status = constants.PricePast
+		}
+	}
+}
+
+// EffectiveFrom returns when a price change takes effect: immediately when
+// no date is given, otherwise the given date, which cannot lie before now.
+func EffectiveFrom(value, now string) (string, error) {
+	if value == "" {
+		return now, nil
+	}
+
+	effectiveFrom, err := FormatDateTime(value)
+	if err != nil {
+		return "", err
+	}
+
+	if effectiveFrom < now {
+		return "", errors.New(constants.ErrPriceEffectiveInPast)
+	}
+	return effectiveFrom, nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/pkg/constants"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PurgeTrash deletes the rows of table that were soft deleted before
+// deletedBefore. Rows are deleted one at a time so that a row still referenced
+// by another table, a user with bookings for instance, is kept in the trash
+// instead of failing the purge, and counted under the referencing table.
+func PurgeTrash(ctx context.Context, db *sql.DB, table, deletedBefore string) (dto.Purged, error) {
+	purged := dto.Purged{Kept: map[string]int{}}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM "+table+" WHERE deleted_at < $1;", deletedBefore)
+	if err != nil {
+		return purged, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return purged, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		_, err := db.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = $1 AND deleted_at IS NOT NULL;", id)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			referencedBy := pqErr.Table
+			if referencedBy == "" {
+				referencedBy = pqErr.Constraint
+			}
+			purged.Kept[referencedBy]++
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged.Deleted++
+	}
+	return purged, nil
+}
+
+// RetentionCutoff is the moment before which trash older than retentionDays
+// is purged.
+func RetentionCutoff(retentionDays int) (string, error) {
+	if retentionDays < 1 {
+		return "", errors.New(constants.ErrInvalidRetention)
+	}
+	return time.Now().AddDate(0, 0, -retentionDays).Format("2006-01-02 15:04:05"), nil
+}
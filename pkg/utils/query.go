@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// ParseQuery reads page, size, sort, start_date, end_date and the filters of
+// the resource from the query string. Sort takes a comma-separated list of
+// fields, each prefixed with - to sort it descending, as in sort=-created_at.
+func ParseQuery(c *gin.Context, resource queryDto.Resource) (queryDto.Query, []json.ValidationField) {
+	query := queryDto.Query{Page: 1, Size: defaultPageSize, Sort: resource.DefaultSort}
+	var invalid []json.ValidationField
+
+	if page := c.Query("page"); page != "" {
+		number, err := strconv.Atoi(page)
+		if err != nil || number < 1 {
+			invalid = append(invalid, json.ValidationField{FieldName: "page", Message: constants.ErrInvalidPage})
+		}
+		query.Page = number
+	}
+
+	if size := c.Query("size"); size != "" {
+		number, err := strconv.Atoi(size)
+		if err != nil || number < 1 || number > maxPageSize {
+			invalid = append(invalid, json.ValidationField{FieldName: "size", Message: constants.ErrInvalidPageSize})
+		}
+		query.Size = number
+	}
+
+	if fields := c.Query("sort"); fields != "" {
+		query.Sort = nil
+		for _, field := range strings.Split(fields, ",") {
+			order := queryDto.Sort{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+			if _, ok := resource.Sorts[order.Field]; !ok {
+				invalid = append(invalid, json.ValidationField{FieldName: "sort", Message: constants.ErrInvalidSortField + ": " + order.Field})
+				continue
+			}
+			query.Sort = append(query.Sort, order)
+		}
+	}
+
+	// filters are read in a fixed order so that the SQL built from them is too
+	var names []string
+	for name := range resource.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := c.Query(name); value != "" {
+			query.Filters = append(query.Filters, queryDto.Filter{Field: name, Value: value})
+		}
+	}
+
+	if resource.DateColumn != "" {
+		var err error
+		for _, date := range []struct {
+			name  string
+			value *string
+		}{{"start_date", &query.StartDate}, {"end_date", &query.EndDate}} {
+			if *date.value = c.Query(date.name); *date.value == "" {
+				continue
+			}
+			if *date.value, err = FormatDate(*date.value); err != nil {
+				invalid = append(invalid, json.ValidationField{FieldName: date.name, Message: err.Error()})
+			}
+		}
+
+		if query.StartDate != "" && query.EndDate != "" && query.StartDate > query.EndDate {
+			invalid = append(invalid, json.ValidationField{FieldName: "start_date", Message: constants.ErrInvalidDateRange})
+		}
+	}
+
+	return query, invalid
+}
+
+// ListConditions turns the filters and date range of a query into SQL
+// conditions, each starting with AND. Their placeholders are numbered after
+// the args already given, and their values appended to them.
+func ListConditions(query queryDto.Query, resource queryDto.Resource, args []interface{}) (string, []interface{}) {
+	var conditions strings.Builder
+	for _, filter := range query.Filters {
+		column, ok := resource.Filters[filter.Field]
+		if !ok {
+			continue
+		}
+		args = append(args, filter.Value)
+		fmt.Fprintf(&conditions, " AND %s::text = $%d", column, len(args))
+	}
+
+	if query.StartDate != "" {
+		args = append(args, query.StartDate)
+		fmt.Fprintf(&conditions, " AND %s::date >= $%d", resource.DateColumn, len(args))
+	}
+
+	if query.EndDate != "" {
+		args = append(args, query.EndDate)
+		fmt.Fprintf(&conditions, " AND %s::date <= $%d", resource.DateColumn, len(args))
+	}
+	return conditions.String(), args
+}
+
+// ListOrder sorts a list query and limits it to the page asked for. A Size of
+// 0 returns the whole list, as exports do.
+func ListOrder(query queryDto.Query, resource queryDto.Resource, args []interface{}) (string, []interface{}) {
+	var columns []string
+	for _, order := range query.Sort {
+		column, ok := resource.Sorts[order.Field]
+		if !ok {
+			continue
+		}
+		if order.Desc {
+			column += " DESC"
+		}
+		columns = append(columns, column)
+	}
+	columns = append(columns, resource.Key)
+
+	// LIMIT NULL is no limit at all
+	var limit interface{}
+	if query.Size > 0 {
+		limit = query.Size
+	}
+	args = append(args, limit, (query.Page-1)*query.Size)
+	return fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", strings.Join(columns, ", "), len(args)-1, len(args)), args
+}
+
+func NewPaging(query queryDto.Query, totalRows int) queryDto.Paging {
+	paging := queryDto.Paging{Page: query.Page, Size: query.Size, TotalRows: totalRows}
+	if query.Size > 0 {
+		paging.TotalPages = (totalRows + query.Size - 1) / query.Size
+	}
+	return paging
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// ReadUpload reads the CSV or XLSX file uploaded in the field of a multipart
+// form.
+func ReadUpload(c *gin.Context, field string) ([]sheetDto.Record, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadSheet(header.Filename, file)
+}
+
+// ReadSheet reads a CSV or XLSX file, told apart by the extension of its name,
+// into records keyed by the lower cased names in the first row. Only the first
+// sheet of a workbook is read, and blank lines are skipped.
+func ReadSheet(fileName string, file io.Reader) ([]sheetDto.Record, error) {
+	var rows [][]string
+	var lines []int
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), ".")) {
+	case constants.ReportFormatCSV:
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			line, _ := reader.FieldPos(0)
+			rows, lines = append(rows, row), append(lines, line)
+		}
+	case constants.ReportFormatXLSX:
+		workbook, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer workbook.Close()
+
+		sheetRows, err := workbook.GetRows(workbook.GetSheetName(0))
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range sheetRows {
+			rows, lines = append(rows, row), append(lines, i+1)
+		}
+	default:
+		return nil, errors.New(constants.ErrInvalidFileFormat)
+	}
+
+	var records []sheetDto.Record
+	var header []string
+	for i, row := range rows {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		if header == nil {
+			for _, column := range row {
+				header = append(header, strings.ToLower(strings.TrimSpace(column)))
+			}
+			continue
+		}
+
+		record := sheetDto.Record{Line: lines[i], Values: map[string]string{}}
+		for j, value := range row {
+			if j < len(header) {
+				record.Values[header[j]] = strings.TrimSpace(value)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// SheetInt reads a whole number from a column of a record, an empty column is
+// 0.
+func SheetInt(record sheetDto.Record, column string) (int, error) {
+	value := record.Values[column]
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(constants.ErrSheetNumber)
+	}
+	return number, nil
+}
+
+// WriteSheet writes the rows under the header as a CSV or XLSX file called
+// name.
+func WriteSheet(name, format string, header []string, rows [][]interface{}) (sheetDto.File, error) {
+	switch strings.ToLower(format) {
+	case constants.ReportFormatCSV:
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+		writer.Write(header)
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = fmt.Sprint(value)
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+
+		return sheetDto.File{FileName: name + ".csv", ContentType: "text/csv", Content: buffer.Bytes()}, writer.Error()
+	case constants.ReportFormatXLSX:
+		file := excelize.NewFile()
+		defer file.Close()
+
+		sheet := file.GetSheetName(0)
+		columns := make([]interface{}, len(header))
+		for i, column := range header {
+			columns[i] = column
+		}
+
+		for i, row := range append([][]interface{}{columns}, rows...) {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return sheetDto.File{}, err
+			}
+			if err := file.SetSheetRow(sheet, cell, &row); err != nil {
+				return sheetDto.File{}, err
+			}
+		}
+
+		buffer, err := file.WriteToBuffer()
+		if err != nil {
+			return sheetDto.File{}, err
+		}
+		return sheetDto.File{
+			FileName:    name + ".xlsx",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Content:     buffer.Bytes(),
+		}, nil
+	}
+	return sheetDto.File{}, errors.New(constants.ErrInvalidFileFormat)
+}
+
+// ImportErrors lists the errors of every failed row of an import, the field
+// prefixed with the line it is on.
+func ImportErrors(result sheetDto.ImportResult) []json.ValidationField {
+	var fields []json.ValidationField
+	for _, row := range result.Rows {
+		for _, field := range row.Errors {
+			fields = append(fields, json.ValidationField{FieldName: fmt.Sprintf("line %d %s", row.Line, field.FieldName), Message: field.Message})
+		}
+	}
+	return fields
+}
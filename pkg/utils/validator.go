@@ -40,6 +40,7 @@ func getErrorMessage(err validator.FieldError) string {
 		"uuid": "Invalid uuid",
 		"uuid3": "Invalid uuid",
 		"uuid4": "Invalid uuid",
+		"min": "Field must be at least "+err.Param(),
 		"lt":       "weekend tutup (>5)",
 		"gt":       "weekend tutup (<=0)",
 	}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"avengers-clinic/pkg/constants"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETag is the entity tag of a resource at the given version.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// SetETag answers with the version of the resource in the ETag header.
+func SetETag(c *gin.Context, version int) {
+	c.Header(constants.ETagHeader, ETag(version))
+}
+
+// IfMatch returns the version the If-Match header of the request expects the
+// resource to be at. "*" accepts any version and is returned as 0.
+func IfMatch(c *gin.Context) (int, error) {
+	header := strings.TrimSpace(c.GetHeader(constants.IfMatchHeader))
+	if header == "" {
+		return 0, errors.New(constants.ErrIfMatchRequired)
+	}
+
+	if header == "*" {
+		return 0, nil
+	}
+
+	// Weak tags carry the same version
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errors.New(constants.ErrInvalidIfMatch)
+	}
+
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version < 1 {
+		return 0, errors.New(constants.ErrInvalidIfMatch)
+	}
+	return version, nil
+}
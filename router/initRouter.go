@@ -1,30 +1,120 @@
 package router
 
 import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/documentDto"
+	"avengers-clinic/pkg/gateway"
+	"avengers-clinic/pkg/interaction"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/notifier"
+	"avengers-clinic/pkg/transaction"
 	"avengers-clinic/src/action/actionDelivery"
 	"avengers-clinic/src/action/actionRepository"
 	"avengers-clinic/src/action/actionUsecase"
+	"avengers-clinic/src/allergy/allergyDelivery"
+	"avengers-clinic/src/allergy/allergyRepository"
+	"avengers-clinic/src/allergy/allergyUsecase"
+	"avengers-clinic/src/analytics/analyticsDelivery"
+	"avengers-clinic/src/analytics/analyticsRepository"
+	"avengers-clinic/src/analytics/analyticsUsecase"
+	"avengers-clinic/src/audit"
+	"avengers-clinic/src/audit/auditDelivery"
+	"avengers-clinic/src/audit/auditRepository"
+	"avengers-clinic/src/audit/auditUsecase"
 	"avengers-clinic/src/booking/bookingDelivery"
 	"avengers-clinic/src/booking/bookingRepository"
 	"avengers-clinic/src/booking/bookingUsecase"
 	"avengers-clinic/src/doctorSchedule/doctorScheduleDelivery"
 	"avengers-clinic/src/doctorSchedule/doctorScheduleRepository"
 	"avengers-clinic/src/doctorSchedule/doctorScheduleUsecase"
+	"avengers-clinic/src/document/documentDelivery"
+	"avengers-clinic/src/document/documentRepository"
+	"avengers-clinic/src/document/documentUsecase"
+	"avengers-clinic/src/fhir/fhirDelivery"
+	"avengers-clinic/src/fhir/fhirRepository"
+	"avengers-clinic/src/fhir/fhirUsecase"
+	"avengers-clinic/src/idempotency/idempotencyDelivery"
+	"avengers-clinic/src/idempotency/idempotencyRepository"
+	"avengers-clinic/src/idempotency/idempotencyUsecase"
+	"avengers-clinic/src/insurance/insuranceDelivery"
+	"avengers-clinic/src/insurance/insuranceRepository"
+	"avengers-clinic/src/insurance/insuranceUsecase"
+	"avengers-clinic/src/invoice/invoiceDelivery"
+	"avengers-clinic/src/invoice/invoiceRepository"
+	"avengers-clinic/src/invoice/invoiceUsecase"
+	"avengers-clinic/src/onlinePayment/onlinePaymentDelivery"
+	"avengers-clinic/src/onlinePayment/onlinePaymentRepository"
+	"avengers-clinic/src/onlinePayment/onlinePaymentUsecase"
 	"avengers-clinic/src/medicalRecord/medicalRecordDelivery"
 	"avengers-clinic/src/medicalRecord/medicalRecordRepository"
 	"avengers-clinic/src/medicalRecord/medicalRecordUsecase"
 	"avengers-clinic/src/medicine/medicineDelivery"
 	"avengers-clinic/src/medicine/medicineRepository"
 	"avengers-clinic/src/medicine/medicineUsecase"
+	"avengers-clinic/src/inventory/inventoryDelivery"
+	"avengers-clinic/src/inventory/inventoryRepository"
+	"avengers-clinic/src/inventory/inventoryUsecase"
+	"avengers-clinic/src/medicineBatch/medicineBatchDelivery"
+	"avengers-clinic/src/medicineBatch/medicineBatchRepository"
+	"avengers-clinic/src/medicineBatch/medicineBatchUsecase"
+	"avengers-clinic/src/report/reportDelivery"
+	"avengers-clinic/src/report/reportRepository"
+	"avengers-clinic/src/report/reportUsecase"
+	"avengers-clinic/src/search/searchDelivery"
+	"avengers-clinic/src/search/searchRepository"
+	"avengers-clinic/src/search/searchUsecase"
 	"avengers-clinic/src/user/userDelivery"
 	"avengers-clinic/src/user/userRepository"
 	"avengers-clinic/src/user/userUsecase"
+	"context"
 	"database/sql"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func InitRoute(v1Group *gin.RouterGroup, db *sql.DB) {
+func InitRoute(v1Group *gin.RouterGroup, db *sql.DB, configData dto.ConfigData) error {
+	requestTimeout, err := time.ParseDuration(configData.AppConfig.RequestTimeout)
+	if err != nil {
+		return err
+	}
+	routeTimeouts, err := middleware.ParseRouteTimeouts(configData.AppConfig.RouteTimeouts)
+	if err != nil {
+		return err
+	}
+	v1Group.Use(middleware.Timeout(requestTimeout, routeTimeouts))
+
+	// the recorder only sees routes added after it, the snapshots are filled in below
+	snapshots := audit.Snapshots{}
+	auditRepo := auditRepository.NewAuditRepository(db)
+	auditUC := auditUsecase.NewAuditUsecase(auditRepo)
+	v1Group.Use(auditDelivery.Recorder(v1Group.BasePath(), auditUC, snapshots))
+	auditDelivery.NewAuditDelivery(v1Group, auditUC)
+
+	// retries of these routes with the same Idempotency-Key get the first response again
+	idempotencyKeyTTL, err := time.ParseDuration(configData.AppConfig.IdempotencyKeyTTL)
+	if err != nil {
+		return err
+	}
+	idempotencyLockTTL, err := time.ParseDuration(configData.AppConfig.IdempotencyLockTTL)
+	if err != nil {
+		return err
+	}
+	idempotencyRepo := idempotencyRepository.NewIdempotencyRepository(db)
+	idempotencyUC := idempotencyUsecase.NewIdempotencyUsecase(idempotencyRepo, idempotencyKeyTTL, idempotencyLockTTL)
+	v1Group.Use(idempotencyDelivery.Idempotent(idempotencyUC,
+		"POST "+v1Group.BasePath()+"/booking",
+		"POST "+v1Group.BasePath()+"/medical-records",
+		"PUT "+v1Group.BasePath()+"/medical-records/:id",
+		"POST "+v1Group.BasePath()+"/invoices/:id/payments",
+		"POST "+v1Group.BasePath()+"/online-payments/charges",
+	))
+	idempotencyUsecase.StartSweeper(idempotencyUC, time.Hour)
+
+	// usecases spanning several repositories run them in one transaction through it
+	txManager := transaction.NewManager(db)
+
 	userRepository := userRepository.NewUserRepository(db)
 	userUsecase := userUsecase.NewUserUsecase(userRepository)
 	userDelivery.NewUserDelivery(v1Group, userUsecase)
@@ -36,15 +126,114 @@ func InitRoute(v1Group *gin.RouterGroup, db *sql.DB) {
 	medicineRepo := medicineRepository.NewMedicineRepository(db)
 	medicineUC := medicineUsecase.NewMedicineUsecase(medicineRepo)
 	medicineDelivery.NewMedicineDelivery(v1Group, medicineUC)
+
+	batchRepo := medicineBatchRepository.NewMedicineBatchRepository(db)
+	batchUC := medicineBatchUsecase.NewMedicineBatchUsecase(batchRepo)
+	medicineBatchDelivery.NewMedicineBatchDelivery(v1Group, batchUC)
+
+	inventoryRepo := inventoryRepository.NewInventoryRepository(db)
+	inventoryUC := inventoryUsecase.NewInventoryUsecase(inventoryRepo, notifier.New(configData.AppConfig.LowStockWebhookURL))
+	inventoryDelivery.NewInventoryDelivery(v1Group, inventoryUC)
 	
 	scheduleRepo := doctorScheduleRepository.NewDoctorScheduleRepo(db)
 	bookingRepo := bookingRepository.NewBookingRepository(db)
 	scheduleUC := doctorScheduleUsecase.NewDoctorScheduleUsecase(scheduleRepo, bookingRepo)
-	bookingUC := bookingUsecase.NewBookingUsecase(bookingRepo, scheduleRepo)
+	bookingUC := bookingUsecase.NewBookingUsecase(bookingRepo, scheduleRepo, txManager)
 	doctorScheduleDelivery.NewDoctorScheduleDelivery(v1Group, scheduleUC)
 	bookingDelivery.NewBookingDelivery(v1Group, bookingUC)
 
-	medicalRecordRepository := medicalRecordRepository.NewMedicalRecordRepository(db)
-	medicalRecordUsecase := medicalRecordUsecase.NewMedicalRecordUsecase(medicalRecordRepository)
-	medicalRecordDelivery.NewMedicalRecordDelivery(v1Group, medicalRecordUsecase)
+	knowledgeBase, err := interaction.LoadKnowledgeBase(configData.AppConfig.DrugKnowledgeFile)
+	if err != nil {
+		return err
+	}
+	allergyRepo := allergyRepository.NewAllergyRepository(db)
+	allergyUC := allergyUsecase.NewAllergyUsecase(allergyRepo, knowledgeBase)
+	allergyDelivery.NewAllergyDelivery(v1Group, allergyUC)
+
+	reservationTTL, err := time.ParseDuration(configData.AppConfig.ReservationTTL)
+	if err != nil {
+		return err
+	}
+	medicalRecordRepo := medicalRecordRepository.NewMedicalRecordRepository(db)
+	medicalRecordUC := medicalRecordUsecase.NewMedicalRecordUsecase(medicalRecordRepo, bookingRepo, allergyUC, inventoryUC, txManager, reservationTTL)
+	medicalRecordDelivery.NewMedicalRecordDelivery(v1Group, medicalRecordUC)
+	medicalRecordUsecase.StartReservationSweeper(medicalRecordUC, 5*time.Minute)
+
+	invoiceRepo := invoiceRepository.NewInvoiceRepository(db)
+	invoiceUC := invoiceUsecase.NewInvoiceUsecase(invoiceRepo, configData.AppConfig.ConsultationFee, configData.AppConfig.TaxRate, reservationTTL)
+	invoiceDelivery.NewInvoiceDelivery(v1Group, invoiceUC)
+
+	documentRepo := documentRepository.NewDocumentRepository(db)
+	documentUC := documentUsecase.NewDocumentUsecase(documentRepo, medicalRecordUC, invoiceUC, documentDto.Clinic{
+		Name:       configData.AppConfig.ClinicName,
+		Address:    configData.AppConfig.ClinicAddress,
+		Phone:      configData.AppConfig.ClinicPhone,
+		LogoFile:   configData.AppConfig.ClinicLogoFile,
+		VerifyURL:  configData.AppConfig.VerifyURL,
+		SigningKey: configData.AppConfig.DocumentSigningKey,
+	})
+	documentDelivery.NewDocumentDelivery(v1Group, documentUC)
+
+	fhirRepo := fhirRepository.NewFhirRepository(db)
+	fhirUC := fhirUsecase.NewFhirUsecase(fhirRepo, configData.AppConfig.FhirBaseURL)
+	fhirDelivery.NewFhirDelivery(v1Group, fhirUC)
+
+	insuranceRepo := insuranceRepository.NewInsuranceRepository(db)
+	insuranceUC := insuranceUsecase.NewInsuranceUsecase(insuranceRepo)
+	insuranceDelivery.NewInsuranceDelivery(v1Group, insuranceUC)
+
+	reportRepo := reportRepository.NewReportRepository(db)
+	reportUC := reportUsecase.NewReportUsecase(reportRepo)
+	reportDelivery.NewReportDelivery(v1Group, reportUC)
+
+	analyticsRepo := analyticsRepository.NewAnalyticsRepository(db)
+	analyticsUC := analyticsUsecase.NewAnalyticsUsecase(analyticsRepo)
+	analyticsDelivery.NewAnalyticsDelivery(v1Group, analyticsUC)
+
+	searchRepo := searchRepository.NewSearchRepository(db)
+	searchUC := searchUsecase.NewSearchUsecase(searchRepo)
+	searchDelivery.NewSearchDelivery(v1Group, searchUC)
+
+	provider, err := gateway.New(gateway.Config{
+		Provider:  configData.AppConfig.PaymentProvider,
+		ChargeURL: configData.AppConfig.PaymentChargeURL,
+		StatusURL: configData.AppConfig.PaymentStatusURL,
+		ServerKey: configData.AppConfig.PaymentServerKey,
+	})
+	if err != nil {
+		return err
+	}
+	chargeTTL, err := time.ParseDuration(configData.AppConfig.PaymentChargeTTL)
+	if err != nil {
+		return err
+	}
+	onlinePaymentRepo := onlinePaymentRepository.NewOnlinePaymentRepository(db)
+	onlinePaymentUC := onlinePaymentUsecase.NewOnlinePaymentUsecase(onlinePaymentRepo, provider, chargeTTL)
+	onlinePaymentDelivery.NewOnlinePaymentDelivery(v1Group, onlinePaymentUC)
+	onlinePaymentUsecase.StartReconciler(onlinePaymentUC, time.Minute)
+
+	snapshots["users"] = func(ctx context.Context, id string) (interface{}, error) { return userUsecase.GetByID(ctx, id) }
+	snapshots["actions"] = func(ctx context.Context, id string) (interface{}, error) { return actionUsecase.GetByID(ctx, id) }
+	snapshots["medicines"] = func(ctx context.Context, id string) (interface{}, error) { return medicineUC.GetById(ctx, id) }
+	snapshots["medicine-batches"] = func(ctx context.Context, id string) (interface{}, error) { return batchUC.GetByID(ctx, id) }
+	snapshots["inventory"] = func(ctx context.Context, id string) (interface{}, error) { return inventoryUC.GetOpname(ctx, id) }
+	snapshots["medical-records"] = func(ctx context.Context, id string) (interface{}, error) { return medicalRecordUC.GetMedicalRecordByID(ctx, id) }
+	snapshots["invoices"] = func(ctx context.Context, id string) (interface{}, error) { return invoiceUC.GetInvoiceByID(ctx, id) }
+	snapshots["claim-batches"] = func(ctx context.Context, id string) (interface{}, error) { return insuranceUC.GetBatchByID(ctx, id) }
+	snapshots["booking"] = func(ctx context.Context, id string) (interface{}, error) {
+		bookingID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, err
+		}
+		return bookingUC.GetOneByID(ctx, bookingID)
+	}
+	snapshots["doctor-schedule"] = func(ctx context.Context, id string) (interface{}, error) {
+		scheduleID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, err
+		}
+		return scheduleUC.GetByID(ctx, scheduleID, "")
+	}
+
+	return nil
 }
@@ -3,11 +3,13 @@ package actionDelivery
 import (
 	"avengers-clinic/model/dto/actionDto"
 	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/priceDto"
 	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/middleware"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/action"
 	"database/sql"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,17 +23,28 @@ func NewActionDelivery(v1Group *gin.RouterGroup, actionUC action.ActionUsecase)
 	actionGroup := v1Group.Group("/actions")
 	{
 		actionGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetAll)
+		actionGroup.POST("/import", middleware.JwtAuth("ADMIN"), handler.Import)
+		actionGroup.GET("/export", middleware.JwtAuth("ADMIN"), handler.Export)
 		actionGroup.GET("/:id", middleware.JwtAuth("ADMIN"), handler.GetByID)
 		actionGroup.POST("", middleware.JwtAuth("ADMIN"), handler.Create)
 		actionGroup.PUT("/:id", middleware.JwtAuth("ADMIN"), handler.Update)
 		actionGroup.DELETE("/:id", middleware.JwtAuth("ADMIN"), handler.Delete)
 		actionGroup.DELETE("/:id/trash", middleware.JwtAuth("ADMIN"), handler.SoftDelete)
 		actionGroup.PUT("/:id/restore", middleware.JwtAuth("ADMIN"), handler.Restore)
+		actionGroup.GET("/:id/prices", middleware.JwtAuth("ADMIN"), handler.GetPrices)
+		actionGroup.POST("/:id/prices", middleware.JwtAuth("ADMIN"), handler.SchedulePrice)
+		actionGroup.DELETE("/:id/prices/:priceId", middleware.JwtAuth("ADMIN"), handler.CancelPrice)
 	}
 }
 
 func (delivery *actionDelivery) GetAll(c *gin.Context) {
-	response, err := delivery.actionUC.GetAll()
+	query, invalid := utils.ParseQuery(c, action.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(c, invalid, "Bad request", constants.ActionService, "02")
+		return
+	}
+
+	response, paging, err := delivery.actionUC.GetAll(c.Request.Context(), query)
 	if err != nil {
 		json.NewResponseError(c, err.Error(), constants.ActionService, "01")
 		return
@@ -42,12 +55,12 @@ func (delivery *actionDelivery) GetAll(c *gin.Context) {
 		return
 	}
 
-	json.NewResponseSuccess(c, response, "actions successfully retrieved", constants.ActionService, "01")
+	json.NewResponsePaging(c, response, paging, "actions successfully retrieved", constants.ActionService, "01")
 }
 
 func (delivery *actionDelivery) GetByID(c *gin.Context) {
 	actionID := c.Param("id")
-	response, err := delivery.actionUC.GetByID(actionID)
+	response, err := delivery.actionUC.GetByID(c.Request.Context(), actionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "Action not found", constants.ActionService, "01")
@@ -58,6 +71,7 @@ func (delivery *actionDelivery) GetByID(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, response.Version)
 	json.NewResponseSuccess(c, response, "action successfully retrieved", constants.ActionService, "01")
 }
 
@@ -73,7 +87,7 @@ func (delivery *actionDelivery) Create(c *gin.Context) {
 		return
 	}
 
-	response, err := delivery.actionUC.Create(request)
+	response, err := delivery.actionUC.Create(c.Request.Context(), request)
 	if err != nil {
 		if err.Error() == "1" {
 			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName:"name", Message:"Name is already registered"}}, "Bad request", constants.ActionService, "03")
@@ -94,8 +108,14 @@ func (delivery *actionDelivery) Update(c *gin.Context) {
 		return
 	}
 	request.ID = c.Param("id")
+	request.UpdatedBy = utils.GetJWT(c).ID
 
-	response, err := delivery.actionUC.Update(request)
+	var ok bool
+	if request.Version, ok = delivery.ifMatch(c); !ok {
+		return
+	}
+
+	response, err := delivery.actionUC.Update(c.Request.Context(), request)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "Action not found", constants.ActionService, "02")
@@ -107,22 +127,37 @@ func (delivery *actionDelivery) Update(c *gin.Context) {
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.ActionService, "11")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.ActionService, "04")
 		return
 	}
 
+	utils.SetETag(c, response.Version)
 	json.NewResponseSuccess(c, response, "Action updated successfully", constants.ActionService, "01")
 }
 
 func (delivery *actionDelivery) Delete(c *gin.Context) {
 	actionID := c.Param("id")
-	err := delivery.actionUC.Delete(actionID)
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	err := delivery.actionUC.Delete(c.Request.Context(), actionID, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "Action not found", constants.ActionService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.ActionService, "11")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.ActionService, "02")
 		return
 	}
@@ -131,30 +166,186 @@ func (delivery *actionDelivery) Delete(c *gin.Context) {
 
 func (delivery *actionDelivery) SoftDelete(c *gin.Context) {
 	actionID := c.Param("id")
-	err := delivery.actionUC.SoftDelete(actionID)
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	version, err := delivery.actionUC.SoftDelete(c.Request.Context(), actionID, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "Action not found", constants.ActionService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.ActionService, "11")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.ActionService, "02")
 		return
 	}
+	utils.SetETag(c, version)
 	json.NewResponseSuccess(c, nil, "Action deleted successfully", constants.ActionService, "01")
 }
 
 func (delivery *actionDelivery) Restore(c *gin.Context) {
 	actionID := c.Param("id")
-	err := delivery.actionUC.Restore(actionID)
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	err := delivery.actionUC.Restore(c.Request.Context(), actionID, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "Action not found", constants.ActionService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.ActionService, "11")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.ActionService, "02")
 		return
 	}
 	json.NewResponseSuccess(c, nil, "Action restored successfully", constants.ActionService, "01")
-}
\ No newline at end of file
+}
+
+// Import creates and updates actions from an uploaded CSV or XLSX file. With
+// dry_run=true it only reports what the import would do.
+func (delivery *actionDelivery) Import(c *gin.Context) {
+	records, err := utils.ReadUpload(c, "file")
+	if err != nil {
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "file", Message: err.Error()}}, "Bad request", constants.ActionService, "07")
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	result, err := delivery.actionUC.Import(c.Request.Context(), records, utils.GetJWT(c).ID, dryRun)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.ActionService, "04")
+		return
+	}
+
+	if result.Failed > 0 {
+		json.NewResponseBadRequest(c, utils.ImportErrors(result), "The file has invalid rows, nothing was imported", constants.ActionService, "08")
+		return
+	}
+	if dryRun {
+		json.NewResponseSuccess(c, result, "The file can be imported", constants.ActionService, "02")
+		return
+	}
+	json.NewResponseCreated(c, result, "Actions imported successfully", constants.ActionService, "02")
+}
+
+// Export sends the actions matching the filters of the list as a CSV or XLSX
+// file, csv unless the format query asks for xlsx.
+func (delivery *actionDelivery) Export(c *gin.Context) {
+	query, invalid := utils.ParseQuery(c, action.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(c, invalid, "Bad request", constants.ActionService, "02")
+		return
+	}
+
+	file, err := delivery.actionUC.Export(c.Request.Context(), query, c.DefaultQuery("format", constants.ReportFormatCSV))
+	if err != nil {
+		if err.Error() == constants.ErrInvalidFileFormat {
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "format", Message: err.Error()}}, "Bad request", constants.ActionService, "09")
+			return
+		}
+		json.NewResponseError(c, err.Error(), constants.ActionService, "01")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+file.FileName+`"`)
+	c.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
+func (delivery *actionDelivery) GetPrices(c *gin.Context) {
+	response, err := delivery.actionUC.GetPrices(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.priceError(c, err)
+		return
+	}
+
+	json.NewResponseSuccess(c, response, "action prices successfully retrieved", constants.ActionService, "01")
+}
+
+func (delivery *actionDelivery) SchedulePrice(c *gin.Context) {
+	var request priceDto.PriceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.ActionService, "01")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.ActionService, "02")
+		return
+	}
+	request.ItemID, request.CreatedBy = c.Param("id"), utils.GetJWT(c).ID
+
+	var ok bool
+	if request.Version, ok = delivery.ifMatch(c); !ok {
+		return
+	}
+
+	response, err := delivery.actionUC.SchedulePrice(c.Request.Context(), request)
+	if err != nil {
+		delivery.priceError(c, err)
+		return
+	}
+
+	json.NewResponseCreated(c, response, "Action price scheduled successfully", constants.ActionService, "01")
+}
+
+func (delivery *actionDelivery) CancelPrice(c *gin.Context) {
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	if err := delivery.actionUC.CancelPrice(c.Request.Context(), c.Param("id"), c.Param("priceId"), version); err != nil {
+		delivery.priceError(c, err)
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Action price canceled successfully", constants.ActionService, "01")
+}
+
+func (delivery *actionDelivery) priceError(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		json.NewResponseNotFound(c, "Action price not found", constants.ActionService, "01")
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrDateTimeFormat, constants.ErrPriceEffectiveInPast:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "effective_from", Message: err.Error()}}, "Bad request", constants.ActionService, "05")
+		return
+	case constants.ErrPriceNotScheduled:
+		json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.ActionService, "06")
+		return
+	case constants.ErrVersionConflict:
+		json.NewResponsePreconditionFailed(c, err.Error(), constants.ActionService, "11")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.ActionService, "04")
+}
+
+// ifMatch reads the version the change is made against from the If-Match
+// header, answering the request itself when the header is missing or invalid.
+func (delivery *actionDelivery) ifMatch(c *gin.Context) (int, bool) {
+	version, err := utils.IfMatch(c)
+	if err == nil {
+		return version, true
+	}
+
+	if err.Error() == constants.ErrIfMatchRequired {
+		json.NewResponsePreconditionRequired(c, err.Error(), constants.ActionService, "10")
+		return 0, false
+	}
+	json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: constants.IfMatchHeader, Message: err.Error()}}, "Bad request", constants.ActionService, "10")
+	return 0, false
+}
@@ -1,12 +1,19 @@
 package actionDelivery
 
 import (
+	"avengers-clinic/model/dto"
 	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -14,47 +21,80 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"github.com/xuri/excelize/v2"
 )
 
+var defaultQuery = queryDto.Query{Page: 1, Size: 10, Sort: []queryDto.Sort{{Field: "name"}}}
+
 type mockActionUsecase struct {
 	mock.Mock
 }
 
-func (mock *mockActionUsecase) GetAll() ([]actionDto.Action, error) {
-	args := mock.Called()
-	return args.Get(0).([]actionDto.Action), args.Error(1)
+func (mock *mockActionUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]actionDto.Action, queryDto.Paging, error) {
+	args := mock.Called(query)
+	return args.Get(0).([]actionDto.Action), args.Get(1).(queryDto.Paging), args.Error(2)
 }
 
-func (mock *mockActionUsecase) GetByID(actionID string) (actionDto.Action, error) {
+func (mock *mockActionUsecase) GetByID(ctx context.Context, actionID string) (actionDto.Action, error) {
 	args := mock.Called(actionID)
 	return args.Get(0).(actionDto.Action), args.Error(1)
 }
 
-func (mock *mockActionUsecase) Create(req actionDto.CreateRequest) (actionDto.Action, error) {
+func (mock *mockActionUsecase) Create(ctx context.Context, req actionDto.CreateRequest) (actionDto.Action, error) {
 	args := mock.Called(req)
 	return args.Get(0).(actionDto.Action), args.Error(1)
 }
 
-func (mock *mockActionUsecase) Update(req actionDto.UpdateRequest) (actionDto.Action, error) {
+func (mock *mockActionUsecase) Update(ctx context.Context, req actionDto.UpdateRequest) (actionDto.Action, error) {
 	args := mock.Called(req)
 	return args.Get(0).(actionDto.Action), args.Error(1)
 }
 
-func (mock *mockActionUsecase) Delete(actionID string) error {
-	args := mock.Called(actionID)
+func (mock *mockActionUsecase) Delete(ctx context.Context, actionID string, version int) error {
+	args := mock.Called(actionID, version)
 	return args.Error(0)
 }
 
-func (mock *mockActionUsecase) SoftDelete(actionID string) error {
-	args := mock.Called(actionID)
+func (mock *mockActionUsecase) SoftDelete(ctx context.Context, actionID string, version int) (int, error) {
+	args := mock.Called(actionID, version)
+	return args.Int(0), args.Error(1)
+}
+
+func (mock *mockActionUsecase) Restore(ctx context.Context, actionID string, version int) error {
+	args := mock.Called(actionID, version)
 	return args.Error(0)
 }
 
-func (mock *mockActionUsecase) Restore(actionID string) error {
+func (mock *mockActionUsecase) GetPrices(ctx context.Context, actionID string) ([]priceDto.Price, error) {
 	args := mock.Called(actionID)
+	return args.Get(0).([]priceDto.Price), args.Error(1)
+}
+
+func (mock *mockActionUsecase) SchedulePrice(ctx context.Context, req priceDto.PriceRequest) ([]priceDto.Price, error) {
+	args := mock.Called(req)
+	return args.Get(0).([]priceDto.Price), args.Error(1)
+}
+
+func (mock *mockActionUsecase) CancelPrice(ctx context.Context, actionID, priceID string, version int) error {
+	args := mock.Called(actionID, priceID, version)
 	return args.Error(0)
 }
 
+func (mock *mockActionUsecase) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	args := mock.Called(retentionDays)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
+func (mock *mockActionUsecase) Import(ctx context.Context, records []sheetDto.Record, updatedBy string, dryRun bool) (sheetDto.ImportResult, error) {
+	args := mock.Called(records, updatedBy, dryRun)
+	return args.Get(0).(sheetDto.ImportResult), args.Error(1)
+}
+
+func (mock *mockActionUsecase) Export(ctx context.Context, query queryDto.Query, format string) (sheetDto.File, error) {
+	args := mock.Called(query, format)
+	return args.Get(0).(sheetDto.File), args.Error(1)
+}
+
 type actionDeliveryTestSuite struct {
 	suite.Suite
 	router *gin.Engine
@@ -73,7 +113,7 @@ func (suite *actionDeliveryTestSuite) SetupTest() {
 func (suite *actionDeliveryTestSuite) TestGetAllSuccess() {
 	actions := []actionDto.Action{{ID: "1", Name: "Konsultasi", Price: 20000}}
 
-	suite.actionUC.On("GetAll").Return(actions, nil)
+	suite.actionUC.On("GetAll", defaultQuery).Return(actions, queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, nil)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/actions", nil)
@@ -82,7 +122,7 @@ func (suite *actionDeliveryTestSuite) TestGetAllSuccess() {
 	req.Header.Set("Authorization", "Bearer "+token)
 	suite.router.ServeHTTP(res, req)
 
-	expected := `{"responseCode":"2000201","responseMessage":"actions successfully retrieved","data":[{"id":"1","name":"Konsultasi","price":20000}]}`
+	expected := `{"responseCode":"2000201","responseMessage":"actions successfully retrieved","data":[{"id":"1","name":"Konsultasi","price":20000}],"paging":{"page":1,"size":10,"total_rows":1,"total_pages":1}}`
 
 	suite.Equal(http.StatusOK, res.Code)
 	suite.JSONEq(expected, res.Body.String())
@@ -91,7 +131,7 @@ func (suite *actionDeliveryTestSuite) TestGetAllSuccess() {
 func (suite *actionDeliveryTestSuite) TestGetAllNotFound() {
 	actions := []actionDto.Action{}
 
-	suite.actionUC.On("GetAll").Return(actions, nil)
+	suite.actionUC.On("GetAll", defaultQuery).Return(actions, queryDto.Paging{}, nil)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/actions", nil)
@@ -109,7 +149,7 @@ func (suite *actionDeliveryTestSuite) TestGetAllNotFound() {
 func (suite *actionDeliveryTestSuite) TestGetAllInternalServerError() {
 	actions := []actionDto.Action{}
 
-	suite.actionUC.On("GetAll").Return(actions, sql.ErrConnDone)
+	suite.actionUC.On("GetAll", defaultQuery).Return(actions, queryDto.Paging{}, sql.ErrConnDone)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/actions", nil)
@@ -123,11 +163,24 @@ func (suite *actionDeliveryTestSuite) TestGetAllInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expected, res.Body.String())
 }
+func (suite *actionDeliveryTestSuite) TestGetAllInvalidDateRange() {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/actions?start_date=2024-03-10&end_date=2024-03-01", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000202","responseMessage":"Bad request","error_description":[{"field":"start_date","message":"the start date must not be after the end date"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
 // End Get All
 
 // Start Get By ID
 func (suite *actionDeliveryTestSuite) TestGetByIDSuccess() {
-	action := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000}
+	action := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000, Version: 3}
 
 	suite.actionUC.On("GetByID", mock.Anything).Return(action, nil)
 	
@@ -138,10 +191,11 @@ func (suite *actionDeliveryTestSuite) TestGetByIDSuccess() {
 	req.Header.Set("Authorization", "Bearer "+token)
 	suite.router.ServeHTTP(res, req)
 
-	expected := `{"responseCode":"2000201","responseMessage":"action successfully retrieved","data":{"id":"1","name":"Konsultasi","price":20000}}`
+	expected := `{"responseCode":"2000201","responseMessage":"action successfully retrieved","data":{"id":"1","name":"Konsultasi","price":20000,"version":3}}`
 
 	suite.Equal(http.StatusOK, res.Code)
 	suite.JSONEq(expected, res.Body.String())
+	suite.Equal(`"3"`, res.Header().Get("ETag"))
 }
 
 func (suite *actionDeliveryTestSuite) TestGetByIDNotFound() {
@@ -281,6 +335,7 @@ func (suite *actionDeliveryTestSuite) TestUpdateSuccess() {
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -297,6 +352,7 @@ func (suite *actionDeliveryTestSuite) TestUpdateErrorJSON() {
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -316,6 +372,7 @@ func (suite *actionDeliveryTestSuite) TestUpdateErrorActionNotFound() {
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -335,6 +392,7 @@ func (suite *actionDeliveryTestSuite) TestUpdateErrorNameExist() {
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -354,6 +412,7 @@ func (suite *actionDeliveryTestSuite) TestUpdateErrorInternalServerError() {
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -364,14 +423,69 @@ func (suite *actionDeliveryTestSuite) TestUpdateErrorInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expected, res.Body.String())
 }
+
+func (suite *actionDeliveryTestSuite) TestUpdatePassesIfMatch() {
+	requestBody := []byte(`{"price":25000}`)
+	action := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 25000, Version: 4}
+
+	suite.actionUC.On("Update", actionDto.UpdateRequest{ID: "1", Price: 25000, UpdatedBy: "1", Version: 3}).Return(action, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"3"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.Equal(`"4"`, res.Header().Get("ETag"))
+}
+
+func (suite *actionDeliveryTestSuite) TestUpdateWithoutIfMatch() {
+	requestBody := []byte(`{"price":25000}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4280210","responseMessage":"` + constants.ErrIfMatchRequired + `"}`
+
+	suite.Equal(http.StatusPreconditionRequired, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+	suite.actionUC.AssertNotCalled(suite.T(), "Update", mock.Anything)
+}
+
+func (suite *actionDeliveryTestSuite) TestUpdateVersionConflict() {
+	requestBody := []byte(`{"price":25000}`)
+
+	suite.actionUC.On("Update", mock.Anything).Return(actionDto.Action{}, errors.New(constants.ErrVersionConflict))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"3"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4120211","responseMessage":"` + constants.ErrVersionConflict + `"}`
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
 // End Update
 
 // Start Delete
 func (suite *actionDeliveryTestSuite) TestDeleteSuccess() {
-	suite.actionUC.On("Delete", mock.Anything).Return(nil)
+	suite.actionUC.On("Delete", mock.Anything, mock.Anything).Return(nil)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -384,10 +498,11 @@ func (suite *actionDeliveryTestSuite) TestDeleteSuccess() {
 }
 
 func (suite *actionDeliveryTestSuite) TestDeleteErrorActionNotFound() {
-	suite.actionUC.On("Delete", mock.Anything).Return(sql.ErrNoRows)
+	suite.actionUC.On("Delete", mock.Anything, mock.Anything).Return(sql.ErrNoRows)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -400,10 +515,11 @@ func (suite *actionDeliveryTestSuite) TestDeleteErrorActionNotFound() {
 }
 
 func (suite *actionDeliveryTestSuite) TestDeleteInternalServerError() {
-	suite.actionUC.On("Delete", mock.Anything).Return(sql.ErrConnDone)
+	suite.actionUC.On("Delete", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -418,10 +534,11 @@ func (suite *actionDeliveryTestSuite) TestDeleteInternalServerError() {
 
 // Start Soft Delete
 func (suite *actionDeliveryTestSuite) TestSoftDeleteSuccess() {
-	suite.actionUC.On("SoftDelete", mock.Anything).Return(nil)
+	suite.actionUC.On("SoftDelete", "1", 1).Return(2, nil)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1/trash", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -431,13 +548,15 @@ func (suite *actionDeliveryTestSuite) TestSoftDeleteSuccess() {
 	
 	suite.Equal(http.StatusOK, res.Code)
 	suite.JSONEq(expected, res.Body.String())
+	suite.Equal(`"2"`, res.Header().Get("ETag"))
 }
 
 func (suite *actionDeliveryTestSuite) TestSoftDeleteErrorActionNotFound() {
-	suite.actionUC.On("SoftDelete", mock.Anything).Return(sql.ErrNoRows)
+	suite.actionUC.On("SoftDelete", mock.Anything, mock.Anything).Return(0, sql.ErrNoRows)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1/trash", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -450,10 +569,11 @@ func (suite *actionDeliveryTestSuite) TestSoftDeleteErrorActionNotFound() {
 }
 
 func (suite *actionDeliveryTestSuite) TestSoftDeleteInternalServerError() {
-	suite.actionUC.On("SoftDelete", mock.Anything).Return(sql.ErrConnDone)
+	suite.actionUC.On("SoftDelete", mock.Anything, mock.Anything).Return(0, sql.ErrConnDone)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1/trash", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -468,10 +588,11 @@ func (suite *actionDeliveryTestSuite) TestSoftDeleteInternalServerError() {
 
 // Start Restore
 func (suite *actionDeliveryTestSuite) TestRestoreSuccess() {
-	suite.actionUC.On("Restore", mock.Anything).Return(nil)
+	suite.actionUC.On("Restore", mock.Anything, mock.Anything).Return(nil)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -484,10 +605,11 @@ func (suite *actionDeliveryTestSuite) TestRestoreSuccess() {
 }
 
 func (suite *actionDeliveryTestSuite) TestRestoreErrorActionNotFound() {
-	suite.actionUC.On("Restore", mock.Anything).Return(sql.ErrNoRows)
+	suite.actionUC.On("Restore", mock.Anything, mock.Anything).Return(sql.ErrNoRows)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -500,10 +622,11 @@ func (suite *actionDeliveryTestSuite) TestRestoreErrorActionNotFound() {
 }
 
 func (suite *actionDeliveryTestSuite) TestRestoreInternalServerError() {
-	suite.actionUC.On("Restore", mock.Anything).Return(sql.ErrConnDone)
+	suite.actionUC.On("Restore", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
 	
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/actions/1/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -516,6 +639,134 @@ func (suite *actionDeliveryTestSuite) TestRestoreInternalServerError() {
 }
 // End Restore
 
+// Start Price
+func (suite *actionDeliveryTestSuite) TestGetPricesNotFound() {
+	suite.actionUC.On("GetPrices", "1").Return([]priceDto.Price(nil), sql.ErrNoRows)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/actions/1/prices", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4040201","responseMessage":"Action price not found"}`
+
+	suite.Equal(http.StatusNotFound, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *actionDeliveryTestSuite) TestSchedulePriceErrorInPast() {
+	requestBody := []byte(`{"price":25000,"effective_from":"2024-01-01"}`)
+
+	suite.actionUC.On("SchedulePrice", mock.Anything).Return([]priceDto.Price(nil), errors.New(constants.ErrPriceEffectiveInPast))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/actions/1/prices", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000205","responseMessage":"Bad request","error_description":[{"field":"effective_from","message":"a price change cannot take effect in the past"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *actionDeliveryTestSuite) TestCancelPriceSuccess() {
+	suite.actionUC.On("CancelPrice", "1", "p2", 1).Return(nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1/prices/p2", nil)
+	req.Header.Set("If-Match", `"1"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"2000201","responseMessage":"Action price canceled successfully"}`
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *actionDeliveryTestSuite) TestCancelPriceVersionConflict() {
+	suite.actionUC.On("CancelPrice", "1", "p2", 2).Return(errors.New(constants.ErrVersionConflict))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/actions/1/prices/p2", nil)
+	req.Header.Set("If-Match", `"2"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+}
+// End Price
+
+// Start Import
+func (suite *actionDeliveryTestSuite) TestImportXLSXDryRun() {
+	workbook := excelize.NewFile()
+	workbook.SetSheetRow("Sheet1", "A1", &[]interface{}{"Name", "Price", "Description"})
+	workbook.SetSheetRow("Sheet1", "A3", &[]interface{}{"Konsultasi", 25000})
+	content, _ := workbook.WriteToBuffer()
+
+	records := []sheetDto.Record{{Line: 3, Values: map[string]string{"name": "Konsultasi", "price": "25000"}}}
+	result := sheetDto.ImportResult{DryRun: true, Updated: 1, Rows: []sheetDto.ImportRow{{Line: 3, Name: "Konsultasi", Action: constants.ImportUpdate, ID: "a1"}}}
+	suite.actionUC.On("Import", records, "1", true).Return(result, nil)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "actions.xlsx")
+	part.Write(content.Bytes())
+	writer.Close()
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/actions/import?dry_run=true", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"2000202","responseMessage":"The file can be imported","data":{"dry_run":true,"created":0,"updated":1,"failed":0,"rows":[{"line":3,"name":"Konsultasi","action":"update","id":"a1"}]}}`
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *actionDeliveryTestSuite) TestImportWithoutFile() {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/actions/import", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.actionUC.AssertNotCalled(suite.T(), "Import", mock.Anything, mock.Anything, mock.Anything)
+}
+// End Import
+
+func (suite *actionDeliveryTestSuite) TestExportSuccess() {
+	file := sheetDto.File{FileName: "actions.csv", ContentType: "text/csv", Content: []byte("name,price,description\n")}
+	suite.actionUC.On("Export", defaultQuery, "csv").Return(file, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/actions/export", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.Equal("text/csv", res.Header().Get("Content-Type"))
+	suite.Equal(`attachment; filename="actions.csv"`, res.Header().Get("Content-Disposition"))
+}
+
 func (suite *actionDeliveryTestSuite) SetupSuite() {
 	gin.SetMode(gin.TestMode)
 }
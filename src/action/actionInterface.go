@@ -1,25 +1,43 @@
 package action
 
-import "avengers-clinic/model/dto/actionDto"
+import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"context"
+)
 
 type ActionRepository interface {
-	GetAll() ([]actionDto.Action, error)
-	GetByID(actionID string) (actionDto.Action, error)
-	GetTrashByID(actionID string) (actionDto.Action, error)
-	Insert(action actionDto.Action) (string, error)
-	Update(action actionDto.Action) error
-	Delete(actionID string) error
-	SoftDelete(actionID string) error
-	Restore(actionID string) error
-	IsNameExist(name string) bool
+	GetAll(ctx context.Context, query queryDto.Query) ([]actionDto.Action, int, error)
+	GetByID(ctx context.Context, actionID string) (actionDto.Action, error)
+	GetTrashByID(ctx context.Context, actionID string) (actionDto.Action, error)
+	GetByName(ctx context.Context, name string) (actionDto.Action, error)
+	Insert(ctx context.Context, action actionDto.Action) (string, error)
+	Update(ctx context.Context, action actionDto.Action) (int, error)
+	Delete(ctx context.Context, actionID string, version int) error
+	SoftDelete(ctx context.Context, actionID string, version int) error
+	Restore(ctx context.Context, actionID string, version int) error
+	IsNameExist(ctx context.Context, name string) bool
+	GetPrices(ctx context.Context, actionID string) ([]priceDto.Price, error)
+	InsertPrice(ctx context.Context, req priceDto.PriceRequest) (string, error)
+	DeletePrice(ctx context.Context, actionID, priceID, now string, version int) error
+	PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error)
 }
 
 type ActionUsecase interface {
-	GetAll() ([]actionDto.Action, error)
-	GetByID(actionID string) (actionDto.Action, error)
-	Create(req actionDto.CreateRequest) (actionDto.Action, error)
-	Update(req actionDto.UpdateRequest) (actionDto.Action, error)
-	Delete(actionID string) error
-	SoftDelete(actionID string) error
-	Restore(actionID string) error
+	GetAll(ctx context.Context, query queryDto.Query) ([]actionDto.Action, queryDto.Paging, error)
+	GetByID(ctx context.Context, actionID string) (actionDto.Action, error)
+	Create(ctx context.Context, req actionDto.CreateRequest) (actionDto.Action, error)
+	Update(ctx context.Context, req actionDto.UpdateRequest) (actionDto.Action, error)
+	Delete(ctx context.Context, actionID string, version int) error
+	SoftDelete(ctx context.Context, actionID string, version int) (int, error)
+	Restore(ctx context.Context, actionID string, version int) error
+	GetPrices(ctx context.Context, actionID string) ([]priceDto.Price, error)
+	SchedulePrice(ctx context.Context, req priceDto.PriceRequest) ([]priceDto.Price, error)
+	CancelPrice(ctx context.Context, actionID, priceID string, version int) error
+	PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error)
+	Import(ctx context.Context, records []sheetDto.Record, updatedBy string, dryRun bool) (sheetDto.ImportResult, error)
+	Export(ctx context.Context, query queryDto.Query, format string) (sheetDto.File, error)
 }
\ No newline at end of file
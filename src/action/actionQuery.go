@@ -0,0 +1,16 @@
+package action
+
+import "avengers-clinic/model/dto/queryDto"
+
+// ListResource is what the list of actions can be sorted by. Price sorts by
+// the price in effect now.
+var ListResource = queryDto.Resource{
+	Sorts: map[string]string{
+		"name":       "name",
+		"price":      "price",
+		"created_at": "created_at",
+	},
+	DateColumn:  "created_at",
+	DefaultSort: []queryDto.Sort{{Field: "name"}},
+	Key:         "id",
+}
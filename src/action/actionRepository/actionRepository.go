@@ -1,11 +1,24 @@
 package actionRepository
 
 import (
+	"avengers-clinic/model/dto"
 	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/action"
+	"context"
 	"database/sql"
+	"errors"
 )
 
+// currentPrice is the price of the action in effect right now. Actions without
+// a price list keep the price they were created with.
+const currentPrice = `COALESCE((SELECT p.price FROM action_prices p WHERE p.action_id = actions.id AND p.effective_from <= LOCALTIMESTAMP
+	ORDER BY p.effective_from DESC LIMIT 1), actions.price)`
+
 type actionRepository struct {
 	db *sql.DB
 }
@@ -14,94 +27,297 @@ func NewActionRepository(db *sql.DB) (action.ActionRepository) {
 	return &actionRepository{db}
 }
 
-func (repository *actionRepository) GetAll() ([]actionDto.Action, error) {
-	query := `
-		SELECT id, name, price, description, created_at, updated_at
-		FROM actions WHERE deleted_at IS NULL;
-	`
-	rows, err := repository.db.Query(query)
+// GetAll returns a page of actions and how many actions match the query in
+// all. Sorting by price sorts by the current price, selected as price.
+func (repository *actionRepository) GetAll(ctx context.Context, query queryDto.Query) ([]actionDto.Action, int, error) {
+	conditions, args := utils.ListConditions(query, action.ListResource, nil)
+
+	var total int
+	if err := repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM actions WHERE deleted_at IS NULL"+conditions+";", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order, args := utils.ListOrder(query, action.ListResource, args)
+	rows, err := repository.db.QueryContext(ctx, `
+		SELECT id, name, `+currentPrice+` AS price, description, created_at, updated_at, version
+		FROM actions WHERE deleted_at IS NULL`+conditions+order+";", args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 	actions, err := scanActions(rows)
-	return actions, err
+	return actions, total, err
 }
 
-func (repository *actionRepository) GetByID(actionID string) (actionDto.Action, error) {
+func (repository *actionRepository) GetByID(ctx context.Context, actionID string) (actionDto.Action, error) {
 	query := `
-		SELECT id, name, price, description, created_at, updated_at
+		SELECT id, name, ` + currentPrice + `, description, created_at, updated_at, version
 		FROM actions WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
 	`
-	action, err := scanAction(repository.db.QueryRow(query, actionID))
+	action, err := scanAction(repository.db.QueryRowContext(ctx, query, actionID))
 	return action, err
 }
 
-func (repository *actionRepository) GetTrashByID(actionID string) (actionDto.Action, error) {
+func (repository *actionRepository) GetTrashByID(ctx context.Context, actionID string) (actionDto.Action, error) {
 	query := `
-		SELECT id, name, price, description, created_at, updated_at
+		SELECT id, name, ` + currentPrice + `, description, created_at, updated_at, version
 		FROM actions WHERE id = $1 AND deleted_at IS NOT NULL LIMIT 1;
 	`
-	action, err := scanAction(repository.db.QueryRow(query, actionID))
+	action, err := scanAction(repository.db.QueryRowContext(ctx, query, actionID))
 	return action, err
 }
 
-func (repository *actionRepository) Insert(action actionDto.Action) (string, error) {
-	query := `
-		INSERT INTO actions (name, price, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5) RETURNING id;
-	`
-	err := repository.db.QueryRow(
-		query,
-		action.Name,
-		action.Price,
-		action.Description,
-		action.CreatedAt,
-		action.UpdatedAt,
-	).Scan(&action.ID)
-	return action.ID, err
-}
-
-func (repository *actionRepository) Update(action actionDto.Action) error {
+func (repository *actionRepository) GetByName(ctx context.Context, name string) (actionDto.Action, error) {
 	query := `
-		UPDATE actions SET name = $2, price = $3, description = $4, updated_at = $5
-		WHERE id = $1;
+		SELECT id, name, ` + currentPrice + `, description, created_at, updated_at, version
+		FROM actions WHERE name = $1 AND deleted_at IS NULL LIMIT 1;
 	`
-	_, err := repository.db.Exec(
-		query,
-		action.ID,
-		action.Name,
-		action.Price,
-		action.Description,
-		action.UpdatedAt,
-	)
-	return err
+	action, err := scanAction(repository.db.QueryRowContext(ctx, query, name))
+	return action, err
+}
+
+func (repository *actionRepository) Insert(ctx context.Context, action actionDto.Action) (string, error) {
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := `
+			INSERT INTO actions (name, price, description, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id;
+		`
+		err := tx.QueryRowContext(ctx,
+			query,
+			action.Name,
+			action.Price,
+			action.Description,
+			action.CreatedAt,
+			action.UpdatedAt,
+		).Scan(&action.ID)
+		if err != nil {
+			return err
+		}
+
+		query = "INSERT INTO action_prices (action_id, price, effective_from, created_at) VALUES ($1, $2, $3, $3);"
+		_, err = tx.ExecContext(ctx, query, action.ID, action.Price, action.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return action.ID, nil
+}
+
+// Update changes the details of the action and returns its new version. A
+// different price is added to its price list from UpdatedAt on, so records made
+// before keep the old price. With a version given the action is only changed
+// while it is still at it.
+func (repository *actionRepository) Update(ctx context.Context, action actionDto.Action) (int, error) {
+	var version int
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := `
+			UPDATE actions SET name = $2, description = $3, updated_at = $4, version = version + 1
+			WHERE id = $1 AND ($5 = 0 OR version = $5) RETURNING version;
+		`
+		err := tx.QueryRowContext(ctx,
+			query,
+			action.ID,
+			action.Name,
+			action.Description,
+			action.UpdatedAt,
+			action.Version,
+		).Scan(&version)
+		if err == sql.ErrNoRows && action.Version != 0 {
+			return errors.New(constants.ErrVersionConflict)
+		}
+		if err != nil {
+			return err
+		}
+
+		price, err := PriceAt(ctx, tx, action.ID, action.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if action.Price != price {
+			req := priceDto.PriceRequest{ItemID: action.ID, Price: action.Price, EffectiveFrom: action.UpdatedAt, CreatedBy: action.UpdatedBy}
+			if _, err := insertPrice(ctx, tx, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
 }
 
-func (repository *actionRepository) Delete(actionID string) error {
-	query := "DELETE FROM actions WHERE id = $1;"
-	_, err := repository.db.Exec(query, actionID)
-	return err
+// Delete deletes the action while it is still at the version, a version of 0
+// deletes any version.
+func (repository *actionRepository) Delete(ctx context.Context, actionID string, version int) error {
+	query := "DELETE FROM actions WHERE id = $1 AND ($2 = 0 OR version = $2);"
+	result, err := repository.db.ExecContext(ctx, query, actionID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
 }
 
-func (repository *actionRepository) SoftDelete(actionID string) error {
-	query := "UPDATE actions SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1;"
-	_, err := repository.db.Exec(query, actionID)
-	return err
+// SoftDelete moves the action to the trash while it is still at the version, a
+// version of 0 moves any version.
+func (repository *actionRepository) SoftDelete(ctx context.Context, actionID string, version int) error {
+	query := "UPDATE actions SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2);"
+	result, err := repository.db.ExecContext(ctx, query, actionID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
 }
 
-func (repository *actionRepository) Restore(actionID string) error {
-	query := "UPDATE actions SET deleted_at = NULL WHERE id = $1;"
-	_, err := repository.db.Exec(query, actionID)
-	return err
+// Restore takes the action out of the trash while it is still at the version,
+// a version of 0 restores any version.
+func (repository *actionRepository) Restore(ctx context.Context, actionID string, version int) error {
+	query := "UPDATE actions SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2);"
+	result, err := repository.db.ExecContext(ctx, query, actionID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
 }
 
-func (repository *actionRepository) IsNameExist(name string) bool {
+func (repository *actionRepository) IsNameExist(ctx context.Context, name string) bool {
 	count, query := 0, "SELECT COUNT(*) FROM actions WHERE name = $1;"
-	repository.db.QueryRow(query, name).Scan(&count)
+	repository.db.QueryRowContext(ctx, query, name).Scan(&count)
 	return count > 0
 }
 
+func (repository *actionRepository) GetPrices(ctx context.Context, actionID string) ([]priceDto.Price, error) {
+	query := `
+		SELECT id, price, TO_CHAR(effective_from, 'YYYY-MM-DD HH24:MI:SS'), COALESCE(created_by::text, ''),
+			TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM action_prices WHERE action_id = $1 ORDER BY effective_from DESC;
+	`
+	rows, err := repository.db.QueryContext(ctx, query, actionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []priceDto.Price
+	for rows.Next() {
+		var price priceDto.Price
+		if err := rows.Scan(&price.ID, &price.Price, &price.EffectiveFrom, &price.CreatedBy, &price.CreatedAt); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// InsertPrice adds a price to the price list and moves the action to a new
+// version. With a version given the price is only added while the action is
+// still at it.
+func (repository *actionRepository) InsertPrice(ctx context.Context, req priceDto.PriceRequest) (string, error) {
+	var id string
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		var err error
+		id, err = insertPrice(ctx, transaction.Tx(ctx), req)
+		if err != nil {
+			return err
+		}
+		return bumpVersion(ctx, transaction.Tx(ctx), req.ItemID, req.Version)
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// DeletePrice cancels a price change that has not taken effect at now yet and
+// moves the action to a new version. With a version given the price is only
+// canceled while the action is still at it.
+func (repository *actionRepository) DeletePrice(ctx context.Context, actionID, priceID, now string, version int) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var scheduled bool
+		query := "SELECT effective_from > $3 FROM action_prices WHERE id = $1 AND action_id = $2;"
+		if err := tx.QueryRowContext(ctx, query, priceID, actionID, now).Scan(&scheduled); err != nil {
+			return err
+		}
+
+		if !scheduled {
+			return errors.New(constants.ErrPriceNotScheduled)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM action_prices WHERE id = $1;", priceID); err != nil {
+			return err
+		}
+		return bumpVersion(ctx, tx, actionID, version)
+	})
+}
+
+// PriceAt returns the price of the action in effect at the given time within
+// the transaction of the caller.
+func PriceAt(ctx context.Context, tx *sql.Tx, actionID, at string) (int, error) {
+	var price int
+	query := `
+		SELECT COALESCE((SELECT price FROM action_prices WHERE action_id = $1 AND effective_from <= $2 ORDER BY effective_from DESC LIMIT 1),
+			(SELECT price FROM actions WHERE id = $1));
+	`
+	err := tx.QueryRowContext(ctx, query, actionID, at).Scan(&price)
+	return price, err
+}
+
+func (repository *actionRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	return utils.PurgeTrash(ctx, repository.db, "actions", deletedBefore)
+}
+
+// insertPrice adds the price to the price list of the action. A price set for
+// the same moment replaces the earlier one.
+func insertPrice(ctx context.Context, tx *sql.Tx, req priceDto.PriceRequest) (string, error) {
+	var id string
+	query := `
+		INSERT INTO action_prices (action_id, price, effective_from, created_by, created_at) VALUES ($1, $2, $3, $4, LOCALTIMESTAMP)
+		ON CONFLICT (action_id, effective_from) DO UPDATE SET price = EXCLUDED.price, created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at
+		RETURNING id;
+	`
+	err := tx.QueryRowContext(ctx, query, req.ItemID, req.Price, req.EffectiveFrom, nullable(req.CreatedBy)).Scan(&id)
+	return id, err
+}
+
+// bumpVersion moves the action to a new version, failing with a version
+// conflict when a version is given and the action is no longer at it.
+func bumpVersion(ctx context.Context, tx *sql.Tx, actionID string, version int) error {
+	result, err := tx.ExecContext(ctx, "UPDATE actions SET version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2);", actionID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
+}
+
+// versionChecked fails when a change expecting a version did not find the row
+// at it anymore.
+func versionChecked(result sql.Result, version int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	return nil
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
 func scanAction(row *sql.Row) (actionDto.Action, error) {
 	var action actionDto.Action
 	err := row.Scan(
@@ -111,6 +327,7 @@ func scanAction(row *sql.Row) (actionDto.Action, error) {
 		&action.Description,
 		&action.CreatedAt,
 		&action.UpdatedAt,
+		&action.Version,
 	)
 	return action, err
 }
@@ -126,6 +343,7 @@ func scanActions(rows *sql.Rows) ([]actionDto.Action, error) {
 			&action.Description,
 			&action.CreatedAt,
 			&action.UpdatedAt,
+			&action.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -2,10 +2,15 @@ package actionRepository
 
 import (
 	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/src/action"
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/suite"
@@ -15,6 +20,7 @@ type actionRepositoryTestSuite struct {
 	suite.Suite
 	actionRepo  action.ActionRepository
 	mock sqlmock.Sqlmock
+	db *sql.DB
 }
 
 func (suite *actionRepositoryTestSuite) SetupTest() {
@@ -22,26 +28,45 @@ func (suite *actionRepositoryTestSuite) SetupTest() {
 
 	suite.actionRepo = NewActionRepository(db)
 	suite.mock = mock
+	suite.db = db
 }
 
 // Start Get All
 func (suite *actionRepositoryTestSuite) TestGetAllSuccess() {
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at", "version"})
 
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
-		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z"))
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
+		WithArgs(10, 0).
+		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z", 1))
 
-	actualActions, err := suite.actionRepo.GetAll()
+	actualActions, total, err := suite.actionRepo.GetAll(context.Background(), queryDto.Query{Page: 1, Size: 10})
 
 	suite.Nil(err)
 	suite.NotEmpty(actualActions)
+	suite.Equal(1, total)
+}
+
+func (suite *actionRepositoryTestSuite) TestGetAllSortedByPrice() {
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at", "version"})
+
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	suite.mock.ExpectQuery(`AS price, (.+) ORDER BY price DESC, id LIMIT \$1 OFFSET \$2;`).
+		WithArgs(20, 20).
+		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z", 1))
+
+	_, _, err := suite.actionRepo.GetAll(context.Background(), queryDto.Query{Page: 2, Size: 20, Sort: []queryDto.Sort{{Field: "price", Desc: true}}})
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
 }
 
 func (suite *actionRepositoryTestSuite) TestGetAllError() {
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
 		WillReturnError(sql.ErrConnDone)
 
-	actualActions, err := suite.actionRepo.GetAll()
+	actualActions, _, err := suite.actionRepo.GetAll(context.Background(), queryDto.Query{Page: 1, Size: 10})
 
 	suite.Error(err)
 	suite.Empty(actualActions)
@@ -50,12 +75,12 @@ func (suite *actionRepositoryTestSuite) TestGetAllError() {
 
 func (suite *actionRepositoryTestSuite) TestGetByID() {
 	actionID := "1"
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at", "version"})
 
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
-		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z"))
+	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
+		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z", 1))
 
-	actualAction, err := suite.actionRepo.GetByID(actionID)
+	actualAction, err := suite.actionRepo.GetByID(context.Background(), actionID)
 
 	suite.Nil(err)
 	suite.NotEmpty(actualAction)
@@ -63,62 +88,229 @@ func (suite *actionRepositoryTestSuite) TestGetByID() {
 
 func (suite *actionRepositoryTestSuite) TestGetTrashByID() {
 	actionID := "1"
-	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at", "version"})
 
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
-		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z"))
+	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM actions").
+		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z", 1))
 
-	actualAction, err := suite.actionRepo.GetTrashByID(actionID)
+	actualAction, err := suite.actionRepo.GetTrashByID(context.Background(), actionID)
 
 	suite.Nil(err)
 	suite.NotEmpty(actualAction)
 }
 
+func (suite *actionRepositoryTestSuite) TestGetByName() {
+	rows := sqlmock.NewRows([]string{"id", "name", "price", "description", "created_at", "updated_at", "version"})
+
+	suite.mock.ExpectQuery("FROM actions WHERE name = (.+) AND deleted_at IS NULL").
+		WithArgs("Konsultasi").
+		WillReturnRows(rows.AddRow("1", "Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z", 1))
+
+	actualAction, err := suite.actionRepo.GetByName(context.Background(), "Konsultasi")
+
+	suite.Nil(err)
+	suite.Equal("1", actualAction.ID)
+}
+
 func (suite *actionRepositoryTestSuite) TestInsert() {
 	args := []driver.Value{"Konsultasi", 20000, nil, "2024-03-12T05:20:00Z", "2024-03-12T05:20:00Z"}
 
+	suite.mock.ExpectBegin()
 	suite.mock.ExpectQuery("INSERT INTO actions").
 		WithArgs(args...).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
 
+	// The first price opens the price list of the action
+	suite.mock.ExpectExec("INSERT INTO action_prices").
+		WithArgs("1", 20000, "2024-03-12T05:20:00Z").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
 	action := actionDto.Action{
 		Name: "Konsultasi",
 		Price: 20000,
 		CreatedAt: "2024-03-12T05:20:00Z",
 		UpdatedAt: "2024-03-12T05:20:00Z",
 	}
-	actionID, err := suite.actionRepo.Insert(action)
+	actionID, err := suite.actionRepo.Insert(context.Background(), action)
 
 	suite.Nil(err)
 	suite.NotEmpty(actionID)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+// A request canceled halfway, by a client that went away, rolls its
+// transaction back and gives the connection back to the pool.
+func (suite *actionRepositoryTestSuite) TestInsertCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO actions").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	suite.mock.ExpectRollback()
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := suite.actionRepo.Insert(ctx, actionDto.Action{Name: "Konsultasi", Price: 20000})
+
+	suite.NotNil(err)
+	suite.Eventually(func() bool {
+		return suite.mock.ExpectationsWereMet() == nil && suite.db.Stats().InUse == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *actionRepositoryTestSuite) TestUpdateTimedOut() {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("UPDATE actions").
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.actionRepo.Update(ctx, actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000})
+
+	suite.NotNil(err)
+	suite.Eventually(func() bool {
+		return suite.mock.ExpectationsWereMet() == nil && suite.db.Stats().InUse == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (suite *actionRepositoryTestSuite) TestInsertAlreadyCanceled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := suite.actionRepo.Insert(ctx, actionDto.Action{Name: "Konsultasi", Price: 20000})
+
+	suite.ErrorIs(err, context.Canceled)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+	suite.Zero(suite.db.Stats().InUse)
 }
 
 func (suite *actionRepositoryTestSuite) TestUpdate() {
-	args := []driver.Value{"1", "Konsultasi", 25000, nil, "2024-03-12T05:20:00Z"}
+	args := []driver.Value{"1", "Konsultasi", nil, "2024-03-12T05:20:00Z", 1}
 
-	suite.mock.ExpectExec("UPDATE actions").
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("UPDATE actions").
 		WithArgs(args...).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	suite.mock.ExpectQuery("SELECT COALESCE(.+) FROM action_prices").
+		WithArgs("1", "2024-03-12T05:20:00Z").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(20000))
+
+	// The new price applies from the update on
+	suite.mock.ExpectQuery("INSERT INTO action_prices").
+		WithArgs("1", 25000, "2024-03-12T05:20:00Z", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	suite.mock.ExpectCommit()
 
 	action := actionDto.Action{
 		ID: "1",
 		Name: "Konsultasi",
 		Price: 25000,
+		UpdatedBy: "u1",
 		UpdatedAt: "2024-03-12T05:20:00Z",
+		Version: 1,
 	}
-	err := suite.actionRepo.Update(action)
+	version, err := suite.actionRepo.Update(context.Background(), action)
 
 	suite.Nil(err)
+	suite.Equal(2, version)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *actionRepositoryTestSuite) TestUpdateVersionConflict() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("UPDATE actions").
+		WithArgs("1", "Konsultasi", nil, "2024-03-12T05:20:00Z", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	suite.mock.ExpectRollback()
+
+	action := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 25000, UpdatedAt: "2024-03-12T05:20:00Z", Version: 1}
+	_, err := suite.actionRepo.Update(context.Background(), action)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *actionRepositoryTestSuite) TestUpdateSamePrice() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("UPDATE actions").
+		WithArgs("1", "Konsultasi Dokter", nil, "2024-03-12T05:20:00Z", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	suite.mock.ExpectQuery("SELECT COALESCE(.+) FROM action_prices").
+		WithArgs("1", "2024-03-12T05:20:00Z").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(25000))
+	suite.mock.ExpectCommit()
+
+	action := actionDto.Action{
+		ID: "1",
+		Name: "Konsultasi Dokter",
+		Price: 25000,
+		UpdatedAt: "2024-03-12T05:20:00Z",
+	}
+	_, err := suite.actionRepo.Update(context.Background(), action)
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *actionRepositoryTestSuite) TestDeletePriceNotScheduled() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT effective_from > (.+) FROM action_prices").
+		WithArgs("p1", "1", "2024-03-12 05:20:00").
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled"}).AddRow(false))
+	suite.mock.ExpectRollback()
+
+	err := suite.actionRepo.DeletePrice(context.Background(), "1", "p1", "2024-03-12 05:20:00", 2)
+
+	suite.EqualError(err, constants.ErrPriceNotScheduled)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *actionRepositoryTestSuite) TestDeletePriceBumpsVersion() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT effective_from > (.+) FROM action_prices").
+		WithArgs("p2", "1", "2024-03-12 05:20:00").
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled"}).AddRow(true))
+	suite.mock.ExpectExec("DELETE FROM action_prices").
+		WithArgs("p2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE actions SET version = version \+ 1`).
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+
+	err := suite.actionRepo.DeletePrice(context.Background(), "1", "p2", "2024-03-12 05:20:00", 2)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *actionRepositoryTestSuite) TestInsertPriceBumpsVersion() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO action_prices").
+		WithArgs("1", 25000, "2999-01-01 00:00:00", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p3"))
+	suite.mock.ExpectExec(`UPDATE actions SET version = version \+ 1`).
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	id, err := suite.actionRepo.InsertPrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 25000, EffectiveFrom: "2999-01-01 00:00:00", CreatedBy: "u1", Version: 2})
+
+	suite.Nil(err)
+	suite.Equal("p3", id)
+	suite.Nil(suite.mock.ExpectationsWereMet())
 }
 
 func (suite *actionRepositoryTestSuite) TestDelete() {
 	actionID := "1"
 
 	suite.mock.ExpectExec("DELETE FROM actions").
-		WithArgs(actionID).
+		WithArgs(actionID, 2).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.actionRepo.Delete(actionID)
+	err := suite.actionRepo.Delete(context.Background(), actionID, 2)
 
 	suite.Nil(err)
 }
@@ -127,10 +319,10 @@ func (suite *actionRepositoryTestSuite) TestSoftDelete() {
 	actionID := "1"
 
 	suite.mock.ExpectExec("UPDATE actions").
-		WithArgs(actionID).
+		WithArgs(actionID, 2).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.actionRepo.SoftDelete(actionID)
+	err := suite.actionRepo.SoftDelete(context.Background(), actionID, 2)
 
 	suite.Nil(err)
 }
@@ -139,14 +331,24 @@ func (suite *actionRepositoryTestSuite) TestRestore() {
 	actionID := "1"
 
 	suite.mock.ExpectExec("UPDATE actions").
-		WithArgs(actionID).
+		WithArgs(actionID, 2).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.actionRepo.Restore(actionID)
+	err := suite.actionRepo.Restore(context.Background(), actionID, 2)
 
 	suite.Nil(err)
 }
 
+func (suite *actionRepositoryTestSuite) TestSoftDeleteVersionConflict() {
+	suite.mock.ExpectExec("UPDATE actions SET deleted_at").
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.actionRepo.SoftDelete(context.Background(), "1", 2)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 func (suite *actionRepositoryTestSuite) TestIsNameExist() {
 	name := "Konsultasi"
 
@@ -154,7 +356,7 @@ func (suite *actionRepositoryTestSuite) TestIsNameExist() {
 		WithArgs(name).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
-	actual := suite.actionRepo.IsNameExist(name)
+	actual := suite.actionRepo.IsNameExist(context.Background(), name)
 	
 	suite.True(actual)
 }
@@ -1,8 +1,14 @@
 package actionUsecase
 
 import (
+	"avengers-clinic/model/dto"
 	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/action"
+	"context"
 	"errors"
 	"time"
 )
@@ -15,21 +21,21 @@ func NewActionUsecase(actionRepo action.ActionRepository) action.ActionUsecase {
 	return &actionUsecase{actionRepo}
 }
 
-func (usecase *actionUsecase) GetAll() ([]actionDto.Action, error) {
-	actions, err := usecase.actionRepo.GetAll()
-	return actions, err
+func (usecase *actionUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]actionDto.Action, queryDto.Paging, error) {
+	actions, total, err := usecase.actionRepo.GetAll(ctx, query)
+	return actions, utils.NewPaging(query, total), err
 }
 
-func (usecase *actionUsecase) GetByID(actionID string) (actionDto.Action, error) {
-	action, err := usecase.actionRepo.GetByID(actionID)
+func (usecase *actionUsecase) GetByID(ctx context.Context, actionID string) (actionDto.Action, error) {
+	action, err := usecase.actionRepo.GetByID(ctx, actionID)
 	if err != nil {
 		return actionDto.Action{}, err
 	}
 	return action, nil
 }
 
-func (usecase *actionUsecase) Create(req actionDto.CreateRequest) (actionDto.Action, error) {
-	if usecase.actionRepo.IsNameExist(req.Name) {
+func (usecase *actionUsecase) Create(ctx context.Context, req actionDto.CreateRequest) (actionDto.Action, error) {
+	if usecase.actionRepo.IsNameExist(ctx, req.Name) {
 		return actionDto.Action{}, errors.New("1")
 	}
 
@@ -43,7 +49,7 @@ func (usecase *actionUsecase) Create(req actionDto.CreateRequest) (actionDto.Act
 	}
 
 	var err error
-	action.ID, err = usecase.actionRepo.Insert(action)
+	action.ID, err = usecase.actionRepo.Insert(ctx, action)
 	if err != nil {
 		return actionDto.Action{}, err
 	}
@@ -51,14 +57,21 @@ func (usecase *actionUsecase) Create(req actionDto.CreateRequest) (actionDto.Act
 	return action, err
 }
 
-func (usecase *actionUsecase) Update(req actionDto.UpdateRequest) (actionDto.Action, error) {
-	action, err := usecase.actionRepo.GetByID(req.ID)
+// Update changes the action. A version given in the request has to be the
+// current one, the change is then made against the version read here so
+// concurrent changes cannot overwrite each other.
+func (usecase *actionUsecase) Update(ctx context.Context, req actionDto.UpdateRequest) (actionDto.Action, error) {
+	action, err := usecase.actionRepo.GetByID(ctx, req.ID)
 	if err != nil {
 		return actionDto.Action{}, err
 	}
 
+	if req.Version != 0 && req.Version != action.Version {
+		return actionDto.Action{}, errors.New(constants.ErrVersionConflict)
+	}
+
 	if req.Name != "" {
-		if usecase.actionRepo.IsNameExist(req.Name) && req.Name != action.Name {
+		if usecase.actionRepo.IsNameExist(ctx, req.Name) && req.Name != action.Name {
 			return actionDto.Action{}, errors.New("1")
 		}
 		action.Name = req.Name
@@ -71,47 +84,118 @@ func (usecase *actionUsecase) Update(req actionDto.UpdateRequest) (actionDto.Act
 	if req.Description != "" {
 		action.Description = req.Description
 	}
+	action.UpdatedBy = req.UpdatedBy
 	action.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
 
-	err = usecase.actionRepo.Update(action)
+	action.Version, err = usecase.actionRepo.Update(ctx, action)
 	if err != nil {
 		return actionDto.Action{}, err
 	}
 	return action, nil
 }
 
-func (usecase *actionUsecase) Delete(actionID string) error {
-	_, err := usecase.actionRepo.GetByID(actionID)
+// Delete deletes the action, only while it is at the given version unless the
+// version is 0.
+func (usecase *actionUsecase) Delete(ctx context.Context, actionID string, version int) error {
+	action, err := usecase.actionRepo.GetByID(ctx, actionID)
 	if err != nil {
 		return err
 	}
-	err = usecase.actionRepo.Delete(actionID)
+	if version != 0 && version != action.Version {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	err = usecase.actionRepo.Delete(ctx, actionID, action.Version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (usecase *actionUsecase) SoftDelete(actionID string) error {
-	_, err := usecase.actionRepo.GetByID(actionID)
+// SoftDelete moves the action to the trash, only while it is at the given
+// version unless the version is 0. It returns the version the action is in the
+// trash at, which restoring it expects.
+func (usecase *actionUsecase) SoftDelete(ctx context.Context, actionID string, version int) (int, error) {
+	action, err := usecase.actionRepo.GetByID(ctx, actionID)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	err = usecase.actionRepo.SoftDelete(actionID)
+	if version != 0 && version != action.Version {
+		return 0, errors.New(constants.ErrVersionConflict)
+	}
+	err = usecase.actionRepo.SoftDelete(ctx, actionID, action.Version)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return action.Version + 1, nil
 }
 
-func (usecase *actionUsecase) Restore(actionID string) error {
-	_, err := usecase.actionRepo.GetTrashByID(actionID)
+// Restore takes the action out of the trash, only while it is at the given
+// version unless the version is 0.
+func (usecase *actionUsecase) Restore(ctx context.Context, actionID string, version int) error {
+	action, err := usecase.actionRepo.GetTrashByID(ctx, actionID)
 	if err != nil {
 		return err
 	}
-	err = usecase.actionRepo.Restore(actionID)
+	if version != 0 && version != action.Version {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	err = usecase.actionRepo.Restore(ctx, actionID, action.Version)
 	if err != nil {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (usecase *actionUsecase) GetPrices(ctx context.Context, actionID string) ([]priceDto.Price, error) {
+	if _, err := usecase.actionRepo.GetByID(ctx, actionID); err != nil {
+		return nil, err
+	}
+
+	prices, err := usecase.actionRepo.GetPrices(ctx, actionID)
+	if err != nil {
+		return nil, err
+	}
+	utils.MarkPriceStatus(prices, utils.GetNow())
+	return prices, nil
+}
+
+// SchedulePrice adds a price to the price list of the action, effective
+// immediately or from a later date. A version given in the request has to be
+// the current one.
+func (usecase *actionUsecase) SchedulePrice(ctx context.Context, req priceDto.PriceRequest) ([]priceDto.Price, error) {
+	var err error
+	if req.EffectiveFrom, err = utils.EffectiveFrom(req.EffectiveFrom, utils.GetNow()); err != nil {
+		return nil, err
+	}
+
+	action, err := usecase.actionRepo.GetByID(ctx, req.ItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Version != 0 && req.Version != action.Version {
+		return nil, errors.New(constants.ErrVersionConflict)
+	}
+	req.Version = action.Version
+
+	if _, err := usecase.actionRepo.InsertPrice(ctx, req); err != nil {
+		return nil, err
+	}
+	return usecase.GetPrices(ctx, req.ItemID)
+}
+
+// CancelPrice removes a price change that has not taken effect yet, only while
+// the action is at the given version unless the version is 0.
+func (usecase *actionUsecase) CancelPrice(ctx context.Context, actionID, priceID string, version int) error {
+	return usecase.actionRepo.DeletePrice(ctx, actionID, priceID, utils.GetNow(), version)
+}
+
+// PurgeTrash deletes the actions that have been in the trash for more than
+// retentionDays. Those still referenced by other rows stay in the trash.
+func (usecase *actionUsecase) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	deletedBefore, err := utils.RetentionCutoff(retentionDays)
+	if err != nil {
+		return dto.Purged{}, err
+	}
+	return usecase.actionRepo.PurgeTrash(ctx, deletedBefore)
+}
@@ -1,9 +1,17 @@
 package actionUsecase
 
 import (
+	"avengers-clinic/model/dto"
 	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/src/action"
+	"context"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -15,51 +23,76 @@ type mockActionRepository struct {
 	mock.Mock
 }
 
-func (mock *mockActionRepository) GetAll() ([]actionDto.Action, error) {
-	args := mock.Called()	
-	return args.Get(0).([]actionDto.Action), args.Error(1)
+func (mock *mockActionRepository) GetAll(ctx context.Context, query queryDto.Query) ([]actionDto.Action, int, error) {
+	args := mock.Called(query)
+	return args.Get(0).([]actionDto.Action), args.Int(1), args.Error(2)
 }
 
-func (mock *mockActionRepository) GetByID(actionID string) (actionDto.Action, error) {
+func (mock *mockActionRepository) GetByID(ctx context.Context, actionID string) (actionDto.Action, error) {
 	args := mock.Called(actionID)
 	return args.Get(0).(actionDto.Action), args.Error(1)
 }
 
-func (mock *mockActionRepository) GetTrashByID(actionID string) (actionDto.Action, error) {
+func (mock *mockActionRepository) GetTrashByID(ctx context.Context, actionID string) (actionDto.Action, error) {
 	args := mock.Called(actionID)
 	return args.Get(0).(actionDto.Action), args.Error(1)
 }
 
-func (mock *mockActionRepository) Insert(action actionDto.Action) (string, error) {
+func (mock *mockActionRepository) GetByName(ctx context.Context, name string) (actionDto.Action, error) {
+	args := mock.Called(name)
+	return args.Get(0).(actionDto.Action), args.Error(1)
+}
+
+func (mock *mockActionRepository) Insert(ctx context.Context, action actionDto.Action) (string, error) {
 	args := mock.Called(action)
 	return args.String(0), args.Error(1)
 }
 
-func (mock *mockActionRepository)  Update(action actionDto.Action) error {
+func (mock *mockActionRepository)  Update(ctx context.Context, action actionDto.Action) (int, error) {
 	args := mock.Called(action)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
 
-func (mock *mockActionRepository) Delete(actionID string) error {
-	args := mock.Called(actionID)
+func (mock *mockActionRepository) Delete(ctx context.Context, actionID string, version int) error {
+	args := mock.Called(actionID, version)
 	return args.Error(0)
 }
 
-func (mock *mockActionRepository) SoftDelete(actionID string) error {
-	args := mock.Called(actionID)
+func (mock *mockActionRepository) SoftDelete(ctx context.Context, actionID string, version int) error {
+	args := mock.Called(actionID, version)
 	return args.Error(0)
 }
 
-func (mock *mockActionRepository) Restore(actionID string) error {
-	args := mock.Called(actionID)
+func (mock *mockActionRepository) Restore(ctx context.Context, actionID string, version int) error {
+	args := mock.Called(actionID, version)
 	return args.Error(0)
 }
 
-func (mock *mockActionRepository) IsNameExist(name string) bool {
+func (mock *mockActionRepository) IsNameExist(ctx context.Context, name string) bool {
 	args := mock.Called(name)
 	return args.Bool(0)
 }
 
+func (mock *mockActionRepository) GetPrices(ctx context.Context, actionID string) ([]priceDto.Price, error) {
+	args := mock.Called(actionID)
+	return args.Get(0).([]priceDto.Price), args.Error(1)
+}
+
+func (mock *mockActionRepository) InsertPrice(ctx context.Context, req priceDto.PriceRequest) (string, error) {
+	args := mock.Called(req)
+	return args.String(0), args.Error(1)
+}
+
+func (mock *mockActionRepository) DeletePrice(ctx context.Context, actionID, priceID, now string, version int) error {
+	args := mock.Called(actionID, priceID, now, version)
+	return args.Error(0)
+}
+
+func (mock *mockActionRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	args := mock.Called(deletedBefore)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
 type actionUsecaseTestSuite struct {
 	suite.Suite
 	actionRepo *mockActionRepository
@@ -76,11 +109,13 @@ func (suite *actionUsecaseTestSuite) TestGetAllSuccess() {
 		{ID: "1", Name: "Konsultasi", Price: 20000, CreatedAt: "2024-03-12T15:04:05Z", UpdatedAt: "2024-03-12T15:04:05Z"},
 	}
 
-	suite.actionRepo.On("GetAll").Return(expected, nil)
-	actual, err := suite.actionUC.GetAll()
+	query := queryDto.Query{Page: 1, Size: 10}
+	suite.actionRepo.On("GetAll", query).Return(expected, 1, nil)
+	actual, paging, err := suite.actionUC.GetAll(context.Background(), query)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
+	suite.Equal(queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, paging)
 }
 
 // Start Get By ID
@@ -88,7 +123,7 @@ func (suite *actionUsecaseTestSuite) TestGetByIDSuccess() {
 	expected := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000, CreatedAt: "2024-03-12T15:04:05Z", UpdatedAt: "2024-03-12T15:04:05Z"}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(expected, nil)
-	actual, err := suite.actionUC.GetByID(expected.ID)
+	actual, err := suite.actionUC.GetByID(context.Background(), expected.ID)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
@@ -98,7 +133,7 @@ func (suite *actionUsecaseTestSuite) TestGetByIDError() {
 	expected := actionDto.Action{}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(expected, sql.ErrConnDone)
-	actual, err := suite.actionUC.GetByID(expected.ID)
+	actual, err := suite.actionUC.GetByID(context.Background(), expected.ID)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
@@ -113,7 +148,7 @@ func (suite *actionUsecaseTestSuite) TestCreateSuccess() {
 
 	suite.actionRepo.On("IsNameExist", mock.Anything).Return(false)
 	suite.actionRepo.On("Insert", mock.Anything).Return(expected.ID, nil)
-	actual, err := suite.actionUC.Create(request)
+	actual, err := suite.actionUC.Create(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
@@ -124,7 +159,7 @@ func (suite *actionUsecaseTestSuite) TestCreateErrorActionExist() {
 	request := actionDto.CreateRequest{Name: "Konsultasi", Price: 20000}
 
 	suite.actionRepo.On("IsNameExist", mock.Anything).Return(true)
-	actual, err := suite.actionUC.Create(request)
+	actual, err := suite.actionUC.Create(context.Background(), request)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
@@ -136,7 +171,7 @@ func (suite *actionUsecaseTestSuite) TestCreateInternalServerError() {
 
 	suite.actionRepo.On("IsNameExist", mock.Anything).Return(false)
 	suite.actionRepo.On("Insert", mock.Anything).Return("", sql.ErrConnDone)
-	actual, err := suite.actionUC.Create(request)
+	actual, err := suite.actionUC.Create(context.Background(), request)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
@@ -146,16 +181,16 @@ func (suite *actionUsecaseTestSuite) TestCreateInternalServerError() {
 // Start Update
 func (suite *actionUsecaseTestSuite) TestUpdateSuccess() {
 	now := time.Now().Format("2006-01-02 15:04:05")
-	expected := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000, CreatedAt: now, UpdatedAt: now}
-	request := actionDto.UpdateRequest{ID: "1",Name: "Konsultasi Dokter"}
+	expected := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000, CreatedAt: now, UpdatedAt: now, Version: 1}
+	request := actionDto.UpdateRequest{ID: "1",Name: "Konsultasi Dokter", Version: 1}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(expected, nil)
 	suite.actionRepo.On("IsNameExist", mock.Anything).Return(false)
 	
-	expected.Name = request.Name
-	suite.actionRepo.On("Update", mock.Anything).Return(nil)
+	expected.Name, expected.Version = request.Name, 2
+	suite.actionRepo.On("Update", mock.Anything).Return(2, nil)
 
-	actual, err := suite.actionUC.Update(request)
+	actual, err := suite.actionUC.Update(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
@@ -167,7 +202,7 @@ func (suite *actionUsecaseTestSuite) TestUpdateNotFound() {
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(expected, sql.ErrNoRows)
 
-	actual, err := suite.actionUC.Update(request)
+	actual, err := suite.actionUC.Update(context.Background(), request)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
@@ -180,7 +215,7 @@ func (suite *actionUsecaseTestSuite) TestUpdateErrorActionExist() {
 	suite.actionRepo.On("GetByID", mock.Anything).Return(expected, nil)
 	suite.actionRepo.On("IsNameExist", mock.Anything).Return(true)
 
-	actual, err := suite.actionUC.Update(request)
+	actual, err := suite.actionUC.Update(context.Background(), request)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
@@ -192,23 +227,84 @@ func (suite *actionUsecaseTestSuite) TestUpdateInternalServerError() {
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(expected, nil)
 	suite.actionRepo.On("IsNameExist", mock.Anything).Return(false)
-	suite.actionRepo.On("Update", mock.Anything).Return(sql.ErrConnDone)
+	suite.actionRepo.On("Update", mock.Anything).Return(0, sql.ErrConnDone)
 
-	actual, err := suite.actionUC.Update(request)
+	actual, err := suite.actionUC.Update(context.Background(), request)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
 }
+
+func (suite *actionUsecaseTestSuite) TestUpdatePassesUpdater() {
+	action := actionDto.Action{ID: "1", Name: "Konsultasi", Price: 20000}
+	request := actionDto.UpdateRequest{ID: "1", Price: 25000, UpdatedBy: "u1"}
+
+	suite.actionRepo.On("GetByID", "1").Return(action, nil)
+	suite.actionRepo.On("Update", mock.MatchedBy(func(action actionDto.Action) bool {
+		return action.Price == 25000 && action.UpdatedBy == "u1"
+	})).Return(2, nil)
+
+	actual, err := suite.actionUC.Update(context.Background(), request)
+
+	suite.Nil(err)
+	suite.Equal(25000, actual.Price)
+}
+
+func (suite *actionUsecaseTestSuite) TestUpdateVersionConflict() {
+	suite.actionRepo.On("GetByID", "1").Return(actionDto.Action{ID: "1", Name: "Konsultasi", Version: 3}, nil)
+
+	_, err := suite.actionUC.Update(context.Background(), actionDto.UpdateRequest{ID: "1", Price: 25000, Version: 2})
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.actionRepo.AssertNotCalled(suite.T(), "Update", mock.Anything)
+}
 // End Update
 
+// Start Price
+func (suite *actionUsecaseTestSuite) TestSchedulePriceSuccess() {
+	prices := []priceDto.Price{
+		{ID: "p2", Price: 25000, EffectiveFrom: "2999-01-01 00:00:00"},
+		{ID: "p1", Price: 20000, EffectiveFrom: "2024-01-01 00:00:00"},
+	}
+	request := priceDto.PriceRequest{ItemID: "1", Price: 25000, EffectiveFrom: "2999-01-01 00:00:00", CreatedBy: "u1", Version: 2}
+
+	suite.actionRepo.On("GetByID", "1").Return(actionDto.Action{ID: "1", Version: 2}, nil)
+	suite.actionRepo.On("InsertPrice", request).Return("p2", nil)
+	suite.actionRepo.On("GetPrices", "1").Return(prices, nil)
+
+	actual, err := suite.actionUC.SchedulePrice(context.Background(), request)
+
+	suite.Nil(err)
+	suite.Equal(constants.PriceScheduled, actual[0].Status)
+	suite.Equal(constants.PriceCurrent, actual[1].Status)
+}
+
+func (suite *actionUsecaseTestSuite) TestSchedulePriceNotFound() {
+	suite.actionRepo.On("GetByID", "1").Return(actionDto.Action{}, sql.ErrNoRows)
+
+	_, err := suite.actionUC.SchedulePrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 25000})
+
+	suite.Equal(sql.ErrNoRows, err)
+	suite.actionRepo.AssertNotCalled(suite.T(), "InsertPrice", mock.Anything)
+}
+
+func (suite *actionUsecaseTestSuite) TestCancelPrice() {
+	suite.actionRepo.On("DeletePrice", "1", "p2", mock.Anything, 2).Return(errors.New(constants.ErrPriceNotScheduled))
+
+	err := suite.actionUC.CancelPrice(context.Background(), "1", "p2", 2)
+
+	suite.EqualError(err, constants.ErrPriceNotScheduled)
+}
+// End Price
+
 // Start Delete
 func (suite *actionUsecaseTestSuite) TestDeleteSuccess()  {
 	actionID := "1"
 	action := actionDto.Action{ID: "1", Name: "Konsultasi"}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(action, nil)
-	suite.actionRepo.On("Delete", mock.Anything).Return(nil)
-	err := suite.actionUC.Delete(actionID)
+	suite.actionRepo.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	err := suite.actionUC.Delete(context.Background(), actionID, 0)
 
 	suite.Nil(err)
 }
@@ -218,7 +314,7 @@ func (suite *actionUsecaseTestSuite) TestDeleteErrorNotFound()  {
 	action := actionDto.Action{}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(action, sql.ErrNoRows)
-	err := suite.actionUC.Delete(actionID)
+	err := suite.actionUC.Delete(context.Background(), actionID, 0)
 
 	suite.Error(err)
 }
@@ -228,11 +324,20 @@ func (suite *actionUsecaseTestSuite) TestDeleteInternalServerError()  {
 	action := actionDto.Action{ID: "1", Name: "Konsultasi"}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(action, nil)
-	suite.actionRepo.On("Delete", mock.Anything).Return(sql.ErrConnDone)
-	err := suite.actionUC.Delete(actionID)
+	suite.actionRepo.On("Delete", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
+	err := suite.actionUC.Delete(context.Background(), actionID, 0)
 
 	suite.Error(err)
 }
+
+func (suite *actionUsecaseTestSuite) TestDeleteVersionConflict()  {
+	suite.actionRepo.On("GetByID", "1").Return(actionDto.Action{ID: "1", Version: 3}, nil)
+
+	err := suite.actionUC.Delete(context.Background(), "1", 2)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.actionRepo.AssertNotCalled(suite.T(), "Delete", mock.Anything, mock.Anything)
+}
 // End Delete
 
 // Start Soft Delete
@@ -241,8 +346,8 @@ func (suite *actionUsecaseTestSuite) TestSoftDeleteSuccess()  {
 	action := actionDto.Action{ID: "1", Name: "Konsultasi"}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(action, nil)
-	suite.actionRepo.On("SoftDelete", mock.Anything).Return(nil)
-	err := suite.actionUC.SoftDelete(actionID)
+	suite.actionRepo.On("SoftDelete", mock.Anything, mock.Anything).Return(nil)
+	_, err := suite.actionUC.SoftDelete(context.Background(), actionID, 0)
 
 	suite.Nil(err)
 }
@@ -252,7 +357,7 @@ func (suite *actionUsecaseTestSuite) TestSoftDeleteErrorNotFound()  {
 	action := actionDto.Action{}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(action, sql.ErrNoRows)
-	err := suite.actionUC.SoftDelete(actionID)
+	_, err := suite.actionUC.SoftDelete(context.Background(), actionID, 0)
 
 	suite.Error(err)
 }
@@ -262,11 +367,22 @@ func (suite *actionUsecaseTestSuite) TestSoftDeleteInternalServerError()  {
 	action := actionDto.Action{ID: "1", Name: "Konsultasi"}
 
 	suite.actionRepo.On("GetByID", mock.Anything).Return(action, nil)
-	suite.actionRepo.On("SoftDelete", mock.Anything).Return(sql.ErrConnDone)
-	err := suite.actionUC.SoftDelete(actionID)
+	suite.actionRepo.On("SoftDelete", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
+	_, err := suite.actionUC.SoftDelete(context.Background(), actionID, 0)
 
 	suite.Error(err)
 }
+
+func (suite *actionUsecaseTestSuite) TestSoftDeletePassesCurrentVersion()  {
+	suite.actionRepo.On("GetByID", "1").Return(actionDto.Action{ID: "1", Version: 3}, nil)
+	suite.actionRepo.On("SoftDelete", "1", 3).Return(nil)
+
+	version, err := suite.actionUC.SoftDelete(context.Background(), "1", 3)
+
+	suite.Nil(err)
+	suite.Equal(4, version)
+	suite.actionRepo.AssertExpectations(suite.T())
+}
 // End Soft Delete
 
 // Start Restore
@@ -275,8 +391,8 @@ func (suite *actionUsecaseTestSuite) TestRestoreSuccess()  {
 	action := actionDto.Action{ID: "1", Name: "Konsultasi"}
 
 	suite.actionRepo.On("GetTrashByID", mock.Anything).Return(action, nil)
-	suite.actionRepo.On("Restore", mock.Anything).Return(nil)
-	err := suite.actionUC.Restore(actionID)
+	suite.actionRepo.On("Restore", mock.Anything, mock.Anything).Return(nil)
+	err := suite.actionUC.Restore(context.Background(), actionID, 0)
 
 	suite.Nil(err)
 }
@@ -286,7 +402,7 @@ func (suite *actionUsecaseTestSuite) TestRestoreErrorNotFound()  {
 	action := actionDto.Action{}
 
 	suite.actionRepo.On("GetTrashByID", mock.Anything).Return(action, sql.ErrNoRows)
-	err := suite.actionUC.Restore(actionID)
+	err := suite.actionUC.Restore(context.Background(), actionID, 0)
 
 	suite.Error(err)
 }
@@ -296,13 +412,123 @@ func (suite *actionUsecaseTestSuite) TestRestoreInternalServerError()  {
 	action := actionDto.Action{ID: "1", Name: "Konsultasi"}
 
 	suite.actionRepo.On("GetTrashByID", mock.Anything).Return(action, nil)
-	suite.actionRepo.On("Restore", mock.Anything).Return(sql.ErrConnDone)
-	err := suite.actionUC.Restore(actionID)
+	suite.actionRepo.On("Restore", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
+	err := suite.actionUC.Restore(context.Background(), actionID, 0)
 
 	suite.Error(err)
 }
+
+func (suite *actionUsecaseTestSuite) TestRestoreVersionConflict()  {
+	suite.actionRepo.On("GetTrashByID", "1").Return(actionDto.Action{ID: "1", Version: 4}, nil)
+
+	err := suite.actionUC.Restore(context.Background(), "1", 3)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.actionRepo.AssertNotCalled(suite.T(), "Restore", mock.Anything, mock.Anything)
+}
 // End Restore
 
+// Start Purge Trash
+func (suite *actionUsecaseTestSuite) TestPurgeTrashSuccess() {
+	suite.actionRepo.On("PurgeTrash", mock.MatchedBy(func(deletedBefore string) bool {
+		return deletedBefore <= time.Now().AddDate(0, 0, -30).Format("2006-01-02 15:04:05")
+	})).Return(dto.Purged{Deleted: 2}, nil)
+
+	purged, err := suite.actionUC.PurgeTrash(context.Background(), 30)
+
+	suite.Nil(err)
+	suite.Equal(2, purged.Deleted)
+}
+
+func (suite *actionUsecaseTestSuite) TestPurgeTrashInvalidRetention() {
+	_, err := suite.actionUC.PurgeTrash(context.Background(), -1)
+
+	suite.EqualError(err, constants.ErrInvalidRetention)
+}
+// End Purge Trash
+
+// Start Import
+func (suite *actionUsecaseTestSuite) TestImportCreatesAndUpdates() {
+	records := []sheetDto.Record{
+		{Line: 2, Values: map[string]string{"name": "Konsultasi", "price": "25000"}},
+		{Line: 3, Values: map[string]string{"name": "Jahit Luka", "price": "150000", "description": "per luka"}},
+	}
+	existing := actionDto.Action{ID: "a1", Name: "Konsultasi", Price: 20000}
+
+	suite.actionRepo.On("GetByName", "Konsultasi").Return(existing, nil)
+	suite.actionRepo.On("GetByName", "Jahit Luka").Return(actionDto.Action{}, sql.ErrNoRows)
+	suite.actionRepo.On("IsNameExist", "Jahit Luka").Return(false)
+	suite.actionRepo.On("GetByID", "a1").Return(existing, nil)
+	suite.actionRepo.On("IsNameExist", "Konsultasi").Return(true)
+	suite.actionRepo.On("Update", mock.MatchedBy(func(action actionDto.Action) bool {
+		return action.ID == "a1" && action.Price == 25000 && action.UpdatedBy == "u1"
+	})).Return(2, nil)
+	suite.actionRepo.On("Insert", mock.MatchedBy(func(action actionDto.Action) bool {
+		return action.Name == "Jahit Luka" && action.Price == 150000 && action.Description == "per luka"
+	})).Return("a2", nil)
+
+	result, err := suite.actionUC.Import(context.Background(), records, "u1", false)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Created)
+	suite.Equal(1, result.Updated)
+	suite.Equal(sheetDto.ImportRow{Line: 2, Name: "Konsultasi", Action: constants.ImportUpdate, ID: "a1"}, result.Rows[0])
+	suite.Equal(sheetDto.ImportRow{Line: 3, Name: "Jahit Luka", Action: constants.ImportCreate, ID: "a2"}, result.Rows[1])
+	suite.actionRepo.AssertExpectations(suite.T())
+}
+
+func (suite *actionUsecaseTestSuite) TestImportNameInTrash() {
+	records := []sheetDto.Record{{Line: 2, Values: map[string]string{"name": "Rontgen", "price": "300000"}}}
+	suite.actionRepo.On("GetByName", "Rontgen").Return(actionDto.Action{}, sql.ErrNoRows)
+	suite.actionRepo.On("IsNameExist", "Rontgen").Return(true)
+
+	result, err := suite.actionUC.Import(context.Background(), records, "u1", false)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Failed)
+	suite.Equal([]json.ValidationField{{FieldName: "name", Message: constants.ErrActionNameInTrash}}, result.Rows[0].Errors)
+	suite.actionRepo.AssertNotCalled(suite.T(), "Insert", mock.Anything)
+}
+
+func (suite *actionUsecaseTestSuite) TestImportInvalidRowWritesNothing() {
+	records := []sheetDto.Record{
+		{Line: 2, Values: map[string]string{"name": "Konsultasi", "price": "25000"}},
+		{Line: 3, Values: map[string]string{"name": "", "price": "dua"}},
+	}
+	suite.actionRepo.On("GetByName", "Konsultasi").Return(actionDto.Action{}, sql.ErrNoRows)
+	suite.actionRepo.On("IsNameExist", "Konsultasi").Return(false)
+
+	result, err := suite.actionUC.Import(context.Background(), records, "u1", false)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Failed)
+	suite.Contains(result.Rows[1].Errors, json.ValidationField{FieldName: "price", Message: constants.ErrSheetNumber})
+	suite.actionRepo.AssertNotCalled(suite.T(), "Insert", mock.Anything)
+}
+
+func (suite *actionUsecaseTestSuite) TestImportDryRun() {
+	records := []sheetDto.Record{{Line: 2, Values: map[string]string{"name": "Konsultasi", "price": "25000"}}}
+	suite.actionRepo.On("GetByName", "Konsultasi").Return(actionDto.Action{ID: "a1", Name: "Konsultasi"}, nil)
+
+	result, err := suite.actionUC.Import(context.Background(), records, "u1", true)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Updated)
+	suite.actionRepo.AssertNotCalled(suite.T(), "Update", mock.Anything)
+}
+// End Import
+
+func (suite *actionUsecaseTestSuite) TestExportEveryPage() {
+	actions := []actionDto.Action{{Name: "Konsultasi", Price: 20000, Description: "dokter umum"}}
+	suite.actionRepo.On("GetAll", queryDto.Query{Page: 1}).Return(actions, 1, nil)
+
+	file, err := suite.actionUC.Export(context.Background(), queryDto.Query{Page: 2, Size: 10}, "csv")
+
+	suite.Nil(err)
+	suite.Equal("actions.csv", file.FileName)
+	suite.Equal("name,price,description\nKonsultasi,20000,dokter umum\n", string(file.Content))
+}
+
 func TestActionUsecaseTestSuite(t *testing.T) {
 	suite.Run(t, new(actionUsecaseTestSuite))
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package actionUsecase
+
+import (
+	"avengers-clinic/model/dto/actionDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"context"
+	"database/sql"
+)
+
+// sheetColumns are the columns of an exported catalogue, which can be
+// imported again as it is.
+var sheetColumns = []string{"name", "price", "description"}
+
+// Import creates the actions of the records and updates the ones whose name
+// is already in the catalogue. Every record is checked first and nothing is
+// written when one of them fails, or on a dry run.
+func (usecase *actionUsecase) Import(ctx context.Context, records []sheetDto.Record, updatedBy string, dryRun bool) (sheetDto.ImportResult, error) {
+	result := sheetDto.ImportResult{DryRun: dryRun}
+	requests := make([]actionDto.CreateRequest, len(records))
+	names := map[string]bool{}
+	for i, record := range records {
+		price, err := utils.SheetInt(record, "price")
+		requests[i] = actionDto.CreateRequest{Name: record.Values["name"], Price: price}
+		if description := record.Values["description"]; description != "" {
+			requests[i].Description = description
+		}
+		row := sheetDto.ImportRow{Line: record.Line, Name: requests[i].Name, Action: constants.ImportCreate}
+
+		if err != nil {
+			row.Errors = append(row.Errors, json.ValidationField{FieldName: "price", Message: err.Error()})
+		}
+		row.Errors = append(row.Errors, utils.Validated(requests[i])...)
+		if names[row.Name] {
+			row.Errors = append(row.Errors, json.ValidationField{FieldName: "name", Message: constants.ErrImportDuplicateName})
+		}
+		names[row.Name] = true
+
+		if len(row.Errors) == 0 {
+			existing, err := usecase.actionRepo.GetByName(ctx, row.Name)
+			switch {
+			case err == nil:
+				row.Action, row.ID = constants.ImportUpdate, existing.ID
+			case err != sql.ErrNoRows:
+				return sheetDto.ImportResult{}, err
+			case usecase.actionRepo.IsNameExist(ctx, row.Name):
+				// names stay taken while the action is in the trash
+				row.Errors = append(row.Errors, json.ValidationField{FieldName: "name", Message: constants.ErrActionNameInTrash})
+			}
+		}
+
+		switch {
+		case len(row.Errors) > 0:
+			row.Action = ""
+			result.Failed++
+		case row.Action == constants.ImportUpdate:
+			result.Updated++
+		default:
+			result.Created++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if result.Failed > 0 || dryRun {
+		return result, nil
+	}
+
+	for i, row := range result.Rows {
+		req := requests[i]
+		if row.Action == constants.ImportUpdate {
+			update := actionDto.UpdateRequest{ID: row.ID, Name: req.Name, Price: req.Price, UpdatedBy: updatedBy}
+			update.Description, _ = req.Description.(string)
+			if _, err := usecase.Update(ctx, update); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		created, err := usecase.Create(ctx, req)
+		if err != nil {
+			return result, err
+		}
+		result.Rows[i].ID = created.ID
+	}
+	return result, nil
+}
+
+// Export writes the actions of the query, on every page, as a CSV or XLSX
+// file.
+func (usecase *actionUsecase) Export(ctx context.Context, query queryDto.Query, format string) (sheetDto.File, error) {
+	query.Page, query.Size = 1, 0
+	actions, _, err := usecase.actionRepo.GetAll(ctx, query)
+	if err != nil {
+		return sheetDto.File{}, err
+	}
+
+	rows := make([][]interface{}, len(actions))
+	for i, action := range actions {
+		description := action.Description
+		if description == nil {
+			description = ""
+		}
+		rows[i] = []interface{}{action.Name, action.Price, description}
+	}
+	return utils.WriteSheet("actions", format, sheetColumns, rows)
+}
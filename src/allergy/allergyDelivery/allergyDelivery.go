@@ -0,0 +1,101 @@
+package allergyDelivery
+
+import (
+	"avengers-clinic/model/dto/allergyDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/allergy"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+type allergyDelivery struct {
+	allergyUC allergy.AllergyUsecase
+}
+
+func NewAllergyDelivery(v1Group *gin.RouterGroup, allergyUC allergy.AllergyUsecase) {
+	handler := allergyDelivery{allergyUC}
+
+	allergyGroup := v1Group.Group("/allergies")
+	{
+		allergyGroup.GET("/patient/:patient-id", middleware.JwtAuth("ADMIN", "DOCTOR", "PATIENT"), handler.GetByPatientID)
+		allergyGroup.POST("", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.Create)
+		allergyGroup.DELETE("/:id", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.Delete)
+		allergyGroup.POST("/check", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.CheckPrescription)
+	}
+}
+
+func (delivery *allergyDelivery) GetByPatientID(c *gin.Context) {
+	allergies, err := delivery.allergyUC.GetByPatientID(c.Request.Context(), c.Param("patient-id"))
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.AllergyService, "01")
+		return
+	}
+
+	json.NewResponseSuccess(c, allergies, "Allergies retrieved successfully", constants.AllergyService, "01")
+}
+
+func (delivery *allergyDelivery) Create(c *gin.Context) {
+	var request allergyDto.CreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.AllergyService, "01")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.AllergyService, "02")
+		return
+	}
+
+	response, err := delivery.allergyUC.Create(c.Request.Context(), request)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.AllergyService, "03")
+		return
+	}
+
+	json.NewResponseCreated(c, response, "Allergy created successfully", constants.AllergyService, "01")
+}
+
+func (delivery *allergyDelivery) Delete(c *gin.Context) {
+	err := delivery.allergyUC.Delete(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Allergy not found", constants.AllergyService, "01")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.AllergyService, "02")
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Allergy deleted successfully", constants.AllergyService, "01")
+}
+
+func (delivery *allergyDelivery) CheckPrescription(c *gin.Context) {
+	var request allergyDto.CheckRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.AllergyService, "01")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.AllergyService, "02")
+		return
+	}
+
+	result, err := delivery.allergyUC.CheckPrescription(c.Request.Context(), request)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Booking not found", constants.AllergyService, "03")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.AllergyService, "04")
+		return
+	}
+
+	json.NewResponseSuccess(c, result, "Prescription checked successfully", constants.AllergyService, "01")
+}
@@ -0,0 +1,25 @@
+package allergy
+
+import (
+	"avengers-clinic/model/dto/allergyDto"
+	"context"
+)
+
+type AllergyRepository interface {
+	GetByPatientID(ctx context.Context, patientID string) ([]allergyDto.Allergy, error)
+	GetByID(ctx context.Context, allergyID string) (allergyDto.Allergy, error)
+	Insert(ctx context.Context, allergy allergyDto.Allergy) (string, error)
+	SoftDelete(ctx context.Context, allergyID string) error
+	GetPatientIDByBookingID(ctx context.Context, bookingID string) (string, error)
+	GetMedicinesByIDs(ctx context.Context, medicineIDs []string) ([]allergyDto.Medicine, error)
+	InsertOverrides(ctx context.Context, medicalRecordID, acknowledgedBy string, acks []allergyDto.Acknowledgement) error
+}
+
+type AllergyUsecase interface {
+	GetByPatientID(ctx context.Context, patientID string) ([]allergyDto.Allergy, error)
+	Create(ctx context.Context, req allergyDto.CreateRequest) (allergyDto.Allergy, error)
+	Delete(ctx context.Context, allergyID string) error
+	CheckPrescription(ctx context.Context, req allergyDto.CheckRequest) (allergyDto.CheckResult, error)
+	ValidatePrescription(ctx context.Context, req allergyDto.CheckRequest, acks []allergyDto.Acknowledgement) error
+	SaveOverrides(ctx context.Context, medicalRecordID, acknowledgedBy string, req allergyDto.CheckRequest, acks []allergyDto.Acknowledgement) error
+}
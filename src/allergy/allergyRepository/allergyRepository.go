@@ -0,0 +1,133 @@
+package allergyRepository
+
+import (
+	"avengers-clinic/model/dto/allergyDto"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/allergy"
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type allergyRepository struct {
+	db *sql.DB
+}
+
+func NewAllergyRepository(db *sql.DB) allergy.AllergyRepository {
+	return &allergyRepository{db}
+}
+
+func (repository *allergyRepository) GetByPatientID(ctx context.Context, patientID string) ([]allergyDto.Allergy, error) {
+	query := `
+		SELECT id, patient_id, allergen, reaction, severity, created_at, updated_at
+		FROM patient_allergies WHERE patient_id = $1 AND deleted_at IS NULL ORDER BY created_at;
+	`
+	rows, err := repository.db.QueryContext(ctx, query, patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allergies []allergyDto.Allergy
+	for rows.Next() {
+		var allergy allergyDto.Allergy
+		err := rows.Scan(
+			&allergy.ID,
+			&allergy.PatientID,
+			&allergy.Allergen,
+			&allergy.Reaction,
+			&allergy.Severity,
+			&allergy.CreatedAt,
+			&allergy.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		allergies = append(allergies, allergy)
+	}
+	return allergies, nil
+}
+
+func (repository *allergyRepository) GetByID(ctx context.Context, allergyID string) (allergyDto.Allergy, error) {
+	query := `
+		SELECT id, patient_id, allergen, reaction, severity, created_at, updated_at
+		FROM patient_allergies WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
+	`
+	var allergy allergyDto.Allergy
+	err := repository.db.QueryRowContext(ctx, query, allergyID).Scan(
+		&allergy.ID,
+		&allergy.PatientID,
+		&allergy.Allergen,
+		&allergy.Reaction,
+		&allergy.Severity,
+		&allergy.CreatedAt,
+		&allergy.UpdatedAt,
+	)
+	return allergy, err
+}
+
+func (repository *allergyRepository) Insert(ctx context.Context, allergy allergyDto.Allergy) (string, error) {
+	query := `
+		INSERT INTO patient_allergies (patient_id, allergen, reaction, severity, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;
+	`
+	err := repository.db.QueryRowContext(ctx,
+		query,
+		allergy.PatientID,
+		allergy.Allergen,
+		allergy.Reaction,
+		allergy.Severity,
+		allergy.CreatedAt,
+		allergy.UpdatedAt,
+	).Scan(&allergy.ID)
+	return allergy.ID, err
+}
+
+func (repository *allergyRepository) SoftDelete(ctx context.Context, allergyID string) error {
+	query := "UPDATE patient_allergies SET updated_at = CURRENT_TIMESTAMP, deleted_at = CURRENT_TIMESTAMP WHERE id = $1;"
+	_, err := repository.db.ExecContext(ctx, query, allergyID)
+	return err
+}
+
+func (repository *allergyRepository) GetPatientIDByBookingID(ctx context.Context, bookingID string) (string, error) {
+	var patientID string
+	query := "SELECT patient_id FROM bookings WHERE id = $1 AND deleted_at IS NULL;"
+	err := repository.db.QueryRowContext(ctx, query, bookingID).Scan(&patientID)
+	return patientID, err
+}
+
+func (repository *allergyRepository) GetMedicinesByIDs(ctx context.Context, medicineIDs []string) ([]allergyDto.Medicine, error) {
+	query := "SELECT id, name FROM medicines WHERE id = ANY($1);"
+	rows, err := repository.db.QueryContext(ctx, query, pq.Array(medicineIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var medicines []allergyDto.Medicine
+	for rows.Next() {
+		var medicine allergyDto.Medicine
+		if err := rows.Scan(&medicine.ID, &medicine.Name); err != nil {
+			return nil, err
+		}
+		medicines = append(medicines, medicine)
+	}
+	return medicines, nil
+}
+
+func (repository *allergyRepository) InsertOverrides(ctx context.Context, medicalRecordID, acknowledgedBy string, acks []allergyDto.Acknowledgement) error {
+	query := `
+		INSERT INTO medical_record_prescription_overrides (medical_record_id, warning_code, reason, acknowledged_by)
+		VALUES ($1, $2, $3, $4);
+	`
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+		for _, ack := range acks {
+			if _, err := tx.ExecContext(ctx, query, medicalRecordID, ack.WarningCode, ack.Reason, acknowledgedBy); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+package allergyRepository
+
+import (
+	"avengers-clinic/model/dto/allergyDto"
+	"avengers-clinic/src/allergy"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type allergyRepositoryTestSuite struct {
+	suite.Suite
+	allergyRepo allergy.AllergyRepository
+	mock        sqlmock.Sqlmock
+}
+
+func (suite *allergyRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.allergyRepo = NewAllergyRepository(db)
+	suite.mock = mock
+}
+
+func (suite *allergyRepositoryTestSuite) TestGetByPatientIDSuccess() {
+	rows := sqlmock.NewRows([]string{"id", "patient_id", "allergen", "reaction", "severity", "created_at", "updated_at"}).
+		AddRow("1", "p1", "amoxicillin", "rash", "SEVERE", "2024-03-12 16:06", "2024-03-12 16:06")
+
+	suite.mock.ExpectQuery("SELECT (.+) FROM patient_allergies").
+		WithArgs("p1").
+		WillReturnRows(rows)
+
+	actual, err := suite.allergyRepo.GetByPatientID(context.Background(), "p1")
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+}
+
+func (suite *allergyRepositoryTestSuite) TestInsertSuccess() {
+	suite.mock.ExpectQuery("INSERT INTO patient_allergies").
+		WithArgs("p1", "amoxicillin", nil, "SEVERE", "2024-03-12 16:06", "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+
+	actual, err := suite.allergyRepo.Insert(context.Background(), allergyDto.Allergy{
+		PatientID: "p1",
+		Allergen:  "amoxicillin",
+		Severity:  "SEVERE",
+		CreatedAt: "2024-03-12 16:06",
+		UpdatedAt: "2024-03-12 16:06",
+	})
+
+	suite.Nil(err)
+	suite.Equal("1", actual)
+}
+
+func (suite *allergyRepositoryTestSuite) TestGetPatientIDByBookingIDError() {
+	suite.mock.ExpectQuery("SELECT patient_id FROM bookings").
+		WithArgs("b1").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := suite.allergyRepo.GetPatientIDByBookingID(context.Background(), "b1")
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *allergyRepositoryTestSuite) TestInsertOverridesRollback() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("INSERT INTO medical_record_prescription_overrides").
+		WithArgs("mr1", "ALLERGY:m1:a1", "benefit outweighs risk", "d1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectExec("INSERT INTO medical_record_prescription_overrides").
+		WillReturnError(sql.ErrConnDone)
+	suite.mock.ExpectRollback()
+
+	err := suite.allergyRepo.InsertOverrides(context.Background(), "mr1", "d1", []allergyDto.Acknowledgement{
+		{WarningCode: "ALLERGY:m1:a1", Reason: "benefit outweighs risk"},
+		{WarningCode: "INTERACTION:m1:m2:DDI-0001", Reason: "monitored"},
+	})
+
+	suite.Error(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func TestAllergyRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(allergyRepositoryTestSuite))
+}
@@ -0,0 +1,192 @@
+package allergyUsecase
+
+import (
+	"avengers-clinic/model/dto/allergyDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/interaction"
+	"avengers-clinic/src/allergy"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	kindAllergy     = "ALLERGY"
+	kindInteraction = "INTERACTION"
+)
+
+type allergyUsecase struct {
+	allergyRepo   allergy.AllergyRepository
+	knowledgeBase *interaction.KnowledgeBase
+}
+
+func NewAllergyUsecase(allergyRepo allergy.AllergyRepository, knowledgeBase *interaction.KnowledgeBase) allergy.AllergyUsecase {
+	return &allergyUsecase{allergyRepo, knowledgeBase}
+}
+
+func (usecase *allergyUsecase) GetByPatientID(ctx context.Context, patientID string) ([]allergyDto.Allergy, error) {
+	allergies, err := usecase.allergyRepo.GetByPatientID(ctx, patientID)
+	return allergies, err
+}
+
+func (usecase *allergyUsecase) Create(ctx context.Context, req allergyDto.CreateRequest) (allergyDto.Allergy, error) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	allergy := allergyDto.Allergy{
+		PatientID: req.PatientID,
+		Allergen:  strings.TrimSpace(req.Allergen),
+		Reaction:  req.Reaction,
+		Severity:  req.Severity,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	var err error
+	allergy.ID, err = usecase.allergyRepo.Insert(ctx, allergy)
+	if err != nil {
+		return allergyDto.Allergy{}, err
+	}
+	return allergy, nil
+}
+
+func (usecase *allergyUsecase) Delete(ctx context.Context, allergyID string) error {
+	_, err := usecase.allergyRepo.GetByID(ctx, allergyID)
+	if err != nil {
+		return err
+	}
+	return usecase.allergyRepo.SoftDelete(ctx, allergyID)
+}
+
+func (usecase *allergyUsecase) CheckPrescription(ctx context.Context, req allergyDto.CheckRequest) (allergyDto.CheckResult, error) {
+	result := allergyDto.CheckResult{Errors: []allergyDto.Issue{}, Warnings: []allergyDto.Issue{}}
+	if len(req.MedicineIDs) == 0 {
+		return result, nil
+	}
+
+	patientID, err := usecase.allergyRepo.GetPatientIDByBookingID(ctx, req.BookingID)
+	if err != nil {
+		return result, err
+	}
+
+	allergies, err := usecase.allergyRepo.GetByPatientID(ctx, patientID)
+	if err != nil {
+		return result, err
+	}
+
+	medicines, err := usecase.allergyRepo.GetMedicinesByIDs(ctx, req.MedicineIDs)
+	if err != nil {
+		return result, err
+	}
+
+	var issues []allergyDto.Issue
+	for _, medicine := range medicines {
+		for _, a := range allergies {
+			if !usecase.knowledgeBase.Matches(medicine.Name, a.Allergen) {
+				continue
+			}
+			issues = append(issues, allergyDto.Issue{
+				Code:        fmt.Sprintf("%s:%s:%s", kindAllergy, medicine.ID, a.ID),
+				Kind:        kindAllergy,
+				Severity:    a.Severity,
+				MedicineIDs: []string{medicine.ID},
+				Message:     fmt.Sprintf("patient has a %s allergy to %s", strings.ToLower(a.Severity), a.Allergen),
+			})
+		}
+	}
+
+	for i := 0; i < len(medicines); i++ {
+		for j := i + 1; j < len(medicines); j++ {
+			ids := []string{medicines[i].ID, medicines[j].ID}
+			sort.Strings(ids)
+			for _, rule := range usecase.knowledgeBase.Interactions(medicines[i].Name, medicines[j].Name) {
+				message := rule.Description
+				if message == "" {
+					message = fmt.Sprintf("%s interacts with %s", medicines[i].Name, medicines[j].Name)
+				}
+				issues = append(issues, allergyDto.Issue{
+					Code:        fmt.Sprintf("%s:%s:%s:%s", kindInteraction, ids[0], ids[1], rule.ID),
+					Kind:        kindInteraction,
+					Severity:    rule.Severity,
+					MedicineIDs: ids,
+					Message:     message,
+				})
+			}
+		}
+	}
+
+	for _, issue := range issues {
+		if isBlocking(issue) {
+			result.Errors = append(result.Errors, issue)
+		} else {
+			result.Warnings = append(result.Warnings, issue)
+		}
+	}
+	return result, nil
+}
+
+func (usecase *allergyUsecase) ValidatePrescription(ctx context.Context, req allergyDto.CheckRequest, acks []allergyDto.Acknowledgement) error {
+	result, err := usecase.CheckPrescription(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 {
+		return allergyDto.PrescriptionError{Message: constants.ErrPrescriptionBlocked, Issues: result.Errors}
+	}
+
+	current := map[string]bool{}
+	for _, warning := range result.Warnings {
+		current[warning.Code] = true
+	}
+
+	var unknown []allergyDto.Issue
+	for _, ack := range acks {
+		if !current[ack.WarningCode] {
+			unknown = append(unknown, allergyDto.Issue{Code: ack.WarningCode, Message: constants.ErrUnknownAcknowledgement})
+		}
+	}
+	if len(unknown) > 0 {
+		return allergyDto.PrescriptionError{Message: constants.ErrUnknownAcknowledgement, Issues: unknown}
+	}
+
+	acknowledged := map[string]bool{}
+	for _, ack := range acks {
+		if strings.TrimSpace(ack.Reason) != "" {
+			acknowledged[ack.WarningCode] = true
+		}
+	}
+
+	var pending []allergyDto.Issue
+	for _, warning := range result.Warnings {
+		if !acknowledged[warning.Code] {
+			pending = append(pending, warning)
+		}
+	}
+
+	if len(pending) > 0 {
+		return allergyDto.PrescriptionError{Message: constants.ErrPrescriptionNeedsAck, Issues: pending}
+	}
+	return nil
+}
+
+// SaveOverrides keeps the acknowledgements of the prescription of a medical
+// record. They are checked against its warnings again, every warning must be
+// acknowledged and nothing else.
+func (usecase *allergyUsecase) SaveOverrides(ctx context.Context, medicalRecordID, acknowledgedBy string, req allergyDto.CheckRequest, acks []allergyDto.Acknowledgement) error {
+	if err := usecase.ValidatePrescription(ctx, req, acks); err != nil {
+		return err
+	}
+
+	if len(acks) == 0 {
+		return nil
+	}
+	return usecase.allergyRepo.InsertOverrides(ctx, medicalRecordID, acknowledgedBy, acks)
+}
+
+func isBlocking(issue allergyDto.Issue) bool {
+	if issue.Kind == kindAllergy {
+		return issue.Severity == "SEVERE"
+	}
+	return interaction.IsBlocking(issue.Severity)
+}
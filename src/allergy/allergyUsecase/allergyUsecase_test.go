@@ -0,0 +1,209 @@
+package allergyUsecase
+
+import (
+	"avengers-clinic/model/dto/allergyDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/interaction"
+	"avengers-clinic/src/allergy"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockAllergyRepository struct {
+	mock.Mock
+}
+
+func (m *mockAllergyRepository) GetByPatientID(ctx context.Context, patientID string) ([]allergyDto.Allergy, error) {
+	args := m.Called(patientID)
+	return args.Get(0).([]allergyDto.Allergy), args.Error(1)
+}
+
+func (m *mockAllergyRepository) GetByID(ctx context.Context, allergyID string) (allergyDto.Allergy, error) {
+	args := m.Called(allergyID)
+	return args.Get(0).(allergyDto.Allergy), args.Error(1)
+}
+
+func (m *mockAllergyRepository) Insert(ctx context.Context, allergy allergyDto.Allergy) (string, error) {
+	args := m.Called(allergy)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockAllergyRepository) SoftDelete(ctx context.Context, allergyID string) error {
+	args := m.Called(allergyID)
+	return args.Error(0)
+}
+
+func (m *mockAllergyRepository) GetPatientIDByBookingID(ctx context.Context, bookingID string) (string, error) {
+	args := m.Called(bookingID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockAllergyRepository) GetMedicinesByIDs(ctx context.Context, medicineIDs []string) ([]allergyDto.Medicine, error) {
+	args := m.Called(medicineIDs)
+	return args.Get(0).([]allergyDto.Medicine), args.Error(1)
+}
+
+func (m *mockAllergyRepository) InsertOverrides(ctx context.Context, medicalRecordID, acknowledgedBy string, acks []allergyDto.Acknowledgement) error {
+	args := m.Called(medicalRecordID, acknowledgedBy, acks)
+	return args.Error(0)
+}
+
+type allergyUsecaseTestSuite struct {
+	suite.Suite
+	allergyRepo *mockAllergyRepository
+	allergyUC   allergy.AllergyUsecase
+}
+
+func (suite *allergyUsecaseTestSuite) SetupTest() {
+	kb := interaction.NewKnowledgeBase()
+	kb.AddIngredients("Amoxsan", "amoxicillin")
+	kb.AddIngredients("Simarc", "warfarin")
+	kb.AddIngredients("Aspilets", "acetylsalicylic acid")
+	kb.AddIngredients("Trimoxul", "trimethoprim")
+	kb.AddRule(interaction.Rule{A: "warfarin", B: "acetylsalicylic acid", Severity: interaction.Major, Description: "bleeding risk"})
+	kb.AddRule(interaction.Rule{ID: "DDI-0002", A: "methotrexate", B: "trimethoprim", Severity: interaction.Contraindicated})
+
+	suite.allergyRepo = new(mockAllergyRepository)
+	suite.allergyUC = NewAllergyUsecase(suite.allergyRepo, kb)
+}
+
+func (suite *allergyUsecaseTestSuite) TestCreateSuccess() {
+	suite.allergyRepo.On("Insert", mock.Anything).Return("1", nil)
+
+	actual, err := suite.allergyUC.Create(context.Background(), allergyDto.CreateRequest{PatientID: "p1", Allergen: " amoxicillin ", Severity: "SEVERE"})
+
+	suite.Nil(err)
+	suite.Equal("1", actual.ID)
+	suite.Equal("amoxicillin", actual.Allergen)
+}
+
+func (suite *allergyUsecaseTestSuite) TestDeleteNotFound() {
+	suite.allergyRepo.On("GetByID", "1").Return(allergyDto.Allergy{}, sql.ErrNoRows)
+
+	err := suite.allergyUC.Delete(context.Background(), "1")
+
+	suite.Equal(sql.ErrNoRows, err)
+	suite.allergyRepo.AssertNotCalled(suite.T(), "SoftDelete", mock.Anything)
+}
+
+func (suite *allergyUsecaseTestSuite) TestCheckPrescriptionAllergyByIngredient() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{{ID: "a1", Allergen: "Amoxicillin", Severity: "SEVERE"}}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", []string{"m1"}).Return([]allergyDto.Medicine{{ID: "m1", Name: "Amoxsan"}}, nil)
+
+	actual, err := suite.allergyUC.CheckPrescription(context.Background(), allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m1"}})
+
+	suite.Nil(err)
+	suite.Len(actual.Errors, 1)
+	suite.Equal("ALLERGY:m1:a1", actual.Errors[0].Code)
+	suite.Empty(actual.Warnings)
+}
+
+func (suite *allergyUsecaseTestSuite) TestCheckPrescriptionInteractions() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{{ID: "a1", Allergen: "aspilets", Severity: "MILD"}}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", mock.Anything).Return([]allergyDto.Medicine{
+		{ID: "m2", Name: "Simarc"},
+		{ID: "m1", Name: "Aspilets"},
+		{ID: "m3", Name: "Methotrexate"},
+		{ID: "m4", Name: "Trimoxul"},
+	}, nil)
+
+	actual, err := suite.allergyUC.CheckPrescription(context.Background(), allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m1", "m2", "m3", "m4"}})
+
+	suite.Nil(err)
+	suite.Len(actual.Errors, 1)
+	suite.Equal("INTERACTION:m3:m4:DDI-0002", actual.Errors[0].Code)
+	suite.Len(actual.Warnings, 2)
+	suite.Equal("ALLERGY:m1:a1", actual.Warnings[0].Code)
+	suite.Equal("INTERACTION:m1:m2:acetylsalicylic acid+warfarin", actual.Warnings[1].Code)
+	suite.Equal("bleeding risk", actual.Warnings[1].Message)
+}
+
+func (suite *allergyUsecaseTestSuite) TestValidatePrescriptionNeedsAcknowledgement() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", mock.Anything).Return([]allergyDto.Medicine{{ID: "m1", Name: "Aspilets"}, {ID: "m2", Name: "Simarc"}}, nil)
+	req := allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m1", "m2"}}
+
+	err := suite.allergyUC.ValidatePrescription(context.Background(), req, []allergyDto.Acknowledgement{{WarningCode: "INTERACTION:m1:m2:acetylsalicylic acid+warfarin", Reason: " "}})
+	suite.EqualError(err, constants.ErrPrescriptionNeedsAck)
+	suite.Len(err.(allergyDto.PrescriptionError).Issues, 1)
+
+	err = suite.allergyUC.ValidatePrescription(context.Background(), req, []allergyDto.Acknowledgement{{WarningCode: "INTERACTION:m1:m2:acetylsalicylic acid+warfarin", Reason: "monitored INR"}})
+	suite.Nil(err)
+}
+
+func (suite *allergyUsecaseTestSuite) TestValidatePrescriptionBlocked() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{{ID: "a1", Allergen: "warfarin", Severity: "SEVERE"}}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", mock.Anything).Return([]allergyDto.Medicine{{ID: "m2", Name: "Simarc"}}, nil)
+
+	err := suite.allergyUC.ValidatePrescription(context.Background(),
+		allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m2"}},
+		[]allergyDto.Acknowledgement{{WarningCode: "ALLERGY:m2:a1", Reason: "acknowledged"}},
+	)
+
+	suite.EqualError(err, constants.ErrPrescriptionBlocked)
+}
+
+func (suite *allergyUsecaseTestSuite) TestValidatePrescriptionUnknownAcknowledgement() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", mock.Anything).Return([]allergyDto.Medicine{{ID: "m1", Name: "Aspilets"}, {ID: "m2", Name: "Simarc"}}, nil)
+	req := allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m1", "m2"}}
+
+	err := suite.allergyUC.ValidatePrescription(context.Background(), req, []allergyDto.Acknowledgement{
+		{WarningCode: "INTERACTION:m1:m2:acetylsalicylic acid+warfarin", Reason: "monitored INR"},
+		{WarningCode: "ALLERGY:m9:a1", Reason: "made up"},
+	})
+	suite.EqualError(err, constants.ErrUnknownAcknowledgement)
+	suite.Equal("ALLERGY:m9:a1", err.(allergyDto.PrescriptionError).Issues[0].Code)
+}
+
+func (suite *allergyUsecaseTestSuite) TestSaveOverridesEmpty() {
+	err := suite.allergyUC.SaveOverrides(context.Background(), "mr1", "d1", allergyDto.CheckRequest{BookingID: "b1"}, nil)
+
+	suite.Nil(err)
+	suite.allergyRepo.AssertNotCalled(suite.T(), "InsertOverrides", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *allergyUsecaseTestSuite) TestSaveOverrides() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", mock.Anything).Return([]allergyDto.Medicine{{ID: "m1", Name: "Aspilets"}, {ID: "m2", Name: "Simarc"}}, nil)
+	acks := []allergyDto.Acknowledgement{{WarningCode: "INTERACTION:m1:m2:acetylsalicylic acid+warfarin", Reason: "monitored INR"}}
+	suite.allergyRepo.On("InsertOverrides", "mr1", "d1", acks).Return(nil)
+
+	err := suite.allergyUC.SaveOverrides(context.Background(), "mr1", "d1", allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m1", "m2"}}, acks)
+
+	suite.Nil(err)
+	suite.allergyRepo.AssertExpectations(suite.T())
+}
+
+func (suite *allergyUsecaseTestSuite) TestSaveOverridesUnknownCode() {
+	err := suite.allergyUC.SaveOverrides(context.Background(), "mr1", "d1", allergyDto.CheckRequest{BookingID: "b1"},
+		[]allergyDto.Acknowledgement{{WarningCode: "INTERACTION:m1:m2:acetylsalicylic acid+warfarin", Reason: "monitored INR"}})
+
+	suite.EqualError(err, constants.ErrUnknownAcknowledgement)
+	suite.allergyRepo.AssertNotCalled(suite.T(), "InsertOverrides", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *allergyUsecaseTestSuite) TestSaveOverridesMissingAcknowledgement() {
+	suite.allergyRepo.On("GetPatientIDByBookingID", "b1").Return("p1", nil)
+	suite.allergyRepo.On("GetByPatientID", "p1").Return([]allergyDto.Allergy{}, nil)
+	suite.allergyRepo.On("GetMedicinesByIDs", mock.Anything).Return([]allergyDto.Medicine{{ID: "m1", Name: "Aspilets"}, {ID: "m2", Name: "Simarc"}}, nil)
+
+	err := suite.allergyUC.SaveOverrides(context.Background(), "mr1", "d1", allergyDto.CheckRequest{BookingID: "b1", MedicineIDs: []string{"m1", "m2"}}, nil)
+
+	suite.EqualError(err, constants.ErrPrescriptionNeedsAck)
+	suite.allergyRepo.AssertNotCalled(suite.T(), "InsertOverrides", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAllergyUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(allergyUsecaseTestSuite))
+}
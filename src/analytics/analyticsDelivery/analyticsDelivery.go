@@ -0,0 +1,160 @@
+package analyticsDelivery
+
+import (
+	"avengers-clinic/model/dto/analyticsDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/src/analytics"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type analyticsDelivery struct {
+	analyticsUC analytics.AnalyticsUsecase
+}
+
+func NewAnalyticsDelivery(v1Group *gin.RouterGroup, analyticsUC analytics.AnalyticsUsecase) {
+	handler := analyticsDelivery{analyticsUC}
+
+	analyticsGroup := v1Group.Group("/analytics")
+	{
+		analyticsGroup.GET("/bookings", middleware.JwtAuth("ADMIN"), handler.GetBookingCounts)
+		analyticsGroup.GET("/booking-rates", middleware.JwtAuth("ADMIN"), handler.GetBookingRates)
+		analyticsGroup.GET("/lead-times", middleware.JwtAuth("ADMIN"), handler.GetLeadTimes)
+		analyticsGroup.GET("/slot-utilization", middleware.JwtAuth("ADMIN"), handler.GetSlotUtilization)
+		analyticsGroup.GET("/top-diagnoses", middleware.JwtAuth("ADMIN"), handler.GetTopDiagnoses)
+		analyticsGroup.GET("/top-medicines", middleware.JwtAuth("ADMIN"), handler.GetTopMedicines)
+	}
+}
+
+func (delivery *analyticsDelivery) GetBookingCounts(c *gin.Context) {
+	filter, err := getFilter(c)
+	if err != nil {
+		delivery.analyticsError(c, err, "01")
+		return
+	}
+
+	counts, err := delivery.analyticsUC.GetBookingCounts(c.Request.Context(), filter)
+	if err != nil {
+		delivery.analyticsError(c, err, "01")
+		return
+	}
+
+	json.NewResponseSuccess(c, counts, "Bookings retrieved successfully", constants.AnalyticsService, "01")
+}
+
+func (delivery *analyticsDelivery) GetBookingRates(c *gin.Context) {
+	filter, err := getFilter(c)
+	if err != nil {
+		delivery.analyticsError(c, err, "02")
+		return
+	}
+
+	rates, err := delivery.analyticsUC.GetBookingRates(c.Request.Context(), filter)
+	if err != nil {
+		delivery.analyticsError(c, err, "02")
+		return
+	}
+
+	json.NewResponseSuccess(c, rates, "Booking rates retrieved successfully", constants.AnalyticsService, "02")
+}
+
+func (delivery *analyticsDelivery) GetLeadTimes(c *gin.Context) {
+	filter, err := getFilter(c)
+	if err != nil {
+		delivery.analyticsError(c, err, "03")
+		return
+	}
+
+	leadTimes, err := delivery.analyticsUC.GetLeadTimes(c.Request.Context(), filter)
+	if err != nil {
+		delivery.analyticsError(c, err, "03")
+		return
+	}
+
+	json.NewResponseSuccess(c, leadTimes, "Lead times retrieved successfully", constants.AnalyticsService, "03")
+}
+
+func (delivery *analyticsDelivery) GetSlotUtilization(c *gin.Context) {
+	filter, err := getFilter(c)
+	if err != nil {
+		delivery.analyticsError(c, err, "04")
+		return
+	}
+
+	schedules, err := delivery.analyticsUC.GetSlotUtilization(c.Request.Context(), filter)
+	if err != nil {
+		delivery.analyticsError(c, err, "04")
+		return
+	}
+
+	json.NewResponseSuccess(c, schedules, "Slot utilization retrieved successfully", constants.AnalyticsService, "04")
+}
+
+func (delivery *analyticsDelivery) GetTopDiagnoses(c *gin.Context) {
+	filter, err := getFilter(c)
+	if err != nil {
+		delivery.analyticsError(c, err, "05")
+		return
+	}
+
+	diagnoses, err := delivery.analyticsUC.GetTopDiagnoses(c.Request.Context(), filter)
+	if err != nil {
+		delivery.analyticsError(c, err, "05")
+		return
+	}
+
+	json.NewResponseSuccess(c, diagnoses, "Top diagnoses retrieved successfully", constants.AnalyticsService, "05")
+}
+
+func (delivery *analyticsDelivery) GetTopMedicines(c *gin.Context) {
+	filter, err := getFilter(c)
+	if err != nil {
+		delivery.analyticsError(c, err, "06")
+		return
+	}
+
+	medicines, err := delivery.analyticsUC.GetTopMedicines(c.Request.Context(), filter)
+	if err != nil {
+		delivery.analyticsError(c, err, "06")
+		return
+	}
+
+	json.NewResponseSuccess(c, medicines, "Top medicines retrieved successfully", constants.AnalyticsService, "06")
+}
+
+func getFilter(c *gin.Context) (analyticsDto.Filter, error) {
+	filter := analyticsDto.Filter{
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+		DoctorID:  c.Query("doctor_id"),
+		GroupBy:   c.Query("group_by"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit == 0 {
+			return analyticsDto.Filter{}, errors.New(constants.ErrInvalidLimit)
+		}
+	}
+	return filter, nil
+}
+
+func (delivery *analyticsDelivery) analyticsError(c *gin.Context, err error, errorCode string) {
+	switch err.Error() {
+	case constants.ErrDateFormat, constants.ErrInvalidDateRange:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "date", Message: err.Error()}}, "Bad request", constants.AnalyticsService, "01")
+		return
+	case constants.ErrInvalidBookingGroup:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "group_by", Message: err.Error()}}, "Bad request", constants.AnalyticsService, "02")
+		return
+	case constants.ErrInvalidLimit:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "limit", Message: err.Error()}}, "Bad request", constants.AnalyticsService, "03")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.AnalyticsService, errorCode)
+}
@@ -0,0 +1,23 @@
+package analytics
+
+import (
+	"avengers-clinic/model/dto/analyticsDto"
+	"context"
+)
+
+type AnalyticsRepository interface {
+	RetrieveBookingCounts(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.BookingCount, error)
+	RetrieveLeadTimes(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.LeadTimes, error)
+	RetrieveSlotUtilization(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.SlotUtilization, error)
+	RetrieveTopDiagnoses(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.Diagnosis, error)
+	RetrieveTopMedicines(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.PrescribedMedicine, error)
+}
+
+type AnalyticsUsecase interface {
+	GetBookingCounts(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.BookingCount, error)
+	GetBookingRates(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.BookingRates, error)
+	GetLeadTimes(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.LeadTimes, error)
+	GetSlotUtilization(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.SlotUtilization, error)
+	GetTopDiagnoses(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.Diagnosis, error)
+	GetTopMedicines(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.PrescribedMedicine, error)
+}
@@ -0,0 +1,202 @@
+package analyticsRepository
+
+import (
+	"avengers-clinic/model/dto/analyticsDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/analytics"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type analyticsRepository struct {
+	db *sql.DB
+}
+
+func NewAnalyticsRepository(db *sql.DB) analytics.AnalyticsRepository {
+	return &analyticsRepository{db}
+}
+
+// bookingStatus is the status of a booking, where a booking still waiting
+// after the day of its schedule is a no-show.
+const bookingStatus = `
+	CASE WHEN b.status = 'WAITING' AND s.schedule_date < CURRENT_DATE THEN 'NO_SHOW' ELSE b.status::text END`
+
+// scheduledBookings restricts a query to the bookings of schedules in the
+// period of the filter, optionally of one doctor.
+const scheduledBookings = `
+	FROM bookings b
+	JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+	JOIN users d ON d.id = s.doctor_id
+	WHERE b.deleted_at IS NULL AND s.schedule_date BETWEEN $1::date AND $2::date AND ($3 = '' OR s.doctor_id::text = $3)`
+
+// recordsInPeriod restricts a query to the medical records created in the
+// period of the filter, optionally by one doctor.
+const recordsInPeriod = `
+	JOIN bookings b ON b.id = r.booking_id
+	JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+	WHERE r.deleted_at IS NULL AND r.created_at >= $1::date AND r.created_at < $2::date + 1 AND ($3 = '' OR s.doctor_id::text = $3)`
+
+var bookingGroups = map[string]string{
+	constants.BookingsByDay:    "TO_CHAR(s.schedule_date, 'YYYY-MM-DD'), TO_CHAR(s.schedule_date, 'YYYY-MM-DD')",
+	constants.BookingsByDoctor: "d.id::text, d.username",
+	constants.BookingsByStatus: bookingStatus + "," + bookingStatus,
+}
+
+var bookingOrders = map[string]string{
+	constants.BookingsByDay:    "1",
+	constants.BookingsByDoctor: "3 DESC, 2",
+	constants.BookingsByStatus: "1",
+}
+
+func (repository *analyticsRepository) RetrieveBookingCounts(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.BookingCount, error) {
+	group, ok := bookingGroups[filter.GroupBy]
+	if !ok {
+		return nil, errors.New(constants.ErrInvalidBookingGroup)
+	}
+
+	query := `
+		SELECT ` + group + `, COUNT(*),
+			COUNT(*) FILTER (WHERE b.status = 'WAITING' AND s.schedule_date >= CURRENT_DATE),
+			COUNT(*) FILTER (WHERE b.status = 'DONE'),
+			COUNT(*) FILTER (WHERE b.status = 'CANCELED'),
+			COUNT(*) FILTER (WHERE b.status = 'WAITING' AND s.schedule_date < CURRENT_DATE)
+		` + scheduledBookings + `
+		GROUP BY 1, 2 ORDER BY ` + bookingOrders[filter.GroupBy] + `;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate, filter.DoctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []analyticsDto.BookingCount
+	for rows.Next() {
+		var count analyticsDto.BookingCount
+		err := rows.Scan(&count.Key, &count.Name, &count.Total, &count.Waiting, &count.Done, &count.Canceled, &count.NoShow)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// RetrieveLeadTimes averages the hours between booking and the start of the
+// booked slot, per doctor and over all of them. Canceled bookings are left out.
+func (repository *analyticsRepository) RetrieveLeadTimes(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.LeadTimes, error) {
+	query := `
+		SELECT COALESCE(d.id::text, ''), COALESCE(d.username, ''), COUNT(*),
+			COALESCE(ROUND((AVG(EXTRACT(EPOCH FROM s.schedule_date + t.start_at - b.created_at)) / 3600)::numeric, 2), 0)
+		FROM bookings b
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users d ON d.id = s.doctor_id
+		JOIN mst_schedule_time t ON t.id = b.mst_schedule_id
+		WHERE b.deleted_at IS NULL AND b.status <> 'CANCELED' AND s.schedule_date BETWEEN $1::date AND $2::date
+			AND ($3 = '' OR s.doctor_id::text = $3)
+		GROUP BY ROLLUP ((d.id, d.username))
+		ORDER BY GROUPING(d.id) DESC, 3 DESC, 2;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate, filter.DoctorID)
+	if err != nil {
+		return analyticsDto.LeadTimes{}, err
+	}
+	defer rows.Close()
+
+	leadTimes := analyticsDto.LeadTimes{Doctors: []analyticsDto.LeadTime{}}
+	first := true
+	for rows.Next() {
+		var leadTime analyticsDto.LeadTime
+		if err := rows.Scan(&leadTime.DoctorID, &leadTime.DoctorName, &leadTime.Bookings, &leadTime.AverageHours); err != nil {
+			return analyticsDto.LeadTimes{}, err
+		}
+
+		// the grand total of the rollup comes first
+		if first {
+			leadTimes.Overall, first = leadTime, false
+			continue
+		}
+		leadTimes.Doctors = append(leadTimes.Doctors, leadTime)
+	}
+	return leadTimes, nil
+}
+
+// RetrieveSlotUtilization counts the slots of every schedule in the period
+// and the slots taken by bookings that were not canceled.
+func (repository *analyticsRepository) RetrieveSlotUtilization(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.SlotUtilization, error) {
+	query := `
+		SELECT s.id, s.doctor_id, d.username, s.schedule_date::text,
+			(SELECT COUNT(*) FROM mst_schedule_time t WHERE t.id BETWEEN s.start_at AND s.end_at AND t.deleted_at IS NULL),
+			COUNT(DISTINCT b.mst_schedule_id)
+		FROM doctor_schedules s
+		JOIN users d ON d.id = s.doctor_id
+		LEFT JOIN bookings b ON b.doctor_schedule_id = s.id AND b.deleted_at IS NULL AND b.status <> 'CANCELED'
+		WHERE s.deleted_at IS NULL AND s.schedule_date BETWEEN $1::date AND $2::date AND ($3 = '' OR s.doctor_id::text = $3)
+		GROUP BY s.id, d.username
+		ORDER BY s.schedule_date, d.username;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate, filter.DoctorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []analyticsDto.SlotUtilization
+	for rows.Next() {
+		var schedule analyticsDto.SlotUtilization
+		err := rows.Scan(&schedule.ScheduleID, &schedule.DoctorID, &schedule.DoctorName, &schedule.ScheduleDate,
+			&schedule.Slots, &schedule.Booked)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func (repository *analyticsRepository) RetrieveTopDiagnoses(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.Diagnosis, error) {
+	query := `
+		SELECT MIN(TRIM(r.diagnosis_results)), COUNT(*)
+		FROM medical_records r
+		` + recordsInPeriod + `
+		GROUP BY LOWER(TRIM(r.diagnosis_results))
+		ORDER BY 2 DESC, 1 LIMIT $4;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate, filter.DoctorID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var diagnoses []analyticsDto.Diagnosis
+	for rows.Next() {
+		var diagnosis analyticsDto.Diagnosis
+		if err := rows.Scan(&diagnosis.Diagnosis, &diagnosis.Records); err != nil {
+			return nil, err
+		}
+		diagnoses = append(diagnoses, diagnosis)
+	}
+	return diagnoses, nil
+}
+
+func (repository *analyticsRepository) RetrieveTopMedicines(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.PrescribedMedicine, error) {
+	query := `
+		SELECT m.id, m.name, COUNT(DISTINCT r.id), SUM(md.quantity)
+		FROM medical_record_medicine_details md
+		JOIN medicines m ON m.id = md.medicine_id
+		JOIN medical_records r ON r.id = md.medical_record_id
+		` + recordsInPeriod + ` AND md.deleted_at IS NULL
+		GROUP BY m.id, m.name
+		ORDER BY 4 DESC, 2 LIMIT $4;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate, filter.DoctorID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var medicines []analyticsDto.PrescribedMedicine
+	for rows.Next() {
+		var medicine analyticsDto.PrescribedMedicine
+		if err := rows.Scan(&medicine.MedicineID, &medicine.Name, &medicine.Records, &medicine.Quantity); err != nil {
+			return nil, err
+		}
+		medicines = append(medicines, medicine)
+	}
+	return medicines, nil
+}
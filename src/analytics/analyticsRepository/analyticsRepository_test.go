@@ -0,0 +1,115 @@
+package analyticsRepository
+
+import (
+	"avengers-clinic/model/dto/analyticsDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/analytics"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type analyticsRepositoryTestSuite struct {
+	suite.Suite
+	analyticsRepo analytics.AnalyticsRepository
+	mock          sqlmock.Sqlmock
+}
+
+func (suite *analyticsRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.analyticsRepo = NewAnalyticsRepository(db)
+	suite.mock = mock
+}
+
+func march() analyticsDto.Filter {
+	return analyticsDto.Filter{StartDate: "2024-03-01", EndDate: "2024-03-31", Limit: 10}
+}
+
+func (suite *analyticsRepositoryTestSuite) TestRetrieveBookingCountsByDoctor() {
+	rows := sqlmock.NewRows([]string{"key", "name", "total", "waiting", "done", "canceled", "no_show"}).
+		AddRow("d1", "Joko", 10, 2, 6, 1, 1)
+	suite.mock.ExpectQuery(`SELECT d.id::text, d.username, COUNT\(\*\)`).
+		WithArgs("2024-03-01", "2024-03-31", "").
+		WillReturnRows(rows)
+
+	filter := march()
+	filter.GroupBy = constants.BookingsByDoctor
+	counts, err := suite.analyticsRepo.RetrieveBookingCounts(context.Background(), filter)
+
+	suite.Nil(err)
+	suite.Equal([]analyticsDto.BookingCount{{Key: "d1", Name: "Joko", Total: 10, Waiting: 2, Done: 6, Canceled: 1, NoShow: 1}}, counts)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *analyticsRepositoryTestSuite) TestRetrieveBookingCountsInvalidGroup() {
+	filter := march()
+	filter.GroupBy = "patient"
+
+	_, err := suite.analyticsRepo.RetrieveBookingCounts(context.Background(), filter)
+
+	suite.EqualError(err, constants.ErrInvalidBookingGroup)
+}
+
+func (suite *analyticsRepositoryTestSuite) TestRetrieveLeadTimes() {
+	rows := sqlmock.NewRows([]string{"id", "username", "bookings", "hours"}).
+		AddRow("", "", 12, "30.5").
+		AddRow("d1", "Joko", 8, "36.25").
+		AddRow("d2", "Sari", 4, "19.00")
+	suite.mock.ExpectQuery(`GROUP BY ROLLUP`).
+		WithArgs("2024-03-01", "2024-03-31", "").
+		WillReturnRows(rows)
+
+	leadTimes, err := suite.analyticsRepo.RetrieveLeadTimes(context.Background(), march())
+
+	suite.Nil(err)
+	suite.Equal(analyticsDto.LeadTime{Bookings: 12, AverageHours: 30.5}, leadTimes.Overall)
+	suite.Len(leadTimes.Doctors, 2)
+	suite.Equal(36.25, leadTimes.Doctors[0].AverageHours)
+}
+
+func (suite *analyticsRepositoryTestSuite) TestRetrieveSlotUtilization() {
+	rows := sqlmock.NewRows([]string{"id", "doctor_id", "username", "schedule_date", "slots", "booked"}).
+		AddRow("s1", "d1", "Joko", "2024-03-14", 8, 6)
+	suite.mock.ExpectQuery(`LEFT JOIN bookings b ON b.doctor_schedule_id = s.id`).
+		WithArgs("2024-03-01", "2024-03-31", "d1").
+		WillReturnRows(rows)
+
+	filter := march()
+	filter.DoctorID = "d1"
+	schedules, err := suite.analyticsRepo.RetrieveSlotUtilization(context.Background(), filter)
+
+	suite.Nil(err)
+	suite.Equal(8, schedules[0].Slots)
+	suite.Equal(6, schedules[0].Booked)
+}
+
+func (suite *analyticsRepositoryTestSuite) TestRetrieveTopDiagnoses() {
+	rows := sqlmock.NewRows([]string{"diagnosis", "records"}).AddRow("Influenza", 7).AddRow("Migraine", 3)
+	suite.mock.ExpectQuery(`GROUP BY LOWER\(TRIM\(r.diagnosis_results\)\)`).
+		WithArgs("2024-03-01", "2024-03-31", "", 10).
+		WillReturnRows(rows)
+
+	diagnoses, err := suite.analyticsRepo.RetrieveTopDiagnoses(context.Background(), march())
+
+	suite.Nil(err)
+	suite.Equal([]analyticsDto.Diagnosis{{Diagnosis: "Influenza", Records: 7}, {Diagnosis: "Migraine", Records: 3}}, diagnoses)
+}
+
+func (suite *analyticsRepositoryTestSuite) TestRetrieveTopMedicines() {
+	rows := sqlmock.NewRows([]string{"id", "name", "records", "quantity"}).AddRow("m1", "Paracetamol", 5, 30)
+	suite.mock.ExpectQuery(`FROM medical_record_medicine_details md`).
+		WithArgs("2024-03-01", "2024-03-31", "", 10).
+		WillReturnRows(rows)
+
+	medicines, err := suite.analyticsRepo.RetrieveTopMedicines(context.Background(), march())
+
+	suite.Nil(err)
+	suite.Equal([]analyticsDto.PrescribedMedicine{{MedicineID: "m1", Name: "Paracetamol", Records: 5, Quantity: 30}}, medicines)
+}
+
+func TestAnalyticsRepository(t *testing.T) {
+	suite.Run(t, new(analyticsRepositoryTestSuite))
+}
@@ -0,0 +1,123 @@
+package analyticsUsecase
+
+import (
+	"avengers-clinic/model/dto/analyticsDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/analytics"
+	"context"
+	"errors"
+	"math"
+	"strings"
+)
+
+// defaultLimit is the number of diagnoses and medicines ranked when the
+// dashboard does not ask for more.
+const defaultLimit = 10
+
+type analyticsUsecase struct {
+	analyticsRepo analytics.AnalyticsRepository
+}
+
+func NewAnalyticsUsecase(analyticsRepo analytics.AnalyticsRepository) analytics.AnalyticsUsecase {
+	return &analyticsUsecase{analyticsRepo}
+}
+
+func (usecase *analyticsUsecase) GetBookingCounts(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.BookingCount, error) {
+	if err := normalize(&filter); err != nil {
+		return nil, err
+	}
+
+	filter.GroupBy = strings.ToLower(filter.GroupBy)
+	if filter.GroupBy == "" {
+		filter.GroupBy = constants.BookingsByDay
+	}
+	return usecase.analyticsRepo.RetrieveBookingCounts(ctx, filter)
+}
+
+// GetBookingRates adds up the bookings per status to rate cancellations and
+// no-shows against all bookings of the period.
+func (usecase *analyticsUsecase) GetBookingRates(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.BookingRates, error) {
+	if err := normalize(&filter); err != nil {
+		return analyticsDto.BookingRates{}, err
+	}
+
+	filter.GroupBy = constants.BookingsByStatus
+	counts, err := usecase.analyticsRepo.RetrieveBookingCounts(ctx, filter)
+	if err != nil {
+		return analyticsDto.BookingRates{}, err
+	}
+
+	var rates analyticsDto.BookingRates
+	for _, count := range counts {
+		rates.Total += count.Total
+		rates.Done += count.Done
+		rates.Canceled += count.Canceled
+		rates.NoShow += count.NoShow
+	}
+	rates.CancellationRate = percent(rates.Canceled, rates.Total)
+	rates.NoShowRate = percent(rates.NoShow, rates.Total)
+	return rates, nil
+}
+
+func (usecase *analyticsUsecase) GetLeadTimes(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.LeadTimes, error) {
+	if err := normalize(&filter); err != nil {
+		return analyticsDto.LeadTimes{}, err
+	}
+	return usecase.analyticsRepo.RetrieveLeadTimes(ctx, filter)
+}
+
+func (usecase *analyticsUsecase) GetSlotUtilization(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.SlotUtilization, error) {
+	if err := normalize(&filter); err != nil {
+		return nil, err
+	}
+
+	schedules, err := usecase.analyticsRepo.RetrieveSlotUtilization(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, schedule := range schedules {
+		schedules[i].Utilization = percent(schedule.Booked, schedule.Slots)
+	}
+	return schedules, nil
+}
+
+func (usecase *analyticsUsecase) GetTopDiagnoses(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.Diagnosis, error) {
+	if err := normalize(&filter); err != nil {
+		return nil, err
+	}
+	return usecase.analyticsRepo.RetrieveTopDiagnoses(ctx, filter)
+}
+
+func (usecase *analyticsUsecase) GetTopMedicines(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.PrescribedMedicine, error) {
+	if err := normalize(&filter); err != nil {
+		return nil, err
+	}
+	return usecase.analyticsRepo.RetrieveTopMedicines(ctx, filter)
+}
+
+// normalize fills the default period and limit of a filter.
+func normalize(filter *analyticsDto.Filter) error {
+	var err error
+	filter.StartDate, filter.EndDate, err = utils.DateRange(filter.StartDate, filter.EndDate)
+	if err != nil {
+		return err
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultLimit
+	}
+	if filter.Limit < 0 || filter.Limit > 100 {
+		return errors.New(constants.ErrInvalidLimit)
+	}
+	return nil
+}
+
+// percent is part of total in percent, rounded to two decimals.
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(total)) / 100
+}
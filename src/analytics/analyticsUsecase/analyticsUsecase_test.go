@@ -0,0 +1,146 @@
+package analyticsUsecase
+
+import (
+	"avengers-clinic/model/dto/analyticsDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/analytics"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockAnalyticsRepository struct {
+	mock.Mock
+}
+
+func (m *mockAnalyticsRepository) RetrieveBookingCounts(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.BookingCount, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]analyticsDto.BookingCount), args.Error(1)
+}
+
+func (m *mockAnalyticsRepository) RetrieveLeadTimes(ctx context.Context, filter analyticsDto.Filter) (analyticsDto.LeadTimes, error) {
+	args := m.Called(filter)
+	return args.Get(0).(analyticsDto.LeadTimes), args.Error(1)
+}
+
+func (m *mockAnalyticsRepository) RetrieveSlotUtilization(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.SlotUtilization, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]analyticsDto.SlotUtilization), args.Error(1)
+}
+
+func (m *mockAnalyticsRepository) RetrieveTopDiagnoses(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.Diagnosis, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]analyticsDto.Diagnosis), args.Error(1)
+}
+
+func (m *mockAnalyticsRepository) RetrieveTopMedicines(ctx context.Context, filter analyticsDto.Filter) ([]analyticsDto.PrescribedMedicine, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]analyticsDto.PrescribedMedicine), args.Error(1)
+}
+
+type analyticsUsecaseTestSuite struct {
+	suite.Suite
+	analyticsRepo *mockAnalyticsRepository
+	analyticsUC   analytics.AnalyticsUsecase
+}
+
+func (suite *analyticsUsecaseTestSuite) SetupTest() {
+	suite.analyticsRepo = new(mockAnalyticsRepository)
+	suite.analyticsUC = NewAnalyticsUsecase(suite.analyticsRepo)
+}
+
+func march() analyticsDto.Filter {
+	return analyticsDto.Filter{StartDate: "2024-03-01", EndDate: "2024-03-31", Limit: defaultLimit}
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetBookingCountsDefaults() {
+	now := time.Now()
+	filter := analyticsDto.Filter{
+		StartDate: now.Format("2006-01") + "-01",
+		EndDate:   now.Format("2006-01-02"),
+		GroupBy:   constants.BookingsByDay,
+		Limit:     defaultLimit,
+	}
+	suite.analyticsRepo.On("RetrieveBookingCounts", filter).Return([]analyticsDto.BookingCount{}, nil)
+
+	_, err := suite.analyticsUC.GetBookingCounts(context.Background(), analyticsDto.Filter{})
+
+	suite.Nil(err)
+	suite.analyticsRepo.AssertExpectations(suite.T())
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetBookingCountsInvalidDate() {
+	_, err := suite.analyticsUC.GetBookingCounts(context.Background(), analyticsDto.Filter{StartDate: "2024/03/01"})
+
+	suite.EqualError(err, constants.ErrDateFormat)
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetBookingRates() {
+	filter := march()
+	filter.GroupBy = constants.BookingsByStatus
+	suite.analyticsRepo.On("RetrieveBookingCounts", filter).Return([]analyticsDto.BookingCount{
+		{Key: "CANCELED", Total: 2, Canceled: 2},
+		{Key: "DONE", Total: 5, Done: 5},
+		{Key: "NO_SHOW", Total: 1, NoShow: 1},
+	}, nil)
+
+	rates, err := suite.analyticsUC.GetBookingRates(context.Background(), analyticsDto.Filter{StartDate: "2024-03-01", EndDate: "2024-03-31", GroupBy: "day"})
+
+	suite.Nil(err)
+	suite.Equal(8, rates.Total)
+	suite.Equal(25.0, rates.CancellationRate)
+	suite.Equal(12.5, rates.NoShowRate)
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetBookingRatesWithoutBookings() {
+	filter := march()
+	filter.GroupBy = constants.BookingsByStatus
+	suite.analyticsRepo.On("RetrieveBookingCounts", filter).Return([]analyticsDto.BookingCount{}, nil)
+
+	rates, err := suite.analyticsUC.GetBookingRates(context.Background(), march())
+
+	suite.Nil(err)
+	suite.Equal(analyticsDto.BookingRates{}, rates)
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetSlotUtilization() {
+	suite.analyticsRepo.On("RetrieveSlotUtilization", march()).Return([]analyticsDto.SlotUtilization{
+		{ScheduleID: "s1", Slots: 8, Booked: 6},
+		{ScheduleID: "s2", Slots: 3, Booked: 1},
+		{ScheduleID: "s3"},
+	}, nil)
+
+	schedules, err := suite.analyticsUC.GetSlotUtilization(context.Background(), march())
+
+	suite.Nil(err)
+	suite.Equal(75.0, schedules[0].Utilization)
+	suite.Equal(33.33, schedules[1].Utilization)
+	suite.Equal(0.0, schedules[2].Utilization)
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetTopDiagnosesInvalidLimit() {
+	filter := march()
+	filter.Limit = 500
+
+	_, err := suite.analyticsUC.GetTopDiagnoses(context.Background(), filter)
+
+	suite.EqualError(err, constants.ErrInvalidLimit)
+}
+
+func (suite *analyticsUsecaseTestSuite) TestGetTopMedicines() {
+	filter := march()
+	filter.Limit = 5
+	suite.analyticsRepo.On("RetrieveTopMedicines", filter).Return([]analyticsDto.PrescribedMedicine{{MedicineID: "m1", Quantity: 20}}, nil)
+
+	medicines, err := suite.analyticsUC.GetTopMedicines(context.Background(), filter)
+
+	suite.Nil(err)
+	suite.Len(medicines, 1)
+}
+
+func TestAnalyticsUsecase(t *testing.T) {
+	suite.Run(t, new(analyticsUsecaseTestSuite))
+}
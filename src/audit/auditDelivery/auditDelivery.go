@@ -0,0 +1,75 @@
+package auditDelivery
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"database/sql"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type auditDelivery struct {
+	auditUC audit.AuditUsecase
+}
+
+func NewAuditDelivery(v1Group *gin.RouterGroup, auditUC audit.AuditUsecase) {
+	handler := auditDelivery{auditUC}
+	auditGroup := v1Group.Group("/audit-logs")
+	{
+		auditGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetAll)
+		auditGroup.GET("/verify", middleware.JwtAuth("ADMIN"), handler.Verify)
+		auditGroup.GET("/:id", middleware.JwtAuth("ADMIN"), handler.GetByID)
+	}
+}
+
+func (delivery *auditDelivery) GetAll(c *gin.Context) {
+	query, invalid := utils.ParseQuery(c, audit.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(c, invalid, "Bad request", constants.AuditService, "01")
+		return
+	}
+
+	entries, paging, err := delivery.auditUC.GetAll(c.Request.Context(), query)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.AuditService, "01")
+		return
+	}
+
+	json.NewResponsePaging(c, entries, paging, "Audit logs retrieved successfully", constants.AuditService, "01")
+}
+
+func (delivery *auditDelivery) GetByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		json.NewResponseNotFound(c, constants.ErrAuditLogNotExist, constants.AuditService, "01")
+		return
+	}
+
+	entry, err := delivery.auditUC.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, constants.ErrAuditLogNotExist, constants.AuditService, "01")
+			return
+		}
+		json.NewResponseError(c, err.Error(), constants.AuditService, "02")
+		return
+	}
+
+	json.NewResponseSuccess(c, entry, "Audit log retrieved successfully", constants.AuditService, "02")
+}
+
+// Verify recomputes the hash chain of the whole log, to tell whether entries
+// were changed or removed in the database.
+func (delivery *auditDelivery) Verify(c *gin.Context) {
+	verification, err := delivery.auditUC.Verify(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.AuditService, "03")
+		return
+	}
+
+	json.NewResponseSuccess(c, verification, "Audit log verified", constants.AuditService, "03")
+}
@@ -0,0 +1,269 @@
+package auditDelivery
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockAuditUsecase struct {
+	mock.Mock
+}
+
+func (m *mockAuditUsecase) Record(ctx context.Context, entry auditDto.Entry, before, after interface{}) error {
+	args := m.Called(entry, before, after)
+	return args.Error(0)
+}
+
+func (m *mockAuditUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]auditDto.Entry, queryDto.Paging, error) {
+	args := m.Called(query)
+	return args.Get(0).([]auditDto.Entry), args.Get(1).(queryDto.Paging), args.Error(2)
+}
+
+func (m *mockAuditUsecase) GetByID(ctx context.Context, id int64) (auditDto.Entry, error) {
+	args := m.Called(id)
+	return args.Get(0).(auditDto.Entry), args.Error(1)
+}
+
+func (m *mockAuditUsecase) Verify(ctx context.Context) (auditDto.Verification, error) {
+	args := m.Called()
+	return args.Get(0).(auditDto.Verification), args.Error(1)
+}
+
+type medicine struct {
+	ID    string `json:"id"`
+	Price int    `json:"price"`
+}
+
+type auditDeliveryTestSuite struct {
+	suite.Suite
+	router    *gin.Engine
+	auditUC   *mockAuditUsecase
+	medicines map[string]medicine
+}
+
+func (suite *auditDeliveryTestSuite) SetupTest() {
+	suite.router = gin.New()
+	suite.auditUC = new(mockAuditUsecase)
+	suite.medicines = map[string]medicine{"m1": {ID: "m1", Price: 5000}}
+
+	snapshots := audit.Snapshots{"medicines": func(ctx context.Context, id string) (interface{}, error) {
+		record, ok := suite.medicines[id]
+		if !ok {
+			return medicine{}, sql.ErrNoRows
+		}
+		return record, nil
+	}}
+
+	suite.router.Use(middleware.RequestID())
+	v1Group := suite.router.Group("/api/v1")
+	v1Group.Use(Recorder(v1Group.BasePath(), suite.auditUC, snapshots))
+	NewAuditDelivery(v1Group, suite.auditUC)
+
+	v1Group.GET("/medicines/:id", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	v1Group.POST("/medicines", func(c *gin.Context) {
+		suite.medicines["m2"] = medicine{ID: "m2", Price: 8000}
+		c.JSON(http.StatusCreated, gin.H{"responseCode": "2010301", "data": gin.H{"id": "m2"}})
+	})
+	v1Group.PUT("/medicines/:id", func(c *gin.Context) {
+		if c.Param("id") == "m3" {
+			c.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		suite.medicines["m1"] = medicine{ID: "m1", Price: 6000}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	v1Group.DELETE("/medicines/:id", func(c *gin.Context) {
+		delete(suite.medicines, c.Param("id"))
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	v1Group.GET("/medical-records/:id", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	v1Group.POST("/users/login", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": "token"}) })
+}
+
+func (suite *auditDeliveryTestSuite) serve(method, path, token string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set(constants.RequestIDHeader, "r1")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	suite.router.ServeHTTP(res, req)
+	return res
+}
+
+// Start Recorder
+func (suite *auditDeliveryTestSuite) TestRecorderUpdate() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	expected := auditDto.Entry{
+		ActorID:       "u1",
+		ActorUsername: "admin",
+		ActorRole:     "ADMIN",
+		Action:        constants.AuditUpdate,
+		Route:         "PUT /api/v1/medicines/:id",
+		Entity:        "medicines",
+		EntityID:      "m1",
+		Status:        http.StatusOK,
+		IP:            "10.0.0.1",
+		RequestID:     "r1",
+	}
+	suite.auditUC.On("Record", expected, medicine{ID: "m1", Price: 5000}, medicine{ID: "m1", Price: 6000}).Return(nil)
+
+	res := suite.serve(http.MethodPut, "/api/v1/medicines/m1", token)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.Equal("r1", res.Header().Get(constants.RequestIDHeader))
+	suite.auditUC.AssertExpectations(suite.T())
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderCreate() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	suite.auditUC.On("Record", mock.MatchedBy(func(entry auditDto.Entry) bool {
+		return entry.Action == constants.AuditCreate && entry.Entity == "medicines" && entry.EntityID == "m2" && entry.Status == http.StatusCreated
+	}), nil, medicine{ID: "m2", Price: 8000}).Return(nil)
+
+	res := suite.serve(http.MethodPost, "/api/v1/medicines", token)
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.JSONEq(`{"responseCode":"2010301","data":{"id":"m2"}}`, res.Body.String())
+	suite.auditUC.AssertExpectations(suite.T())
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderDelete() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	suite.auditUC.On("Record", mock.MatchedBy(func(entry auditDto.Entry) bool {
+		return entry.Action == constants.AuditDelete && entry.EntityID == "m1"
+	}), medicine{ID: "m1", Price: 5000}, nil).Return(nil)
+
+	suite.serve(http.MethodDelete, "/api/v1/medicines/m1", token)
+
+	suite.auditUC.AssertExpectations(suite.T())
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderFailedChangeKeepsNoRecord() {
+	suite.medicines["m3"] = medicine{ID: "m3"}
+	suite.auditUC.On("Record", mock.MatchedBy(func(entry auditDto.Entry) bool {
+		return entry.Status == http.StatusNotFound && entry.ActorID == ""
+	}), nil, nil).Return(nil)
+
+	suite.serve(http.MethodPut, "/api/v1/medicines/m3", "")
+
+	suite.auditUC.AssertExpectations(suite.T())
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderMedicalRecordRead() {
+	token, _ := utils.GenerateJWT("d1", "dokter", "DOCTOR")
+	suite.auditUC.On("Record", mock.MatchedBy(func(entry auditDto.Entry) bool {
+		return entry.Action == constants.AuditRead && entry.Entity == "medical-records" && entry.EntityID == "r1" && entry.ActorID == "d1"
+	}), nil, nil).Return(nil)
+
+	suite.serve(http.MethodGet, "/api/v1/medical-records/r1", token)
+
+	suite.auditUC.AssertExpectations(suite.T())
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderSkipsOtherReads() {
+	suite.serve(http.MethodGet, "/api/v1/medicines/m1", "")
+
+	suite.auditUC.AssertNotCalled(suite.T(), "Record", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderLogin() {
+	suite.auditUC.On("Record", mock.MatchedBy(func(entry auditDto.Entry) bool {
+		return entry.Action == constants.AuditLogin && entry.Entity == "users"
+	}), nil, nil).Return(nil)
+
+	suite.serve(http.MethodPost, "/api/v1/users/login", "")
+
+	suite.auditUC.AssertExpectations(suite.T())
+}
+
+func (suite *auditDeliveryTestSuite) TestRecorderErrorDoesNotFailRequest() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	suite.auditUC.On("Record", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("connection lost"))
+
+	res := suite.serve(http.MethodDelete, "/api/v1/medicines/m1", token)
+
+	suite.Equal(http.StatusOK, res.Code)
+}
+
+// Start Get All
+func (suite *auditDeliveryTestSuite) TestGetAllSuccess() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	query := queryDto.Query{Page: 1, Size: 10, Sort: audit.ListResource.DefaultSort, Filters: []queryDto.Filter{{Field: "entity_id", Value: "r1"}}}
+	suite.auditUC.On("GetAll", query).Return([]auditDto.Entry{{ID: 1, Action: constants.AuditRead, Entity: "medical-records", EntityID: "r1"}}, queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, nil)
+
+	res := suite.serve(http.MethodGet, "/api/v1/audit-logs?entity_id=r1", token)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.True(strings.Contains(res.Body.String(), `"responseCode":"2001701"`))
+	suite.True(strings.Contains(res.Body.String(), `"entity_id":"r1"`))
+}
+
+func (suite *auditDeliveryTestSuite) TestGetAllBadRequest() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+
+	res := suite.serve(http.MethodGet, "/api/v1/audit-logs?sort=actor", token)
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+}
+
+func (suite *auditDeliveryTestSuite) TestGetAllForbidden() {
+	token, _ := utils.GenerateJWT("d1", "dokter", "DOCTOR")
+
+	res := suite.serve(http.MethodGet, "/api/v1/audit-logs", token)
+
+	suite.Equal(http.StatusForbidden, res.Code)
+}
+
+// Start Get By ID
+func (suite *auditDeliveryTestSuite) TestGetByIDSuccess() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	suite.auditUC.On("GetByID", int64(7)).Return(auditDto.Entry{ID: 7}, nil)
+
+	res := suite.serve(http.MethodGet, "/api/v1/audit-logs/7", token)
+
+	suite.Equal(http.StatusOK, res.Code)
+}
+
+func (suite *auditDeliveryTestSuite) TestGetByIDNotFound() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	suite.auditUC.On("GetByID", int64(7)).Return(auditDto.Entry{}, sql.ErrNoRows)
+
+	for _, id := range []string{"7", "abc"} {
+		res := suite.serve(http.MethodGet, "/api/v1/audit-logs/"+id, token)
+
+		suite.Equal(http.StatusNotFound, res.Code)
+	}
+}
+
+// Start Verify
+func (suite *auditDeliveryTestSuite) TestVerify() {
+	token, _ := utils.GenerateJWT("u1", "admin", "ADMIN")
+	suite.auditUC.On("Verify").Return(auditDto.Verification{Valid: false, Checked: 3, BrokenAt: 3, Reason: constants.AuditReasonTampered}, nil)
+
+	res := suite.serve(http.MethodGet, "/api/v1/audit-logs/verify", token)
+
+	expected := `{"responseCode":"2001703","responseMessage":"Audit log verified","data":{"valid":false,"checked":3,"broken_at":3,"reason":"` + constants.AuditReasonTampered + `"}}`
+	suite.Equal(http.StatusOK, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func TestAuditDeliveryTestSuite(t *testing.T) {
+	suite.Run(t, new(auditDeliveryTestSuite))
+}
@@ -0,0 +1,153 @@
+package auditDelivery
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// auditedReads are the routes under which reads are recorded as well, as
+// they show medical records.
+var auditedReads = []string{"/medical-records", "/documents/medical-records", "/fhir", "/allergies"}
+
+// routeActions are the routes whose method does not tell what they do.
+var routeActions = map[string]string{
+	"POST /users/login":                constants.AuditLogin,
+	"GET /doctor-schedule/restore/:id": constants.AuditUpdate,
+}
+
+// bodyWriter keeps a copy of the response, for the ID of created records.
+type bodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// Recorder writes an audit entry for every request that changes something
+// and for every read of medical records, routed under basePath. Changes keep
+// the record before and after them when its entity has a snapshot. Request
+// bodies and responses are never kept, as they carry passwords and tokens.
+// An entry that cannot be written is logged and the request is not failed, it
+// has already happened.
+func Recorder(basePath string, auditUC audit.AuditUsecase, snapshots audit.Snapshots) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := strings.TrimPrefix(c.FullPath(), basePath)
+		action := auditAction(c.Request.Method, path, len(c.Params) > 0)
+		if c.FullPath() == "" || action == "" {
+			c.Next()
+			return
+		}
+
+		entry := auditDto.Entry{
+			Action:   action,
+			Route:    c.Request.Method + " " + c.FullPath(),
+			Entity:   strings.Split(strings.TrimPrefix(path, "/"), "/")[0],
+			EntityID: c.Param("id"),
+			IP:       c.ClientIP(),
+		}
+		if entry.EntityID == "" && len(c.Params) > 0 {
+			entry.EntityID = c.Params[0].Value
+		}
+		if claims := utils.Actor(c); claims != nil {
+			entry.ActorID, entry.ActorUsername, entry.ActorRole = claims.ID, claims.Username, claims.Role
+		}
+
+		// only the id param names the record of the entity, others name records of their own
+		snapshot := snapshots[entry.Entity]
+		var before, after interface{}
+		if action != constants.AuditRead && snapshot != nil && c.Param("id") != "" {
+			before = load(c.Request.Context(), snapshot, c.Param("id"))
+		}
+
+		writer := &bodyWriter{ResponseWriter: c.Writer}
+		if action == constants.AuditCreate {
+			c.Writer = writer
+		}
+		c.Next()
+
+		// what happened is recorded even when the client is gone by now
+		ctx := context.WithoutCancel(c.Request.Context())
+		entry.Status = c.Writer.Status()
+		entry.RequestID = c.GetString(constants.RequestIDHeader)
+		if entry.Status >= http.StatusBadRequest {
+			before = nil
+		} else if action != constants.AuditRead && snapshot != nil {
+			if action == constants.AuditCreate {
+				entry.EntityID = createdID(writer.body.Bytes())
+			}
+			if entry.EntityID != "" && (action == constants.AuditCreate || c.Param("id") != "") {
+				after = load(ctx, snapshot, entry.EntityID)
+			}
+		}
+
+		if err := auditUC.Record(ctx, entry, before, after); err != nil {
+			log.Error().Msg("auditDelivery.Recorder.err : " + err.Error())
+		}
+	}
+}
+
+// auditAction is what a request does to its entity, empty when it is not
+// recorded. Posting to a record changes it rather than creating one.
+func auditAction(method, path string, hasParams bool) string {
+	if action, ok := routeActions[method+" "+path]; ok {
+		return action
+	}
+
+	switch method {
+	case http.MethodPost:
+		if hasParams {
+			return constants.AuditUpdate
+		}
+		return constants.AuditCreate
+	case http.MethodPut, http.MethodPatch:
+		return constants.AuditUpdate
+	case http.MethodDelete:
+		return constants.AuditDelete
+	case http.MethodGet:
+		for _, prefix := range auditedReads {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return constants.AuditRead
+			}
+		}
+	}
+	return ""
+}
+
+// load is the record with the id, nil when it is not there.
+func load(ctx context.Context, snapshot audit.Snapshot, id string) interface{} {
+	record, err := snapshot(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+// createdID is the id of the record in a created response, if it has one.
+func createdID(body []byte) string {
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if json.Unmarshal(body, &response) != nil {
+		return ""
+	}
+
+	for key, value := range response.Data {
+		if id, ok := value.(string); ok && strings.EqualFold(key, "id") {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/model/dto/queryDto"
+	"context"
+)
+
+type AuditRepository interface {
+	Append(ctx context.Context, entry auditDto.Entry) (auditDto.Entry, error)
+	RetrieveAll(ctx context.Context, query queryDto.Query) ([]auditDto.Entry, int, error)
+	RetrieveByID(ctx context.Context, id int64) (auditDto.Entry, error)
+	RetrieveChain(ctx context.Context, afterID int64, limit int) ([]auditDto.Entry, error)
+}
+
+type AuditUsecase interface {
+	Record(ctx context.Context, entry auditDto.Entry, before, after interface{}) error
+	GetAll(ctx context.Context, query queryDto.Query) ([]auditDto.Entry, queryDto.Paging, error)
+	GetByID(ctx context.Context, id int64) (auditDto.Entry, error)
+	Verify(ctx context.Context) (auditDto.Verification, error)
+}
+
+// Snapshot loads a record as it is now, to keep it before and after a change.
+type Snapshot func(ctx context.Context, id string) (interface{}, error)
+
+// Snapshots are the loaders of the records of each entity, named by the first
+// segment of their routes, as in "medicines".
+type Snapshots map[string]Snapshot
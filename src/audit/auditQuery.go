@@ -0,0 +1,23 @@
+package audit
+
+import "avengers-clinic/model/dto/queryDto"
+
+// ListResource is what the audit log can be filtered and sorted by. The
+// newest entries come first.
+var ListResource = queryDto.Resource{
+	Filters: map[string]string{
+		"actor_id":   "actor_id",
+		"action":     "action",
+		"entity":     "entity",
+		"entity_id":  "entity_id",
+		"request_id": "request_id",
+		"status":     "status",
+	},
+	Sorts: map[string]string{
+		"id":         "id",
+		"created_at": "created_at",
+	},
+	DateColumn:  "created_at",
+	DefaultSort: []queryDto.Sort{{Field: "id", Desc: true}},
+	Key:         "id",
+}
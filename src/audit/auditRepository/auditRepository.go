@@ -0,0 +1,137 @@
+package auditRepository
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"context"
+	"database/sql"
+)
+
+// lockKey is the advisory lock held while appending, so that every entry is
+// chained to the one written just before it.
+const lockKey = 4207732
+
+const columns = `id, actor_id, actor_username, actor_role, action, route, entity, entity_id, before, after, diff,
+	status, ip, request_id, TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS.US'), prev_hash, hash`
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) audit.AuditRepository {
+	return &auditRepository{db}
+}
+
+// Append chains the entry to the last one and writes it. The ID is taken
+// before the hash is made, as the hash covers it.
+func (repository *auditRepository) Append(ctx context.Context, entry auditDto.Entry) (auditDto.Entry, error) {
+	tx, err := repository.db.BeginTx(ctx, nil)
+	if err != nil {
+		return auditDto.Entry{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1);", lockKey); err != nil {
+		tx.Rollback()
+		return auditDto.Entry{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_logs ORDER BY id DESC LIMIT 1;").Scan(&entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.PrevHash, err = constants.AuditGenesisHash, nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return auditDto.Entry{}, err
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT nextval('audit_logs_id_seq');").Scan(&entry.ID); err != nil {
+		tx.Rollback()
+		return auditDto.Entry{}, err
+	}
+	entry.Hash = utils.AuditHash(entry)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_logs (id, actor_id, actor_username, actor_role, action, route, entity, entity_id, before, after, diff,
+			status, ip, request_id, created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);`,
+		entry.ID, entry.ActorID, entry.ActorUsername, entry.ActorRole, entry.Action, entry.Route, entry.Entity, entry.EntityID,
+		jsonb(entry.Before), jsonb(entry.After), jsonb(entry.Diff),
+		entry.Status, entry.IP, entry.RequestID, entry.CreatedAt, entry.PrevHash, entry.Hash)
+	if err != nil {
+		tx.Rollback()
+		return auditDto.Entry{}, err
+	}
+	return entry, tx.Commit()
+}
+
+// RetrieveAll returns a page of the entries of the query and how many entries
+// match it in all.
+func (repository *auditRepository) RetrieveAll(ctx context.Context, query queryDto.Query) ([]auditDto.Entry, int, error) {
+	conditions, args := utils.ListConditions(query, audit.ListResource, nil)
+
+	var total int
+	if err := repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_logs WHERE TRUE"+conditions+";", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order, args := utils.ListOrder(query, audit.ListResource, args)
+	rows, err := repository.db.QueryContext(ctx, "SELECT "+columns+" FROM audit_logs WHERE TRUE"+conditions+order+";", args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries, err := scanEntries(rows)
+	return entries, total, err
+}
+
+func (repository *auditRepository) RetrieveByID(ctx context.Context, id int64) (auditDto.Entry, error) {
+	return scanEntry(repository.db.QueryRowContext(ctx, "SELECT "+columns+" FROM audit_logs WHERE id = $1;", id))
+}
+
+// RetrieveChain returns up to limit entries after the one with afterID, in the
+// order they were chained.
+func (repository *auditRepository) RetrieveChain(ctx context.Context, afterID int64, limit int) ([]auditDto.Entry, error) {
+	rows, err := repository.db.QueryContext(ctx, "SELECT "+columns+" FROM audit_logs WHERE id > $1 ORDER BY id LIMIT $2;", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEntries(rows)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEntry(row scanner) (auditDto.Entry, error) {
+	var entry auditDto.Entry
+	var before, after, diff []byte
+	err := row.Scan(&entry.ID, &entry.ActorID, &entry.ActorUsername, &entry.ActorRole, &entry.Action, &entry.Route,
+		&entry.Entity, &entry.EntityID, &before, &after, &diff, &entry.Status, &entry.IP, &entry.RequestID,
+		&entry.CreatedAt, &entry.PrevHash, &entry.Hash)
+	entry.Before, entry.After, entry.Diff = before, after, diff
+	return entry, err
+}
+
+func scanEntries(rows *sql.Rows) ([]auditDto.Entry, error) {
+	entries := []auditDto.Entry{}
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// jsonb is NULL for an empty value.
+func jsonb(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
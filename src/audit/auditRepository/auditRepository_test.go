@@ -0,0 +1,153 @@
+package auditRepository
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type auditRepositoryTestSuite struct {
+	suite.Suite
+	auditRepo audit.AuditRepository
+	mock      sqlmock.Sqlmock
+}
+
+func (suite *auditRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.auditRepo = NewAuditRepository(db)
+	suite.mock = mock
+}
+
+var entryColumns = []string{"id", "actor_id", "actor_username", "actor_role", "action", "route", "entity", "entity_id", "before", "after", "diff",
+	"status", "ip", "request_id", "created_at", "prev_hash", "hash"}
+
+var entry = auditDto.Entry{
+	ActorID:       "u1",
+	ActorUsername: "admin",
+	ActorRole:     "ADMIN",
+	Action:        constants.AuditUpdate,
+	Route:         "PUT /api/v1/actions/:id",
+	Entity:        "actions",
+	EntityID:      "a1",
+	Before:        json.RawMessage(`{"price":20000}`),
+	After:         json.RawMessage(`{"price":25000}`),
+	Diff:          json.RawMessage(`{"price":{"from":20000,"to":25000}}`),
+	Status:        200,
+	IP:            "10.0.0.1",
+	RequestID:     "r1",
+	CreatedAt:     "2026-10-19 09:30:00.000000",
+}
+
+func (suite *auditRepositoryTestSuite) TestAppendChainsToLastEntry() {
+	expected := entry
+	expected.ID, expected.PrevHash = 8, "abc"
+	expected.Hash = utils.AuditHash(expected)
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`SELECT hash FROM audit_logs ORDER BY id DESC`).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("abc"))
+	suite.mock.ExpectQuery(`SELECT nextval\('audit_logs_id_seq'\)`).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(8))
+	suite.mock.ExpectExec(`INSERT INTO audit_logs`).
+		WithArgs(int64(8), "u1", "admin", "ADMIN", constants.AuditUpdate, "PUT /api/v1/actions/:id", "actions", "a1",
+			`{"price":20000}`, `{"price":25000}`, `{"price":{"from":20000,"to":25000}}`,
+			200, "10.0.0.1", "r1", "2026-10-19 09:30:00.000000", "abc", expected.Hash).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.auditRepo.Append(context.Background(), entry)
+
+	suite.Nil(err)
+	suite.Equal(expected, actual)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *auditRepositoryTestSuite) TestAppendFirstEntry() {
+	read := auditDto.Entry{Action: constants.AuditRead, Route: "GET /api/v1/medical-records/:id", Entity: "medical-records", Status: 200}
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`SELECT hash FROM audit_logs`).WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectQuery(`SELECT nextval`).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(1))
+	suite.mock.ExpectExec(`INSERT INTO audit_logs`).
+		WithArgs(int64(1), "", "", "", constants.AuditRead, read.Route, "medical-records", "", nil, nil, nil,
+			200, "", "", "", constants.AuditGenesisHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.auditRepo.Append(context.Background(), read)
+
+	suite.Nil(err)
+	suite.Equal(constants.AuditGenesisHash, actual.PrevHash)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *auditRepositoryTestSuite) TestAppendInsertError() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`SELECT hash FROM audit_logs`).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("abc"))
+	suite.mock.ExpectQuery(`SELECT nextval`).WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(8))
+	suite.mock.ExpectExec(`INSERT INTO audit_logs`).WillReturnError(errors.New("connection lost"))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.auditRepo.Append(context.Background(), entry)
+
+	suite.EqualError(err, "connection lost")
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *auditRepositoryTestSuite) TestRetrieveAllFiltered() {
+	query := queryDto.Query{Page: 2, Size: 10, Sort: audit.ListResource.DefaultSort, Filters: []queryDto.Filter{{Field: "entity", Value: "actions"}}}
+
+	suite.mock.ExpectQuery(`SELECT COUNT\(\*\) FROM audit_logs WHERE TRUE AND entity::text = \$1;`).
+		WithArgs("actions").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	suite.mock.ExpectQuery(`FROM audit_logs WHERE TRUE AND entity::text = \$1 ORDER BY id DESC, id LIMIT \$2 OFFSET \$3;`).
+		WithArgs("actions", 10, 10).
+		WillReturnRows(sqlmock.NewRows(entryColumns).AddRow(3, "u1", "admin", "ADMIN", "update", entry.Route, "actions", "a1",
+			[]byte(`{"price": 20000}`), nil, nil, 200, "10.0.0.1", "r1", entry.CreatedAt, "abc", "def"))
+
+	entries, total, err := suite.auditRepo.RetrieveAll(context.Background(), query)
+
+	suite.Nil(err)
+	suite.Equal(11, total)
+	suite.Len(entries, 1)
+	suite.Equal(json.RawMessage(`{"price": 20000}`), entries[0].Before)
+	suite.Nil(entries[0].After)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *auditRepositoryTestSuite) TestRetrieveByIDNotFound() {
+	suite.mock.ExpectQuery(`FROM audit_logs WHERE id = \$1;`).WithArgs(int64(9)).WillReturnError(sql.ErrNoRows)
+
+	_, err := suite.auditRepo.RetrieveByID(context.Background(), 9)
+
+	suite.Equal(sql.ErrNoRows, err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *auditRepositoryTestSuite) TestRetrieveChain() {
+	suite.mock.ExpectQuery(`FROM audit_logs WHERE id > \$1 ORDER BY id LIMIT \$2;`).
+		WithArgs(int64(500), 500).
+		WillReturnRows(sqlmock.NewRows(entryColumns))
+
+	entries, err := suite.auditRepo.RetrieveChain(context.Background(), 500, 500)
+
+	suite.Nil(err)
+	suite.Empty(entries)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func TestAuditRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(auditRepositoryTestSuite))
+}
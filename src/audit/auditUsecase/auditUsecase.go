@@ -0,0 +1,146 @@
+package auditUsecase
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// verifyBatch is how many entries are read at once while verifying the chain.
+const verifyBatch = 500
+
+type auditUsecase struct {
+	auditRepo audit.AuditRepository
+}
+
+func NewAuditUsecase(auditRepo audit.AuditRepository) audit.AuditUsecase {
+	return &auditUsecase{auditRepo}
+}
+
+// Record appends an entry to the audit log, keeping the record before and
+// after the change, if given, and the fields that changed.
+func (usecase *auditUsecase) Record(ctx context.Context, entry auditDto.Entry, before, after interface{}) error {
+	entry.CreatedAt = time.Now().Format(utils.AuditTimeFormat)
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+	if entry.Diff, err = diff(entry.Before, entry.After); err != nil {
+		return err
+	}
+
+	_, err = usecase.auditRepo.Append(ctx, entry)
+	return err
+}
+
+func (usecase *auditUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]auditDto.Entry, queryDto.Paging, error) {
+	entries, total, err := usecase.auditRepo.RetrieveAll(ctx, query)
+	return entries, utils.NewPaging(query, total), err
+}
+
+func (usecase *auditUsecase) GetByID(ctx context.Context, id int64) (auditDto.Entry, error) {
+	return usecase.auditRepo.RetrieveByID(ctx, id)
+}
+
+// Verify walks the whole chain and recomputes every hash. It stops at the
+// first entry that was changed, or that does not follow the entry before it.
+func (usecase *auditUsecase) Verify(ctx context.Context) (auditDto.Verification, error) {
+	verification := auditDto.Verification{Valid: true}
+	prevHash, lastID := constants.AuditGenesisHash, int64(0)
+	for {
+		entries, err := usecase.auditRepo.RetrieveChain(ctx, lastID, verifyBatch)
+		if err != nil {
+			return auditDto.Verification{}, err
+		}
+
+		for _, entry := range entries {
+			verification.Checked++
+			if entry.PrevHash != prevHash {
+				verification.Valid, verification.BrokenAt, verification.Reason = false, entry.ID, constants.AuditReasonUnchained
+				return verification, nil
+			}
+
+			// JSONB does not keep the JSON as it was written
+			for _, value := range []*json.RawMessage{&entry.Before, &entry.After, &entry.Diff} {
+				if *value, err = utils.CanonicalJSON(*value); err != nil {
+					return auditDto.Verification{}, err
+				}
+			}
+			if utils.AuditHash(entry) != entry.Hash {
+				verification.Valid, verification.BrokenAt, verification.Reason = false, entry.ID, constants.AuditReasonTampered
+				return verification, nil
+			}
+			prevHash, lastID = entry.Hash, entry.ID
+		}
+
+		if len(entries) < verifyBatch {
+			return verification, nil
+		}
+	}
+}
+
+// snapshot is a record as canonical JSON, empty without a record.
+func snapshot(record interface{}) (json.RawMessage, error) {
+	if record == nil {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return utils.CanonicalJSON(value)
+}
+
+// diff lists the top level fields whose values differ between two records.
+// A created record changes every field from null and a deleted one every field
+// to null. There is no diff when neither record is an object.
+func diff(before, after json.RawMessage) (json.RawMessage, error) {
+	beforeFields, afterFields := fields(before), fields(after)
+	if beforeFields == nil && afterFields == nil {
+		return nil, nil
+	}
+
+	changes := map[string]auditDto.Change{}
+	for name, from := range beforeFields {
+		if to := afterFields[name]; !bytes.Equal(from, to) {
+			changes[name] = auditDto.Change{From: from, To: orNull(to)}
+		}
+	}
+	for name, to := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = auditDto.Change{From: orNull(nil), To: to}
+		}
+	}
+
+	value, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	return utils.CanonicalJSON(value)
+}
+
+func fields(record json.RawMessage) map[string]json.RawMessage {
+	var values map[string]json.RawMessage
+	if json.Unmarshal(record, &values) != nil {
+		return nil
+	}
+	return values
+}
+
+func orNull(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
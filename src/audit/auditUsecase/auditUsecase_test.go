@@ -0,0 +1,206 @@
+package auditUsecase
+
+import (
+	"avengers-clinic/model/dto/auditDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/audit"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *mockAuditRepository) Append(ctx context.Context, entry auditDto.Entry) (auditDto.Entry, error) {
+	args := m.Called(entry)
+	return args.Get(0).(auditDto.Entry), args.Error(1)
+}
+
+func (m *mockAuditRepository) RetrieveAll(ctx context.Context, query queryDto.Query) ([]auditDto.Entry, int, error) {
+	args := m.Called(query)
+	return args.Get(0).([]auditDto.Entry), args.Int(1), args.Error(2)
+}
+
+func (m *mockAuditRepository) RetrieveByID(ctx context.Context, id int64) (auditDto.Entry, error) {
+	args := m.Called(id)
+	return args.Get(0).(auditDto.Entry), args.Error(1)
+}
+
+func (m *mockAuditRepository) RetrieveChain(ctx context.Context, afterID int64, limit int) ([]auditDto.Entry, error) {
+	args := m.Called(afterID, limit)
+	return args.Get(0).([]auditDto.Entry), args.Error(1)
+}
+
+type auditUsecaseTestSuite struct {
+	suite.Suite
+	auditRepo *mockAuditRepository
+	auditUC   audit.AuditUsecase
+}
+
+func (suite *auditUsecaseTestSuite) SetupTest() {
+	suite.auditRepo = new(mockAuditRepository)
+	suite.auditUC = NewAuditUsecase(suite.auditRepo)
+}
+
+type record struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+	Stock int    `json:"stock"`
+}
+
+func (suite *auditUsecaseTestSuite) TestRecordChange() {
+	var appended auditDto.Entry
+	suite.auditRepo.On("Append", mock.Anything).Run(func(args mock.Arguments) {
+		appended = args.Get(0).(auditDto.Entry)
+	}).Return(auditDto.Entry{}, nil)
+
+	err := suite.auditUC.Record(context.Background(), auditDto.Entry{Action: constants.AuditUpdate, Entity: "medicines", EntityID: "m1"},
+		record{Name: "Paracetamol", Price: 5000, Stock: 10}, record{Name: "Paracetamol", Price: 6000, Stock: 10})
+
+	suite.Nil(err)
+	suite.Equal(`{"name":"Paracetamol","price":5000,"stock":10}`, string(appended.Before))
+	suite.Equal(`{"name":"Paracetamol","price":6000,"stock":10}`, string(appended.After))
+	suite.Equal(`{"price":{"from":5000,"to":6000}}`, string(appended.Diff))
+	suite.Len(appended.CreatedAt, len(utils.AuditTimeFormat))
+}
+
+func (suite *auditUsecaseTestSuite) TestRecordCreate() {
+	var appended auditDto.Entry
+	suite.auditRepo.On("Append", mock.Anything).Run(func(args mock.Arguments) {
+		appended = args.Get(0).(auditDto.Entry)
+	}).Return(auditDto.Entry{}, nil)
+
+	err := suite.auditUC.Record(context.Background(), auditDto.Entry{Action: constants.AuditCreate}, nil, record{Name: "Amoxicillin", Price: 8000})
+
+	suite.Nil(err)
+	suite.Nil(appended.Before)
+	suite.Equal(`{"name":{"from":null,"to":"Amoxicillin"},"price":{"from":null,"to":8000},"stock":{"from":null,"to":0}}`, string(appended.Diff))
+}
+
+func (suite *auditUsecaseTestSuite) TestRecordRead() {
+	var appended auditDto.Entry
+	suite.auditRepo.On("Append", mock.Anything).Run(func(args mock.Arguments) {
+		appended = args.Get(0).(auditDto.Entry)
+	}).Return(auditDto.Entry{}, nil)
+
+	err := suite.auditUC.Record(context.Background(), auditDto.Entry{Action: constants.AuditRead, Entity: "medical-records", EntityID: "r1"}, nil, nil)
+
+	suite.Nil(err)
+	suite.Nil(appended.Before)
+	suite.Nil(appended.After)
+	suite.Nil(appended.Diff)
+}
+
+func (suite *auditUsecaseTestSuite) TestRecordError() {
+	suite.auditRepo.On("Append", mock.Anything).Return(auditDto.Entry{}, errors.New("connection lost"))
+
+	err := suite.auditUC.Record(context.Background(), auditDto.Entry{Action: constants.AuditDelete}, record{Name: "Paracetamol"}, nil)
+
+	suite.EqualError(err, "connection lost")
+}
+
+func (suite *auditUsecaseTestSuite) TestGetAll() {
+	query := queryDto.Query{Page: 1, Size: 10}
+	suite.auditRepo.On("RetrieveAll", query).Return([]auditDto.Entry{{ID: 1}}, 21, nil)
+
+	entries, paging, err := suite.auditUC.GetAll(context.Background(), query)
+
+	suite.Nil(err)
+	suite.Len(entries, 1)
+	suite.Equal(queryDto.Paging{Page: 1, Size: 10, TotalRows: 21, TotalPages: 3}, paging)
+}
+
+// chain hashes the entries as they would be appended. Their JSON is written as
+// JSONB gives it back, with spaces.
+func chain(entries ...auditDto.Entry) []auditDto.Entry {
+	prevHash := constants.AuditGenesisHash
+	for i := range entries {
+		entries[i].ID, entries[i].PrevHash = int64(i+1), prevHash
+
+		hashed := entries[i]
+		for _, value := range []*json.RawMessage{&hashed.Before, &hashed.After, &hashed.Diff} {
+			*value, _ = utils.CanonicalJSON(*value)
+		}
+		entries[i].Hash = utils.AuditHash(hashed)
+		prevHash = entries[i].Hash
+	}
+	return entries
+}
+
+func (suite *auditUsecaseTestSuite) TestVerifyValid() {
+	entries := chain(
+		auditDto.Entry{Action: constants.AuditRead, Entity: "medical-records", EntityID: "r1", Status: 200},
+		auditDto.Entry{Action: constants.AuditUpdate, Entity: "medicines", EntityID: "m1", Status: 200,
+			Before: json.RawMessage(`{"price": 5000, "name": "Paracetamol"}`), After: json.RawMessage(`{"price": 6000, "name": "Paracetamol"}`)},
+	)
+	suite.auditRepo.On("RetrieveChain", int64(0), verifyBatch).Return(entries, nil)
+
+	verification, err := suite.auditUC.Verify(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(auditDto.Verification{Valid: true, Checked: 2}, verification)
+}
+
+func (suite *auditUsecaseTestSuite) TestVerifyTampered() {
+	entries := chain(
+		auditDto.Entry{Action: constants.AuditUpdate, Entity: "invoices", EntityID: "i1", Status: 200, After: json.RawMessage(`{"total": 100000}`)},
+		auditDto.Entry{Action: constants.AuditRead, Entity: "medical-records", EntityID: "r1", Status: 200},
+	)
+	entries[0].After = json.RawMessage(`{"total": 10000}`)
+	suite.auditRepo.On("RetrieveChain", int64(0), verifyBatch).Return(entries, nil)
+
+	verification, err := suite.auditUC.Verify(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(auditDto.Verification{Valid: false, Checked: 1, BrokenAt: 1, Reason: constants.AuditReasonTampered}, verification)
+}
+
+func (suite *auditUsecaseTestSuite) TestVerifyRemoved() {
+	entries := chain(
+		auditDto.Entry{Action: constants.AuditRead, Entity: "medical-records", EntityID: "r1", Status: 200},
+		auditDto.Entry{Action: constants.AuditDelete, Entity: "users", EntityID: "u2", Status: 200},
+		auditDto.Entry{Action: constants.AuditRead, Entity: "medical-records", EntityID: "r2", Status: 200},
+	)
+	suite.auditRepo.On("RetrieveChain", int64(0), verifyBatch).Return([]auditDto.Entry{entries[0], entries[2]}, nil)
+
+	verification, err := suite.auditUC.Verify(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(auditDto.Verification{Valid: false, Checked: 2, BrokenAt: 3, Reason: constants.AuditReasonUnchained}, verification)
+}
+
+func (suite *auditUsecaseTestSuite) TestVerifyReadsInBatches() {
+	entries := make([]auditDto.Entry, verifyBatch+1)
+	for i := range entries {
+		entries[i] = auditDto.Entry{Action: constants.AuditRead, Entity: "fhir", Status: 200}
+	}
+	entries = chain(entries...)
+	suite.auditRepo.On("RetrieveChain", int64(0), verifyBatch).Return(entries[:verifyBatch], nil)
+	suite.auditRepo.On("RetrieveChain", int64(verifyBatch), verifyBatch).Return(entries[verifyBatch:], nil)
+
+	verification, err := suite.auditUC.Verify(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(auditDto.Verification{Valid: true, Checked: verifyBatch + 1}, verification)
+	suite.auditRepo.AssertExpectations(suite.T())
+}
+
+func (suite *auditUsecaseTestSuite) TestVerifyError() {
+	suite.auditRepo.On("RetrieveChain", int64(0), verifyBatch).Return([]auditDto.Entry{}, errors.New("connection lost"))
+
+	_, err := suite.auditUC.Verify(context.Background())
+
+	suite.EqualError(err, "connection lost")
+}
+
+func TestAuditUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(auditUsecaseTestSuite))
+}
@@ -36,14 +36,20 @@ func NewBookingDelivery(v1Group *gin.RouterGroup, bookingUC booking.BookingUseca
 
 func (bd bookingDelivery) GetAll(ctx *gin.Context) {
 
+	query, invalid := utils.ParseQuery(ctx, booking.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(ctx, invalid, "Bad request", constants.BookingService, "01")
+		return
+	}
+
 	//Get the datas
-	data, err := bd.bookingUC.GetAll()
+	data, paging, err := bd.bookingUC.GetAll(ctx.Request.Context(), query)
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.BookingService, "01")
 		return
 	}
 
-	json.NewResponseSuccess(ctx, data, "success", constants.BookingService, "01")
+	json.NewResponsePaging(ctx, data, paging, "success", constants.BookingService, "01")
 }
 
 func (bd bookingDelivery) GetByID(ctx *gin.Context) {
@@ -56,7 +62,7 @@ func (bd bookingDelivery) GetByID(ctx *gin.Context) {
 	}
 
 	//Get data
-	data, err := bd.bookingUC.GetOneByID(id)
+	data, err := bd.bookingUC.GetOneByID(ctx.Request.Context(), id)
 
 	//validating error
 	if err != nil && err == sql.ErrNoRows {
@@ -67,6 +73,7 @@ func (bd bookingDelivery) GetByID(ctx *gin.Context) {
 		return
 	}
 
+	utils.SetETag(ctx, data.Version)
 	json.NewResponseSuccess(ctx, data, "success", constants.BookingService, "01")
 
 }
@@ -83,7 +90,7 @@ func (bd bookingDelivery) GetByScheduleID(ctx *gin.Context) {
 	status := ctx.Query("status")
 
 	//Get data
-	data, err := bd.bookingUC.GetBookingByScheduleID(schedID, status)
+	data, err := bd.bookingUC.GetBookingByScheduleID(ctx.Request.Context(), schedID, status)
 
 	//validating error
 	if err != nil && err == sql.ErrNoRows {
@@ -114,7 +121,7 @@ func (bd bookingDelivery) Create(ctx *gin.Context) {
 	}
 
 	//Create booking
-	data, err := bd.bookingUC.Create(input)
+	data, err := bd.bookingUC.Create(ctx.Request.Context(), input)
 	//if create failed, it return err no rows
 	//because we do use validation create where not exist
 	//and returnin ID
@@ -154,15 +161,24 @@ func (bd bookingDelivery) EditSchedule(ctx *gin.Context) {
 		return
 	}
 
-	data, err := bd.bookingUC.EditSchedule(id, input)
+	var ok bool
+	if input.Version, ok = bd.ifMatch(ctx); !ok {
+		return
+	}
+
+	data, err := bd.bookingUC.EditSchedule(ctx.Request.Context(), id, input)
 	if err != nil && (err == sql.ErrNoRows || err.Error() == constants.ErrScheduleTaken) {
 		json.NewResponseBadRequest(ctx, nil, constants.ErrScheduleTaken, constants.BookingService, "01")
 		return
+	} else if err != nil && err.Error() == constants.ErrVersionConflict {
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.BookingService, "03")
+		return
 	} else if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.BookingService, "01")
 		return
 	}
 
+	utils.SetETag(ctx, data.Version)
 	json.NewResponseCreated(ctx, data, "success", constants.BookingService, "01")
 }
 
@@ -190,15 +206,24 @@ func (bd bookingDelivery) Done(ctx *gin.Context) {
 	// 	return
 	// }
 
-	data, err := bd.bookingUC.FinishBooking(id)
+	version, ok := bd.ifMatch(ctx)
+	if !ok {
+		return
+	}
+
+	data, err := bd.bookingUC.FinishBooking(ctx.Request.Context(), id, version)
 	if err != nil && err == sql.ErrNoRows {
 		json.NewResponseBadRequest(ctx, nil, "data not found", constants.BookingService, "01")
 		return
+	} else if err != nil && err.Error() == constants.ErrVersionConflict {
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.BookingService, "03")
+		return
 	} else if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.BookingService, "01")
 		return
 	}
 
+	utils.SetETag(ctx, data.Version)
 	json.NewResponseCreated(ctx, data, "success", constants.BookingService, "01")
 }
 
@@ -209,13 +234,38 @@ func (bd bookingDelivery) Cancel(ctx *gin.Context) {
 		return
 	}
 
-	data, err := bd.bookingUC.Cancel(id)
+	version, ok := bd.ifMatch(ctx)
+	if !ok {
+		return
+	}
+
+	data, err := bd.bookingUC.Cancel(ctx.Request.Context(), id, version)
 	if err != nil && err == sql.ErrNoRows {
 		json.NewResponseBadRequest(ctx, nil, "data not found", constants.BookingService, "01")
 		return
+	} else if err != nil && err.Error() == constants.ErrVersionConflict {
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.BookingService, "03")
+		return
 	} else if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.BookingService, "01")
 		return
 	}
+	utils.SetETag(ctx, data.Version)
 	json.NewResponseCreated(ctx, data, "canceled", constants.BookingService, "01")
 }
+
+// ifMatch reads the version the change is made against from the If-Match
+// header, answering the request itself when the header is missing or invalid.
+func (bd bookingDelivery) ifMatch(ctx *gin.Context) (int, bool) {
+	version, err := utils.IfMatch(ctx)
+	if err == nil {
+		return version, true
+	}
+
+	if err.Error() == constants.ErrIfMatchRequired {
+		json.NewResponsePreconditionRequired(ctx, err.Error(), constants.BookingService, "02")
+		return 0, false
+	}
+	json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: constants.IfMatchHeader, Message: err.Error()}}, "Bad request", constants.BookingService, "02")
+	return 0, false
+}
@@ -2,30 +2,32 @@ package booking
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/entity"
+	"context"
 
 	"github.com/google/uuid"
 )
 
 type (
 	BookingRepository interface {
-		GetAllBooking() ([]entity.Bookings, error)
-		GetOneByID(id uuid.UUID) (entity.Bookings, error)
-		GetBookingByScheduleID(scheduleId uuid.UUID, status []string) ([]entity.Bookings, error)
-		CreateBooking(input entity.Bookings) (entity.Bookings, error)
-		CheckExist(doctorScheduleID uuid.UUID, mstScheduleID int) bool
-		EditSchedule(id uuid.UUID, input entity.Bookings) error
-		CancelBooking(id uuid.UUID) error
-		FinishBooking(id uuid.UUID) error
+		GetAllBooking(ctx context.Context, query queryDto.Query) ([]entity.Bookings, int, error)
+		GetOneByID(ctx context.Context, id uuid.UUID) (entity.Bookings, error)
+		GetBookingByScheduleID(ctx context.Context, scheduleId uuid.UUID, status []string) ([]entity.Bookings, error)
+		CreateBooking(ctx context.Context, input entity.Bookings) (entity.Bookings, error)
+		CheckExist(ctx context.Context, doctorScheduleID uuid.UUID, mstScheduleID int) bool
+		EditSchedule(ctx context.Context, id uuid.UUID, input entity.Bookings) (int, error)
+		CancelBooking(ctx context.Context, id uuid.UUID, version int) error
+		FinishBooking(ctx context.Context, id uuid.UUID, version int) error
 	}
 
 	BookingUsecase interface {
-		GetAll() ([]entity.Bookings, error)
-		GetOneByID(id uuid.UUID) (entity.Bookings, error)
-		GetBookingByScheduleID(scheduleId uuid.UUID, status string) ([]entity.Bookings, error)
-		Create(input dto.CreateBooking) (entity.Bookings, error)
-		EditSchedule(id uuid.UUID, input dto.UpdateBookingSchedule) (entity.Bookings, error)
-		Cancel(id uuid.UUID) (entity.Bookings, error)
-		FinishBooking(id uuid.UUID) (entity.Bookings, error)
+		GetAll(ctx context.Context, query queryDto.Query) ([]entity.Bookings, queryDto.Paging, error)
+		GetOneByID(ctx context.Context, id uuid.UUID) (entity.Bookings, error)
+		GetBookingByScheduleID(ctx context.Context, scheduleId uuid.UUID, status string) ([]entity.Bookings, error)
+		Create(ctx context.Context, input dto.CreateBooking) (entity.Bookings, error)
+		EditSchedule(ctx context.Context, id uuid.UUID, input dto.UpdateBookingSchedule) (entity.Bookings, error)
+		Cancel(ctx context.Context, id uuid.UUID, version int) (entity.Bookings, error)
+		FinishBooking(ctx context.Context, id uuid.UUID, version int) (entity.Bookings, error)
 	}
 )
\ No newline at end of file
@@ -0,0 +1,21 @@
+package booking
+
+import "avengers-clinic/model/dto/queryDto"
+
+// ListResource is what the list of bookings can be filtered and sorted by.
+// The date range bounds the day the booking is for.
+var ListResource = queryDto.Resource{
+	Filters: map[string]string{
+		"status":  "b.status",
+		"doctor":  "ds.doctor_id",
+		"patient": "b.patient_id",
+	},
+	Sorts: map[string]string{
+		"schedule_date": "ds.schedule_date",
+		"status":        "b.status",
+		"created_at":    "b.created_at",
+	},
+	DateColumn:  "ds.schedule_date",
+	DefaultSort: []queryDto.Sort{{Field: "created_at"}},
+	Key:         "b.id",
+}
@@ -1,10 +1,15 @@
 package bookingRepository
 
 import (
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/entity"
 	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/booking"
+	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -20,8 +25,30 @@ func NewBookingRepository(db *sql.DB) booking.BookingRepository {
 	}
 }
 
+// conn returns the transaction of the unit of work ctx belongs to, or the
+// database outside of one.
+func (br bookingRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.Executor(ctx, br.db)
+}
+
+
+// GetAllBooking returns a page of bookings and how many bookings match the
+// query in all. The doctor filter and the date range go through the schedule
+// the booking is for.
+func (br bookingRepository) GetAllBooking(ctx context.Context, query queryDto.Query) ([]entity.Bookings, int, error) {
+	conditions, args := utils.ListConditions(query, booking.ListResource, nil)
 
-func (br bookingRepository) GetAllBooking() ([]entity.Bookings, error) {
+	var total int
+	countstat := `
+		SELECT COUNT(*)
+		FROM bookings b
+		JOIN doctor_schedules ds ON ds.id = b.doctor_schedule_id
+		WHERE b.deleted_at IS NULL` + conditions + ";"
+	if err := br.conn(ctx).QueryRowContext(ctx, countstat, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order, args := utils.ListOrder(query, booking.ListResource, args)
 	sqlstat := `
 		SELECT 
 				b.id, 
@@ -30,27 +57,28 @@ func (br bookingRepository) GetAllBooking() ([]entity.Bookings, error) {
 				b.mst_schedule_id, 
 				b.complaint, 
 				b.status, 
+				b.version, 
 				s.id, 
 				to_char(s.start_at, 'HH24:MI:SS'), 
 				to_char(s.end_at, 'HH24:MI:SS')
 		FROM bookings b 
+		JOIN doctor_schedules ds ON ds.id = b.doctor_schedule_id
 		LEFT JOIN mst_schedule_time s ON s.id = b.mst_schedule_id 
-		WHERE b.deleted_at IS NULL ORDER BY b.created_at, b.mst_schedule_id;`
-
+		WHERE b.deleted_at IS NULL` + conditions + order + ";"
 
-	rows, err := br.db.Query(sqlstat)
+	rows, err := br.conn(ctx).QueryContext(ctx, sqlstat, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	data, err := scanBookingRows(rows)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return data, nil
+	return data, total, nil
 }
 
-func (br bookingRepository) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
+func (br bookingRepository) GetOneByID(ctx context.Context, id uuid.UUID) (entity.Bookings, error) {
 	var book entity.Bookings
 	sqlstat := `
 		SELECT 
@@ -60,6 +88,7 @@ func (br bookingRepository) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
 				b.mst_schedule_id, 
 				b.complaint, 
 				b.status, 
+				b.version, 
 				s.id, 
 				to_char(s.start_at, 'HH24:MI:SS'), 
 				to_char(s.end_at, 'HH24:MI:SS')
@@ -67,13 +96,14 @@ func (br bookingRepository) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
 			LEFT JOIN mst_schedule_time s ON s.id = b.mst_schedule_id 
 		WHERE b.id = $1 AND b.deleted_at IS NULL;`
 
-	err := br.db.QueryRow(sqlstat, id).Scan(
+	err := br.conn(ctx).QueryRowContext(ctx, sqlstat, id).Scan(
 		&book.ID,
 		&book.DoctorScheduleID,
 		&book.PatientID,
 		&book.MstScheduleID,
 		&book.Complaint,
 		&book.Status,
+		&book.Version,
 		&book.ScheduleTime.ID,
 		&book.ScheduleTime.StartAt,
 		&book.ScheduleTime.EndAt,
@@ -87,7 +117,7 @@ func (br bookingRepository) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
 
 
 
-func (br bookingRepository) GetBookingByScheduleID(scheduleID uuid.UUID, status []string) ([]entity.Bookings, error) {
+func (br bookingRepository) GetBookingByScheduleID(ctx context.Context, scheduleID uuid.UUID, status []string) ([]entity.Bookings, error) {
 	var rows *sql.Rows
 	var err error
 
@@ -99,6 +129,7 @@ func (br bookingRepository) GetBookingByScheduleID(scheduleID uuid.UUID, status
 				b.mst_schedule_id, 
 				b.complaint, 
 				b.status, 
+				b.version, 
 				s.id, 
 				to_char(s.start_at, 'HH24:MI:SS'), 
 				to_char(s.end_at, 'HH24:MI:SS')
@@ -109,9 +140,9 @@ func (br bookingRepository) GetBookingByScheduleID(scheduleID uuid.UUID, status
 	orderStmt := "ORDER BY b.mst_schedule_id ASC;"
 	if len(status) > 0 {
 		sqlstat += "AND b.status = ANY($2) " + orderStmt
-		rows, err = br.db.Query(sqlstat, scheduleID, pq.Array(status))
+		rows, err = br.conn(ctx).QueryContext(ctx, sqlstat, scheduleID, pq.Array(status))
 	}else {
-		rows, err = br.db.Query(sqlstat+orderStmt, scheduleID)
+		rows, err = br.conn(ctx).QueryContext(ctx, sqlstat+orderStmt, scheduleID)
 	}
 	if err != nil {
 		return nil, err
@@ -123,7 +154,7 @@ func (br bookingRepository) GetBookingByScheduleID(scheduleID uuid.UUID, status
 	return data, nil
 }
 
-func (br bookingRepository) CreateBooking(input entity.Bookings) (entity.Bookings, error) {
+func (br bookingRepository) CreateBooking(ctx context.Context, input entity.Bookings) (entity.Bookings, error) {
 	sqlstat := `
 	
 	INSERT INTO bookings(doctor_schedule_id, patient_id, mst_schedule_id, complaint, status)
@@ -133,7 +164,7 @@ func (br bookingRepository) CreateBooking(input entity.Bookings) (entity.Booking
 		)
 	RETURNING id;`
 
-	err := br.db.QueryRow(sqlstat, 
+	err := br.conn(ctx).QueryRowContext(ctx, sqlstat, 
 		input.DoctorScheduleID, 
 		input.PatientID, 
 		input.MstScheduleID, 
@@ -147,45 +178,63 @@ func (br bookingRepository) CreateBooking(input entity.Bookings) (entity.Booking
 	return input, nil
 }
 
-func (br bookingRepository) EditSchedule(id uuid.UUID, input entity.Bookings) error {
-	sqlstat := "UPDATE bookings SET doctor_schedule_id = $1, mst_schedule_id = $2, complaint = $3 WHERE id = $4"
-	_, err := br.db.Exec(sqlstat, 
+// EditSchedule moves the booking and returns its new version. With a version
+// given in the input the booking is only moved while it is still at it.
+func (br bookingRepository) EditSchedule(ctx context.Context, id uuid.UUID, input entity.Bookings) (int, error) {
+	sqlstat := `
+		UPDATE bookings SET doctor_schedule_id = $1, mst_schedule_id = $2, complaint = $3, version = version + 1
+		WHERE id = $4 AND ($5 = 0 OR version = $5) RETURNING version;`
+	var version int
+	err := br.conn(ctx).QueryRowContext(ctx, sqlstat, 
 		input.DoctorScheduleID, 
 		input.MstScheduleID, 
 		input.Complaint, 
 		id,
-		)		
+		input.Version,
+		).Scan(&version)
+	if err == sql.ErrNoRows && input.Version != 0 {
+		return 0, errors.New(constants.ErrVersionConflict)
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return version, nil
 }
 
-func (br bookingRepository) CancelBooking(id uuid.UUID) error {
-	sqlstat := "UPDATE bookings SET status = $1 WHERE id = $2;"
-	_, err := br.db.Exec(sqlstat, constants.Canceled, id)
-	if err != nil {
-		return err
-	}
+// CancelBooking cancels the booking while it is still at the version, a
+// version of 0 cancels any version.
+func (br bookingRepository) CancelBooking(ctx context.Context, id uuid.UUID, version int) error {
+	return br.setStatus(ctx, id, constants.Canceled, version)
+}
 
-	return nil
+// FinishBooking marks the booking done while it is still at the version, a
+// version of 0 finishes any version.
+func (br bookingRepository) FinishBooking(ctx context.Context, id uuid.UUID, version int) error {
+	return br.setStatus(ctx, id, constants.Done, version)
 }
 
-func (br bookingRepository) FinishBooking(id uuid.UUID) error {
-	sqlstat := "UPDATE bookings SET status = $1 WHERE id = $2;"
-	_, err := br.db.Exec(sqlstat, constants.Done, id)
+func (br bookingRepository) setStatus(ctx context.Context, id uuid.UUID, status string, version int) error {
+	sqlstat := "UPDATE bookings SET status = $1, version = version + 1 WHERE id = $2 AND ($3 = 0 OR version = $3);"
+	result, err := br.conn(ctx).ExecContext(ctx, sqlstat, status, id, version)
 	if err != nil {
 		return err
 	}
 
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
 	return nil
 }
 
-func (br bookingRepository) CheckExist(doctorScheduleID uuid.UUID, mstScheduleID int) bool {
+func (br bookingRepository) CheckExist(ctx context.Context, doctorScheduleID uuid.UUID, mstScheduleID int) bool {
 	exist := false
 	sqlstat := "SELECT true FROM bookings WHERE doctor_schedule_id = $1 AND mst_schedule_id = $3;"
 
-	_ = br.db.QueryRow(sqlstat, doctorScheduleID, mstScheduleID).Scan(&exist)
+	_ = br.conn(ctx).QueryRowContext(ctx, sqlstat, doctorScheduleID, mstScheduleID).Scan(&exist)
 	return exist
 }
 
@@ -202,6 +251,7 @@ func scanBookingRows(rows *sql.Rows) ([]entity.Bookings, error) {
 			&book.MstScheduleID,
 			&book.Complaint,
 			&book.Status,
+			&book.Version,
 			&book.ScheduleTime.ID,
 			&book.ScheduleTime.StartAt,
 			&book.ScheduleTime.EndAt,
@@ -2,11 +2,14 @@ package bookingUsecase
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/entity"
 	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/booking"
 	"avengers-clinic/src/doctorSchedule"
+	"context"
 	"errors"
 	"fmt"
 
@@ -16,26 +19,28 @@ import (
 type bookingUsecase struct {
 	bookingRepo  booking.BookingRepository
 	scheduleRepo doctorSchedule.DoctorScheduleRepository
+	txManager    transaction.Manager
 }
 
-func NewBookingUsecase(bookingRepo booking.BookingRepository, scheduleRepo doctorSchedule.DoctorScheduleRepository) booking.BookingUsecase {
+func NewBookingUsecase(bookingRepo booking.BookingRepository, scheduleRepo doctorSchedule.DoctorScheduleRepository, txManager transaction.Manager) booking.BookingUsecase {
 	return &bookingUsecase{
 		bookingRepo,
 		scheduleRepo,
+		txManager,
 	}
 }
 
-func (bu bookingUsecase) GetAll() ([]entity.Bookings, error) {
-	data, err := bu.bookingRepo.GetAllBooking()
+func (bu bookingUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]entity.Bookings, queryDto.Paging, error) {
+	data, total, err := bu.bookingRepo.GetAllBooking(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, queryDto.Paging{}, err
 	}
 
-	return data, nil
+	return data, utils.NewPaging(query, total), nil
 }
 
-func (bu bookingUsecase) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
-	data, err := bu.bookingRepo.GetOneByID(id)
+func (bu bookingUsecase) GetOneByID(ctx context.Context, id uuid.UUID) (entity.Bookings, error) {
+	data, err := bu.bookingRepo.GetOneByID(ctx, id)
 	if err != nil {
 		return data, err
 	}
@@ -43,10 +48,10 @@ func (bu bookingUsecase) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
 	return data, nil
 }
 
-func (bu bookingUsecase) GetBookingByScheduleID(scheduleId uuid.UUID, status string) ([]entity.Bookings, error) {
+func (bu bookingUsecase) GetBookingByScheduleID(ctx context.Context, scheduleId uuid.UUID, status string) ([]entity.Bookings, error) {
 
 	arrStatus := utils.SanitizeStatusQuery(status)
-	data, err := bu.bookingRepo.GetBookingByScheduleID(scheduleId, arrStatus)
+	data, err := bu.bookingRepo.GetBookingByScheduleID(ctx, scheduleId, arrStatus)
 	if err != nil {
 		return nil, err
 	}
@@ -54,92 +59,109 @@ func (bu bookingUsecase) GetBookingByScheduleID(scheduleId uuid.UUID, status str
 	return data, nil
 }
 
-func (bu bookingUsecase) Create(input dto.CreateBooking) (entity.Bookings, error) {
-
-	sched, err := bu.scheduleRepo.RetrieveByID(input.DoctorScheduleID)
+// Create books the schedule time and reads the booking back in one
+// transaction, so a booking that cannot be returned is not kept either.
+func (bu bookingUsecase) Create(ctx context.Context, input dto.CreateBooking) (entity.Bookings, error) {
+	var data entity.Bookings
+	err := bu.txManager.Do(ctx, func(ctx context.Context) error {
+		sched, err := bu.scheduleRepo.RetrieveByID(ctx, input.DoctorScheduleID)
+		if err != nil {
+			return fmt.Errorf(constants.ErrDocSchedNotExist)
+		}
+
+		if input.MstScheduleID > sched.EndAt {
+			return fmt.Errorf(constants.ErrScheduleNotMatch)
+		}
+
+		book := entity.Bookings{
+			DoctorScheduleID: input.DoctorScheduleID,
+			PatientID:        input.PatientID,
+			MstScheduleID:    input.MstScheduleID,
+			Complaint:        input.Complaint,
+			Status:           constants.Waiting,
+		}
+
+		created, err := bu.bookingRepo.CreateBooking(ctx, book)
+		if err != nil {
+			return err
+		}
+
+		data, err = bu.bookingRepo.GetOneByID(ctx, created.ID)
+		return err
+	})
 	if err != nil {
-		return entity.Bookings{}, fmt.Errorf(constants.ErrDocSchedNotExist)
-	}
-
-	if input.MstScheduleID > sched.EndAt {
-		return entity.Bookings{}, fmt.Errorf(constants.ErrScheduleNotMatch)
-	}
-
-	book := entity.Bookings{
-		DoctorScheduleID: input.DoctorScheduleID,
-		PatientID:        input.PatientID,
-		MstScheduleID:    input.MstScheduleID,
-		Complaint:        input.Complaint,
-		Status:           constants.Waiting,
-	}
-
-	data, err := bu.bookingRepo.CreateBooking(book)
-	if err != nil {
-		return data, err
-	}
-
-	data, err = bu.bookingRepo.GetOneByID(data.ID)
-	if err != nil {
-		return data, err
+		return entity.Bookings{}, err
 	}
 
 	return data, nil
-
 }
 
-func (bu bookingUsecase) EditSchedule(id uuid.UUID, input dto.UpdateBookingSchedule) (entity.Bookings, error) {
-	//Find data
-	data, err := bu.bookingRepo.GetOneByID(id)
-	if err != nil {
-		return data, err
-	}
-
-	if input.DoctorScheduleID != uuid.Nil {
-		data.DoctorScheduleID = input.DoctorScheduleID
-	}
-	if input.MstScheduleID > 0 {
-		data.MstScheduleID = input.MstScheduleID
-	}
-	if input.Complaint != "" {
-		data.Complaint = input.Complaint
-	}
-
-	existUpdate := bu.bookingRepo.CheckExist(data.DoctorScheduleID, data.MstScheduleID)
-
-	if existUpdate {
-		return data, errors.New(constants.ErrScheduleTaken)
-	}
-
-	err = bu.bookingRepo.EditSchedule(id, data)
-	if err != nil {
-		return data, err
-	}
-	return data, nil
+func (bu bookingUsecase) EditSchedule(ctx context.Context, id uuid.UUID, input dto.UpdateBookingSchedule) (entity.Bookings, error) {
+	var data entity.Bookings
+	err := bu.txManager.Do(ctx, func(ctx context.Context) error {
+		//Find data
+		var err error
+		data, err = bu.bookingRepo.GetOneByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if input.Version != 0 && input.Version != data.Version {
+			return errors.New(constants.ErrVersionConflict)
+		}
+
+		if input.DoctorScheduleID != uuid.Nil {
+			data.DoctorScheduleID = input.DoctorScheduleID
+		}
+		if input.MstScheduleID > 0 {
+			data.MstScheduleID = input.MstScheduleID
+		}
+		if input.Complaint != "" {
+			data.Complaint = input.Complaint
+		}
+
+		existUpdate := bu.bookingRepo.CheckExist(ctx, data.DoctorScheduleID, data.MstScheduleID)
+
+		if existUpdate {
+			return errors.New(constants.ErrScheduleTaken)
+		}
+
+		data.Version, err = bu.bookingRepo.EditSchedule(ctx, id, data)
+		return err
+	})
+	return data, err
 }
 
-func (bu bookingUsecase) Cancel(id uuid.UUID) (entity.Bookings, error) {
-	data, err := bu.bookingRepo.GetOneByID(id)
+func (bu bookingUsecase) Cancel(ctx context.Context, id uuid.UUID, version int) (entity.Bookings, error) {
+	data, err := bu.bookingRepo.GetOneByID(ctx, id)
 	if err != nil {
 		return data, err
 	}
-	err = bu.bookingRepo.CancelBooking(id)
+	if version != 0 && version != data.Version {
+		return data, errors.New(constants.ErrVersionConflict)
+	}
+	err = bu.bookingRepo.CancelBooking(ctx, id, data.Version)
 	if err != nil {
 		return data, err
 	}
 	data.Status = constants.Canceled
+	data.Version++
 	return data, nil
 }
 
-func (bu bookingUsecase) FinishBooking(id uuid.UUID) (entity.Bookings, error) {
-	data, err := bu.bookingRepo.GetOneByID(id)
+func (bu bookingUsecase) FinishBooking(ctx context.Context, id uuid.UUID, version int) (entity.Bookings, error) {
+	data, err := bu.bookingRepo.GetOneByID(ctx, id)
 	if err != nil {
 		return data, err
 	}
-	err = bu.bookingRepo.FinishBooking(id)
+	if version != 0 && version != data.Version {
+		return data, errors.New(constants.ErrVersionConflict)
+	}
+	err = bu.bookingRepo.FinishBooking(ctx, id, data.Version)
 	if err != nil {
 		return data, err
 	}
 	data.Status = constants.Done
+	data.Version++
 	return data, nil
 }
 
@@ -38,7 +38,7 @@ func (dd doctorScheduleDelivery) GetAll(ctx *gin.Context) {
 
 	startDate := ctx.Query("sd")
 	endDate := ctx.Query("ed")
-	data, err := dd.scheduleUC.GetAll(startDate, endDate)
+	data, err := dd.scheduleUC.GetAll(ctx.Request.Context(), startDate, endDate)
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), "04", "01")
 		return
@@ -56,7 +56,7 @@ func (dd doctorScheduleDelivery) GetByID(ctx *gin.Context) {
 	status := ctx.Query("status")
 
 
-	data, err := dd.scheduleUC.GetByID(id, status)
+	data, err := dd.scheduleUC.GetByID(ctx.Request.Context(), id, status)
 	if err != nil && err == sql.ErrNoRows {
 		json.NewResponseBadRequest(ctx, nil, "data not found", constants.DoctorScheduleService, "01")
 		return
@@ -65,6 +65,7 @@ func (dd doctorScheduleDelivery) GetByID(ctx *gin.Context) {
 		return
 	}
 
+	utils.SetETag(ctx, data.Version)
 	json.NewResponseSuccess(ctx, data, "success", constants.DoctorScheduleService, "01")
 
 }
@@ -80,7 +81,7 @@ func (dd doctorScheduleDelivery) GetMySchedule(ctx *gin.Context) {
 	startDate := ctx.Query("sd")
 	endDate := ctx.Query("ed")
 
-	data, err := dd.scheduleUC.GetMySchedule(doctorId, dayOfWeeks, status, startDate, endDate)
+	data, err := dd.scheduleUC.GetMySchedule(ctx.Request.Context(), doctorId, dayOfWeeks, status, startDate, endDate)
 	if err != nil && err == sql.ErrNoRows {
 		json.NewResponseBadRequest(ctx, nil, "data not found", constants.DoctorScheduleService, "01")
 		return
@@ -111,7 +112,7 @@ func (dd doctorScheduleDelivery) CreateSchedule(ctx *gin.Context) {
 		}
 	}
 
-	data, err := dd.scheduleUC.CreateSchedule(input)
+	data, err := dd.scheduleUC.CreateSchedule(ctx.Request.Context(), input)
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.DoctorScheduleService, "01")
 		return
@@ -139,15 +140,24 @@ func (dd doctorScheduleDelivery) UpdateSchedule(ctx *gin.Context) {
 		return
 	}
 
-	data, err := dd.scheduleUC.UpdateSchedule(id, input)
+	var ok bool
+	if input.Version, ok = dd.ifMatch(ctx); !ok {
+		return
+	}
+
+	data, err := dd.scheduleUC.UpdateSchedule(ctx.Request.Context(), id, input)
 	if err != nil && (err == sql.ErrNoRows || err.Error() == constants.ErrScheduleDateExist) {
 		json.NewResponseBadRequest(ctx, nil, err.Error(), constants.DoctorScheduleService, "01")
 		return
+	}else if err != nil && err.Error() == constants.ErrVersionConflict {
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.DoctorScheduleService, "03")
+		return
 	}else if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.DoctorScheduleService, "01")
 		return
 	}
 
+	utils.SetETag(ctx, data.Version)
 	json.NewResponseCreated(ctx, data, "success", constants.DoctorScheduleService, "01")
 }
 
@@ -158,10 +168,18 @@ func (dd doctorScheduleDelivery) DeleteSchedule(ctx *gin.Context) {
 		return
 	}
 
-	err = dd.scheduleUC.DeleteSchedule(id)
+	version, ok := dd.ifMatch(ctx)
+	if !ok {
+		return
+	}
+
+	err = dd.scheduleUC.DeleteSchedule(ctx.Request.Context(), id, version)
 	if err != nil && err == sql.ErrNoRows {
 		json.NewResponseBadRequest(ctx, nil, "data not found", constants.DoctorScheduleService, "01")
 		return
+	}else if err != nil && err.Error() == constants.ErrVersionConflict {
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.DoctorScheduleService, "03")
+		return
 	}else if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.DoctorScheduleService, "01")
 		return
@@ -177,13 +195,37 @@ func (dd doctorScheduleDelivery) RestoreSchedule(ctx *gin.Context) {
 		return
 	}
 
-	err = dd.scheduleUC.Restore(id)
+	version, ok := dd.ifMatch(ctx)
+	if !ok {
+		return
+	}
+
+	err = dd.scheduleUC.Restore(ctx.Request.Context(), id, version)
 	if err != nil && err == sql.ErrNoRows {
 		json.NewResponseBadRequest(ctx, nil, "data not found", constants.DoctorScheduleService, "01")
 		return
+	}else if err != nil && err.Error() == constants.ErrVersionConflict {
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.DoctorScheduleService, "03")
+		return
 	}else if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.DoctorScheduleService, "01")
 		return
 	}
 	json.NewResponseSuccess(ctx, nil, "restored", constants.DoctorScheduleService, "01")
-}
\ No newline at end of file
+}
+
+// ifMatch reads the version the change is made against from the If-Match
+// header, answering the request itself when the header is missing or invalid.
+func (dd doctorScheduleDelivery) ifMatch(ctx *gin.Context) (int, bool) {
+	version, err := utils.IfMatch(ctx)
+	if err == nil {
+		return version, true
+	}
+
+	if err.Error() == constants.ErrIfMatchRequired {
+		json.NewResponsePreconditionRequired(ctx, err.Error(), constants.DoctorScheduleService, "02")
+		return 0, false
+	}
+	json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: constants.IfMatchHeader, Message: err.Error()}}, "bad request", constants.DoctorScheduleService, "02")
+	return 0, false
+}
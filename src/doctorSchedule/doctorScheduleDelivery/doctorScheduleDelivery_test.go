@@ -3,9 +3,12 @@ package doctorScheduleDelivery
 import (
 	"avengers-clinic/model/dto"
 	"avengers-clinic/model/entity"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -20,41 +23,46 @@ type mockDoctorScheduleUC struct {
 	mock.Mock
 }
 
-func (du *mockDoctorScheduleUC) GetAll(startDate string, endDate string) ([]entity.DoctorSchedule, error) {
+func (du *mockDoctorScheduleUC) GetAll(ctx context.Context, startDate string, endDate string) ([]entity.DoctorSchedule, error) {
 	args := du.Called()
 	return args.Get(0).([]entity.DoctorSchedule), args.Error(1)
 }
 
-func (du *mockDoctorScheduleUC) GetByID(id uuid.UUID, status string) (entity.DoctorSchedule, error) {
+func (du *mockDoctorScheduleUC) GetByID(ctx context.Context, id uuid.UUID, status string) (entity.DoctorSchedule, error) {
 	args := du.Called()
 	return args.Get(0).(entity.DoctorSchedule), args.Error(1)
 }
 
-func (du *mockDoctorScheduleUC) CreateSchedule(input dto.CreateDoctorSchedule) ([]entity.DoctorSchedule, error) {
+func (du *mockDoctorScheduleUC) CreateSchedule(ctx context.Context, input dto.CreateDoctorSchedule) ([]entity.DoctorSchedule, error) {
 	args := du.Called()
 	return args.Get(0).([]entity.DoctorSchedule), args.Error(1)
 }
 
-func (du *mockDoctorScheduleUC) GetMySchedule(doctorId uuid.UUID, dayOfWeek string, status string, startDate string, endDate string) ([]entity.DoctorSchedule, error) {
+func (du *mockDoctorScheduleUC) GetMySchedule(ctx context.Context, doctorId uuid.UUID, dayOfWeek string, status string, startDate string, endDate string) ([]entity.DoctorSchedule, error) {
 	args := du.Called()
 	return args.Get(0).([]entity.DoctorSchedule), args.Error(1)
 }
 
-func (du *mockDoctorScheduleUC) UpdateSchedule(id uuid.UUID, input dto.UpdateSchedule) (entity.DoctorSchedule, error) {
+func (du *mockDoctorScheduleUC) UpdateSchedule(ctx context.Context, id uuid.UUID, input dto.UpdateSchedule) (entity.DoctorSchedule, error) {
 	args := du.Called()
 	return args.Get(0).(entity.DoctorSchedule), args.Error(1)
 }
 
-func (du *mockDoctorScheduleUC) DeleteSchedule(id uuid.UUID) error {
+func (du *mockDoctorScheduleUC) DeleteSchedule(ctx context.Context, id uuid.UUID, version int) error {
 	args := du.Called()
 	return args.Error(0)
 }
 
-func (du *mockDoctorScheduleUC) Restore(id uuid.UUID) error {
+func (du *mockDoctorScheduleUC) Restore(ctx context.Context, id uuid.UUID, version int) error {
 	args := du.Called()
 	return args.Error(0)
 }
 
+func (du *mockDoctorScheduleUC) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	args := du.Called()
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
 type doctorScheduleDeliveryTestSuite struct {
 	suite.Suite
 	router           *gin.Engine
@@ -206,6 +214,7 @@ func (suite *doctorScheduleDeliveryTestSuite) TestUpdate() {
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"2010401","responseMessage":"success","data":{"id":"74d93144-6f2e-4bbc-9f89-973c62d3ac54","doctor_id":"5bc18dd0-58cb-4612-8dc3-5fc2419b7f29","schedule_date":"2024-03-14","start_at":1,"end_at":9,"created_at":"2024-03-12 22:39:22.245736"}}`
@@ -223,6 +232,7 @@ func (suite *doctorScheduleDeliveryTestSuite) TestDelete() {
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"2000401","responseMessage":"deleted"}`
@@ -232,11 +242,59 @@ func (suite *doctorScheduleDeliveryTestSuite) TestDelete() {
 
 }
 
+func (suite *doctorScheduleDeliveryTestSuite) TestUpdateWithoutIfMatch() {
+	reqBody := []byte(`{"schedule_date":"2024-03-19","start_at":1,"end_at":9}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/doctor-schedule/74d93144-6f2e-4bbc-9f89-973c62d3ac54", bytes.NewBuffer(reqBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusPreconditionRequired, res.Code)
+	suite.Contains(res.Body.String(), `"responseCode":"4280402"`)
+	suite.doctorScheduleUC.AssertNotCalled(suite.T(), "UpdateSchedule")
+}
+
+func (suite *doctorScheduleDeliveryTestSuite) TestUpdateVersionConflict() {
+	suite.doctorScheduleUC.On("UpdateSchedule").Return(entity.DoctorSchedule{}, errors.New(constants.ErrVersionConflict))
+
+	reqBody := []byte(`{"schedule_date":"2024-03-19","start_at":1,"end_at":9}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/doctor-schedule/74d93144-6f2e-4bbc-9f89-973c62d3ac54", bytes.NewBuffer(reqBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+	suite.Contains(res.Body.String(), `"responseCode":"4120403"`)
+}
+
+func (suite *doctorScheduleDeliveryTestSuite) TestDeleteVersionConflict() {
+	suite.doctorScheduleUC.On("DeleteSchedule").Return(errors.New(constants.ErrVersionConflict))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/doctor-schedule/5bc18dd0-58cb-4612-8dc3-5fc2419b7f29", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+	suite.Contains(res.Body.String(), `"responseCode":"4120403"`)
+}
+
 func (suite *doctorScheduleDeliveryTestSuite) TestRestore() {
 	suite.doctorScheduleUC.On("Restore").Return(nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/doctor-schedule/restore/5bc18dd0-58cb-4612-8dc3-5fc2419b7f29", nil)
+	req.Header.Set("If-Match", `"2"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -249,6 +307,18 @@ func (suite *doctorScheduleDeliveryTestSuite) TestRestore() {
 
 }
 
+func (suite *doctorScheduleDeliveryTestSuite) TestRestoreWithoutIfMatch() {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/doctor-schedule/restore/5bc18dd0-58cb-4612-8dc3-5fc2419b7f29", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusPreconditionRequired, res.Code)
+	suite.doctorScheduleUC.AssertNotCalled(suite.T(), "Restore")
+}
+
 func TestDoctorScheduleDelivery(t *testing.T) {
 	suite.Run(t, new(doctorScheduleDeliveryTestSuite))
 }
@@ -3,31 +3,34 @@ package doctorSchedule
 import (
 	"avengers-clinic/model/dto"
 	"avengers-clinic/model/entity"
+	"context"
 
 	"github.com/google/uuid"
 )
 
 type (
 	DoctorScheduleRepository interface {
-		RetrieveAll(startDate, endDate string) ([]entity.DoctorSchedule, error)
-		RetrieveByID(id uuid.UUID) (entity.DoctorSchedule, error)
-		InsertSchedule(input dto.CreateDoctorSchedule) (uuid.UUIDs, error)
-		GetMySchedule(doctorId uuid.UUID, dayOfWeek []int, startDate, endDate string) ([]entity.DoctorSchedule, error)
-		UpdateSchedule(id uuid.UUID, input entity.DoctorSchedule) (error)
-		GetByIDs(uuid.UUIDs) ([]entity.DoctorSchedule, error)
-		DeleteSchedule(id uuid.UUID) error
-		Restore(id uuid.UUID) error
-		SearchByDateAndDoctorID(date string, doctorID uuid.UUID) error
+		RetrieveAll(ctx context.Context, startDate, endDate string) ([]entity.DoctorSchedule, error)
+		RetrieveByID(ctx context.Context, id uuid.UUID) (entity.DoctorSchedule, error)
+		InsertSchedule(ctx context.Context, input dto.CreateDoctorSchedule) (uuid.UUIDs, error)
+		GetMySchedule(ctx context.Context, doctorId uuid.UUID, dayOfWeek []int, startDate, endDate string) ([]entity.DoctorSchedule, error)
+		UpdateSchedule(ctx context.Context, id uuid.UUID, input entity.DoctorSchedule) (error)
+		GetByIDs(ctx context.Context, ids uuid.UUIDs) ([]entity.DoctorSchedule, error)
+		DeleteSchedule(ctx context.Context, id uuid.UUID, version int) error
+		Restore(ctx context.Context, id uuid.UUID, version int) error
+		SearchByDateAndDoctorID(ctx context.Context, date string, doctorID uuid.UUID) error
+		PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error)
 	}
 
 
 	DoctorScheduleUsecase interface {
-		GetAll(startDate, endDate string) ([]entity.DoctorSchedule, error)
-		GetByID(id uuid.UUID, status string) (entity.DoctorSchedule, error)
-		CreateSchedule(input dto.CreateDoctorSchedule) ([]entity.DoctorSchedule, error)
-		GetMySchedule(doctorId uuid.UUID, dayOfWeek, status string, startDate, endDate string) ([]entity.DoctorSchedule, error)
-		UpdateSchedule(id uuid.UUID, input dto.UpdateSchedule) (entity.DoctorSchedule, error)
-		DeleteSchedule(id uuid.UUID) error
-		Restore(id uuid.UUID) error
+		GetAll(ctx context.Context, startDate, endDate string) ([]entity.DoctorSchedule, error)
+		GetByID(ctx context.Context, id uuid.UUID, status string) (entity.DoctorSchedule, error)
+		CreateSchedule(ctx context.Context, input dto.CreateDoctorSchedule) ([]entity.DoctorSchedule, error)
+		GetMySchedule(ctx context.Context, doctorId uuid.UUID, dayOfWeek, status string, startDate, endDate string) ([]entity.DoctorSchedule, error)
+		UpdateSchedule(ctx context.Context, id uuid.UUID, input dto.UpdateSchedule) (entity.DoctorSchedule, error)
+		DeleteSchedule(ctx context.Context, id uuid.UUID, version int) error
+		Restore(ctx context.Context, id uuid.UUID, version int) error
+		PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error)
 	}
 )
@@ -3,8 +3,13 @@ package doctorScheduleRepository
 import (
 	"avengers-clinic/model/dto"
 	"avengers-clinic/model/entity"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/doctorSchedule"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -23,7 +28,13 @@ func NewDoctorScheduleRepo(db *sql.DB) doctorSchedule.DoctorScheduleRepository {
 	}
 }
 
-func (ds doctorScheduleRepository) RetrieveAll(startDate, endDate string) ([]entity.DoctorSchedule, error) {
+// conn returns the transaction of the unit of work ctx belongs to, or the
+// database outside of one.
+func (ds doctorScheduleRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.Executor(ctx, ds.db)
+}
+
+func (ds doctorScheduleRepository) RetrieveAll(ctx context.Context, startDate, endDate string) ([]entity.DoctorSchedule, error) {
 	sqlstat := `
 		SELECT 
 				id, 
@@ -35,14 +46,14 @@ func (ds doctorScheduleRepository) RetrieveAll(startDate, endDate string) ([]ent
 				updated_at
 		FROM doctor_schedules 
 		WHERE deleted_at IS NULL AND schedule_date BETWEEN $1 AND $2;`
-	rows, err := ds.db.Query(sqlstat, startDate, endDate)
+	rows, err := ds.conn(ctx).QueryContext(ctx, sqlstat, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
 	return scanDoctorSchedules(rows)
 }
 
-func (ds doctorScheduleRepository) GetMySchedule(doctorId uuid.UUID, daysOfWeek []int, startDate, endDate string) ([]entity.DoctorSchedule, error) {
+func (ds doctorScheduleRepository) GetMySchedule(ctx context.Context, doctorId uuid.UUID, daysOfWeek []int, startDate, endDate string) ([]entity.DoctorSchedule, error) {
 	var rows *sql.Rows
 	var err error
 	sqlstat := `
@@ -61,9 +72,9 @@ func (ds doctorScheduleRepository) GetMySchedule(doctorId uuid.UUID, daysOfWeek
 		//Search by day of week, represented on int
 		//Start from SUNDAY = 0 .... SATURDAY = 6
 		sqlstat += " AND EXTRACT(dow from date (schedule_date)) = ANY($4)"
-		rows, err = ds.db.Query(sqlstat, doctorId, startDate, endDate, pq.Array(daysOfWeek))
+		rows, err = ds.conn(ctx).QueryContext(ctx, sqlstat, doctorId, startDate, endDate, pq.Array(daysOfWeek))
 	} else {
-		rows, err = ds.db.Query(sqlstat, doctorId, startDate, endDate)
+		rows, err = ds.conn(ctx).QueryContext(ctx, sqlstat, doctorId, startDate, endDate)
 	}
 
 	if err != nil {
@@ -73,7 +84,7 @@ func (ds doctorScheduleRepository) GetMySchedule(doctorId uuid.UUID, daysOfWeek
 	return scanDoctorSchedules(rows)
 }
 
-func (ds doctorScheduleRepository) RetrieveByID(id uuid.UUID) (entity.DoctorSchedule, error) {
+func (ds doctorScheduleRepository) RetrieveByID(ctx context.Context, id uuid.UUID) (entity.DoctorSchedule, error) {
 	var schedule entity.DoctorSchedule
 	sqlstat := `
 		SELECT 
@@ -81,15 +92,17 @@ func (ds doctorScheduleRepository) RetrieveByID(id uuid.UUID) (entity.DoctorSche
 				doctor_id, 
 				to_char(schedule_date, 'YYYY-MM-DD'), 
 				start_at, 
-				end_at 
+				end_at,
+				version
 		FROM doctor_schedules 
 		WHERE id = $1 AND deleted_at IS NULL;`
-	err := ds.db.QueryRow(sqlstat, id).Scan(
+	err := ds.conn(ctx).QueryRowContext(ctx, sqlstat, id).Scan(
 		&schedule.ID,
 		&schedule.DoctorID,
 		&schedule.ScheduleDate,
 		&schedule.StartAt,
 		&schedule.EndAt,
+		&schedule.Version,
 	)
 	if err != nil {
 		return entity.DoctorSchedule{}, err
@@ -101,7 +114,7 @@ func (ds doctorScheduleRepository) RetrieveByID(id uuid.UUID) (entity.DoctorSche
 
 }
 
-func (ds doctorScheduleRepository) InsertSchedule(input dto.CreateDoctorSchedule) (uuid.UUIDs, error) {
+func (ds doctorScheduleRepository) InsertSchedule(ctx context.Context, input dto.CreateDoctorSchedule) (uuid.UUIDs, error) {
 
 	insertQuery := "INSERT INTO doctor_schedules(doctor_id, schedule_date, start_at, end_at) VALUES"
 	returnIDQ := " RETURNING id;"
@@ -121,7 +134,7 @@ func (ds doctorScheduleRepository) InsertSchedule(input dto.CreateDoctorSchedule
 	sqlstat := insertQuery + strings.Join(inserts, ",") + returnIDQ
 
 	//format all vals at once
-	rows, err := ds.db.Query(sqlstat, vals...)
+	rows, err := ds.conn(ctx).QueryContext(ctx, sqlstat, vals...)
 	if err != nil {
 		log.Error().Msg(err.Error())
 		return nil, err
@@ -141,7 +154,7 @@ func (ds doctorScheduleRepository) InsertSchedule(input dto.CreateDoctorSchedule
 	return ids, nil
 }
 
-func (ds doctorScheduleRepository) GetByIDs(ids uuid.UUIDs) ([]entity.DoctorSchedule, error) {
+func (ds doctorScheduleRepository) GetByIDs(ctx context.Context, ids uuid.UUIDs) ([]entity.DoctorSchedule, error) {
 	var vals []interface{}
 
 	//Setup placeholders $1... refer by length id
@@ -154,7 +167,7 @@ func (ds doctorScheduleRepository) GetByIDs(ids uuid.UUIDs) ([]entity.DoctorSche
 	query := fmt.Sprintf("SELECT id, doctor_id, to_char(schedule_date, 'YYYY-MM-DD'), start_at, end_at, created_at, updated_at FROM doctor_schedules WHERE id IN (%s)",
 		strings.Join(placeholders, ","))
 
-	rows, err := ds.db.Query(query, vals...)
+	rows, err := ds.conn(ctx).QueryContext(ctx, query, vals...)
 	if err != nil {
 		return nil, err
 	}
@@ -162,43 +175,63 @@ func (ds doctorScheduleRepository) GetByIDs(ids uuid.UUIDs) ([]entity.DoctorSche
 	return scanDoctorSchedules(rows)
 }
 
-func (ds doctorScheduleRepository) UpdateSchedule(id uuid.UUID, data entity.DoctorSchedule) error {
+// UpdateSchedule changes the schedule while it is still at data.Version, a
+// Version of 0 changes any version.
+func (ds doctorScheduleRepository) UpdateSchedule(ctx context.Context, id uuid.UUID, data entity.DoctorSchedule) error {
 
-	sqlStat := "UPDATE doctor_schedules SET schedule_date = $1, start_at = $2, end_at = $3, updated_at = $4 WHERE id = $5;"
+	sqlStat := "UPDATE doctor_schedules SET schedule_date = $1, start_at = $2, end_at = $3, updated_at = $4, version = version + 1 WHERE id = $5 AND ($6 = 0 OR version = $6);"
 
-	_, err := ds.db.Exec(sqlStat, data.ScheduleDate, data.StartAt, data.EndAt, data.UpdatedAt, id)
+	result, err := ds.conn(ctx).ExecContext(ctx, sqlStat, data.ScheduleDate, data.StartAt, data.EndAt, data.UpdatedAt, id, data.Version)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return versionChecked(result, data.Version)
 }
 
-func (ds doctorScheduleRepository) DeleteSchedule(id uuid.UUID) error {
-	sqlStat := "UPDATE doctor_schedules SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1"
-	_, err := ds.db.Exec(sqlStat, id)
+// DeleteSchedule deletes the schedule while it is still at the version, a
+// version of 0 deletes any version.
+func (ds doctorScheduleRepository) DeleteSchedule(ctx context.Context, id uuid.UUID, version int) error {
+	sqlStat := "UPDATE doctor_schedules SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2)"
+	result, err := ds.conn(ctx).ExecContext(ctx, sqlStat, id, version)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return versionChecked(result, version)
 }
 
-func (ds doctorScheduleRepository) Restore(id uuid.UUID) error {
-	sqlStat := "UPDATE doctor_schedules SET deleted_at = NULL WHERE id = $1"
-	_, err := ds.db.Exec(sqlStat, id)
+// versionChecked fails when a change expecting a version did not find the row
+// at it anymore.
+func versionChecked(result sql.Result, version int) error {
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
 	return nil
 }
 
-func (ds doctorScheduleRepository) SearchByDateAndDoctorID(date string, doctorID uuid.UUID) error {
+// Restore takes the schedule out of the trash while it is still at the
+// version, a version of 0 restores any version.
+func (ds doctorScheduleRepository) Restore(ctx context.Context, id uuid.UUID, version int) error {
+	sqlStat := "UPDATE doctor_schedules SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2)"
+	result, err := ds.conn(ctx).ExecContext(ctx, sqlStat, id, version)
+	if err != nil {
+		return err
+	}
+
+	return versionChecked(result, version)
+}
+
+func (ds doctorScheduleRepository) SearchByDateAndDoctorID(ctx context.Context, date string, doctorID uuid.UUID) error {
 
 	tr := false
 	sqlStat := "SELECT true FROM doctor_schedules WHERE doctor_id = $1 AND schedule_date = $2"
-	err := ds.db.QueryRow(sqlStat, doctorID, date).Scan(&tr)
+	err := ds.conn(ctx).QueryRowContext(ctx, sqlStat, doctorID, date).Scan(&tr)
 	fmt.Println("HERE :", tr)
 	if err != nil {
 		return err
@@ -229,3 +262,7 @@ func scanDoctorSchedules(rows *sql.Rows) ([]entity.DoctorSchedule, error) {
 
 	return datas, nil
 }
+
+func (ds doctorScheduleRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	return utils.PurgeTrash(ctx, ds.db, "doctor_schedules", deletedBefore)
+}
@@ -3,7 +3,9 @@ package doctorScheduleRepository
 import (
 	"avengers-clinic/model/dto"
 	"avengers-clinic/model/entity"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/src/doctorSchedule"
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"testing"
@@ -50,7 +52,7 @@ func (suite *doctorRepositoryTestSuite) TestRetrieveAllSuccess() {
 			"2024-03-12 22:39:22.245736",
 		))
 
-	data, err := suite.doctorRepo.RetrieveAll(startDate, endDate)
+	data, err := suite.doctorRepo.RetrieveAll(context.Background(), startDate, endDate)
 	suite.NoError(err)
 	suite.NotEmpty(data)
 }
@@ -62,7 +64,7 @@ func (suite *doctorRepositoryTestSuite) TestRetrieveAllFail() {
 	suite.mock.ExpectQuery(`SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM doctor_schedules`).
 		WillReturnError(sql.ErrConnDone)
 
-	data, err := suite.doctorRepo.RetrieveAll(startDate, endDate)
+	data, err := suite.doctorRepo.RetrieveAll(context.Background(), startDate, endDate)
 	suite.Error(err)
 	suite.Empty(data)
 }
@@ -76,19 +78,22 @@ func (suite *doctorRepositoryTestSuite) TestRetrieveByID() {
 		"schedule_date",
 		"start_at",
 		"end_at",
+		"version",
 	})
-	suite.mock.ExpectQuery(`SELECT (.+), (.+), (.+), (.+), (.+) FROM doctor_schedules`).
+	suite.mock.ExpectQuery(`SELECT (.+), (.+), (.+), (.+), (.+), (.+) FROM doctor_schedules`).
 		WillReturnRows(rows.AddRow(
 			"74d93144-6f2e-4bbc-9f89-973c62d3ac54",
 			"5bc18dd0-58cb-4612-8dc3-5fc2419b7f29",
 			"2024-03-14",
 			1,
 			9,
+			2,
 		))
 
-	data, err := suite.doctorRepo.RetrieveByID(id)
+	data, err := suite.doctorRepo.RetrieveByID(context.Background(), id)
 	suite.NoError(err)
 	suite.NotEmpty(data)
+	suite.Equal(2, data.Version)
 }
 
 func (suite *doctorRepositoryTestSuite) TestGetMySchedule() {
@@ -117,7 +122,7 @@ func (suite *doctorRepositoryTestSuite) TestGetMySchedule() {
 			"2024-03-12 22:39:22.245736",
 		))
 
-	data, err := suite.doctorRepo.GetMySchedule(doctorID, dayOfWeeks, startDate, endDate)
+	data, err := suite.doctorRepo.GetMySchedule(context.Background(), doctorID, dayOfWeeks, startDate, endDate)
 	suite.NoError(err)
 	suite.NotEmpty(data)
 }
@@ -146,7 +151,7 @@ func (suite *doctorRepositoryTestSuite) TestInsertSchedules() {
 		},
 	}
 
-	ids, err := suite.doctorRepo.InsertSchedule(input)
+	ids, err := suite.doctorRepo.InsertSchedule(context.Background(), input)
 	suite.NoError(err)
 	suite.NotEmpty(ids)
 }
@@ -176,7 +181,7 @@ func (suite *doctorRepositoryTestSuite) TestGetByIDs() {
 			"2024-03-12 22:39:22.245736",
 		))
 
-	ids, err := suite.doctorRepo.GetByIDs(IDs)
+	ids, err := suite.doctorRepo.GetByIDs(context.Background(), IDs)
 	suite.NoError(err)
 	suite.NotEmpty(ids)
 }
@@ -185,7 +190,7 @@ func (suite *doctorRepositoryTestSuite) TestGetByIDs() {
 
 func (suite *doctorRepositoryTestSuite) TestUpdateSchedules() {
 	id, _ := uuid.Parse("74d93144-6f2e-4bbc-9f89-973c62d3ac54")
-	args := []driver.Value{"2024-03-14", 1, 9, "2024-03-12 22:39:22.245736", "74d93144-6f2e-4bbc-9f89-973c62d3ac54"}
+	args := []driver.Value{"2024-03-14", 1, 9, "2024-03-12 22:39:22.245736", "74d93144-6f2e-4bbc-9f89-973c62d3ac54", 2}
 	updatedAt := "2024-03-12 22:39:22.245736"
 
 	suite.mock.ExpectExec(`UPDATE doctor_schedules`).
@@ -197,34 +202,72 @@ func (suite *doctorRepositoryTestSuite) TestUpdateSchedules() {
 				StartAt:      1,
 				EndAt:        9,
 				UpdatedAt: &updatedAt,
+				Version: 2,
 		}
 
-	err := suite.doctorRepo.UpdateSchedule(id, input)
+	err := suite.doctorRepo.UpdateSchedule(context.Background(), id, input)
 	suite.NoError(err)
 }
 
+func (suite *doctorRepositoryTestSuite) TestUpdateSchedulesVersionConflict() {
+	id, _ := uuid.Parse("74d93144-6f2e-4bbc-9f89-973c62d3ac54")
+	updatedAt := "2024-03-12 22:39:22.245736"
+
+	// Someone else changed the schedule after it was read
+	suite.mock.ExpectExec(`UPDATE doctor_schedules (.+) version = version \+ 1 WHERE id = \$5 AND \(\$6 = 0 OR version = \$6\)`).
+		WithArgs("2024-03-14", 1, 9, updatedAt, id, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	input := entity.DoctorSchedule{ScheduleDate: "2024-03-14", StartAt: 1, EndAt: 9, UpdatedAt: &updatedAt, Version: 2}
+
+	err := suite.doctorRepo.UpdateSchedule(context.Background(), id, input)
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 func (suite *doctorRepositoryTestSuite) TestDeleteSchedules() {
 	id, _ := uuid.Parse("74d93144-6f2e-4bbc-9f89-973c62d3ac54")
 
 	suite.mock.ExpectExec(`UPDATE doctor_schedules`).
-		WithArgs(id).
+		WithArgs(id, 2).
 		WillReturnResult(sqlmock.NewResult(1,1))
 
-	err := suite.doctorRepo.DeleteSchedule(id)
+	err := suite.doctorRepo.DeleteSchedule(context.Background(), id, 2)
 	suite.NoError(err)
 }
 
+func (suite *doctorRepositoryTestSuite) TestDeleteSchedulesVersionConflict() {
+	id, _ := uuid.Parse("74d93144-6f2e-4bbc-9f89-973c62d3ac54")
+
+	suite.mock.ExpectExec(`UPDATE doctor_schedules SET deleted_at`).
+		WithArgs(id, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.doctorRepo.DeleteSchedule(context.Background(), id, 2)
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 func (suite *doctorRepositoryTestSuite) TestRestoreSchedules() {
 	id, _ := uuid.Parse("74d93144-6f2e-4bbc-9f89-973c62d3ac54")
 
 	suite.mock.ExpectExec(`UPDATE doctor_schedules`).
-		WithArgs(id).
+		WithArgs(id, 2).
 		WillReturnResult(sqlmock.NewResult(1,1))
 
-	err := suite.doctorRepo.Restore(id)
+	err := suite.doctorRepo.Restore(context.Background(), id, 2)
 	suite.NoError(err)
 }
 
+func (suite *doctorRepositoryTestSuite) TestRestoreSchedulesVersionConflict() {
+	id, _ := uuid.Parse("74d93144-6f2e-4bbc-9f89-973c62d3ac54")
+
+	suite.mock.ExpectExec(`UPDATE doctor_schedules SET deleted_at = NULL`).
+		WithArgs(id, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.doctorRepo.Restore(context.Background(), id, 2)
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 
 
 
@@ -237,7 +280,7 @@ func (suite *doctorRepositoryTestSuite) TestSearchByDateAndDoctorID() {
 		WithArgs(args...).
 		WillReturnRows(sqlmock.NewRows([]string{"column"}).AddRow(true))
 
-	err := suite.doctorRepo.SearchByDateAndDoctorID(date, id)
+	err := suite.doctorRepo.SearchByDateAndDoctorID(context.Background(), date, id)
 	suite.NoError(err)
 }
 
@@ -7,6 +7,8 @@ import (
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/booking"
 	"avengers-clinic/src/doctorSchedule"
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
@@ -24,7 +26,7 @@ func NewDoctorScheduleUsecase(scheduleRepo doctorSchedule.DoctorScheduleReposito
 	}
 }
 
-func (du doctorScheduleUsecase) GetAll(startDate, endDate string) ([]entity.DoctorSchedule, error) {
+func (du doctorScheduleUsecase) GetAll(ctx context.Context, startDate, endDate string) ([]entity.DoctorSchedule, error) {
 	var err error
 
 	startDate, endDate, err = utils.ValidateStartEndDate(startDate, endDate)
@@ -32,26 +34,26 @@ func (du doctorScheduleUsecase) GetAll(startDate, endDate string) ([]entity.Doct
 		return nil, err
 	}
 
-	data, err := du.scheduleRepo.RetrieveAll(startDate, endDate)
+	data, err := du.scheduleRepo.RetrieveAll(ctx, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
-func (du doctorScheduleUsecase) GetByID(id uuid.UUID, status string) (entity.DoctorSchedule, error) {
-	data, err := du.scheduleRepo.RetrieveByID(id)
+func (du doctorScheduleUsecase) GetByID(ctx context.Context, id uuid.UUID, status string) (entity.DoctorSchedule, error) {
+	data, err := du.scheduleRepo.RetrieveByID(ctx, id)
 	if err != nil {
 		return data, err
 	}
 
 	arrStatus := utils.SanitizeStatusQuery(status)
-	data.Schedules, _ = du.bookingRepo.GetBookingByScheduleID(data.ID, arrStatus)
+	data.Schedules, _ = du.bookingRepo.GetBookingByScheduleID(ctx, data.ID, arrStatus)
 
 	return data, nil
 }
 
-func (du doctorScheduleUsecase) CreateSchedule(input dto.CreateDoctorSchedule) ([]entity.DoctorSchedule, error) {
+func (du doctorScheduleUsecase) CreateSchedule(ctx context.Context, input dto.CreateDoctorSchedule) ([]entity.DoctorSchedule, error) {
 	// TODO : Add validation for input.doctor_id
 	//TODO : Add validation for input.schedule_date
 	var err error
@@ -65,12 +67,12 @@ func (du doctorScheduleUsecase) CreateSchedule(input dto.CreateDoctorSchedule) (
 
 	}
 
-	ids, err := du.scheduleRepo.InsertSchedule(input)
+	ids, err := du.scheduleRepo.InsertSchedule(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := du.scheduleRepo.GetByIDs(ids)
+	data, err := du.scheduleRepo.GetByIDs(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +80,7 @@ func (du doctorScheduleUsecase) CreateSchedule(input dto.CreateDoctorSchedule) (
 	return data, nil
 }
 
-func (du doctorScheduleUsecase) GetMySchedule(doctorId uuid.UUID, dayOfWeek, status string, startDate, endDate string) ([]entity.DoctorSchedule, error) {
+func (du doctorScheduleUsecase) GetMySchedule(ctx context.Context, doctorId uuid.UUID, dayOfWeek, status string, startDate, endDate string) ([]entity.DoctorSchedule, error) {
 	var err error
 
 	startDate, endDate, err = utils.ValidateStartEndDate(startDate, endDate)
@@ -90,24 +92,31 @@ func (du doctorScheduleUsecase) GetMySchedule(doctorId uuid.UUID, dayOfWeek, sta
 	arrDow := utils.SanitizeDowQuery(dayOfWeek)
 	arrStatus := utils.SanitizeStatusQuery(status)
 
-	sched, err := du.scheduleRepo.GetMySchedule(doctorId, arrDow, startDate, endDate)
+	sched, err := du.scheduleRepo.GetMySchedule(ctx, doctorId, arrDow, startDate, endDate)
 	if err != nil {
 		return sched, err
 	}
 
 	for i, v := range sched {
-		sched[i].Schedules, _ = du.bookingRepo.GetBookingByScheduleID(v.ID, arrStatus)
+		sched[i].Schedules, _ = du.bookingRepo.GetBookingByScheduleID(ctx, v.ID, arrStatus)
 	}
 
 	return sched, nil
 }
 
-func (du doctorScheduleUsecase) UpdateSchedule(id uuid.UUID, input dto.UpdateSchedule) (entity.DoctorSchedule, error) {
-	schedule, err := du.scheduleRepo.RetrieveByID(id)
+// UpdateSchedule changes the schedule. A version given in the input has to be
+// the current one, the change is then made against the version read here so
+// concurrent changes cannot overwrite each other.
+func (du doctorScheduleUsecase) UpdateSchedule(ctx context.Context, id uuid.UUID, input dto.UpdateSchedule) (entity.DoctorSchedule, error) {
+	schedule, err := du.scheduleRepo.RetrieveByID(ctx, id)
 	if err != nil {
 		return schedule, err
 	}
 
+	if input.Version != 0 && input.Version != schedule.Version {
+		return entity.DoctorSchedule{}, errors.New(constants.ErrVersionConflict)
+	}
+
 	if input.ScheduleDate != "" && input.ScheduleDate != schedule.ScheduleDate {
 
 		sd, err := utils.FormatDate(input.ScheduleDate)
@@ -116,7 +125,7 @@ func (du doctorScheduleUsecase) UpdateSchedule(id uuid.UUID, input dto.UpdateSch
 		}
 		schedule.ScheduleDate = sd
 
-		err = du.scheduleRepo.SearchByDateAndDoctorID(input.ScheduleDate, schedule.DoctorID)
+		err = du.scheduleRepo.SearchByDateAndDoctorID(ctx, input.ScheduleDate, schedule.DoctorID)
 		if err == nil {
 			return schedule, fmt.Errorf(constants.ErrScheduleDateExist)
 		}
@@ -138,31 +147,51 @@ func (du doctorScheduleUsecase) UpdateSchedule(id uuid.UUID, input dto.UpdateSch
 	now := utils.GetNow()
 	schedule.UpdatedAt = &now
 
-	err = du.scheduleRepo.UpdateSchedule(id, schedule)
+	err = du.scheduleRepo.UpdateSchedule(ctx, id, schedule)
 	if err != nil {
 		return schedule, err
 	}
 
+	// The update only succeeds on the version read, so it is one ahead now
+	if schedule.Version != 0 {
+		schedule.Version++
+	}
 	return schedule, nil
 }
 
-func (du doctorScheduleUsecase) DeleteSchedule(id uuid.UUID) error {
-	_, err := du.scheduleRepo.RetrieveByID(id)
+// DeleteSchedule deletes the schedule, only while it is at the given version
+// unless the version is 0.
+func (du doctorScheduleUsecase) DeleteSchedule(ctx context.Context, id uuid.UUID, version int) error {
+	schedule, err := du.scheduleRepo.RetrieveByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	err = du.scheduleRepo.DeleteSchedule(id)
+	if version != 0 && version != schedule.Version {
+		return errors.New(constants.ErrVersionConflict)
+	}
+
+	err = du.scheduleRepo.DeleteSchedule(ctx, id, schedule.Version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (du doctorScheduleUsecase) Restore(id uuid.UUID) error {
-	err := du.scheduleRepo.Restore(id)
+func (du doctorScheduleUsecase) Restore(ctx context.Context, id uuid.UUID, version int) error {
+	err := du.scheduleRepo.Restore(ctx, id, version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// PurgeTrash deletes the schedules that have been in the trash for more than
+// retentionDays. Those still referenced by other rows stay in the trash.
+func (du doctorScheduleUsecase) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	deletedBefore, err := utils.RetentionCutoff(retentionDays)
+	if err != nil {
+		return dto.Purged{}, err
+	}
+	return du.scheduleRepo.PurgeTrash(ctx, deletedBefore)
+}
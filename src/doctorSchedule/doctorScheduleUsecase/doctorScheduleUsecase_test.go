@@ -2,10 +2,14 @@ package doctorScheduleUsecase
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/entity"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/doctorSchedule"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -16,58 +20,63 @@ type mockDoctorScheduleRepo struct {
 	mock.Mock
 }
 
-func (mr *mockDoctorScheduleRepo) RetrieveAll(startDate string, endDate string) ([]entity.DoctorSchedule, error) {
+func (mr *mockDoctorScheduleRepo) RetrieveAll(ctx context.Context, startDate string, endDate string) ([]entity.DoctorSchedule, error) {
 	args := mr.Called()
 	return args.Get(0).([]entity.DoctorSchedule), args.Error(1)
 }
 
-func (mr *mockDoctorScheduleRepo) RetrieveByID(id uuid.UUID) (entity.DoctorSchedule, error) {
+func (mr *mockDoctorScheduleRepo) RetrieveByID(ctx context.Context, id uuid.UUID) (entity.DoctorSchedule, error) {
 	args := mr.Called()
 	return args.Get(0).(entity.DoctorSchedule), args.Error(1)
 }
 
-func (mr *mockDoctorScheduleRepo) GetByIDs(id uuid.UUIDs) ([]entity.DoctorSchedule, error) {
+func (mr *mockDoctorScheduleRepo) GetByIDs(ctx context.Context, id uuid.UUIDs) ([]entity.DoctorSchedule, error) {
 	args := mr.Called()
 	return args.Get(0).([]entity.DoctorSchedule), args.Error(1)
 }
 
-func (mr *mockDoctorScheduleRepo) InsertSchedule(input dto.CreateDoctorSchedule) (uuid.UUIDs, error) {
+func (mr *mockDoctorScheduleRepo) InsertSchedule(ctx context.Context, input dto.CreateDoctorSchedule) (uuid.UUIDs, error) {
 	args := mr.Called()
 	return args.Get(0).(uuid.UUIDs), args.Error(1)
 }
 
-func (mr *mockDoctorScheduleRepo) GetMySchedule(doctorId uuid.UUID, dayOfWeek []int, startDate string, endDate string) ([]entity.DoctorSchedule, error) {
+func (mr *mockDoctorScheduleRepo) GetMySchedule(ctx context.Context, doctorId uuid.UUID, dayOfWeek []int, startDate string, endDate string) ([]entity.DoctorSchedule, error) {
 	args := mr.Called()
 	return args.Get(0).([]entity.DoctorSchedule), args.Error(1)
 }
 
-func (mr *mockDoctorScheduleRepo) UpdateSchedule(id uuid.UUID, input entity.DoctorSchedule) error {
+func (mr *mockDoctorScheduleRepo) UpdateSchedule(ctx context.Context, id uuid.UUID, input entity.DoctorSchedule) error {
 	args := mr.Called()
 	return args.Error(0)
 }
 
-func (mr *mockDoctorScheduleRepo) DeleteSchedule(id uuid.UUID) error {
+func (mr *mockDoctorScheduleRepo) DeleteSchedule(ctx context.Context, id uuid.UUID, version int) error {
 	args := mr.Called()
 	return args.Error(0)
 }
 
-func (mr *mockDoctorScheduleRepo) Restore(id uuid.UUID) error {
+func (mr *mockDoctorScheduleRepo) Restore(ctx context.Context, id uuid.UUID, version int) error {
 	args := mr.Called()
 	return args.Error(0)
 }
 
-func (mr *mockDoctorScheduleRepo) SearchByDateAndDoctorID(date string, doctorID uuid.UUID) error {
+func (mr *mockDoctorScheduleRepo) SearchByDateAndDoctorID(ctx context.Context, date string, doctorID uuid.UUID) error {
 	args := mr.Called()
 	return args.Error(0)
 }
 
+func (mr *mockDoctorScheduleRepo) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	args := mr.Called(deletedBefore)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
 type mockBookingRepo struct {
 	mock.Mock
 }
 
-func (mb *mockBookingRepo) GetAllBooking() ([]entity.Bookings, error) {
-	args := mb.Called()
-	return args.Get(0).([]entity.Bookings), args.Error(1)
+func (mb *mockBookingRepo) GetAllBooking(ctx context.Context, query queryDto.Query) ([]entity.Bookings, int, error) {
+	args := mb.Called(query)
+	return args.Get(0).([]entity.Bookings), args.Int(1), args.Error(2)
 }
 
 func (mb *mockBookingRepo) GetAllBookingByDoctorID(doctorId uuid.UUID) ([]entity.Bookings, error) {
@@ -75,37 +84,37 @@ func (mb *mockBookingRepo) GetAllBookingByDoctorID(doctorId uuid.UUID) ([]entity
 	return args.Get(0).([]entity.Bookings), args.Error(1)
 }
 
-func (mb *mockBookingRepo) GetOneByID(id uuid.UUID) (entity.Bookings, error) {
+func (mb *mockBookingRepo) GetOneByID(ctx context.Context, id uuid.UUID) (entity.Bookings, error) {
 	args := mb.Called()
 	return args.Get(0).(entity.Bookings), args.Error(1)
 }
 
-func (mb *mockBookingRepo) GetBookingByScheduleID(scheduleId uuid.UUID, status []string) ([]entity.Bookings, error) {
+func (mb *mockBookingRepo) GetBookingByScheduleID(ctx context.Context, scheduleId uuid.UUID, status []string) ([]entity.Bookings, error) {
 	args := mb.Called()
 	return args.Get(0).([]entity.Bookings), args.Error(1)
 }
 
-func (mb *mockBookingRepo) CreateBooking(input entity.Bookings) (entity.Bookings, error) {
+func (mb *mockBookingRepo) CreateBooking(ctx context.Context, input entity.Bookings) (entity.Bookings, error) {
 	args := mb.Called()
 	return args.Get(0).(entity.Bookings), args.Error(1)
 }
 
-func (mb *mockBookingRepo) CheckExist(doctorScheduleID uuid.UUID, mstScheduleID int) bool {
+func (mb *mockBookingRepo) CheckExist(ctx context.Context, doctorScheduleID uuid.UUID, mstScheduleID int) bool {
 	args := mb.Called()
 	return args.Get(0).(bool)
 }
 
-func (mb *mockBookingRepo) EditSchedule(id uuid.UUID, input entity.Bookings) error {
+func (mb *mockBookingRepo) EditSchedule(ctx context.Context, id uuid.UUID, input entity.Bookings) (int, error) {
 	args := mb.Called()
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
 
-func (mb *mockBookingRepo) CancelBooking(id uuid.UUID) error {
+func (mb *mockBookingRepo) CancelBooking(ctx context.Context, id uuid.UUID, version int) error {
 	args := mb.Called()
 	return args.Error(0)
 }
 
-func (mb *mockBookingRepo) FinishBooking(id uuid.UUID) error {
+func (mb *mockBookingRepo) FinishBooking(ctx context.Context, id uuid.UUID, version int) error {
 	args := mb.Called()
 	return args.Error(0)
 }
@@ -166,7 +175,7 @@ var (
 
 func (suite *doctorUcTestSuite) TestGetAll() {
 	suite.doctorRepo.On("RetrieveAll").Return(arrExpected, nil)
-	actual, err := suite.doctorUC.GetAll(startDate, endDate)
+	actual, err := suite.doctorUC.GetAll(context.Background(), startDate, endDate)
 	suite.Nil(err)
 	suite.Equal(arrExpected, actual)
 }
@@ -174,7 +183,7 @@ func (suite *doctorUcTestSuite) TestGetAll() {
 func (suite *doctorUcTestSuite) TestGetByID() {
 	suite.doctorRepo.On("RetrieveByID").Return(expected, nil)
 	suite.bookingRepo.On("GetBookingByScheduleID").Return(bookings, nil)
-	actual, err := suite.doctorUC.GetByID(id, status)
+	actual, err := suite.doctorUC.GetByID(context.Background(), id, status)
 	suite.Nil(err)
 	suite.Equal(expected, actual)
 }
@@ -182,7 +191,7 @@ func (suite *doctorUcTestSuite) TestGetByID() {
 func (suite *doctorUcTestSuite) TestCreate() {
 	suite.doctorRepo.On("InsertSchedule").Return(uuids, nil)
 	suite.doctorRepo.On("GetByIDs").Return(arrExpected, nil)
-	actual, err := suite.doctorUC.CreateSchedule(dto.CreateDoctorSchedule{})
+	actual, err := suite.doctorUC.CreateSchedule(context.Background(), dto.CreateDoctorSchedule{})
 	suite.Nil(err)
 	suite.Equal(arrExpected, actual)
 }
@@ -190,7 +199,7 @@ func (suite *doctorUcTestSuite) TestCreate() {
 func (suite *doctorUcTestSuite) TestGetMySchedule() {
 	suite.doctorRepo.On("GetMySchedule").Return(arrExpected, nil)
 	suite.bookingRepo.On("GetBookingByScheduleID").Return(bookings, nil)
-	actual, err := suite.doctorUC.GetMySchedule(doctorID, dayOfWeeks, status, startDate, endDate)
+	actual, err := suite.doctorUC.GetMySchedule(context.Background(), doctorID, dayOfWeeks, status, startDate, endDate)
 	suite.Nil(err)
 	suite.Equal(arrExpected, actual)
 }
@@ -210,7 +219,7 @@ func (suite *doctorUcTestSuite) TestUpdate() {
 	suite.doctorRepo.On("RetrieveByID").Return(expected, nil)
 	suite.doctorRepo.On("UpdateSchedule").Return(nil)
 
-	actual, err := suite.doctorUC.UpdateSchedule(id, dto.UpdateSchedule{})
+	actual, err := suite.doctorUC.UpdateSchedule(context.Background(), id, dto.UpdateSchedule{})
 	suite.Nil(err)
 	suite.Equal(expected, actual)
 }
@@ -218,14 +227,54 @@ func (suite *doctorUcTestSuite) TestUpdate() {
 func (suite *doctorUcTestSuite) TestDelete() {
 	suite.doctorRepo.On("RetrieveByID").Return(expected, nil)
 	suite.doctorRepo.On("DeleteSchedule").Return(nil)
-	err := suite.doctorUC.DeleteSchedule(id)
+	err := suite.doctorUC.DeleteSchedule(context.Background(), id, 0)
+	suite.Nil(err)
+}
+
+func (suite *doctorUcTestSuite) TestUpdateVersion() {
+	current := entity.DoctorSchedule{ID: id, DoctorID: doctorID, ScheduleDate: "2024-03-14", StartAt: 1, EndAt: 9, Version: 2}
+
+	suite.doctorRepo.On("RetrieveByID").Return(current, nil)
+	suite.doctorRepo.On("UpdateSchedule").Return(nil)
+
+	actual, err := suite.doctorUC.UpdateSchedule(context.Background(), id, dto.UpdateSchedule{EndAt: 8, Version: 2})
 	suite.Nil(err)
+	suite.Equal(3, actual.Version)
+}
+
+func (suite *doctorUcTestSuite) TestUpdateVersionConflict() {
+	current := entity.DoctorSchedule{ID: id, DoctorID: doctorID, ScheduleDate: "2024-03-14", StartAt: 1, EndAt: 9, Version: 3}
+
+	suite.doctorRepo.On("RetrieveByID").Return(current, nil)
+
+	_, err := suite.doctorUC.UpdateSchedule(context.Background(), id, dto.UpdateSchedule{EndAt: 8, Version: 2})
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.doctorRepo.AssertNotCalled(suite.T(), "UpdateSchedule")
+}
+
+func (suite *doctorUcTestSuite) TestDeleteVersionConflict() {
+	suite.doctorRepo.On("RetrieveByID").Return(entity.DoctorSchedule{ID: id, Version: 3}, nil)
+
+	err := suite.doctorUC.DeleteSchedule(context.Background(), id, 2)
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.doctorRepo.AssertNotCalled(suite.T(), "DeleteSchedule")
 }
 
 func (suite *doctorUcTestSuite) TestRestore() {
 	suite.doctorRepo.On("Restore").Return(nil)
-	err := suite.doctorUC.Restore(id)
+	err := suite.doctorUC.Restore(context.Background(), id, 2)
+	suite.Nil(err)
+}
+
+func (suite *doctorUcTestSuite) TestPurgeTrash() {
+	suite.doctorRepo.On("PurgeTrash", mock.MatchedBy(func(deletedBefore string) bool {
+		return deletedBefore <= time.Now().AddDate(0, 0, -30).Format("2006-01-02 15:04:05")
+	})).Return(dto.Purged{Deleted: 2, Kept: map[string]int{"bookings": 1}}, nil)
+
+	purged, err := suite.doctorUC.PurgeTrash(context.Background(), 30)
+
 	suite.Nil(err)
+	suite.Equal(dto.Purged{Deleted: 2, Kept: map[string]int{"bookings": 1}}, purged)
 }
 
 func TestDoctorUsecase(t *testing.T) {
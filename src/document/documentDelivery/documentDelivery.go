@@ -0,0 +1,155 @@
+package documentDelivery
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/document"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type documentDelivery struct {
+	documentUC document.DocumentUsecase
+}
+
+func NewDocumentDelivery(v1Group *gin.RouterGroup, documentUC document.DocumentUsecase) {
+	handler := documentDelivery{documentUC}
+
+	documentGroup := v1Group.Group("/documents")
+	{
+		documentGroup.GET("/invoices/:id", middleware.JwtAuth("ADMIN"), handler.PrintInvoice)
+		documentGroup.GET("/medical-records/:id/prescription", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.PrintPrescription)
+		documentGroup.GET("/medical-records/:id/summary", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.PrintVisitSummary)
+		documentGroup.POST("/medical-records/:id/sick-leave", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.PrintSickLeave)
+		documentGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetDocuments)
+		documentGroup.POST("/:id/revoke", middleware.JwtAuth("ADMIN"), handler.RevokeDocument)
+	}
+
+	// public, for employers and insurers checking a document they were given
+	v1Group.GET("/verify/:code", handler.Verify)
+}
+
+func (delivery *documentDelivery) PrintInvoice(c *gin.Context) {
+	file, err := delivery.documentUC.PrintInvoice(c.Request.Context(), c.Param("id"), utils.GetJWT(c).ID)
+	if err != nil {
+		delivery.documentError(c, err, "01")
+		return
+	}
+
+	sendFile(c, file)
+}
+
+func (delivery *documentDelivery) PrintPrescription(c *gin.Context) {
+	file, err := delivery.documentUC.PrintPrescription(c.Request.Context(), c.Param("id"), utils.GetJWT(c).ID)
+	if err != nil {
+		delivery.documentError(c, err, "02")
+		return
+	}
+
+	sendFile(c, file)
+}
+
+func (delivery *documentDelivery) PrintVisitSummary(c *gin.Context) {
+	file, err := delivery.documentUC.PrintVisitSummary(c.Request.Context(), c.Param("id"), utils.GetJWT(c).ID)
+	if err != nil {
+		delivery.documentError(c, err, "03")
+		return
+	}
+
+	sendFile(c, file)
+}
+
+func (delivery *documentDelivery) PrintSickLeave(c *gin.Context) {
+	var request documentDto.SickLeaveRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.DocumentService, "04")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.DocumentService, "01")
+		return
+	}
+
+	request.MedicalRecordID, request.IssuedBy = c.Param("id"), utils.GetJWT(c).ID
+	file, err := delivery.documentUC.PrintSickLeave(c.Request.Context(), request)
+	if err != nil {
+		delivery.documentError(c, err, "05")
+		return
+	}
+
+	sendFile(c, file)
+}
+
+func (delivery *documentDelivery) GetDocuments(c *gin.Context) {
+	documents, err := delivery.documentUC.GetDocuments(c.Request.Context(), c.Query("reference_id"))
+	if err != nil {
+		delivery.documentError(c, err, "06")
+		return
+	}
+
+	json.NewResponseSuccess(c, documents, "Documents retrieved successfully", constants.DocumentService, "01")
+}
+
+func (delivery *documentDelivery) RevokeDocument(c *gin.Context) {
+	var request documentDto.RevokeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.DocumentService, "07")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.DocumentService, "01")
+		return
+	}
+
+	request.RevokedBy = utils.GetJWT(c).ID
+	if err := delivery.documentUC.RevokeDocument(c.Request.Context(), c.Param("id"), request); err != nil {
+		delivery.documentError(c, err, "08")
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Document revoked successfully", constants.DocumentService, "02")
+}
+
+func (delivery *documentDelivery) Verify(c *gin.Context) {
+	verification, err := delivery.documentUC.Verify(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		delivery.documentError(c, err, "09")
+		return
+	}
+
+	json.NewResponseSuccess(c, verification, "Document verified", constants.DocumentService, "03")
+}
+
+// sendFile lets the browser show the PDF, or save it under its file name.
+func sendFile(c *gin.Context, file documentDto.File) {
+	c.Header("Content-Disposition", `inline; filename="`+file.FileName+`"`)
+	c.Data(http.StatusOK, "application/pdf", file.Content)
+}
+
+func (delivery *documentDelivery) documentError(c *gin.Context, err error, errorCode string) {
+	if err == sql.ErrNoRows {
+		json.NewResponseNotFound(c, "Data not found", constants.DocumentService, "01")
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrMedicalRecordNotExist, constants.ErrDocumentNotExist:
+		json.NewResponseNotFound(c, err.Error(), constants.DocumentService, "02")
+		return
+	case constants.ErrDateFormat:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "start_date", Message: err.Error()}}, "Bad request", constants.DocumentService, "02")
+		return
+	case constants.ErrInvoiceNotPrintable, constants.ErrNothingPrescribed, constants.ErrDocumentRevoked:
+		json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.DocumentService, "03")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.DocumentService, errorCode)
+}
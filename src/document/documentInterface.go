@@ -0,0 +1,24 @@
+package document
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"context"
+)
+
+type DocumentRepository interface {
+	RetrieveVisit(ctx context.Context, medicalRecordID string) (documentDto.Visit, error)
+	InsertDocument(ctx context.Context, document documentDto.Document) (string, error)
+	RetrieveDocuments(ctx context.Context, referenceID string) ([]documentDto.Document, error)
+	RetrieveDocumentByCode(ctx context.Context, code string) (documentDto.Document, error)
+	RevokeDocument(ctx context.Context, id string, req documentDto.RevokeRequest) error
+}
+
+type DocumentUsecase interface {
+	PrintInvoice(ctx context.Context, id, issuedBy string) (documentDto.File, error)
+	PrintPrescription(ctx context.Context, medicalRecordID, issuedBy string) (documentDto.File, error)
+	PrintVisitSummary(ctx context.Context, medicalRecordID, issuedBy string) (documentDto.File, error)
+	PrintSickLeave(ctx context.Context, req documentDto.SickLeaveRequest) (documentDto.File, error)
+	GetDocuments(ctx context.Context, referenceID string) ([]documentDto.Document, error)
+	RevokeDocument(ctx context.Context, id string, req documentDto.RevokeRequest) error
+	Verify(ctx context.Context, code string) (documentDto.Verification, error)
+}
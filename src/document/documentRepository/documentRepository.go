@@ -0,0 +1,119 @@
+package documentRepository
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/document"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// documentColumns reads dates and times back in the format they were signed in.
+const documentColumns = `
+	id, code, document_type, reference_id, COALESCE(doctor_name, ''), COALESCE(valid_from::text, ''),
+	COALESCE(valid_until::text, ''), COALESCE(signature, ''), COALESCE(issued_by::text, ''), TO_CHAR(issued_at, 'YYYY-MM-DD HH24:MI:SS'),
+	COALESCE(TO_CHAR(revoked_at, 'YYYY-MM-DD HH24:MI:SS'), ''), COALESCE(revoked_by::text, ''), COALESCE(revoke_reason, '')
+	FROM documents`
+
+type documentRepository struct {
+	db *sql.DB
+}
+
+func NewDocumentRepository(db *sql.DB) document.DocumentRepository {
+	return &documentRepository{db}
+}
+
+func (repository *documentRepository) RetrieveVisit(ctx context.Context, medicalRecordID string) (documentDto.Visit, error) {
+	query := `
+		SELECT r.id, p.username, d.username, COALESCE(d.specialization, ''), s.schedule_date::text, b.complaint, r.diagnosis_results
+		FROM medical_records r
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN users p ON p.id = b.patient_id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users d ON d.id = s.doctor_id
+		WHERE r.id = $1 AND r.deleted_at IS NULL;`
+
+	var visit documentDto.Visit
+	err := repository.db.QueryRowContext(ctx, query, medicalRecordID).Scan(&visit.MedicalRecordID, &visit.PatientName, &visit.DoctorName,
+		&visit.DoctorSpecialization, &visit.VisitDate, &visit.Complaint, &visit.Diagnosis)
+	return visit, err
+}
+
+func (repository *documentRepository) InsertDocument(ctx context.Context, document documentDto.Document) (string, error) {
+	var id string
+	query := `
+		INSERT INTO documents (code, document_type, reference_id, doctor_name, valid_from, valid_until, signature, issued_by, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`
+	err := repository.db.QueryRowContext(ctx, query, document.Code, document.DocumentType, document.ReferenceID, nullable(document.DoctorName),
+		nullable(document.ValidFrom), nullable(document.ValidUntil), nullable(document.Signature), nullable(document.IssuedBy),
+		document.IssuedAt).Scan(&id)
+	return id, err
+}
+
+func (repository *documentRepository) RetrieveDocuments(ctx context.Context, referenceID string) ([]documentDto.Document, error) {
+	rows, err := repository.db.QueryContext(ctx, "SELECT "+documentColumns+" WHERE reference_id = $1 ORDER BY issued_at;", referenceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []documentDto.Document
+	for rows.Next() {
+		document, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+func (repository *documentRepository) RetrieveDocumentByCode(ctx context.Context, code string) (documentDto.Document, error) {
+	return scanDocument(repository.db.QueryRowContext(ctx, "SELECT "+documentColumns+" WHERE code = $1;", code))
+}
+
+// RevokeDocument withdraws a document, after which its verification reports it
+// as revoked.
+func (repository *documentRepository) RevokeDocument(ctx context.Context, id string, req documentDto.RevokeRequest) error {
+	var revoked bool
+	if err := repository.db.QueryRowContext(ctx, "SELECT revoked_at IS NOT NULL FROM documents WHERE id = $1;", id).Scan(&revoked); err != nil {
+		return err
+	}
+
+	if revoked {
+		return errors.New(constants.ErrDocumentRevoked)
+	}
+
+	query := "UPDATE documents SET revoked_at = $1, revoked_by = $2, revoke_reason = $3 WHERE id = $4 AND revoked_at IS NULL;"
+	result, err := repository.db.ExecContext(ctx, query, time.Now().Format("2006-01-02 15:04:05"), nullable(req.RevokedBy), req.Reason, id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New(constants.ErrDocumentRevoked)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDocument(row scanner) (documentDto.Document, error) {
+	var document documentDto.Document
+	err := row.Scan(&document.ID, &document.Code, &document.DocumentType, &document.ReferenceID, &document.DoctorName,
+		&document.ValidFrom, &document.ValidUntil, &document.Signature, &document.IssuedBy, &document.IssuedAt,
+		&document.RevokedAt, &document.RevokedBy, &document.RevokeReason)
+	return document, err
+}
+
+// nullable stores empty strings as NULL for the optional columns.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
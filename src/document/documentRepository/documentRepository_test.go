@@ -0,0 +1,115 @@
+package documentRepository
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/document"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type documentRepositoryTestSuite struct {
+	suite.Suite
+	documentRepo document.DocumentRepository
+	mock         sqlmock.Sqlmock
+}
+
+func (suite *documentRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.documentRepo = NewDocumentRepository(db)
+	suite.mock = mock
+}
+
+func (suite *documentRepositoryTestSuite) TestRetrieveVisit() {
+	rows := sqlmock.NewRows([]string{"id", "patient", "doctor", "specialization", "schedule_date", "complaint", "diagnosis"}).
+		AddRow("mr1", "Budi", "Joko", "Ortopedi", "2024-03-14", "Nyeri lutut", "Osteoarthritis")
+	suite.mock.ExpectQuery(`FROM medical_records r`).WithArgs("mr1").WillReturnRows(rows)
+
+	visit, err := suite.documentRepo.RetrieveVisit(context.Background(), "mr1")
+
+	suite.Nil(err)
+	suite.Equal(documentDto.Visit{
+		MedicalRecordID:      "mr1",
+		PatientName:          "Budi",
+		DoctorName:           "Joko",
+		DoctorSpecialization: "Ortopedi",
+		VisitDate:            "2024-03-14",
+		Complaint:            "Nyeri lutut",
+		Diagnosis:            "Osteoarthritis",
+	}, visit)
+}
+
+func (suite *documentRepositoryTestSuite) TestRetrieveVisitNotFound() {
+	suite.mock.ExpectQuery(`FROM medical_records r`).WithArgs("mr1").WillReturnError(sql.ErrNoRows)
+
+	_, err := suite.documentRepo.RetrieveVisit(context.Background(), "mr1")
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *documentRepositoryTestSuite) TestInsertDocument() {
+	suite.mock.ExpectQuery(`INSERT INTO documents`).
+		WithArgs("ABCDEFGHIJKLMNOP", constants.DocumentInvoice, "inv1", nil, nil, nil, "5ig", "u1", "2024-03-14 10:00:00").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("doc1"))
+
+	id, err := suite.documentRepo.InsertDocument(context.Background(), documentDto.Document{
+		Code:         "ABCDEFGHIJKLMNOP",
+		DocumentType: constants.DocumentInvoice,
+		ReferenceID:  "inv1",
+		Signature:    "5ig",
+		IssuedBy:     "u1",
+		IssuedAt:     "2024-03-14 10:00:00",
+	})
+
+	suite.Nil(err)
+	suite.Equal("doc1", id)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *documentRepositoryTestSuite) TestRetrieveDocumentByCode() {
+	rows := sqlmock.NewRows([]string{"id", "code", "document_type", "reference_id", "doctor_name", "valid_from", "valid_until",
+		"signature", "issued_by", "issued_at", "revoked_at", "revoked_by", "revoke_reason"}).
+		AddRow("doc1", "ABCDEFGHIJKLMNOP", constants.DocumentSickLeave, "mr1", "Joko", "2024-03-14", "2024-03-16", "5ig", "u1",
+			"2024-03-14 10:00:00", "", "", "")
+	suite.mock.ExpectQuery(`FROM documents WHERE code = \$1`).WithArgs("ABCDEFGHIJKLMNOP").WillReturnRows(rows)
+
+	doc, err := suite.documentRepo.RetrieveDocumentByCode(context.Background(), "ABCDEFGHIJKLMNOP")
+
+	suite.Nil(err)
+	suite.Equal("doc1", doc.ID)
+	suite.Equal("2024-03-16", doc.ValidUntil)
+	suite.Equal("5ig", doc.Signature)
+}
+
+func (suite *documentRepositoryTestSuite) TestRevokeDocument() {
+	suite.mock.ExpectQuery(`SELECT revoked_at IS NOT NULL FROM documents`).
+		WithArgs("doc1").
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(false))
+	suite.mock.ExpectExec(`UPDATE documents SET revoked_at = \$1`).
+		WithArgs(sqlmock.AnyArg(), "u1", "issued by mistake", "doc1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := suite.documentRepo.RevokeDocument(context.Background(), "doc1", documentDto.RevokeRequest{Reason: "issued by mistake", RevokedBy: "u1"})
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *documentRepositoryTestSuite) TestRevokeDocumentAlreadyRevoked() {
+	suite.mock.ExpectQuery(`SELECT revoked_at IS NOT NULL FROM documents`).
+		WithArgs("doc1").
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(true))
+
+	err := suite.documentRepo.RevokeDocument(context.Background(), "doc1", documentDto.RevokeRequest{Reason: "again"})
+
+	suite.EqualError(err, constants.ErrDocumentRevoked)
+}
+
+func TestDocumentRepository(t *testing.T) {
+	suite.Run(t, new(documentRepositoryTestSuite))
+}
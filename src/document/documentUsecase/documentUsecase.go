@@ -0,0 +1,349 @@
+package documentUsecase
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/document"
+	"avengers-clinic/src/invoice"
+	"avengers-clinic/src/medicalRecord"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type documentUsecase struct {
+	documentRepo    document.DocumentRepository
+	medicalRecordUC medicalRecord.MedicalRecordUsecase
+	invoiceUC       invoice.InvoiceUsecase
+	clinic          documentDto.Clinic
+}
+
+func NewDocumentUsecase(documentRepo document.DocumentRepository, medicalRecordUC medicalRecord.MedicalRecordUsecase,
+	invoiceUC invoice.InvoiceUsecase, clinic documentDto.Clinic) document.DocumentUsecase {
+	return &documentUsecase{documentRepo, medicalRecordUC, invoiceUC, clinic}
+}
+
+func (usecase *documentUsecase) PrintInvoice(ctx context.Context, id, issuedBy string) (documentDto.File, error) {
+	inv, err := usecase.invoiceUC.GetInvoiceByID(ctx, id)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	if inv.Status == constants.InvoiceDraft {
+		return documentDto.File{}, errors.New(constants.ErrInvoiceNotPrintable)
+	}
+
+	visit, err := usecase.visit(ctx, inv.MedicalRecordID)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	doc, err := usecase.issue(ctx, documentDto.Document{DocumentType: constants.DocumentInvoice, ReferenceID: id, IssuedBy: issuedBy})
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	p := newPage(usecase.clinic, "INVOICE")
+	p.field("Invoice number", inv.InvoiceNumber)
+	p.field("Issued at", inv.IssuedAt)
+	p.field("Status", inv.Status)
+	p.field("Patient", visit.PatientName)
+	p.field("Doctor", visit.DoctorName)
+	p.field("Visit date", longDate(visit.VisitDate))
+	p.pdf.Ln(4)
+
+	var rows [][]string
+	for _, item := range inv.Items {
+		rows = append(rows, []string{item.Description, strconv.Itoa(item.Quantity), rupiah(item.UnitPrice), rupiah(item.Amount)})
+	}
+	p.table([]float64{90, 20, 35, 35}, []string{"L", "R", "R", "R"}, []string{"Description", "Qty", "Unit price", "Amount"}, rows)
+
+	totals := [][]string{
+		{"Subtotal", rupiah(inv.Subtotal)},
+		{"Discount", "-" + rupiah(inv.DiscountAmount)},
+		{"PPN " + strconv.FormatFloat(inv.TaxRate, 'f', -1, 64) + "%", rupiah(inv.TaxAmount)},
+		{"Total", rupiah(inv.Total)},
+	}
+	if inv.CoveredAmount > 0 {
+		totals = append(totals, []string{"Covered by insurance", rupiah(inv.CoveredAmount)}, []string{"Patient pays", rupiah(inv.PatientAmount)})
+	}
+	totals = append(totals, []string{"Paid", rupiah(inv.PaidAmount)}, []string{"Balance", rupiah(inv.Balance)})
+	for _, total := range totals {
+		p.pdf.CellFormat(145, lineHeight, p.translate(total[0]), "", 0, "R", false, 0, "")
+		p.pdf.CellFormat(35, lineHeight, p.translate(total[1]), "", 1, "R", false, 0, "")
+	}
+
+	if len(inv.Payments) > 0 {
+		p.heading("Payments")
+		rows = nil
+		for _, payment := range inv.Payments {
+			rows = append(rows, []string{payment.CreatedAt, payment.Method, payment.Status, rupiah(payment.Amount)})
+		}
+		p.table([]float64{55, 40, 40, 45}, []string{"L", "L", "L", "R"}, []string{"Date", "Method", "Status", "Amount"}, rows)
+	}
+
+	return usecase.output(p, doc, "invoice-"+strings.ReplaceAll(inv.InvoiceNumber, "/", "-"))
+}
+
+func (usecase *documentUsecase) PrintPrescription(ctx context.Context, medicalRecordID, issuedBy string) (documentDto.File, error) {
+	visit, err := usecase.visit(ctx, medicalRecordID)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	record, err := usecase.medicalRecordUC.GetMedicalRecordByID(ctx, medicalRecordID)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	if len(record.Medicine_Details) == 0 {
+		return documentDto.File{}, errors.New(constants.ErrNothingPrescribed)
+	}
+
+	doc, err := usecase.issue(ctx, documentDto.Document{
+		DocumentType: constants.DocumentPrescription,
+		ReferenceID:  medicalRecordID,
+		DoctorName:   visit.DoctorName,
+		IssuedBy:     issuedBy,
+	})
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	p := newPage(usecase.clinic, "PRESCRIPTION")
+	usecase.visitFields(p, visit)
+	p.heading("R/")
+
+	var rows [][]string
+	for i, medicine := range record.Medicine_Details {
+		rows = append(rows, []string{strconv.Itoa(i + 1), medicine.Medicine_Name, strconv.Itoa(medicine.Quantity)})
+	}
+	p.table([]float64{12, 138, 30}, []string{"R", "L", "R"}, []string{"No", "Medicine", "Qty"}, rows)
+	p.signature(longDate(visit.VisitDate), doctorTitle(visit))
+
+	return usecase.output(p, doc, "prescription-"+medicalRecordID)
+}
+
+func (usecase *documentUsecase) PrintVisitSummary(ctx context.Context, medicalRecordID, issuedBy string) (documentDto.File, error) {
+	visit, err := usecase.visit(ctx, medicalRecordID)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	record, err := usecase.medicalRecordUC.GetMedicalRecordByID(ctx, medicalRecordID)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	doc, err := usecase.issue(ctx, documentDto.Document{
+		DocumentType: constants.DocumentVisitSummary,
+		ReferenceID:  medicalRecordID,
+		DoctorName:   visit.DoctorName,
+		IssuedBy:     issuedBy,
+	})
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	p := newPage(usecase.clinic, "VISIT SUMMARY")
+	usecase.visitFields(p, visit)
+	p.heading("Complaint")
+	p.paragraph(visit.Complaint)
+	p.heading("Diagnosis")
+	p.paragraph(visit.Diagnosis)
+
+	if len(record.Action_Details) > 0 {
+		p.heading("Actions")
+		var rows [][]string
+		for i, action := range record.Action_Details {
+			rows = append(rows, []string{strconv.Itoa(i + 1), action.Action_Name})
+		}
+		p.table([]float64{12, 168}, []string{"R", "L"}, []string{"No", "Action"}, rows)
+	}
+
+	if len(record.Medicine_Details) > 0 {
+		p.heading("Medicines")
+		var rows [][]string
+		for i, medicine := range record.Medicine_Details {
+			rows = append(rows, []string{strconv.Itoa(i + 1), medicine.Medicine_Name, strconv.Itoa(medicine.Quantity)})
+		}
+		p.table([]float64{12, 138, 30}, []string{"R", "L", "R"}, []string{"No", "Medicine", "Qty"}, rows)
+	}
+	p.signature(longDate(visit.VisitDate), doctorTitle(visit))
+
+	return usecase.output(p, doc, "visit-summary-"+medicalRecordID)
+}
+
+// PrintSickLeave writes the doctor's statement that the patient needs rest,
+// the surat keterangan sakit employers ask for.
+func (usecase *documentUsecase) PrintSickLeave(ctx context.Context, req documentDto.SickLeaveRequest) (documentDto.File, error) {
+	visit, err := usecase.visit(ctx, req.MedicalRecordID)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	if req.StartDate == "" {
+		req.StartDate = visit.VisitDate
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return documentDto.File{}, errors.New(constants.ErrDateFormat)
+	}
+	until := start.AddDate(0, 0, req.Days-1).Format("2006-01-02")
+
+	doc, err := usecase.issue(ctx, documentDto.Document{
+		DocumentType: constants.DocumentSickLeave,
+		ReferenceID:  req.MedicalRecordID,
+		DoctorName:   visit.DoctorName,
+		ValidFrom:    req.StartDate,
+		ValidUntil:   until,
+		IssuedBy:     req.IssuedBy,
+	})
+	if err != nil {
+		return documentDto.File{}, err
+	}
+
+	p := newPage(usecase.clinic, "SURAT KETERANGAN SAKIT / SICK LEAVE CERTIFICATE")
+	p.paragraph("The undersigned, " + doctorTitle(visit) + ", states that the patient")
+	p.pdf.Ln(2)
+	p.field("Name", visit.PatientName)
+	p.field("Examined on", longDate(visit.VisitDate))
+	p.pdf.Ln(2)
+
+	days := strconv.Itoa(req.Days) + " day"
+	if req.Days > 1 {
+		days += "s"
+	}
+	p.paragraph("needs to rest because of illness for " + days + ", from " + longDate(req.StartDate) + " up to and including " +
+		longDate(until) + ".")
+	if note := strings.TrimSpace(req.Note); note != "" {
+		p.pdf.Ln(2)
+		p.paragraph(note)
+	}
+	p.pdf.Ln(2)
+	p.paragraph("This certificate is issued to be used as needed.")
+	p.signature(longDate(doc.IssuedAt[:10]), doctorTitle(visit))
+
+	return usecase.output(p, doc, "sick-leave-"+req.MedicalRecordID)
+}
+
+func (usecase *documentUsecase) GetDocuments(ctx context.Context, referenceID string) ([]documentDto.Document, error) {
+	return usecase.documentRepo.RetrieveDocuments(ctx, referenceID)
+}
+
+func (usecase *documentUsecase) RevokeDocument(ctx context.Context, id string, req documentDto.RevokeRequest) error {
+	req.Reason = strings.TrimSpace(req.Reason)
+	return usecase.documentRepo.RevokeDocument(ctx, id, req)
+}
+
+// Verify tells whether a document with the code was issued by the clinic.
+// A document whose stored facts no longer match its signature has been
+// tampered with and reveals nothing but its status. Documents printed before
+// they were signed or without a signing key have no signature and are told
+// apart as unsigned. Without the key no signature can be checked at all.
+func (usecase *documentUsecase) Verify(ctx context.Context, code string) (documentDto.Verification, error) {
+	doc, err := usecase.documentRepo.RetrieveDocumentByCode(ctx, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return documentDto.Verification{}, errors.New(constants.ErrDocumentNotExist)
+		}
+		return documentDto.Verification{}, err
+	}
+
+	status := constants.DocumentValid
+	if doc.Signature == "" || usecase.clinic.SigningKey == "" {
+		status = constants.DocumentUnsigned
+	} else if !hmac.Equal([]byte(doc.Signature), []byte(usecase.sign(doc))) {
+		return documentDto.Verification{Code: doc.Code, Status: constants.DocumentTampered}, nil
+	}
+
+	verification := documentDto.Verification{
+		Code:         doc.Code,
+		Status:       status,
+		DocumentType: doc.DocumentType,
+		IssuedOn:     doc.IssuedAt[:10],
+		DoctorName:   doc.DoctorName,
+		ValidFrom:    doc.ValidFrom,
+		ValidUntil:   doc.ValidUntil,
+	}
+	if doc.RevokedAt != "" {
+		verification.Status, verification.RevokedOn = constants.DocumentRevoked, doc.RevokedAt[:10]
+	}
+	return verification, nil
+}
+
+// sign is the HMAC of the facts of a document that verification reveals.
+func (usecase *documentUsecase) sign(doc documentDto.Document) string {
+	mac := hmac.New(sha256.New, []byte(usecase.clinic.SigningKey))
+	mac.Write([]byte(strings.Join([]string{
+		doc.Code, doc.DocumentType, doc.ReferenceID, doc.DoctorName, doc.ValidFrom, doc.ValidUntil, doc.IssuedAt,
+	}, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (usecase *documentUsecase) visit(ctx context.Context, medicalRecordID string) (documentDto.Visit, error) {
+	visit, err := usecase.documentRepo.RetrieveVisit(ctx, medicalRecordID)
+	if err == sql.ErrNoRows {
+		return documentDto.Visit{}, errors.New(constants.ErrMedicalRecordNotExist)
+	}
+	return visit, err
+}
+
+func (usecase *documentUsecase) visitFields(p *page, visit documentDto.Visit) {
+	p.field("Patient", visit.PatientName)
+	p.field("Doctor", doctorTitle(visit))
+	p.field("Visit date", longDate(visit.VisitDate))
+}
+
+// issue records the document under a new verification code.
+func (usecase *documentUsecase) issue(ctx context.Context, doc documentDto.Document) (documentDto.Document, error) {
+	random := make([]byte, 10)
+	if _, err := rand.Read(random); err != nil {
+		return documentDto.Document{}, err
+	}
+
+	doc.Code = base32.StdEncoding.EncodeToString(random)
+	doc.IssuedAt = utils.GetNow()
+	if usecase.clinic.SigningKey != "" {
+		doc.Signature = usecase.sign(doc)
+	}
+	id, err := usecase.documentRepo.InsertDocument(ctx, doc)
+	if err != nil {
+		return documentDto.Document{}, err
+	}
+	doc.ID = id
+	return doc, nil
+}
+
+func (usecase *documentUsecase) output(p *page, doc documentDto.Document, name string) (documentDto.File, error) {
+	content, err := p.output(usecase.clinic.VerifyURL, doc)
+	if err != nil {
+		return documentDto.File{}, err
+	}
+	return documentDto.File{FileName: name + ".pdf", Content: content}, nil
+}
+
+func doctorTitle(visit documentDto.Visit) string {
+	if visit.DoctorSpecialization == "" {
+		return "dr. " + visit.DoctorName
+	}
+	return "dr. " + visit.DoctorName + " (" + visit.DoctorSpecialization + ")"
+}
+
+// longDate writes a date as "14 March 2024", leaving other values as they are.
+func longDate(date string) string {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return d.Format("2 January 2006")
+}
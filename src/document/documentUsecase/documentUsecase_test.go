@@ -0,0 +1,403 @@
+package documentUsecase
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/document"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockDocumentRepository struct {
+	mock.Mock
+}
+
+func (m *mockDocumentRepository) RetrieveVisit(ctx context.Context, medicalRecordID string) (documentDto.Visit, error) {
+	args := m.Called(medicalRecordID)
+	return args.Get(0).(documentDto.Visit), args.Error(1)
+}
+
+func (m *mockDocumentRepository) InsertDocument(ctx context.Context, document documentDto.Document) (string, error) {
+	args := m.Called(document)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockDocumentRepository) RetrieveDocuments(ctx context.Context, referenceID string) ([]documentDto.Document, error) {
+	args := m.Called(referenceID)
+	return args.Get(0).([]documentDto.Document), args.Error(1)
+}
+
+func (m *mockDocumentRepository) RetrieveDocumentByCode(ctx context.Context, code string) (documentDto.Document, error) {
+	args := m.Called(code)
+	return args.Get(0).(documentDto.Document), args.Error(1)
+}
+
+func (m *mockDocumentRepository) RevokeDocument(ctx context.Context, id string, req documentDto.RevokeRequest) error {
+	args := m.Called(id, req)
+	return args.Error(0)
+}
+
+type mockMedicalRecordUsecase struct {
+	mock.Mock
+}
+
+func (m *mockMedicalRecordUsecase) CreateMedicalRecord(ctx context.Context, mr medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
+	args := m.Called(mr)
+	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
+}
+
+func (m *mockMedicalRecordUsecase) GetMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, queryDto.Paging, error) {
+	args := m.Called(query)
+	return args.Get(0).([]medicalRecordDTO.Medical_Record), args.Get(1).(queryDto.Paging), args.Error(2)
+}
+
+func (m *mockMedicalRecordUsecase) GetMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error) {
+	args := m.Called(id)
+	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
+}
+
+func (m *mockMedicalRecordUsecase) UpdatePaymentStatus(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error) {
+	args := m.Called(id, userID)
+	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
+}
+
+func (m *mockMedicalRecordUsecase) CancelMedicalRecord(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockMedicalRecordUsecase) ReleaseExpiredReservations(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type mockInvoiceUsecase struct {
+	mock.Mock
+}
+
+func (m *mockInvoiceUsecase) GetInvoices(ctx context.Context, filter invoiceDto.InvoiceFilter) ([]invoiceDto.Invoice, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) GetInvoiceByID(ctx context.Context, id string) (invoiceDto.Invoice, error) {
+	args := m.Called(id)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) CreateInvoice(ctx context.Context, req invoiceDto.CreateRequest) (invoiceDto.Invoice, error) {
+	args := m.Called(req)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) SetDiscount(ctx context.Context, id string, req invoiceDto.DiscountRequest) (invoiceDto.Invoice, error) {
+	args := m.Called(id, req)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) IssueInvoice(ctx context.Context, id, issuedBy string) (invoiceDto.Invoice, error) {
+	args := m.Called(id, issuedBy)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) PayInvoice(ctx context.Context, id string, req invoiceDto.PaymentRequest) (invoiceDto.Invoice, error) {
+	args := m.Called(id, req)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) VoidPayment(ctx context.Context, id, paymentID string, req invoiceDto.VoidRequest) (invoiceDto.Invoice, error) {
+	args := m.Called(id, paymentID, req)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) RefundInvoice(ctx context.Context, id string, req invoiceDto.RefundRequest) (invoiceDto.Invoice, error) {
+	args := m.Called(id, req)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) GetEvents(ctx context.Context, id string) ([]invoiceDto.Event, error) {
+	args := m.Called(id)
+	return args.Get(0).([]invoiceDto.Event), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) VoidInvoice(ctx context.Context, id string, req invoiceDto.VoidRequest) (invoiceDto.Invoice, error) {
+	args := m.Called(id, req)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) GetConsultationFees(ctx context.Context) ([]invoiceDto.ConsultationFee, error) {
+	args := m.Called()
+	return args.Get(0).([]invoiceDto.ConsultationFee), args.Error(1)
+}
+
+func (m *mockInvoiceUsecase) SetConsultationFee(ctx context.Context, req invoiceDto.ConsultationFeeRequest) (invoiceDto.ConsultationFee, error) {
+	args := m.Called(req)
+	return args.Get(0).(invoiceDto.ConsultationFee), args.Error(1)
+}
+
+type documentUsecaseTestSuite struct {
+	suite.Suite
+	documentRepo    *mockDocumentRepository
+	medicalRecordUC *mockMedicalRecordUsecase
+	invoiceUC       *mockInvoiceUsecase
+	documentUC      document.DocumentUsecase
+}
+
+func (suite *documentUsecaseTestSuite) SetupTest() {
+	suite.documentRepo = new(mockDocumentRepository)
+	suite.medicalRecordUC = new(mockMedicalRecordUsecase)
+	suite.invoiceUC = new(mockInvoiceUsecase)
+	suite.documentUC = NewDocumentUsecase(suite.documentRepo, suite.medicalRecordUC, suite.invoiceUC, documentDto.Clinic{
+		Name:       "Avengers Clinic",
+		Address:    "Jl. Sudirman No. 1, Jakarta",
+		VerifyURL:  "https://clinic.example.com/api/v1/verify",
+		SigningKey: "secret",
+	})
+}
+
+var visit = documentDto.Visit{
+	MedicalRecordID: "mr1",
+	PatientName:     "Budi",
+	DoctorName:      "Joko",
+	VisitDate:       "2024-03-14",
+	Complaint:       "Sakit kepala",
+	Diagnosis:       "Migraine",
+}
+
+var record = medicalRecordDTO.Medical_Record{
+	ID:               "mr1",
+	Medicine_Details: []medicalRecordDTO.Medical_Record_Medicine_Details{{Medicine_Name: "Paracetamol", Quantity: 10}},
+	Action_Details:   []medicalRecordDTO.Medical_Record_Action_Details{{Action_Name: "Pemeriksaan"}},
+}
+
+func issued(documentType string) interface{} {
+	return mock.MatchedBy(func(doc documentDto.Document) bool {
+		return doc.DocumentType == documentType && len(doc.Code) == 16 && doc.IssuedAt != "" && len(doc.Signature) == 64
+	})
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintInvoice() {
+	suite.invoiceUC.On("GetInvoiceByID", "inv1").Return(invoiceDto.Invoice{
+		ID:              "inv1",
+		InvoiceNumber:   "INV/202403/0001",
+		MedicalRecordID: "mr1",
+		Status:          constants.InvoicePaid,
+		Items:           []invoiceDto.Item{{Description: "Paracetamol", Quantity: 10, UnitPrice: 5000, Amount: 50000}},
+		Subtotal:        50000,
+		Total:           50000,
+		PaidAmount:      50000,
+	}, nil)
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+	suite.documentRepo.On("InsertDocument", issued(constants.DocumentInvoice)).Return("doc1", nil)
+
+	file, err := suite.documentUC.PrintInvoice(context.Background(), "inv1", "u1")
+
+	suite.Nil(err)
+	suite.Equal("invoice-INV-202403-0001.pdf", file.FileName)
+	suite.Equal("%PDF", string(file.Content[:4]))
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintWithoutSigningKey() {
+	suite.documentUC = NewDocumentUsecase(suite.documentRepo, suite.medicalRecordUC, suite.invoiceUC, documentDto.Clinic{Name: "Avengers Clinic"})
+	suite.invoiceUC.On("GetInvoiceByID", "inv1").Return(invoiceDto.Invoice{
+		ID:              "inv1",
+		InvoiceNumber:   "INV/202403/0001",
+		MedicalRecordID: "mr1",
+		Status:          constants.InvoiceIssued,
+	}, nil)
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+	suite.documentRepo.On("InsertDocument", mock.MatchedBy(func(doc documentDto.Document) bool {
+		return doc.DocumentType == constants.DocumentInvoice && doc.Signature == ""
+	})).Return("doc1", nil)
+
+	_, err := suite.documentUC.PrintInvoice(context.Background(), "inv1", "u1")
+
+	suite.Nil(err)
+	suite.documentRepo.AssertExpectations(suite.T())
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintInvoiceDraft() {
+	suite.invoiceUC.On("GetInvoiceByID", "inv1").Return(invoiceDto.Invoice{ID: "inv1", Status: constants.InvoiceDraft}, nil)
+
+	_, err := suite.documentUC.PrintInvoice(context.Background(), "inv1", "u1")
+
+	suite.EqualError(err, constants.ErrInvoiceNotPrintable)
+	suite.documentRepo.AssertNotCalled(suite.T(), "InsertDocument", mock.Anything)
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintPrescription() {
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+	suite.medicalRecordUC.On("GetMedicalRecordByID", "mr1").Return(record, nil)
+	suite.documentRepo.On("InsertDocument", issued(constants.DocumentPrescription)).Return("doc1", nil)
+
+	file, err := suite.documentUC.PrintPrescription(context.Background(), "mr1", "u1")
+
+	suite.Nil(err)
+	suite.Equal("prescription-mr1.pdf", file.FileName)
+	suite.Equal("%PDF", string(file.Content[:4]))
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintPrescriptionWithoutMedicines() {
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+	suite.medicalRecordUC.On("GetMedicalRecordByID", "mr1").Return(medicalRecordDTO.Medical_Record{ID: "mr1"}, nil)
+
+	_, err := suite.documentUC.PrintPrescription(context.Background(), "mr1", "u1")
+
+	suite.EqualError(err, constants.ErrNothingPrescribed)
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintVisitSummaryRecordNotExist() {
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(documentDto.Visit{}, sql.ErrNoRows)
+
+	_, err := suite.documentUC.PrintVisitSummary(context.Background(), "mr1", "u1")
+
+	suite.EqualError(err, constants.ErrMedicalRecordNotExist)
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintVisitSummary() {
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+	suite.medicalRecordUC.On("GetMedicalRecordByID", "mr1").Return(record, nil)
+	suite.documentRepo.On("InsertDocument", issued(constants.DocumentVisitSummary)).Return("doc1", nil)
+
+	file, err := suite.documentUC.PrintVisitSummary(context.Background(), "mr1", "u1")
+
+	suite.Nil(err)
+	suite.Equal("%PDF", string(file.Content[:4]))
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintSickLeave() {
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+	suite.documentRepo.On("InsertDocument", mock.MatchedBy(func(doc documentDto.Document) bool {
+		return doc.DocumentType == constants.DocumentSickLeave && doc.DoctorName == "Joko" &&
+			doc.ValidFrom == "2024-03-14" && doc.ValidUntil == "2024-03-16"
+	})).Return("doc1", nil)
+
+	file, err := suite.documentUC.PrintSickLeave(context.Background(), documentDto.SickLeaveRequest{MedicalRecordID: "mr1", Days: 3, IssuedBy: "u1"})
+
+	suite.Nil(err)
+	suite.Equal("sick-leave-mr1.pdf", file.FileName)
+	suite.Equal("%PDF", string(file.Content[:4]))
+}
+
+func (suite *documentUsecaseTestSuite) TestPrintSickLeaveInvalidDate() {
+	suite.documentRepo.On("RetrieveVisit", "mr1").Return(visit, nil)
+
+	_, err := suite.documentUC.PrintSickLeave(context.Background(), documentDto.SickLeaveRequest{MedicalRecordID: "mr1", StartDate: "14-03-2024", Days: 1})
+
+	suite.EqualError(err, constants.ErrDateFormat)
+}
+
+// signedLetter is a sick-leave letter as stored, with its signature made
+// with the key of the suite.
+func (suite *documentUsecaseTestSuite) signedLetter() documentDto.Document {
+	doc := documentDto.Document{
+		ID:           "doc1",
+		Code:         "ABCDEFGHIJKLMNOP",
+		DocumentType: constants.DocumentSickLeave,
+		ReferenceID:  "mr1",
+		DoctorName:   "Joko",
+		ValidFrom:    "2024-03-14",
+		ValidUntil:   "2024-03-16",
+		IssuedAt:     "2024-03-14 10:00:00",
+	}
+	doc.Signature = suite.documentUC.(*documentUsecase).sign(doc)
+	return doc
+}
+
+func (suite *documentUsecaseTestSuite) TestVerify() {
+	suite.documentRepo.On("RetrieveDocumentByCode", "ABCDEFGHIJKLMNOP").Return(suite.signedLetter(), nil)
+
+	verification, err := suite.documentUC.Verify(context.Background(), " abcdefghijklmnop")
+
+	suite.Nil(err)
+	suite.Equal(documentDto.Verification{
+		Code:         "ABCDEFGHIJKLMNOP",
+		Status:       constants.DocumentValid,
+		DocumentType: constants.DocumentSickLeave,
+		IssuedOn:     "2024-03-14",
+		DoctorName:   "Joko",
+		ValidFrom:    "2024-03-14",
+		ValidUntil:   "2024-03-16",
+	}, verification)
+}
+
+func (suite *documentUsecaseTestSuite) TestVerifyRevoked() {
+	doc := suite.signedLetter()
+	doc.RevokedAt = "2024-03-15 08:00:00"
+	suite.documentRepo.On("RetrieveDocumentByCode", "ABCDEFGHIJKLMNOP").Return(doc, nil)
+
+	verification, err := suite.documentUC.Verify(context.Background(), "ABCDEFGHIJKLMNOP")
+
+	suite.Nil(err)
+	suite.Equal(constants.DocumentRevoked, verification.Status)
+	suite.Equal("2024-03-15", verification.RevokedOn)
+}
+
+func (suite *documentUsecaseTestSuite) TestVerifyTampered() {
+	doc := suite.signedLetter()
+	doc.ValidUntil = "2024-03-30"
+	suite.documentRepo.On("RetrieveDocumentByCode", "ABCDEFGHIJKLMNOP").Return(doc, nil)
+
+	verification, err := suite.documentUC.Verify(context.Background(), "ABCDEFGHIJKLMNOP")
+
+	suite.Nil(err)
+	suite.Equal(documentDto.Verification{Code: "ABCDEFGHIJKLMNOP", Status: constants.DocumentTampered}, verification)
+}
+
+func (suite *documentUsecaseTestSuite) TestVerifyUnsigned() {
+	doc := suite.signedLetter()
+	doc.Signature = ""
+	suite.documentRepo.On("RetrieveDocumentByCode", "ABCDEFGHIJKLMNOP").Return(doc, nil)
+
+	verification, err := suite.documentUC.Verify(context.Background(), "ABCDEFGHIJKLMNOP")
+
+	suite.Nil(err)
+	suite.Equal(constants.DocumentUnsigned, verification.Status)
+	suite.Equal("2024-03-14", verification.IssuedOn)
+}
+
+func (suite *documentUsecaseTestSuite) TestVerifyWithoutSigningKey() {
+	doc := suite.signedLetter()
+	suite.documentUC = NewDocumentUsecase(suite.documentRepo, suite.medicalRecordUC, suite.invoiceUC, documentDto.Clinic{Name: "Avengers Clinic"})
+	suite.documentRepo.On("RetrieveDocumentByCode", "ABCDEFGHIJKLMNOP").Return(doc, nil)
+
+	verification, err := suite.documentUC.Verify(context.Background(), "ABCDEFGHIJKLMNOP")
+
+	suite.Nil(err)
+	suite.Equal(constants.DocumentUnsigned, verification.Status)
+	suite.Equal("Joko", verification.DoctorName)
+}
+
+func (suite *documentUsecaseTestSuite) TestVerifyNotExist() {
+	suite.documentRepo.On("RetrieveDocumentByCode", "UNKNOWN").Return(documentDto.Document{}, sql.ErrNoRows)
+
+	_, err := suite.documentUC.Verify(context.Background(), "unknown")
+
+	suite.EqualError(err, constants.ErrDocumentNotExist)
+}
+
+func (suite *documentUsecaseTestSuite) TestRevokeDocument() {
+	suite.documentRepo.On("RevokeDocument", "doc1", documentDto.RevokeRequest{Reason: "issued by mistake", RevokedBy: "u1"}).Return(nil)
+
+	err := suite.documentUC.RevokeDocument(context.Background(), "doc1", documentDto.RevokeRequest{Reason: " issued by mistake ", RevokedBy: "u1"})
+
+	suite.Nil(err)
+}
+
+func (suite *documentUsecaseTestSuite) TestRupiah() {
+	suite.Equal("Rp 0", rupiah(0))
+	suite.Equal("Rp 950", rupiah(950))
+	suite.Equal("Rp 1.250.000", rupiah(1250000))
+	suite.Equal("-Rp 10.000", rupiah(-10000))
+}
+
+func TestDocumentUsecase(t *testing.T) {
+	suite.Run(t, new(documentUsecaseTestSuite))
+}
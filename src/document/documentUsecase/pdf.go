@@ -0,0 +1,154 @@
+package documentUsecase
+
+import (
+	"avengers-clinic/model/dto/documentDto"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	pageWidth  = 210.0
+	margin     = 15.0
+	lineHeight = 6.0
+	qrSize     = 30.0
+)
+
+// page is an A4 document with the clinic header and a verification footer.
+// The core fonts only know Latin-1, so text goes through translate first.
+type page struct {
+	pdf       *gofpdf.Fpdf
+	translate func(string) string
+}
+
+func newPage(clinic documentDto.Clinic, title string) *page {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin+qrSize)
+	pdf.AddPage()
+
+	p := &page{pdf: pdf, translate: pdf.UnicodeTranslatorFromDescriptor("")}
+
+	left := margin
+	if clinic.LogoFile != "" {
+		pdf.ImageOptions(clinic.LogoFile, margin, margin, 0, 20, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+		left += 25
+	}
+
+	pdf.SetXY(left, margin)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, p.translate(clinic.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range []string{clinic.Address, clinic.Phone} {
+		if line != "" {
+			pdf.SetX(left)
+			pdf.CellFormat(0, 5, p.translate(line), "", 1, "L", false, 0, "")
+		}
+	}
+
+	pdf.SetY(margin + 22)
+	pdf.Line(margin, pdf.GetY(), pageWidth-margin, pdf.GetY())
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, p.translate(title), "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "", 10)
+	return p
+}
+
+// field writes a label and its value on one line.
+func (p *page) field(label, value string) {
+	p.pdf.SetFont("Helvetica", "", 10)
+	p.pdf.CellFormat(40, lineHeight, p.translate(label), "", 0, "L", false, 0, "")
+	p.pdf.MultiCell(0, lineHeight, p.translate(": "+value), "", "L", false)
+}
+
+func (p *page) heading(text string) {
+	p.pdf.Ln(3)
+	p.pdf.SetFont("Helvetica", "B", 11)
+	p.pdf.CellFormat(0, lineHeight+1, p.translate(text), "", 1, "L", false, 0, "")
+	p.pdf.SetFont("Helvetica", "", 10)
+}
+
+func (p *page) paragraph(text string) {
+	p.pdf.SetFont("Helvetica", "", 10)
+	p.pdf.MultiCell(0, lineHeight, p.translate(text), "", "L", false)
+}
+
+// table writes a bordered table. Columns after the first are right aligned
+// when align is "R".
+func (p *page) table(widths []float64, align []string, header []string, rows [][]string) {
+	p.pdf.SetFont("Helvetica", "B", 10)
+	for i, column := range header {
+		p.pdf.CellFormat(widths[i], lineHeight+1, p.translate(column), "1", 0, align[i], false, 0, "")
+	}
+	p.pdf.Ln(-1)
+
+	p.pdf.SetFont("Helvetica", "", 10)
+	for _, row := range rows {
+		for i, value := range row {
+			p.pdf.CellFormat(widths[i], lineHeight+1, p.translate(value), "1", 0, align[i], false, 0, "")
+		}
+		p.pdf.Ln(-1)
+	}
+}
+
+// signature leaves room for the doctor to sign above their name.
+func (p *page) signature(place, name string) {
+	p.pdf.Ln(8)
+	x := pageWidth - margin - 60
+	p.pdf.SetX(x)
+	p.pdf.CellFormat(60, lineHeight, p.translate(place), "", 1, "C", false, 0, "")
+	p.pdf.Ln(18)
+	p.pdf.SetX(x)
+	p.pdf.SetFont("Helvetica", "B", 10)
+	p.pdf.CellFormat(60, lineHeight, p.translate(name), "T", 1, "C", false, 0, "")
+	p.pdf.SetFont("Helvetica", "", 10)
+}
+
+// output adds the QR code linking to the verification of the document at the
+// bottom of the last page and renders the file.
+func (p *page) output(verifyURL string, document documentDto.Document) ([]byte, error) {
+	link := verifyURL + "/" + document.Code
+	png, err := qrcode.Encode(link, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	p.pdf.SetAutoPageBreak(false, 0)
+	top := 297 - margin - qrSize
+	p.pdf.RegisterImageOptionsReader("qr", gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+	p.pdf.ImageOptions("qr", margin, top, qrSize, qrSize, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, link)
+
+	p.pdf.SetFont("Helvetica", "", 8)
+	p.pdf.SetXY(margin+qrSize+4, top+8)
+	p.pdf.CellFormat(0, 4, "Scan to verify this document or open", "", 2, "L", false, 0, "")
+	p.pdf.CellFormat(0, 4, p.translate(link), "", 2, "L", false, 0, link)
+	p.pdf.CellFormat(0, 4, p.translate("Verification code "+document.Code+", issued "+document.IssuedAt), "", 2, "L", false, 0, "")
+	p.pdf.CellFormat(0, 4, "Signature "+document.Signature, "", 2, "L", false, 0, "")
+
+	var buffer bytes.Buffer
+	if err := p.pdf.Output(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// rupiah formats an amount with dots between thousands, as in "Rp 1.250.000".
+func rupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	digits := strconv.Itoa(amount)
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	return sign + "Rp " + strings.Join(append([]string{digits}, groups...), ".")
+}
@@ -0,0 +1,141 @@
+package fhirDelivery
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/src/fhir"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fhirDelivery struct {
+	fhirUC fhir.FhirUsecase
+}
+
+// NewFhirDelivery serves the FHIR endpoints. Unlike the rest of the API they
+// answer with bare FHIR resources, and with an OperationOutcome on errors, so
+// that FHIR clients can read them.
+func NewFhirDelivery(v1Group *gin.RouterGroup, fhirUC fhir.FhirUsecase) {
+	handler := fhirDelivery{fhirUC}
+
+	fhirGroup := v1Group.Group("/fhir")
+	{
+		fhirGroup.POST("", middleware.JwtAuth("ADMIN"), handler.Import)
+		fhirGroup.GET("/:type", middleware.JwtAuth("ADMIN"), handler.Search)
+		fhirGroup.GET("/:type/:id", middleware.JwtAuth("ADMIN"), handler.Read)
+		fhirGroup.GET("/:type/:id/:operation", middleware.JwtAuth("ADMIN"), handler.Everything)
+	}
+}
+
+func (delivery *fhirDelivery) Read(c *gin.Context) {
+	resource, err := delivery.fhirUC.Read(c.Request.Context(), c.Param("type"), c.Param("id"))
+	if err != nil {
+		fhirError(c, err)
+		return
+	}
+
+	send(c, http.StatusOK, resource)
+}
+
+// Search supports the patient, practitioner and encounter references, plus
+// identifier and name on patients.
+func (delivery *fhirDelivery) Search(c *gin.Context) {
+	filter := fhirDto.Filter{
+		ID:           c.Query("_id"),
+		Patient:      strings.TrimPrefix(c.Query("patient"), constants.FhirPatient+"/"),
+		Practitioner: strings.TrimPrefix(c.Query("practitioner"), constants.FhirPractitioner+"/"),
+		Encounter:    strings.TrimPrefix(c.Query("encounter"), constants.FhirEncounter+"/"),
+		Identifier:   tokenValue(c.Query("identifier")),
+		Name:         c.Query("name"),
+	}
+
+	bundle, err := delivery.fhirUC.Search(c.Request.Context(), c.Param("type"), filter)
+	if err != nil {
+		fhirError(c, err)
+		return
+	}
+
+	send(c, http.StatusOK, bundle)
+}
+
+// Everything serves Patient/:id/$everything, the only operation supported.
+func (delivery *fhirDelivery) Everything(c *gin.Context) {
+	if c.Param("type") != constants.FhirPatient || c.Param("operation") != "$everything" {
+		fhirError(c, errors.New(constants.ErrFhirResourceNotSupported))
+		return
+	}
+
+	bundle, err := delivery.fhirUC.Everything(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		fhirError(c, err)
+		return
+	}
+
+	send(c, http.StatusOK, bundle)
+}
+
+func (delivery *fhirDelivery) Import(c *gin.Context) {
+	var bundle fhirDto.IncomingBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		outcome(c, http.StatusBadRequest, "structure", err.Error())
+		return
+	}
+
+	response, err := delivery.fhirUC.Import(c.Request.Context(), bundle)
+	if err != nil {
+		fhirError(c, err)
+		return
+	}
+
+	send(c, http.StatusOK, response)
+}
+
+// tokenValue drops the system of a token search parameter, as in
+// identifier=https://fhir.kemkes.go.id/id/nik|3171...
+func tokenValue(token string) string {
+	if i := strings.LastIndex(token, "|"); i >= 0 {
+		return token[i+1:]
+	}
+	return token
+}
+
+func send(c *gin.Context, status int, resource interface{}) {
+	c.Header("Content-Type", "application/fhir+json; charset=utf-8")
+	c.JSON(status, resource)
+}
+
+func outcome(c *gin.Context, status int, code, diagnostics string) {
+	send(c, status, fhirDto.OperationOutcome{
+		ResourceType: constants.FhirOperationOutcome,
+		Issue:        []fhirDto.OperationOutcomeIssue{{Severity: "error", Code: code, Diagnostics: diagnostics}},
+	})
+}
+
+func fhirError(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		outcome(c, http.StatusNotFound, "not-found", "Resource not found")
+		return
+	}
+
+	var invalid *fhirDto.ValidationError
+	if errors.As(err, &invalid) {
+		send(c, http.StatusBadRequest, fhirDto.OperationOutcome{ResourceType: constants.FhirOperationOutcome, Issue: invalid.Issues})
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrFhirResourceNotSupported:
+		outcome(c, http.StatusNotFound, "not-supported", err.Error())
+		return
+	case constants.ErrFhirInvalidBundle:
+		outcome(c, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+
+	outcome(c, http.StatusInternalServerError, "exception", err.Error())
+}
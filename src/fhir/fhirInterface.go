@@ -0,0 +1,23 @@
+package fhir
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"context"
+)
+
+type FhirRepository interface {
+	RetrievePatients(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.PatientRow, error)
+	RetrievePractitioners(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.PractitionerRow, error)
+	RetrieveAppointments(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.AppointmentRow, error)
+	RetrieveEncounters(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.EncounterRow, error)
+	RetrieveMedications(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.MedicationRow, error)
+	RetrieveProcedures(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.ProcedureRow, error)
+	ImportPatients(ctx context.Context, profiles []fhirDto.PatientProfile) ([]fhirDto.ImportResult, error)
+}
+
+type FhirUsecase interface {
+	Read(ctx context.Context, resourceType, id string) (interface{}, error)
+	Search(ctx context.Context, resourceType string, filter fhirDto.Filter) (fhirDto.Bundle, error)
+	Everything(ctx context.Context, patientID string) (fhirDto.Bundle, error)
+	Import(ctx context.Context, bundle fhirDto.IncomingBundle) (fhirDto.Bundle, error)
+}
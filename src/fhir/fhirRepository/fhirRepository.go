@@ -0,0 +1,280 @@
+package fhirRepository
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/fhir"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type fhirRepository struct {
+	db *sql.DB
+}
+
+func NewFhirRepository(db *sql.DB) fhir.FhirRepository {
+	return &fhirRepository{db}
+}
+
+// RetrievePatients finds patients by id, NIK or part of their name. Patients
+// that were never imported have no profile and are named by their username.
+func (repository *fhirRepository) RetrievePatients(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.PatientRow, error) {
+	query := `
+		SELECT u.id, u.username, COALESCE(p.nik, ''), COALESCE(p.full_name, ''), COALESCE(p.gender::text, 'unknown'),
+			COALESCE(p.birth_date::text, ''), COALESCE(p.phone, ''), COALESCE(p.address, ''),
+			TO_CHAR(COALESCE(p.updated_at, p.created_at, u.updated_at, u.created_at), 'YYYY-MM-DD HH24:MI:SS')
+		FROM users u
+		LEFT JOIN patient_profiles p ON p.user_id = u.id
+		WHERE u.role = 'PATIENT' AND u.deleted_at IS NULL AND ($1 = '' OR u.id::text = $1) AND ($2 = '' OR p.nik = $2)
+			AND ($3 = '' OR COALESCE(p.full_name, u.username) ILIKE '%' || $3 || '%')
+		ORDER BY u.username;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.ID, filter.Identifier, filter.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patients []fhirDto.PatientRow
+	for rows.Next() {
+		var patient fhirDto.PatientRow
+		if err := rows.Scan(&patient.ID, &patient.Username, &patient.NIK, &patient.FullName, &patient.Gender,
+			&patient.BirthDate, &patient.Phone, &patient.Address, &patient.UpdatedAt); err != nil {
+			return nil, err
+		}
+		patients = append(patients, patient)
+	}
+	return patients, nil
+}
+
+func (repository *fhirRepository) RetrievePractitioners(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.PractitionerRow, error) {
+	query := `
+		SELECT id, username, COALESCE(specialization, ''), TO_CHAR(COALESCE(updated_at, created_at), 'YYYY-MM-DD HH24:MI:SS')
+		FROM users
+		WHERE role = 'DOCTOR' AND deleted_at IS NULL AND ($1 = '' OR id::text = $1)
+		ORDER BY username;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var practitioners []fhirDto.PractitionerRow
+	for rows.Next() {
+		var practitioner fhirDto.PractitionerRow
+		if err := rows.Scan(&practitioner.ID, &practitioner.Username, &practitioner.Specialization, &practitioner.UpdatedAt); err != nil {
+			return nil, err
+		}
+		practitioners = append(practitioners, practitioner)
+	}
+	return practitioners, nil
+}
+
+func (repository *fhirRepository) RetrieveAppointments(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.AppointmentRow, error) {
+	query := `
+		SELECT b.id, b.patient_id, COALESCE(pp.full_name, p.username), s.doctor_id, d.username, s.schedule_date::text,
+			t.start_at::text, t.end_at::text, b.status, b.complaint, TO_CHAR(b.created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM bookings b
+		JOIN users p ON p.id = b.patient_id
+		LEFT JOIN patient_profiles pp ON pp.user_id = p.id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users d ON d.id = s.doctor_id
+		JOIN mst_schedule_time t ON t.id = b.mst_schedule_id
+		WHERE b.deleted_at IS NULL AND ($1 = '' OR b.id::text = $1) AND ($2 = '' OR b.patient_id::text = $2)
+			AND ($3 = '' OR s.doctor_id::text = $3)
+		ORDER BY s.schedule_date, t.start_at;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.ID, filter.Patient, filter.Practitioner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []fhirDto.AppointmentRow
+	for rows.Next() {
+		var appointment fhirDto.AppointmentRow
+		if err := rows.Scan(&appointment.ID, &appointment.PatientID, &appointment.PatientName, &appointment.DoctorID,
+			&appointment.DoctorName, &appointment.ScheduleDate, &appointment.StartAt, &appointment.EndAt, &appointment.Status,
+			&appointment.Complaint, &appointment.CreatedAt); err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, appointment)
+	}
+	return appointments, nil
+}
+
+func (repository *fhirRepository) RetrieveEncounters(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.EncounterRow, error) {
+	query := `
+		SELECT r.id, b.id, b.patient_id, COALESCE(pp.full_name, p.username), s.doctor_id, d.username, s.schedule_date::text,
+			t.start_at::text, t.end_at::text, r.diagnosis_results, TO_CHAR(r.created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM medical_records r
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN users p ON p.id = b.patient_id
+		LEFT JOIN patient_profiles pp ON pp.user_id = p.id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users d ON d.id = s.doctor_id
+		JOIN mst_schedule_time t ON t.id = b.mst_schedule_id
+		WHERE r.deleted_at IS NULL AND ($1 = '' OR r.id::text = $1) AND ($2 = '' OR b.patient_id::text = $2)
+			AND ($3 = '' OR s.doctor_id::text = $3)
+		ORDER BY r.created_at;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.ID, filter.Patient, filter.Practitioner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var encounters []fhirDto.EncounterRow
+	for rows.Next() {
+		var encounter fhirDto.EncounterRow
+		if err := rows.Scan(&encounter.ID, &encounter.BookingID, &encounter.PatientID, &encounter.PatientName,
+			&encounter.DoctorID, &encounter.DoctorName, &encounter.ScheduleDate, &encounter.StartAt, &encounter.EndAt,
+			&encounter.Diagnosis, &encounter.CreatedAt); err != nil {
+			return nil, err
+		}
+		encounters = append(encounters, encounter)
+	}
+	return encounters, nil
+}
+
+func (repository *fhirRepository) RetrieveMedications(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.MedicationRow, error) {
+	query := `
+		SELECT md.id, r.id, b.patient_id, s.doctor_id, m.name, m.medicine_type, md.quantity, COALESCE(r.payment_status, false),
+			TO_CHAR(md.created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM medical_record_medicine_details md
+		JOIN medicines m ON m.id = md.medicine_id
+		JOIN medical_records r ON r.id = md.medical_record_id
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		WHERE md.deleted_at IS NULL AND r.deleted_at IS NULL AND ($1 = '' OR md.id::text = $1)
+			AND ($2 = '' OR b.patient_id::text = $2) AND ($3 = '' OR r.id::text = $3)
+		ORDER BY md.created_at;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.ID, filter.Patient, filter.Encounter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var medications []fhirDto.MedicationRow
+	for rows.Next() {
+		var medication fhirDto.MedicationRow
+		if err := rows.Scan(&medication.ID, &medication.MedicalRecordID, &medication.PatientID, &medication.DoctorID,
+			&medication.MedicineName, &medication.MedicineType, &medication.Quantity, &medication.Paid,
+			&medication.CreatedAt); err != nil {
+			return nil, err
+		}
+		medications = append(medications, medication)
+	}
+	return medications, nil
+}
+
+func (repository *fhirRepository) RetrieveProcedures(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.ProcedureRow, error) {
+	query := `
+		SELECT ad.id, r.id, b.patient_id, s.doctor_id, a.name, TO_CHAR(ad.created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM medical_record_action_details ad
+		JOIN actions a ON a.id = ad.action_id
+		JOIN medical_records r ON r.id = ad.medical_record_id
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		WHERE ad.deleted_at IS NULL AND r.deleted_at IS NULL AND ($1 = '' OR ad.id::text = $1)
+			AND ($2 = '' OR b.patient_id::text = $2) AND ($3 = '' OR r.id::text = $3)
+		ORDER BY ad.created_at;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.ID, filter.Patient, filter.Encounter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var procedures []fhirDto.ProcedureRow
+	for rows.Next() {
+		var procedure fhirDto.ProcedureRow
+		if err := rows.Scan(&procedure.ID, &procedure.MedicalRecordID, &procedure.PatientID, &procedure.DoctorID,
+			&procedure.ActionName, &procedure.CreatedAt); err != nil {
+			return nil, err
+		}
+		procedures = append(procedures, procedure)
+	}
+	return procedures, nil
+}
+
+// ImportPatients creates a patient user with a profile for each imported
+// patient in one transaction. A patient whose NIK is already known has their
+// profile updated instead.
+func (repository *fhirRepository) ImportPatients(ctx context.Context, profiles []fhirDto.PatientProfile) ([]fhirDto.ImportResult, error) {
+	var results []fhirDto.ImportResult
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		for _, profile := range profiles {
+			var result fhirDto.ImportResult
+			if profile.NIK != "" {
+				err := tx.QueryRowContext(ctx, "SELECT user_id FROM patient_profiles WHERE nik = $1 FOR UPDATE;", profile.NIK).Scan(&result.ID)
+				if err != nil && err != sql.ErrNoRows {
+					return err
+				}
+			}
+
+			if result.ID != "" {
+				query := `
+					UPDATE patient_profiles SET full_name = $1, gender = $2, birth_date = $3, phone = $4, address = $5, updated_at = $6
+					WHERE user_id = $7;`
+				if _, err := tx.ExecContext(ctx, query, nullable(profile.FullName), profile.Gender, nullable(profile.BirthDate),
+					nullable(profile.Phone), nullable(profile.Address), now, result.ID); err != nil {
+					return err
+				}
+				results = append(results, result)
+				continue
+			}
+
+			username, err := availableUsername(ctx, tx, profile.Username)
+			if err != nil {
+				return err
+			}
+
+			query := "INSERT INTO users (username, password, role, created_at) VALUES ($1, $2, 'PATIENT', $3) RETURNING id;"
+			if err := tx.QueryRowContext(ctx, query, username, profile.Password, now).Scan(&result.ID); err != nil {
+				return err
+			}
+
+			query = `
+				INSERT INTO patient_profiles (user_id, nik, full_name, gender, birth_date, phone, address, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+			if _, err := tx.ExecContext(ctx, query, result.ID, nullable(profile.NIK), nullable(profile.FullName), profile.Gender,
+				nullable(profile.BirthDate), nullable(profile.Phone), nullable(profile.Address), now); err != nil {
+				return err
+			}
+
+			result.Created = true
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// availableUsername numbers the username when it is already taken.
+func availableUsername(ctx context.Context, tx *sql.Tx, username string) (string, error) {
+	candidate := username
+	for i := 2; ; i++ {
+		var taken bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE username = $1);", candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", username, i)
+	}
+}
+
+// nullable stores empty strings as NULL for the optional columns.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,129 @@
+package fhirRepository
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/src/fhir"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type fhirRepositoryTestSuite struct {
+	suite.Suite
+	fhirRepo fhir.FhirRepository
+	mock     sqlmock.Sqlmock
+}
+
+func (suite *fhirRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.fhirRepo = NewFhirRepository(db)
+	suite.mock = mock
+}
+
+func (suite *fhirRepositoryTestSuite) TestRetrievePatients() {
+	rows := sqlmock.NewRows([]string{"id", "username", "nik", "full_name", "gender", "birth_date", "phone", "address", "updated_at"}).
+		AddRow("p1", "budi", "3171234567890123", "Budi Santoso", "male", "1985-07-12", "0812", "Jakarta", "2024-03-01 08:00:00")
+	suite.mock.ExpectQuery(`FROM users u\s+LEFT JOIN patient_profiles p`).
+		WithArgs("", "3171234567890123", "").
+		WillReturnRows(rows)
+
+	patients, err := suite.fhirRepo.RetrievePatients(context.Background(), fhirDto.Filter{Identifier: "3171234567890123"})
+
+	suite.Nil(err)
+	suite.Equal([]fhirDto.PatientRow{{
+		ID: "p1", Username: "budi", NIK: "3171234567890123", FullName: "Budi Santoso", Gender: "male",
+		BirthDate: "1985-07-12", Phone: "0812", Address: "Jakarta", UpdatedAt: "2024-03-01 08:00:00",
+	}}, patients)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *fhirRepositoryTestSuite) TestRetrieveAppointments() {
+	rows := sqlmock.NewRows([]string{"id", "patient_id", "patient", "doctor_id", "doctor", "date", "start_at", "end_at", "status", "complaint", "created_at"}).
+		AddRow("b1", "p1", "budi", "d1", "joko", "2024-03-04", "09:00:00", "10:00:00", "DONE", "Fever", "2024-03-01 08:00:00")
+	suite.mock.ExpectQuery(`JOIN mst_schedule_time t ON t.id = b.mst_schedule_id`).
+		WithArgs("", "p1", "").
+		WillReturnRows(rows)
+
+	appointments, err := suite.fhirRepo.RetrieveAppointments(context.Background(), fhirDto.Filter{Patient: "p1"})
+
+	suite.Nil(err)
+	suite.Equal([]fhirDto.AppointmentRow{{
+		ID: "b1", PatientID: "p1", PatientName: "budi", DoctorID: "d1", DoctorName: "joko", ScheduleDate: "2024-03-04",
+		StartAt: "09:00:00", EndAt: "10:00:00", Status: "DONE", Complaint: "Fever", CreatedAt: "2024-03-01 08:00:00",
+	}}, appointments)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *fhirRepositoryTestSuite) TestRetrieveMedications() {
+	rows := sqlmock.NewRows([]string{"id", "record_id", "patient_id", "doctor_id", "name", "type", "quantity", "paid", "created_at"}).
+		AddRow("m1", "r1", "p1", "d1", "Paracetamol", "TABLET", 10, true, "2024-03-04 09:40:00")
+	suite.mock.ExpectQuery(`FROM medical_record_medicine_details md`).
+		WithArgs("", "", "r1").
+		WillReturnRows(rows)
+
+	medications, err := suite.fhirRepo.RetrieveMedications(context.Background(), fhirDto.Filter{Encounter: "r1"})
+
+	suite.Nil(err)
+	suite.Equal([]fhirDto.MedicationRow{{
+		ID: "m1", MedicalRecordID: "r1", PatientID: "p1", DoctorID: "d1", MedicineName: "Paracetamol",
+		MedicineType: "TABLET", Quantity: 10, Paid: true, CreatedAt: "2024-03-04 09:40:00",
+	}}, medications)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *fhirRepositoryTestSuite) TestImportPatients() {
+	profiles := []fhirDto.PatientProfile{
+		{NIK: "3171234567890123", FullName: "Budi Santoso", Gender: "male", BirthDate: "1985-07-12"},
+		{Username: "sitirahayu", Password: "hash", FullName: "Siti Rahayu", Gender: "female"},
+	}
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT user_id FROM patient_profiles WHERE nik = \$1`).
+		WithArgs("3171234567890123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("p1"))
+	suite.mock.ExpectExec(`UPDATE patient_profiles SET full_name = \$1`).
+		WithArgs("Budi Santoso", "male", "1985-07-12", nil, nil, sqlmock.AnyArg(), "p1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("sitirahayu").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	suite.mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("sitirahayu2").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	suite.mock.ExpectQuery(`INSERT INTO users`).
+		WithArgs("sitirahayu2", "hash", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	suite.mock.ExpectExec(`INSERT INTO patient_profiles`).
+		WithArgs("p2", nil, "Siti Rahayu", "female", nil, nil, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	results, err := suite.fhirRepo.ImportPatients(context.Background(), profiles)
+
+	suite.Nil(err)
+	suite.Equal([]fhirDto.ImportResult{{ID: "p1"}, {ID: "p2", Created: true}}, results)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *fhirRepositoryTestSuite) TestImportPatientsRollsBack() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("siti").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	suite.mock.ExpectQuery(`INSERT INTO users`).
+		WillReturnError(errors.New("insert failed"))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.fhirRepo.ImportPatients(context.Background(), []fhirDto.PatientProfile{{Username: "siti", Password: "hash", Gender: "unknown"}})
+
+	suite.EqualError(err, "insert failed")
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func TestFhirRepository(t *testing.T) {
+	suite.Run(t, new(fhirRepositoryTestSuite))
+}
@@ -0,0 +1,215 @@
+package fhirUsecase
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/fhir"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type fhirUsecase struct {
+	fhirRepo fhir.FhirRepository
+	baseURL  string
+}
+
+// NewFhirUsecase takes the public base URL of the FHIR endpoints, which the
+// entries of returned bundles are addressed by.
+func NewFhirUsecase(fhirRepo fhir.FhirRepository, baseURL string) fhir.FhirUsecase {
+	return &fhirUsecase{fhirRepo, baseURL}
+}
+
+func (usecase *fhirUsecase) Read(ctx context.Context, resourceType, id string) (interface{}, error) {
+	resources, err := usecase.resources(ctx, resourceType, fhirDto.Filter{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resources) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return resources[0].resource, nil
+}
+
+func (usecase *fhirUsecase) Search(ctx context.Context, resourceType string, filter fhirDto.Filter) (fhirDto.Bundle, error) {
+	resources, err := usecase.resources(ctx, resourceType, filter)
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	return usecase.searchset(resources), nil
+}
+
+// Everything exports a patient with their appointments, visits and what was
+// prescribed and done in them, plus the doctors involved.
+func (usecase *fhirUsecase) Everything(ctx context.Context, patientID string) (fhirDto.Bundle, error) {
+	filter := fhirDto.Filter{Patient: patientID}
+	patients, err := usecase.resources(ctx, constants.FhirPatient, fhirDto.Filter{ID: patientID})
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	if len(patients) == 0 {
+		return fhirDto.Bundle{}, sql.ErrNoRows
+	}
+
+	appointments, err := usecase.fhirRepo.RetrieveAppointments(ctx, filter)
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	encounters, err := usecase.fhirRepo.RetrieveEncounters(ctx, filter)
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	medications, err := usecase.fhirRepo.RetrieveMedications(ctx, filter)
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	procedures, err := usecase.fhirRepo.RetrieveProcedures(ctx, filter)
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	resources := patients
+	doctors := map[string]bool{}
+	today := time.Now().Format("2006-01-02")
+	for _, row := range appointments {
+		doctors[row.DoctorID] = true
+		resources = append(resources, entry(constants.FhirAppointment, row.ID, appointment(row, today)))
+	}
+
+	for _, row := range encounters {
+		doctors[row.DoctorID] = true
+		resources = append(resources, entry(constants.FhirEncounter, row.ID, encounter(row)),
+			entry(constants.FhirCondition, row.ID, condition(row)))
+	}
+
+	for _, row := range medications {
+		resources = append(resources, entry(constants.FhirMedicationRequest, row.ID, medicationRequest(row)))
+	}
+
+	for _, row := range procedures {
+		resources = append(resources, entry(constants.FhirProcedure, row.ID, procedure(row)))
+	}
+
+	if len(doctors) > 0 {
+		practitioners, err := usecase.fhirRepo.RetrievePractitioners(ctx, fhirDto.Filter{})
+		if err != nil {
+			return fhirDto.Bundle{}, err
+		}
+
+		for _, row := range practitioners {
+			if doctors[row.ID] {
+				included := entry(constants.FhirPractitioner, row.ID, practitioner(row))
+				included.mode = "include"
+				resources = append(resources, included)
+			}
+		}
+	}
+
+	return usecase.searchset(resources), nil
+}
+
+// resourceEntry is a resource found by a search. Its mode tells whether it
+// matched the search or was only included because a match refers to it.
+type resourceEntry struct {
+	resourceType string
+	id           string
+	resource     interface{}
+	mode         string
+}
+
+func entry(resourceType, id string, resource interface{}) resourceEntry {
+	return resourceEntry{resourceType, id, resource, "match"}
+}
+
+// resources finds the resources of one type matching the search parameters.
+// Conditions share their id with the encounter they were diagnosed in.
+func (usecase *fhirUsecase) resources(ctx context.Context, resourceType string, filter fhirDto.Filter) ([]resourceEntry, error) {
+	var resources []resourceEntry
+	switch resourceType {
+	case constants.FhirPatient:
+		rows, err := usecase.fhirRepo.RetrievePatients(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			resources = append(resources, entry(resourceType, row.ID, patient(row)))
+		}
+	case constants.FhirPractitioner:
+		rows, err := usecase.fhirRepo.RetrievePractitioners(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			resources = append(resources, entry(resourceType, row.ID, practitioner(row)))
+		}
+	case constants.FhirAppointment:
+		rows, err := usecase.fhirRepo.RetrieveAppointments(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		today := time.Now().Format("2006-01-02")
+		for _, row := range rows {
+			resources = append(resources, entry(resourceType, row.ID, appointment(row, today)))
+		}
+	case constants.FhirEncounter, constants.FhirCondition:
+		if resourceType == constants.FhirCondition && filter.ID == "" {
+			filter.ID = filter.Encounter
+		}
+		rows, err := usecase.fhirRepo.RetrieveEncounters(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if resourceType == constants.FhirCondition {
+				resources = append(resources, entry(resourceType, row.ID, condition(row)))
+				continue
+			}
+			resources = append(resources, entry(resourceType, row.ID, encounter(row)))
+		}
+	case constants.FhirMedicationRequest:
+		rows, err := usecase.fhirRepo.RetrieveMedications(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			resources = append(resources, entry(resourceType, row.ID, medicationRequest(row)))
+		}
+	case constants.FhirProcedure:
+		rows, err := usecase.fhirRepo.RetrieveProcedures(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			resources = append(resources, entry(resourceType, row.ID, procedure(row)))
+		}
+	default:
+		return nil, errors.New(constants.ErrFhirResourceNotSupported)
+	}
+	return resources, nil
+}
+
+func (usecase *fhirUsecase) searchset(resources []resourceEntry) fhirDto.Bundle {
+	total := len(resources)
+	bundle := fhirDto.Bundle{
+		ResourceType: constants.FhirBundle,
+		Type:         "searchset",
+		Timestamp:    time.Now().Format(time.RFC3339),
+		Total:        &total,
+	}
+
+	for _, resource := range resources {
+		bundle.Entry = append(bundle.Entry, fhirDto.BundleEntry{
+			FullURL:  usecase.baseURL + "/" + resource.resourceType + "/" + resource.id,
+			Resource: resource.resource,
+			Search:   &fhirDto.BundleSearch{Mode: resource.mode},
+		})
+	}
+	return bundle
+}
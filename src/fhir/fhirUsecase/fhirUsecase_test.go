@@ -0,0 +1,456 @@
+package fhirUsecase
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/fhir"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockFhirRepository struct {
+	mock.Mock
+}
+
+func (m *mockFhirRepository) RetrievePatients(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.PatientRow, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]fhirDto.PatientRow), args.Error(1)
+}
+
+func (m *mockFhirRepository) RetrievePractitioners(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.PractitionerRow, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]fhirDto.PractitionerRow), args.Error(1)
+}
+
+func (m *mockFhirRepository) RetrieveAppointments(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.AppointmentRow, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]fhirDto.AppointmentRow), args.Error(1)
+}
+
+func (m *mockFhirRepository) RetrieveEncounters(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.EncounterRow, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]fhirDto.EncounterRow), args.Error(1)
+}
+
+func (m *mockFhirRepository) RetrieveMedications(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.MedicationRow, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]fhirDto.MedicationRow), args.Error(1)
+}
+
+func (m *mockFhirRepository) RetrieveProcedures(ctx context.Context, filter fhirDto.Filter) ([]fhirDto.ProcedureRow, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]fhirDto.ProcedureRow), args.Error(1)
+}
+
+func (m *mockFhirRepository) ImportPatients(ctx context.Context, profiles []fhirDto.PatientProfile) ([]fhirDto.ImportResult, error) {
+	args := m.Called(profiles)
+	return args.Get(0).([]fhirDto.ImportResult), args.Error(1)
+}
+
+const baseURL = "http://clinic.test/api/v1/fhir"
+
+type fhirUsecaseTestSuite struct {
+	suite.Suite
+	fhirRepo *mockFhirRepository
+	fhirUC   fhir.FhirUsecase
+}
+
+func (suite *fhirUsecaseTestSuite) SetupTest() {
+	suite.fhirRepo = new(mockFhirRepository)
+	suite.fhirUC = NewFhirUsecase(suite.fhirRepo, baseURL)
+}
+
+var (
+	patientRow = fhirDto.PatientRow{
+		ID: "p1", Username: "budi", NIK: "3171234567890123", FullName: "Budi Santoso", Gender: "male",
+		BirthDate: "1985-07-12", Phone: "081234567890", Address: "Jl. Merdeka No. 10", UpdatedAt: "2024-03-01 08:00:00",
+	}
+	encounterRow = fhirDto.EncounterRow{
+		ID: "r1", BookingID: "b1", PatientID: "p1", PatientName: "Budi Santoso", DoctorID: "d1", DoctorName: "joko",
+		ScheduleDate: "2024-03-04", StartAt: "09:00:00", EndAt: "10:00:00", Diagnosis: "Common cold", CreatedAt: "2024-03-04 09:40:00",
+	}
+)
+
+func appointmentRow(id, status, date string) fhirDto.AppointmentRow {
+	return fhirDto.AppointmentRow{
+		ID: id, PatientID: "p1", PatientName: "Budi Santoso", DoctorID: "d1", DoctorName: "joko", ScheduleDate: date,
+		StartAt: "09:00:00", EndAt: "10:00:00", Status: status, Complaint: "Fever", CreatedAt: "2024-03-01 08:00:00",
+	}
+}
+
+func (suite *fhirUsecaseTestSuite) TestReadPatient() {
+	suite.fhirRepo.On("RetrievePatients", fhirDto.Filter{ID: "p1"}).Return([]fhirDto.PatientRow{patientRow}, nil)
+
+	resource, err := suite.fhirUC.Read(context.Background(), constants.FhirPatient, "p1")
+
+	suite.Nil(err)
+	patient := resource.(fhirDto.Patient)
+	suite.Equal("Budi Santoso", patient.Name[0].Text)
+	suite.Equal([]fhirDto.Identifier{
+		{Use: "usual", System: constants.FhirPatientSystem, Value: "p1"},
+		{Use: "official", System: constants.FhirNIKSystem, Value: "3171234567890123"},
+	}, patient.Identifier)
+	suite.Empty(suite.validate(resource))
+}
+
+func (suite *fhirUsecaseTestSuite) TestReadPatientWithoutProfile() {
+	row := fhirDto.PatientRow{ID: "p2", Username: "siti", Gender: "unknown", UpdatedAt: "2024-03-01 08:00:00"}
+	suite.fhirRepo.On("RetrievePatients", fhirDto.Filter{ID: "p2"}).Return([]fhirDto.PatientRow{row}, nil)
+
+	resource, err := suite.fhirUC.Read(context.Background(), constants.FhirPatient, "p2")
+
+	suite.Nil(err)
+	patient := resource.(fhirDto.Patient)
+	suite.Equal("siti", patient.Name[0].Text)
+	suite.Len(patient.Identifier, 1)
+	suite.Nil(patient.Telecom)
+	suite.Empty(suite.validate(resource))
+}
+
+func (suite *fhirUsecaseTestSuite) TestReadNotFound() {
+	suite.fhirRepo.On("RetrieveProcedures", fhirDto.Filter{ID: "x"}).Return([]fhirDto.ProcedureRow{}, nil)
+
+	_, err := suite.fhirUC.Read(context.Background(), constants.FhirProcedure, "x")
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *fhirUsecaseTestSuite) TestReadUnsupported() {
+	_, err := suite.fhirUC.Read(context.Background(), "Observation", "x")
+
+	suite.EqualError(err, constants.ErrFhirResourceNotSupported)
+}
+
+func (suite *fhirUsecaseTestSuite) TestSearchAppointments() {
+	filter := fhirDto.Filter{Practitioner: "d1"}
+	rows := []fhirDto.AppointmentRow{
+		appointmentRow("b1", constants.Waiting, "2000-01-03"),
+		appointmentRow("b2", constants.Waiting, "2999-01-03"),
+		appointmentRow("b3", constants.Done, "2024-03-04"),
+		appointmentRow("b4", constants.Canceled, "2024-03-04"),
+	}
+	suite.fhirRepo.On("RetrieveAppointments", filter).Return(rows, nil)
+
+	bundle, err := suite.fhirUC.Search(context.Background(), constants.FhirAppointment, filter)
+
+	suite.Nil(err)
+	suite.Equal("searchset", bundle.Type)
+	suite.Equal(4, *bundle.Total)
+	suite.Equal(baseURL+"/Appointment/b1", bundle.Entry[0].FullURL)
+	var statuses []string
+	for _, entry := range bundle.Entry {
+		statuses = append(statuses, entry.Resource.(fhirDto.Appointment).Status)
+	}
+	suite.Equal([]string{"noshow", "booked", "fulfilled", "cancelled"}, statuses)
+	suite.Empty(suite.validate(bundle))
+}
+
+func (suite *fhirUsecaseTestSuite) TestSearchConditionsByEncounter() {
+	suite.fhirRepo.On("RetrieveEncounters", fhirDto.Filter{ID: "r1", Encounter: "r1"}).Return([]fhirDto.EncounterRow{encounterRow}, nil)
+
+	bundle, err := suite.fhirUC.Search(context.Background(), constants.FhirCondition, fhirDto.Filter{Encounter: "r1"})
+
+	suite.Nil(err)
+	condition := bundle.Entry[0].Resource.(fhirDto.Condition)
+	suite.Equal("Common cold", condition.Code.Text)
+	suite.Equal("Encounter/r1", condition.Encounter.Reference)
+	suite.Empty(suite.validate(bundle))
+}
+
+func (suite *fhirUsecaseTestSuite) TestEverything() {
+	filter := fhirDto.Filter{Patient: "p1"}
+	suite.fhirRepo.On("RetrievePatients", fhirDto.Filter{ID: "p1"}).Return([]fhirDto.PatientRow{patientRow}, nil)
+	suite.fhirRepo.On("RetrieveAppointments", filter).Return([]fhirDto.AppointmentRow{appointmentRow("b1", constants.Done, "2024-03-04")}, nil)
+	suite.fhirRepo.On("RetrieveEncounters", filter).Return([]fhirDto.EncounterRow{encounterRow}, nil)
+	suite.fhirRepo.On("RetrieveMedications", filter).Return([]fhirDto.MedicationRow{
+		{ID: "m1", MedicalRecordID: "r1", PatientID: "p1", DoctorID: "d1", MedicineName: "Paracetamol", MedicineType: "TABLET", Quantity: 10, Paid: true, CreatedAt: "2024-03-04 09:40:00"},
+		{ID: "m2", MedicalRecordID: "r1", PatientID: "p1", DoctorID: "d1", MedicineName: "Cough syrup", MedicineType: "CAIR", Quantity: 1, CreatedAt: "2024-03-04 09:40:00"},
+	}, nil)
+	suite.fhirRepo.On("RetrieveProcedures", filter).Return([]fhirDto.ProcedureRow{
+		{ID: "a1", MedicalRecordID: "r1", PatientID: "p1", DoctorID: "d1", ActionName: "Nebulizer", CreatedAt: "2024-03-04 09:40:00"},
+	}, nil)
+	suite.fhirRepo.On("RetrievePractitioners", fhirDto.Filter{}).Return([]fhirDto.PractitionerRow{
+		{ID: "d1", Username: "joko", Specialization: "General practitioner", UpdatedAt: "2024-01-01 08:00:00"},
+		{ID: "d2", Username: "sari", UpdatedAt: "2024-01-01 08:00:00"},
+	}, nil)
+
+	bundle, err := suite.fhirUC.Everything(context.Background(), "p1")
+
+	suite.Nil(err)
+	var types, modes []string
+	for _, entry := range bundle.Entry {
+		var header struct{ ResourceType string }
+		raw, _ := json.Marshal(entry.Resource)
+		json.Unmarshal(raw, &header)
+		types = append(types, header.ResourceType)
+		modes = append(modes, entry.Search.Mode)
+	}
+	suite.Equal([]string{"Patient", "Appointment", "Encounter", "Condition", "MedicationRequest", "MedicationRequest", "Procedure", "Practitioner"}, types)
+	suite.Equal("include", modes[7])
+	suite.Equal("completed", bundle.Entry[4].Resource.(fhirDto.MedicationRequest).Status)
+	suite.Equal("active", bundle.Entry[5].Resource.(fhirDto.MedicationRequest).Status)
+	suite.Equal("dr. joko", bundle.Entry[7].Resource.(fhirDto.Practitioner).Name[0].Text)
+	suite.Empty(suite.validate(bundle))
+	suite.fhirRepo.AssertExpectations(suite.T())
+}
+
+func (suite *fhirUsecaseTestSuite) TestEverythingNotFound() {
+	suite.fhirRepo.On("RetrievePatients", fhirDto.Filter{ID: "x"}).Return([]fhirDto.PatientRow{}, nil)
+
+	_, err := suite.fhirUC.Everything(context.Background(), "x")
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *fhirUsecaseTestSuite) TestImport() {
+	imported := func(profiles []fhirDto.PatientProfile) bool {
+		return len(profiles) == 2 &&
+			profiles[0].NIK == "3171234567890123" && profiles[0].FullName == "Budi Agus Santoso" &&
+			profiles[0].Username == "budiagussantoso" && profiles[0].Gender == "male" && profiles[0].BirthDate == "1985-07-12" &&
+			profiles[0].Phone == "081234567890" && profiles[0].Address == "Jl. Merdeka No. 10, Jakarta, 10110, ID" &&
+			strings.HasPrefix(profiles[0].Password, "$2") &&
+			profiles[1].NIK == "" && profiles[1].FullName == "Siti Rahayu" && profiles[1].Username == "sitirahayu"
+	}
+	suite.fhirRepo.On("ImportPatients", mock.MatchedBy(imported)).
+		Return([]fhirDto.ImportResult{{ID: "p1"}, {ID: "p2", Created: true}}, nil)
+
+	response, err := suite.fhirUC.Import(context.Background(), suite.bundle("patient-bundle.json"))
+
+	suite.Nil(err)
+	suite.Equal("transaction-response", response.Type)
+	suite.Equal(&fhirDto.BundleResponse{Status: "200 OK", Location: "Patient/p1"}, response.Entry[0].Response)
+	suite.Equal(&fhirDto.BundleResponse{Status: "201 Created", Location: "Patient/p2"}, response.Entry[1].Response)
+	suite.Empty(suite.validate(response))
+	suite.fhirRepo.AssertExpectations(suite.T())
+}
+
+func (suite *fhirUsecaseTestSuite) TestImportSpecExample() {
+	raw, err := os.ReadFile("testdata/patient-example.json")
+	suite.Require().Nil(err)
+	suite.Empty(suite.validate(json.RawMessage(raw)))
+
+	imported := func(profiles []fhirDto.PatientProfile) bool {
+		return len(profiles) == 1 && profiles[0].FullName == "Peter James Chalmers" && profiles[0].NIK == "" &&
+			profiles[0].Phone == "(03) 5555 6473" && profiles[0].BirthDate == "1974-12-25"
+	}
+	suite.fhirRepo.On("ImportPatients", mock.MatchedBy(imported)).Return([]fhirDto.ImportResult{{ID: "p3", Created: true}}, nil)
+
+	bundle := fhirDto.IncomingBundle{ResourceType: "Bundle", Type: "collection", Entry: []fhirDto.IncomingEntry{{Resource: raw}}}
+	response, err := suite.fhirUC.Import(context.Background(), bundle)
+
+	suite.Nil(err)
+	suite.Equal("transaction-response", response.Type)
+	suite.fhirRepo.AssertExpectations(suite.T())
+}
+
+func (suite *fhirUsecaseTestSuite) TestImportInvalid() {
+	_, err := suite.fhirUC.Import(context.Background(), suite.bundle("invalid-bundle.json"))
+
+	var invalid *fhirDto.ValidationError
+	suite.Require().True(errors.As(err, &invalid))
+	var expressions []string
+	for _, issue := range invalid.Issues {
+		expressions = append(expressions, issue.Expression[0])
+	}
+	suite.Equal([]string{
+		"Bundle.entry[0].resource.identifier[0].value",
+		"Bundle.entry[0].resource.gender",
+		"Bundle.entry[0].resource.birthDate",
+		"Bundle.entry[1].resource.resourceType",
+		"Bundle.entry[2].resource.name",
+	}, expressions)
+	suite.fhirRepo.AssertNotCalled(suite.T(), "ImportPatients", mock.Anything)
+}
+
+func (suite *fhirUsecaseTestSuite) TestImportNotTransaction() {
+	_, err := suite.fhirUC.Import(context.Background(), fhirDto.IncomingBundle{ResourceType: "Bundle", Type: "searchset"})
+
+	suite.EqualError(err, constants.ErrFhirInvalidBundle)
+}
+
+func (suite *fhirUsecaseTestSuite) TestValidateRejectsInvalidResources() {
+	appointment := fhirDto.Appointment{ResourceType: "Appointment", Status: "waiting", Start: "2024-03-04 09:00:00"}
+
+	suite.ElementsMatch([]string{
+		"Appointment.status: waiting is not in the value set",
+		"Appointment.participant: is required",
+		"Appointment.start: 2024-03-04 09:00:00 is not an instant",
+	}, suite.validate(appointment))
+}
+
+func (suite *fhirUsecaseTestSuite) bundle(name string) fhirDto.IncomingBundle {
+	raw, err := os.ReadFile("testdata/" + name)
+	suite.Require().Nil(err)
+
+	var bundle fhirDto.IncomingBundle
+	suite.Require().Nil(json.Unmarshal(raw, &bundle))
+	return bundle
+}
+
+// rule lists what the R4 specification requires of a resource type; see
+// testdata/r4-rules.json. Paths are dotted element names and step through
+// arrays.
+type rule struct {
+	Required   []string            `json:"required"`
+	Codes      map[string][]string `json:"codes"`
+	Dates      []string            `json:"dates"`
+	Instants   []string            `json:"instants"`
+	References []string            `json:"references"`
+}
+
+var (
+	instantPattern   = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})$`)
+	referencePattern = regexp.MustCompile(`^[A-Z][A-Za-z]+/[A-Za-z0-9\-.]{1,64}$`)
+)
+
+// validate checks a resource, and the resources in it if it is a bundle,
+// against the rules and returns what is wrong with them.
+func (suite *fhirUsecaseTestSuite) validate(resource interface{}) []string {
+	raw, err := os.ReadFile("testdata/r4-rules.json")
+	suite.Require().Nil(err)
+	var rules map[string]rule
+	suite.Require().Nil(json.Unmarshal(raw, &rules))
+
+	encoded, err := json.Marshal(resource)
+	suite.Require().Nil(err)
+	var decoded map[string]interface{}
+	suite.Require().Nil(json.Unmarshal(encoded, &decoded))
+
+	return validateResource(rules, decoded)
+}
+
+func validateResource(rules map[string]rule, resource map[string]interface{}) []string {
+	resourceType, _ := resource["resourceType"].(string)
+	rule, ok := rules[resourceType]
+	if !ok {
+		return []string{fmt.Sprintf("%q is not a known resource type", resourceType)}
+	}
+
+	var problems []string
+	report := func(path, format string, args ...interface{}) {
+		problems = append(problems, resourceType+"."+path+": "+fmt.Sprintf(format, args...))
+	}
+
+	for _, path := range rule.Required {
+		parent, name := "", path
+		if i := strings.LastIndex(path, "."); i >= 0 {
+			parent, name = path[:i], path[i+1:]
+		}
+		for _, node := range values(resource, parent) {
+			if object, ok := node.(map[string]interface{}); ok && empty(object[name]) {
+				report(path, "is required")
+			}
+		}
+	}
+
+	for path, codes := range rule.Codes {
+		for _, value := range values(resource, path) {
+			if !contains(codes, fmt.Sprint(value)) {
+				report(path, "%v is not in the value set", value)
+			}
+		}
+	}
+
+	for _, path := range rule.Dates {
+		for _, value := range values(resource, path) {
+			if !datePattern.MatchString(fmt.Sprint(value)) {
+				report(path, "%v is not a date", value)
+			}
+		}
+	}
+
+	for _, path := range rule.Instants {
+		for _, value := range values(resource, path) {
+			if !instantPattern.MatchString(fmt.Sprint(value)) {
+				report(path, "%v is not an instant", value)
+			}
+		}
+	}
+
+	for _, path := range rule.References {
+		for _, value := range values(resource, path+".reference") {
+			if !referencePattern.MatchString(fmt.Sprint(value)) {
+				report(path, "%v is not a relative reference", value)
+			}
+		}
+	}
+
+	for _, entry := range values(resource, "entry") {
+		if object, ok := entry.(map[string]interface{}); ok {
+			if fullURL, ok := object["fullUrl"].(string); ok && !strings.Contains(fullURL, ":") {
+				report("entry.fullUrl", "%s is not absolute", fullURL)
+			}
+			if contained, ok := object["resource"].(map[string]interface{}); ok {
+				problems = append(problems, validateResource(rules, contained)...)
+			}
+		}
+	}
+	return problems
+}
+
+// values collects the values at a dotted path, stepping into every element
+// of the arrays on the way.
+func values(node interface{}, path string) []interface{} {
+	if array, ok := node.([]interface{}); ok {
+		var collected []interface{}
+		for _, element := range array {
+			collected = append(collected, values(element, path)...)
+		}
+		return collected
+	}
+
+	if path == "" {
+		if node == nil {
+			return nil
+		}
+		return []interface{}{node}
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	name, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+	return values(object[name], rest)
+}
+
+func empty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+func contains(codes []string, code string) bool {
+	for _, candidate := range codes {
+		if candidate == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFhirUsecase(t *testing.T) {
+	suite.Run(t, new(fhirUsecaseTestSuite))
+}
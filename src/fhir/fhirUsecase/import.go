@@ -0,0 +1,202 @@
+package fhirUsecase
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	nikPattern  = regexp.MustCompile(`^[0-9]{16}$`)
+	datePattern = regexp.MustCompile(`^[0-9]{4}-(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])$`)
+	usernameCut = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Import creates patient profiles from a bundle of Patient resources. The
+// whole bundle is validated first and imported in one transaction, so either
+// every patient is imported or none is.
+func (usecase *fhirUsecase) Import(ctx context.Context, bundle fhirDto.IncomingBundle) (fhirDto.Bundle, error) {
+	responseType := "transaction-response"
+	switch {
+	case bundle.ResourceType != constants.FhirBundle:
+		return fhirDto.Bundle{}, errors.New(constants.ErrFhirInvalidBundle)
+	case bundle.Type == "batch":
+		responseType = "batch-response"
+	case bundle.Type != "transaction" && bundle.Type != "collection":
+		return fhirDto.Bundle{}, errors.New(constants.ErrFhirInvalidBundle)
+	}
+
+	var profiles []fhirDto.PatientProfile
+	var issues []fhirDto.OperationOutcomeIssue
+	for i, entry := range bundle.Entry {
+		path := fmt.Sprintf("Bundle.entry[%d].resource", i)
+		profile, entryIssues := patientProfile(entry.Resource, path)
+		issues = append(issues, entryIssues...)
+		profiles = append(profiles, profile)
+	}
+
+	if len(issues) > 0 {
+		return fhirDto.Bundle{}, &fhirDto.ValidationError{Issues: issues}
+	}
+
+	// imported patients sign in only after an admin resets their password
+	for i := range profiles {
+		password, err := randomPassword()
+		if err != nil {
+			return fhirDto.Bundle{}, err
+		}
+		if profiles[i].Password, err = utils.GenerateHashPassword(password); err != nil {
+			return fhirDto.Bundle{}, err
+		}
+	}
+
+	results, err := usecase.fhirRepo.ImportPatients(ctx, profiles)
+	if err != nil {
+		return fhirDto.Bundle{}, err
+	}
+
+	response := fhirDto.Bundle{ResourceType: constants.FhirBundle, Type: responseType}
+	for _, result := range results {
+		status := "200 OK"
+		if result.Created {
+			status = "201 Created"
+		}
+
+		response.Entry = append(response.Entry, fhirDto.BundleEntry{
+			FullURL:  usecase.baseURL + "/" + constants.FhirPatient + "/" + result.ID,
+			Response: &fhirDto.BundleResponse{Status: status, Location: constants.FhirPatient + "/" + result.ID},
+		})
+	}
+	return response, nil
+}
+
+// patientProfile validates a Patient resource against the parts of the R4
+// specification the clinic stores and maps it to a profile.
+func patientProfile(raw json.RawMessage, path string) (fhirDto.PatientProfile, []fhirDto.OperationOutcomeIssue) {
+	var header struct {
+		ResourceType string `json:"resourceType"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return fhirDto.PatientProfile{}, []fhirDto.OperationOutcomeIssue{issue("structure", err.Error(), path)}
+	}
+
+	if header.ResourceType != constants.FhirPatient {
+		message := fmt.Sprintf("only Patient resources can be imported, not %q", header.ResourceType)
+		return fhirDto.PatientProfile{}, []fhirDto.OperationOutcomeIssue{issue("not-supported", message, path+".resourceType")}
+	}
+
+	var resource fhirDto.Patient
+	if err := json.Unmarshal(raw, &resource); err != nil {
+		return fhirDto.PatientProfile{}, []fhirDto.OperationOutcomeIssue{issue("structure", err.Error(), path)}
+	}
+
+	var issues []fhirDto.OperationOutcomeIssue
+	profile := fhirDto.PatientProfile{Gender: "unknown", BirthDate: resource.BirthDate}
+	for i, identifier := range resource.Identifier {
+		if identifier.System != constants.FhirNIKSystem {
+			continue
+		}
+
+		if !nikPattern.MatchString(identifier.Value) {
+			issues = append(issues, issue("value", "a NIK must be 16 digits", fmt.Sprintf("%s.identifier[%d].value", path, i)))
+		}
+		profile.NIK = identifier.Value
+	}
+
+	if len(resource.Name) > 0 {
+		profile.FullName = fullName(resource.Name)
+	}
+
+	if profile.NIK == "" && profile.FullName == "" {
+		issues = append(issues, issue("required", "a patient needs a name or a NIK identifier", path+".name"))
+	}
+
+	switch resource.Gender {
+	case "":
+	case "male", "female", "other", "unknown":
+		profile.Gender = resource.Gender
+	default:
+		issues = append(issues, issue("code-invalid", "gender must be male, female, other or unknown", path+".gender"))
+	}
+
+	if resource.BirthDate != "" && !datePattern.MatchString(resource.BirthDate) {
+		issues = append(issues, issue("value", "birthDate must be a full date in the format YYYY-MM-DD", path+".birthDate"))
+	}
+
+	for _, telecom := range resource.Telecom {
+		if telecom.System == "phone" && profile.Phone == "" {
+			profile.Phone = telecom.Value
+		}
+	}
+
+	if len(resource.Address) > 0 {
+		profile.Address = addressText(resource.Address[0])
+	}
+
+	profile.Username = username(profile)
+	return profile, issues
+}
+
+// fullName takes the official name of a patient, or their first name if none
+// is marked official.
+func fullName(names []fhirDto.HumanName) string {
+	name := names[0]
+	for _, candidate := range names {
+		if candidate.Use == "official" {
+			name = candidate
+			break
+		}
+	}
+
+	if name.Text != "" {
+		return name.Text
+	}
+	return strings.TrimSpace(strings.Join(append(append([]string{}, name.Given...), name.Family), " "))
+}
+
+func addressText(address fhirDto.Address) string {
+	if address.Text != "" {
+		return address.Text
+	}
+
+	parts := append([]string{}, address.Line...)
+	for _, part := range []string{address.City, address.PostalCode, address.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// username is made from the patient's name, or their NIK when they have none.
+// The repository numbers it if it is taken.
+func username(profile fhirDto.PatientProfile) string {
+	if name := usernameCut.ReplaceAllString(strings.ToLower(profile.FullName), ""); name != "" {
+		return name
+	}
+
+	if profile.NIK != "" {
+		return profile.NIK
+	}
+	return "patient"
+}
+
+func randomPassword() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+func issue(code, diagnostics, expression string) fhirDto.OperationOutcomeIssue {
+	return fhirDto.OperationOutcomeIssue{Severity: "error", Code: code, Diagnostics: diagnostics, Expression: []string{expression}}
+}
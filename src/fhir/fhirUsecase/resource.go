@@ -0,0 +1,198 @@
+package fhirUsecase
+
+import (
+	"avengers-clinic/model/dto/fhirDto"
+	"avengers-clinic/pkg/constants"
+	"strings"
+	"time"
+)
+
+// The functions below map the clinic's records to FHIR R4 resources. Each
+// resource keeps the id of the record it was made from, so a resource read
+// twice has the same id.
+
+func patient(row fhirDto.PatientRow) fhirDto.Patient {
+	active := true
+	resource := fhirDto.Patient{
+		ResourceType: constants.FhirPatient,
+		ID:           row.ID,
+		Meta:         &fhirDto.Meta{LastUpdated: dateTime(row.UpdatedAt)},
+		Identifier:   []fhirDto.Identifier{{Use: "usual", System: constants.FhirPatientSystem, Value: row.ID}},
+		Active:       &active,
+		Name:         []fhirDto.HumanName{{Use: "official", Text: patientName(row)}},
+		Gender:       row.Gender,
+		BirthDate:    row.BirthDate,
+	}
+
+	if row.NIK != "" {
+		resource.Identifier = append(resource.Identifier, fhirDto.Identifier{Use: "official", System: constants.FhirNIKSystem, Value: row.NIK})
+	}
+
+	if row.Phone != "" {
+		resource.Telecom = []fhirDto.ContactPoint{{System: "phone", Value: row.Phone, Use: "mobile"}}
+	}
+
+	if row.Address != "" {
+		resource.Address = []fhirDto.Address{{Use: "home", Text: row.Address}}
+	}
+	return resource
+}
+
+// patientName prefers the name a patient was imported with over their username.
+func patientName(row fhirDto.PatientRow) string {
+	if row.FullName != "" {
+		return row.FullName
+	}
+	return row.Username
+}
+
+func practitioner(row fhirDto.PractitionerRow) fhirDto.Practitioner {
+	active := true
+	resource := fhirDto.Practitioner{
+		ResourceType: constants.FhirPractitioner,
+		ID:           row.ID,
+		Meta:         &fhirDto.Meta{LastUpdated: dateTime(row.UpdatedAt)},
+		Identifier:   []fhirDto.Identifier{{Use: "usual", System: constants.FhirPractitionerSystem, Value: row.ID}},
+		Active:       &active,
+		Name:         []fhirDto.HumanName{{Use: "official", Text: doctorName(row.Username), Prefix: []string{"dr."}}},
+	}
+
+	if row.Specialization != "" {
+		resource.Qualification = []fhirDto.PractitionerQualification{{Code: fhirDto.CodeableConcept{Text: row.Specialization}}}
+	}
+	return resource
+}
+
+// appointment maps a booking. A booking still waiting after its day has
+// passed is reported as a no-show.
+func appointment(row fhirDto.AppointmentRow, today string) fhirDto.Appointment {
+	return fhirDto.Appointment{
+		ResourceType: constants.FhirAppointment,
+		ID:           row.ID,
+		Status:       appointmentStatus(row.Status, row.ScheduleDate, today),
+		Description:  row.Complaint,
+		Start:        instant(row.ScheduleDate, row.StartAt),
+		End:          instant(row.ScheduleDate, row.EndAt),
+		Created:      dateTime(row.CreatedAt),
+		Participant: []fhirDto.AppointmentParticipant{
+			{Actor: reference(constants.FhirPatient, row.PatientID, row.PatientName), Status: "accepted"},
+			{Actor: reference(constants.FhirPractitioner, row.DoctorID, doctorName(row.DoctorName)), Status: "accepted"},
+		},
+	}
+}
+
+func appointmentStatus(status, scheduleDate, today string) string {
+	switch status {
+	case constants.Done:
+		return "fulfilled"
+	case constants.Canceled:
+		return "cancelled"
+	}
+
+	if scheduleDate < today {
+		return "noshow"
+	}
+	return "booked"
+}
+
+// encounter maps a medical record to the visit it records. The diagnosis is
+// exported separately as a Condition with the same id.
+func encounter(row fhirDto.EncounterRow) fhirDto.Encounter {
+	return fhirDto.Encounter{
+		ResourceType: constants.FhirEncounter,
+		ID:           row.ID,
+		Status:       "finished",
+		Class:        fhirDto.Coding{System: constants.FhirActCodeSystem, Code: "AMB", Display: "ambulatory"},
+		Subject:      reference(constants.FhirPatient, row.PatientID, row.PatientName),
+		Participant: []fhirDto.EncounterParticipant{
+			{Individual: reference(constants.FhirPractitioner, row.DoctorID, doctorName(row.DoctorName))},
+		},
+		Appointment: []fhirDto.Reference{reference(constants.FhirAppointment, row.BookingID, "")},
+		Period:      &fhirDto.Period{Start: instant(row.ScheduleDate, row.StartAt), End: instant(row.ScheduleDate, row.EndAt)},
+		Diagnosis:   []fhirDto.EncounterDiagnosis{{Condition: reference(constants.FhirCondition, row.ID, "")}},
+	}
+}
+
+func condition(row fhirDto.EncounterRow) fhirDto.Condition {
+	encounter := reference(constants.FhirEncounter, row.ID, "")
+	recorder := reference(constants.FhirPractitioner, row.DoctorID, doctorName(row.DoctorName))
+	return fhirDto.Condition{
+		ResourceType: constants.FhirCondition,
+		ID:           row.ID,
+		VerificationStatus: &fhirDto.CodeableConcept{
+			Coding: []fhirDto.Coding{{System: constants.FhirConditionVerStatus, Code: "confirmed"}},
+		},
+		Category: []fhirDto.CodeableConcept{
+			{Coding: []fhirDto.Coding{{System: constants.FhirConditionCategory, Code: "encounter-diagnosis", Display: "Encounter Diagnosis"}}},
+		},
+		Code:         fhirDto.CodeableConcept{Text: row.Diagnosis},
+		Subject:      reference(constants.FhirPatient, row.PatientID, row.PatientName),
+		Encounter:    &encounter,
+		RecordedDate: dateTime(row.CreatedAt),
+		Recorder:     &recorder,
+	}
+}
+
+// medicationRequest maps a prescribed medicine. It stays active until the
+// medical record is paid for and the medicine handed over.
+func medicationRequest(row fhirDto.MedicationRow) fhirDto.MedicationRequest {
+	status := "active"
+	if row.Paid {
+		status = "completed"
+	}
+
+	encounter := reference(constants.FhirEncounter, row.MedicalRecordID, "")
+	requester := reference(constants.FhirPractitioner, row.DoctorID, "")
+	return fhirDto.MedicationRequest{
+		ResourceType:              constants.FhirMedicationRequest,
+		ID:                        row.ID,
+		Status:                    status,
+		Intent:                    "order",
+		MedicationCodeableConcept: fhirDto.CodeableConcept{Text: row.MedicineName},
+		Subject:                   reference(constants.FhirPatient, row.PatientID, ""),
+		Encounter:                 &encounter,
+		AuthoredOn:                dateTime(row.CreatedAt),
+		Requester:                 &requester,
+		DispenseRequest: &fhirDto.DispenseRequest{
+			Quantity: fhirDto.Quantity{Value: float64(row.Quantity), Unit: strings.ToLower(row.MedicineType)},
+		},
+	}
+}
+
+func procedure(row fhirDto.ProcedureRow) fhirDto.Procedure {
+	encounter := reference(constants.FhirEncounter, row.MedicalRecordID, "")
+	return fhirDto.Procedure{
+		ResourceType:      constants.FhirProcedure,
+		ID:                row.ID,
+		Status:            "completed",
+		Code:              fhirDto.CodeableConcept{Text: row.ActionName},
+		Subject:           reference(constants.FhirPatient, row.PatientID, ""),
+		Encounter:         &encounter,
+		PerformedDateTime: dateTime(row.CreatedAt),
+		Performer:         []fhirDto.ProcedurePerformer{{Actor: reference(constants.FhirPractitioner, row.DoctorID, "")}},
+	}
+}
+
+func reference(resourceType, id, display string) fhirDto.Reference {
+	return fhirDto.Reference{Reference: resourceType + "/" + id, Display: display}
+}
+
+func doctorName(username string) string {
+	return "dr. " + username
+}
+
+// instant joins a date and a clock time into a FHIR instant in the clinic's
+// time zone.
+func instant(date, clock string) string {
+	return dateTime(date + " " + clock)
+}
+
+// dateTime converts the timestamps stored by the clinic, which have no time
+// zone, to FHIR dateTimes, which must have one once they have a time.
+func dateTime(value string) string {
+	parsed, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		return ""
+	}
+	return parsed.Format(time.RFC3339)
+}
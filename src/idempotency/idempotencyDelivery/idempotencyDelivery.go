@@ -0,0 +1,150 @@
+package idempotencyDelivery
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/idempotency"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// bodyWriter keeps a copy of the response, to replay it on retries.
+type bodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// Idempotent makes retries of the routes, written as "POST /api/v1/booking",
+// safe. A request sent with an Idempotency-Key runs once, and retries with the
+// same key and body get its response again until the key expires. Reusing the
+// key for another request is refused, as is a retry while the first request is
+// still running. Keys belong to the user who sent them, requests without a
+// valid token or without a key run as usual. Server errors are not kept when
+// the request saved nothing, a retry after one runs again. Once a unit of work
+// of the request committed, whatever it answered is kept.
+func Idempotent(idempotencyUC idempotency.IdempotencyUsecase, routes ...string) gin.HandlerFunc {
+	covered := map[string]bool{}
+	for _, route := range routes {
+		covered[route] = true
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(constants.IdempotencyKeyHeader)
+		route := c.Request.Method + " " + c.FullPath()
+		if key == "" || !covered[route] {
+			c.Next()
+			return
+		}
+
+		// the route refuses the request itself
+		claims := utils.Actor(c)
+		if claims == nil {
+			c.Next()
+			return
+		}
+
+		if len(key) > constants.MaxIdempotencyKeyLength {
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: constants.IdempotencyKeyHeader, Message: constants.ErrInvalidIdempotencyKey}}, "bad request", constants.IdempotencyService, "01")
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			json.NewResponseError(c, err.Error(), constants.IdempotencyService, "04")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := idempotencyDto.Record{
+			ActorID:     claims.ID,
+			Key:         key,
+			Route:       route,
+			RequestHash: requestHash(c.Request, body),
+		}
+		stored, err := idempotencyUC.Begin(c.Request.Context(), record)
+		if err != nil {
+			switch err.Error() {
+			case constants.ErrIdempotencyKeyReused:
+				json.NewResponseUnprocessableEntity(c, err.Error(), constants.IdempotencyService, "02")
+			case constants.ErrIdempotencyKeyInProgress:
+				json.NewResponseConflict(c, err.Error(), constants.IdempotencyService, "03")
+			default:
+				json.NewResponseError(c, err.Error(), constants.IdempotencyService, "04")
+			}
+			c.Abort()
+			return
+		}
+
+		if stored.Status != 0 {
+			c.Header(constants.IdempotentReplayedHeader, "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		// the key is freed as well when the route panics, unless the request
+		// saved its changes before
+		ctx, committed := transaction.Track(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+		completed := false
+		ctx = context.WithoutCancel(ctx)
+		defer func() {
+			if completed {
+				return
+			}
+			if committed() {
+				// the recovery answers with a bare 500, retries get it as well
+				record.Status = http.StatusInternalServerError
+				if err := idempotencyUC.Complete(ctx, record); err != nil {
+					log.Error().Msg("idempotencyDelivery.Idempotent.err : " + err.Error())
+				}
+				return
+			}
+			if err := idempotencyUC.Release(ctx, record.ActorID, record.Key); err != nil {
+				log.Error().Msg("idempotencyDelivery.Idempotent.err : " + err.Error())
+			}
+		}()
+
+		writer := &bodyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		record.Status = c.Writer.Status()
+		if record.Status >= http.StatusInternalServerError && !committed() {
+			return
+		}
+		// a response that cannot be kept leaves the key held until its lock
+		// ends, retries are refused rather than run again meanwhile
+		completed = true
+		record.ContentType = c.Writer.Header().Get("Content-Type")
+		record.Body = writer.body.Bytes()
+		if err := idempotencyUC.Complete(ctx, record); err != nil {
+			log.Error().Msg("idempotencyDelivery.Idempotent.err : " + err.Error())
+		}
+	}
+}
+
+// requestHash tells requests apart by their method, address and body.
+func requestHash(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(request.Method + " " + request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
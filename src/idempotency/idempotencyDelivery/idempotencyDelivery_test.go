@@ -0,0 +1,232 @@
+package idempotencyDelivery
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockIdempotencyUsecase struct {
+	mock.Mock
+}
+
+func (m *mockIdempotencyUsecase) Begin(ctx context.Context, record idempotencyDto.Record) (idempotencyDto.Record, error) {
+	args := m.Called(record)
+	return args.Get(0).(idempotencyDto.Record), args.Error(1)
+}
+
+func (m *mockIdempotencyUsecase) Complete(ctx context.Context, record idempotencyDto.Record) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *mockIdempotencyUsecase) Release(ctx context.Context, actorID, key string) error {
+	args := m.Called(actorID, key)
+	return args.Error(0)
+}
+
+func (m *mockIdempotencyUsecase) PurgeExpired(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type idempotencyDeliveryTestSuite struct {
+	suite.Suite
+	router        *gin.Engine
+	idempotencyUC *mockIdempotencyUsecase
+	mock          sqlmock.Sqlmock
+	created       int
+}
+
+func (suite *idempotencyDeliveryTestSuite) SetupTest() {
+	suite.router = gin.New()
+	suite.router.Use(gin.Recovery())
+	suite.idempotencyUC = new(mockIdempotencyUsecase)
+	suite.created = 0
+
+	db, mock, _ := sqlmock.New()
+	suite.mock = mock
+
+	v1Group := suite.router.Group("/api/v1")
+	v1Group.Use(Idempotent(suite.idempotencyUC, "POST /api/v1/booking", "POST /api/v1/invoices",
+		"POST /api/v1/invoices/:id/payments", "POST /api/v1/invoices/:id/issue"))
+	v1Group.POST("/booking", func(c *gin.Context) {
+		suite.created++
+		c.JSON(http.StatusCreated, gin.H{"responseCode": "2010501"})
+	})
+	v1Group.POST("/medicines", func(c *gin.Context) {
+		suite.created++
+		c.JSON(http.StatusCreated, gin.H{"responseCode": "2010301"})
+	})
+	v1Group.POST("/invoices", func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"responseCode": "5001001"})
+	})
+	// the payment is saved, reading it back fails
+	v1Group.POST("/invoices/:id/payments", func(c *gin.Context) {
+		transaction.Run(c.Request.Context(), db, func(ctx context.Context) error { return nil })
+		c.JSON(http.StatusInternalServerError, gin.H{"responseCode": "5001002"})
+	})
+	v1Group.POST("/invoices/:id/issue", func(c *gin.Context) {
+		transaction.Run(c.Request.Context(), db, func(ctx context.Context) error { return nil })
+		panic("invoice not found after issuing")
+	})
+}
+
+func (suite *idempotencyDeliveryTestSuite) serve(path, key, body string) *httptest.ResponseRecorder {
+	token, _ := utils.GenerateJWT("u1", "Budi", "PATIENT")
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set(constants.IdempotencyKeyHeader, key)
+	}
+	suite.router.ServeHTTP(res, req)
+	return res
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestFirstRequestKept() {
+	suite.idempotencyUC.On("Begin", mock.MatchedBy(func(record idempotencyDto.Record) bool {
+		return record.ActorID == "u1" && record.Key == "k1" && record.Route == "POST /api/v1/booking" && record.RequestHash != ""
+	})).Return(idempotencyDto.Record{}, nil)
+	suite.idempotencyUC.On("Complete", mock.MatchedBy(func(record idempotencyDto.Record) bool {
+		return record.Status == http.StatusCreated && string(record.Body) == `{"responseCode":"2010501"}` &&
+			strings.HasPrefix(record.ContentType, "application/json")
+	})).Return(nil)
+
+	res := suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s1"}`)
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.Equal(1, suite.created)
+	suite.Empty(res.Header().Get(constants.IdempotentReplayedHeader))
+	suite.idempotencyUC.AssertExpectations(suite.T())
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Release", "u1", "k1")
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestRetryReplayed() {
+	stored := idempotencyDto.Record{Status: http.StatusCreated, ContentType: "application/json; charset=utf-8", Body: []byte(`{"responseCode":"2010501"}`)}
+	suite.idempotencyUC.On("Begin", mock.Anything).Return(stored, nil)
+
+	res := suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s1"}`)
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.Equal(0, suite.created)
+	suite.Equal("true", res.Header().Get(constants.IdempotentReplayedHeader))
+	suite.JSONEq(`{"responseCode":"2010501"}`, res.Body.String())
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestSameRequestSameHash() {
+	var hashes []string
+	suite.idempotencyUC.On("Begin", mock.Anything).Run(func(args mock.Arguments) {
+		hashes = append(hashes, args.Get(0).(idempotencyDto.Record).RequestHash)
+	}).Return(idempotencyDto.Record{}, errors.New(constants.ErrIdempotencyKeyInProgress))
+
+	suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s1"}`)
+	suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s1"}`)
+	suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s2"}`)
+
+	suite.Len(hashes, 3)
+	suite.Equal(hashes[0], hashes[1])
+	suite.NotEqual(hashes[0], hashes[2])
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestKeyReused() {
+	suite.idempotencyUC.On("Begin", mock.Anything).Return(idempotencyDto.Record{}, errors.New(constants.ErrIdempotencyKeyReused))
+
+	res := suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s2"}`)
+
+	suite.Equal(http.StatusUnprocessableEntity, res.Code)
+	suite.Contains(res.Body.String(), `"responseCode":"4221802"`)
+	suite.Equal(0, suite.created)
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestStillRunning() {
+	suite.idempotencyUC.On("Begin", mock.Anything).Return(idempotencyDto.Record{}, errors.New(constants.ErrIdempotencyKeyInProgress))
+
+	res := suite.serve("/api/v1/booking", "k1", `{"schedule_id":"s1"}`)
+
+	suite.Equal(http.StatusConflict, res.Code)
+	suite.Contains(res.Body.String(), `"responseCode":"4091803"`)
+	suite.Equal(0, suite.created)
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestKeyTooLong() {
+	res := suite.serve("/api/v1/booking", strings.Repeat("k", constants.MaxIdempotencyKeyLength+1), `{}`)
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.Contains(res.Body.String(), `"responseCode":"4001801"`)
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Begin", mock.Anything)
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestWithoutKey() {
+	res := suite.serve("/api/v1/booking", "", `{"schedule_id":"s1"}`)
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.Equal(1, suite.created)
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Begin", mock.Anything)
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestOtherRoute() {
+	res := suite.serve("/api/v1/medicines", "k1", `{"name":"Paracetamol"}`)
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Begin", mock.Anything)
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestServerErrorReleased() {
+	suite.idempotencyUC.On("Begin", mock.Anything).Return(idempotencyDto.Record{}, nil)
+	suite.idempotencyUC.On("Release", "u1", "k1").Return(nil)
+
+	res := suite.serve("/api/v1/invoices", "k1", `{}`)
+
+	suite.Equal(http.StatusInternalServerError, res.Code)
+	suite.idempotencyUC.AssertExpectations(suite.T())
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Complete", mock.Anything)
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestServerErrorAfterCommitKept() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectCommit()
+	suite.idempotencyUC.On("Begin", mock.Anything).Return(idempotencyDto.Record{}, nil)
+	suite.idempotencyUC.On("Complete", mock.MatchedBy(func(record idempotencyDto.Record) bool {
+		return record.Status == http.StatusInternalServerError && string(record.Body) == `{"responseCode":"5001002"}`
+	})).Return(nil)
+
+	res := suite.serve("/api/v1/invoices/i1/payments", "k1", `{}`)
+
+	suite.Equal(http.StatusInternalServerError, res.Code)
+	suite.idempotencyUC.AssertExpectations(suite.T())
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Release", "u1", "k1")
+}
+
+func (suite *idempotencyDeliveryTestSuite) TestPanicAfterCommitKept() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectCommit()
+	suite.idempotencyUC.On("Begin", mock.Anything).Return(idempotencyDto.Record{}, nil)
+	suite.idempotencyUC.On("Complete", mock.MatchedBy(func(record idempotencyDto.Record) bool {
+		return record.Status == http.StatusInternalServerError
+	})).Return(nil)
+
+	res := suite.serve("/api/v1/invoices/i1/issue", "k1", `{}`)
+
+	suite.Equal(http.StatusInternalServerError, res.Code)
+	suite.idempotencyUC.AssertExpectations(suite.T())
+	suite.idempotencyUC.AssertNotCalled(suite.T(), "Release", "u1", "k1")
+}
+
+func TestIdempotencyDelivery(t *testing.T) {
+	suite.Run(t, new(idempotencyDeliveryTestSuite))
+}
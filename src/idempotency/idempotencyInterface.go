@@ -0,0 +1,21 @@
+package idempotency
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"context"
+)
+
+type IdempotencyRepository interface {
+	Claim(ctx context.Context, record idempotencyDto.Record) (bool, error)
+	RetrieveByKey(ctx context.Context, actorID, key string) (idempotencyDto.Record, error)
+	Complete(ctx context.Context, record idempotencyDto.Record) error
+	Release(ctx context.Context, actorID, key string) error
+	DeleteExpired(ctx context.Context, now string) (int64, error)
+}
+
+type IdempotencyUsecase interface {
+	Begin(ctx context.Context, record idempotencyDto.Record) (idempotencyDto.Record, error)
+	Complete(ctx context.Context, record idempotencyDto.Record) error
+	Release(ctx context.Context, actorID, key string) error
+	PurgeExpired(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,83 @@
+package idempotencyRepository
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"avengers-clinic/src/idempotency"
+	"context"
+	"database/sql"
+)
+
+type idempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) idempotency.IdempotencyRepository {
+	return &idempotencyRepository{db}
+}
+
+// Claim takes the key of the record for its request, false when the key is
+// held by another request that has not expired yet. An expired key is taken
+// over as if it was never used, and a key the same request left without a
+// response past its lock is taken over as well.
+func (repository *idempotencyRepository) Claim(ctx context.Context, record idempotencyDto.Record) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (actor_id, key, route, request_hash, created_at, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (actor_id, key) DO UPDATE SET route = EXCLUDED.route, request_hash = EXCLUDED.request_hash,
+			status = NULL, content_type = NULL, body = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at,
+			locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.request_hash = EXCLUDED.request_hash
+				AND idempotency_keys.locked_until <= EXCLUDED.created_at)
+		RETURNING key;`
+
+	var key string
+	err := repository.db.QueryRowContext(ctx, query, record.ActorID, record.Key, record.Route, record.RequestHash,
+		record.CreatedAt, record.ExpiresAt, record.LockedUntil).Scan(&key)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (repository *idempotencyRepository) RetrieveByKey(ctx context.Context, actorID, key string) (idempotencyDto.Record, error) {
+	query := `
+		SELECT actor_id, key, route, request_hash, COALESCE(status, 0), COALESCE(content_type, ''), body,
+			TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS'), TO_CHAR(expires_at, 'YYYY-MM-DD HH24:MI:SS'),
+			TO_CHAR(locked_until, 'YYYY-MM-DD HH24:MI:SS')
+		FROM idempotency_keys WHERE actor_id = $1 AND key = $2;`
+
+	var record idempotencyDto.Record
+	err := repository.db.QueryRowContext(ctx, query, actorID, key).Scan(&record.ActorID, &record.Key, &record.Route,
+		&record.RequestHash, &record.Status, &record.ContentType, &record.Body, &record.CreatedAt, &record.ExpiresAt, &record.LockedUntil)
+	if err != nil {
+		return idempotencyDto.Record{}, err
+	}
+	return record, nil
+}
+
+// Complete keeps the response of the request holding the key.
+func (repository *idempotencyRepository) Complete(ctx context.Context, record idempotencyDto.Record) error {
+	query := "UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5 WHERE actor_id = $1 AND key = $2;"
+	_, err := repository.db.ExecContext(ctx, query, record.ActorID, record.Key, record.Status, record.ContentType, record.Body)
+	return err
+}
+
+// Release frees a key whose request did not get a response worth keeping, so
+// that a retry runs again.
+func (repository *idempotencyRepository) Release(ctx context.Context, actorID, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE actor_id = $1 AND key = $2 AND status IS NULL;"
+	_, err := repository.db.ExecContext(ctx, query, actorID, key)
+	return err
+}
+
+func (repository *idempotencyRepository) DeleteExpired(ctx context.Context, now string) (int64, error) {
+	result, err := repository.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1;", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
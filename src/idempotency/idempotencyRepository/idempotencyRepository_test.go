@@ -0,0 +1,126 @@
+package idempotencyRepository
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"avengers-clinic/src/idempotency"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type idempotencyRepositoryTestSuite struct {
+	suite.Suite
+	idempotencyRepo idempotency.IdempotencyRepository
+	mock            sqlmock.Sqlmock
+}
+
+func (suite *idempotencyRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.idempotencyRepo = NewIdempotencyRepository(db)
+	suite.mock = mock
+}
+
+var record = idempotencyDto.Record{
+	ActorID:     "u1",
+	Key:         "k1",
+	Route:       "POST /api/v1/booking",
+	RequestHash: "abc",
+	CreatedAt:   "2026-10-19 09:30:00",
+	ExpiresAt:   "2026-10-20 09:30:00",
+	LockedUntil: "2026-10-19 09:35:00",
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestClaim() {
+	suite.mock.ExpectQuery(`INSERT INTO idempotency_keys (.+) ON CONFLICT \(actor_id, key\) DO UPDATE (.+) WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`).
+		WithArgs("u1", "k1", "POST /api/v1/booking", "abc", "2026-10-19 09:30:00", "2026-10-20 09:30:00", "2026-10-19 09:35:00").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("k1"))
+
+	claimed, err := suite.idempotencyRepo.Claim(context.Background(), record)
+	suite.NoError(err)
+	suite.True(claimed)
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestClaimAbandoned() {
+	// the same request left the key without a response past its lock
+	suite.mock.ExpectQuery(`OR \(idempotency_keys.status IS NULL AND idempotency_keys.request_hash = EXCLUDED.request_hash\s+AND idempotency_keys.locked_until <= EXCLUDED.created_at\)`).
+		WithArgs("u1", "k1", "POST /api/v1/booking", "abc", "2026-10-19 09:30:00", "2026-10-20 09:30:00", "2026-10-19 09:35:00").
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("k1"))
+
+	claimed, err := suite.idempotencyRepo.Claim(context.Background(), record)
+	suite.NoError(err)
+	suite.True(claimed)
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestClaimHeld() {
+	// the key is used and has not expired, nothing is returned
+	suite.mock.ExpectQuery(`INSERT INTO idempotency_keys`).WillReturnRows(sqlmock.NewRows([]string{"key"}))
+
+	claimed, err := suite.idempotencyRepo.Claim(context.Background(), record)
+	suite.NoError(err)
+	suite.False(claimed)
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestClaimFailed() {
+	suite.mock.ExpectQuery(`INSERT INTO idempotency_keys`).WillReturnError(errors.New("connection refused"))
+
+	_, err := suite.idempotencyRepo.Claim(context.Background(), record)
+	suite.EqualError(err, "connection refused")
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestRetrieveByKey() {
+	rows := sqlmock.NewRows([]string{"actor_id", "key", "route", "request_hash", "status", "content_type", "body", "created_at", "expires_at", "locked_until"}).
+		AddRow("u1", "k1", "POST /api/v1/booking", "abc", 201, "application/json; charset=utf-8", []byte(`{"responseCode":"2010501"}`), "2026-10-19 09:30:00", "2026-10-20 09:30:00", "2026-10-19 09:35:00")
+	suite.mock.ExpectQuery(`SELECT (.+) FROM idempotency_keys WHERE actor_id = \$1 AND key = \$2`).WithArgs("u1", "k1").WillReturnRows(rows)
+
+	expected := record
+	expected.Status, expected.ContentType, expected.Body = 201, "application/json; charset=utf-8", []byte(`{"responseCode":"2010501"}`)
+
+	actual, err := suite.idempotencyRepo.RetrieveByKey(context.Background(), "u1", "k1")
+	suite.NoError(err)
+	suite.Equal(expected, actual)
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestRetrieveByKeyNotFound() {
+	suite.mock.ExpectQuery(`SELECT (.+) FROM idempotency_keys`).WillReturnError(sql.ErrNoRows)
+
+	_, err := suite.idempotencyRepo.RetrieveByKey(context.Background(), "u1", "k1")
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestComplete() {
+	completed := record
+	completed.Status, completed.ContentType, completed.Body = 201, "application/json", []byte(`{}`)
+	suite.mock.ExpectExec(`UPDATE idempotency_keys SET status = \$3, content_type = \$4, body = \$5 WHERE actor_id = \$1 AND key = \$2`).
+		WithArgs("u1", "k1", 201, "application/json", []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	suite.NoError(suite.idempotencyRepo.Complete(context.Background(), completed))
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestRelease() {
+	// only a key still waiting for its response is freed
+	suite.mock.ExpectExec(`DELETE FROM idempotency_keys WHERE actor_id = \$1 AND key = \$2 AND status IS NULL`).
+		WithArgs("u1", "k1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	suite.NoError(suite.idempotencyRepo.Release(context.Background(), "u1", "k1"))
+}
+
+func (suite *idempotencyRepositoryTestSuite) TestDeleteExpired() {
+	suite.mock.ExpectExec(`DELETE FROM idempotency_keys WHERE expires_at <= \$1`).
+		WithArgs("2026-10-19 09:30:00").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	deleted, err := suite.idempotencyRepo.DeleteExpired(context.Background(), "2026-10-19 09:30:00")
+	suite.NoError(err)
+	suite.Equal(int64(4), deleted)
+}
+
+func TestIdempotencyRepository(t *testing.T) {
+	suite.Run(t, new(idempotencyRepositoryTestSuite))
+}
@@ -0,0 +1,96 @@
+package idempotencyUsecase
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/idempotency"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type idempotencyUsecase struct {
+	idempotencyRepo idempotency.IdempotencyRepository
+	retention       time.Duration
+	lock            time.Duration
+}
+
+// NewIdempotencyUsecase keeps the responses of requests sent with an
+// Idempotency-Key for retention, retries within it are answered with them. A
+// request holds its key for lock, a key still without a response after it is
+// given to the next retry.
+func NewIdempotencyUsecase(idempotencyRepo idempotency.IdempotencyRepository, retention, lock time.Duration) idempotency.IdempotencyUsecase {
+	return &idempotencyUsecase{idempotencyRepo, retention, lock}
+}
+
+// Begin claims the key of the record for its request. When the key was used
+// before for the same request, the record of that request is returned with
+// its response to replay. A zero Status means the request is to run now.
+func (usecase *idempotencyUsecase) Begin(ctx context.Context, record idempotencyDto.Record) (idempotencyDto.Record, error) {
+	now := time.Now()
+	record.CreatedAt = now.Format("2006-01-02 15:04:05")
+	record.ExpiresAt = now.Add(usecase.retention).Format("2006-01-02 15:04:05")
+	record.LockedUntil = now.Add(usecase.lock).Format("2006-01-02 15:04:05")
+
+	claimed, err := usecase.idempotencyRepo.Claim(ctx, record)
+	if err != nil {
+		return idempotencyDto.Record{}, err
+	}
+	if claimed {
+		return idempotencyDto.Record{}, nil
+	}
+
+	// the request holding the key released it in the meantime, a retry may claim it
+	stored, err := usecase.idempotencyRepo.RetrieveByKey(ctx, record.ActorID, record.Key)
+	if err == sql.ErrNoRows {
+		return idempotencyDto.Record{}, errors.New(constants.ErrIdempotencyKeyInProgress)
+	}
+	if err != nil {
+		return idempotencyDto.Record{}, err
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		return idempotencyDto.Record{}, errors.New(constants.ErrIdempotencyKeyReused)
+	}
+	if stored.Status == 0 {
+		return idempotencyDto.Record{}, errors.New(constants.ErrIdempotencyKeyInProgress)
+	}
+	return stored, nil
+}
+
+func (usecase *idempotencyUsecase) Complete(ctx context.Context, record idempotencyDto.Record) error {
+	return usecase.idempotencyRepo.Complete(ctx, record)
+}
+
+func (usecase *idempotencyUsecase) Release(ctx context.Context, actorID, key string) error {
+	return usecase.idempotencyRepo.Release(ctx, actorID, key)
+}
+
+// PurgeExpired deletes the keys past their retention. Expired keys are
+// already taken over by new requests, this only keeps the table small.
+func (usecase *idempotencyUsecase) PurgeExpired(ctx context.Context) (int64, error) {
+	return usecase.idempotencyRepo.DeleteExpired(ctx, time.Now().Format("2006-01-02 15:04:05"))
+}
+
+// StartSweeper purges expired keys every interval in the background.
+func StartSweeper(idempotencyUC idempotency.IdempotencyUsecase, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := idempotencyUC.PurgeExpired(context.Background())
+			if err != nil {
+				log.Error().Msg("StartSweeper.err : " + err.Error())
+				continue
+			}
+
+			if purged > 0 {
+				log.Info().Int64("purged", purged).Msg("expired idempotency keys purged")
+			}
+		}
+	}()
+}
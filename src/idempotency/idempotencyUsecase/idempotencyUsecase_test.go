@@ -0,0 +1,129 @@
+package idempotencyUsecase
+
+import (
+	"avengers-clinic/model/dto/idempotencyDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/idempotency"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *mockIdempotencyRepository) Claim(ctx context.Context, record idempotencyDto.Record) (bool, error) {
+	args := m.Called(record)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockIdempotencyRepository) RetrieveByKey(ctx context.Context, actorID, key string) (idempotencyDto.Record, error) {
+	args := m.Called(actorID, key)
+	return args.Get(0).(idempotencyDto.Record), args.Error(1)
+}
+
+func (m *mockIdempotencyRepository) Complete(ctx context.Context, record idempotencyDto.Record) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *mockIdempotencyRepository) Release(ctx context.Context, actorID, key string) error {
+	args := m.Called(actorID, key)
+	return args.Error(0)
+}
+
+func (m *mockIdempotencyRepository) DeleteExpired(ctx context.Context, now string) (int64, error) {
+	args := m.Called(now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type idempotencyUsecaseTestSuite struct {
+	suite.Suite
+	idempotencyRepo *mockIdempotencyRepository
+	idempotencyUC   idempotency.IdempotencyUsecase
+}
+
+func (suite *idempotencyUsecaseTestSuite) SetupTest() {
+	suite.idempotencyRepo = new(mockIdempotencyRepository)
+	suite.idempotencyUC = NewIdempotencyUsecase(suite.idempotencyRepo, 24*time.Hour, 5*time.Minute)
+}
+
+var record = idempotencyDto.Record{ActorID: "u1", Key: "k1", Route: "POST /api/v1/booking", RequestHash: "abc"}
+
+var completed = idempotencyDto.Record{ActorID: "u1", Key: "k1", Route: "POST /api/v1/booking", RequestHash: "abc",
+	Status: 201, ContentType: "application/json", Body: []byte(`{"responseCode":"2010501"}`)}
+
+func (suite *idempotencyUsecaseTestSuite) TestBeginClaims() {
+	suite.idempotencyRepo.On("Claim", mock.MatchedBy(func(claimed idempotencyDto.Record) bool {
+		createdAt, _ := time.Parse("2006-01-02 15:04:05", claimed.CreatedAt)
+		expiresAt, _ := time.Parse("2006-01-02 15:04:05", claimed.ExpiresAt)
+		lockedUntil, _ := time.Parse("2006-01-02 15:04:05", claimed.LockedUntil)
+		return claimed.Key == "k1" && expiresAt.Sub(createdAt) == 24*time.Hour && lockedUntil.Sub(createdAt) == 5*time.Minute
+	})).Return(true, nil)
+
+	stored, err := suite.idempotencyUC.Begin(context.Background(), record)
+	suite.NoError(err)
+	suite.Zero(stored.Status)
+	suite.idempotencyRepo.AssertNotCalled(suite.T(), "RetrieveByKey", "u1", "k1")
+}
+
+func (suite *idempotencyUsecaseTestSuite) TestBeginReplays() {
+	suite.idempotencyRepo.On("Claim", mock.Anything).Return(false, nil)
+	suite.idempotencyRepo.On("RetrieveByKey", "u1", "k1").Return(completed, nil)
+
+	stored, err := suite.idempotencyUC.Begin(context.Background(), record)
+	suite.NoError(err)
+	suite.Equal(completed, stored)
+}
+
+func (suite *idempotencyUsecaseTestSuite) TestBeginKeyReused() {
+	other := completed
+	other.RequestHash = "def"
+	suite.idempotencyRepo.On("Claim", mock.Anything).Return(false, nil)
+	suite.idempotencyRepo.On("RetrieveByKey", "u1", "k1").Return(other, nil)
+
+	_, err := suite.idempotencyUC.Begin(context.Background(), record)
+	suite.EqualError(err, constants.ErrIdempotencyKeyReused)
+}
+
+func (suite *idempotencyUsecaseTestSuite) TestBeginInProgress() {
+	running := record
+	suite.idempotencyRepo.On("Claim", mock.Anything).Return(false, nil)
+	suite.idempotencyRepo.On("RetrieveByKey", "u1", "k1").Return(running, nil)
+
+	_, err := suite.idempotencyUC.Begin(context.Background(), record)
+	suite.EqualError(err, constants.ErrIdempotencyKeyInProgress)
+}
+
+func (suite *idempotencyUsecaseTestSuite) TestBeginReleasedMeanwhile() {
+	suite.idempotencyRepo.On("Claim", mock.Anything).Return(false, nil)
+	suite.idempotencyRepo.On("RetrieveByKey", "u1", "k1").Return(idempotencyDto.Record{}, sql.ErrNoRows)
+
+	_, err := suite.idempotencyUC.Begin(context.Background(), record)
+	suite.EqualError(err, constants.ErrIdempotencyKeyInProgress)
+}
+
+func (suite *idempotencyUsecaseTestSuite) TestBeginClaimFailed() {
+	suite.idempotencyRepo.On("Claim", mock.Anything).Return(false, errors.New("connection refused"))
+
+	_, err := suite.idempotencyUC.Begin(context.Background(), record)
+	suite.EqualError(err, "connection refused")
+}
+
+func (suite *idempotencyUsecaseTestSuite) TestPurgeExpired() {
+	suite.idempotencyRepo.On("DeleteExpired", mock.Anything).Return(int64(3), nil)
+
+	purged, err := suite.idempotencyUC.PurgeExpired(context.Background())
+	suite.NoError(err)
+	suite.Equal(int64(3), purged)
+}
+
+func TestIdempotencyUsecase(t *testing.T) {
+	suite.Run(t, new(idempotencyUsecaseTestSuite))
+}
@@ -0,0 +1,362 @@
+package insuranceDelivery
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/insurance"
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type insuranceDelivery struct {
+	insuranceUC insurance.InsuranceUsecase
+}
+
+func NewInsuranceDelivery(v1Group *gin.RouterGroup, insuranceUC insurance.InsuranceUsecase) {
+	handler := insuranceDelivery{insuranceUC}
+
+	payerGroup := v1Group.Group("/payers")
+	{
+		payerGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetPayers)
+		payerGroup.POST("", middleware.JwtAuth("ADMIN"), handler.CreatePayer)
+		payerGroup.PUT("/:id", middleware.JwtAuth("ADMIN"), handler.UpdatePayer)
+		payerGroup.GET("/:id/rules", middleware.JwtAuth("ADMIN"), handler.GetRules)
+		payerGroup.PUT("/:id/rules", middleware.JwtAuth("ADMIN"), handler.SetRule)
+		payerGroup.DELETE("/:id/rules/:rule-id", middleware.JwtAuth("ADMIN"), handler.DeleteRule)
+	}
+
+	membershipGroup := v1Group.Group("/payer-memberships")
+	{
+		membershipGroup.GET("/patient/:patient-id", middleware.JwtAuth("ADMIN", "PATIENT"), handler.GetMemberships)
+		membershipGroup.POST("", middleware.JwtAuth("ADMIN"), handler.AddMembership)
+		membershipGroup.DELETE("/:id", middleware.JwtAuth("ADMIN"), handler.RemoveMembership)
+	}
+
+	invoiceGroup := v1Group.Group("/invoices")
+	{
+		invoiceGroup.PUT("/:id/coverage", middleware.JwtAuth("ADMIN"), handler.ApplyCoverage)
+		invoiceGroup.DELETE("/:id/coverage", middleware.JwtAuth("ADMIN"), handler.RemoveCoverage)
+	}
+
+	claimGroup := v1Group.Group("/claims")
+	{
+		claimGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetClaims)
+	}
+
+	batchGroup := v1Group.Group("/claim-batches")
+	{
+		batchGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetBatches)
+		batchGroup.GET("/:id", middleware.JwtAuth("ADMIN"), handler.GetBatchByID)
+		batchGroup.POST("", middleware.JwtAuth("ADMIN"), handler.SubmitBatch)
+		batchGroup.POST("/:id/decisions", middleware.JwtAuth("ADMIN"), handler.DecideClaims)
+		batchGroup.GET("/:id/export", middleware.JwtAuth("ADMIN"), handler.ExportBatch)
+	}
+}
+
+func (delivery *insuranceDelivery) GetPayers(c *gin.Context) {
+	payers, err := delivery.insuranceUC.GetPayers(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "01")
+		return
+	}
+
+	json.NewResponseSuccess(c, payers, "Payers retrieved successfully", constants.InsuranceService, "01")
+}
+
+func (delivery *insuranceDelivery) CreatePayer(c *gin.Context) {
+	var request insuranceDto.PayerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "02")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	payer, err := delivery.insuranceUC.CreatePayer(c.Request.Context(), request)
+	if err != nil {
+		delivery.insuranceError(c, err, "03")
+		return
+	}
+
+	json.NewResponseCreated(c, payer, "Payer created successfully", constants.InsuranceService, "01")
+}
+
+func (delivery *insuranceDelivery) UpdatePayer(c *gin.Context) {
+	var request insuranceDto.PayerRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "04")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	request.ID = c.Param("id")
+	payer, err := delivery.insuranceUC.UpdatePayer(c.Request.Context(), request)
+	if err != nil {
+		delivery.insuranceError(c, err, "05")
+		return
+	}
+
+	json.NewResponseSuccess(c, payer, "Payer updated successfully", constants.InsuranceService, "02")
+}
+
+func (delivery *insuranceDelivery) GetRules(c *gin.Context) {
+	rules, err := delivery.insuranceUC.GetRules(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.insuranceError(c, err, "06")
+		return
+	}
+
+	json.NewResponseSuccess(c, rules, "Coverage rules retrieved successfully", constants.InsuranceService, "03")
+}
+
+func (delivery *insuranceDelivery) SetRule(c *gin.Context) {
+	var request insuranceDto.CoverageRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "07")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	request.PayerID = c.Param("id")
+	rule, err := delivery.insuranceUC.SetRule(c.Request.Context(), request)
+	if err != nil {
+		delivery.insuranceError(c, err, "08")
+		return
+	}
+
+	json.NewResponseSuccess(c, rule, "Coverage rule saved successfully", constants.InsuranceService, "04")
+}
+
+func (delivery *insuranceDelivery) DeleteRule(c *gin.Context) {
+	if err := delivery.insuranceUC.DeleteRule(c.Request.Context(), c.Param("id"), c.Param("rule-id")); err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Coverage rule not found", constants.InsuranceService, "02")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "09")
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Coverage rule deleted successfully", constants.InsuranceService, "05")
+}
+
+func (delivery *insuranceDelivery) GetMemberships(c *gin.Context) {
+	claims := utils.GetJWT(c)
+	if claims.Role == "PATIENT" && claims.ID != c.Param("patient-id") {
+		json.NewResponseForbidden(c, "Access denied", constants.InsuranceService, "01")
+		return
+	}
+
+	memberships, err := delivery.insuranceUC.GetMemberships(c.Request.Context(), c.Param("patient-id"))
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "10")
+		return
+	}
+
+	json.NewResponseSuccess(c, memberships, "Memberships retrieved successfully", constants.InsuranceService, "06")
+}
+
+func (delivery *insuranceDelivery) AddMembership(c *gin.Context) {
+	var request insuranceDto.MembershipRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "11")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	memberships, err := delivery.insuranceUC.AddMembership(c.Request.Context(), request)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Patient or payer not found", constants.InsuranceService, "03")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "12")
+		return
+	}
+
+	json.NewResponseCreated(c, memberships, "Membership saved successfully", constants.InsuranceService, "02")
+}
+
+func (delivery *insuranceDelivery) RemoveMembership(c *gin.Context) {
+	if err := delivery.insuranceUC.RemoveMembership(c.Request.Context(), c.Param("id")); err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Membership not found", constants.InsuranceService, "04")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "13")
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Membership removed successfully", constants.InsuranceService, "07")
+}
+
+func (delivery *insuranceDelivery) ApplyCoverage(c *gin.Context) {
+	var request insuranceDto.CoverageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "14")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	coverage, err := delivery.insuranceUC.ApplyCoverage(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.insuranceError(c, err, "15")
+		return
+	}
+
+	json.NewResponseSuccess(c, coverage, "Coverage applied successfully", constants.InsuranceService, "08")
+}
+
+func (delivery *insuranceDelivery) RemoveCoverage(c *gin.Context) {
+	if err := delivery.insuranceUC.RemoveCoverage(c.Request.Context(), c.Param("id")); err != nil {
+		delivery.insuranceError(c, err, "16")
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Coverage removed successfully", constants.InsuranceService, "09")
+}
+
+func (delivery *insuranceDelivery) GetClaims(c *gin.Context) {
+	filter := insuranceDto.ClaimFilter{
+		Status:  c.Query("status"),
+		PayerID: c.Query("payer_id"),
+	}
+
+	claims, err := delivery.insuranceUC.GetClaims(c.Request.Context(), filter)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "17")
+		return
+	}
+
+	json.NewResponseSuccess(c, claims, "Claims retrieved successfully", constants.InsuranceService, "10")
+}
+
+func (delivery *insuranceDelivery) GetBatches(c *gin.Context) {
+	batches, err := delivery.insuranceUC.GetBatches(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "18")
+		return
+	}
+
+	json.NewResponseSuccess(c, batches, "Claim batches retrieved successfully", constants.InsuranceService, "11")
+}
+
+func (delivery *insuranceDelivery) GetBatchByID(c *gin.Context) {
+	batch, err := delivery.insuranceUC.GetBatchByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.insuranceError(c, err, "19")
+		return
+	}
+
+	json.NewResponseSuccess(c, batch, "Claim batch retrieved successfully", constants.InsuranceService, "12")
+}
+
+func (delivery *insuranceDelivery) SubmitBatch(c *gin.Context) {
+	var request insuranceDto.BatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "20")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	request.SubmittedBy = utils.GetJWT(c).ID
+	batch, err := delivery.insuranceUC.SubmitBatch(c.Request.Context(), request)
+	if err != nil {
+		delivery.insuranceError(c, err, "21")
+		return
+	}
+
+	json.NewResponseCreated(c, batch, "Claim batch submitted successfully", constants.InsuranceService, "03")
+}
+
+func (delivery *insuranceDelivery) DecideClaims(c *gin.Context) {
+	var request insuranceDto.DecisionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InsuranceService, "22")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InsuranceService, "01")
+		return
+	}
+
+	request.DecidedBy = utils.GetJWT(c).ID
+	batch, err := delivery.insuranceUC.DecideClaims(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.insuranceError(c, err, "23")
+		return
+	}
+
+	json.NewResponseSuccess(c, batch, "Claim decisions recorded successfully", constants.InsuranceService, "13")
+}
+
+func (delivery *insuranceDelivery) ExportBatch(c *gin.Context) {
+	export, err := delivery.insuranceUC.ExportBatch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.insuranceError(c, err, "24")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName+`"`)
+	c.Data(http.StatusOK, export.ContentType, export.Content)
+}
+
+func (delivery *insuranceDelivery) insuranceError(c *gin.Context, err error, errorCode string) {
+	if err == sql.ErrNoRows {
+		json.NewResponseNotFound(c, "Data not found", constants.InsuranceService, "05")
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrPayerNotExist:
+		json.NewResponseNotFound(c, err.Error(), constants.InsuranceService, "06")
+		return
+	case constants.ErrInvalidClaimField:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "claim_fields", Message: err.Error()}}, "Bad request", constants.InsuranceService, "02")
+		return
+	case constants.ErrApprovedExceedsClaim:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "approved_amount", Message: err.Error()}}, "Bad request", constants.InsuranceService, "03")
+		return
+	case constants.ErrRejectionReasonRequired:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "reason", Message: err.Error()}}, "Bad request", constants.InsuranceService, "04")
+		return
+	case constants.ErrPayerCodeExist, constants.ErrMembershipNotExist, constants.ErrInvoiceNotDraft, constants.ErrNoClaimsToSubmit,
+		constants.ErrClaimNotDecidable, constants.ErrPaymentNotVoidable:
+		json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.InsuranceService, "05")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.InsuranceService, errorCode)
+}
@@ -0,0 +1,48 @@
+package insurance
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"context"
+)
+
+type InsuranceRepository interface {
+	RetrievePayers(ctx context.Context) ([]insuranceDto.Payer, error)
+	RetrievePayerByID(ctx context.Context, id string) (insuranceDto.Payer, error)
+	InsertPayer(ctx context.Context, req insuranceDto.PayerRequest) (string, error)
+	UpdatePayer(ctx context.Context, req insuranceDto.PayerRequest) error
+	RetrieveRules(ctx context.Context, payerID string) ([]insuranceDto.CoverageRule, error)
+	UpsertRule(ctx context.Context, req insuranceDto.CoverageRuleRequest) (insuranceDto.CoverageRule, error)
+	DeleteRule(ctx context.Context, payerID, ruleID string) error
+	RetrieveMemberships(ctx context.Context, patientID string) ([]insuranceDto.Membership, error)
+	RetrieveMembership(ctx context.Context, patientID, payerID string) (insuranceDto.Membership, error)
+	InsertMembership(ctx context.Context, req insuranceDto.MembershipRequest) (string, error)
+	DeleteMembership(ctx context.Context, id string) error
+	RetrieveCoverableInvoice(ctx context.Context, invoiceID string) (insuranceDto.CoverableInvoice, error)
+	UpdateCoverage(ctx context.Context, coverage insuranceDto.Coverage) error
+	RemoveCoverage(ctx context.Context, invoiceID string) error
+	RetrieveClaims(ctx context.Context, filter insuranceDto.ClaimFilter) ([]insuranceDto.Claim, error)
+	RetrieveBatches(ctx context.Context) ([]insuranceDto.Batch, error)
+	RetrieveBatchByID(ctx context.Context, id string) (insuranceDto.Batch, error)
+	InsertBatch(ctx context.Context, req insuranceDto.BatchRequest) (string, error)
+	DecideClaims(ctx context.Context, batchID string, req insuranceDto.DecisionRequest) error
+}
+
+type InsuranceUsecase interface {
+	GetPayers(ctx context.Context) ([]insuranceDto.Payer, error)
+	CreatePayer(ctx context.Context, req insuranceDto.PayerRequest) (insuranceDto.Payer, error)
+	UpdatePayer(ctx context.Context, req insuranceDto.PayerRequest) (insuranceDto.Payer, error)
+	GetRules(ctx context.Context, payerID string) ([]insuranceDto.CoverageRule, error)
+	SetRule(ctx context.Context, req insuranceDto.CoverageRuleRequest) (insuranceDto.CoverageRule, error)
+	DeleteRule(ctx context.Context, payerID, ruleID string) error
+	GetMemberships(ctx context.Context, patientID string) ([]insuranceDto.Membership, error)
+	AddMembership(ctx context.Context, req insuranceDto.MembershipRequest) ([]insuranceDto.Membership, error)
+	RemoveMembership(ctx context.Context, id string) error
+	ApplyCoverage(ctx context.Context, invoiceID string, req insuranceDto.CoverageRequest) (insuranceDto.Coverage, error)
+	RemoveCoverage(ctx context.Context, invoiceID string) error
+	GetClaims(ctx context.Context, filter insuranceDto.ClaimFilter) ([]insuranceDto.Claim, error)
+	GetBatches(ctx context.Context) ([]insuranceDto.Batch, error)
+	GetBatchByID(ctx context.Context, id string) (insuranceDto.Batch, error)
+	SubmitBatch(ctx context.Context, req insuranceDto.BatchRequest) (insuranceDto.Batch, error)
+	DecideClaims(ctx context.Context, batchID string, req insuranceDto.DecisionRequest) (insuranceDto.Batch, error)
+	ExportBatch(ctx context.Context, id string) (insuranceDto.Export, error)
+}
@@ -0,0 +1,277 @@
+package insuranceRepository
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/invoice/invoiceRepository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const claimColumns = `
+	c.id, c.invoice_id, COALESCE(i.invoice_number, ''), c.payer_id, COALESCE(c.batch_id::text, ''), c.payment_id,
+	c.member_number, p.username, d.username, s.schedule_date::text, r.diagnosis_results, c.status, c.claimed_amount,
+	c.approved_amount, COALESCE(c.rejection_reason, ''), COALESCE(c.decided_by::text, ''), c.created_at, COALESCE(c.decided_at::text, '')
+	FROM insurance_claims c
+	JOIN invoices i ON i.id = c.invoice_id
+	JOIN medical_records r ON r.id = i.medical_record_id
+	JOIN bookings b ON b.id = r.booking_id
+	JOIN users p ON p.id = b.patient_id
+	JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+	JOIN users d ON d.id = s.doctor_id`
+
+const batchColumns = `
+	b.id, b.batch_number, b.payer_id, p.name, b.status, b.total_claimed, b.total_approved,
+	COALESCE(b.submitted_by::text, ''), b.submitted_at, COALESCE(b.decided_at::text, '')
+	FROM claim_batches b JOIN payers p ON p.id = b.payer_id`
+
+func (repository *insuranceRepository) RetrieveClaims(ctx context.Context, filter insuranceDto.ClaimFilter) ([]insuranceDto.Claim, error) {
+	query := "SELECT " + claimColumns + " WHERE 1 = 1"
+
+	var args []interface{}
+	addFilter := func(condition string, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		query += " AND " + condition + " $" + strconv.Itoa(len(args))
+	}
+	addFilter("c.status =", filter.Status)
+	addFilter("c.payer_id =", filter.PayerID)
+	addFilter("c.batch_id =", filter.BatchID)
+	query += " ORDER BY c.created_at;"
+
+	rows, err := repository.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claims []insuranceDto.Claim
+	for rows.Next() {
+		var claim insuranceDto.Claim
+		err := rows.Scan(&claim.ID, &claim.InvoiceID, &claim.InvoiceNumber, &claim.PayerID, &claim.BatchID, &claim.PaymentID,
+			&claim.MemberNumber, &claim.PatientName, &claim.DoctorName, &claim.ServiceDate, &claim.Diagnosis, &claim.Status,
+			&claim.ClaimedAmount, &claim.ApprovedAmount, &claim.RejectionReason, &claim.DecidedBy, &claim.CreatedAt, &claim.DecidedAt)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	return claims, nil
+}
+
+func (repository *insuranceRepository) RetrieveBatches(ctx context.Context) ([]insuranceDto.Batch, error) {
+	rows, err := repository.db.QueryContext(ctx, "SELECT "+batchColumns+" ORDER BY b.submitted_at DESC;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []insuranceDto.Batch
+	for rows.Next() {
+		batch, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+func (repository *insuranceRepository) RetrieveBatchByID(ctx context.Context, id string) (insuranceDto.Batch, error) {
+	batch, err := scanBatch(repository.db.QueryRowContext(ctx, "SELECT "+batchColumns+" WHERE b.id = $1;", id))
+	if err != nil {
+		return insuranceDto.Batch{}, err
+	}
+
+	batch.Claims, err = repository.RetrieveClaims(ctx, insuranceDto.ClaimFilter{BatchID: id})
+	if err != nil {
+		return insuranceDto.Batch{}, err
+	}
+	return batch, nil
+}
+
+// InsertBatch submits every pending claim of the payer in a new batch,
+// numbered per payer and month.
+func (repository *insuranceRepository) InsertBatch(ctx context.Context, req insuranceDto.BatchRequest) (string, error) {
+	var id string
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var code string
+		if err := tx.QueryRowContext(ctx, "SELECT code FROM payers WHERE id = $1 AND deleted_at IS NULL;", req.PayerID).Scan(&code); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.New(constants.ErrPayerNotExist)
+			}
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, "SELECT id, claimed_amount FROM insurance_claims WHERE payer_id = $1 AND status = $2 FOR UPDATE;", req.PayerID, constants.ClaimPending)
+		if err != nil {
+			return err
+		}
+
+		var claimIDs []string
+		var total int
+		for rows.Next() {
+			var id string
+			var amount int
+			if err := rows.Scan(&id, &amount); err != nil {
+				rows.Close()
+				return err
+			}
+			claimIDs, total = append(claimIDs, id), total+amount
+		}
+		rows.Close()
+
+		if len(claimIDs) == 0 {
+			return errors.New(constants.ErrNoClaimsToSubmit)
+		}
+
+		now := time.Now()
+		period := now.Format("200601")
+
+		var number int
+		query := `
+			INSERT INTO claim_batch_sequences (payer_id, period, last_number) VALUES ($1, $2, 1)
+			ON CONFLICT (payer_id, period) DO UPDATE SET last_number = claim_batch_sequences.last_number + 1
+			RETURNING last_number;`
+		if err := tx.QueryRowContext(ctx, query, req.PayerID, period).Scan(&number); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO claim_batches (batch_number, payer_id, status, total_claimed, submitted_by, submitted_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+		err = tx.QueryRowContext(ctx, query, fmt.Sprintf("CLM/%s/%s/%04d", code, period, number), req.PayerID, constants.ClaimBatchSubmitted, total,
+			nullable(req.SubmittedBy), now.Format("2006-01-02 15:04:05")).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		query = "UPDATE insurance_claims SET batch_id = $1, status = $2 WHERE id = $3;"
+		for _, claimID := range claimIDs {
+			if _, err := tx.ExecContext(ctx, query, id, constants.ClaimSubmitted, claimID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// DecideClaims records the decisions of the payer on claims of a batch. What
+// the payer does not pay becomes part of the balance of the patient. The batch
+// is decided once none of its claims is waiting anymore.
+func (repository *insuranceRepository) DecideClaims(ctx context.Context, batchID string, req insuranceDto.DecisionRequest) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var status string
+		if err := tx.QueryRowContext(ctx, "SELECT status FROM claim_batches WHERE id = $1 FOR UPDATE;", batchID).Scan(&status); err != nil {
+			return err
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		for _, decision := range req.Decisions {
+			if err := decideClaim(ctx, tx, batchID, decision, req.DecidedBy, now); err != nil {
+				return err
+			}
+		}
+
+		return updateBatchStatus(ctx, tx, batchID, now)
+	})
+}
+
+func decideClaim(ctx context.Context, tx *sql.Tx, batchID string, decision insuranceDto.ClaimDecision, decidedBy, now string) error {
+	var invoiceID, paymentID, status string
+	var claimed int
+	query := "SELECT invoice_id, payment_id, status, claimed_amount FROM insurance_claims WHERE id = $1 AND batch_id = $2 FOR UPDATE;"
+	err := tx.QueryRowContext(ctx, query, decision.ClaimID, batchID).Scan(&invoiceID, &paymentID, &status, &claimed)
+	if err == sql.ErrNoRows || (err == nil && status != constants.ClaimSubmitted) {
+		return errors.New(constants.ErrClaimNotDecidable)
+	}
+	if err != nil {
+		return err
+	}
+
+	approved := 0
+	if decision.Status == constants.ClaimApproved {
+		approved = decision.ApprovedAmount
+		if approved == 0 {
+			approved = claimed
+		}
+	}
+
+	if approved > claimed {
+		return errors.New(constants.ErrApprovedExceedsClaim)
+	}
+
+	if approved < claimed {
+		note := fmt.Sprintf("claim %s", decision.Status)
+		if decision.Reason != "" {
+			note += ": " + decision.Reason
+		}
+
+		newPaymentID, err := invoiceRepository.ReduceCoverage(ctx, tx, invoiceID, paymentID, approved, note, decidedBy)
+		if err != nil {
+			return err
+		}
+
+		if newPaymentID != "" {
+			paymentID = newPaymentID
+		}
+	}
+
+	query = `
+		UPDATE insurance_claims SET status = $1, approved_amount = $2, payment_id = $3, rejection_reason = $4, decided_by = $5, decided_at = $6
+		WHERE id = $7;`
+	_, err = tx.ExecContext(ctx, query, decision.Status, approved, paymentID, nullable(decision.Reason), nullable(decidedBy), now, decision.ClaimID)
+	return err
+}
+
+func updateBatchStatus(ctx context.Context, tx *sql.Tx, batchID, now string) error {
+	var waiting, approved, rejected, totalApproved int
+	query := `
+		SELECT COUNT(*) FILTER (WHERE status = $2), COUNT(*) FILTER (WHERE status = $3), COUNT(*) FILTER (WHERE status = $4),
+			COALESCE(SUM(approved_amount), 0)
+		FROM insurance_claims WHERE batch_id = $1;`
+	err := tx.QueryRowContext(ctx, query, batchID, constants.ClaimSubmitted, constants.ClaimApproved, constants.ClaimRejected).
+		Scan(&waiting, &approved, &rejected, &totalApproved)
+	if err != nil {
+		return err
+	}
+
+	status, decidedAt := constants.ClaimBatchSubmitted, interface{}(nil)
+	if waiting == 0 {
+		decidedAt = now
+		switch {
+		case rejected == 0:
+			status = constants.ClaimBatchApproved
+		case approved == 0:
+			status = constants.ClaimBatchRejected
+		default:
+			status = constants.ClaimBatchPartiallyApproved
+		}
+	}
+
+	query = "UPDATE claim_batches SET status = $1, total_approved = $2, decided_at = $3 WHERE id = $4;"
+	_, err = tx.ExecContext(ctx, query, status, totalApproved, decidedAt, batchID)
+	return err
+}
+
+func scanBatch(row scanner) (insuranceDto.Batch, error) {
+	var batch insuranceDto.Batch
+	err := row.Scan(&batch.ID, &batch.BatchNumber, &batch.PayerID, &batch.PayerName, &batch.Status, &batch.TotalClaimed,
+		&batch.TotalApproved, &batch.SubmittedBy, &batch.SubmittedAt, &batch.DecidedAt)
+	return batch, err
+}
@@ -0,0 +1,319 @@
+package insuranceRepository
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/insurance"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const payerColumns = `
+	id, code, name, payer_type, claim_format, COALESCE(claim_fields, ''), created_at, COALESCE(updated_at::text, '')
+	FROM payers`
+
+type insuranceRepository struct {
+	db *sql.DB
+}
+
+func NewInsuranceRepository(db *sql.DB) insurance.InsuranceRepository {
+	return &insuranceRepository{db}
+}
+
+func (repository *insuranceRepository) RetrievePayers(ctx context.Context) ([]insuranceDto.Payer, error) {
+	query := "SELECT " + payerColumns + " WHERE deleted_at IS NULL ORDER BY name;"
+	rows, err := repository.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payers []insuranceDto.Payer
+	for rows.Next() {
+		payer, err := scanPayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		payers = append(payers, payer)
+	}
+	return payers, nil
+}
+
+func (repository *insuranceRepository) RetrievePayerByID(ctx context.Context, id string) (insuranceDto.Payer, error) {
+	query := "SELECT " + payerColumns + " WHERE id = $1 AND deleted_at IS NULL;"
+	return scanPayer(repository.db.QueryRowContext(ctx, query, id))
+}
+
+func (repository *insuranceRepository) InsertPayer(ctx context.Context, req insuranceDto.PayerRequest) (string, error) {
+	query := `
+		INSERT INTO payers (code, name, payer_type, claim_format, claim_fields, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+
+	var id string
+	err := repository.db.QueryRowContext(ctx, query, req.Code, req.Name, req.PayerType, req.ClaimFormat, nullable(strings.Join(req.ClaimFields, ",")),
+		time.Now().Format("2006-01-02 15:04:05")).Scan(&id)
+	if isUniqueViolation(err) {
+		return "", errors.New(constants.ErrPayerCodeExist)
+	}
+	return id, err
+}
+
+func (repository *insuranceRepository) UpdatePayer(ctx context.Context, req insuranceDto.PayerRequest) error {
+	query := `
+		UPDATE payers SET code = $1, name = $2, payer_type = $3, claim_format = $4, claim_fields = $5, updated_at = $6
+		WHERE id = $7 AND deleted_at IS NULL;`
+	result, err := repository.db.ExecContext(ctx, query, req.Code, req.Name, req.PayerType, req.ClaimFormat, nullable(strings.Join(req.ClaimFields, ",")),
+		time.Now().Format("2006-01-02 15:04:05"), req.ID)
+	if isUniqueViolation(err) {
+		return errors.New(constants.ErrPayerCodeExist)
+	}
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (repository *insuranceRepository) RetrieveRules(ctx context.Context, payerID string) ([]insuranceDto.CoverageRule, error) {
+	query := `
+		SELECT id, payer_id, item_type, COALESCE(reference_id::text, ''), coverage_percent, max_amount, updated_at
+		FROM payer_coverage_rules WHERE payer_id = $1 ORDER BY item_type, reference_id NULLS FIRST;`
+	rows, err := repository.db.QueryContext(ctx, query, payerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []insuranceDto.CoverageRule
+	for rows.Next() {
+		var rule insuranceDto.CoverageRule
+		err := rows.Scan(&rule.ID, &rule.PayerID, &rule.ItemType, &rule.ReferenceID, &rule.CoveragePercent, &rule.MaxAmount, &rule.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// UpsertRule sets the coverage of an item type, or of a single medicine or
+// action when a reference is given, replacing the rule it had.
+func (repository *insuranceRepository) UpsertRule(ctx context.Context, req insuranceDto.CoverageRuleRequest) (insuranceDto.CoverageRule, error) {
+	query := `
+		INSERT INTO payer_coverage_rules (payer_id, item_type, reference_id, coverage_percent, max_amount, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (payer_id, item_type, COALESCE(reference_id, '00000000-0000-0000-0000-000000000000'))
+		DO UPDATE SET coverage_percent = EXCLUDED.coverage_percent, max_amount = EXCLUDED.max_amount, updated_at = EXCLUDED.updated_at
+		RETURNING id, updated_at::text;`
+
+	rule := insuranceDto.CoverageRule{
+		PayerID:         req.PayerID,
+		ItemType:        req.ItemType,
+		ReferenceID:     req.ReferenceID,
+		CoveragePercent: req.CoveragePercent,
+		MaxAmount:       req.MaxAmount,
+	}
+	err := repository.db.QueryRowContext(ctx, query, req.PayerID, req.ItemType, nullable(req.ReferenceID), req.CoveragePercent, req.MaxAmount,
+		time.Now().Format("2006-01-02 15:04:05")).Scan(&rule.ID, &rule.UpdatedAt)
+	return rule, err
+}
+
+func (repository *insuranceRepository) DeleteRule(ctx context.Context, payerID, ruleID string) error {
+	result, err := repository.db.ExecContext(ctx, "DELETE FROM payer_coverage_rules WHERE id = $1 AND payer_id = $2;", ruleID, payerID)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (repository *insuranceRepository) RetrieveMemberships(ctx context.Context, patientID string) ([]insuranceDto.Membership, error) {
+	query := `
+		SELECT m.id, m.patient_id, m.payer_id, p.name, m.member_number, COALESCE(m.valid_until::text, ''), m.created_at
+		FROM patient_payers m JOIN payers p ON p.id = m.payer_id
+		WHERE m.patient_id = $1 AND m.deleted_at IS NULL
+		ORDER BY p.name;`
+	rows, err := repository.db.QueryContext(ctx, query, patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []insuranceDto.Membership
+	for rows.Next() {
+		membership, err := scanMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, membership)
+	}
+	return memberships, nil
+}
+
+// RetrieveMembership returns the membership of the patient with the payer
+// when it is still valid today.
+func (repository *insuranceRepository) RetrieveMembership(ctx context.Context, patientID, payerID string) (insuranceDto.Membership, error) {
+	query := `
+		SELECT m.id, m.patient_id, m.payer_id, p.name, m.member_number, COALESCE(m.valid_until::text, ''), m.created_at
+		FROM patient_payers m JOIN payers p ON p.id = m.payer_id
+		WHERE m.patient_id = $1 AND m.payer_id = $2 AND m.deleted_at IS NULL AND p.deleted_at IS NULL
+			AND (m.valid_until IS NULL OR m.valid_until >= $3);`
+	return scanMembership(repository.db.QueryRowContext(ctx, query, patientID, payerID, time.Now().Format("2006-01-02")))
+}
+
+func (repository *insuranceRepository) InsertMembership(ctx context.Context, req insuranceDto.MembershipRequest) (string, error) {
+	query := `
+		INSERT INTO patient_payers (patient_id, payer_id, member_number, valid_until, created_at)
+		SELECT u.id, p.id, $3, $4, $5 FROM users u, payers p
+		WHERE u.id = $1 AND u.role = 'PATIENT' AND u.deleted_at IS NULL AND p.id = $2 AND p.deleted_at IS NULL
+		ON CONFLICT (patient_id, payer_id) WHERE deleted_at IS NULL
+		DO UPDATE SET member_number = EXCLUDED.member_number, valid_until = EXCLUDED.valid_until
+		RETURNING id;`
+
+	var id string
+	err := repository.db.QueryRowContext(ctx, query, req.PatientID, req.PayerID, req.MemberNumber, nullable(req.ValidUntil),
+		time.Now().Format("2006-01-02 15:04:05")).Scan(&id)
+	return id, err
+}
+
+func (repository *insuranceRepository) DeleteMembership(ctx context.Context, id string) error {
+	query := "UPDATE patient_payers SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL;"
+	result, err := repository.db.ExecContext(ctx, query, time.Now().Format("2006-01-02 15:04:05"), id)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (repository *insuranceRepository) RetrieveCoverableInvoice(ctx context.Context, invoiceID string) (insuranceDto.CoverableInvoice, error) {
+	query := `
+		SELECT i.id, i.status, b.patient_id, i.total
+		FROM invoices i
+		JOIN medical_records r ON r.id = i.medical_record_id
+		JOIN bookings b ON b.id = r.booking_id
+		WHERE i.id = $1;`
+
+	var invoice insuranceDto.CoverableInvoice
+	if err := repository.db.QueryRowContext(ctx, query, invoiceID).Scan(&invoice.ID, &invoice.Status, &invoice.PatientID, &invoice.Total); err != nil {
+		return insuranceDto.CoverableInvoice{}, err
+	}
+
+	query = `
+		SELECT id, item_type, COALESCE(reference_id::text, ''), description, quantity, unit_price, amount
+		FROM invoice_items WHERE invoice_id = $1 ORDER BY item_type, description;`
+	rows, err := repository.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return insuranceDto.CoverableInvoice{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item invoiceDto.Item
+		if err := rows.Scan(&item.ID, &item.ItemType, &item.ReferenceID, &item.Description, &item.Quantity, &item.UnitPrice, &item.Amount); err != nil {
+			return insuranceDto.CoverableInvoice{}, err
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+	return invoice, nil
+}
+
+// UpdateCoverage stores the split of a draft invoice between its payer and
+// the patient.
+func (repository *insuranceRepository) UpdateCoverage(ctx context.Context, coverage insuranceDto.Coverage) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := `
+			UPDATE invoices SET payer_id = $1, member_number = $2, covered_amount = $3, updated_at = $4
+			WHERE id = $5 AND status = $6;`
+		result, err := tx.ExecContext(ctx, query, nullable(coverage.PayerID), nullable(coverage.MemberNumber), coverage.CoveredAmount,
+			time.Now().Format("2006-01-02 15:04:05"), coverage.InvoiceID, constants.InvoiceDraft)
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return errors.New(constants.ErrInvoiceNotDraft)
+		}
+
+		query = "UPDATE invoice_items SET covered_amount = $1 WHERE id = $2 AND invoice_id = $3;"
+		for _, item := range coverage.Items {
+			if _, err := tx.ExecContext(ctx, query, item.CoveredAmount, item.ID, coverage.InvoiceID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repository *insuranceRepository) RemoveCoverage(ctx context.Context, invoiceID string) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := `
+			UPDATE invoices SET payer_id = NULL, member_number = NULL, covered_amount = 0, updated_at = $1
+			WHERE id = $2 AND status = $3;`
+		result, err := tx.ExecContext(ctx, query, time.Now().Format("2006-01-02 15:04:05"), invoiceID, constants.InvoiceDraft)
+		if err != nil {
+			return err
+		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return errors.New(constants.ErrInvoiceNotDraft)
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE invoice_items SET covered_amount = 0 WHERE invoice_id = $1;", invoiceID)
+		return err
+	})
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayer(row scanner) (insuranceDto.Payer, error) {
+	var payer insuranceDto.Payer
+	var fields string
+	err := row.Scan(&payer.ID, &payer.Code, &payer.Name, &payer.PayerType, &payer.ClaimFormat, &fields, &payer.CreatedAt, &payer.UpdatedAt)
+	if fields != "" {
+		payer.ClaimFields = strings.Split(fields, ",")
+	}
+	return payer, err
+}
+
+func scanMembership(row scanner) (insuranceDto.Membership, error) {
+	var membership insuranceDto.Membership
+	err := row.Scan(&membership.ID, &membership.PatientID, &membership.PayerID, &membership.PayerName, &membership.MemberNumber,
+		&membership.ValidUntil, &membership.CreatedAt)
+	return membership, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// nullable stores empty strings as NULL for the optional columns.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
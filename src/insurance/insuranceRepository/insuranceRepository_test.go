@@ -0,0 +1,141 @@
+package insuranceRepository
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/insurance"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type insuranceRepositoryTestSuite struct {
+	suite.Suite
+	insuranceRepo insurance.InsuranceRepository
+	mock          sqlmock.Sqlmock
+}
+
+func (suite *insuranceRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.insuranceRepo = NewInsuranceRepository(db)
+	suite.mock = mock
+}
+
+func (suite *insuranceRepositoryTestSuite) expectPendingClaims(rows *sqlmock.Rows) {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT code FROM payers`).
+		WithArgs("py1").
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).AddRow("BPJS"))
+	suite.mock.ExpectQuery(`SELECT id, claimed_amount FROM insurance_claims WHERE payer_id = \$1 AND status = \$2 FOR UPDATE`).
+		WithArgs("py1", constants.ClaimPending).
+		WillReturnRows(rows)
+}
+
+func (suite *insuranceRepositoryTestSuite) TestInsertBatch() {
+	period := time.Now().Format("200601")
+
+	suite.expectPendingClaims(sqlmock.NewRows([]string{"id", "claimed_amount"}).AddRow("c1", 145000).AddRow("c2", 90000))
+	suite.mock.ExpectQuery(`INSERT INTO claim_batch_sequences`).
+		WithArgs("py1", period).
+		WillReturnRows(sqlmock.NewRows([]string{"last_number"}).AddRow(3))
+	suite.mock.ExpectQuery(`INSERT INTO claim_batches`).
+		WithArgs(fmt.Sprintf("CLM/BPJS/%s/0003", period), "py1", constants.ClaimBatchSubmitted, 235000, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("b1"))
+	suite.mock.ExpectExec(`UPDATE insurance_claims SET batch_id = \$1, status = \$2`).
+		WithArgs("b1", constants.ClaimSubmitted, "c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE insurance_claims SET batch_id = \$1, status = \$2`).
+		WithArgs("b1", constants.ClaimSubmitted, "c2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	id, err := suite.insuranceRepo.InsertBatch(context.Background(), insuranceDto.BatchRequest{PayerID: "py1", SubmittedBy: "u1"})
+
+	suite.Nil(err)
+	suite.Equal("b1", id)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *insuranceRepositoryTestSuite) TestInsertBatchNothingPending() {
+	suite.expectPendingClaims(sqlmock.NewRows([]string{"id", "claimed_amount"}))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.insuranceRepo.InsertBatch(context.Background(), insuranceDto.BatchRequest{PayerID: "py1"})
+
+	suite.EqualError(err, constants.ErrNoClaimsToSubmit)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *insuranceRepositoryTestSuite) expectClaim(id, status string) {
+	suite.mock.ExpectQuery(`SELECT invoice_id, payment_id, status, claimed_amount FROM insurance_claims WHERE id = \$1 AND batch_id = \$2 FOR UPDATE`).
+		WithArgs(id, "b1").
+		WillReturnRows(sqlmock.NewRows([]string{"invoice_id", "payment_id", "status", "claimed_amount"}).AddRow("i1", "p1", status, 145000))
+}
+
+func (suite *insuranceRepositoryTestSuite) TestDecideClaimsApproved() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT status FROM claim_batches WHERE id = \$1 FOR UPDATE`).
+		WithArgs("b1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(constants.ClaimBatchSubmitted))
+	suite.expectClaim("c1", constants.ClaimSubmitted)
+	// Approved in full, the invoice is left alone
+	suite.mock.ExpectExec(`UPDATE insurance_claims SET status = \$1, approved_amount = \$2`).
+		WithArgs(constants.ClaimApproved, 145000, "p1", nil, "u1", sqlmock.AnyArg(), "c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery(`FROM insurance_claims WHERE batch_id = \$1`).
+		WithArgs("b1", constants.ClaimSubmitted, constants.ClaimApproved, constants.ClaimRejected).
+		WillReturnRows(sqlmock.NewRows([]string{"waiting", "approved", "rejected", "total"}).AddRow(0, 1, 1, 145000))
+	suite.mock.ExpectExec(`UPDATE claim_batches SET status = \$1`).
+		WithArgs(constants.ClaimBatchPartiallyApproved, 145000, sqlmock.AnyArg(), "b1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	err := suite.insuranceRepo.DecideClaims(context.Background(), "b1", insuranceDto.DecisionRequest{
+		Decisions: []insuranceDto.ClaimDecision{{ClaimID: "c1", Status: constants.ClaimApproved}},
+		DecidedBy: "u1",
+	})
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *insuranceRepositoryTestSuite) TestDecideClaimsAlreadyDecided() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT status FROM claim_batches WHERE id = \$1 FOR UPDATE`).
+		WithArgs("b1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(constants.ClaimBatchSubmitted))
+	suite.expectClaim("c1", constants.ClaimRejected)
+	suite.mock.ExpectRollback()
+
+	err := suite.insuranceRepo.DecideClaims(context.Background(), "b1", insuranceDto.DecisionRequest{
+		Decisions: []insuranceDto.ClaimDecision{{ClaimID: "c1", Status: constants.ClaimApproved}},
+	})
+
+	suite.EqualError(err, constants.ErrClaimNotDecidable)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *insuranceRepositoryTestSuite) TestDecideClaimsApprovedExceedsClaim() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT status FROM claim_batches WHERE id = \$1 FOR UPDATE`).
+		WithArgs("b1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(constants.ClaimBatchSubmitted))
+	suite.expectClaim("c1", constants.ClaimSubmitted)
+	suite.mock.ExpectRollback()
+
+	err := suite.insuranceRepo.DecideClaims(context.Background(), "b1", insuranceDto.DecisionRequest{
+		Decisions: []insuranceDto.ClaimDecision{{ClaimID: "c1", Status: constants.ClaimApproved, ApprovedAmount: 150000}},
+	})
+
+	suite.EqualError(err, constants.ErrApprovedExceedsClaim)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func TestInsuranceRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(insuranceRepositoryTestSuite))
+}
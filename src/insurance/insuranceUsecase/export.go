@@ -0,0 +1,92 @@
+package insuranceUsecase
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/pkg/constants"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// claimFields are the columns a payer can ask for in its claim files.
+var claimFields = map[string]func(batch insuranceDto.Batch, claim insuranceDto.Claim) interface{}{
+	"batch_number":    func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return b.BatchNumber },
+	"claim_id":        func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.ID },
+	"invoice_number":  func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.InvoiceNumber },
+	"member_number":   func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.MemberNumber },
+	"patient_name":    func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.PatientName },
+	"doctor_name":     func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.DoctorName },
+	"service_date":    func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.ServiceDate },
+	"diagnosis":       func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.Diagnosis },
+	"claimed_amount":  func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.ClaimedAmount },
+	"approved_amount": func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.ApprovedAmount },
+	"status":          func(b insuranceDto.Batch, c insuranceDto.Claim) interface{} { return c.Status },
+}
+
+// defaultClaimFields are exported for payers that did not choose their own.
+var defaultClaimFields = []string{
+	"batch_number", "claim_id", "invoice_number", "member_number", "patient_name",
+	"doctor_name", "service_date", "diagnosis", "claimed_amount",
+}
+
+// ExportBatch writes the claims of a batch in the file format and with the
+// columns configured on its payer.
+func (usecase *insuranceUsecase) ExportBatch(ctx context.Context, id string) (insuranceDto.Export, error) {
+	batch, err := usecase.insuranceRepo.RetrieveBatchByID(ctx, id)
+	if err != nil {
+		return insuranceDto.Export{}, err
+	}
+
+	payer, err := usecase.insuranceRepo.RetrievePayerByID(ctx, batch.PayerID)
+	if err != nil {
+		return insuranceDto.Export{}, err
+	}
+
+	fields := payer.ClaimFields
+	if len(fields) == 0 {
+		fields = defaultClaimFields
+	}
+
+	export := insuranceDto.Export{FileName: strings.ReplaceAll(batch.BatchNumber, "/", "-")}
+	switch payer.ClaimFormat {
+	case constants.ClaimFormatJSON:
+		records := []map[string]interface{}{}
+		for _, claim := range batch.Claims {
+			record := map[string]interface{}{}
+			for _, field := range fields {
+				record[field] = claimFields[field](batch, claim)
+			}
+			records = append(records, record)
+		}
+
+		export.FileName += ".json"
+		export.ContentType = "application/json"
+		export.Content, err = json.MarshalIndent(records, "", "  ")
+		return export, err
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(fields)
+	for _, claim := range batch.Claims {
+		record := make([]string, len(fields))
+		for i, field := range fields {
+			switch value := claimFields[field](batch, claim).(type) {
+			case int:
+				record[i] = strconv.Itoa(value)
+			default:
+				record[i] = value.(string)
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	export.FileName += ".csv"
+	export.ContentType = "text/csv"
+	export.Content = buffer.Bytes()
+	return export, writer.Error()
+}
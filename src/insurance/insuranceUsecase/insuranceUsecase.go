@@ -0,0 +1,209 @@
+package insuranceUsecase
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/insurance"
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+)
+
+type insuranceUsecase struct {
+	insuranceRepo insurance.InsuranceRepository
+}
+
+func NewInsuranceUsecase(insuranceRepo insurance.InsuranceRepository) insurance.InsuranceUsecase {
+	return &insuranceUsecase{insuranceRepo}
+}
+
+func (usecase *insuranceUsecase) GetPayers(ctx context.Context) ([]insuranceDto.Payer, error) {
+	return usecase.insuranceRepo.RetrievePayers(ctx)
+}
+
+func (usecase *insuranceUsecase) CreatePayer(ctx context.Context, req insuranceDto.PayerRequest) (insuranceDto.Payer, error) {
+	if err := normalizePayer(&req); err != nil {
+		return insuranceDto.Payer{}, err
+	}
+
+	id, err := usecase.insuranceRepo.InsertPayer(ctx, req)
+	if err != nil {
+		return insuranceDto.Payer{}, err
+	}
+	return usecase.insuranceRepo.RetrievePayerByID(ctx, id)
+}
+
+func (usecase *insuranceUsecase) UpdatePayer(ctx context.Context, req insuranceDto.PayerRequest) (insuranceDto.Payer, error) {
+	if err := normalizePayer(&req); err != nil {
+		return insuranceDto.Payer{}, err
+	}
+
+	if err := usecase.insuranceRepo.UpdatePayer(ctx, req); err != nil {
+		return insuranceDto.Payer{}, err
+	}
+	return usecase.insuranceRepo.RetrievePayerByID(ctx, req.ID)
+}
+
+func (usecase *insuranceUsecase) GetRules(ctx context.Context, payerID string) ([]insuranceDto.CoverageRule, error) {
+	if _, err := usecase.insuranceRepo.RetrievePayerByID(ctx, payerID); err != nil {
+		return nil, err
+	}
+	return usecase.insuranceRepo.RetrieveRules(ctx, payerID)
+}
+
+func (usecase *insuranceUsecase) SetRule(ctx context.Context, req insuranceDto.CoverageRuleRequest) (insuranceDto.CoverageRule, error) {
+	if _, err := usecase.insuranceRepo.RetrievePayerByID(ctx, req.PayerID); err != nil {
+		return insuranceDto.CoverageRule{}, err
+	}
+	return usecase.insuranceRepo.UpsertRule(ctx, req)
+}
+
+func (usecase *insuranceUsecase) DeleteRule(ctx context.Context, payerID, ruleID string) error {
+	return usecase.insuranceRepo.DeleteRule(ctx, payerID, ruleID)
+}
+
+func (usecase *insuranceUsecase) GetMemberships(ctx context.Context, patientID string) ([]insuranceDto.Membership, error) {
+	return usecase.insuranceRepo.RetrieveMemberships(ctx, patientID)
+}
+
+// AddMembership registers the patient with a payer, or updates the member
+// number and validity when they were already registered.
+func (usecase *insuranceUsecase) AddMembership(ctx context.Context, req insuranceDto.MembershipRequest) ([]insuranceDto.Membership, error) {
+	req.MemberNumber = strings.TrimSpace(req.MemberNumber)
+	if _, err := usecase.insuranceRepo.InsertMembership(ctx, req); err != nil {
+		return nil, err
+	}
+	return usecase.insuranceRepo.RetrieveMemberships(ctx, req.PatientID)
+}
+
+func (usecase *insuranceUsecase) RemoveMembership(ctx context.Context, id string) error {
+	return usecase.insuranceRepo.DeleteMembership(ctx, id)
+}
+
+// ApplyCoverage splits a draft invoice between the payer and the patient
+// following the coverage rules of the payer. The patient has to hold a valid
+// membership with it.
+func (usecase *insuranceUsecase) ApplyCoverage(ctx context.Context, invoiceID string, req insuranceDto.CoverageRequest) (insuranceDto.Coverage, error) {
+	invoice, err := usecase.insuranceRepo.RetrieveCoverableInvoice(ctx, invoiceID)
+	if err != nil {
+		return insuranceDto.Coverage{}, err
+	}
+
+	if invoice.Status != constants.InvoiceDraft {
+		return insuranceDto.Coverage{}, errors.New(constants.ErrInvoiceNotDraft)
+	}
+
+	membership, err := usecase.insuranceRepo.RetrieveMembership(ctx, invoice.PatientID, req.PayerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return insuranceDto.Coverage{}, errors.New(constants.ErrMembershipNotExist)
+		}
+		return insuranceDto.Coverage{}, err
+	}
+
+	rules, err := usecase.insuranceRepo.RetrieveRules(ctx, req.PayerID)
+	if err != nil {
+		return insuranceDto.Coverage{}, err
+	}
+
+	coverage := calculateCoverage(invoice, rules)
+	coverage.PayerID, coverage.MemberNumber = req.PayerID, membership.MemberNumber
+	if err := usecase.insuranceRepo.UpdateCoverage(ctx, coverage); err != nil {
+		return insuranceDto.Coverage{}, err
+	}
+	return coverage, nil
+}
+
+func (usecase *insuranceUsecase) RemoveCoverage(ctx context.Context, invoiceID string) error {
+	return usecase.insuranceRepo.RemoveCoverage(ctx, invoiceID)
+}
+
+func (usecase *insuranceUsecase) GetClaims(ctx context.Context, filter insuranceDto.ClaimFilter) ([]insuranceDto.Claim, error) {
+	filter.Status = strings.ToUpper(filter.Status)
+	return usecase.insuranceRepo.RetrieveClaims(ctx, filter)
+}
+
+func (usecase *insuranceUsecase) GetBatches(ctx context.Context) ([]insuranceDto.Batch, error) {
+	return usecase.insuranceRepo.RetrieveBatches(ctx)
+}
+
+func (usecase *insuranceUsecase) GetBatchByID(ctx context.Context, id string) (insuranceDto.Batch, error) {
+	return usecase.insuranceRepo.RetrieveBatchByID(ctx, id)
+}
+
+func (usecase *insuranceUsecase) SubmitBatch(ctx context.Context, req insuranceDto.BatchRequest) (insuranceDto.Batch, error) {
+	id, err := usecase.insuranceRepo.InsertBatch(ctx, req)
+	if err != nil {
+		return insuranceDto.Batch{}, err
+	}
+	return usecase.insuranceRepo.RetrieveBatchByID(ctx, id)
+}
+
+func (usecase *insuranceUsecase) DecideClaims(ctx context.Context, batchID string, req insuranceDto.DecisionRequest) (insuranceDto.Batch, error) {
+	for i, decision := range req.Decisions {
+		req.Decisions[i].Reason = strings.TrimSpace(decision.Reason)
+		if decision.Status == constants.ClaimRejected && req.Decisions[i].Reason == "" {
+			return insuranceDto.Batch{}, errors.New(constants.ErrRejectionReasonRequired)
+		}
+	}
+
+	if err := usecase.insuranceRepo.DecideClaims(ctx, batchID, req); err != nil {
+		return insuranceDto.Batch{}, err
+	}
+	return usecase.insuranceRepo.RetrieveBatchByID(ctx, batchID)
+}
+
+// calculateCoverage applies the rules to every item of the invoice. The rule
+// of the medicine, action or doctor itself wins over the rule of its type.
+// The payer never covers more than the invoice total.
+func calculateCoverage(invoice insuranceDto.CoverableInvoice, rules []insuranceDto.CoverageRule) insuranceDto.Coverage {
+	coverage := insuranceDto.Coverage{InvoiceID: invoice.ID, Total: invoice.Total}
+
+	for _, item := range invoice.Items {
+		var match *insuranceDto.CoverageRule
+		for i, rule := range rules {
+			if rule.ItemType != item.ItemType {
+				continue
+			}
+
+			if rule.ReferenceID == item.ReferenceID {
+				match = &rules[i]
+				break
+			}
+
+			if rule.ReferenceID == "" {
+				match = &rules[i]
+			}
+		}
+
+		if match != nil {
+			item.CoveredAmount = int(math.Round(float64(item.Amount) * match.CoveragePercent / 100))
+			if match.MaxAmount > 0 && item.CoveredAmount > match.MaxAmount {
+				item.CoveredAmount = match.MaxAmount
+			}
+		}
+
+		coverage.CoveredAmount += item.CoveredAmount
+		coverage.Items = append(coverage.Items, item)
+	}
+
+	if coverage.CoveredAmount > coverage.Total {
+		coverage.CoveredAmount = coverage.Total
+	}
+	coverage.PatientAmount = coverage.Total - coverage.CoveredAmount
+	return coverage
+}
+
+func normalizePayer(req *insuranceDto.PayerRequest) error {
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+
+	for i, field := range req.ClaimFields {
+		req.ClaimFields[i] = strings.TrimSpace(field)
+		if _, ok := claimFields[req.ClaimFields[i]]; !ok {
+			return errors.New(constants.ErrInvalidClaimField)
+		}
+	}
+	return nil
+}
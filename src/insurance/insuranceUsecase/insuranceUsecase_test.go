@@ -0,0 +1,264 @@
+package insuranceUsecase
+
+import (
+	"avengers-clinic/model/dto/insuranceDto"
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/insurance"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockInsuranceRepository struct {
+	mock.Mock
+}
+
+func (m *mockInsuranceRepository) RetrievePayers(ctx context.Context) ([]insuranceDto.Payer, error) {
+	args := m.Called()
+	return args.Get(0).([]insuranceDto.Payer), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) RetrievePayerByID(ctx context.Context, id string) (insuranceDto.Payer, error) {
+	args := m.Called(id)
+	return args.Get(0).(insuranceDto.Payer), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) InsertPayer(ctx context.Context, req insuranceDto.PayerRequest) (string, error) {
+	args := m.Called(req)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) UpdatePayer(ctx context.Context, req insuranceDto.PayerRequest) error {
+	args := m.Called(req)
+	return args.Error(0)
+}
+
+func (m *mockInsuranceRepository) RetrieveRules(ctx context.Context, payerID string) ([]insuranceDto.CoverageRule, error) {
+	args := m.Called(payerID)
+	return args.Get(0).([]insuranceDto.CoverageRule), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) UpsertRule(ctx context.Context, req insuranceDto.CoverageRuleRequest) (insuranceDto.CoverageRule, error) {
+	args := m.Called(req)
+	return args.Get(0).(insuranceDto.CoverageRule), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) DeleteRule(ctx context.Context, payerID, ruleID string) error {
+	args := m.Called(payerID, ruleID)
+	return args.Error(0)
+}
+
+func (m *mockInsuranceRepository) RetrieveMemberships(ctx context.Context, patientID string) ([]insuranceDto.Membership, error) {
+	args := m.Called(patientID)
+	return args.Get(0).([]insuranceDto.Membership), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) RetrieveMembership(ctx context.Context, patientID, payerID string) (insuranceDto.Membership, error) {
+	args := m.Called(patientID, payerID)
+	return args.Get(0).(insuranceDto.Membership), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) InsertMembership(ctx context.Context, req insuranceDto.MembershipRequest) (string, error) {
+	args := m.Called(req)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) DeleteMembership(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockInsuranceRepository) RetrieveCoverableInvoice(ctx context.Context, invoiceID string) (insuranceDto.CoverableInvoice, error) {
+	args := m.Called(invoiceID)
+	return args.Get(0).(insuranceDto.CoverableInvoice), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) UpdateCoverage(ctx context.Context, coverage insuranceDto.Coverage) error {
+	args := m.Called(coverage)
+	return args.Error(0)
+}
+
+func (m *mockInsuranceRepository) RemoveCoverage(ctx context.Context, invoiceID string) error {
+	args := m.Called(invoiceID)
+	return args.Error(0)
+}
+
+func (m *mockInsuranceRepository) RetrieveClaims(ctx context.Context, filter insuranceDto.ClaimFilter) ([]insuranceDto.Claim, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]insuranceDto.Claim), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) RetrieveBatches(ctx context.Context) ([]insuranceDto.Batch, error) {
+	args := m.Called()
+	return args.Get(0).([]insuranceDto.Batch), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) RetrieveBatchByID(ctx context.Context, id string) (insuranceDto.Batch, error) {
+	args := m.Called(id)
+	return args.Get(0).(insuranceDto.Batch), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) InsertBatch(ctx context.Context, req insuranceDto.BatchRequest) (string, error) {
+	args := m.Called(req)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockInsuranceRepository) DecideClaims(ctx context.Context, batchID string, req insuranceDto.DecisionRequest) error {
+	args := m.Called(batchID, req)
+	return args.Error(0)
+}
+
+type insuranceUsecaseTestSuite struct {
+	suite.Suite
+	repo        *mockInsuranceRepository
+	insuranceUC insurance.InsuranceUsecase
+}
+
+func (suite *insuranceUsecaseTestSuite) SetupTest() {
+	suite.repo = new(mockInsuranceRepository)
+	suite.insuranceUC = NewInsuranceUsecase(suite.repo)
+}
+
+var draft = insuranceDto.CoverableInvoice{
+	ID:        "i1",
+	Status:    constants.InvoiceDraft,
+	PatientID: "p1",
+	Total:     271950,
+	Items: []invoiceDto.Item{
+		{ID: "it1", ItemType: constants.InvoiceItemConsultation, ReferenceID: "d1", Amount: 100000},
+		{ID: "it2", ItemType: constants.InvoiceItemMedicine, ReferenceID: "m1", Amount: 50000},
+		{ID: "it3", ItemType: constants.InvoiceItemMedicine, ReferenceID: "m2", Amount: 30000},
+		{ID: "it4", ItemType: constants.InvoiceItemAction, ReferenceID: "a1", Amount: 65000},
+	},
+}
+
+func (suite *insuranceUsecaseTestSuite) TestApplyCoverage() {
+	suite.repo.On("RetrieveCoverableInvoice", "i1").Return(draft, nil)
+	suite.repo.On("RetrieveMembership", "p1", "py1").Return(insuranceDto.Membership{MemberNumber: "0001234"}, nil)
+	suite.repo.On("RetrieveRules", "py1").Return([]insuranceDto.CoverageRule{
+		{ItemType: constants.InvoiceItemConsultation, CoveragePercent: 100, MaxAmount: 75000},
+		{ItemType: constants.InvoiceItemMedicine, CoveragePercent: 80},
+		// The generic medicine is fully covered
+		{ItemType: constants.InvoiceItemMedicine, ReferenceID: "m2", CoveragePercent: 100},
+	}, nil)
+	suite.repo.On("UpdateCoverage", mock.Anything).Return(nil)
+
+	coverage, err := suite.insuranceUC.ApplyCoverage(context.Background(), "i1", insuranceDto.CoverageRequest{PayerID: "py1"})
+
+	suite.Nil(err)
+	suite.Equal(75000, coverage.Items[0].CoveredAmount)
+	suite.Equal(40000, coverage.Items[1].CoveredAmount)
+	suite.Equal(30000, coverage.Items[2].CoveredAmount)
+	suite.Equal(0, coverage.Items[3].CoveredAmount)
+	suite.Equal(145000, coverage.CoveredAmount)
+	suite.Equal(126950, coverage.PatientAmount)
+	suite.Equal("0001234", coverage.MemberNumber)
+	suite.repo.AssertCalled(suite.T(), "UpdateCoverage", coverage)
+}
+
+func (suite *insuranceUsecaseTestSuite) TestApplyCoverageCappedAtTotal() {
+	discounted := draft
+	discounted.Total = 100000
+	suite.repo.On("RetrieveCoverableInvoice", "i1").Return(discounted, nil)
+	suite.repo.On("RetrieveMembership", "p1", "py1").Return(insuranceDto.Membership{MemberNumber: "0001234"}, nil)
+	suite.repo.On("RetrieveRules", "py1").Return([]insuranceDto.CoverageRule{
+		{ItemType: constants.InvoiceItemConsultation, CoveragePercent: 100},
+		{ItemType: constants.InvoiceItemMedicine, CoveragePercent: 100},
+	}, nil)
+	suite.repo.On("UpdateCoverage", mock.Anything).Return(nil)
+
+	coverage, err := suite.insuranceUC.ApplyCoverage(context.Background(), "i1", insuranceDto.CoverageRequest{PayerID: "py1"})
+
+	suite.Nil(err)
+	suite.Equal(100000, coverage.CoveredAmount)
+	suite.Equal(0, coverage.PatientAmount)
+}
+
+func (suite *insuranceUsecaseTestSuite) TestApplyCoverageNoMembership() {
+	suite.repo.On("RetrieveCoverableInvoice", "i1").Return(draft, nil)
+	suite.repo.On("RetrieveMembership", "p1", "py1").Return(insuranceDto.Membership{}, sql.ErrNoRows)
+
+	_, err := suite.insuranceUC.ApplyCoverage(context.Background(), "i1", insuranceDto.CoverageRequest{PayerID: "py1"})
+
+	suite.EqualError(err, constants.ErrMembershipNotExist)
+	suite.repo.AssertNotCalled(suite.T(), "UpdateCoverage", mock.Anything)
+}
+
+func (suite *insuranceUsecaseTestSuite) TestApplyCoverageNotDraft() {
+	issued := draft
+	issued.Status = constants.InvoiceIssued
+	suite.repo.On("RetrieveCoverableInvoice", "i1").Return(issued, nil)
+
+	_, err := suite.insuranceUC.ApplyCoverage(context.Background(), "i1", insuranceDto.CoverageRequest{PayerID: "py1"})
+
+	suite.EqualError(err, constants.ErrInvoiceNotDraft)
+}
+
+func (suite *insuranceUsecaseTestSuite) TestCreatePayerInvalidField() {
+	_, err := suite.insuranceUC.CreatePayer(context.Background(), insuranceDto.PayerRequest{Code: "bpjs", ClaimFields: []string{"member_number", "nik"}})
+
+	suite.EqualError(err, constants.ErrInvalidClaimField)
+	suite.repo.AssertNotCalled(suite.T(), "InsertPayer", mock.Anything)
+}
+
+func (suite *insuranceUsecaseTestSuite) TestDecideClaimsRejectionReasonRequired() {
+	req := insuranceDto.DecisionRequest{Decisions: []insuranceDto.ClaimDecision{
+		{ClaimID: "c1", Status: constants.ClaimApproved},
+		{ClaimID: "c2", Status: constants.ClaimRejected, Reason: " "},
+	}}
+
+	_, err := suite.insuranceUC.DecideClaims(context.Background(), "b1", req)
+
+	suite.EqualError(err, constants.ErrRejectionReasonRequired)
+	suite.repo.AssertNotCalled(suite.T(), "DecideClaims", mock.Anything, mock.Anything)
+}
+
+var submitted = insuranceDto.Batch{
+	ID:          "b1",
+	BatchNumber: "CLM/BPJS/202610/0001",
+	PayerID:     "py1",
+	Claims: []insuranceDto.Claim{
+		{ID: "c1", InvoiceNumber: "INV/202610/00001", MemberNumber: "0001234", PatientName: "Budi, S.Kom", ClaimedAmount: 145000},
+		{ID: "c2", InvoiceNumber: "INV/202610/00002", MemberNumber: "0005678", PatientName: "Siti", ClaimedAmount: 90000},
+	},
+}
+
+func (suite *insuranceUsecaseTestSuite) TestExportBatchCSV() {
+	suite.repo.On("RetrieveBatchByID", "b1").Return(submitted, nil)
+	suite.repo.On("RetrievePayerByID", "py1").Return(insuranceDto.Payer{
+		ClaimFormat: constants.ClaimFormatCSV,
+		ClaimFields: []string{"member_number", "patient_name", "claimed_amount"},
+	}, nil)
+
+	export, err := suite.insuranceUC.ExportBatch(context.Background(), "b1")
+
+	suite.Nil(err)
+	suite.Equal("CLM-BPJS-202610-0001.csv", export.FileName)
+	suite.Equal("text/csv", export.ContentType)
+	suite.Equal("member_number,patient_name,claimed_amount\n0001234,\"Budi, S.Kom\",145000\n0005678,Siti,90000\n", string(export.Content))
+}
+
+func (suite *insuranceUsecaseTestSuite) TestExportBatchJSON() {
+	suite.repo.On("RetrieveBatchByID", "b1").Return(submitted, nil)
+	suite.repo.On("RetrievePayerByID", "py1").Return(insuranceDto.Payer{
+		ClaimFormat: constants.ClaimFormatJSON,
+		ClaimFields: []string{"batch_number", "invoice_number", "claimed_amount"},
+	}, nil)
+
+	export, err := suite.insuranceUC.ExportBatch(context.Background(), "b1")
+
+	suite.Nil(err)
+	suite.Equal("CLM-BPJS-202610-0001.json", export.FileName)
+	suite.JSONEq(`[
+		{"batch_number": "CLM/BPJS/202610/0001", "invoice_number": "INV/202610/00001", "claimed_amount": 145000},
+		{"batch_number": "CLM/BPJS/202610/0001", "invoice_number": "INV/202610/00002", "claimed_amount": 90000}
+	]`, string(export.Content))
+}
+
+func TestInsuranceUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(insuranceUsecaseTestSuite))
+}
@@ -0,0 +1,296 @@
+package inventoryDelivery
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/inventory"
+	"context"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+type inventoryDelivery struct {
+	inventoryUC inventory.InventoryUsecase
+}
+
+func NewInventoryDelivery(v1Group *gin.RouterGroup, inventoryUC inventory.InventoryUsecase) {
+	handler := inventoryDelivery{inventoryUC}
+
+	inventoryGroup := v1Group.Group("/inventory")
+	{
+		inventoryGroup.GET("/movements", middleware.JwtAuth("ADMIN"), handler.GetMovements)
+		inventoryGroup.POST("/receipts", middleware.JwtAuth("ADMIN"), handler.CreateReceipt)
+		inventoryGroup.POST("/returns", middleware.JwtAuth("ADMIN"), handler.CreateReturn)
+		inventoryGroup.POST("/adjustments", middleware.JwtAuth("ADMIN"), handler.CreateAdjustment)
+		inventoryGroup.POST("/expiry-write-offs", middleware.JwtAuth("ADMIN"), handler.WriteOffExpired)
+		inventoryGroup.GET("/reconciliation", middleware.JwtAuth("ADMIN"), handler.GetReconciliation)
+		inventoryGroup.POST("/opnames", middleware.JwtAuth("ADMIN"), handler.StartOpname)
+		inventoryGroup.GET("/opnames/:id", middleware.JwtAuth("ADMIN"), handler.GetOpname)
+		inventoryGroup.PUT("/opnames/:id/counts", middleware.JwtAuth("ADMIN"), handler.RecordCounts)
+		inventoryGroup.POST("/opnames/:id/complete", middleware.JwtAuth("ADMIN"), handler.CompleteOpname)
+		inventoryGroup.GET("/opnames/:id/variance", middleware.JwtAuth("ADMIN"), handler.GetVarianceReport)
+		inventoryGroup.PUT("/thresholds/:medicine-id", middleware.JwtAuth("ADMIN"), handler.SetThreshold)
+		inventoryGroup.GET("/low-stock", middleware.JwtAuth("ADMIN"), handler.GetLowStock)
+		inventoryGroup.GET("/reorder-suggestions", middleware.JwtAuth("ADMIN"), handler.GetReorderSuggestions)
+	}
+}
+
+func (delivery *inventoryDelivery) GetMovements(c *gin.Context) {
+	filter := inventoryDto.MovementFilter{
+		MedicineID:   c.Query("medicine_id"),
+		MovementType: c.Query("type"),
+		StartDate:    c.Query("sd"),
+		EndDate:      c.Query("ed"),
+	}
+
+	movements, err := delivery.inventoryUC.GetMovements(c.Request.Context(), filter)
+	if err != nil {
+		if err.Error() == constants.ErrDateFormat {
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "sd/ed", Message: err.Error()}}, "Bad request", constants.InventoryService, "01")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "01")
+		return
+	}
+
+	json.NewResponseSuccess(c, movements, "Stock movements retrieved successfully", constants.InventoryService, "01")
+}
+
+func (delivery *inventoryDelivery) CreateReceipt(c *gin.Context) {
+	var request inventoryDto.ReceiptRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "02")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InventoryService, "02")
+		return
+	}
+
+	request.ReceivedBy = utils.GetJWT(c).ID
+	receipt, err := delivery.inventoryUC.CreateReceipt(c.Request.Context(), request)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrDateFormat, constants.ErrBatchAlreadyExpired:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "expiry_date", Message: err.Error()}}, "Bad request", constants.InventoryService, "03")
+			return
+		case constants.ErrMedicineNotExist:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "medicine_id", Message: err.Error()}}, "Bad request", constants.InventoryService, "04")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "03")
+		return
+	}
+
+	json.NewResponseCreated(c, receipt, "Goods received successfully", constants.InventoryService, "01")
+}
+
+func (delivery *inventoryDelivery) CreateReturn(c *gin.Context) {
+	delivery.createMovement(c, delivery.inventoryUC.CreateReturn, "Return recorded successfully")
+}
+
+func (delivery *inventoryDelivery) CreateAdjustment(c *gin.Context) {
+	delivery.createMovement(c, delivery.inventoryUC.CreateAdjustment, "Adjustment recorded successfully")
+}
+
+func (delivery *inventoryDelivery) createMovement(c *gin.Context, create func(context.Context, inventoryDto.AdjustmentRequest) (inventoryDto.Movement, error), message string) {
+	var request inventoryDto.AdjustmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "04")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InventoryService, "05")
+		return
+	}
+
+	request.CreatedBy = utils.GetJWT(c).ID
+	movement, err := create(c.Request.Context(), request)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrMedicineNotExist:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "medicine_id", Message: err.Error()}}, "Bad request", constants.InventoryService, "06")
+			return
+		case constants.ErrBatchNotMatch:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "batch_id", Message: err.Error()}}, "Bad request", constants.InventoryService, "07")
+			return
+		case constants.ErrZeroQuantity, constants.ErrNegativeStock:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "quantity", Message: err.Error()}}, "Bad request", constants.InventoryService, "08")
+			return
+		case constants.ErrAdjustmentNoteRequired:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "note", Message: err.Error()}}, "Bad request", constants.InventoryService, "09")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "05")
+		return
+	}
+
+	json.NewResponseCreated(c, movement, message, constants.InventoryService, "02")
+}
+
+func (delivery *inventoryDelivery) WriteOffExpired(c *gin.Context) {
+	movements, err := delivery.inventoryUC.WriteOffExpired(c.Request.Context(), utils.GetJWT(c).ID)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "06")
+		return
+	}
+
+	json.NewResponseCreated(c, movements, "Expired batches written off successfully", constants.InventoryService, "03")
+}
+
+func (delivery *inventoryDelivery) GetReconciliation(c *gin.Context) {
+	result, err := delivery.inventoryUC.GetReconciliation(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "07")
+		return
+	}
+
+	json.NewResponseSuccess(c, result, "Stock reconciliation retrieved successfully", constants.InventoryService, "02")
+}
+
+func (delivery *inventoryDelivery) StartOpname(c *gin.Context) {
+	var request inventoryDto.OpnameRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "08")
+		return
+	}
+
+	request.StartedBy = utils.GetJWT(c).ID
+	opname, err := delivery.inventoryUC.StartOpname(c.Request.Context(), request)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "09")
+		return
+	}
+
+	json.NewResponseCreated(c, opname, "Stock opname started successfully", constants.InventoryService, "04")
+}
+
+func (delivery *inventoryDelivery) GetOpname(c *gin.Context) {
+	opname, err := delivery.inventoryUC.GetOpname(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.opnameError(c, err)
+		return
+	}
+
+	json.NewResponseSuccess(c, opname, "Stock opname retrieved successfully", constants.InventoryService, "03")
+}
+
+func (delivery *inventoryDelivery) RecordCounts(c *gin.Context) {
+	var request inventoryDto.OpnameCountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "10")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InventoryService, "10")
+		return
+	}
+
+	opname, err := delivery.inventoryUC.RecordCounts(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.opnameError(c, err)
+		return
+	}
+
+	json.NewResponseSuccess(c, opname, "Stock counts recorded successfully", constants.InventoryService, "04")
+}
+
+func (delivery *inventoryDelivery) CompleteOpname(c *gin.Context) {
+	report, err := delivery.inventoryUC.CompleteOpname(c.Request.Context(), c.Param("id"), utils.GetJWT(c).ID)
+	if err != nil {
+		delivery.opnameError(c, err)
+		return
+	}
+
+	json.NewResponseSuccess(c, report, "Stock opname completed successfully", constants.InventoryService, "05")
+}
+
+func (delivery *inventoryDelivery) GetVarianceReport(c *gin.Context) {
+	report, err := delivery.inventoryUC.GetVarianceReport(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.opnameError(c, err)
+		return
+	}
+
+	json.NewResponseSuccess(c, report, "Variance report retrieved successfully", constants.InventoryService, "06")
+}
+
+func (delivery *inventoryDelivery) SetThreshold(c *gin.Context) {
+	var request inventoryDto.ThresholdRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "12")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InventoryService, "13")
+		return
+	}
+
+	request.MedicineID = c.Param("medicine-id")
+	level, err := delivery.inventoryUC.SetThreshold(c.Request.Context(), request)
+	if err != nil {
+		if err.Error() == constants.ErrMedicineNotExist {
+			json.NewResponseNotFound(c, "Medicine not found", constants.InventoryService, "02")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "13")
+		return
+	}
+
+	json.NewResponseSuccess(c, level, "Stock threshold updated successfully", constants.InventoryService, "07")
+}
+
+func (delivery *inventoryDelivery) GetLowStock(c *gin.Context) {
+	levels, err := delivery.inventoryUC.GetLowStock(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "14")
+		return
+	}
+
+	json.NewResponseSuccess(c, levels, "Low stock medicines retrieved successfully", constants.InventoryService, "08")
+}
+
+func (delivery *inventoryDelivery) GetReorderSuggestions(c *gin.Context) {
+	suggestions, err := delivery.inventoryUC.GetReorderSuggestions(c.Request.Context(), c.Query("days"), c.Query("cover"))
+	if err != nil {
+		if err.Error() == constants.ErrInvalidDays {
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "days/cover", Message: err.Error()}}, "Bad request", constants.InventoryService, "14")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InventoryService, "15")
+		return
+	}
+
+	json.NewResponseSuccess(c, suggestions, "Reorder suggestions retrieved successfully", constants.InventoryService, "09")
+}
+
+func (delivery *inventoryDelivery) opnameError(c *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		json.NewResponseNotFound(c, "Stock opname not found", constants.InventoryService, "01")
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrOpnameNotOpen, constants.ErrOpnameNotCounted, constants.ErrNegativeStock:
+		json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.InventoryService, "11")
+		return
+	case constants.ErrOpnameItemNotExist:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "medicine_id", Message: err.Error()}}, "Bad request", constants.InventoryService, "12")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.InventoryService, "11")
+}
@@ -0,0 +1,42 @@
+package inventory
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"context"
+)
+
+type InventoryRepository interface {
+	RetrieveMovements(ctx context.Context, filter inventoryDto.MovementFilter) ([]inventoryDto.Movement, error)
+	InsertReceipt(ctx context.Context, receipt inventoryDto.Receipt) (inventoryDto.Receipt, error)
+	InsertMovement(ctx context.Context, movement inventoryDto.Movement) (inventoryDto.Movement, error)
+	WriteOffExpired(ctx context.Context, createdBy string) ([]inventoryDto.Movement, error)
+	RetrieveReconciliation(ctx context.Context) ([]inventoryDto.Reconciliation, error)
+	IsMedicineExist(ctx context.Context, medicineID string) bool
+	InsertOpname(ctx context.Context, opname inventoryDto.Opname) (string, error)
+	RetrieveOpnameByID(ctx context.Context, id string) (inventoryDto.Opname, error)
+	UpdateOpnameCounts(ctx context.Context, id string, counts []inventoryDto.CountRequest) error
+	CompleteOpname(ctx context.Context, opname inventoryDto.Opname) error
+	UpdateThreshold(ctx context.Context, req inventoryDto.ThresholdRequest) (inventoryDto.StockLevel, error)
+	RetrieveLowStock(ctx context.Context) ([]inventoryDto.StockLevel, error)
+	RetrieveStockLevels(ctx context.Context, medicineIDs []string) ([]inventoryDto.StockLevel, error)
+	RetrieveConsumption(ctx context.Context, days int) ([]inventoryDto.ReorderSuggestion, error)
+	UpdateLowStockAlert(ctx context.Context, medicineID string, alertedAt interface{}) error
+}
+
+type InventoryUsecase interface {
+	GetMovements(ctx context.Context, filter inventoryDto.MovementFilter) ([]inventoryDto.Movement, error)
+	CreateReceipt(ctx context.Context, req inventoryDto.ReceiptRequest) (inventoryDto.Receipt, error)
+	CreateReturn(ctx context.Context, req inventoryDto.AdjustmentRequest) (inventoryDto.Movement, error)
+	CreateAdjustment(ctx context.Context, req inventoryDto.AdjustmentRequest) (inventoryDto.Movement, error)
+	WriteOffExpired(ctx context.Context, createdBy string) ([]inventoryDto.Movement, error)
+	GetReconciliation(ctx context.Context) ([]inventoryDto.Reconciliation, error)
+	StartOpname(ctx context.Context, req inventoryDto.OpnameRequest) (inventoryDto.Opname, error)
+	GetOpname(ctx context.Context, id string) (inventoryDto.Opname, error)
+	RecordCounts(ctx context.Context, id string, req inventoryDto.OpnameCountRequest) (inventoryDto.Opname, error)
+	CompleteOpname(ctx context.Context, id, completedBy string) (inventoryDto.VarianceReport, error)
+	GetVarianceReport(ctx context.Context, id string) (inventoryDto.VarianceReport, error)
+	SetThreshold(ctx context.Context, req inventoryDto.ThresholdRequest) (inventoryDto.StockLevel, error)
+	GetLowStock(ctx context.Context) ([]inventoryDto.StockLevel, error)
+	GetReorderSuggestions(ctx context.Context, days, cover string) ([]inventoryDto.ReorderSuggestion, error)
+	CheckLowStock(ctx context.Context, medicineIDs []string)
+}
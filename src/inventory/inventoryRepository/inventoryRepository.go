@@ -0,0 +1,465 @@
+package inventoryRepository
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/inventory"
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+type inventoryRepository struct {
+	db *sql.DB
+}
+
+func NewInventoryRepository(db *sql.DB) inventory.InventoryRepository {
+	return &inventoryRepository{db}
+}
+
+// conn returns the transaction of the unit of work ctx belongs to, or the
+// database outside of one.
+func (repository *inventoryRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.Executor(ctx, repository.db)
+}
+
+func (repository *inventoryRepository) RetrieveMovements(ctx context.Context, filter inventoryDto.MovementFilter) ([]inventoryDto.Movement, error) {
+	query := `
+		SELECT s.id, s.medicine_id, m.name, COALESCE(s.batch_id::text, ''), s.movement_type, s.quantity, s.balance_after,
+			COALESCE(s.reference_type, ''), COALESCE(s.reference_id::text, ''), COALESCE(s.note, ''),
+			COALESCE(s.created_by::text, ''), s.created_at
+		FROM stock_movements s JOIN medicines m ON m.id = s.medicine_id
+		WHERE 1 = 1`
+
+	var args []interface{}
+	addFilter := func(condition string, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		query += " AND " + condition + " $" + strconv.Itoa(len(args))
+	}
+	addFilter("s.medicine_id =", filter.MedicineID)
+	addFilter("s.movement_type =", filter.MovementType)
+	addFilter("s.created_at::date >=", filter.StartDate)
+	addFilter("s.created_at::date <=", filter.EndDate)
+	query += " ORDER BY s.created_at, s.id;"
+
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []inventoryDto.Movement
+	for rows.Next() {
+		var movement inventoryDto.Movement
+		err := rows.Scan(
+			&movement.ID,
+			&movement.MedicineID,
+			&movement.MedicineName,
+			&movement.BatchID,
+			&movement.MovementType,
+			&movement.Quantity,
+			&movement.BalanceAfter,
+			&movement.ReferenceType,
+			&movement.ReferenceID,
+			&movement.Note,
+			&movement.CreatedBy,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+// InsertReceipt stores the goods receipt with one batch per item and posts a
+// RECEIPT movement for each of them in one transaction.
+func (repository *inventoryRepository) InsertReceipt(ctx context.Context, receipt inventoryDto.Receipt) (inventoryDto.Receipt, error) {
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := "INSERT INTO goods_receipts (receipt_number, supplier, note, received_by, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+		err := tx.QueryRowContext(ctx, query, receipt.ReceiptNumber, receipt.Supplier, nullable(receipt.Note), receipt.ReceivedBy, receipt.CreatedAt).Scan(&receipt.ID)
+		if err != nil {
+			return err
+		}
+
+		// The batch starts empty, the RECEIPT movement fills it
+		query = `
+			INSERT INTO medicine_batches (medicine_id, goods_receipt_id, lot_number, expiry_date, supplier, purchase_cost, initial_quantity, quantity, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $8) RETURNING id;
+		`
+		for i, item := range receipt.Items {
+			err := tx.QueryRowContext(ctx, query, item.MedicineID, receipt.ID, item.LotNumber, item.ExpiryDate, receipt.Supplier, item.PurchaseCost, item.InitialQuantity, receipt.CreatedAt).Scan(&receipt.Items[i].ID)
+			if err != nil {
+				return err
+			}
+
+			_, err = ApplyMovement(ctx, tx, inventoryDto.Movement{
+				MedicineID:    item.MedicineID,
+				BatchID:       receipt.Items[i].ID,
+				MovementType:  constants.MovementReceipt,
+				Quantity:      item.InitialQuantity,
+				ReferenceType: "goods_receipt",
+				ReferenceID:   receipt.ID,
+				CreatedBy:     receipt.ReceivedBy,
+				CreatedAt:     receipt.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return inventoryDto.Receipt{}, err
+	}
+	return receipt, nil
+}
+
+func (repository *inventoryRepository) InsertMovement(ctx context.Context, movement inventoryDto.Movement) (inventoryDto.Movement, error) {
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		var err error
+		movement, err = ApplyMovement(ctx, transaction.Tx(ctx), movement)
+		return err
+	})
+	if err != nil {
+		return inventoryDto.Movement{}, err
+	}
+	return movement, nil
+}
+
+// WriteOffExpired empties every expired batch that still holds stock.
+func (repository *inventoryRepository) WriteOffExpired(ctx context.Context, createdBy string) ([]inventoryDto.Movement, error) {
+	var movements []inventoryDto.Movement
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := `
+			SELECT id, medicine_id, quantity FROM medicine_batches
+			WHERE deleted_at IS NULL AND quantity > 0 AND expiry_date < CURRENT_DATE
+			ORDER BY expiry_date FOR UPDATE`
+		rows, err := tx.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		var expired []inventoryDto.Movement
+		for rows.Next() {
+			movement := inventoryDto.Movement{MovementType: constants.MovementExpiryWriteOff, CreatedBy: createdBy}
+			if err := rows.Scan(&movement.BatchID, &movement.MedicineID, &movement.Quantity); err != nil {
+				rows.Close()
+				return err
+			}
+			movement.Quantity = -movement.Quantity
+			expired = append(expired, movement)
+		}
+		rows.Close()
+
+		for _, movement := range expired {
+			movement, err := ApplyMovement(ctx, tx, movement)
+			if err != nil {
+				return err
+			}
+			movements = append(movements, movement)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+// RetrieveReconciliation compares the stock column of every medicine with the
+// stock derived from its ledger and returns only the ones that differ.
+func (repository *inventoryRepository) RetrieveReconciliation(ctx context.Context) ([]inventoryDto.Reconciliation, error) {
+	query := `
+		SELECT m.id, m.name, COALESCE(m.stock, 0), COALESCE(SUM(s.quantity), 0)
+		FROM medicines m LEFT JOIN stock_movements s ON s.medicine_id = m.id
+		WHERE m.deleted_at IS NULL
+		GROUP BY m.id, m.name, m.stock
+		HAVING COALESCE(m.stock, 0) <> COALESCE(SUM(s.quantity), 0)
+		ORDER BY m.name;
+	`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []inventoryDto.Reconciliation
+	for rows.Next() {
+		var item inventoryDto.Reconciliation
+		if err := rows.Scan(&item.MedicineID, &item.MedicineName, &item.Stock, &item.LedgerStock); err != nil {
+			return nil, err
+		}
+		item.Difference = item.Stock - item.LedgerStock
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func (repository *inventoryRepository) IsMedicineExist(ctx context.Context, medicineID string) bool {
+	count, query := 0, "SELECT COUNT(*) FROM medicines WHERE id = $1 AND deleted_at IS NULL;"
+	repository.conn(ctx).QueryRowContext(ctx, query, medicineID).Scan(&count)
+	return count > 0
+}
+
+// InsertOpname opens a stock take and snapshots the current stock of every
+// medicine as its system quantity.
+func (repository *inventoryRepository) InsertOpname(ctx context.Context, opname inventoryDto.Opname) (string, error) {
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := "INSERT INTO stock_opnames (status, note, started_by, created_at) VALUES ($1, $2, $3, $4) RETURNING id;"
+		if err := tx.QueryRowContext(ctx, query, constants.OpnameOpen, nullable(opname.Note), opname.StartedBy, opname.CreatedAt).Scan(&opname.ID); err != nil {
+			return err
+		}
+
+		// Items are valued at the price in effect when the stock take starts
+		query = `
+			INSERT INTO stock_opname_items (stock_opname_id, medicine_id, price, system_quantity)
+			SELECT $1, m.id, COALESCE(p.price, m.price), COALESCE(m.stock, 0) FROM medicines m
+			LEFT JOIN LATERAL (
+				SELECT price FROM medicine_prices WHERE medicine_id = m.id AND effective_from <= $2 ORDER BY effective_from DESC LIMIT 1
+			) p ON true
+			WHERE m.deleted_at IS NULL;
+		`
+		_, err := tx.ExecContext(ctx, query, opname.ID, opname.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return opname.ID, nil
+}
+
+func (repository *inventoryRepository) RetrieveOpnameByID(ctx context.Context, id string) (inventoryDto.Opname, error) {
+	var opname inventoryDto.Opname
+	query := `
+		SELECT id, status, COALESCE(note, ''), COALESCE(started_by::text, ''), COALESCE(completed_by::text, ''),
+			created_at, COALESCE(TO_CHAR(completed_at, 'YYYY-MM-DD HH24:MI:SS'), '')
+		FROM stock_opnames WHERE id = $1;
+	`
+	err := repository.conn(ctx).QueryRowContext(ctx, query, id).Scan(
+		&opname.ID,
+		&opname.Status,
+		&opname.Note,
+		&opname.StartedBy,
+		&opname.CompletedBy,
+		&opname.CreatedAt,
+		&opname.CompletedAt,
+	)
+	if err != nil {
+		return inventoryDto.Opname{}, err
+	}
+
+	query = `
+		SELECT i.medicine_id, m.name, i.price, i.system_quantity, i.counted_quantity
+		FROM stock_opname_items i JOIN medicines m ON m.id = i.medicine_id
+		WHERE i.stock_opname_id = $1 ORDER BY m.name;
+	`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return inventoryDto.Opname{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item inventoryDto.OpnameItem
+		var counted sql.NullInt64
+		if err := rows.Scan(&item.MedicineID, &item.MedicineName, &item.Price, &item.SystemQuantity, &counted); err != nil {
+			return inventoryDto.Opname{}, err
+		}
+
+		if counted.Valid {
+			quantity := int(counted.Int64)
+			item.CountedQuantity = &quantity
+		}
+		opname.Items = append(opname.Items, item)
+	}
+	return opname, nil
+}
+
+func (repository *inventoryRepository) UpdateOpnameCounts(ctx context.Context, id string, counts []inventoryDto.CountRequest) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := "UPDATE stock_opname_items SET counted_quantity = $1 WHERE stock_opname_id = $2 AND medicine_id = $3;"
+		for _, count := range counts {
+			result, err := tx.ExecContext(ctx, query, count.CountedQuantity, id, count.MedicineID)
+			if err != nil {
+				return err
+			}
+
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return errors.New(constants.ErrOpnameItemNotExist)
+			}
+		}
+		return nil
+	})
+}
+
+// CompleteOpname posts an OPNAME_CORRECTION movement for every counted variance
+// and closes the stock take. The status is checked again under lock so two
+// completions cannot both post corrections.
+func (repository *inventoryRepository) CompleteOpname(ctx context.Context, opname inventoryDto.Opname) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var status string
+		if err := tx.QueryRowContext(ctx, "SELECT status FROM stock_opnames WHERE id = $1 FOR UPDATE;", opname.ID).Scan(&status); err != nil {
+			return err
+		}
+
+		if status != constants.OpnameOpen {
+			return errors.New(constants.ErrOpnameNotOpen)
+		}
+
+		for _, item := range opname.Items {
+			if item.Variance == 0 {
+				continue
+			}
+
+			_, err := ApplyMovement(ctx, tx, inventoryDto.Movement{
+				MedicineID:    item.MedicineID,
+				MovementType:  constants.MovementOpnameCorrection,
+				Quantity:      item.Variance,
+				ReferenceType: "stock_opname",
+				ReferenceID:   opname.ID,
+				CreatedBy:     opname.CompletedBy,
+				CreatedAt:     opname.CompletedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		query := "UPDATE stock_opnames SET status = $1, completed_by = $2, completed_at = $3 WHERE id = $4;"
+		_, err := tx.ExecContext(ctx, query, constants.OpnameCompleted, opname.CompletedBy, opname.CompletedAt, opname.ID)
+		return err
+	})
+}
+
+func (repository *inventoryRepository) UpdateThreshold(ctx context.Context, req inventoryDto.ThresholdRequest) (inventoryDto.StockLevel, error) {
+	query := `
+		UPDATE medicines SET min_stock = $1, reorder_quantity = $2 WHERE id = $3 AND deleted_at IS NULL
+		RETURNING id, name, COALESCE(stock, 0), min_stock, reorder_quantity, low_stock_alerted_at IS NOT NULL;
+	`
+	return scanStockLevel(repository.conn(ctx).QueryRowContext(ctx, query, req.MinStock, req.ReorderQuantity, req.MedicineID))
+}
+
+// RetrieveLowStock returns the medicines with a minimum stock set whose stock
+// has fallen to or below it.
+func (repository *inventoryRepository) RetrieveLowStock(ctx context.Context) ([]inventoryDto.StockLevel, error) {
+	query := `
+		SELECT id, name, COALESCE(stock, 0), min_stock, reorder_quantity, low_stock_alerted_at IS NOT NULL
+		FROM medicines WHERE deleted_at IS NULL AND min_stock > 0 AND COALESCE(stock, 0) <= min_stock
+		ORDER BY COALESCE(stock, 0) - min_stock, name;
+	`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []inventoryDto.StockLevel
+	for rows.Next() {
+		level, err := scanStockLevel(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+func (repository *inventoryRepository) RetrieveStockLevels(ctx context.Context, medicineIDs []string) ([]inventoryDto.StockLevel, error) {
+	query := `
+		SELECT id, name, COALESCE(stock, 0), min_stock, reorder_quantity, low_stock_alerted_at IS NOT NULL
+		FROM medicines WHERE id = ANY($1) AND deleted_at IS NULL;
+	`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, pq.Array(medicineIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []inventoryDto.StockLevel
+	for rows.Next() {
+		level, err := scanStockLevel(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// RetrieveConsumption sums the quantity of every medicine on paid medical
+// records over the last given days.
+func (repository *inventoryRepository) RetrieveConsumption(ctx context.Context, days int) ([]inventoryDto.ReorderSuggestion, error) {
+	query := `
+		SELECT m.id, m.name, COALESCE(m.stock, 0), m.min_stock, m.reorder_quantity, m.low_stock_alerted_at IS NOT NULL,
+			COALESCE(SUM(d.quantity), 0)
+		FROM medicines m
+		LEFT JOIN (
+			medical_record_medicine_details d JOIN medical_records r ON r.id = d.medical_record_id
+				AND r.payment_status AND r.deleted_at IS NULL AND r.created_at >= CURRENT_DATE - $1::int
+		) ON d.medicine_id = m.id AND d.deleted_at IS NULL
+		WHERE m.deleted_at IS NULL
+		GROUP BY m.id, m.name, m.stock, m.min_stock, m.reorder_quantity, m.low_stock_alerted_at
+		ORDER BY m.name;
+	`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []inventoryDto.ReorderSuggestion
+	for rows.Next() {
+		var item inventoryDto.ReorderSuggestion
+		err := rows.Scan(
+			&item.MedicineID,
+			&item.MedicineName,
+			&item.Stock,
+			&item.MinStock,
+			&item.ReorderQuantity,
+			&item.Alerted,
+			&item.Consumed,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// UpdateLowStockAlert records when the low stock alert was sent, a nil time
+// clears it so the next drop is alerted again.
+func (repository *inventoryRepository) UpdateLowStockAlert(ctx context.Context, medicineID string, alertedAt interface{}) error {
+	query := "UPDATE medicines SET low_stock_alerted_at = $1 WHERE id = $2;"
+	_, err := repository.conn(ctx).ExecContext(ctx, query, alertedAt, medicineID)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanStockLevel(row scanner) (inventoryDto.StockLevel, error) {
+	var level inventoryDto.StockLevel
+	err := row.Scan(&level.MedicineID, &level.MedicineName, &level.Stock, &level.MinStock, &level.ReorderQuantity, &level.Alerted)
+	return level, err
+}
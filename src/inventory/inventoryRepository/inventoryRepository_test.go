@@ -0,0 +1,170 @@
+package inventoryRepository
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/inventory"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type inventoryRepositoryTestSuite struct {
+	suite.Suite
+	inventoryRepo inventory.InventoryRepository
+	mock          sqlmock.Sqlmock
+}
+
+func (suite *inventoryRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.inventoryRepo = NewInventoryRepository(db)
+	suite.mock = mock
+}
+
+func (suite *inventoryRepositoryTestSuite) TestRetrieveMovementsFilters() {
+	rows := sqlmock.NewRows([]string{"id", "medicine_id", "name", "batch_id", "movement_type", "quantity", "balance_after", "reference_type", "reference_id", "note", "created_by", "created_at"}).
+		AddRow("s1", "m1", "Paracetamol", "", "DISPENSE", -2, 8, "medical_record", "mr1", "", "u1", "2024-03-12 16:06")
+
+	suite.mock.ExpectQuery(`FROM stock_movements (.+) AND s.medicine_id = \$1 AND s.created_at::date >= \$2`).
+		WithArgs("m1", "2024-03-01").
+		WillReturnRows(rows)
+
+	actual, err := suite.inventoryRepo.RetrieveMovements(context.Background(), inventoryDto.MovementFilter{MedicineID: "m1", StartDate: "2024-03-01"})
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+	suite.Equal(-2, actual[0].Quantity)
+}
+
+func (suite *inventoryRepositoryTestSuite) TestInsertMovementNegativeStock() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("UPDATE medicines SET stock").
+		WithArgs(-5, "2024-03-12 16:06", "m1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(-3))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.inventoryRepo.InsertMovement(context.Background(), inventoryDto.Movement{
+		MedicineID:   "m1",
+		MovementType: constants.MovementAdjustment,
+		Quantity:     -5,
+		CreatedAt:    "2024-03-12 16:06",
+	})
+
+	suite.EqualError(err, constants.ErrNegativeStock)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *inventoryRepositoryTestSuite) TestInsertMovementBumpsVersion() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`UPDATE medicines SET stock = COALESCE\(stock, 0\) \+ \$1, updated_at = \$2, version = version \+ 1`).
+		WithArgs(5, "2024-03-12 16:06", "m1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(15))
+	suite.mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("m1", nil, constants.MovementReceipt, 5, 15, nil, nil, nil, nil, "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.inventoryRepo.InsertMovement(context.Background(), inventoryDto.Movement{
+		MedicineID:   "m1",
+		MovementType: constants.MovementReceipt,
+		Quantity:     5,
+		CreatedAt:    "2024-03-12 16:06",
+	})
+
+	suite.Nil(err)
+	suite.Equal(15, actual.BalanceAfter)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *inventoryRepositoryTestSuite) TestInsertMovementBatchNotMatch() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").
+		WithArgs("b1").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("m2", 10))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.inventoryRepo.InsertMovement(context.Background(), inventoryDto.Movement{MedicineID: "m1", BatchID: "b1", MovementType: constants.MovementReturn, Quantity: 1})
+
+	suite.EqualError(err, constants.ErrBatchNotMatch)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *inventoryRepositoryTestSuite) TestWriteOffExpired() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT id, medicine_id, quantity FROM medicine_batches").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medicine_id", "quantity"}).AddRow("b1", "m1", 4))
+	suite.mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").
+		WithArgs("b1").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("m1", 4))
+	suite.mock.ExpectExec("UPDATE medicine_batches SET quantity").
+		WithArgs(-4, sqlmock.AnyArg(), "b1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("UPDATE medicines SET stock").
+		WithArgs(-4, sqlmock.AnyArg(), "m1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(6))
+	suite.mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("m1", "b1", "EXPIRY_WRITE_OFF", -4, 6, nil, nil, nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.inventoryRepo.WriteOffExpired(context.Background(), "u1")
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+	suite.Equal(6, actual[0].BalanceAfter)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *inventoryRepositoryTestSuite) TestCompleteOpnameAlreadyCompleted() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT status FROM stock_opnames").
+		WithArgs("o1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(constants.OpnameCompleted))
+	suite.mock.ExpectRollback()
+
+	err := suite.inventoryRepo.CompleteOpname(context.Background(), inventoryDto.Opname{ID: "o1", Items: []inventoryDto.OpnameItem{{MedicineID: "m1", Variance: 2}}})
+
+	suite.EqualError(err, constants.ErrOpnameNotOpen)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *inventoryRepositoryTestSuite) TestRetrieveReconciliation() {
+	suite.mock.ExpectQuery("FROM medicines m LEFT JOIN stock_movements").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "ledger"}).AddRow("m1", "Paracetamol", 10, 7))
+
+	actual, err := suite.inventoryRepo.RetrieveReconciliation(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(3, actual[0].Difference)
+}
+
+func (suite *inventoryRepositoryTestSuite) TestRetrieveConsumption() {
+	suite.mock.ExpectQuery("FROM medicines m LEFT JOIN (.+) medical_record_medicine_details").
+		WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "min_stock", "reorder_quantity", "alerted", "consumed"}).
+			AddRow("m1", "Paracetamol", 10, 5, 20, false, 45))
+
+	actual, err := suite.inventoryRepo.RetrieveConsumption(context.Background(), 30)
+
+	suite.Nil(err)
+	suite.Equal(45, actual[0].Consumed)
+	suite.Equal(20, actual[0].ReorderQuantity)
+}
+
+func (suite *inventoryRepositoryTestSuite) TestUpdateThresholdNotFound() {
+	suite.mock.ExpectQuery("UPDATE medicines SET min_stock").
+		WithArgs(5, 20, "m1").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := suite.inventoryRepo.UpdateThreshold(context.Background(), inventoryDto.ThresholdRequest{MedicineID: "m1", MinStock: 5, ReorderQuantity: 20})
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func TestInventoryRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(inventoryRepositoryTestSuite))
+}
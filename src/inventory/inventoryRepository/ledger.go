@@ -0,0 +1,92 @@
+package inventoryRepository
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/pkg/constants"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ApplyMovement changes the medicine stock (and the batch quantity when a batch
+// is given) by the signed movement quantity and appends the movement to the
+// ledger. It is the only place stock should change, so callers from other
+// modules run it inside their own transaction.
+func ApplyMovement(ctx context.Context, tx *sql.Tx, movement inventoryDto.Movement) (inventoryDto.Movement, error) {
+	if movement.Quantity == 0 {
+		return inventoryDto.Movement{}, errors.New(constants.ErrZeroQuantity)
+	}
+
+	if movement.CreatedAt == "" {
+		movement.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	if movement.BatchID != "" {
+		var medicineID string
+		var quantity int
+		query := "SELECT medicine_id, quantity FROM medicine_batches WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+		if err := tx.QueryRowContext(ctx, query, movement.BatchID).Scan(&medicineID, &quantity); err != nil {
+			if err == sql.ErrNoRows {
+				return inventoryDto.Movement{}, errors.New(constants.ErrBatchNotMatch)
+			}
+			return inventoryDto.Movement{}, err
+		}
+
+		if medicineID != movement.MedicineID {
+			return inventoryDto.Movement{}, errors.New(constants.ErrBatchNotMatch)
+		}
+
+		if quantity+movement.Quantity < 0 {
+			return inventoryDto.Movement{}, errors.New(constants.ErrNegativeStock)
+		}
+
+		query = "UPDATE medicine_batches SET quantity = quantity + $1, updated_at = $2 WHERE id = $3"
+		if _, err := tx.ExecContext(ctx, query, movement.Quantity, movement.CreatedAt, movement.BatchID); err != nil {
+			return inventoryDto.Movement{}, err
+		}
+	}
+
+	// The stock is part of the medicine, so its version moves with it
+	query := "UPDATE medicines SET stock = COALESCE(stock, 0) + $1, updated_at = $2, version = version + 1 WHERE id = $3 AND deleted_at IS NULL RETURNING stock"
+	if err := tx.QueryRowContext(ctx, query, movement.Quantity, movement.CreatedAt, movement.MedicineID).Scan(&movement.BalanceAfter); err != nil {
+		if err == sql.ErrNoRows {
+			return inventoryDto.Movement{}, errors.New(constants.ErrMedicineNotExist)
+		}
+		return inventoryDto.Movement{}, err
+	}
+
+	if movement.BalanceAfter < 0 {
+		return inventoryDto.Movement{}, errors.New(constants.ErrNegativeStock)
+	}
+
+	query = `
+		INSERT INTO stock_movements (medicine_id, batch_id, movement_type, quantity, balance_after, reference_type, reference_id, note, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;
+	`
+	err := tx.QueryRowContext(ctx,
+		query,
+		movement.MedicineID,
+		nullable(movement.BatchID),
+		movement.MovementType,
+		movement.Quantity,
+		movement.BalanceAfter,
+		nullable(movement.ReferenceType),
+		nullable(movement.ReferenceID),
+		nullable(movement.Note),
+		nullable(movement.CreatedBy),
+		movement.CreatedAt,
+	).Scan(&movement.ID)
+	if err != nil {
+		return inventoryDto.Movement{}, err
+	}
+
+	return movement, nil
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
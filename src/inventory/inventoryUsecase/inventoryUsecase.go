@@ -0,0 +1,411 @@
+package inventoryUsecase
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/notifier"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/inventory"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultConsumptionDays = 30
+	defaultCoverDays       = 14
+)
+
+type inventoryUsecase struct {
+	inventoryRepo inventory.InventoryRepository
+	notifier      notifier.Notifier
+}
+
+func NewInventoryUsecase(inventoryRepo inventory.InventoryRepository, notifier notifier.Notifier) inventory.InventoryUsecase {
+	return &inventoryUsecase{inventoryRepo, notifier}
+}
+
+func (usecase *inventoryUsecase) GetMovements(ctx context.Context, filter inventoryDto.MovementFilter) ([]inventoryDto.Movement, error) {
+	var err error
+	if filter.StartDate != "" {
+		if filter.StartDate, err = utils.FormatDate(filter.StartDate); err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.EndDate != "" {
+		if filter.EndDate, err = utils.FormatDate(filter.EndDate); err != nil {
+			return nil, err
+		}
+	}
+
+	filter.MovementType = strings.ToUpper(filter.MovementType)
+	movements, err := usecase.inventoryRepo.RetrieveMovements(ctx, filter)
+	return movements, err
+}
+
+func (usecase *inventoryUsecase) CreateReceipt(ctx context.Context, req inventoryDto.ReceiptRequest) (inventoryDto.Receipt, error) {
+	receipt := inventoryDto.Receipt{
+		ReceiptNumber: strings.TrimSpace(req.ReceiptNumber),
+		Supplier:      strings.TrimSpace(req.Supplier),
+		Note:          req.Note,
+		ReceivedBy:    req.ReceivedBy,
+		CreatedAt:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	for _, item := range req.Items {
+		expiryDate, err := utils.FormatDate(item.ExpiryDate)
+		if err != nil {
+			return inventoryDto.Receipt{}, err
+		}
+
+		if expiryDate < time.Now().Format("2006-01-02") {
+			return inventoryDto.Receipt{}, errors.New(constants.ErrBatchAlreadyExpired)
+		}
+
+		if !usecase.inventoryRepo.IsMedicineExist(ctx, item.MedicineID) {
+			return inventoryDto.Receipt{}, errors.New(constants.ErrMedicineNotExist)
+		}
+
+		receipt.Items = append(receipt.Items, medicineBatchDto.Batch{
+			MedicineID:      item.MedicineID,
+			LotNumber:       strings.TrimSpace(item.LotNumber),
+			ExpiryDate:      expiryDate,
+			Supplier:        receipt.Supplier,
+			PurchaseCost:    item.PurchaseCost,
+			InitialQuantity: item.Quantity,
+			Quantity:        item.Quantity,
+			CreatedAt:       receipt.CreatedAt,
+		})
+	}
+
+	receipt, err := usecase.inventoryRepo.InsertReceipt(ctx, receipt)
+	if err != nil {
+		return inventoryDto.Receipt{}, err
+	}
+
+	var medicineIDs []string
+	for _, item := range receipt.Items {
+		medicineIDs = append(medicineIDs, item.MedicineID)
+	}
+	usecase.CheckLowStock(ctx, medicineIDs)
+	return receipt, nil
+}
+
+// CreateReturn puts returned medicine back into stock, so the quantity is
+// always positive whatever sign the client sent.
+func (usecase *inventoryUsecase) CreateReturn(ctx context.Context, req inventoryDto.AdjustmentRequest) (inventoryDto.Movement, error) {
+	if req.Quantity < 0 {
+		req.Quantity = -req.Quantity
+	}
+
+	return usecase.createMovement(ctx, constants.MovementReturn, req)
+}
+
+func (usecase *inventoryUsecase) CreateAdjustment(ctx context.Context, req inventoryDto.AdjustmentRequest) (inventoryDto.Movement, error) {
+	if strings.TrimSpace(req.Note) == "" {
+		return inventoryDto.Movement{}, errors.New(constants.ErrAdjustmentNoteRequired)
+	}
+
+	return usecase.createMovement(ctx, constants.MovementAdjustment, req)
+}
+
+func (usecase *inventoryUsecase) createMovement(ctx context.Context, movementType string, req inventoryDto.AdjustmentRequest) (inventoryDto.Movement, error) {
+	if req.Quantity == 0 {
+		return inventoryDto.Movement{}, errors.New(constants.ErrZeroQuantity)
+	}
+
+	if !usecase.inventoryRepo.IsMedicineExist(ctx, req.MedicineID) {
+		return inventoryDto.Movement{}, errors.New(constants.ErrMedicineNotExist)
+	}
+
+	movement := inventoryDto.Movement{
+		MedicineID:   req.MedicineID,
+		BatchID:      req.BatchID,
+		MovementType: movementType,
+		Quantity:     req.Quantity,
+		ReferenceID:  req.ReferenceID,
+		Note:         strings.TrimSpace(req.Note),
+		CreatedBy:    req.CreatedBy,
+		CreatedAt:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if movement.ReferenceID != "" {
+		movement.ReferenceType = "medical_record"
+	}
+
+	movement, err := usecase.inventoryRepo.InsertMovement(ctx, movement)
+	if err != nil {
+		return inventoryDto.Movement{}, err
+	}
+
+	usecase.CheckLowStock(ctx, []string{movement.MedicineID})
+	return movement, nil
+}
+
+func (usecase *inventoryUsecase) WriteOffExpired(ctx context.Context, createdBy string) ([]inventoryDto.Movement, error) {
+	movements, err := usecase.inventoryRepo.WriteOffExpired(ctx, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var medicineIDs []string
+	for _, movement := range movements {
+		medicineIDs = append(medicineIDs, movement.MedicineID)
+	}
+	usecase.CheckLowStock(ctx, medicineIDs)
+	return movements, nil
+}
+
+func (usecase *inventoryUsecase) GetReconciliation(ctx context.Context) ([]inventoryDto.Reconciliation, error) {
+	result, err := usecase.inventoryRepo.RetrieveReconciliation(ctx)
+	return result, err
+}
+
+func (usecase *inventoryUsecase) StartOpname(ctx context.Context, req inventoryDto.OpnameRequest) (inventoryDto.Opname, error) {
+	opname := inventoryDto.Opname{
+		Note:      strings.TrimSpace(req.Note),
+		StartedBy: req.StartedBy,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	id, err := usecase.inventoryRepo.InsertOpname(ctx, opname)
+	if err != nil {
+		return inventoryDto.Opname{}, err
+	}
+
+	opname, err = usecase.inventoryRepo.RetrieveOpnameByID(ctx, id)
+	return opname, err
+}
+
+func (usecase *inventoryUsecase) GetOpname(ctx context.Context, id string) (inventoryDto.Opname, error) {
+	opname, err := usecase.inventoryRepo.RetrieveOpnameByID(ctx, id)
+	if err != nil {
+		return inventoryDto.Opname{}, err
+	}
+
+	calculateVariance(&opname)
+	return opname, nil
+}
+
+func (usecase *inventoryUsecase) RecordCounts(ctx context.Context, id string, req inventoryDto.OpnameCountRequest) (inventoryDto.Opname, error) {
+	opname, err := usecase.inventoryRepo.RetrieveOpnameByID(ctx, id)
+	if err != nil {
+		return inventoryDto.Opname{}, err
+	}
+
+	if opname.Status != constants.OpnameOpen {
+		return inventoryDto.Opname{}, errors.New(constants.ErrOpnameNotOpen)
+	}
+
+	if err := usecase.inventoryRepo.UpdateOpnameCounts(ctx, id, req.Items); err != nil {
+		return inventoryDto.Opname{}, err
+	}
+
+	return usecase.GetOpname(ctx, id)
+}
+
+// CompleteOpname books the counted variances as corrections. Every item must
+// be counted first, an uncounted item would otherwise look like a zero count.
+func (usecase *inventoryUsecase) CompleteOpname(ctx context.Context, id, completedBy string) (inventoryDto.VarianceReport, error) {
+	opname, err := usecase.inventoryRepo.RetrieveOpnameByID(ctx, id)
+	if err != nil {
+		return inventoryDto.VarianceReport{}, err
+	}
+
+	if opname.Status != constants.OpnameOpen {
+		return inventoryDto.VarianceReport{}, errors.New(constants.ErrOpnameNotOpen)
+	}
+
+	for _, item := range opname.Items {
+		if item.CountedQuantity == nil {
+			return inventoryDto.VarianceReport{}, errors.New(constants.ErrOpnameNotCounted)
+		}
+	}
+
+	calculateVariance(&opname)
+	opname.CompletedBy = completedBy
+	opname.CompletedAt = time.Now().Format("2006-01-02 15:04:05")
+	if err := usecase.inventoryRepo.CompleteOpname(ctx, opname); err != nil {
+		return inventoryDto.VarianceReport{}, err
+	}
+
+	opname.Status = constants.OpnameCompleted
+	report := newVarianceReport(opname)
+
+	var medicineIDs []string
+	for _, item := range report.Items {
+		medicineIDs = append(medicineIDs, item.MedicineID)
+	}
+	usecase.CheckLowStock(ctx, medicineIDs)
+	return report, nil
+}
+
+func (usecase *inventoryUsecase) GetVarianceReport(ctx context.Context, id string) (inventoryDto.VarianceReport, error) {
+	opname, err := usecase.GetOpname(ctx, id)
+	if err != nil {
+		return inventoryDto.VarianceReport{}, err
+	}
+
+	return newVarianceReport(opname), nil
+}
+
+func (usecase *inventoryUsecase) SetThreshold(ctx context.Context, req inventoryDto.ThresholdRequest) (inventoryDto.StockLevel, error) {
+	if !usecase.inventoryRepo.IsMedicineExist(ctx, req.MedicineID) {
+		return inventoryDto.StockLevel{}, errors.New(constants.ErrMedicineNotExist)
+	}
+
+	level, err := usecase.inventoryRepo.UpdateThreshold(ctx, req)
+	if err != nil {
+		return inventoryDto.StockLevel{}, err
+	}
+
+	usecase.CheckLowStock(ctx, []string{req.MedicineID})
+	return level, nil
+}
+
+func (usecase *inventoryUsecase) GetLowStock(ctx context.Context) ([]inventoryDto.StockLevel, error) {
+	levels, err := usecase.inventoryRepo.RetrieveLowStock(ctx)
+	return levels, err
+}
+
+// GetReorderSuggestions suggests how much to order for every medicine that is
+// below its minimum stock or will run out within the cover period at the
+// average daily usage of the last days.
+func (usecase *inventoryUsecase) GetReorderSuggestions(ctx context.Context, days, cover string) ([]inventoryDto.ReorderSuggestion, error) {
+	consumptionDays, err := parseDays(days, defaultConsumptionDays)
+	if err != nil {
+		return nil, err
+	}
+
+	coverDays, err := parseDays(cover, defaultCoverDays)
+	if err != nil {
+		return nil, err
+	}
+
+	consumption, err := usecase.inventoryRepo.RetrieveConsumption(ctx, consumptionDays)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []inventoryDto.ReorderSuggestion{}
+	for _, item := range consumption {
+		average := float64(item.Consumed) / float64(consumptionDays)
+		item.AverageDailyUsage = math.Round(average*100) / 100
+
+		belowMinimum := item.MinStock > 0 && item.Stock <= item.MinStock
+		runningOut := false
+		if average > 0 {
+			daysOfCover := math.Round(float64(item.Stock)/average*10) / 10
+			item.DaysOfCover = &daysOfCover
+			runningOut = daysOfCover < float64(coverDays)
+		}
+
+		if !belowMinimum && !runningOut {
+			continue
+		}
+
+		needed := int(math.Ceil(average*float64(coverDays))) + item.MinStock - item.Stock
+		item.SuggestedQuantity = needed
+		if item.ReorderQuantity > needed {
+			item.SuggestedQuantity = item.ReorderQuantity
+		}
+
+		if item.SuggestedQuantity > 0 {
+			suggestions = append(suggestions, item)
+		}
+	}
+	return suggestions, nil
+}
+
+// CheckLowStock alerts once when a medicine falls to its minimum stock and
+// re-arms the alert once the stock is back above it. Failures are only logged,
+// the stock change that triggered the check has already been saved.
+func (usecase *inventoryUsecase) CheckLowStock(ctx context.Context, medicineIDs []string) {
+	if len(medicineIDs) == 0 {
+		return
+	}
+
+	levels, err := usecase.inventoryRepo.RetrieveStockLevels(ctx, medicineIDs)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check low stock")
+		return
+	}
+
+	for _, level := range levels {
+		below := level.MinStock > 0 && level.Stock <= level.MinStock
+		if !below {
+			if level.Alerted {
+				if err := usecase.inventoryRepo.UpdateLowStockAlert(ctx, level.MedicineID, nil); err != nil {
+					log.Error().Err(err).Str("medicine_id", level.MedicineID).Msg("failed to clear low stock alert")
+				}
+			}
+			continue
+		}
+
+		if level.Alerted {
+			continue
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		alert := notifier.Alert{
+			Type:      "LOW_STOCK",
+			Message:   fmt.Sprintf("%s stock is %d, at or below the minimum of %d", level.MedicineName, level.Stock, level.MinStock),
+			Data:      level,
+			CreatedAt: now,
+		}
+		if err := usecase.notifier.Notify(alert); err != nil {
+			log.Error().Err(err).Str("medicine_id", level.MedicineID).Msg("failed to send low stock alert")
+			continue
+		}
+
+		if err := usecase.inventoryRepo.UpdateLowStockAlert(ctx, level.MedicineID, now); err != nil {
+			log.Error().Err(err).Str("medicine_id", level.MedicineID).Msg("failed to save low stock alert")
+		}
+	}
+}
+
+func parseDays(value string, defaultDays int) (int, error) {
+	if value == "" {
+		return defaultDays, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, errors.New(constants.ErrInvalidDays)
+	}
+	return days, nil
+}
+
+func calculateVariance(opname *inventoryDto.Opname) {
+	for i, item := range opname.Items {
+		if item.CountedQuantity == nil {
+			continue
+		}
+
+		opname.Items[i].Variance = *item.CountedQuantity - item.SystemQuantity
+		opname.Items[i].VarianceValue = opname.Items[i].Variance * item.Price
+	}
+}
+
+// newVarianceReport lists only the counted items that differ from the system
+// quantity.
+func newVarianceReport(opname inventoryDto.Opname) inventoryDto.VarianceReport {
+	report := inventoryDto.VarianceReport{OpnameID: opname.ID, Status: opname.Status, Items: []inventoryDto.OpnameItem{}}
+	for _, item := range opname.Items {
+		if item.Variance == 0 {
+			continue
+		}
+
+		report.Items = append(report.Items, item)
+		report.TotalVariance += item.Variance
+		report.TotalVarianceValue += item.VarianceValue
+	}
+	return report
+}
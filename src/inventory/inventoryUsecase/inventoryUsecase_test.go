@@ -0,0 +1,276 @@
+package inventoryUsecase
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/notifier"
+	"avengers-clinic/src/inventory"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockInventoryRepository struct {
+	mock.Mock
+}
+
+func (m *mockInventoryRepository) RetrieveMovements(ctx context.Context, filter inventoryDto.MovementFilter) ([]inventoryDto.Movement, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]inventoryDto.Movement), args.Error(1)
+}
+
+func (m *mockInventoryRepository) InsertReceipt(ctx context.Context, receipt inventoryDto.Receipt) (inventoryDto.Receipt, error) {
+	args := m.Called(receipt)
+	return args.Get(0).(inventoryDto.Receipt), args.Error(1)
+}
+
+func (m *mockInventoryRepository) InsertMovement(ctx context.Context, movement inventoryDto.Movement) (inventoryDto.Movement, error) {
+	args := m.Called(movement)
+	return args.Get(0).(inventoryDto.Movement), args.Error(1)
+}
+
+func (m *mockInventoryRepository) WriteOffExpired(ctx context.Context, createdBy string) ([]inventoryDto.Movement, error) {
+	args := m.Called(createdBy)
+	return args.Get(0).([]inventoryDto.Movement), args.Error(1)
+}
+
+func (m *mockInventoryRepository) RetrieveReconciliation(ctx context.Context) ([]inventoryDto.Reconciliation, error) {
+	args := m.Called()
+	return args.Get(0).([]inventoryDto.Reconciliation), args.Error(1)
+}
+
+func (m *mockInventoryRepository) IsMedicineExist(ctx context.Context, medicineID string) bool {
+	args := m.Called(medicineID)
+	return args.Bool(0)
+}
+
+func (m *mockInventoryRepository) InsertOpname(ctx context.Context, opname inventoryDto.Opname) (string, error) {
+	args := m.Called(opname)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockInventoryRepository) RetrieveOpnameByID(ctx context.Context, id string) (inventoryDto.Opname, error) {
+	args := m.Called(id)
+	return args.Get(0).(inventoryDto.Opname), args.Error(1)
+}
+
+func (m *mockInventoryRepository) UpdateOpnameCounts(ctx context.Context, id string, counts []inventoryDto.CountRequest) error {
+	args := m.Called(id, counts)
+	return args.Error(0)
+}
+
+func (m *mockInventoryRepository) CompleteOpname(ctx context.Context, opname inventoryDto.Opname) error {
+	args := m.Called(opname)
+	return args.Error(0)
+}
+
+func (m *mockInventoryRepository) UpdateThreshold(ctx context.Context, req inventoryDto.ThresholdRequest) (inventoryDto.StockLevel, error) {
+	args := m.Called(req)
+	return args.Get(0).(inventoryDto.StockLevel), args.Error(1)
+}
+
+func (m *mockInventoryRepository) RetrieveLowStock(ctx context.Context) ([]inventoryDto.StockLevel, error) {
+	args := m.Called()
+	return args.Get(0).([]inventoryDto.StockLevel), args.Error(1)
+}
+
+func (m *mockInventoryRepository) RetrieveStockLevels(ctx context.Context, medicineIDs []string) ([]inventoryDto.StockLevel, error) {
+	args := m.Called(medicineIDs)
+	return args.Get(0).([]inventoryDto.StockLevel), args.Error(1)
+}
+
+func (m *mockInventoryRepository) RetrieveConsumption(ctx context.Context, days int) ([]inventoryDto.ReorderSuggestion, error) {
+	args := m.Called(days)
+	return args.Get(0).([]inventoryDto.ReorderSuggestion), args.Error(1)
+}
+
+func (m *mockInventoryRepository) UpdateLowStockAlert(ctx context.Context, medicineID string, alertedAt interface{}) error {
+	args := m.Called(medicineID, alertedAt)
+	return args.Error(0)
+}
+
+type mockNotifier struct {
+	mock.Mock
+}
+
+func (m *mockNotifier) Notify(alert notifier.Alert) error {
+	args := m.Called(alert)
+	return args.Error(0)
+}
+
+type inventoryUsecaseTestSuite struct {
+	suite.Suite
+	inventoryRepo *mockInventoryRepository
+	notifier      *mockNotifier
+	inventoryUC   inventory.InventoryUsecase
+}
+
+func (suite *inventoryUsecaseTestSuite) SetupTest() {
+	suite.inventoryRepo = new(mockInventoryRepository)
+	suite.notifier = new(mockNotifier)
+	suite.inventoryUC = NewInventoryUsecase(suite.inventoryRepo, suite.notifier)
+}
+
+func counted(quantity int) *int {
+	return &quantity
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCreateReceiptMedicineNotExist() {
+	suite.inventoryRepo.On("IsMedicineExist", "m1").Return(false)
+
+	_, err := suite.inventoryUC.CreateReceipt(context.Background(), inventoryDto.ReceiptRequest{
+		ReceiptNumber: "GR-1",
+		Supplier:      "PT Farma",
+		Items:         []inventoryDto.ReceiptItemRequest{{MedicineID: "m1", LotNumber: "LOT-1", ExpiryDate: "2099-01-01", Quantity: 10}},
+	})
+
+	suite.EqualError(err, constants.ErrMedicineNotExist)
+	suite.inventoryRepo.AssertNotCalled(suite.T(), "InsertReceipt", mock.Anything)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCreateReceiptSuccess() {
+	suite.inventoryRepo.On("IsMedicineExist", "m1").Return(true)
+	suite.inventoryRepo.On("InsertReceipt", mock.MatchedBy(func(receipt inventoryDto.Receipt) bool {
+		return receipt.ReceivedBy == "u1" && len(receipt.Items) == 1 && receipt.Items[0].InitialQuantity == 10
+	})).Return(inventoryDto.Receipt{ID: "r1", Items: []medicineBatchDto.Batch{{MedicineID: "m1"}}}, nil)
+	suite.inventoryRepo.On("RetrieveStockLevels", []string{"m1"}).Return([]inventoryDto.StockLevel{}, nil)
+
+	actual, err := suite.inventoryUC.CreateReceipt(context.Background(), inventoryDto.ReceiptRequest{
+		ReceiptNumber: "GR-1",
+		Supplier:      "PT Farma",
+		ReceivedBy:    "u1",
+		Items:         []inventoryDto.ReceiptItemRequest{{MedicineID: "m1", LotNumber: "LOT-1", ExpiryDate: "2099-01-01", Quantity: 10}},
+	})
+
+	suite.Nil(err)
+	suite.Equal("r1", actual.ID)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCreateReturnAlwaysAddsStock() {
+	suite.inventoryRepo.On("IsMedicineExist", "m1").Return(true)
+	suite.inventoryRepo.On("InsertMovement", mock.MatchedBy(func(movement inventoryDto.Movement) bool {
+		return movement.MovementType == constants.MovementReturn && movement.Quantity == 3 && movement.ReferenceType == "medical_record"
+	})).Return(inventoryDto.Movement{ID: "s1", MedicineID: "m1"}, nil)
+	suite.inventoryRepo.On("RetrieveStockLevels", []string{"m1"}).Return([]inventoryDto.StockLevel{}, nil)
+
+	actual, err := suite.inventoryUC.CreateReturn(context.Background(), inventoryDto.AdjustmentRequest{MedicineID: "m1", Quantity: -3, ReferenceID: "mr1"})
+
+	suite.Nil(err)
+	suite.Equal("s1", actual.ID)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCreateAdjustmentNeedsNote() {
+	_, err := suite.inventoryUC.CreateAdjustment(context.Background(), inventoryDto.AdjustmentRequest{MedicineID: "m1", Quantity: -2, Note: " "})
+
+	suite.EqualError(err, constants.ErrAdjustmentNoteRequired)
+	suite.inventoryRepo.AssertNotCalled(suite.T(), "InsertMovement", mock.Anything)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestRecordCountsOpnameClosed() {
+	suite.inventoryRepo.On("RetrieveOpnameByID", "o1").Return(inventoryDto.Opname{ID: "o1", Status: constants.OpnameCompleted}, nil)
+
+	_, err := suite.inventoryUC.RecordCounts(context.Background(), "o1", inventoryDto.OpnameCountRequest{Items: []inventoryDto.CountRequest{{MedicineID: "m1"}}})
+
+	suite.EqualError(err, constants.ErrOpnameNotOpen)
+	suite.inventoryRepo.AssertNotCalled(suite.T(), "UpdateOpnameCounts", mock.Anything, mock.Anything)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCompleteOpnameNotCounted() {
+	suite.inventoryRepo.On("RetrieveOpnameByID", "o1").Return(inventoryDto.Opname{
+		ID:     "o1",
+		Status: constants.OpnameOpen,
+		Items:  []inventoryDto.OpnameItem{{MedicineID: "m1", SystemQuantity: 10}},
+	}, nil)
+
+	_, err := suite.inventoryUC.CompleteOpname(context.Background(), "o1", "u1")
+
+	suite.EqualError(err, constants.ErrOpnameNotCounted)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCompleteOpnameVarianceReport() {
+	suite.inventoryRepo.On("RetrieveOpnameByID", "o1").Return(inventoryDto.Opname{
+		ID:     "o1",
+		Status: constants.OpnameOpen,
+		Items: []inventoryDto.OpnameItem{
+			{MedicineID: "m1", Price: 1000, SystemQuantity: 10, CountedQuantity: counted(8)},
+			{MedicineID: "m2", Price: 500, SystemQuantity: 4, CountedQuantity: counted(4)},
+			{MedicineID: "m3", Price: 200, SystemQuantity: 0, CountedQuantity: counted(5)},
+		},
+	}, nil)
+	suite.inventoryRepo.On("CompleteOpname", mock.MatchedBy(func(opname inventoryDto.Opname) bool {
+		return opname.CompletedBy == "u1" && opname.Items[0].Variance == -2 && opname.Items[2].Variance == 5
+	})).Return(nil)
+	suite.inventoryRepo.On("RetrieveStockLevels", []string{"m1", "m3"}).Return([]inventoryDto.StockLevel{}, nil)
+
+	actual, err := suite.inventoryUC.CompleteOpname(context.Background(), "o1", "u1")
+
+	suite.Nil(err)
+	suite.Equal(constants.OpnameCompleted, actual.Status)
+	suite.Len(actual.Items, 2)
+	suite.Equal(3, actual.TotalVariance)
+	suite.Equal(-1000, actual.TotalVarianceValue)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCheckLowStockAlertsOnce() {
+	suite.inventoryRepo.On("RetrieveStockLevels", []string{"m1", "m2", "m3"}).Return([]inventoryDto.StockLevel{
+		{MedicineID: "m1", MedicineName: "Paracetamol", Stock: 3, MinStock: 5},
+		{MedicineID: "m2", MedicineName: "Amoxsan", Stock: 1, MinStock: 5, Alerted: true},
+		{MedicineID: "m3", MedicineName: "Simarc", Stock: 20, MinStock: 5, Alerted: true},
+	}, nil)
+	suite.notifier.On("Notify", mock.MatchedBy(func(alert notifier.Alert) bool {
+		return alert.Type == "LOW_STOCK" && alert.Data.(inventoryDto.StockLevel).MedicineID == "m1"
+	})).Return(nil).Once()
+	suite.inventoryRepo.On("UpdateLowStockAlert", "m3", nil).Return(nil)
+	suite.inventoryRepo.On("UpdateLowStockAlert", "m1", mock.AnythingOfType("string")).Return(nil)
+
+	suite.inventoryUC.CheckLowStock(context.Background(), []string{"m1", "m2", "m3"})
+
+	suite.notifier.AssertNumberOfCalls(suite.T(), "Notify", 1)
+	suite.inventoryRepo.AssertExpectations(suite.T())
+}
+
+func (suite *inventoryUsecaseTestSuite) TestCheckLowStockNotifierFails() {
+	suite.inventoryRepo.On("RetrieveStockLevels", []string{"m1"}).Return([]inventoryDto.StockLevel{{MedicineID: "m1", Stock: 0, MinStock: 5}}, nil)
+	suite.notifier.On("Notify", mock.Anything).Return(errors.New("webhook down"))
+
+	suite.inventoryUC.CheckLowStock(context.Background(), []string{"m1"})
+
+	suite.inventoryRepo.AssertNotCalled(suite.T(), "UpdateLowStockAlert", mock.Anything, mock.Anything)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestGetReorderSuggestions() {
+	suite.inventoryRepo.On("RetrieveConsumption", 10).Return([]inventoryDto.ReorderSuggestion{
+		// 3 a day, 5 days left
+		{StockLevel: inventoryDto.StockLevel{MedicineID: "m1", Stock: 15, MinStock: 5, ReorderQuantity: 10}, Consumed: 30},
+		// below minimum and never dispensed
+		{StockLevel: inventoryDto.StockLevel{MedicineID: "m2", Stock: 2, MinStock: 5, ReorderQuantity: 20}},
+		// plenty left
+		{StockLevel: inventoryDto.StockLevel{MedicineID: "m3", Stock: 100, MinStock: 5}, Consumed: 10},
+	}, nil)
+
+	actual, err := suite.inventoryUC.GetReorderSuggestions(context.Background(), "10", "7")
+
+	suite.Nil(err)
+	suite.Len(actual, 2)
+	suite.Equal("m1", actual[0].MedicineID)
+	suite.Equal(3.0, actual[0].AverageDailyUsage)
+	suite.Equal(5.0, *actual[0].DaysOfCover)
+	suite.Equal(11, actual[0].SuggestedQuantity)
+	suite.Equal("m2", actual[1].MedicineID)
+	suite.Nil(actual[1].DaysOfCover)
+	suite.Equal(20, actual[1].SuggestedQuantity)
+}
+
+func (suite *inventoryUsecaseTestSuite) TestGetReorderSuggestionsInvalidDays() {
+	_, err := suite.inventoryUC.GetReorderSuggestions(context.Background(), "0", "")
+
+	suite.EqualError(err, constants.ErrInvalidDays)
+}
+
+func TestInventoryUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(inventoryUsecaseTestSuite))
+}
@@ -0,0 +1,295 @@
+package invoiceDelivery
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/invoice"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+type invoiceDelivery struct {
+	invoiceUC invoice.InvoiceUsecase
+}
+
+func NewInvoiceDelivery(v1Group *gin.RouterGroup, invoiceUC invoice.InvoiceUsecase) {
+	handler := invoiceDelivery{invoiceUC}
+
+	invoiceGroup := v1Group.Group("/invoices")
+	{
+		invoiceGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetInvoices)
+		invoiceGroup.GET("/:id", middleware.JwtAuth("ADMIN"), handler.GetInvoiceByID)
+		invoiceGroup.POST("", middleware.JwtAuth("ADMIN"), handler.CreateInvoice)
+		invoiceGroup.PUT("/:id/discount", middleware.JwtAuth("ADMIN"), handler.SetDiscount)
+		invoiceGroup.POST("/:id/issue", middleware.JwtAuth("ADMIN"), handler.IssueInvoice)
+		invoiceGroup.POST("/:id/void", middleware.JwtAuth("ADMIN"), handler.VoidInvoice)
+		invoiceGroup.POST("/:id/payments", middleware.JwtAuth("ADMIN"), handler.PayInvoice)
+		invoiceGroup.POST("/:id/payments/:payment-id/void", middleware.JwtAuth("ADMIN"), handler.VoidPayment)
+		invoiceGroup.POST("/:id/refunds", middleware.JwtAuth("ADMIN"), handler.RefundInvoice)
+		invoiceGroup.GET("/:id/events", middleware.JwtAuth("ADMIN"), handler.GetEvents)
+	}
+
+	feeGroup := v1Group.Group("/consultation-fees")
+	{
+		feeGroup.GET("", middleware.JwtAuth("ADMIN"), handler.GetConsultationFees)
+		feeGroup.PUT("/:doctor-id", middleware.JwtAuth("ADMIN"), handler.SetConsultationFee)
+	}
+}
+
+func (delivery *invoiceDelivery) GetInvoices(c *gin.Context) {
+	filter := invoiceDto.InvoiceFilter{
+		Status:          c.Query("status"),
+		MedicalRecordID: c.Query("medical_record_id"),
+	}
+
+	invoices, err := delivery.invoiceUC.GetInvoices(c.Request.Context(), filter)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "01")
+		return
+	}
+
+	json.NewResponseSuccess(c, invoices, "Invoices retrieved successfully", constants.InvoiceService, "01")
+}
+
+func (delivery *invoiceDelivery) GetInvoiceByID(c *gin.Context) {
+	invoice, err := delivery.invoiceUC.GetInvoiceByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.invoiceError(c, err, "02")
+		return
+	}
+
+	json.NewResponseSuccess(c, invoice, "Invoice retrieved successfully", constants.InvoiceService, "02")
+}
+
+func (delivery *invoiceDelivery) CreateInvoice(c *gin.Context) {
+	var request invoiceDto.CreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "03")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "01")
+		return
+	}
+
+	request.CreatedBy = utils.GetJWT(c).ID
+	invoice, err := delivery.invoiceUC.CreateInvoice(c.Request.Context(), request)
+	if err != nil {
+		delivery.invoiceError(c, err, "04")
+		return
+	}
+
+	json.NewResponseCreated(c, invoice, "Invoice created successfully", constants.InvoiceService, "01")
+}
+
+func (delivery *invoiceDelivery) SetDiscount(c *gin.Context) {
+	var request invoiceDto.DiscountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "05")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "02")
+		return
+	}
+
+	invoice, err := delivery.invoiceUC.SetDiscount(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.invoiceError(c, err, "06")
+		return
+	}
+
+	json.NewResponseSuccess(c, invoice, "Discount updated successfully", constants.InvoiceService, "03")
+}
+
+func (delivery *invoiceDelivery) IssueInvoice(c *gin.Context) {
+	invoice, err := delivery.invoiceUC.IssueInvoice(c.Request.Context(), c.Param("id"), utils.GetJWT(c).ID)
+	if err != nil {
+		delivery.invoiceError(c, err, "07")
+		return
+	}
+
+	json.NewResponseSuccess(c, invoice, "Invoice issued successfully", constants.InvoiceService, "04")
+}
+
+func (delivery *invoiceDelivery) VoidInvoice(c *gin.Context) {
+	var request invoiceDto.VoidRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "09")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "03")
+		return
+	}
+
+	request.VoidedBy = utils.GetJWT(c).ID
+	invoice, err := delivery.invoiceUC.VoidInvoice(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.invoiceError(c, err, "10")
+		return
+	}
+
+	json.NewResponseSuccess(c, invoice, "Invoice voided successfully", constants.InvoiceService, "06")
+}
+
+func (delivery *invoiceDelivery) PayInvoice(c *gin.Context) {
+	var request invoiceDto.PaymentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "08")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "08")
+		return
+	}
+
+	request.CashierID = utils.GetJWT(c).ID
+	invoice, err := delivery.invoiceUC.PayInvoice(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.invoiceError(c, err, "14")
+		return
+	}
+
+	json.NewResponseCreated(c, invoice, "Payment recorded successfully", constants.InvoiceService, "02")
+}
+
+func (delivery *invoiceDelivery) VoidPayment(c *gin.Context) {
+	var request invoiceDto.VoidRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "15")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "09")
+		return
+	}
+
+	request.VoidedBy = utils.GetJWT(c).ID
+	invoice, err := delivery.invoiceUC.VoidPayment(c.Request.Context(), c.Param("id"), c.Param("payment-id"), request)
+	if err != nil {
+		delivery.invoiceError(c, err, "16")
+		return
+	}
+
+	json.NewResponseSuccess(c, invoice, "Payment voided successfully", constants.InvoiceService, "05")
+}
+
+func (delivery *invoiceDelivery) RefundInvoice(c *gin.Context) {
+	var request invoiceDto.RefundRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "17")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "10")
+		return
+	}
+
+	request.CreatedBy = utils.GetJWT(c).ID
+	invoice, err := delivery.invoiceUC.RefundInvoice(c.Request.Context(), c.Param("id"), request)
+	if err != nil {
+		delivery.invoiceError(c, err, "18")
+		return
+	}
+
+	json.NewResponseCreated(c, invoice, "Refund recorded successfully", constants.InvoiceService, "03")
+}
+
+func (delivery *invoiceDelivery) GetEvents(c *gin.Context) {
+	events, err := delivery.invoiceUC.GetEvents(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		delivery.invoiceError(c, err, "19")
+		return
+	}
+
+	json.NewResponseSuccess(c, events, "Invoice events retrieved successfully", constants.InvoiceService, "09")
+}
+
+func (delivery *invoiceDelivery) GetConsultationFees(c *gin.Context) {
+	fees, err := delivery.invoiceUC.GetConsultationFees(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "11")
+		return
+	}
+
+	json.NewResponseSuccess(c, fees, "Consultation fees retrieved successfully", constants.InvoiceService, "07")
+}
+
+func (delivery *invoiceDelivery) SetConsultationFee(c *gin.Context) {
+	var request invoiceDto.ConsultationFeeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "12")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.InvoiceService, "04")
+		return
+	}
+
+	request.DoctorID = c.Param("doctor-id")
+	request.UpdatedBy = utils.GetJWT(c).ID
+	fee, err := delivery.invoiceUC.SetConsultationFee(c.Request.Context(), request)
+	if err != nil {
+		if err.Error() == constants.ErrDoctorNotExist {
+			json.NewResponseNotFound(c, "Doctor not found", constants.InvoiceService, "02")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.InvoiceService, "13")
+		return
+	}
+
+	json.NewResponseSuccess(c, fee, "Consultation fee updated successfully", constants.InvoiceService, "08")
+}
+
+// invoiceError maps the errors shared by the invoice endpoints, anything else
+// is reported as an internal error with the given code.
+func (delivery *invoiceDelivery) invoiceError(c *gin.Context, err error, errorCode string) {
+	if err == sql.ErrNoRows {
+		json.NewResponseNotFound(c, "Invoice not found", constants.InvoiceService, "01")
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrPaymentNotExist:
+		json.NewResponseNotFound(c, "Payment not found", constants.InvoiceService, "03")
+		return
+	case constants.ErrMedicalRecordNotExist:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "medical_record_id", Message: err.Error()}}, "Bad request", constants.InvoiceService, "05")
+		return
+	case constants.ErrInvalidDiscount:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "discount", Message: err.Error()}}, "Bad request", constants.InvoiceService, "06")
+		return
+	case constants.ErrPaymentExceedsBalance, constants.ErrRefundExceedsPaid:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "amount", Message: err.Error()}}, "Bad request", constants.InvoiceService, "11")
+		return
+	case constants.ErrInsufficientTender:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "tendered_amount", Message: err.Error()}}, "Bad request", constants.InvoiceService, "12")
+		return
+	case constants.ErrPaymentReferenceRequired:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "reference", Message: err.Error()}}, "Bad request", constants.InvoiceService, "13")
+		return
+	case constants.ErrMedicalRecordAlreadyPaid:
+		json.NewResponseConflict(c, err.Error(), constants.InvoiceService, "01")
+		return
+	case constants.ErrInvoiceAlreadyExist, constants.ErrInvoiceNotDraft, constants.ErrInvoiceNotPayable, constants.ErrInvoiceNotVoidable,
+		constants.ErrPaymentNotVoidable, constants.ErrPaymentClaimSubmitted, constants.ErrInvoiceNotRefundable,
+		constants.ErrNoStockAvailable, constants.ErrQuantityGreaterThanStock, constants.ErrExpiredStock:
+		json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.InvoiceService, "07")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.InvoiceService, errorCode)
+}
@@ -0,0 +1,40 @@
+package invoice
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"context"
+)
+
+type InvoiceRepository interface {
+	RetrieveInvoices(ctx context.Context, filter invoiceDto.InvoiceFilter) ([]invoiceDto.Invoice, error)
+	RetrieveInvoiceByID(ctx context.Context, id string) (invoiceDto.Invoice, error)
+	RetrieveBillableItems(ctx context.Context, medicalRecordID string) ([]invoiceDto.Item, error)
+	RetrieveConsultationFee(ctx context.Context, medicalRecordID string, defaultFee int) (invoiceDto.ConsultationFee, error)
+	IsMedicalRecordExist(ctx context.Context, medicalRecordID string) (bool, error)
+	IsInvoiced(ctx context.Context, medicalRecordID string) (bool, error)
+	InsertInvoice(ctx context.Context, invoice invoiceDto.Invoice) (string, error)
+	UpdateDiscount(ctx context.Context, invoice invoiceDto.Invoice) error
+	IssueInvoice(ctx context.Context, id, issuedBy string) error
+	InsertPayments(ctx context.Context, id string, payments []invoiceDto.Payment) error
+	VoidPayment(ctx context.Context, id, paymentID string, req invoiceDto.VoidRequest, reservedUntil string) error
+	InsertRefund(ctx context.Context, id string, refund invoiceDto.Refund, reservedUntil string) error
+	RetrieveEvents(ctx context.Context, id string) ([]invoiceDto.Event, error)
+	VoidInvoice(ctx context.Context, id string, req invoiceDto.VoidRequest) error
+	RetrieveConsultationFees(ctx context.Context, defaultFee int) ([]invoiceDto.ConsultationFee, error)
+	UpsertConsultationFee(ctx context.Context, req invoiceDto.ConsultationFeeRequest) (invoiceDto.ConsultationFee, error)
+}
+
+type InvoiceUsecase interface {
+	GetInvoices(ctx context.Context, filter invoiceDto.InvoiceFilter) ([]invoiceDto.Invoice, error)
+	GetInvoiceByID(ctx context.Context, id string) (invoiceDto.Invoice, error)
+	CreateInvoice(ctx context.Context, req invoiceDto.CreateRequest) (invoiceDto.Invoice, error)
+	SetDiscount(ctx context.Context, id string, req invoiceDto.DiscountRequest) (invoiceDto.Invoice, error)
+	IssueInvoice(ctx context.Context, id, issuedBy string) (invoiceDto.Invoice, error)
+	PayInvoice(ctx context.Context, id string, req invoiceDto.PaymentRequest) (invoiceDto.Invoice, error)
+	VoidPayment(ctx context.Context, id, paymentID string, req invoiceDto.VoidRequest) (invoiceDto.Invoice, error)
+	RefundInvoice(ctx context.Context, id string, req invoiceDto.RefundRequest) (invoiceDto.Invoice, error)
+	GetEvents(ctx context.Context, id string) ([]invoiceDto.Event, error)
+	VoidInvoice(ctx context.Context, id string, req invoiceDto.VoidRequest) (invoiceDto.Invoice, error)
+	GetConsultationFees(ctx context.Context) ([]invoiceDto.ConsultationFee, error)
+	SetConsultationFee(ctx context.Context, req invoiceDto.ConsultationFeeRequest) (invoiceDto.ConsultationFee, error)
+}
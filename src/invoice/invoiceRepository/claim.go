@@ -0,0 +1,116 @@
+package invoiceRepository
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// cancelClaim drops the claim of an insurance payment being voided. Once the
+// claim has been sent to the payer the payment has to stay until the payer
+// decides on it.
+func cancelClaim(ctx context.Context, tx *sql.Tx, id, paymentID, now string) error {
+	var claimID, status string
+	query := "SELECT id, status FROM insurance_claims WHERE payment_id = $1 AND status <> $2 FOR UPDATE;"
+	err := tx.QueryRowContext(ctx, query, paymentID, constants.ClaimCanceled).Scan(&claimID, &status)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if status != constants.ClaimPending {
+		return errors.New(constants.ErrPaymentClaimSubmitted)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE insurance_claims SET status = $1, decided_at = $2 WHERE id = $3;", constants.ClaimCanceled, now, claimID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE invoices SET covered_amount = 0 WHERE id = $1;", id)
+	return err
+}
+
+// ReduceCoverage moves the part of a claim the payer did not approve to the
+// patient: the insurance payment is voided and the approved amount, if any, is
+// booked again. The medicines have already left with the patient, so unlike a
+// voided payment nothing goes back into stock. It returns the id of the new
+// insurance payment.
+func ReduceCoverage(ctx context.Context, tx *sql.Tx, id, paymentID string, approved int, note, userID string) (string, error) {
+	before, err := lockCollected(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+
+	var amount int
+	var method, status string
+	query := "SELECT amount, method, status FROM invoice_payments WHERE id = $1 AND invoice_id = $2 FOR UPDATE;"
+	if err := tx.QueryRowContext(ctx, query, paymentID, id).Scan(&amount, &method, &status); err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New(constants.ErrPaymentNotExist)
+		}
+		return "", err
+	}
+
+	if method != constants.PaymentInsurance || status != constants.PaymentCompleted || amount > before.paidAmount {
+		return "", errors.New(constants.ErrPaymentNotVoidable)
+	}
+
+	if approved > amount {
+		return "", errors.New(constants.ErrApprovedExceedsClaim)
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	query = "UPDATE invoice_payments SET status = $1, void_reason = $2, voided_by = $3, voided_at = $4 WHERE id = $5;"
+	if _, err := tx.ExecContext(ctx, query, constants.PaymentVoid, note, nullable(userID), now, paymentID); err != nil {
+		return "", err
+	}
+
+	after := before
+	after.paidAmount -= amount
+	event := invoiceDto.Event{
+		EventType: constants.InvoiceEventPaymentVoid,
+		PaymentID: paymentID,
+		Amount:    -amount,
+		Note:      note,
+		CreatedBy: userID,
+	}
+	if err := insertEvent(ctx, tx, id, after, event, now); err != nil {
+		return "", err
+	}
+
+	var newPaymentID string
+	if approved > 0 {
+		after.paidAmount += approved
+
+		query = `
+			INSERT INTO invoice_payments (invoice_id, method, amount, tendered_amount, change_amount, reference, cashier_id, created_at)
+			SELECT invoice_id, method, $2, $2, 0, reference, $3, $4 FROM invoice_payments WHERE id = $1 RETURNING id;`
+		if err := tx.QueryRowContext(ctx, query, paymentID, approved, nullable(userID), now).Scan(&newPaymentID); err != nil {
+			return "", err
+		}
+
+		event := invoiceDto.Event{
+			EventType: constants.InvoiceEventPayment,
+			PaymentID: newPaymentID,
+			Amount:    approved,
+			CreatedBy: userID,
+		}
+		if err := insertEvent(ctx, tx, id, after, event, now); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE invoices SET covered_amount = $1 WHERE id = $2;", approved, id); err != nil {
+		return "", err
+	}
+
+	if err := updateAmounts(ctx, tx, id, after, now); err != nil {
+		return "", err
+	}
+	return newPaymentID, nil
+}
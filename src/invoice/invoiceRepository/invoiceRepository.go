@@ -0,0 +1,404 @@
+package invoiceRepository
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/invoice"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const invoiceColumns = `
+	id, COALESCE(invoice_number, ''), medical_record_id, status, subtotal, discount_percent, discount_amount,
+	tax_rate, tax_amount, total, paid_amount, refunded_amount, COALESCE(note, ''), COALESCE(created_by::text, ''),
+	COALESCE(issued_by::text, ''), COALESCE(voided_by::text, ''), COALESCE(void_reason, ''), created_at,
+	COALESCE(updated_at::text, ''), COALESCE(issued_at::text, ''), COALESCE(paid_at::text, ''), COALESCE(voided_at::text, ''),
+	COALESCE(payer_id::text, ''), COALESCE(member_number, ''), covered_amount`
+
+type invoiceRepository struct {
+	db *sql.DB
+}
+
+func NewInvoiceRepository(db *sql.DB) invoice.InvoiceRepository {
+	return &invoiceRepository{db}
+}
+
+// conn returns the transaction of the unit of work ctx belongs to, or the
+// database outside of one.
+func (repository *invoiceRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.Executor(ctx, repository.db)
+}
+
+func (repository *invoiceRepository) RetrieveInvoices(ctx context.Context, filter invoiceDto.InvoiceFilter) ([]invoiceDto.Invoice, error) {
+	query := "SELECT " + invoiceColumns + " FROM invoices WHERE 1 = 1"
+
+	var args []interface{}
+	addFilter := func(condition string, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		query += " AND " + condition + " $" + strconv.Itoa(len(args))
+	}
+	addFilter("status =", filter.Status)
+	addFilter("medical_record_id =", filter.MedicalRecordID)
+	query += " ORDER BY created_at DESC;"
+
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []invoiceDto.Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, nil
+}
+
+func (repository *invoiceRepository) RetrieveInvoiceByID(ctx context.Context, id string) (invoiceDto.Invoice, error) {
+	query := "SELECT " + invoiceColumns + " FROM invoices WHERE id = $1;"
+	invoice, err := scanInvoice(repository.conn(ctx).QueryRowContext(ctx, query, id))
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	query = `
+		SELECT id, item_type, COALESCE(reference_id::text, ''), description, quantity, unit_price, amount, covered_amount
+		FROM invoice_items WHERE invoice_id = $1 ORDER BY item_type, description;`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item invoiceDto.Item
+		err := rows.Scan(&item.ID, &item.ItemType, &item.ReferenceID, &item.Description, &item.Quantity, &item.UnitPrice, &item.Amount, &item.CoveredAmount)
+		if err != nil {
+			return invoiceDto.Invoice{}, err
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+
+	if invoice.Payments, err = repository.retrievePayments(ctx, id); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	if invoice.Refunds, err = repository.retrieveRefunds(ctx, id); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return invoice, nil
+}
+
+// RetrieveBillableItems returns the medicines and actions of a medical record
+// at the prices they were prescribed for.
+func (repository *invoiceRepository) RetrieveBillableItems(ctx context.Context, medicalRecordID string) ([]invoiceDto.Item, error) {
+	query := `
+		SELECT 'MEDICINE', d.medicine_id, m.name, d.quantity, COALESCE(d.medicine_price, 0)
+		FROM medical_record_medicine_details d JOIN medicines m ON m.id = d.medicine_id
+		WHERE d.medical_record_id = $1 AND d.deleted_at IS NULL
+		UNION ALL
+		SELECT 'ACTION', d.action_id, a.name, 1, COALESCE(d.action_price, 0)
+		FROM medical_record_action_details d JOIN actions a ON a.id = d.action_id
+		WHERE d.medical_record_id = $1 AND d.deleted_at IS NULL;`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, medicalRecordID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []invoiceDto.Item
+	for rows.Next() {
+		var item invoiceDto.Item
+		if err := rows.Scan(&item.ItemType, &item.ReferenceID, &item.Description, &item.Quantity, &item.UnitPrice); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// RetrieveConsultationFee returns the doctor who handled the booking of the
+// medical record, falling back to the default fee when none was set.
+func (repository *invoiceRepository) RetrieveConsultationFee(ctx context.Context, medicalRecordID string, defaultFee int) (invoiceDto.ConsultationFee, error) {
+	query := `
+		SELECT u.id, u.username, COALESCE(f.fee, $2)
+		FROM medical_records r
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users u ON u.id = s.doctor_id
+		LEFT JOIN consultation_fees f ON f.doctor_id = u.id
+		WHERE r.id = $1;`
+
+	var fee invoiceDto.ConsultationFee
+	err := repository.conn(ctx).QueryRowContext(ctx, query, medicalRecordID, defaultFee).Scan(&fee.DoctorID, &fee.DoctorName, &fee.Fee)
+	return fee, err
+}
+
+func (repository *invoiceRepository) IsMedicalRecordExist(ctx context.Context, medicalRecordID string) (bool, error) {
+	count, query := 0, "SELECT COUNT(*) FROM medical_records WHERE id = $1 AND deleted_at IS NULL;"
+	err := repository.conn(ctx).QueryRowContext(ctx, query, medicalRecordID).Scan(&count)
+	return count > 0, err
+}
+
+func (repository *invoiceRepository) IsInvoiced(ctx context.Context, medicalRecordID string) (bool, error) {
+	count, query := 0, "SELECT COUNT(*) FROM invoices WHERE medical_record_id = $1 AND status <> $2;"
+	err := repository.conn(ctx).QueryRowContext(ctx, query, medicalRecordID, constants.InvoiceVoid).Scan(&count)
+	return count > 0, err
+}
+
+func (repository *invoiceRepository) InsertInvoice(ctx context.Context, invoice invoiceDto.Invoice) (string, error) {
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		// The record stays locked, so it cannot be marked paid without the invoice meanwhile
+		var paid bool
+		query := "SELECT payment_status FROM medical_records WHERE id = $1 FOR UPDATE;"
+		if err := tx.QueryRowContext(ctx, query, invoice.MedicalRecordID).Scan(&paid); err != nil {
+			return err
+		}
+		if paid {
+			return errors.New(constants.ErrMedicalRecordAlreadyPaid)
+		}
+
+		query = `
+			INSERT INTO invoices (medical_record_id, status, subtotal, discount_percent, discount_amount, tax_rate, tax_amount, total, note, created_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id;`
+		err := tx.QueryRowContext(ctx, query, invoice.MedicalRecordID, invoice.Status, invoice.Subtotal, invoice.DiscountPercent, invoice.DiscountAmount,
+			invoice.TaxRate, invoice.TaxAmount, invoice.Total, invoice.Note, nullable(invoice.CreatedBy), invoice.CreatedAt).Scan(&invoice.ID)
+		if err != nil {
+			// Another invoice was made for the record at the same time
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return errors.New(constants.ErrInvoiceAlreadyExist)
+			}
+			return err
+		}
+
+		query = "INSERT INTO invoice_items (invoice_id, item_type, reference_id, description, quantity, unit_price, amount) VALUES ($1, $2, $3, $4, $5, $6, $7);"
+		for _, item := range invoice.Items {
+			if _, err := tx.ExecContext(ctx, query, invoice.ID, item.ItemType, nullable(item.ReferenceID), item.Description, item.Quantity, item.UnitPrice, item.Amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return invoice.ID, nil
+}
+
+func (repository *invoiceRepository) UpdateDiscount(ctx context.Context, invoice invoiceDto.Invoice) error {
+	query := `
+		UPDATE invoices SET discount_percent = $1, discount_amount = $2, tax_amount = $3, total = $4, note = $5, updated_at = $6
+		WHERE id = $7 AND status = $8;`
+	result, err := repository.conn(ctx).ExecContext(ctx, query, invoice.DiscountPercent, invoice.DiscountAmount, invoice.TaxAmount, invoice.Total,
+		invoice.Note, invoice.UpdatedAt, invoice.ID, constants.InvoiceDraft)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errors.New(constants.ErrInvoiceNotDraft)
+	}
+	return nil
+}
+
+// IssueInvoice gives a draft invoice the next number of the month. The
+// sequence row is locked by the upsert, so numbers are never handed out twice.
+// The part covered by a payer is booked as an insurance payment right away and
+// a claim for it is opened, the patient only pays the rest. An invoice without
+// anything to pay is paid as soon as it is issued.
+func (repository *invoiceRepository) IssueInvoice(ctx context.Context, id, issuedBy string) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var status, payerID, memberNumber string
+		var total, covered int
+		query := "SELECT status, total, covered_amount, COALESCE(payer_id::text, ''), COALESCE(member_number, '') FROM invoices WHERE id = $1 FOR UPDATE;"
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&status, &total, &covered, &payerID, &memberNumber); err != nil {
+			return err
+		}
+
+		if status != constants.InvoiceDraft {
+			return errors.New(constants.ErrInvoiceNotDraft)
+		}
+
+		now := time.Now()
+		period := now.Format("200601")
+
+		var number int
+		query = `
+			INSERT INTO invoice_sequences (period, last_number) VALUES ($1, 1)
+			ON CONFLICT (period) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+			RETURNING last_number;`
+		if err := tx.QueryRowContext(ctx, query, period).Scan(&number); err != nil {
+			return err
+		}
+
+		// A discount given after the coverage was applied may have lowered the total
+		if covered > total {
+			covered = total
+		}
+
+		query = "UPDATE invoices SET invoice_number = $1, status = $2, covered_amount = $3, issued_by = $4, issued_at = $5, updated_at = $5 WHERE id = $6;"
+		_, err := tx.ExecContext(ctx, query, fmt.Sprintf("INV/%s/%05d", period, number), constants.InvoiceIssued, covered, nullable(issuedBy), now.Format("2006-01-02 15:04:05"), id)
+		if err != nil {
+			return err
+		}
+
+		if payerID != "" && covered > 0 {
+			return openClaim(ctx, tx, id, payerID, memberNumber, covered, issuedBy)
+		}
+
+		// Nothing is left to pay, e.g. a free visit, so the record is settled right away
+		if total == 0 {
+			before, err := lockCollected(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			return updateCollected(ctx, tx, id, before, before, issuedBy, "", now.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	})
+}
+
+// openClaim books the covered part of an invoice as paid by the payer and
+// opens the claim that collects it.
+func openClaim(ctx context.Context, tx *sql.Tx, id, payerID, memberNumber string, covered int, issuedBy string) error {
+	payments := []invoiceDto.Payment{{
+		Method:         constants.PaymentInsurance,
+		Amount:         covered,
+		TenderedAmount: covered,
+		Reference:      memberNumber,
+		CashierID:      issuedBy,
+	}}
+	if err := RecordPayments(ctx, tx, id, payments); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO insurance_claims (invoice_id, payer_id, member_number, payment_id, status, claimed_amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	_, err := tx.ExecContext(ctx, query, id, payerID, memberNumber, payments[0].ID, constants.ClaimPending, covered, time.Now().Format("2006-01-02 15:04:05"))
+	return err
+}
+
+func (repository *invoiceRepository) VoidInvoice(ctx context.Context, id string, req invoiceDto.VoidRequest) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var status string
+		var paidAmount int
+		if err := tx.QueryRowContext(ctx, "SELECT status, paid_amount FROM invoices WHERE id = $1 FOR UPDATE;", id).Scan(&status, &paidAmount); err != nil {
+			return err
+		}
+
+		if (status != constants.InvoiceDraft && status != constants.InvoiceIssued) || paidAmount > 0 {
+			return errors.New(constants.ErrInvoiceNotVoidable)
+		}
+
+		query := "UPDATE invoices SET status = $1, void_reason = $2, voided_by = $3, voided_at = $4, updated_at = $4 WHERE id = $5;"
+		_, err := tx.ExecContext(ctx, query, constants.InvoiceVoid, req.Reason, nullable(req.VoidedBy), time.Now().Format("2006-01-02 15:04:05"), id)
+		return err
+	})
+}
+
+func (repository *invoiceRepository) RetrieveConsultationFees(ctx context.Context, defaultFee int) ([]invoiceDto.ConsultationFee, error) {
+	query := `
+		SELECT u.id, u.username, COALESCE(f.fee, $1), COALESCE(f.updated_by::text, ''), COALESCE(f.updated_at::text, '')
+		FROM users u LEFT JOIN consultation_fees f ON f.doctor_id = u.id
+		WHERE u.role = 'DOCTOR' AND u.deleted_at IS NULL
+		ORDER BY u.username;`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, defaultFee)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fees []invoiceDto.ConsultationFee
+	for rows.Next() {
+		var fee invoiceDto.ConsultationFee
+		if err := rows.Scan(&fee.DoctorID, &fee.DoctorName, &fee.Fee, &fee.UpdatedBy, &fee.UpdatedAt); err != nil {
+			return nil, err
+		}
+		fees = append(fees, fee)
+	}
+	return fees, nil
+}
+
+func (repository *invoiceRepository) UpsertConsultationFee(ctx context.Context, req invoiceDto.ConsultationFeeRequest) (invoiceDto.ConsultationFee, error) {
+	query := `
+		INSERT INTO consultation_fees (doctor_id, fee, updated_by, updated_at)
+		SELECT id, $2, $3, $4 FROM users WHERE id = $1 AND role = 'DOCTOR' AND deleted_at IS NULL
+		ON CONFLICT (doctor_id) DO UPDATE SET fee = EXCLUDED.fee, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING doctor_id, fee, updated_at::text;`
+
+	fee := invoiceDto.ConsultationFee{UpdatedBy: req.UpdatedBy}
+	err := repository.conn(ctx).QueryRowContext(ctx, query, req.DoctorID, req.Fee, nullable(req.UpdatedBy), time.Now().Format("2006-01-02 15:04:05")).
+		Scan(&fee.DoctorID, &fee.Fee, &fee.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return invoiceDto.ConsultationFee{}, errors.New(constants.ErrDoctorNotExist)
+	}
+	return fee, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanInvoice(row scanner) (invoiceDto.Invoice, error) {
+	var invoice invoiceDto.Invoice
+	err := row.Scan(
+		&invoice.ID,
+		&invoice.InvoiceNumber,
+		&invoice.MedicalRecordID,
+		&invoice.Status,
+		&invoice.Subtotal,
+		&invoice.DiscountPercent,
+		&invoice.DiscountAmount,
+		&invoice.TaxRate,
+		&invoice.TaxAmount,
+		&invoice.Total,
+		&invoice.PaidAmount,
+		&invoice.RefundedAmount,
+		&invoice.Note,
+		&invoice.CreatedBy,
+		&invoice.IssuedBy,
+		&invoice.VoidedBy,
+		&invoice.VoidReason,
+		&invoice.CreatedAt,
+		&invoice.UpdatedAt,
+		&invoice.IssuedAt,
+		&invoice.PaidAt,
+		&invoice.VoidedAt,
+		&invoice.PayerID,
+		&invoice.MemberNumber,
+		&invoice.CoveredAmount,
+	)
+	invoice.Balance = invoice.Total - invoice.PaidAmount
+	invoice.PatientAmount = invoice.Total - invoice.CoveredAmount
+	return invoice, err
+}
+
+// nullable stores empty strings as NULL for the optional uuid columns.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
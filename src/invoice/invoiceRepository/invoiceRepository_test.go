@@ -0,0 +1,403 @@
+package invoiceRepository
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/invoice"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type invoiceRepositoryTestSuite struct {
+	suite.Suite
+	invoiceRepo invoice.InvoiceRepository
+	mock        sqlmock.Sqlmock
+}
+
+func (suite *invoiceRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.invoiceRepo = NewInvoiceRepository(db)
+	suite.mock = mock
+}
+
+func (suite *invoiceRepositoryTestSuite) expectIssueLock(status string, total, covered int, payerID string) {
+	suite.mock.ExpectQuery(`SELECT status, total, covered_amount, (.+) FROM invoices WHERE id = \$1 FOR UPDATE`).
+		WithArgs("i1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "total", "covered_amount", "payer_id", "member_number"}).
+			AddRow(status, total, covered, payerID, "0001234"))
+}
+
+func (suite *invoiceRepositoryTestSuite) TestIssueInvoice() {
+	period := time.Now().Format("200601")
+
+	suite.mock.ExpectBegin()
+	suite.expectIssueLock(constants.InvoiceDraft, 100000, 0, "")
+	suite.mock.ExpectQuery(`INSERT INTO invoice_sequences`).
+		WithArgs(period).
+		WillReturnRows(sqlmock.NewRows([]string{"last_number"}).AddRow(7))
+	suite.mock.ExpectExec(`UPDATE invoices SET invoice_number = \$1`).
+		WithArgs(fmt.Sprintf("INV/%s/00007", period), constants.InvoiceIssued, 0, "u1", sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	err := suite.invoiceRepo.IssueInvoice(context.Background(), "i1", "u1")
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestIssueInvoiceNothingToPay() {
+	suite.mock.ExpectBegin()
+	suite.expectIssueLock(constants.InvoiceDraft, 0, 0, "")
+	suite.mock.ExpectQuery(`INSERT INTO invoice_sequences`).
+		WillReturnRows(sqlmock.NewRows([]string{"last_number"}).AddRow(9))
+	suite.mock.ExpectExec(`UPDATE invoices SET invoice_number = \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.expectLockCollected(constants.InvoiceIssued, 0, 0, 0)
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(0, 0, constants.InvoicePaid, sqlmock.AnyArg(), sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.expectSettle()
+	suite.mock.ExpectCommit()
+
+	err := suite.invoiceRepo.IssueInvoice(context.Background(), "i1", "u1")
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestIssueInvoiceNotDraft() {
+	suite.mock.ExpectBegin()
+	suite.expectIssueLock(constants.InvoiceIssued, 100000, 0, "")
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.IssueInvoice(context.Background(), "i1", "u1")
+
+	suite.EqualError(err, constants.ErrInvoiceNotDraft)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestIssueInvoiceOpensClaim() {
+	suite.mock.ExpectBegin()
+	// The coverage was applied before a discount lowered the total
+	suite.expectIssueLock(constants.InvoiceDraft, 80000, 90000, "py1")
+	suite.mock.ExpectQuery(`INSERT INTO invoice_sequences`).
+		WillReturnRows(sqlmock.NewRows([]string{"last_number"}).AddRow(8))
+	suite.mock.ExpectExec(`UPDATE invoices SET invoice_number = \$1`).
+		WithArgs(sqlmock.AnyArg(), constants.InvoiceIssued, 80000, "u1", sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.expectLockCollected(constants.InvoiceIssued, 80000, 0, 0)
+	suite.mock.ExpectQuery(`INSERT INTO invoice_payments`).
+		WithArgs("i1", constants.PaymentInsurance, 80000, 80000, 0, "0001234", "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p1"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(80000, 0, constants.InvoicePaid, sqlmock.AnyArg(), sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.expectSettle()
+	suite.mock.ExpectExec(`INSERT INTO insurance_claims`).
+		WithArgs("i1", "py1", "0001234", "p1", constants.ClaimPending, 80000, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	err := suite.invoiceRepo.IssueInvoice(context.Background(), "i1", "u1")
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) expectLockCollected(status string, total, paid, refunded int) {
+	suite.mock.ExpectQuery(`SELECT medical_record_id, status, total, paid_amount, refunded_amount FROM invoices`).
+		WithArgs("i1").
+		WillReturnRows(sqlmock.NewRows([]string{"medical_record_id", "status", "total", "paid_amount", "refunded_amount"}).
+			AddRow("mr1", status, total, paid, refunded))
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertPaymentsSplit() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoiceIssued, 100000, 0, 0)
+	suite.mock.ExpectQuery(`INSERT INTO invoice_payments`).
+		WithArgs("i1", constants.PaymentCash, 40000, 50000, 10000, nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p1"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).
+		WithArgs("i1", constants.InvoiceEventPayment, "p1", nil, 40000, 40000, constants.InvoicePartiallyPaid, nil, "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery(`INSERT INTO invoice_payments`).
+		WithArgs("i1", constants.PaymentDebit, 60000, 60000, 0, "TRX1", "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).
+		WithArgs("i1", constants.InvoiceEventPayment, "p2", nil, 60000, 100000, constants.InvoicePaid, nil, "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(100000, 0, constants.InvoicePaid, sqlmock.AnyArg(), sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.expectSettle()
+	suite.mock.ExpectCommit()
+
+	err := suite.invoiceRepo.InsertPayments(context.Background(), "i1", []invoiceDto.Payment{
+		{Method: constants.PaymentCash, Amount: 40000, TenderedAmount: 50000, ChangeAmount: 10000, CashierID: "u1"},
+		{Method: constants.PaymentDebit, Amount: 60000, TenderedAmount: 60000, Reference: "TRX1", CashierID: "u1"},
+	})
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertInvoiceRecordAlreadyPaid() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT payment_status FROM medical_records WHERE id = \$1 FOR UPDATE`).WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(true))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.invoiceRepo.InsertInvoice(context.Background(), invoiceDto.Invoice{MedicalRecordID: "mr1", Status: constants.InvoiceDraft})
+
+	suite.EqualError(err, constants.ErrMedicalRecordAlreadyPaid)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+// expectSettle expects the record of the invoice, without medicines or
+// actions, to be marked paid.
+func (suite *invoiceRepositoryTestSuite) expectSettle() {
+	suite.mock.ExpectQuery(`FROM medical_records WHERE id = \$1 AND deleted_at IS null FOR UPDATE`).
+		WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "payment_status", "created_at"}).
+			AddRow("mr1", "b1", "flu", false, "2024-03-12 16:06:00"))
+	suite.mock.ExpectQuery(`UPDATE medical_records SET payment_status = true`).WithArgs("mr1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status", "updated_at"}).AddRow(true, "2024-03-12 16:30:00"))
+	suite.mock.ExpectExec(`SELECT id FROM medicines WHERE id IN (.+) FOR UPDATE`).WithArgs("mr1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id`).WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"}))
+	suite.mock.ExpectQuery(`FROM medical_record_action_details d JOIN actions a (.+) WHERE d.medical_record_id`).WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action_id", "name", "action_price", "description", "created_at"}))
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertPaymentsRecordAlreadyPaid() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoiceIssued, 100000, 0, 0)
+	suite.mock.ExpectQuery(`INSERT INTO invoice_payments`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p1"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	// The record was paid through the legacy endpoint, the money would be taken twice
+	suite.mock.ExpectQuery(`FROM medical_records WHERE id = \$1 AND deleted_at IS null FOR UPDATE`).
+		WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "payment_status", "created_at"}).
+			AddRow("mr1", "b1", "flu", true, "2024-03-12 16:06:00"))
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.InsertPayments(context.Background(), "i1", []invoiceDto.Payment{{Method: constants.PaymentCash, Amount: 100000, TenderedAmount: 100000, CashierID: "u1"}})
+
+	suite.EqualError(err, constants.ErrMedicalRecordAlreadyPaid)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertPaymentsExceedsBalance() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoicePartiallyPaid, 100000, 70000, 0)
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.InsertPayments(context.Background(), "i1", []invoiceDto.Payment{{Method: constants.PaymentCash, Amount: 40000}})
+
+	suite.EqualError(err, constants.ErrPaymentExceedsBalance)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertPaymentsNotPayable() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoiceDraft, 100000, 0, 0)
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.InsertPayments(context.Background(), "i1", []invoiceDto.Payment{{Method: constants.PaymentCash, Amount: 1000}})
+
+	suite.EqualError(err, constants.ErrInvoiceNotPayable)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestVoidPaymentNotExist() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoicePartiallyPaid, 100000, 40000, 0)
+	suite.mock.ExpectQuery(`SELECT amount, status FROM invoice_payments`).
+		WithArgs("p1", "i1").
+		WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.VoidPayment(context.Background(), "i1", "p1", invoiceDto.VoidRequest{Reason: "typo"}, "2024-03-13 16:06:00")
+
+	suite.EqualError(err, constants.ErrPaymentNotExist)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestVoidPaymentClaimSubmitted() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoicePartiallyPaid, 100000, 60000, 0)
+	suite.mock.ExpectQuery(`SELECT amount, status FROM invoice_payments`).
+		WithArgs("p1", "i1").
+		WillReturnRows(sqlmock.NewRows([]string{"amount", "status"}).AddRow(60000, constants.PaymentCompleted))
+	suite.mock.ExpectQuery(`SELECT id, status FROM insurance_claims WHERE payment_id = \$1`).
+		WithArgs("p1", constants.ClaimCanceled).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow("c1", constants.ClaimSubmitted))
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.VoidPayment(context.Background(), "i1", "p1", invoiceDto.VoidRequest{Reason: "wrong payer"}, "2024-03-13 16:06:00")
+
+	suite.EqualError(err, constants.ErrPaymentClaimSubmitted)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestReduceCoverage() {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT medical_record_id, status, total, paid_amount, refunded_amount FROM invoices`).
+		WithArgs("i1").
+		WillReturnRows(sqlmock.NewRows([]string{"medical_record_id", "status", "total", "paid_amount", "refunded_amount"}).
+			AddRow("mr1", constants.InvoicePaid, 100000, 100000, 0))
+	mock.ExpectQuery(`SELECT amount, method, status FROM invoice_payments`).
+		WithArgs("p1", "i1").
+		WillReturnRows(sqlmock.NewRows([]string{"amount", "method", "status"}).AddRow(80000, constants.PaymentInsurance, constants.PaymentCompleted))
+	mock.ExpectExec(`UPDATE invoice_payments SET status = \$1`).
+		WithArgs(constants.PaymentVoid, "partly approved", "u1", sqlmock.AnyArg(), "p1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO invoice_events`).
+		WithArgs("i1", constants.InvoiceEventPaymentVoid, "p1", nil, -80000, 20000, constants.InvoicePartiallyPaid, "partly approved", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO invoice_payments`).
+		WithArgs("p1", 50000, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	mock.ExpectExec(`INSERT INTO invoice_events`).
+		WithArgs("i1", constants.InvoiceEventPayment, "p2", nil, 50000, 70000, constants.InvoicePartiallyPaid, nil, "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE invoices SET covered_amount = \$1`).
+		WithArgs(50000, "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Nothing goes back into stock, the medicines have been taken home
+	mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(70000, 0, constants.InvoicePartiallyPaid, nil, sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	paymentID, err := ReduceCoverage(context.Background(), tx, "i1", "p1", 50000, "partly approved", "u1")
+	tx.Commit()
+
+	suite.Nil(err)
+	suite.Equal("p2", paymentID)
+	suite.Nil(mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertRefundReversesSettlement() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoicePaid, 100000, 100000, 0)
+	suite.mock.ExpectQuery(`INSERT INTO invoice_refunds`).
+		WithArgs("i1", constants.PaymentCash, 100000, nil, "patient left", "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("r1"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).
+		WithArgs("i1", constants.InvoiceEventRefund, nil, "r1", -100000, 0, constants.InvoiceRefunded, "patient left", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(0, 100000, constants.InvoiceRefunded, nil, sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM invoice_items`).
+		WithArgs("i1", constants.InvoiceItemMedicine).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(30000))
+	suite.mock.ExpectQuery(`SELECT payment_status FROM medical_records`).
+		WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(true))
+	suite.mock.ExpectExec(`SELECT id FROM medicines WHERE id IN`).
+		WithArgs("mr1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`FROM stock_movements`).
+		WithArgs("mr1", constants.MovementDispense, constants.MovementReturn).
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "batch_id", "quantity"}).AddRow("m1", "", 2))
+	suite.mock.ExpectQuery(`UPDATE medicines SET stock = COALESCE\(stock, 0\) \+ \$1`).
+		WithArgs(2, sqlmock.AnyArg(), "m1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(10))
+	suite.mock.ExpectQuery(`INSERT INTO stock_movements`).
+		WithArgs("m1", nil, constants.MovementReturn, 2, 10, "medical_record", "mr1", nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	suite.mock.ExpectExec(`UPDATE stock_reservations SET status = \$1, expires_at = \$2`).
+		WithArgs(constants.ReservationReserved, "2024-03-13 16:06:00", sqlmock.AnyArg(), "mr1", constants.ReservationConsumed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE medical_records SET payment_status = false`).
+		WithArgs(sqlmock.AnyArg(), "mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	refund := invoiceDto.Refund{Method: constants.PaymentCash, Amount: 100000, Reason: "patient left", CreatedBy: "u1"}
+	err := suite.invoiceRepo.InsertRefund(context.Background(), "i1", refund, "2024-03-13 16:06:00")
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertPartialRefundKeepsSettlement() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoicePaid, 100000, 100000, 0)
+	suite.mock.ExpectQuery(`INSERT INTO invoice_refunds`).
+		WithArgs("i1", constants.PaymentCash, 20000, nil, "action not done", "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("r1"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).
+		WithArgs("i1", constants.InvoiceEventRefund, nil, "r1", -20000, 80000, constants.InvoicePartiallyPaid, "action not done", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(80000, 20000, constants.InvoicePartiallyPaid, nil, sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The medicines are still paid for, nothing goes back into stock
+	suite.mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM invoice_items`).
+		WithArgs("i1", constants.InvoiceItemMedicine).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(30000))
+	suite.mock.ExpectCommit()
+
+	refund := invoiceDto.Refund{Method: constants.PaymentCash, Amount: 20000, Reason: "action not done", CreatedBy: "u1"}
+	err := suite.invoiceRepo.InsertRefund(context.Background(), "i1", refund, "2024-03-13 16:06:00")
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestInsertRefundExceedsPaid() {
+	suite.mock.ExpectBegin()
+	suite.expectLockCollected(constants.InvoicePartiallyPaid, 100000, 30000, 0)
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.InsertRefund(context.Background(), "i1", invoiceDto.Refund{Method: constants.PaymentCash, Amount: 50000}, "")
+
+	suite.EqualError(err, constants.ErrRefundExceedsPaid)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestVoidInvoicePartiallyPaid() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`SELECT status, paid_amount FROM invoices`).
+		WithArgs("i1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "paid_amount"}).AddRow(constants.InvoicePartiallyPaid, 10000))
+	suite.mock.ExpectRollback()
+
+	err := suite.invoiceRepo.VoidInvoice(context.Background(), "i1", invoiceDto.VoidRequest{Reason: "wrong patient"})
+
+	suite.EqualError(err, constants.ErrInvoiceNotVoidable)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *invoiceRepositoryTestSuite) TestUpsertConsultationFeeDoctorNotExist() {
+	suite.mock.ExpectQuery(`INSERT INTO consultation_fees`).
+		WithArgs("d1", 100000, "u1", sqlmock.AnyArg()).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := suite.invoiceRepo.UpsertConsultationFee(context.Background(), invoiceDto.ConsultationFeeRequest{DoctorID: "d1", Fee: 100000, UpdatedBy: "u1"})
+
+	suite.EqualError(err, constants.ErrDoctorNotExist)
+}
+
+func TestInvoiceRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(invoiceRepositoryTestSuite))
+}
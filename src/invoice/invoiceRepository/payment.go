@@ -0,0 +1,346 @@
+package invoiceRepository
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/medicalRecord/medicalRecordRepository"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// collected is the money state of an invoice, read under a row lock by every
+// operation that changes it.
+type collected struct {
+	medicalRecordID string
+	status          string
+	total           int
+	paidAmount      int
+	refundedAmount  int
+}
+
+func lockCollected(ctx context.Context, tx *sql.Tx, id string) (collected, error) {
+	var c collected
+	query := "SELECT medical_record_id, status, total, paid_amount, refunded_amount FROM invoices WHERE id = $1 FOR UPDATE;"
+	err := tx.QueryRowContext(ctx, query, id).Scan(&c.medicalRecordID, &c.status, &c.total, &c.paidAmount, &c.refundedAmount)
+	return c, err
+}
+
+// paidStatus is the status of an issued invoice holding the given amounts.
+func paidStatus(c collected) string {
+	switch {
+	case c.paidAmount >= c.total:
+		return constants.InvoicePaid
+	case c.paidAmount > 0:
+		return constants.InvoicePartiallyPaid
+	case c.refundedAmount > 0:
+		return constants.InvoiceRefunded
+	}
+	return constants.InvoiceIssued
+}
+
+// InsertPayments records the payments of an invoice in one go, so a split
+// payment is either taken completely or not at all. Once the balance reaches
+// zero the medical record is settled and its medicines are dispensed.
+func (repository *invoiceRepository) InsertPayments(ctx context.Context, id string, payments []invoiceDto.Payment) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		return RecordPayments(ctx, transaction.Tx(ctx), id, payments)
+	})
+}
+
+// RecordPayments is InsertPayments inside the caller's transaction, for
+// payments arriving from outside the cashier such as a payment gateway. The
+// business checks run before anything is written. The ids of the recorded
+// payments are set on the given slice.
+func RecordPayments(ctx context.Context, tx *sql.Tx, id string, payments []invoiceDto.Payment) error {
+	before, err := lockCollected(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if before.status != constants.InvoiceIssued && before.status != constants.InvoicePartiallyPaid {
+		return errors.New(constants.ErrInvoiceNotPayable)
+	}
+
+	total := before.paidAmount
+	for _, payment := range payments {
+		total += payment.Amount
+	}
+
+	if total > before.total {
+		return errors.New(constants.ErrPaymentExceedsBalance)
+	}
+
+	after, now := before, time.Now().Format("2006-01-02 15:04:05")
+	for i := range payments {
+		payment := &payments[i]
+		after.paidAmount += payment.Amount
+
+		query := `
+			INSERT INTO invoice_payments (invoice_id, method, amount, tendered_amount, change_amount, reference, cashier_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;`
+		err := tx.QueryRowContext(ctx, query, id, payment.Method, payment.Amount, payment.TenderedAmount, payment.ChangeAmount,
+			nullable(payment.Reference), nullable(payment.CashierID), now).Scan(&payment.ID)
+		if err != nil {
+			return err
+		}
+
+		event := invoiceDto.Event{
+			EventType: constants.InvoiceEventPayment,
+			PaymentID: payment.ID,
+			Amount:    payment.Amount,
+			CreatedBy: payment.CashierID,
+		}
+		if err := insertEvent(ctx, tx, id, after, event, now); err != nil {
+			return err
+		}
+	}
+
+	return updateCollected(ctx, tx, id, before, after, payments[0].CashierID, "", now)
+}
+
+// VoidPayment reverses a payment taken by mistake. A payment the invoice no
+// longer holds because it was refunded cannot be voided.
+func (repository *invoiceRepository) VoidPayment(ctx context.Context, id, paymentID string, req invoiceDto.VoidRequest, reservedUntil string) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		before, err := lockCollected(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		var amount int
+		var status string
+		query := "SELECT amount, status FROM invoice_payments WHERE id = $1 AND invoice_id = $2 FOR UPDATE;"
+		if err := tx.QueryRowContext(ctx, query, paymentID, id).Scan(&amount, &status); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.New(constants.ErrPaymentNotExist)
+			}
+			return err
+		}
+
+		if status != constants.PaymentCompleted || amount > before.paidAmount {
+			return errors.New(constants.ErrPaymentNotVoidable)
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		if err := cancelClaim(ctx, tx, id, paymentID, now); err != nil {
+			return err
+		}
+
+		query = "UPDATE invoice_payments SET status = $1, void_reason = $2, voided_by = $3, voided_at = $4 WHERE id = $5;"
+		if _, err := tx.ExecContext(ctx, query, constants.PaymentVoid, req.Reason, nullable(req.VoidedBy), now, paymentID); err != nil {
+			return err
+		}
+
+		after := before
+		after.paidAmount -= amount
+		event := invoiceDto.Event{
+			EventType: constants.InvoiceEventPaymentVoid,
+			PaymentID: paymentID,
+			Amount:    -amount,
+			Note:      req.Reason,
+			CreatedBy: req.VoidedBy,
+		}
+		if err := insertEvent(ctx, tx, id, after, event, now); err != nil {
+			return err
+		}
+
+		return updateCollected(ctx, tx, id, before, after, req.VoidedBy, reservedUntil, now)
+	})
+}
+
+// InsertRefund gives money of a paid invoice back. A refund taking the invoice
+// below its total puts the dispensed medicines back into reserved stock.
+func (repository *invoiceRepository) InsertRefund(ctx context.Context, id string, refund invoiceDto.Refund, reservedUntil string) error {
+	return transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		before, err := lockCollected(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if before.status != constants.InvoicePaid && before.status != constants.InvoicePartiallyPaid {
+			return errors.New(constants.ErrInvoiceNotRefundable)
+		}
+
+		if refund.Amount > before.paidAmount {
+			return errors.New(constants.ErrRefundExceedsPaid)
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		query := `
+			INSERT INTO invoice_refunds (invoice_id, method, amount, reference, reason, created_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+		err = tx.QueryRowContext(ctx, query, id, refund.Method, refund.Amount, nullable(refund.Reference), refund.Reason,
+			nullable(refund.CreatedBy), now).Scan(&refund.ID)
+		if err != nil {
+			return err
+		}
+
+		after := before
+		after.paidAmount -= refund.Amount
+		after.refundedAmount += refund.Amount
+		event := invoiceDto.Event{
+			EventType: constants.InvoiceEventRefund,
+			RefundID:  refund.ID,
+			Amount:    -refund.Amount,
+			Note:      refund.Reason,
+			CreatedBy: refund.CreatedBy,
+		}
+		if err := insertEvent(ctx, tx, id, after, event, now); err != nil {
+			return err
+		}
+
+		return updateCollected(ctx, tx, id, before, after, refund.CreatedBy, reservedUntil, now)
+	})
+}
+
+func (repository *invoiceRepository) RetrieveEvents(ctx context.Context, id string) ([]invoiceDto.Event, error) {
+	query := `
+		SELECT id, invoice_id, event_type, COALESCE(payment_id::text, ''), COALESCE(refund_id::text, ''), amount,
+			paid_amount_after, status_after, COALESCE(note, ''), COALESCE(created_by::text, ''), created_at
+		FROM invoice_events WHERE invoice_id = $1 ORDER BY seq;`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []invoiceDto.Event
+	for rows.Next() {
+		var event invoiceDto.Event
+		err := rows.Scan(&event.ID, &event.InvoiceID, &event.EventType, &event.PaymentID, &event.RefundID, &event.Amount,
+			&event.PaidAmountAfter, &event.StatusAfter, &event.Note, &event.CreatedBy, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (repository *invoiceRepository) retrievePayments(ctx context.Context, id string) ([]invoiceDto.Payment, error) {
+	query := `
+		SELECT id, invoice_id, method, amount, tendered_amount, change_amount, COALESCE(reference, ''), status,
+			COALESCE(cashier_id::text, ''), COALESCE(void_reason, ''), COALESCE(voided_by::text, ''), created_at, COALESCE(voided_at::text, '')
+		FROM invoice_payments WHERE invoice_id = $1 ORDER BY created_at;`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []invoiceDto.Payment
+	for rows.Next() {
+		var payment invoiceDto.Payment
+		err := rows.Scan(&payment.ID, &payment.InvoiceID, &payment.Method, &payment.Amount, &payment.TenderedAmount, &payment.ChangeAmount,
+			&payment.Reference, &payment.Status, &payment.CashierID, &payment.VoidReason, &payment.VoidedBy, &payment.CreatedAt, &payment.VoidedAt)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+func (repository *invoiceRepository) retrieveRefunds(ctx context.Context, id string) ([]invoiceDto.Refund, error) {
+	query := `
+		SELECT id, invoice_id, method, amount, COALESCE(reference, ''), reason, COALESCE(created_by::text, ''), created_at
+		FROM invoice_refunds WHERE invoice_id = $1 ORDER BY created_at;`
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []invoiceDto.Refund
+	for rows.Next() {
+		var refund invoiceDto.Refund
+		err := rows.Scan(&refund.ID, &refund.InvoiceID, &refund.Method, &refund.Amount, &refund.Reference, &refund.Reason, &refund.CreatedBy, &refund.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+	}
+	return refunds, nil
+}
+
+func insertEvent(ctx context.Context, tx *sql.Tx, id string, after collected, event invoiceDto.Event, now string) error {
+	query := `
+		INSERT INTO invoice_events (invoice_id, event_type, payment_id, refund_id, amount, paid_amount_after, status_after, note, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+	_, err := tx.ExecContext(ctx, query, id, event.EventType, nullable(event.PaymentID), nullable(event.RefundID), event.Amount,
+		after.paidAmount, paidStatus(after), nullable(event.Note), nullable(event.CreatedBy), now)
+	return err
+}
+
+// updateCollected stores the new amounts of the invoice and keeps its medical
+// record in step: settled when the invoice becomes paid, reversed when so much
+// money is given back that what is left no longer pays for the settlement.
+func updateCollected(ctx context.Context, tx *sql.Tx, id string, before, after collected, userID, reservedUntil, now string) error {
+	if err := updateAmounts(ctx, tx, id, after, now); err != nil {
+		return err
+	}
+
+	status := paidStatus(after)
+	switch {
+	case status == constants.InvoicePaid && before.status != constants.InvoicePaid:
+		// A record paid without the invoice would be paid twice
+		_, err := medicalRecordRepository.SettlePayment(ctx, tx, before.medicalRecordID, userID)
+		if err != nil && err.Error() == constants.ErrPaymentAlreadyTrue {
+			return errors.New(constants.ErrMedicalRecordAlreadyPaid)
+		}
+		if err != nil {
+			return err
+		}
+	case status != constants.InvoicePaid && after.paidAmount < before.paidAmount:
+		// A partial refund keeps the medicines dispensed while the rest still
+		// pays for them, reversing a record that is not settled does nothing
+		required, err := settlementRequired(ctx, tx, id, after.total)
+		if err != nil {
+			return err
+		}
+		if after.paidAmount >= required {
+			return nil
+		}
+		return medicalRecordRepository.ReverseSettlement(ctx, tx, before.medicalRecordID, userID, reservedUntil)
+	}
+	return nil
+}
+
+// settlementRequired is what an invoice has to keep collected for its record
+// to stay settled: the medicines that settling dispensed, at most the total,
+// and anything at all for a record without medicines.
+func settlementRequired(ctx context.Context, tx *sql.Tx, id string, total int) (int, error) {
+	var required int
+	query := "SELECT COALESCE(SUM(amount), 0) FROM invoice_items WHERE invoice_id = $1 AND item_type = $2;"
+	if err := tx.QueryRowContext(ctx, query, id, constants.InvoiceItemMedicine).Scan(&required); err != nil {
+		return 0, err
+	}
+
+	if required > total {
+		required = total
+	}
+	if required < 1 {
+		required = 1
+	}
+	return required, nil
+}
+
+func updateAmounts(ctx context.Context, tx *sql.Tx, id string, after collected, now string) error {
+	status := paidStatus(after)
+
+	var paidAt interface{}
+	if status == constants.InvoicePaid {
+		paidAt = now
+	}
+
+	query := "UPDATE invoices SET paid_amount = $1, refunded_amount = $2, status = $3, paid_at = $4, updated_at = $5 WHERE id = $6;"
+	_, err := tx.ExecContext(ctx, query, after.paidAmount, after.refundedAmount, status, paidAt, now, id)
+	return err
+}
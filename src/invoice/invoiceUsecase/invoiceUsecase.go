@@ -0,0 +1,250 @@
+package invoiceUsecase
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/invoice"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+type invoiceUsecase struct {
+	invoiceRepo     invoice.InvoiceRepository
+	consultationFee int
+	taxRate         float64
+	reservationTTL  time.Duration
+}
+
+// NewInvoiceUsecase takes the consultation fee charged for doctors without
+// their own fee, the PPN rate in percent applied to new invoices and how long
+// medicines stay reserved after the payment of a record is given back.
+func NewInvoiceUsecase(invoiceRepo invoice.InvoiceRepository, consultationFee int, taxRate float64, reservationTTL time.Duration) invoice.InvoiceUsecase {
+	return &invoiceUsecase{invoiceRepo, consultationFee, taxRate, reservationTTL}
+}
+
+func (usecase *invoiceUsecase) GetInvoices(ctx context.Context, filter invoiceDto.InvoiceFilter) ([]invoiceDto.Invoice, error) {
+	filter.Status = strings.ToUpper(filter.Status)
+	return usecase.invoiceRepo.RetrieveInvoices(ctx, filter)
+}
+
+func (usecase *invoiceUsecase) GetInvoiceByID(ctx context.Context, id string) (invoiceDto.Invoice, error) {
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+// CreateInvoice drafts the invoice of a medical record: the consultation fee
+// of the doctor followed by the prescribed medicines and the actions taken.
+func (usecase *invoiceUsecase) CreateInvoice(ctx context.Context, req invoiceDto.CreateRequest) (invoiceDto.Invoice, error) {
+	exist, err := usecase.invoiceRepo.IsMedicalRecordExist(ctx, req.MedicalRecordID)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	if !exist {
+		return invoiceDto.Invoice{}, errors.New(constants.ErrMedicalRecordNotExist)
+	}
+
+	invoiced, err := usecase.invoiceRepo.IsInvoiced(ctx, req.MedicalRecordID)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	if invoiced {
+		return invoiceDto.Invoice{}, errors.New(constants.ErrInvoiceAlreadyExist)
+	}
+
+	fee, err := usecase.invoiceRepo.RetrieveConsultationFee(ctx, req.MedicalRecordID, usecase.consultationFee)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	items, err := usecase.invoiceRepo.RetrieveBillableItems(ctx, req.MedicalRecordID)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	if fee.Fee > 0 {
+		consultation := invoiceDto.Item{
+			ItemType:    constants.InvoiceItemConsultation,
+			ReferenceID: fee.DoctorID,
+			Description: "Consultation - " + fee.DoctorName,
+			Quantity:    1,
+			UnitPrice:   fee.Fee,
+		}
+		items = append([]invoiceDto.Item{consultation}, items...)
+	}
+
+	for i := range items {
+		items[i].Amount = items[i].Quantity * items[i].UnitPrice
+	}
+
+	newInvoice := invoiceDto.Invoice{
+		MedicalRecordID: req.MedicalRecordID,
+		Status:          constants.InvoiceDraft,
+		Items:           items,
+		TaxRate:         usecase.taxRate,
+		Note:            req.Note,
+		CreatedBy:       req.CreatedBy,
+		CreatedAt:       time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := calculateTotals(&newInvoice); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	id, err := usecase.invoiceRepo.InsertInvoice(ctx, newInvoice)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+func (usecase *invoiceUsecase) SetDiscount(ctx context.Context, id string, req invoiceDto.DiscountRequest) (invoiceDto.Invoice, error) {
+	draft, err := usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+	if err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	if draft.Status != constants.InvoiceDraft {
+		return invoiceDto.Invoice{}, errors.New(constants.ErrInvoiceNotDraft)
+	}
+
+	draft.DiscountPercent = req.DiscountPercent
+	draft.DiscountAmount = req.DiscountAmount
+	if req.DiscountAmount > 0 {
+		draft.DiscountPercent = 0
+	}
+
+	if req.Note != "" {
+		draft.Note = req.Note
+	}
+
+	if err := calculateTotals(&draft); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+
+	draft.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	if err := usecase.invoiceRepo.UpdateDiscount(ctx, draft); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+func (usecase *invoiceUsecase) IssueInvoice(ctx context.Context, id, issuedBy string) (invoiceDto.Invoice, error) {
+	if err := usecase.invoiceRepo.IssueInvoice(ctx, id, issuedBy); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+// PayInvoice takes one or more payments towards the balance. Cash may be
+// tendered above the amount paid and the change is recorded with it, other
+// methods need the reference of the transaction.
+func (usecase *invoiceUsecase) PayInvoice(ctx context.Context, id string, req invoiceDto.PaymentRequest) (invoiceDto.Invoice, error) {
+	var payments []invoiceDto.Payment
+	for _, line := range req.Payments {
+		payment := invoiceDto.Payment{
+			Method:         line.Method,
+			Amount:         line.Amount,
+			TenderedAmount: line.TenderedAmount,
+			Reference:      strings.TrimSpace(line.Reference),
+			CashierID:      req.CashierID,
+		}
+
+		if payment.Method == constants.PaymentCash {
+			if payment.TenderedAmount == 0 {
+				payment.TenderedAmount = payment.Amount
+			}
+
+			if payment.TenderedAmount < payment.Amount {
+				return invoiceDto.Invoice{}, errors.New(constants.ErrInsufficientTender)
+			}
+			payment.ChangeAmount = payment.TenderedAmount - payment.Amount
+		} else {
+			if payment.Reference == "" {
+				return invoiceDto.Invoice{}, errors.New(constants.ErrPaymentReferenceRequired)
+			}
+			payment.TenderedAmount = payment.Amount
+		}
+
+		payments = append(payments, payment)
+	}
+
+	if err := usecase.invoiceRepo.InsertPayments(ctx, id, payments); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+func (usecase *invoiceUsecase) VoidPayment(ctx context.Context, id, paymentID string, req invoiceDto.VoidRequest) (invoiceDto.Invoice, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := usecase.invoiceRepo.VoidPayment(ctx, id, paymentID, req, usecase.reservedUntil()); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+func (usecase *invoiceUsecase) RefundInvoice(ctx context.Context, id string, req invoiceDto.RefundRequest) (invoiceDto.Invoice, error) {
+	refund := invoiceDto.Refund{
+		Method:    req.Method,
+		Amount:    req.Amount,
+		Reference: strings.TrimSpace(req.Reference),
+		Reason:    strings.TrimSpace(req.Reason),
+		CreatedBy: req.CreatedBy,
+	}
+	if err := usecase.invoiceRepo.InsertRefund(ctx, id, refund, usecase.reservedUntil()); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+func (usecase *invoiceUsecase) GetEvents(ctx context.Context, id string) ([]invoiceDto.Event, error) {
+	if _, err := usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return usecase.invoiceRepo.RetrieveEvents(ctx, id)
+}
+
+func (usecase *invoiceUsecase) VoidInvoice(ctx context.Context, id string, req invoiceDto.VoidRequest) (invoiceDto.Invoice, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := usecase.invoiceRepo.VoidInvoice(ctx, id, req); err != nil {
+		return invoiceDto.Invoice{}, err
+	}
+	return usecase.invoiceRepo.RetrieveInvoiceByID(ctx, id)
+}
+
+func (usecase *invoiceUsecase) GetConsultationFees(ctx context.Context) ([]invoiceDto.ConsultationFee, error) {
+	return usecase.invoiceRepo.RetrieveConsultationFees(ctx, usecase.consultationFee)
+}
+
+func (usecase *invoiceUsecase) SetConsultationFee(ctx context.Context, req invoiceDto.ConsultationFeeRequest) (invoiceDto.ConsultationFee, error) {
+	return usecase.invoiceRepo.UpsertConsultationFee(ctx, req)
+}
+
+// reservedUntil is when medicines put back into stock by a refund or voided
+// payment stop being held for the record.
+func (usecase *invoiceUsecase) reservedUntil() string {
+	return time.Now().Add(usecase.reservationTTL).Format("2006-01-02 15:04:05")
+}
+
+// calculateTotals derives the subtotal, discount, tax and total of the invoice
+// from its items. A percentage discount is turned into an amount first.
+func calculateTotals(invoice *invoiceDto.Invoice) error {
+	invoice.Subtotal = 0
+	for _, item := range invoice.Items {
+		invoice.Subtotal += item.Amount
+	}
+
+	if invoice.DiscountPercent > 0 {
+		invoice.DiscountAmount = int(math.Round(float64(invoice.Subtotal) * invoice.DiscountPercent / 100))
+	}
+
+	if invoice.DiscountAmount > invoice.Subtotal {
+		return errors.New(constants.ErrInvalidDiscount)
+	}
+
+	taxable := invoice.Subtotal - invoice.DiscountAmount
+	invoice.TaxAmount = int(math.Round(float64(taxable) * invoice.TaxRate / 100))
+	invoice.Total = taxable + invoice.TaxAmount
+	invoice.Balance = invoice.Total - invoice.PaidAmount
+	return nil
+}
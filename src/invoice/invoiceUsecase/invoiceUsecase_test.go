@@ -0,0 +1,300 @@
+package invoiceUsecase
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/invoice"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockInvoiceRepository struct {
+	mock.Mock
+}
+
+func (m *mockInvoiceRepository) RetrieveInvoices(ctx context.Context, filter invoiceDto.InvoiceFilter) ([]invoiceDto.Invoice, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) RetrieveInvoiceByID(ctx context.Context, id string) (invoiceDto.Invoice, error) {
+	args := m.Called(id)
+	return args.Get(0).(invoiceDto.Invoice), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) RetrieveBillableItems(ctx context.Context, medicalRecordID string) ([]invoiceDto.Item, error) {
+	args := m.Called(medicalRecordID)
+	return args.Get(0).([]invoiceDto.Item), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) RetrieveConsultationFee(ctx context.Context, medicalRecordID string, defaultFee int) (invoiceDto.ConsultationFee, error) {
+	args := m.Called(medicalRecordID, defaultFee)
+	return args.Get(0).(invoiceDto.ConsultationFee), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) IsMedicalRecordExist(ctx context.Context, medicalRecordID string) (bool, error) {
+	args := m.Called(medicalRecordID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) IsInvoiced(ctx context.Context, medicalRecordID string) (bool, error) {
+	args := m.Called(medicalRecordID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) InsertInvoice(ctx context.Context, invoice invoiceDto.Invoice) (string, error) {
+	args := m.Called(invoice)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) UpdateDiscount(ctx context.Context, invoice invoiceDto.Invoice) error {
+	args := m.Called(invoice)
+	return args.Error(0)
+}
+
+func (m *mockInvoiceRepository) IssueInvoice(ctx context.Context, id, issuedBy string) error {
+	args := m.Called(id, issuedBy)
+	return args.Error(0)
+}
+
+func (m *mockInvoiceRepository) InsertPayments(ctx context.Context, id string, payments []invoiceDto.Payment) error {
+	args := m.Called(id, payments)
+	return args.Error(0)
+}
+
+func (m *mockInvoiceRepository) VoidPayment(ctx context.Context, id, paymentID string, req invoiceDto.VoidRequest, reservedUntil string) error {
+	args := m.Called(id, paymentID, req, reservedUntil)
+	return args.Error(0)
+}
+
+func (m *mockInvoiceRepository) InsertRefund(ctx context.Context, id string, refund invoiceDto.Refund, reservedUntil string) error {
+	args := m.Called(id, refund, reservedUntil)
+	return args.Error(0)
+}
+
+func (m *mockInvoiceRepository) RetrieveEvents(ctx context.Context, id string) ([]invoiceDto.Event, error) {
+	args := m.Called(id)
+	return args.Get(0).([]invoiceDto.Event), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) VoidInvoice(ctx context.Context, id string, req invoiceDto.VoidRequest) error {
+	args := m.Called(id, req)
+	return args.Error(0)
+}
+
+func (m *mockInvoiceRepository) RetrieveConsultationFees(ctx context.Context, defaultFee int) ([]invoiceDto.ConsultationFee, error) {
+	args := m.Called(defaultFee)
+	return args.Get(0).([]invoiceDto.ConsultationFee), args.Error(1)
+}
+
+func (m *mockInvoiceRepository) UpsertConsultationFee(ctx context.Context, req invoiceDto.ConsultationFeeRequest) (invoiceDto.ConsultationFee, error) {
+	args := m.Called(req)
+	return args.Get(0).(invoiceDto.ConsultationFee), args.Error(1)
+}
+
+type invoiceUsecaseTestSuite struct {
+	suite.Suite
+	repoMock  *mockInvoiceRepository
+	invoiceUC invoice.InvoiceUsecase
+}
+
+func (suite *invoiceUsecaseTestSuite) SetupTest() {
+	suite.repoMock = new(mockInvoiceRepository)
+	suite.invoiceUC = NewInvoiceUsecase(suite.repoMock, 50000, 11, 24*time.Hour)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestCreateInvoice() {
+	suite.repoMock.On("IsMedicalRecordExist", "mr1").Return(true, nil)
+	suite.repoMock.On("IsInvoiced", "mr1").Return(false, nil)
+	suite.repoMock.On("RetrieveConsultationFee", "mr1", 50000).
+		Return(invoiceDto.ConsultationFee{DoctorID: "d1", DoctorName: "strange", Fee: 75000}, nil)
+	suite.repoMock.On("RetrieveBillableItems", "mr1").Return([]invoiceDto.Item{
+		{ItemType: constants.InvoiceItemMedicine, ReferenceID: "m1", Description: "Paracetamol", Quantity: 3, UnitPrice: 5000},
+		{ItemType: constants.InvoiceItemAction, ReferenceID: "a1", Description: "Injection", Quantity: 1, UnitPrice: 40000},
+	}, nil)
+	suite.repoMock.On("InsertInvoice", mock.MatchedBy(func(invoice invoiceDto.Invoice) bool {
+		return len(invoice.Items) == 3 &&
+			invoice.Items[0].ItemType == constants.InvoiceItemConsultation &&
+			invoice.Items[1].Amount == 15000 &&
+			invoice.Subtotal == 130000 &&
+			invoice.TaxAmount == 14300 &&
+			invoice.Total == 144300 &&
+			invoice.Status == constants.InvoiceDraft
+	})).Return("i1", nil)
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(invoiceDto.Invoice{ID: "i1", Total: 144300}, nil)
+
+	actual, err := suite.invoiceUC.CreateInvoice(context.Background(), invoiceDto.CreateRequest{MedicalRecordID: "mr1", CreatedBy: "u1"})
+
+	suite.Nil(err)
+	suite.Equal(144300, actual.Total)
+	suite.repoMock.AssertExpectations(suite.T())
+}
+
+func (suite *invoiceUsecaseTestSuite) TestCreateInvoiceWithoutConsultationFee() {
+	suite.repoMock.On("IsMedicalRecordExist", "mr1").Return(true, nil)
+	suite.repoMock.On("IsInvoiced", "mr1").Return(false, nil)
+	suite.repoMock.On("RetrieveConsultationFee", "mr1", 50000).Return(invoiceDto.ConsultationFee{DoctorID: "d1"}, nil)
+	suite.repoMock.On("RetrieveBillableItems", "mr1").Return([]invoiceDto.Item{
+		{ItemType: constants.InvoiceItemAction, Description: "Injection", Quantity: 1, UnitPrice: 40000},
+	}, nil)
+	suite.repoMock.On("InsertInvoice", mock.MatchedBy(func(invoice invoiceDto.Invoice) bool {
+		return len(invoice.Items) == 1 && invoice.Subtotal == 40000
+	})).Return("i1", nil)
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(invoiceDto.Invoice{ID: "i1"}, nil)
+
+	_, err := suite.invoiceUC.CreateInvoice(context.Background(), invoiceDto.CreateRequest{MedicalRecordID: "mr1"})
+
+	suite.Nil(err)
+	suite.repoMock.AssertExpectations(suite.T())
+}
+
+func (suite *invoiceUsecaseTestSuite) TestCreateInvoiceAlreadyInvoiced() {
+	suite.repoMock.On("IsMedicalRecordExist", "mr1").Return(true, nil)
+	suite.repoMock.On("IsInvoiced", "mr1").Return(true, nil)
+
+	_, err := suite.invoiceUC.CreateInvoice(context.Background(), invoiceDto.CreateRequest{MedicalRecordID: "mr1"})
+
+	suite.EqualError(err, constants.ErrInvoiceAlreadyExist)
+	suite.repoMock.AssertNotCalled(suite.T(), "InsertInvoice", mock.Anything)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestCreateInvoiceMedicalRecordNotExist() {
+	suite.repoMock.On("IsMedicalRecordExist", "mr1").Return(false, nil)
+
+	_, err := suite.invoiceUC.CreateInvoice(context.Background(), invoiceDto.CreateRequest{MedicalRecordID: "mr1"})
+
+	suite.EqualError(err, constants.ErrMedicalRecordNotExist)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestCreateInvoiceInvoicedCheckFailed() {
+	suite.repoMock.On("IsMedicalRecordExist", "mr1").Return(true, nil)
+	suite.repoMock.On("IsInvoiced", "mr1").Return(false, sql.ErrConnDone)
+
+	_, err := suite.invoiceUC.CreateInvoice(context.Background(), invoiceDto.CreateRequest{MedicalRecordID: "mr1"})
+
+	suite.Equal(sql.ErrConnDone, err)
+	suite.repoMock.AssertNotCalled(suite.T(), "InsertInvoice", mock.Anything)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestSetDiscountPercent() {
+	draft := invoiceDto.Invoice{
+		ID:      "i1",
+		Status:  constants.InvoiceDraft,
+		TaxRate: 11,
+		Items:   []invoiceDto.Item{{Amount: 100000}, {Amount: 50000}},
+	}
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(draft, nil).Once()
+	suite.repoMock.On("UpdateDiscount", mock.MatchedBy(func(invoice invoiceDto.Invoice) bool {
+		return invoice.DiscountAmount == 15000 && invoice.TaxAmount == 14850 && invoice.Total == 149850
+	})).Return(nil)
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(invoiceDto.Invoice{ID: "i1", Total: 149850}, nil).Once()
+
+	actual, err := suite.invoiceUC.SetDiscount(context.Background(), "i1", invoiceDto.DiscountRequest{DiscountPercent: 10})
+
+	suite.Nil(err)
+	suite.Equal(149850, actual.Total)
+	suite.repoMock.AssertExpectations(suite.T())
+}
+
+func (suite *invoiceUsecaseTestSuite) TestSetDiscountExceedsSubtotal() {
+	draft := invoiceDto.Invoice{ID: "i1", Status: constants.InvoiceDraft, Items: []invoiceDto.Item{{Amount: 10000}}}
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(draft, nil)
+
+	_, err := suite.invoiceUC.SetDiscount(context.Background(), "i1", invoiceDto.DiscountRequest{DiscountAmount: 20000})
+
+	suite.EqualError(err, constants.ErrInvalidDiscount)
+	suite.repoMock.AssertNotCalled(suite.T(), "UpdateDiscount", mock.Anything)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestSetDiscountIssuedInvoice() {
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(invoiceDto.Invoice{ID: "i1", Status: constants.InvoiceIssued}, nil)
+
+	_, err := suite.invoiceUC.SetDiscount(context.Background(), "i1", invoiceDto.DiscountRequest{DiscountAmount: 1000})
+
+	suite.EqualError(err, constants.ErrInvoiceNotDraft)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestPayInvoiceSplitWithChange() {
+	expected := []invoiceDto.Payment{
+		{Method: constants.PaymentCash, Amount: 40000, TenderedAmount: 50000, ChangeAmount: 10000, CashierID: "u1"},
+		{Method: constants.PaymentQRIS, Amount: 60000, TenderedAmount: 60000, Reference: "QR123", CashierID: "u1"},
+	}
+	suite.repoMock.On("InsertPayments", "i1", expected).Return(nil)
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(invoiceDto.Invoice{ID: "i1", Status: constants.InvoicePaid}, nil)
+
+	actual, err := suite.invoiceUC.PayInvoice(context.Background(), "i1", invoiceDto.PaymentRequest{
+		CashierID: "u1",
+		Payments: []invoiceDto.PaymentLineRequest{
+			{Method: constants.PaymentCash, Amount: 40000, TenderedAmount: 50000},
+			{Method: constants.PaymentQRIS, Amount: 60000, Reference: " QR123 "},
+		},
+	})
+
+	suite.Nil(err)
+	suite.Equal(constants.InvoicePaid, actual.Status)
+	suite.repoMock.AssertExpectations(suite.T())
+}
+
+func (suite *invoiceUsecaseTestSuite) TestPayInvoiceInsufficientTender() {
+	_, err := suite.invoiceUC.PayInvoice(context.Background(), "i1", invoiceDto.PaymentRequest{
+		Payments: []invoiceDto.PaymentLineRequest{{Method: constants.PaymentCash, Amount: 40000, TenderedAmount: 30000}},
+	})
+
+	suite.EqualError(err, constants.ErrInsufficientTender)
+	suite.repoMock.AssertNotCalled(suite.T(), "InsertPayments", mock.Anything, mock.Anything)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestPayInvoiceReferenceRequired() {
+	_, err := suite.invoiceUC.PayInvoice(context.Background(), "i1", invoiceDto.PaymentRequest{
+		Payments: []invoiceDto.PaymentLineRequest{{Method: constants.PaymentTransfer, Amount: 40000}},
+	})
+
+	suite.EqualError(err, constants.ErrPaymentReferenceRequired)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestPayInvoiceNotPayable() {
+	suite.repoMock.On("InsertPayments", "i1", mock.AnythingOfType("[]invoiceDto.Payment")).Return(errors.New(constants.ErrInvoiceNotPayable))
+
+	_, err := suite.invoiceUC.PayInvoice(context.Background(), "i1", invoiceDto.PaymentRequest{
+		Payments: []invoiceDto.PaymentLineRequest{{Method: constants.PaymentCash, Amount: 1000}},
+	})
+
+	suite.EqualError(err, constants.ErrInvoiceNotPayable)
+	suite.repoMock.AssertNotCalled(suite.T(), "RetrieveInvoiceByID", mock.Anything)
+}
+
+func (suite *invoiceUsecaseTestSuite) TestRefundInvoice() {
+	refund := invoiceDto.Refund{Method: constants.PaymentCash, Amount: 20000, Reason: "medicine returned", CreatedBy: "u1"}
+	suite.repoMock.On("InsertRefund", "i1", refund, mock.AnythingOfType("string")).Return(nil)
+	suite.repoMock.On("RetrieveInvoiceByID", "i1").Return(invoiceDto.Invoice{ID: "i1", RefundedAmount: 20000}, nil)
+
+	actual, err := suite.invoiceUC.RefundInvoice(context.Background(), "i1", invoiceDto.RefundRequest{
+		Method:    constants.PaymentCash,
+		Amount:    20000,
+		Reason:    " medicine returned ",
+		CreatedBy: "u1",
+	})
+
+	suite.Nil(err)
+	suite.Equal(20000, actual.RefundedAmount)
+	suite.repoMock.AssertExpectations(suite.T())
+}
+
+func (suite *invoiceUsecaseTestSuite) TestVoidPaymentNotVoidable() {
+	req := invoiceDto.VoidRequest{Reason: "typo", VoidedBy: "u1"}
+	suite.repoMock.On("VoidPayment", "i1", "p1", req, mock.AnythingOfType("string")).Return(errors.New(constants.ErrPaymentNotVoidable))
+
+	_, err := suite.invoiceUC.VoidPayment(context.Background(), "i1", "p1", req)
+
+	suite.EqualError(err, constants.ErrPaymentNotVoidable)
+}
+
+func TestInvoiceUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(invoiceUsecaseTestSuite))
+}
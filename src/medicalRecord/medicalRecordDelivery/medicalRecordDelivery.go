@@ -1,12 +1,16 @@
 package medicalRecordDelivery
 
 import (
+	"avengers-clinic/model/dto/allergyDto"
 	"avengers-clinic/model/dto/json"
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/middleware"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/medicalRecord"
+	"database/sql"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +30,7 @@ func NewMedicalRecordDelivery(v1Group *gin.RouterGroup, medicalRecordUC medicalR
 		medicalRecordGoup.GET("", middleware.JwtAuth("ADMIN"), handler.getMedicalRecords)
 		medicalRecordGoup.GET("/:id", middleware.JwtAuth("ADMIN"), handler.getMedicalRecordByID)
 		medicalRecordGoup.PUT("/:id", middleware.JwtAuth("ADMIN"), handler.updatePaymentStatus)
+		medicalRecordGoup.DELETE("/:id", middleware.JwtAuth("ADMIN", "DOCTOR"), handler.cancelMedicalRecord)
 	}
 }
 
@@ -43,8 +48,31 @@ func (dd *medicalRecordDelivery) createMedicalRecord(ctx *gin.Context) {
 		return
 	}
 
-	medicalRecord, err := dd.medicalRecordUC.CreateMedicalRecord(req)
+	req.Created_By = utils.GetJWT(ctx).ID
+
+	medicalRecord, err := dd.medicalRecordUC.CreateMedicalRecord(ctx.Request.Context(), req)
 	if err != nil {
+		var prescriptionErr allergyDto.PrescriptionError
+		if errors.As(err, &prescriptionErr) {
+			var issues []json.ValidationField
+			for _, issue := range prescriptionErr.Issues {
+				issues = append(issues, json.ValidationField{FieldName: issue.Code, Message: issue.Message})
+			}
+
+			if prescriptionErr.Message == constants.ErrPrescriptionBlocked {
+				json.NewResponseBadRequest(ctx, issues, constants.ErrPrescriptionBlocked, constants.MedicalRecordService, "06")
+				return
+			}
+
+			if prescriptionErr.Message == constants.ErrUnknownAcknowledgement {
+				json.NewResponseBadRequest(ctx, issues, constants.ErrUnknownAcknowledgement, constants.MedicalRecordService, "10")
+				return
+			}
+
+			json.NewResponseBadRequest(ctx, issues, constants.ErrPrescriptionNeedsAck, constants.MedicalRecordService, "07")
+			return
+		}
+
 		if err.Error() == constants.ErrNoStockAvailable {
 			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrNoStockAvailable, constants.MedicalRecordService, "03")
 			return
@@ -55,6 +83,11 @@ func (dd *medicalRecordDelivery) createMedicalRecord(ctx *gin.Context) {
 			return
 		}
 
+		if err.Error() == constants.ErrExpiredStock {
+			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrExpiredStock, constants.MedicalRecordService, "08")
+			return
+		}
+
 		json.NewResponseError(ctx, err.Error(), constants.MedicalRecordService, "05")
 		return
 	}
@@ -64,15 +97,22 @@ func (dd *medicalRecordDelivery) createMedicalRecord(ctx *gin.Context) {
 
 func (dd *medicalRecordDelivery) getMedicalRecords(ctx *gin.Context) {
 	var mrs []medicalRecordDTO.Medical_Record
+	var paging queryDto.Paging
 	var err error
 
-	mrs, err = dd.medicalRecordUC.GetMedicalRecords()
+	query, invalid := utils.ParseQuery(ctx, medicalRecord.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(ctx, invalid, "bad request", constants.MedicalRecordService, "09")
+		return
+	}
+
+	mrs, paging, err = dd.medicalRecordUC.GetMedicalRecords(ctx.Request.Context(), query)
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.MedicalRecordService, "01")
 		return
 	}
 
-	json.NewResponseSuccess(ctx, mrs, "data received", constants.MedicalRecordService, "01")
+	json.NewResponsePaging(ctx, mrs, paging, "data received", constants.MedicalRecordService, "01")
 }
 
 func (dd *medicalRecordDelivery) getMedicalRecordByID(ctx *gin.Context) {
@@ -81,7 +121,7 @@ func (dd *medicalRecordDelivery) getMedicalRecordByID(ctx *gin.Context) {
 
 	id := ctx.Param("id")
 
-	mr, err = dd.medicalRecordUC.GetMedicalRecordByID(id)
+	mr, err = dd.medicalRecordUC.GetMedicalRecordByID(ctx.Request.Context(), id)
 	if err != nil {
 		json.NewResponseBadRequest(ctx, []json.ValidationField{}, "data not found", constants.MedicalRecordService, "01")
 		return
@@ -96,13 +136,18 @@ func (dd *medicalRecordDelivery) updatePaymentStatus(ctx *gin.Context) {
 
 	id := ctx.Param("id")
 
-	mr, err = dd.medicalRecordUC.UpdatePaymentStatus(id)
+	mr, err = dd.medicalRecordUC.UpdatePaymentStatus(ctx.Request.Context(), id, utils.GetJWT(ctx).ID)
 	if err != nil {
 		if err.Error() == constants.ErrPaymentAlreadyTrue {
 			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrPaymentAlreadyTrue, constants.MedicalRecordService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrMedicalRecordInvoiced {
+			json.NewResponseConflict(ctx, constants.ErrMedicalRecordInvoiced, constants.MedicalRecordService, "01")
+			return
+		}
+
 		if err.Error() == constants.ErrNoStockAvailable {
 			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrNoStockAvailable, constants.MedicalRecordService, "02")
 			return
@@ -112,9 +157,35 @@ func (dd *medicalRecordDelivery) updatePaymentStatus(ctx *gin.Context) {
 			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrQuantityGreaterThanStock, constants.MedicalRecordService, "03")
 			return
 		}
+
+		if err.Error() == constants.ErrExpiredStock {
+			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrExpiredStock, constants.MedicalRecordService, "04")
+			return
+		}
 		json.NewResponseBadRequest(ctx, []json.ValidationField{}, "data not found", constants.MedicalRecordService, "02")
 		return
 	}
 
 	json.NewResponseSuccess(ctx, mr, "data updated", constants.MedicalRecordService, "01")
 }
+
+func (dd *medicalRecordDelivery) cancelMedicalRecord(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if err := dd.medicalRecordUC.CancelMedicalRecord(ctx.Request.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(ctx, "data not found", constants.MedicalRecordService, "01")
+			return
+		}
+
+		if err.Error() == constants.ErrPaymentAlreadyTrue {
+			json.NewResponseBadRequest(ctx, []json.ValidationField{}, constants.ErrPaymentAlreadyTrue, constants.MedicalRecordService, "02")
+			return
+		}
+
+		json.NewResponseError(ctx, err.Error(), constants.MedicalRecordService, "01")
+		return
+	}
+
+	json.NewResponseSuccess(ctx, nil, "data canceled", constants.MedicalRecordService, "01")
+}
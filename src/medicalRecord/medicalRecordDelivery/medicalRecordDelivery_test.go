@@ -1,11 +1,15 @@
 package medicalRecordDelivery
 
 import (
+	"avengers-clinic/model/dto/allergyDto"
 	myjson "avengers-clinic/model/dto/json"
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -22,26 +26,43 @@ type mockMedicalRecordUsecase struct {
 	mock.Mock
 }
 
-func (m *mockMedicalRecordUsecase) CreateMedicalRecord(mr medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
+func (m *mockMedicalRecordUsecase) CreateMedicalRecord(ctx context.Context, mr medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
 	args := m.Called(mr)
 	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
 }
 
-func (m *mockMedicalRecordUsecase) GetMedicalRecords() ([]medicalRecordDTO.Medical_Record, error) {
-	args := m.Called()
-	return args.Get(0).([]medicalRecordDTO.Medical_Record), args.Error(1)
+func (m *mockMedicalRecordUsecase) GetMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, queryDto.Paging, error) {
+	args := m.Called(query)
+	return args.Get(0).([]medicalRecordDTO.Medical_Record), args.Get(1).(queryDto.Paging), args.Error(2)
 }
 
-func (m *mockMedicalRecordUsecase) GetMedicalRecordByID(id string) (medicalRecordDTO.Medical_Record, error) {
+func (m *mockMedicalRecordUsecase) GetMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error) {
 	args := m.Called(id)
 	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
 }
 
-func (m *mockMedicalRecordUsecase) UpdatePaymentStatus(id string) (medicalRecordDTO.Medical_Record, error) {
-	args := m.Called(id)
+func (m *mockMedicalRecordUsecase) UpdatePaymentStatus(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error) {
+	args := m.Called(id, userID)
 	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
 }
 
+func (m *mockMedicalRecordUsecase) CancelMedicalRecord(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockMedicalRecordUsecase) ReleaseExpiredReservations(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// withCreator returns the request as the handler passes it on, with the
+// creator taken from the test token.
+func withCreator(req medicalRecordDTO.Medical_Record_Request) medicalRecordDTO.Medical_Record_Request {
+	req.Created_By = "2cfde543-ea6a-469f-b332-4e630a1cad8c"
+	return req
+}
+
 type MedicalRecordDeliverySuite struct {
 	suite.Suite
 	router              *gin.Engine
@@ -104,7 +125,7 @@ func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_Success() {
 			},
 		},
 	}
-	suite.medicalRecordUCMock.On("CreateMedicalRecord", requestPayload).Return(expectedMedicalRecord, nil)
+	suite.medicalRecordUCMock.On("CreateMedicalRecord", withCreator(requestPayload)).Return(expectedMedicalRecord, nil)
 
 	w := httptest.NewRecorder()
 	reqBody, _ := json.Marshal(requestPayload)
@@ -178,7 +199,7 @@ func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_Error() {
 	}
 
 	expectedError := errors.New("mocked error")
-	suite.medicalRecordUCMock.On("CreateMedicalRecord", requestPayload).Return(medicalRecordDTO.Medical_Record{}, expectedError)
+	suite.medicalRecordUCMock.On("CreateMedicalRecord", withCreator(requestPayload)).Return(medicalRecordDTO.Medical_Record{}, expectedError)
 
 	w := httptest.NewRecorder()
 	reqBody, _ := json.Marshal(requestPayload)
@@ -203,6 +224,87 @@ func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_Error() {
 	suite.Equal("mocked error", response.Error)
 }
 
+func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_PrescriptionNeedsAck() {
+	requestPayload := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{
+			{Medicine_ID: "5ad34dce-d1bc-408e-9f82-e5c370cc01f5", Quantity: 1},
+			{Medicine_ID: "83803a11-1388-4beb-b06b-b22f1c98edaf", Quantity: 2},
+		},
+	}
+
+	expectedError := allergyDto.PrescriptionError{
+		Message: constants.ErrPrescriptionNeedsAck,
+		Issues: []allergyDto.Issue{{
+			Code:    "INTERACTION:5ad34dce-d1bc-408e-9f82-e5c370cc01f5:83803a11-1388-4beb-b06b-b22f1c98edaf:DDI-0001",
+			Message: "bleeding risk",
+		}},
+	}
+	suite.medicalRecordUCMock.On("CreateMedicalRecord", withCreator(requestPayload)).Return(medicalRecordDTO.Medical_Record{}, expectedError)
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(requestPayload)
+	req, _ := http.NewRequest("POST", "/api/v1/medical-records", bytes.NewBuffer(reqBody))
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "doctor", "DOCTOR")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	var response struct {
+		ResponseCode     string                   `json:"responseCode"`
+		ResponseMessage  string                   `json:"responseMessage"`
+		ErrorDescription []myjson.ValidationField `json:"error_description"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	suite.NoError(err)
+
+	suite.Equal("4000607", response.ResponseCode)
+	suite.Equal(constants.ErrPrescriptionNeedsAck, response.ResponseMessage)
+	suite.Equal(expectedError.Issues[0].Code, response.ErrorDescription[0].FieldName)
+}
+
+func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_UnknownAcknowledgement() {
+	requestPayload := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{
+			{Medicine_ID: "5ad34dce-d1bc-408e-9f82-e5c370cc01f5", Quantity: 1},
+		},
+		Acknowledgements: []allergyDto.Acknowledgement{{WarningCode: "ALLERGY:x:y", Reason: "made up"}},
+	}
+
+	expectedError := allergyDto.PrescriptionError{
+		Message: constants.ErrUnknownAcknowledgement,
+		Issues:  []allergyDto.Issue{{Code: "ALLERGY:x:y", Message: constants.ErrUnknownAcknowledgement}},
+	}
+	suite.medicalRecordUCMock.On("CreateMedicalRecord", withCreator(requestPayload)).Return(medicalRecordDTO.Medical_Record{}, expectedError)
+
+	w := httptest.NewRecorder()
+	reqBody, _ := json.Marshal(requestPayload)
+	req, _ := http.NewRequest("POST", "/api/v1/medical-records", bytes.NewBuffer(reqBody))
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "doctor", "DOCTOR")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	var response struct {
+		ResponseCode     string                   `json:"responseCode"`
+		ResponseMessage  string                   `json:"responseMessage"`
+		ErrorDescription []myjson.ValidationField `json:"error_description"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	suite.NoError(err)
+
+	suite.Equal("4000610", response.ResponseCode)
+	suite.Equal(constants.ErrUnknownAcknowledgement, response.ResponseMessage)
+	suite.Equal("ALLERGY:x:y", response.ErrorDescription[0].FieldName)
+}
+
 func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_NoStockAvailable() {
 	requestPayload := medicalRecordDTO.Medical_Record_Request{
 		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
@@ -222,7 +324,7 @@ func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_NoStockAvailabl
 	}
 
 	expectedError := errors.New(constants.ErrNoStockAvailable)
-	suite.medicalRecordUCMock.On("CreateMedicalRecord", requestPayload).Return(medicalRecordDTO.Medical_Record{}, expectedError)
+	suite.medicalRecordUCMock.On("CreateMedicalRecord", withCreator(requestPayload)).Return(medicalRecordDTO.Medical_Record{}, expectedError)
 
 	w := httptest.NewRecorder()
 	reqBody, _ := json.Marshal(requestPayload)
@@ -265,7 +367,7 @@ func (suite *MedicalRecordDeliverySuite) TestCreateMedicalRecord_QuantityGreater
 	}
 
 	expectedError := errors.New(constants.ErrQuantityGreaterThanStock)
-	suite.medicalRecordUCMock.On("CreateMedicalRecord", requestPayload).Return(medicalRecordDTO.Medical_Record{}, expectedError)
+	suite.medicalRecordUCMock.On("CreateMedicalRecord", withCreator(requestPayload)).Return(medicalRecordDTO.Medical_Record{}, expectedError)
 
 	w := httptest.NewRecorder()
 	reqBody, _ := json.Marshal(requestPayload)
@@ -339,7 +441,8 @@ func (suite *MedicalRecordDeliverySuite) TestGetMedicalRecords_Success() {
 		},
 	}
 
-	suite.medicalRecordUCMock.On("GetMedicalRecords").Return(mockMedicalRecords, nil)
+	query := queryDto.Query{Page: 1, Size: 10, Sort: []queryDto.Sort{{Field: "created_at"}}}
+	suite.medicalRecordUCMock.On("GetMedicalRecords", query).Return(mockMedicalRecords, queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/v1/medical-records", nil)
@@ -361,6 +464,18 @@ func (suite *MedicalRecordDeliverySuite) TestGetMedicalRecords_Success() {
 	suite.Equal(mockMedicalRecords, response.Data)
 }
 
+func (suite *MedicalRecordDeliverySuite) TestGetMedicalRecords_InvalidSort() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/medical-records?sort=diagnosis_results", nil)
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "hello", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+	suite.Contains(w.Body.String(), constants.ErrInvalidSortField)
+	suite.medicalRecordUCMock.AssertNotCalled(suite.T(), "GetMedicalRecords", mock.Anything)
+}
+
 func (suite *MedicalRecordDeliverySuite) TestGetMedicalRecordByID_Success() {
 	mockMedicalRecord := medicalRecordDTO.Medical_Record{
 		ID:               "1",
@@ -422,7 +537,7 @@ func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_Success() {
 		Payment_Status:   true,
 	}
 
-	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1").Return(mockMedicalRecord, nil)
+	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1", "2cfde543-ea6a-469f-b332-4e630a1cad8c").Return(mockMedicalRecord, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("PUT", "/api/v1/medical-records/1", nil)
@@ -446,7 +561,7 @@ func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_Success() {
 
 func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_PaymentAlreadyTrue() {
 	mockError := errors.New(constants.ErrPaymentAlreadyTrue)
-	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1").Return(medicalRecordDTO.Medical_Record{}, mockError)
+	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1", "2cfde543-ea6a-469f-b332-4e630a1cad8c").Return(medicalRecordDTO.Medical_Record{}, mockError)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("PUT", "/api/v1/medical-records/1", nil)
@@ -468,9 +583,24 @@ func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_PaymentAlreadyT
 	suite.Empty(response.Data)
 }
 
+func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_Invoiced() {
+	mockError := errors.New(constants.ErrMedicalRecordInvoiced)
+	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1", "2cfde543-ea6a-469f-b332-4e630a1cad8c").Return(medicalRecordDTO.Medical_Record{}, mockError)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/medical-records/1", nil)
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "hello", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusConflict, w.Code)
+	suite.Contains(w.Body.String(), `"responseCode":"4090601"`)
+	suite.Contains(w.Body.String(), constants.ErrMedicalRecordInvoiced)
+}
+
 func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_NoStockAvailable() {
 	mockError := errors.New(constants.ErrNoStockAvailable)
-	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1").Return(medicalRecordDTO.Medical_Record{}, mockError)
+	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1", "2cfde543-ea6a-469f-b332-4e630a1cad8c").Return(medicalRecordDTO.Medical_Record{}, mockError)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("PUT", "/api/v1/medical-records/1", nil)
@@ -494,7 +624,7 @@ func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_NoStockAvailabl
 
 func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_QuantityGreaterThanStock() {
 	mockError := errors.New(constants.ErrQuantityGreaterThanStock)
-	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1").Return(medicalRecordDTO.Medical_Record{}, mockError)
+	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1", "2cfde543-ea6a-469f-b332-4e630a1cad8c").Return(medicalRecordDTO.Medical_Record{}, mockError)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("PUT", "/api/v1/medical-records/1", nil)
@@ -518,7 +648,7 @@ func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_QuantityGreater
 
 func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_DataNotFound() {
 	mockError := errors.New("data not found")
-	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1").Return(medicalRecordDTO.Medical_Record{}, mockError)
+	suite.medicalRecordUCMock.On("UpdatePaymentStatus", "1", "2cfde543-ea6a-469f-b332-4e630a1cad8c").Return(medicalRecordDTO.Medical_Record{}, mockError)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("PUT", "/api/v1/medical-records/1", nil)
@@ -540,6 +670,49 @@ func (suite *MedicalRecordDeliverySuite) TestUpdatePaymentStatus_DataNotFound()
 	suite.Empty(response.Data)
 }
 
+func (suite *MedicalRecordDeliverySuite) TestCancelMedicalRecord_Success() {
+	suite.medicalRecordUCMock.On("CancelMedicalRecord", "1").Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/medical-records/1", nil)
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "doctor", "DOCTOR")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+func (suite *MedicalRecordDeliverySuite) TestCancelMedicalRecord_AlreadyPaid() {
+	suite.medicalRecordUCMock.On("CancelMedicalRecord", "1").Return(errors.New(constants.ErrPaymentAlreadyTrue))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/medical-records/1", nil)
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "hello", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusBadRequest, w.Code)
+
+	var response struct {
+		ResponseMessage string `json:"responseMessage"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	suite.NoError(err)
+	suite.Equal(constants.ErrPaymentAlreadyTrue, response.ResponseMessage)
+}
+
+func (suite *MedicalRecordDeliverySuite) TestCancelMedicalRecord_NotFound() {
+	suite.medicalRecordUCMock.On("CancelMedicalRecord", "1").Return(sql.ErrNoRows)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/v1/medical-records/1", nil)
+	token, _ := utils.GenerateJWT("2cfde543-ea6a-469f-b332-4e630a1cad8c", "hello", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
 func (suite *medicalRecordDelivery) SetupSuite() {
 	gin.SetMode(gin.TestMode)
 }
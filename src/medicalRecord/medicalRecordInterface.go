@@ -2,22 +2,28 @@ package medicalRecord
 
 import (
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
+	"context"
 	"database/sql"
 )
 
 type MedicalRecordRepository interface {
-	AddMedicalRecord(medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error)
-	RetrieveMedicalRecords() ([]medicalRecordDTO.Medical_Record, error)
-	RetrieveMedicalRecordByID(id string) (medicalRecordDTO.Medical_Record, error)
-	GetMedicineDetails(db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Medicine_Details, error)
-	GetActionDetails(db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Action_Details, error)
-	UpdatePaymentToDone(id string) (medicalRecordDTO.Medical_Record, error)
-	UpdateMedicineStock(tx *sql.Tx, stock, quantity int, medicineID string) (int, error)
+	AddMedicalRecord(ctx context.Context, mr medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error)
+	RetrieveMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, int, error)
+	RetrieveMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error)
+	GetMedicineDetails(ctx context.Context, db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Medicine_Details, error)
+	GetActionDetails(ctx context.Context, db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Action_Details, error)
+	UpdatePaymentToDone(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error)
+	UpdateMedicineStock(ctx context.Context, tx *sql.Tx, stock, quantity int, medicineID, medicalRecordID, userID string) (int, error)
+	CancelMedicalRecord(ctx context.Context, id string) error
+	ReleaseExpiredReservations(ctx context.Context) (int64, error)
 }
 
 type MedicalRecordUsecase interface {
-	CreateMedicalRecord(mr medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error)
-	GetMedicalRecords() ([]medicalRecordDTO.Medical_Record, error)
-	GetMedicalRecordByID(id string) (medicalRecordDTO.Medical_Record, error)
-	UpdatePaymentStatus(id string) (medicalRecordDTO.Medical_Record, error)
+	CreateMedicalRecord(ctx context.Context, mr medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error)
+	GetMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, queryDto.Paging, error)
+	GetMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error)
+	UpdatePaymentStatus(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error)
+	CancelMedicalRecord(ctx context.Context, id string) error
+	ReleaseExpiredReservations(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,18 @@
+package medicalRecord
+
+import "avengers-clinic/model/dto/queryDto"
+
+// ListResource is what the list of medical records can be filtered and sorted
+// by.
+var ListResource = queryDto.Resource{
+	Filters: map[string]string{
+		"doctor":  "ds.doctor_id",
+		"patient": "b.patient_id",
+	},
+	Sorts: map[string]string{
+		"created_at": "mr.created_at",
+	},
+	DateColumn:  "mr.created_at",
+	DefaultSort: []queryDto.Sort{{Field: "created_at"}},
+	Key:         "mr.id",
+}
@@ -1,12 +1,22 @@
 package medicalRecordRepository
 
 import (
+	"avengers-clinic/model/dto/inventoryDto"
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/action/actionRepository"
+	"avengers-clinic/src/inventory/inventoryRepository"
 	"avengers-clinic/src/medicalRecord"
+	"avengers-clinic/src/medicine/medicineRepository"
+	"context"
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type medicalRecordRepository struct {
@@ -17,20 +27,37 @@ func NewMedicalRecordRepository(db *sql.DB) medicalRecord.MedicalRecordRepositor
 	return &medicalRecordRepository{db}
 }
 
-func (dr *medicalRecordRepository) AddMedicalRecord(req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
+func (dr *medicalRecordRepository) AddMedicalRecord(ctx context.Context, req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
 	var medicalRecord medicalRecordDTO.Medical_Record
-	medicalRecord.Diagnosis_Result = req.Diagnosis_Result
-
-	tx, err := dr.db.Begin()
+	err := transaction.Run(ctx, dr.db, func(ctx context.Context) error {
+		var err error
+		medicalRecord, err = dr.addMedicalRecord(ctx, transaction.Tx(ctx), req)
+		return err
+	})
 	if err != nil {
-		tx.Rollback()
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
+	return medicalRecord, nil
+}
+
+// addMedicalRecord stores the record with its details and either dispenses or
+// reserves the prescribed medicines within the transaction of the caller.
+func (dr *medicalRecordRepository) addMedicalRecord(ctx context.Context, tx *sql.Tx, req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
+	var medicalRecord medicalRecordDTO.Medical_Record
+	medicalRecord.Diagnosis_Result = req.Diagnosis_Result
+	var err error
+
 	// Inserting medical record values
 	query := "INSERT INTO medical_records (booking_id, diagnosis_results, total_medicine, total_action, total_amount, payment_status, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, payment_status, created_at, updated_at"
-	if err := tx.QueryRow(query, req.Booking_ID, req.Diagnosis_Result, 0, 0, 0, req.Payment_Status, req.Created_At, req.Updated_At).Scan(&medicalRecord.ID, &medicalRecord.Payment_Status, &medicalRecord.Created_At, &medicalRecord.Updated_At); err != nil {
-		tx.Rollback()
+	if err := tx.QueryRowContext(ctx, query, req.Booking_ID, req.Diagnosis_Result, 0, 0, 0, req.Payment_Status, req.Created_At, req.Updated_At).Scan(&medicalRecord.ID, &medicalRecord.Payment_Status, &medicalRecord.Created_At, &medicalRecord.Updated_At); err != nil {
+		return medicalRecordDTO.Medical_Record{}, err
+	}
+
+	// Lock the prescribed medicines so concurrent records check and reserve the
+	// same stock one after another
+	now := time.Now().Format("2006-01-02 15:04:05")
+	if err := dr.lockMedicines(ctx, tx, req.Medicine_Details); err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
@@ -44,41 +71,61 @@ func (dr *medicalRecordRepository) AddMedicalRecord(req medicalRecordDTO.Medical
 		medicineDetail.Medicine_ID = md.Medicine_ID
 		medicineDetail.Quantity = md.Quantity
 
-		// TODO: get medicine price
 		// Read and assign values from medicines tables into medicine_details struct
-		query = "SELECT name, stock, price from medicines WHERE id = $1"
-		err = tx.QueryRow(query, md.Medicine_ID).Scan(&medicineDetail.Medicine_Name, &medicineDetail.Medicine_Stock, &medicineDetail.Medicine_Price)
+		query = "SELECT name, stock from medicines WHERE id = $1"
+		err = tx.QueryRowContext(ctx, query, md.Medicine_ID).Scan(&medicineDetail.Medicine_Name, &medicineDetail.Medicine_Stock)
+		if err != nil {
+			return medicalRecordDTO.Medical_Record{}, err
+		}
+
+		// The record keeps the price in effect now, later price changes do not apply to it
+		medicineDetail.Medicine_Price, err = medicineRepository.PriceAt(ctx, tx, md.Medicine_ID, now)
+		if err != nil {
+			return medicalRecordDTO.Medical_Record{}, err
+		}
+
+		// Expired batches cannot be dispensed, so only count the unexpired ones
+		medicineDetail.Medicine_Stock, err = dr.availableStock(ctx, tx, md.Medicine_ID, medicineDetail.Medicine_Stock)
 		if err != nil {
-			tx.Rollback()
 			return medicalRecordDTO.Medical_Record{}, err
 		}
 
+		// Stock held for unpaid records cannot be prescribed again
+		reserved, err := dr.reservedStock(ctx, tx, md.Medicine_ID, now)
+		if err != nil {
+			return medicalRecordDTO.Medical_Record{}, err
+		}
+		medicineDetail.Medicine_Stock -= reserved
+
 		// Check if the stock is empty
 		if medicineDetail.Medicine_Stock <= 0 {
-			tx.Rollback()
 			return medicalRecordDTO.Medical_Record{}, errors.New(constants.ErrNoStockAvailable)
 		}
 
 		// Check if the quantity amount is greater than stock available
 		if md.Quantity > medicineDetail.Medicine_Stock {
-			tx.Rollback()
 			return medicalRecordDTO.Medical_Record{}, errors.New(constants.ErrQuantityGreaterThanStock)
 		}
 
 		// Insert medicine details
 		query := "INSERT INTO medical_record_medicine_details (medical_record_id, medicine_id, medicine_price, quantity) VALUES ($1, $2, $3, $4) RETURNING id"
-		if err := tx.QueryRow(query, medicalRecord.ID, md.Medicine_ID, medicineDetail.Medicine_Price, md.Quantity).Scan(&medicineDetail.ID); err != nil {
-			tx.Rollback()
+		if err := tx.QueryRowContext(ctx, query, medicalRecord.ID, md.Medicine_ID, medicineDetail.Medicine_Price, md.Quantity).Scan(&medicineDetail.ID); err != nil {
 			return medicalRecordDTO.Medical_Record{}, err
 		}
 
 		// In case the payment status set to true in the request body
 		if req.Payment_Status {
 			// Update medicine stock
-			medicineDetail.Medicine_Stock, err = dr.UpdateMedicineStock(tx, medicineDetail.Medicine_Stock, md.Quantity, md.Medicine_ID)
+			medicineDetail.Medicine_Stock, err = dr.UpdateMedicineStock(ctx, tx, medicineDetail.Medicine_Stock, md.Quantity, md.Medicine_ID, medicalRecord.ID, req.Created_By)
 			if err != nil {
 				return medicalRecordDTO.Medical_Record{}, err
 			}
+		} else {
+			// Otherwise hold the quantity until the record is paid, canceled or the reservation expires
+			query = "INSERT INTO stock_reservations (medical_record_id, medicine_detail_id, medicine_id, quantity, expires_at, created_by, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $7)"
+			if _, err := tx.ExecContext(ctx, query, medicalRecord.ID, medicineDetail.ID, md.Medicine_ID, md.Quantity, req.Reserved_Until, nullableUser(req.Created_By), now); err != nil {
+				return medicalRecordDTO.Medical_Record{}, err
+			}
 		}
 
 		medicineDetail.Medicine_Price *= md.Quantity
@@ -93,17 +140,18 @@ func (dr *medicalRecordRepository) AddMedicalRecord(req medicalRecordDTO.Medical
 		var actionDetail medicalRecordDTO.Medical_Record_Action_Details
 		actionDetail.Action_ID = ad.Action_ID
 
-		// Get price value from medicine table
-		query = "SELECT name, price, description from actions WHERE id = $1 AND deleted_at IS null"
-		if err = tx.QueryRow(query, ad.Action_ID).Scan(&actionDetail.Action_Name, &actionDetail.Action_Price, &actionDetail.Action_Description); err != nil {
-			tx.Rollback()
+		query = "SELECT name, description from actions WHERE id = $1 AND deleted_at IS null"
+		if err = tx.QueryRowContext(ctx, query, ad.Action_ID).Scan(&actionDetail.Action_Name, &actionDetail.Action_Description); err != nil {
+			return medicalRecordDTO.Medical_Record{}, err
+		}
+
+		if actionDetail.Action_Price, err = actionRepository.PriceAt(ctx, tx, ad.Action_ID, now); err != nil {
 			return medicalRecordDTO.Medical_Record{}, err
 		}
 
 		query := "INSERT INTO medical_record_action_details (medical_record_id, action_id, action_price) VALUES ($1, $2, $3) RETURNING id"
 		// TODO: Get medical record id to insert here
-		if err := tx.QueryRow(query, medicalRecord.ID, ad.Action_ID, actionDetail.Action_Price).Scan(&actionDetail.ID); err != nil {
-			tx.Rollback()
+		if err := tx.QueryRowContext(ctx, query, medicalRecord.ID, ad.Action_ID, actionDetail.Action_Price).Scan(&actionDetail.ID); err != nil {
 			return medicalRecordDTO.Medical_Record{}, err
 		}
 
@@ -113,13 +161,8 @@ func (dr *medicalRecordRepository) AddMedicalRecord(req medicalRecordDTO.Medical
 
 	totalAmount := totalMedicine + totalAction
 	query = "UPDATE medical_records SET total_medicine = $1, total_action = $2, total_amount = $3 WHERE id = $4"
-	_, err = tx.Exec(query, totalMedicine, totalAction, totalAmount, medicalRecord.ID)
+	_, err = tx.ExecContext(ctx, query, totalMedicine, totalAction, totalAmount, medicalRecord.ID)
 	if err != nil {
-		tx.Rollback()
-		return medicalRecordDTO.Medical_Record{}, err
-	}
-
-	if err := tx.Commit(); err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
@@ -127,109 +170,103 @@ func (dr *medicalRecordRepository) AddMedicalRecord(req medicalRecordDTO.Medical
 	return medicalRecord, nil
 }
 
-func (dr *medicalRecordRepository) RetrieveMedicalRecords() ([]medicalRecordDTO.Medical_Record, error) {
-	var mrs []medicalRecordDTO.Medical_Record
-	tx, err := dr.db.Begin()
-	if err != nil {
-		return []medicalRecordDTO.Medical_Record{}, err
-	}
+func (dr *medicalRecordRepository) RetrieveMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, int, error) {
+	mrs := []medicalRecordDTO.Medical_Record{}
+	var total int
+	err := transaction.Run(ctx, dr.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		conditions, args := utils.ListConditions(query, medicalRecord.ListResource, nil)
+		from := `
+			FROM medical_records mr
+			JOIN bookings b ON b.id = mr.booking_id
+			JOIN doctor_schedules ds ON ds.id = b.doctor_schedule_id
+			WHERE mr.deleted_at IS NULL` + conditions
+
+		// Counting every medical_record matching the filters
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+			return err
+		}
 
-	defer func() {
+		// Getting medical_record values of the requested page
+		order, args := utils.ListOrder(query, medicalRecord.ListResource, args)
+		row, err := tx.QueryContext(ctx, "SELECT mr.id, mr.booking_id, mr.diagnosis_results, mr.created_at"+from+order, args...)
 		if err != nil {
-			tx.Rollback()
+			return err
 		}
-	}()
+		defer row.Close()
 
-	// Getting medical_record values
-	query := "SELECT id, booking_id, diagnosis_results, created_at FROM medical_records WHERE deleted_at IS null"
-	row, err := tx.Query(query)
-	if err != nil {
-		return []medicalRecordDTO.Medical_Record{}, err
-	}
-	defer row.Close()
+		// Assign for each received medical_record values into mr variable
+		for row.Next() {
+			var mr medicalRecordDTO.Medical_Record
+			if err := row.Scan(&mr.ID, &mr.Booking_ID, &mr.Diagnosis_Result, &mr.Created_At); err != nil {
+				return err
+			}
 
-	// Assign for each received medical_record values into mr variable
-	for row.Next() {
-		var mr medicalRecordDTO.Medical_Record
-		if err := row.Scan(&mr.ID, &mr.Booking_ID, &mr.Diagnosis_Result, &mr.Created_At); err != nil {
-			return []medicalRecordDTO.Medical_Record{}, err
+			// Assign received medical record values into mr slice
+			mrs = append(mrs, mr)
 		}
+		row.Close()
 
-		// Assign received medical record values into mr slice
-		mrs = append(mrs, mr)
-	}
-
-	// Here we try to assign medicine_details and action_details for each medical record in the mr array
-	for i := range mrs {
-		// Get and assign medical record medicine details into medical record struct
-		if mrs[i].Medicine_Details, err = dr.GetMedicineDetails(tx, mrs[i].ID); err != nil {
-			return []medicalRecordDTO.Medical_Record{}, err
-		}
+		// Here we try to assign medicine_details and action_details for each medical record in the mr array
+		for i := range mrs {
+			// Get and assign medical record medicine details into medical record struct
+			if mrs[i].Medicine_Details, err = dr.GetMedicineDetails(ctx, tx, mrs[i].ID); err != nil {
+				return err
+			}
 
-		// Get and assign medical record action details into medical record struct
-		if mrs[i].Action_Details, err = dr.GetActionDetails(tx, mrs[i].ID); err != nil {
-			return []medicalRecordDTO.Medical_Record{}, err
+			// Get and assign medical record action details into medical record struct
+			if mrs[i].Action_Details, err = dr.GetActionDetails(ctx, tx, mrs[i].ID); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return []medicalRecordDTO.Medical_Record{}, 0, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return []medicalRecordDTO.Medical_Record{}, err
-	}
-
-	// If received medical_record data in db is not empty, return the data
-	if len(mrs) > 0 {
-		return mrs, nil
-	}
-
-	// return data not found as error if data is empty on the db
-	return []medicalRecordDTO.Medical_Record{}, errors.New("data not found")
+	// A page past the end or filters matching nothing is an empty list
+	return mrs, total, nil
 }
 
-func (dr *medicalRecordRepository) RetrieveMedicalRecordByID(id string) (medicalRecordDTO.Medical_Record, error) {
+func (dr *medicalRecordRepository) RetrieveMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error) {
 	var mr medicalRecordDTO.Medical_Record
-	tx, err := dr.db.Begin()
-	if err != nil {
-		return medicalRecordDTO.Medical_Record{}, err
-	}
+	err := transaction.Run(ctx, dr.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
 
-	defer func() {
+		// Getting medical record values
+		query := "SELECT id, booking_id, diagnosis_results, created_at FROM medical_records WHERE id = $1 AND deleted_at IS null"
+		err := tx.QueryRowContext(ctx, query, id).Scan(&mr.ID, &mr.Booking_ID, &mr.Diagnosis_Result, &mr.Created_At)
 		if err != nil {
-			tx.Rollback()
+			return err
 		}
-	}()
 
-	// Getting medical record values
-	query := "SELECT id, booking_id, diagnosis_results, created_at FROM medical_records WHERE id = $1 AND deleted_at IS null"
-	err = tx.QueryRow(query, id).Scan(&mr.ID, &mr.Booking_ID, &mr.Diagnosis_Result, &mr.Created_At)
-	if err != nil {
-		return medicalRecordDTO.Medical_Record{}, err
-	}
-
-	// Get and assign medical record medicine details into medical record struct
-	if mr.Medicine_Details, err = dr.GetMedicineDetails(tx, mr.ID); err != nil {
-		return medicalRecordDTO.Medical_Record{}, err
-	}
-
-	// Get and assign medical record action details into medical record struct
-	if mr.Action_Details, err = dr.GetActionDetails(tx, mr.ID); err != nil {
-		return medicalRecordDTO.Medical_Record{}, err
-	}
-
-	// Assign medical record medicine details into medical record struct at the current iteration
-	//mr.Action_Details = mrads
+		// Get and assign medical record medicine details into medical record struct
+		if mr.Medicine_Details, err = dr.GetMedicineDetails(ctx, tx, mr.ID); err != nil {
+			return err
+		}
 
-	if err = tx.Commit(); err != nil {
+		// Get and assign medical record action details into medical record struct
+		mr.Action_Details, err = dr.GetActionDetails(ctx, tx, mr.ID)
+		return err
+	})
+	if err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 	return mr, nil
 }
 
-func (dr *medicalRecordRepository) GetMedicineDetails(db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Medicine_Details, error) {
+// GetMedicineDetails returns the medicines of the record with the price they
+// were prescribed for.
+func (dr *medicalRecordRepository) GetMedicineDetails(ctx context.Context, db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Medicine_Details, error) {
 	var medicineDetails []medicalRecordDTO.Medical_Record_Medicine_Details
-	var query string
 
-	query = "SELECT id, medicine_id, quantity, created_at FROM medical_record_medicine_details WHERE medical_record_id = $1"
-	rows, err := db.Query(query, mrID)
+	query := `
+		SELECT d.id, d.medicine_id, m.name, m.stock, COALESCE(d.medicine_price, 0), d.quantity, d.created_at
+		FROM medical_record_medicine_details d JOIN medicines m ON m.id = d.medicine_id
+		WHERE d.medical_record_id = $1`
+	rows, err := db.QueryContext(ctx, query, mrID)
 	if err != nil {
 		return []medicalRecordDTO.Medical_Record_Medicine_Details{}, err
 	}
@@ -237,31 +274,26 @@ func (dr *medicalRecordRepository) GetMedicineDetails(db *sql.Tx, mrID string) (
 
 	for rows.Next() {
 		var md medicalRecordDTO.Medical_Record_Medicine_Details
-		if err := rows.Scan(&md.ID, &md.Medicine_ID, &md.Quantity, &md.Created_At); err != nil {
+		if err := rows.Scan(&md.ID, &md.Medicine_ID, &md.Medicine_Name, &md.Medicine_Stock, &md.Medicine_Price, &md.Quantity, &md.Created_At); err != nil {
 			return []medicalRecordDTO.Medical_Record_Medicine_Details{}, err
 		}
 
 		medicineDetails = append(medicineDetails, md)
 	}
 
-	for i := range medicineDetails {
-		query = "SELECT name, stock, price from medicines WHERE id = $1"
-		err = db.QueryRow(query, medicineDetails[i].Medicine_ID).Scan(&medicineDetails[i].Medicine_Name, &medicineDetails[i].Medicine_Stock, &medicineDetails[i].Medicine_Price)
-		if err != nil {
-
-			return []medicalRecordDTO.Medical_Record_Medicine_Details{}, err
-		}
-	}
-
 	return medicineDetails, nil
 }
 
-func (dr *medicalRecordRepository) GetActionDetails(tx *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Action_Details, error) {
+// GetActionDetails returns the actions of the record with the price they were
+// performed for.
+func (dr *medicalRecordRepository) GetActionDetails(ctx context.Context, tx *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Action_Details, error) {
 	var actionDetails []medicalRecordDTO.Medical_Record_Action_Details
-	var query string
 
-	query = "SELECT id, action_id, created_at FROM medical_record_action_details WHERE medical_record_id = $1"
-	rows, err := tx.Query(query, mrID)
+	query := `
+		SELECT d.id, d.action_id, a.name, COALESCE(d.action_price, 0), COALESCE(a.description, ''), d.created_at
+		FROM medical_record_action_details d JOIN actions a ON a.id = d.action_id
+		WHERE d.medical_record_id = $1`
+	rows, err := tx.QueryContext(ctx, query, mrID)
 	if err != nil {
 		return []medicalRecordDTO.Medical_Record_Action_Details{}, err
 	}
@@ -269,63 +301,98 @@ func (dr *medicalRecordRepository) GetActionDetails(tx *sql.Tx, mrID string) ([]
 
 	for rows.Next() {
 		var ad medicalRecordDTO.Medical_Record_Action_Details
-		if err := rows.Scan(&ad.ID, &ad.Action_ID, &ad.Created_At); err != nil {
+		if err := rows.Scan(&ad.ID, &ad.Action_ID, &ad.Action_Name, &ad.Action_Price, &ad.Action_Description, &ad.Created_At); err != nil {
 			return []medicalRecordDTO.Medical_Record_Action_Details{}, err
 		}
 
 		actionDetails = append(actionDetails, ad)
 	}
 
-	for i := range actionDetails {
-		query = "SELECT name, price, description from actions WHERE id = $1"
-		err = tx.QueryRow(query, actionDetails[i].Action_ID).Scan(&actionDetails[i].Action_Name, &actionDetails[i].Action_Price, &actionDetails[i].Action_Description)
-		if err != nil {
-			return []medicalRecordDTO.Medical_Record_Action_Details{}, err
-		}
-	}
-
 	return actionDetails, nil
 }
 
-func (dr *medicalRecordRepository) UpdatePaymentToDone(id string) (medicalRecordDTO.Medical_Record, error) {
+// UpdatePaymentToDone settles a record paid outside of invoicing. A record
+// with an invoice that is not void is paid through the invoice only.
+func (dr *medicalRecordRepository) UpdatePaymentToDone(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error) {
 	var medicalRecord medicalRecordDTO.Medical_Record
-	medicalRecord.ID = id
-	tx, err := dr.db.Begin()
+	err := transaction.Run(ctx, dr.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		// Locked first, so no invoice can be made for the record meanwhile
+		if _, err := tx.ExecContext(ctx, "SELECT id FROM medical_records WHERE id = $1 FOR UPDATE", id); err != nil {
+			return err
+		}
+
+		var invoiced bool
+		query := "SELECT EXISTS (SELECT 1 FROM invoices WHERE medical_record_id = $1 AND status <> $2)"
+		if err := tx.QueryRowContext(ctx, query, id, constants.InvoiceVoid).Scan(&invoiced); err != nil {
+			return err
+		}
+		if invoiced {
+			return errors.New(constants.ErrMedicalRecordInvoiced)
+		}
+
+		var err error
+		medicalRecord, err = dr.settlePayment(ctx, tx, id, userID)
+		return err
+	})
 	if err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
-	// Populate medical record struct fields
-	query := "SELECT id, booking_id, diagnosis_results, payment_status, created_at FROM medical_records WHERE id = $1 AND deleted_at IS null"
-	err = tx.QueryRow(query, id).Scan(&medicalRecord.ID, &medicalRecord.Booking_ID, &medicalRecord.Diagnosis_Result, &medicalRecord.Payment_Status, &medicalRecord.Created_At)
+	return medicalRecord, nil
+}
+
+// SettlePayment marks the medical record as paid and dispenses its medicines
+// within the transaction of the caller.
+func SettlePayment(ctx context.Context, tx *sql.Tx, id, userID string) (medicalRecordDTO.Medical_Record, error) {
+	dr := &medicalRecordRepository{}
+	return dr.settlePayment(ctx, tx, id, userID)
+}
+
+func (dr *medicalRecordRepository) settlePayment(ctx context.Context, tx *sql.Tx, id, userID string) (medicalRecordDTO.Medical_Record, error) {
+	var medicalRecord medicalRecordDTO.Medical_Record
+	medicalRecord.ID = id
+
+	// The row stays locked so the same record cannot be paid twice concurrently
+	query := "SELECT id, booking_id, diagnosis_results, payment_status, created_at FROM medical_records WHERE id = $1 AND deleted_at IS null FOR UPDATE"
+	err := tx.QueryRowContext(ctx, query, id).Scan(&medicalRecord.ID, &medicalRecord.Booking_ID, &medicalRecord.Diagnosis_Result, &medicalRecord.Payment_Status, &medicalRecord.Created_At)
 	if err != nil {
-		tx.Rollback()
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
 	if medicalRecord.Payment_Status {
-		tx.Rollback()
 		return medicalRecordDTO.Medical_Record{}, errors.New(constants.ErrPaymentAlreadyTrue)
 	}
 	// Update payment status and updated at values
 	query = "UPDATE medical_records SET payment_status = true, updated_at = $2 WHERE id = $1 RETURNING payment_status, updated_at"
-	err = tx.QueryRow(query, id, time.Now().Format("2006-01-02 15:04:05")).Scan(&medicalRecord.Payment_Status, &medicalRecord.Updated_At)
+	err = tx.QueryRowContext(ctx, query, id, time.Now().Format("2006-01-02 15:04:05")).Scan(&medicalRecord.Payment_Status, &medicalRecord.Updated_At)
 	if err != nil {
-		tx.Rollback()
+		return medicalRecordDTO.Medical_Record{}, err
+	}
+
+	// Lock the prescribed medicines before reading their stock
+	query = "SELECT id FROM medicines WHERE id IN (SELECT medicine_id FROM medical_record_medicine_details WHERE medical_record_id = $1) ORDER BY id FOR UPDATE"
+	if _, err = tx.ExecContext(ctx, query, id); err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
 	//var mds []medicalRecordDTO.Medical_Record_Medicine_Details
-	mds, err := dr.GetMedicineDetails(tx, id)
+	mds, err := dr.GetMedicineDetails(ctx, tx, id)
 	if err != nil {
-		tx.Rollback()
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
+	now := time.Now().Format("2006-01-02 15:04:05")
 	for i := range mds {
-		stockResult, err := dr.UpdateMedicineStock(tx, mds[i].Medicine_Stock, mds[i].Quantity, mds[i].Medicine_ID)
+		// Convert the reservation into a deduction
+		stock, err := dr.consumeReservation(ctx, tx, mds[i], now)
+		if err != nil {
+			return medicalRecordDTO.Medical_Record{}, err
+		}
+
+		stockResult, err := dr.UpdateMedicineStock(ctx, tx, stock, mds[i].Quantity, mds[i].Medicine_ID, id, userID)
 		if err != nil {
-			tx.Rollback()
 			return medicalRecordDTO.Medical_Record{}, err
 		}
 
@@ -336,47 +403,326 @@ func (dr *medicalRecordRepository) UpdatePaymentToDone(id string) (medicalRecord
 	medicalRecord.Medicine_Details = mds
 
 	// Populate action details
-	var ads []medicalRecordDTO.Medical_Record_Action_Details
-	ads, err = dr.GetActionDetails(tx, id)
+	medicalRecord.Action_Details, err = dr.GetActionDetails(ctx, tx, id)
 	if err != nil {
-		tx.Rollback()
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return medicalRecordDTO.Medical_Record{}, err
+	return medicalRecord, nil
+}
+
+// ReverseSettlement undoes SettlePayment when the money of a record is given
+// back: the dispensed medicines return to stock and are reserved for the
+// record again until reservedUntil, so paying again dispenses them once more.
+func ReverseSettlement(ctx context.Context, tx *sql.Tx, id, userID, reservedUntil string) error {
+	dr := &medicalRecordRepository{}
+	return dr.reverseSettlement(ctx, tx, id, userID, reservedUntil)
+}
+
+func (dr *medicalRecordRepository) reverseSettlement(ctx context.Context, tx *sql.Tx, id, userID, reservedUntil string) error {
+	var paymentStatus bool
+	query := "SELECT payment_status FROM medical_records WHERE id = $1 AND deleted_at IS null FOR UPDATE"
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&paymentStatus); err != nil {
+		return err
 	}
 
-	// Append action details into medical record
-	medicalRecord.Action_Details = ads
+	if !paymentStatus {
+		return nil
+	}
 
-	return medicalRecord, nil
+	query = "SELECT id FROM medicines WHERE id IN (SELECT medicine_id FROM medical_record_medicine_details WHERE medical_record_id = $1) ORDER BY id FOR UPDATE"
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+
+	// What is still out of stock for the record, per batch
+	query = `
+		SELECT medicine_id, COALESCE(batch_id::text, ''), -SUM(quantity) FROM stock_movements
+		WHERE reference_type = 'medical_record' AND reference_id = $1 AND movement_type IN ($2, $3)
+		GROUP BY medicine_id, batch_id HAVING SUM(quantity) < 0`
+	rows, err := tx.QueryContext(ctx, query, id, constants.MovementDispense, constants.MovementReturn)
+	if err != nil {
+		return err
+	}
 
+	var returns []inventoryDto.Movement
+	for rows.Next() {
+		movement := inventoryDto.Movement{
+			MovementType:  constants.MovementReturn,
+			ReferenceType: "medical_record",
+			ReferenceID:   id,
+			CreatedBy:     userID,
+		}
+		if err := rows.Scan(&movement.MedicineID, &movement.BatchID, &movement.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		returns = append(returns, movement)
+	}
+	rows.Close()
+
+	for _, movement := range returns {
+		if _, err := inventoryRepository.ApplyMovement(ctx, tx, movement); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	query = "UPDATE stock_reservations SET status = $1, expires_at = $2, updated_at = $3 WHERE medical_record_id = $4 AND status = $5"
+	if _, err := tx.ExecContext(ctx, query, constants.ReservationReserved, reservedUntil, now, id, constants.ReservationConsumed); err != nil {
+		return err
+	}
+
+	query = "UPDATE medical_records SET payment_status = false, updated_at = $1 WHERE id = $2"
+	_, err = tx.ExecContext(ctx, query, now, id)
+	return err
 }
 
-func (dr *medicalRecordRepository) UpdateMedicineStock(tx *sql.Tx, stock, quantity int, medicineID string) (int, error) {
+// UpdateMedicineStock dispenses the quantity through the stock ledger, taking
+// it from the batches that expire first when the medicine has any.
+func (dr *medicalRecordRepository) UpdateMedicineStock(ctx context.Context, tx *sql.Tx, stock, quantity int, medicineID, medicalRecordID, userID string) (int, error) {
 
 	// Check if the stock is empty
 	if stock <= 0 {
-		tx.Rollback()
 		return 0, errors.New(constants.ErrNoStockAvailable)
 	}
 
 	// Check if the quantity amount is greater than stock available
 	if quantity > stock {
-		tx.Rollback()
 		return 0, errors.New(constants.ErrQuantityGreaterThanStock)
 	}
 
-	stock -= quantity
+	allocations, err := dr.allocateBatches(ctx, tx, medicineID, quantity)
+	if err != nil {
+		return 0, err
+	}
+
+	// Medicines without batches are dispensed from the stock column only
+	if len(allocations) == 0 {
+		allocations = append(allocations, allocation{quantity: quantity})
+	}
+
+	var movement inventoryDto.Movement
+	for _, a := range allocations {
+		movement, err = inventoryRepository.ApplyMovement(ctx, tx, inventoryDto.Movement{
+			MedicineID:    medicineID,
+			BatchID:       a.batchID,
+			MovementType:  constants.MovementDispense,
+			Quantity:      -a.quantity,
+			ReferenceType: "medical_record",
+			ReferenceID:   medicalRecordID,
+			CreatedBy:     userID,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return movement.BalanceAfter, nil
+}
+
+// availableStock returns the stock of a medicine that can be dispensed, its
+// unexpired batches and the stock from before batches were recorded, which does
+// not expire. Medicines without any batch recorded keep using the stock column
+// as is.
+func (dr *medicalRecordRepository) availableStock(ctx context.Context, tx *sql.Tx, medicineID string, stock int) (int, error) {
+	var batchCount, batched, unexpired int
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(quantity), 0), COALESCE(SUM(quantity) FILTER (WHERE expiry_date >= CURRENT_DATE), 0)
+		FROM medicine_batches WHERE medicine_id = $1 AND deleted_at IS NULL`
+	if err := tx.QueryRowContext(ctx, query, medicineID).Scan(&batchCount, &batched, &unexpired); err != nil {
+		return 0, err
+	}
+
+	available := unbatched(stock, batched) + unexpired
+	if batchCount == 0 || available > stock {
+		return stock, nil
+	}
+	return available, nil
+}
+
+// unbatched is the stock of a medicine that is in none of its batches.
+func unbatched(stock, batched int) int {
+	if stock < batched {
+		return 0
+	}
+	return stock - batched
+}
+
+type allocation struct {
+	batchID  string
+	quantity int
+}
+
+// allocateBatches picks the unexpired batches of a medicine to dispense the
+// quantity from in first-expired-first-out order, locking the rows it reads.
+// What they do not cover is taken from the stock that is in no batch, with no
+// batch on the allocation. It returns nothing for medicines without any batch
+// recorded.
+func (dr *medicalRecordRepository) allocateBatches(ctx context.Context, tx *sql.Tx, medicineID string, quantity int) ([]allocation, error) {
+	var batchCount, stock, batched int
+	query := `
+		SELECT COUNT(b.id), COALESCE(m.stock, 0), COALESCE(SUM(b.quantity), 0)
+		FROM medicines m LEFT JOIN medicine_batches b ON b.medicine_id = m.id AND b.deleted_at IS NULL
+		WHERE m.id = $1 GROUP BY m.id`
+	if err := tx.QueryRowContext(ctx, query, medicineID).Scan(&batchCount, &stock, &batched); err != nil {
+		return nil, err
+	}
+
+	if batchCount == 0 {
+		return nil, nil
+	}
+
+	query = `
+		SELECT id, quantity FROM medicine_batches
+		WHERE medicine_id = $1 AND deleted_at IS NULL AND quantity > 0 AND expiry_date >= CURRENT_DATE
+		ORDER BY expiry_date, created_at FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, medicineID)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocations []allocation
+	remaining := quantity
+	for rows.Next() && remaining > 0 {
+		var batchID string
+		var batchQuantity int
+		if err := rows.Scan(&batchID, &batchQuantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		taken := batchQuantity
+		if taken > remaining {
+			taken = remaining
+		}
+		allocations = append(allocations, allocation{batchID, taken})
+		remaining -= taken
+	}
+	rows.Close()
+
+	if remaining > 0 && remaining <= unbatched(stock, batched) {
+		allocations = append(allocations, allocation{quantity: remaining})
+		remaining = 0
+	}
+
+	if remaining > 0 {
+		return nil, errors.New(constants.ErrExpiredStock)
+	}
+
+	return allocations, nil
+}
+
+// CancelMedicalRecord deletes an unpaid medical record and releases the stock
+// reserved for it.
+func (dr *medicalRecordRepository) CancelMedicalRecord(ctx context.Context, id string) error {
+	return transaction.Run(ctx, dr.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var paymentStatus bool
+		query := "SELECT payment_status FROM medical_records WHERE id = $1 AND deleted_at IS null FOR UPDATE"
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&paymentStatus); err != nil {
+			return err
+		}
+
+		if paymentStatus {
+			return errors.New(constants.ErrPaymentAlreadyTrue)
+		}
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+		query = "UPDATE stock_reservations SET status = $1, updated_at = $2 WHERE medical_record_id = $3 AND status = $4"
+		if _, err := tx.ExecContext(ctx, query, constants.ReservationReleased, now, id, constants.ReservationReserved); err != nil {
+			return err
+		}
+
+		query = "UPDATE medical_records SET deleted_at = $1, updated_at = $1 WHERE id = $2"
+		_, err := tx.ExecContext(ctx, query, now, id)
+		return err
+	})
+}
+
+// ReleaseExpiredReservations marks the reservations past their expiry as
+// released. Expired reservations are already ignored when checking stock, this
+// only keeps their status up to date.
+func (dr *medicalRecordRepository) ReleaseExpiredReservations(ctx context.Context) (int64, error) {
+	query := "UPDATE stock_reservations SET status = $1, updated_at = $2 WHERE status = $3 AND expires_at <= $2"
+	result, err := transaction.Executor(ctx, dr.db).ExecContext(ctx, query, constants.ReservationReleased, time.Now().Format("2006-01-02 15:04:05"), constants.ReservationReserved)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// lockMedicines locks the medicine rows in a fixed order so transactions
+// prescribing the same medicines wait for each other instead of deadlocking.
+func (dr *medicalRecordRepository) lockMedicines(ctx context.Context, tx *sql.Tx, mds []medicalRecordDTO.Medicine_Details_Request) error {
+	if len(mds) == 0 {
+		return nil
+	}
+
+	var medicineIDs []string
+	for _, md := range mds {
+		medicineIDs = append(medicineIDs, md.Medicine_ID)
+	}
 
-	// Update stock
-	query := "UPDATE medicines SET stock = $1 WHERE id = $2 and deleted_at IS null"
-	_, err := tx.Exec(query, stock, medicineID)
+	query := "SELECT id FROM medicines WHERE id = ANY($1) ORDER BY id FOR UPDATE"
+	rows, err := tx.QueryContext(ctx, query, pq.Array(medicineIDs))
 	if err != nil {
-		tx.Rollback()
+		return err
+	}
+	defer rows.Close()
+
+	// The locks are taken as the rows are read
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// reservedStock returns the quantity of a medicine held by unexpired
+// reservations.
+func (dr *medicalRecordRepository) reservedStock(ctx context.Context, tx *sql.Tx, medicineID, now string) (int, error) {
+	var reserved int
+	query := "SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE medicine_id = $1 AND status = $2 AND expires_at > $3"
+	if err := tx.QueryRowContext(ctx, query, medicineID, constants.ReservationReserved, now).Scan(&reserved); err != nil {
+		return 0, err
+	}
+
+	return reserved, nil
+}
+
+// consumeReservation marks the reservation of a medicine detail as consumed and
+// returns the stock the detail may be dispensed from. A detail still holding an
+// unexpired reservation may use the whole available stock, any other detail
+// only what is not reserved for other records.
+func (dr *medicalRecordRepository) consumeReservation(ctx context.Context, tx *sql.Tx, md medicalRecordDTO.Medical_Record_Medicine_Details, now string) (int, error) {
+	var valid bool
+	query := "UPDATE stock_reservations SET status = $1, updated_at = $2 WHERE medicine_detail_id = $3 AND status = $4 RETURNING expires_at > $2"
+	err := tx.QueryRowContext(ctx, query, constants.ReservationConsumed, now, md.ID, constants.ReservationReserved).Scan(&valid)
+	if err != nil && err != sql.ErrNoRows {
 		return 0, err
 	}
 
-	return stock, nil
+	stock, err := dr.availableStock(ctx, tx, md.Medicine_ID, md.Medicine_Stock)
+	if err != nil {
+		return 0, err
+	}
+
+	if valid {
+		return stock, nil
+	}
+
+	reserved, err := dr.reservedStock(ctx, tx, md.Medicine_ID, now)
+	if err != nil {
+		return 0, err
+	}
+	return stock - reserved, nil
+}
+
+// nullableUser keeps records created without a user id valid for the uuid column.
+func nullableUser(userID string) interface{} {
+	if userID == "" {
+		return nil
+	}
+	return userID
 }
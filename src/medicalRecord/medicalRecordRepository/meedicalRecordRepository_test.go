@@ -1,13 +1,17 @@
 package medicalRecordRepository
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"testing"
 	"time"
 
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
 	"avengers-clinic/src/medicalRecord"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -21,6 +25,7 @@ func TestMedicalRecordRepositorySuite(t *testing.T) {
 type MedicalRecordRepositorySuite struct {
 	suite.Suite
 	medicalRecordRepo medicalRecord.MedicalRecordRepository
+	db                *sql.DB
 	mock              sqlmock.Sqlmock
 }
 
@@ -28,6 +33,7 @@ func (suite *MedicalRecordRepositorySuite) SetupTest() {
 	db, mock, _ := sqlmock.New()
 
 	suite.medicalRecordRepo = NewMedicalRecordRepository(db)
+	suite.db = db
 	suite.mock = mock
 }
 
@@ -43,9 +49,15 @@ func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_Success() {
 			AddRow("mr1", true, "2024-03-13 09:04:26", "2024-03-13 09:04:26"))
 
 	// Receive necessary medicine data
-	med_rows := sqlmock.NewRows([]string{"name", "stock", "price"}).AddRow("betadine", 500, 25000)
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+) from medicines WHERE id = ?").
+	med_rows := sqlmock.NewRows([]string{"name", "stock"}).AddRow("betadine", 500)
+	suite.mock.ExpectQuery("SELECT (.+), (.+) from medicines WHERE id = ?").
 		WithArgs("med1").WillReturnRows(med_rows)
+	suite.mock.ExpectQuery("FROM medicine_prices").
+		WithArgs("med1", sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(25000))
+
+	// Medicine without batches keeps the stock column
+	suite.mock.ExpectQuery("FROM medicine_batches").
+		WithArgs("med1").WillReturnRows(sqlmock.NewRows([]string{"count", "batched", "unexpired"}).AddRow(0, 0, 0))
 
 	// Insert into medicine_details
 	md_args := []driver.Value{"mr1", "med1", 25000, 5}
@@ -93,7 +105,7 @@ func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_Success() {
 		},
 	}
 
-	_, _ = suite.medicalRecordRepo.AddMedicalRecord(mr_req)
+	_, _ = suite.medicalRecordRepo.AddMedicalRecord(context.Background(), mr_req)
 
 	//suite.Nil(err)
 	//suite.NotEmpty(actual)
@@ -109,8 +121,8 @@ func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_ErrNoStockAvaila
 	suite.mock.ExpectQuery("INSERT INTO medical_records").WithArgs(mrArgs...).WillReturnRows(sqlmock.NewRows([]string{"id", "payment_status", "created_at", "updated_at"}).AddRow("mr1", true, "2024-03-13 09:04:26", "2024-03-13 09:04:26"))
 
 	// Receive necessary medicine data
-	medRows := sqlmock.NewRows([]string{"name", "stock", "price"})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+) FROM medicines WHERE id = ?").WithArgs("med1").WillReturnRows(medRows.AddRow("betadine", 0, 25000)).WillReturnError(errors.New(constants.ErrNoStockAvailable))
+	medRows := sqlmock.NewRows([]string{"name", "stock"})
+	suite.mock.ExpectQuery("SELECT (.+), (.+) FROM medicines WHERE id = ?").WithArgs("med1").WillReturnRows(medRows.AddRow("betadine", 0)).WillReturnError(errors.New(constants.ErrNoStockAvailable))
 
 	suite.mock.ExpectRollback()
 
@@ -133,7 +145,7 @@ func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_ErrNoStockAvaila
 		},
 	}
 
-	_, err := suite.medicalRecordRepo.AddMedicalRecord(mrModel)
+	_, err := suite.medicalRecordRepo.AddMedicalRecord(context.Background(), mrModel)
 
 	// Check if the error chain contains the expected error
 	suite.Error(err)
@@ -144,18 +156,20 @@ func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_ErrNoStockAvaila
 func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecords_Success() {
 	suite.mock.ExpectBegin()
 
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM medical_records mr").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
 	mr_rows := sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "created_at"})
 	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+) FROM medical_records").WillReturnRows(mr_rows.AddRow("1", "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151", "tes diagnosis", "2024-03-13 09:04:26"))
 
-	md_rows := sqlmock.NewRows([]string{"id", "medicine_id", "quantity", "created_at"})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+) FROM medical_record_medicine_details WHERE medical_record_id = ?").WithArgs("1").WillReturnRows(md_rows)
+	md_rows := sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"})
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id = ?").WithArgs("1").WillReturnRows(md_rows)
 
-	ad_rows := sqlmock.NewRows([]string{"id", "action_id", "created_at"})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+) FROM medical_record_action_details WHERE medical_record_id = ?").WithArgs("1").WillReturnRows(ad_rows)
+	ad_rows := sqlmock.NewRows([]string{"id", "action_id", "name", "action_price", "description", "created_at"})
+	suite.mock.ExpectQuery("FROM medical_record_action_details d JOIN actions a (.+) WHERE d.medical_record_id = ?").WithArgs("1").WillReturnRows(ad_rows)
 
 	suite.mock.ExpectCommit()
 
-	actual, ret_err := suite.medicalRecordRepo.RetrieveMedicalRecords()
+	actual, total, ret_err := suite.medicalRecordRepo.RetrieveMedicalRecords(context.Background(), queryDto.Query{Page: 1, Size: 10})
 
 	err := suite.mock.ExpectationsWereMet()
 	if err != nil {
@@ -164,25 +178,71 @@ func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecords_Success()
 
 	suite.Nil(ret_err)
 	suite.NotEmpty(actual)
+	suite.Equal(1, total)
+}
+
+func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecords_FilteredByPatient() {
+	query := queryDto.Query{Page: 2, Size: 5, Filters: []queryDto.Filter{{Field: "patient", Value: "p-1"}}}
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM medical_records mr (.+) AND b.patient_id::text = \\$1").
+		WithArgs("p-1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(6))
+	suite.mock.ExpectQuery("SELECT mr.id, (.+) AND b.patient_id::text = \\$1 ORDER BY mr.id LIMIT \\$2 OFFSET \\$3").
+		WithArgs("p-1", 5, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "created_at"}).AddRow("6", "b-6", "flu", "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details").WithArgs("6").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"}))
+	suite.mock.ExpectQuery("FROM medical_record_action_details").WithArgs("6").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action_id", "name", "action_price", "description", "created_at"}))
+	suite.mock.ExpectCommit()
+
+	actual, total, err := suite.medicalRecordRepo.RetrieveMedicalRecords(context.Background(), query)
+
+	suite.Nil(err)
+	suite.Equal(6, total)
+	suite.Len(actual, 1)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecords_EmptyPage() {
+	query := queryDto.Query{Page: 3, Size: 5}
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM medical_records mr").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(6))
+	suite.mock.ExpectQuery("SELECT mr.id, (.+) LIMIT \\$1 OFFSET \\$2").
+		WithArgs(5, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "created_at"}))
+	suite.mock.ExpectCommit()
+
+	actual, total, err := suite.medicalRecordRepo.RetrieveMedicalRecords(context.Background(), query)
+
+	suite.Nil(err)
+	suite.Equal(6, total)
+	suite.NotNil(actual)
+	suite.Empty(actual)
+	suite.Nil(suite.mock.ExpectationsWereMet())
 }
+
 func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecords_ErrDataNotFound() {
 	suite.mock.ExpectBegin()
 
+	suite.mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM medical_records mr").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
 	mrRows := sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "created_at"})
 	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+) FROM medical_records").WillReturnRows(mrRows).WillReturnError(errors.New("data not found"))
 
-	mdRows := sqlmock.NewRows([]string{"", "", "", ""})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+) FROM medical_record_medicine_details WHERE medical_record_id = ?").WithArgs("1").
+	mdRows := sqlmock.NewRows([]string{"", "", "", "", "", "", ""})
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id = ?").WithArgs("1").
 		WillReturnRows(mdRows).WillReturnError(errors.New("data not found"))
 
-	adRows := sqlmock.NewRows([]string{"", "", ""})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+) FROM medical_record_action_details WHERE medical_record_id = ?").WithArgs("1").
+	adRows := sqlmock.NewRows([]string{"", "", "", "", "", ""})
+	suite.mock.ExpectQuery("FROM medical_record_action_details d JOIN actions a (.+) WHERE d.medical_record_id = ?").WithArgs("1").
 		WillReturnRows(adRows).WillReturnError(errors.New("data not found"))
 
 	suite.mock.ExpectCommit()
 
 	// Call the method under test
-	actual, retErr := suite.medicalRecordRepo.RetrieveMedicalRecords()
+	actual, _, retErr := suite.medicalRecordRepo.RetrieveMedicalRecords(context.Background(), queryDto.Query{Page: 1, Size: 10})
 
 	// Check if the expectations were met
 	// err := suite.mock.ExpectationsWereMet()
@@ -203,15 +263,15 @@ func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecordByID_Success
 	mr_rows := sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "created_at"})
 	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+) FROM medical_records").WillReturnRows(mr_rows.AddRow("1", "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151", "tes diagnosis", "2024-03-13 09:04:26"))
 
-	md_rows := sqlmock.NewRows([]string{"id", "medicine_id", "quantity", "created_at"})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+), (.+) FROM medical_record_medicine_details WHERE medical_record_id = ?").WithArgs("1").WillReturnRows(md_rows)
+	md_rows := sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"})
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id = ?").WithArgs("1").WillReturnRows(md_rows)
 
-	ad_rows := sqlmock.NewRows([]string{"id", "action_id", "created_at"})
-	suite.mock.ExpectQuery("SELECT (.+), (.+), (.+) FROM medical_record_action_details WHERE medical_record_id = ?").WithArgs("1").WillReturnRows(ad_rows)
+	ad_rows := sqlmock.NewRows([]string{"id", "action_id", "name", "action_price", "description", "created_at"})
+	suite.mock.ExpectQuery("FROM medical_record_action_details d JOIN actions a (.+) WHERE d.medical_record_id = ?").WithArgs("1").WillReturnRows(ad_rows)
 
 	suite.mock.ExpectCommit()
 
-	actual, ret_err := suite.medicalRecordRepo.RetrieveMedicalRecordByID(id)
+	actual, ret_err := suite.medicalRecordRepo.RetrieveMedicalRecordByID(context.Background(), id)
 
 	err := suite.mock.ExpectationsWereMet()
 	if err != nil {
@@ -222,6 +282,29 @@ func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecordByID_Success
 	suite.NotEmpty(actual)
 }
 
+func (suite *MedicalRecordRepositorySuite) TestRetrieveMedicalRecordByID_RecordedPrices() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("FROM medical_records").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "created_at"}).
+			AddRow("mr1", "b1", "tes diagnosis", "2024-03-13 09:04:26"))
+
+	// The prices come from the record, not from the current catalogue
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id = ?").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"}).
+			AddRow("md1", "med1", "betadine", 40, 25000, 2, "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("FROM medical_record_action_details d JOIN actions a (.+) WHERE d.medical_record_id = ?").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action_id", "name", "action_price", "description", "created_at"}).
+			AddRow("ad1", "ac1", "konsultasi", 20000, "", "2024-03-13 09:04:26"))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.medicalRecordRepo.RetrieveMedicalRecordByID(context.Background(), "mr1")
+
+	suite.Nil(err)
+	suite.Equal(25000, actual.Medicine_Details[0].Medicine_Price)
+	suite.Equal(20000, actual.Action_Details[0].Action_Price)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
 func (suite *MedicalRecordRepositorySuite) TestGetActionDetails_Success() {
 	// Receive necessary medicine data
 	ad_rows := sqlmock.NewRows([]string{"name", "price", "description"}).AddRow("action 1", 20000, "deskripsi action 1")
@@ -255,7 +338,7 @@ func (suite *MedicalRecordRepositorySuite) TestUpdatePaymentToDone_Success() {
 
 	suite.mock.ExpectCommit()
 
-	_, _ = suite.medicalRecordRepo.UpdatePaymentToDone(id)
+	_, _ = suite.medicalRecordRepo.UpdatePaymentToDone(context.Background(), id, "u1")
 	//suite.Nil(err)
 	//suite.NotEmpty(actual)
 }
@@ -305,3 +388,363 @@ func (suite *MedicalRecordRepositorySuite) TestUpdateMedcineStock_Success() {
 	// 	suite.Fail("there were unfulfilled expectations: %s", err)
 	// }
 }
+
+func (suite *MedicalRecordRepositorySuite) TestUpdateMedicineStock_AllocatesFEFO() {
+	db, mock, _ := sqlmock.New()
+	repo := NewMedicalRecordRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM medicines m LEFT JOIN medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "stock", "batched"}).AddRow(2, 13, 13))
+	mock.ExpectQuery("SELECT id, quantity FROM medicine_batches (.+) ORDER BY expiry_date, created_at FOR UPDATE").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow("batch-old", 3).AddRow("batch-new", 10))
+
+	// Every batch taken from is booked as its own DISPENSE movement
+	mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").WithArgs("batch-old").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("med1", 3))
+	mock.ExpectExec("UPDATE medicine_batches").WithArgs(-3, sqlmock.AnyArg(), "batch-old").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE medicines SET stock").WithArgs(-3, sqlmock.AnyArg(), "med1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(4))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("med1", "batch-old", "DISPENSE", -3, 4, "medical_record", "mr1", nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").WithArgs("batch-new").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("med1", 10))
+	mock.ExpectExec("UPDATE medicine_batches").WithArgs(-2, sqlmock.AnyArg(), "batch-new").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE medicines SET stock").WithArgs(-2, sqlmock.AnyArg(), "med1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(2))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("med1", "batch-new", "DISPENSE", -2, 2, "medical_record", "mr1", nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s2"))
+
+	tx, _ := db.Begin()
+	stock, err := repo.UpdateMedicineStock(context.Background(), tx, 7, 5, "med1", "mr1", "u1")
+
+	suite.Nil(err)
+	suite.Equal(2, stock)
+	suite.Nil(mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestUpdateMedicineStock_ExpiredBatches() {
+	db, mock, _ := sqlmock.New()
+	repo := NewMedicalRecordRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM medicines m LEFT JOIN medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "stock", "batched"}).AddRow(1, 10, 10))
+	mock.ExpectQuery("SELECT id, quantity FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}))
+
+	tx, _ := db.Begin()
+	_, err := repo.UpdateMedicineStock(context.Background(), tx, 10, 5, "med1", "mr1", "u1")
+
+	suite.EqualError(err, constants.ErrExpiredStock)
+	suite.Nil(mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestUpdateMedicineStock_StockBeforeBatches() {
+	db, mock, _ := sqlmock.New()
+	repo := NewMedicalRecordRepository(db)
+
+	// 8 of the 12 in stock were there before the only batch was received
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM medicines m LEFT JOIN medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "stock", "batched"}).AddRow(1, 12, 4))
+	mock.ExpectQuery("SELECT id, quantity FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "quantity"}).AddRow("batch-1", 4))
+	mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").WithArgs("batch-1").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("med1", 4))
+	mock.ExpectExec("UPDATE medicine_batches").WithArgs(-4, sqlmock.AnyArg(), "batch-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE medicines SET stock").WithArgs(-4, sqlmock.AnyArg(), "med1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(8))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("med1", "batch-1", "DISPENSE", -4, 8, "medical_record", "mr1", nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+
+	// The rest comes from the stock in no batch
+	mock.ExpectQuery("UPDATE medicines SET stock").WithArgs(-6, sqlmock.AnyArg(), "med1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(2))
+	mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("med1", nil, "DISPENSE", -6, 2, "medical_record", "mr1", nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s2"))
+
+	tx, _ := db.Begin()
+	stock, err := repo.UpdateMedicineStock(context.Background(), tx, 12, 10, "med1", "mr1", "u1")
+
+	suite.Nil(err)
+	suite.Equal(2, stock)
+	suite.Nil(mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_CountsStockBeforeBatches() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medical_records").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_status", "created_at", "updated_at"}).
+			AddRow("mr1", false, "2024-03-13 09:04:26", "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("SELECT id FROM medicines WHERE id = ANY(.+) FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("med1"))
+	suite.mock.ExpectQuery("SELECT (.+), (.+) from medicines WHERE id = ?").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("betadine", 12))
+	suite.mock.ExpectQuery("FROM medicine_prices").WithArgs("med1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(25000))
+
+	// 8 from before batches, 3 in an expired batch and 1 in an unexpired one
+	suite.mock.ExpectQuery("FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "batched", "unexpired"}).AddRow(2, 4, 1))
+	suite.mock.ExpectQuery("FROM stock_reservations WHERE medicine_id").WithArgs("med1", "RESERVED", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(0))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.medicalRecordRepo.AddMedicalRecord(context.Background(), medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "bookingid1",
+		Diagnosis_Result: "tes diagnosis",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{{Medicine_ID: "med1", Quantity: 10}},
+	})
+
+	suite.EqualError(err, constants.ErrQuantityGreaterThanStock)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_ReservesStock() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medical_records").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_status", "created_at", "updated_at"}).
+			AddRow("mr1", false, "2024-03-13 09:04:26", "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("SELECT id FROM medicines WHERE id = ANY(.+) ORDER BY id FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("med1"))
+	suite.mock.ExpectQuery("SELECT (.+), (.+) from medicines WHERE id = ?").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("betadine", 10))
+	suite.mock.ExpectQuery("FROM medicine_prices").WithArgs("med1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(25000))
+	suite.mock.ExpectQuery("FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "batched", "unexpired"}).AddRow(0, 0, 0))
+	suite.mock.ExpectQuery("FROM stock_reservations WHERE medicine_id").WithArgs("med1", "RESERVED", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(4))
+	suite.mock.ExpectQuery("INSERT INTO medical_record_medicine_details").WithArgs("mr1", "med1", 25000, 6).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("md1"))
+	suite.mock.ExpectExec("INSERT INTO stock_reservations").
+		WithArgs("mr1", "md1", "med1", 6, "2024-03-14 09:04:26", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("UPDATE medical_records").WithArgs(150000, 0, 150000, "mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.medicalRecordRepo.AddMedicalRecord(context.Background(), medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "bookingid1",
+		Diagnosis_Result: "tes diagnosis",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{{Medicine_ID: "med1", Quantity: 6}},
+		Created_By:       "u1",
+		Reserved_Until:   "2024-03-14 09:04:26",
+	})
+
+	suite.Nil(err)
+	suite.Equal(6, actual.Medicine_Details[0].Medicine_Stock)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_ReservedStockNotAvailable() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medical_records").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payment_status", "created_at", "updated_at"}).
+			AddRow("mr1", false, "2024-03-13 09:04:26", "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("SELECT id FROM medicines WHERE id = ANY(.+) FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("med1"))
+	suite.mock.ExpectQuery("SELECT (.+), (.+) from medicines WHERE id = ?").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "stock"}).AddRow("betadine", 10))
+	suite.mock.ExpectQuery("FROM medicine_prices").WithArgs("med1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(25000))
+	suite.mock.ExpectQuery("FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "batched", "unexpired"}).AddRow(0, 0, 0))
+
+	// Another unpaid record already holds most of the stock
+	suite.mock.ExpectQuery("FROM stock_reservations WHERE medicine_id").WithArgs("med1", "RESERVED", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(8))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.medicalRecordRepo.AddMedicalRecord(context.Background(), medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "bookingid1",
+		Diagnosis_Result: "tes diagnosis",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{{Medicine_ID: "med1", Quantity: 3}},
+	})
+
+	suite.EqualError(err, constants.ErrQuantityGreaterThanStock)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+// expectNotInvoiced expects the record to be locked and found without an
+// invoice that is not void.
+func (suite *MedicalRecordRepositorySuite) expectNotInvoiced(id string) {
+	suite.mock.ExpectExec("SELECT id FROM medical_records WHERE id = (.+) FOR UPDATE").WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT EXISTS (.+) FROM invoices WHERE medical_record_id").WithArgs(id, "VOID").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+}
+
+func (suite *MedicalRecordRepositorySuite) TestUpdatePaymentToDone_Invoiced() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectExec("SELECT id FROM medical_records WHERE id = (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("SELECT EXISTS (.+) FROM invoices WHERE medical_record_id").WithArgs("mr1", "VOID").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.medicalRecordRepo.UpdatePaymentToDone(context.Background(), "mr1", "u1")
+
+	suite.EqualError(err, constants.ErrMedicalRecordInvoiced)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestUpdatePaymentToDone_ConsumesReservation() {
+	suite.mock.ExpectBegin()
+	suite.expectNotInvoiced("mr1")
+	suite.mock.ExpectQuery("FROM medical_records WHERE id = (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "payment_status", "created_at"}).
+			AddRow("mr1", "b1", "tes diagnosis", false, "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("UPDATE medical_records SET payment_status = true").WithArgs("mr1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status", "updated_at"}).AddRow(true, "2024-03-13 10:00:00"))
+	suite.mock.ExpectExec("SELECT id FROM medicines WHERE id IN (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"}).
+			AddRow("md1", "med1", "betadine", 6, 25000, 6, "2024-03-13 09:04:26"))
+
+	// The reservation made with the record covers the whole remaining stock
+	suite.mock.ExpectQuery("UPDATE stock_reservations SET status").WithArgs("CONSUMED", sqlmock.AnyArg(), "md1", "RESERVED").
+		WillReturnRows(sqlmock.NewRows([]string{"valid"}).AddRow(true))
+	suite.mock.ExpectQuery("FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "batched", "unexpired"}).AddRow(0, 0, 0))
+	suite.mock.ExpectQuery("SELECT COUNT(.+) FROM medicines m LEFT JOIN medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "stock", "batched"}).AddRow(0, 6, 0))
+	suite.mock.ExpectQuery("UPDATE medicines SET stock").WithArgs(-6, sqlmock.AnyArg(), "med1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(0))
+	suite.mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("med1", nil, "DISPENSE", -6, 0, "medical_record", "mr1", nil, "u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	suite.mock.ExpectQuery("FROM medical_record_action_details d JOIN actions a (.+) WHERE d.medical_record_id").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action_id", "name", "action_price", "description", "created_at"}))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.medicalRecordRepo.UpdatePaymentToDone(context.Background(), "mr1", "u1")
+
+	suite.Nil(err)
+	suite.Equal(0, actual.Medicine_Details[0].Medicine_Stock)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestUpdatePaymentToDone_ExpiredReservation() {
+	suite.mock.ExpectBegin()
+	suite.expectNotInvoiced("mr1")
+	suite.mock.ExpectQuery("FROM medical_records WHERE id = (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "booking_id", "diagnosis_results", "payment_status", "created_at"}).
+			AddRow("mr1", "b1", "tes diagnosis", false, "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("UPDATE medical_records SET payment_status = true").WithArgs("mr1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status", "updated_at"}).AddRow(true, "2024-03-13 10:00:00"))
+	suite.mock.ExpectExec("SELECT id FROM medicines WHERE id IN (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("FROM medical_record_medicine_details d JOIN medicines m (.+) WHERE d.medical_record_id").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "medicine_id", "name", "stock", "medicine_price", "quantity", "created_at"}).
+			AddRow("md1", "med1", "betadine", 6, 25000, 6, "2024-03-13 09:04:26"))
+	suite.mock.ExpectQuery("UPDATE stock_reservations SET status").WithArgs("CONSUMED", sqlmock.AnyArg(), "md1", "RESERVED").
+		WillReturnRows(sqlmock.NewRows([]string{"valid"}).AddRow(false))
+	suite.mock.ExpectQuery("FROM medicine_batches").WithArgs("med1").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "batched", "unexpired"}).AddRow(0, 0, 0))
+
+	// Meanwhile the stock was reserved by another record
+	suite.mock.ExpectQuery("FROM stock_reservations WHERE medicine_id").WithArgs("med1", "RESERVED", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"reserved"}).AddRow(6))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.medicalRecordRepo.UpdatePaymentToDone(context.Background(), "mr1", "u1")
+
+	suite.EqualError(err, constants.ErrNoStockAvailable)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestCancelMedicalRecord_ReleasesReservations() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT payment_status FROM medical_records (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(false))
+	suite.mock.ExpectExec("UPDATE stock_reservations SET status").WithArgs("RELEASED", sqlmock.AnyArg(), "mr1", "RESERVED").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectExec("UPDATE medical_records SET deleted_at").WithArgs(sqlmock.AnyArg(), "mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	err := suite.medicalRecordRepo.CancelMedicalRecord(context.Background(), "mr1")
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestCancelMedicalRecord_AlreadyPaid() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT payment_status FROM medical_records").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(true))
+	suite.mock.ExpectRollback()
+
+	err := suite.medicalRecordRepo.CancelMedicalRecord(context.Background(), "mr1")
+
+	suite.EqualError(err, constants.ErrPaymentAlreadyTrue)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestReleaseExpiredReservations() {
+	suite.mock.ExpectExec("UPDATE stock_reservations SET status (.+) expires_at <=").WithArgs("RELEASED", sqlmock.AnyArg(), "RESERVED").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	released, err := suite.medicalRecordRepo.ReleaseExpiredReservations(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(int64(3), released)
+}
+
+func (suite *MedicalRecordRepositorySuite) TestAddMedicalRecord_BeginFailed() {
+	suite.mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+
+	_, err := suite.medicalRecordRepo.AddMedicalRecord(context.Background(), medicalRecordDTO.Medical_Record_Request{Booking_ID: "bookingid1"})
+
+	suite.EqualError(err, "connection refused")
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestCancelMedicalRecord_JoinsTransaction() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT payment_status FROM medical_records (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(false))
+	suite.mock.ExpectExec("UPDATE stock_reservations SET status").WithArgs("RELEASED", sqlmock.AnyArg(), "mr1", "RESERVED").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectExec("UPDATE medical_records SET deleted_at").WithArgs(sqlmock.AnyArg(), "mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectRollback()
+
+	// A later step of the unit of work failing undoes the cancellation too
+	err := transaction.Run(context.Background(), suite.db, func(ctx context.Context) error {
+		if err := suite.medicalRecordRepo.CancelMedicalRecord(ctx, "mr1"); err != nil {
+			return err
+		}
+		return errors.New("booking not found")
+	})
+
+	suite.EqualError(err, "booking not found")
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *MedicalRecordRepositorySuite) TestCancelMedicalRecord_PanicRollsBack() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT payment_status FROM medical_records (.+) FOR UPDATE").WithArgs("mr1").
+		WillReturnRows(sqlmock.NewRows([]string{"payment_status"}).AddRow(false))
+	suite.mock.ExpectExec("UPDATE stock_reservations SET status").WithArgs("RELEASED", sqlmock.AnyArg(), "mr1", "RESERVED").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	suite.mock.ExpectExec("UPDATE medical_records SET deleted_at").WithArgs(sqlmock.AnyArg(), "mr1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectRollback()
+
+	suite.Panics(func() {
+		transaction.Run(context.Background(), suite.db, func(ctx context.Context) error {
+			suite.medicalRecordRepo.CancelMedicalRecord(ctx, "mr1")
+			panic("unexpected state")
+		})
+	})
+
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
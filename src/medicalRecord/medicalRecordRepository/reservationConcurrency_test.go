@@ -0,0 +1,300 @@
+package medicalRecordRepository
+
+import (
+	"avengers-clinic/migrations"
+	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/migration"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The concurrency tests need a real PostgreSQL to take the row locks, they run
+// in a throwaway schema built by the migrations when TEST_DATABASE_URL is set.
+// CI always sets it, elsewhere they are skipped without it.
+
+func openReservationTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("TEST_DATABASE_URL must be set in CI")
+		}
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The extensions live in public so dropping the test schema keeps them
+	for _, extension := range []string{`"uuid-ossp"`, "pg_trgm"} {
+		if _, err := admin.Exec("CREATE EXTENSION IF NOT EXISTS " + extension + " SCHEMA public"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schema := fmt.Sprintf("reservation_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+
+	separator := " "
+	if strings.Contains(dsn, "://") {
+		separator = "&"
+		if !strings.Contains(dsn, "?") {
+			separator = "?"
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn+separator+"search_path="+schema+",public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(20)
+
+	t.Cleanup(func() {
+		db.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	migrator, err := migration.New(db, migrations.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func insertTestMedicine(t *testing.T, db *sql.DB, stock int) string {
+	var id string
+	if err := db.QueryRow("INSERT INTO medicines (name, medicine_type, price, stock) VALUES ('paracetamol', 'TABLET', 1000, $1) RETURNING id", stock).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// insertTestBooking makes the patient, doctor and schedule a medical record
+// belongs to and returns the booking
+func insertTestBooking(t *testing.T, db *sql.DB) string {
+	var patientID, doctorID, scheduleID, bookingID string
+	if err := db.QueryRow("INSERT INTO users (username, password, role) VALUES ('patient', 'secret', 'PATIENT') RETURNING id").Scan(&patientID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("INSERT INTO users (username, password, role, specialization) VALUES ('doctor', 'secret', 'DOCTOR', 'umum') RETURNING id").Scan(&doctorID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("INSERT INTO doctor_schedules (doctor_id, schedule_date, start_at, end_at) VALUES ($1, CURRENT_DATE, 1, 2) RETURNING id", doctorID).Scan(&scheduleID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("INSERT INTO bookings (patient_id, doctor_schedule_id, mst_schedule_id, status, complaint) VALUES ($1, $2, 1, 'WAITING', 'demam') RETURNING id", patientID, scheduleID).Scan(&bookingID); err != nil {
+		t.Fatal(err)
+	}
+	return bookingID
+}
+
+func unpaidRequest(bookingID string, mds ...medicalRecordDTO.Medicine_Details_Request) medicalRecordDTO.Medical_Record_Request {
+	now := time.Now()
+	return medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       bookingID,
+		Diagnosis_Result: "tes diagnosis",
+		Medicine_Details: mds,
+		Created_At:       now.Format("2006-01-02 15:04:05"),
+		Updated_At:       now.Format("2006-01-02 15:04:05"),
+		Reserved_Until:   now.Add(time.Hour).Format("2006-01-02 15:04:05"),
+	}
+}
+
+func isStockError(err error) bool {
+	return err.Error() == constants.ErrNoStockAvailable || err.Error() == constants.ErrQuantityGreaterThanStock
+}
+
+func TestConcurrentReservationsDoNotOversell(t *testing.T) {
+	db := openReservationTestDB(t)
+	repo := NewMedicalRecordRepository(db)
+
+	bookingID := insertTestBooking(t, db)
+	first := insertTestMedicine(t, db, 5)
+	second := insertTestMedicine(t, db, 5)
+
+	// Half of the records prescribe the medicines in the opposite order, the
+	// locks must still be taken without deadlocking
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		mds := []medicalRecordDTO.Medicine_Details_Request{{Medicine_ID: first, Quantity: 1}, {Medicine_ID: second, Quantity: 1}}
+		if i%2 == 1 {
+			mds[0], mds[1] = mds[1], mds[0]
+		}
+
+		wg.Add(1)
+		go func(req medicalRecordDTO.Medical_Record_Request) {
+			defer wg.Done()
+			_, err := repo.AddMedicalRecord(context.Background(), req)
+			errs <- err
+		}(unpaidRequest(bookingID, mds...))
+	}
+	wg.Wait()
+	close(errs)
+
+	var created int
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+
+		if !isStockError(err) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if created != 5 {
+		t.Fatalf("expected 5 records to reserve the stock, got %d", created)
+	}
+
+	for _, medicineID := range []string{first, second} {
+		var reserved int
+		if err := db.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE medicine_id = $1 AND status = 'RESERVED'", medicineID).Scan(&reserved); err != nil {
+			t.Fatal(err)
+		}
+
+		if reserved != 5 {
+			t.Fatalf("expected 5 reserved for %s, got %d", medicineID, reserved)
+		}
+	}
+}
+
+func TestConcurrentPaymentsDispenseOnce(t *testing.T) {
+	db := openReservationTestDB(t)
+	repo := NewMedicalRecordRepository(db)
+
+	bookingID := insertTestBooking(t, db)
+	medicineID := insertTestMedicine(t, db, 5)
+	mr, err := repo.AddMedicalRecord(context.Background(), unpaidRequest(bookingID, medicalRecordDTO.Medicine_Details_Request{Medicine_ID: medicineID, Quantity: 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A record made without the reservation cannot take the reserved stock
+	if _, err := repo.AddMedicalRecord(context.Background(), unpaidRequest(bookingID, medicalRecordDTO.Medicine_Details_Request{Medicine_ID: medicineID, Quantity: 1})); err == nil || !isStockError(err) {
+		t.Fatalf("expected a stock error, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.UpdatePaymentToDone(context.Background(), mr.ID, "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var paid int
+	for err := range errs {
+		if err == nil {
+			paid++
+			continue
+		}
+
+		if err.Error() != constants.ErrPaymentAlreadyTrue {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if paid != 1 {
+		t.Fatalf("expected the record to be paid once, got %d", paid)
+	}
+
+	var stock, dispensed int
+	if err := db.QueryRow("SELECT stock FROM medicines WHERE id = $1", medicineID).Scan(&stock); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM stock_movements WHERE medicine_id = $1 AND movement_type = 'DISPENSE'", medicineID).Scan(&dispensed); err != nil {
+		t.Fatal(err)
+	}
+
+	if stock != 0 || dispensed != 1 {
+		t.Fatalf("expected the stock dispensed once, got stock %d and %d movements", stock, dispensed)
+	}
+}
+
+func TestCancelReleasesReservation(t *testing.T) {
+	db := openReservationTestDB(t)
+	repo := NewMedicalRecordRepository(db)
+
+	bookingID := insertTestBooking(t, db)
+	medicineID := insertTestMedicine(t, db, 3)
+	mr, err := repo.AddMedicalRecord(context.Background(), unpaidRequest(bookingID, medicalRecordDTO.Medicine_Details_Request{Medicine_ID: medicineID, Quantity: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.CancelMedicalRecord(context.Background(), mr.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.AddMedicalRecord(context.Background(), unpaidRequest(bookingID, medicalRecordDTO.Medicine_Details_Request{Medicine_ID: medicineID, Quantity: 3})); err != nil {
+		t.Fatalf("expected the released stock to be available, got %v", err)
+	}
+}
+
+func TestRecordTakesPriceListPrice(t *testing.T) {
+	db := openReservationTestDB(t)
+	repo := NewMedicalRecordRepository(db)
+
+	bookingID := insertTestBooking(t, db)
+	medicineID := insertTestMedicine(t, db, 5)
+
+	var actionID string
+	if err := db.QueryRow("INSERT INTO actions (name, price) VALUES ('injeksi', 20000) RETURNING id").Scan(&actionID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The price in effect now is the latest one that already started, the
+	// created price and a future change do not apply
+	if _, err := db.Exec(`INSERT INTO medicine_prices (medicine_id, price, effective_from) VALUES
+		($1, 1500, LOCALTIMESTAMP - INTERVAL '1 day'), ($1, 3000, LOCALTIMESTAMP + INTERVAL '1 day')`, medicineID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO action_prices (action_id, price, effective_from) VALUES
+		($1, 25000, LOCALTIMESTAMP - INTERVAL '1 day'), ($1, 40000, LOCALTIMESTAMP + INTERVAL '1 day')`, actionID); err != nil {
+		t.Fatal(err)
+	}
+
+	req := unpaidRequest(bookingID, medicalRecordDTO.Medicine_Details_Request{Medicine_ID: medicineID, Quantity: 2})
+	req.Action_Details = []medicalRecordDTO.Action_Details_Request{{Action_ID: actionID}}
+	mr, err := repo.AddMedicalRecord(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var medicinePrice, actionPrice, totalAmount int
+	if err := db.QueryRow("SELECT medicine_price FROM medical_record_medicine_details WHERE medical_record_id = $1", mr.ID).Scan(&medicinePrice); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT action_price FROM medical_record_action_details WHERE medical_record_id = $1", mr.ID).Scan(&actionPrice); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT total_amount FROM medical_records WHERE id = $1", mr.ID).Scan(&totalAmount); err != nil {
+		t.Fatal(err)
+	}
+
+	if medicinePrice != 1500 || actionPrice != 25000 || totalAmount != 2*1500+25000 {
+		t.Fatalf("expected the price list prices, got medicine %d, action %d and total %d", medicinePrice, actionPrice, totalAmount)
+	}
+}
@@ -1,20 +1,36 @@
 package medicalRecordUsecase
 
 import (
+	"avengers-clinic/model/dto/allergyDto"
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/allergy"
+	"avengers-clinic/src/booking"
+	"avengers-clinic/src/inventory"
 	"avengers-clinic/src/medicalRecord"
+	"context"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 )
 
 type medicalRecordUsecase struct {
 	medicalRecordRepo medicalRecord.MedicalRecordRepository
+	bookingRepo       booking.BookingRepository
+	allergyUC         allergy.AllergyUsecase
+	inventoryUC       inventory.InventoryUsecase
+	txManager         transaction.Manager
+	reservationTTL    time.Duration
 }
 
-func NewMedicalRecordUsecase(medicalRecordRepo medicalRecord.MedicalRecordRepository) medicalRecord.MedicalRecordUsecase {
-	return &medicalRecordUsecase{medicalRecordRepo}
+func NewMedicalRecordUsecase(medicalRecordRepo medicalRecord.MedicalRecordRepository, bookingRepo booking.BookingRepository, allergyUC allergy.AllergyUsecase, inventoryUC inventory.InventoryUsecase, txManager transaction.Manager, reservationTTL time.Duration) medicalRecord.MedicalRecordUsecase {
+	return &medicalRecordUsecase{medicalRecordRepo, bookingRepo, allergyUC, inventoryUC, txManager, reservationTTL}
 }
 
-func (du *medicalRecordUsecase) CreateMedicalRecord(req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
+func (du *medicalRecordUsecase) CreateMedicalRecord(ctx context.Context, req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
 
 	// if req.Booking_ID == "" || req.Diagnosis_Result == "" {
 	// 	return medicalRecordDTO.Medical_Record{}, errors.New("err1")
@@ -40,44 +56,123 @@ func (du *medicalRecordUsecase) CreateMedicalRecord(req medicalRecordDTO.Medical
 		req.Updated_At = time.Now().Format("2006-01-02 15:04:05")
 	}
 
-	medicalRecord, err := du.medicalRecordRepo.AddMedicalRecord(req)
+	// Unpaid records hold their medicines until the reservation expires
+	if !req.Payment_Status {
+		req.Reserved_Until = time.Now().Add(du.reservationTTL).Format("2006-01-02 15:04:05")
+	}
+
+	// Check the prescription against patient allergies and drug interactions
+	checkReq := allergyDto.CheckRequest{BookingID: req.Booking_ID}
+	for _, md := range req.Medicine_Details {
+		checkReq.MedicineIDs = append(checkReq.MedicineIDs, md.Medicine_ID)
+	}
+	if len(req.Medicine_Details) > 0 {
+		if err := du.allergyUC.ValidatePrescription(ctx, checkReq, req.Acknowledgements); err != nil {
+			return medicalRecordDTO.Medical_Record{}, err
+		}
+	}
+
+	bookingID, err := uuid.Parse(req.Booking_ID)
+	if err != nil {
+		return medicalRecordDTO.Medical_Record{}, err
+	}
+
+	// The record, its stock movements, the overrides and the finished booking
+	// are stored together or not at all
+	var medicalRecord medicalRecordDTO.Medical_Record
+	err = du.txManager.Do(ctx, func(ctx context.Context) error {
+		if medicalRecord, err = du.medicalRecordRepo.AddMedicalRecord(ctx, req); err != nil {
+			return err
+		}
+
+		// Keep the reasons given for every overridden warning
+		if err := du.allergyUC.SaveOverrides(ctx, medicalRecord.ID, req.Created_By, checkReq, req.Acknowledgements); err != nil {
+			return err
+		}
+
+		return du.bookingRepo.FinishBooking(ctx, bookingID, 0)
+	})
 	if err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
+	// The medicines are only dispensed when the record is created as paid
+	if req.Payment_Status {
+		du.checkLowStock(ctx, medicalRecord.Medicine_Details)
+	}
+
 	return medicalRecord, nil
 }
 
-func (du *medicalRecordUsecase) GetMedicalRecords() ([]medicalRecordDTO.Medical_Record, error) {
+func (du *medicalRecordUsecase) GetMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, queryDto.Paging, error) {
 	var medicalRecords []medicalRecordDTO.Medical_Record
+	var total int
 	var err error
 
-	medicalRecords, err = du.medicalRecordRepo.RetrieveMedicalRecords()
+	medicalRecords, total, err = du.medicalRecordRepo.RetrieveMedicalRecords(ctx, query)
 	if err != nil {
-		return []medicalRecordDTO.Medical_Record{}, err
+		return []medicalRecordDTO.Medical_Record{}, queryDto.Paging{}, err
 	}
 
-	return medicalRecords, nil
+	return medicalRecords, utils.NewPaging(query, total), nil
 }
 
-func (du *medicalRecordUsecase) GetMedicalRecordByID(id string) (medicalRecordDTO.Medical_Record, error) {
+func (du *medicalRecordUsecase) GetMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error) {
 	var medicalRecord medicalRecordDTO.Medical_Record
 	var err error
 
-	if medicalRecord, err = du.medicalRecordRepo.RetrieveMedicalRecordByID(id); err != nil {
+	if medicalRecord, err = du.medicalRecordRepo.RetrieveMedicalRecordByID(ctx, id); err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
 	return medicalRecord, nil
 }
 
-func (du *medicalRecordUsecase) UpdatePaymentStatus(id string) (medicalRecordDTO.Medical_Record, error) {
+func (du *medicalRecordUsecase) UpdatePaymentStatus(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error) {
 	var medicalRecord medicalRecordDTO.Medical_Record
 	var err error
 
-	if medicalRecord, err = du.medicalRecordRepo.UpdatePaymentToDone(id); err != nil {
+	if medicalRecord, err = du.medicalRecordRepo.UpdatePaymentToDone(ctx, id, userID); err != nil {
 		return medicalRecordDTO.Medical_Record{}, err
 	}
 
+	du.checkLowStock(ctx, medicalRecord.Medicine_Details)
 	return medicalRecord, nil
 }
+
+func (du *medicalRecordUsecase) CancelMedicalRecord(ctx context.Context, id string) error {
+	return du.medicalRecordRepo.CancelMedicalRecord(ctx, id)
+}
+
+func (du *medicalRecordUsecase) ReleaseExpiredReservations(ctx context.Context) (int64, error) {
+	return du.medicalRecordRepo.ReleaseExpiredReservations(ctx)
+}
+
+// StartReservationSweeper periodically marks expired stock reservations as
+// released until the application stops.
+func StartReservationSweeper(medicalRecordUC medicalRecord.MedicalRecordUsecase, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			released, err := medicalRecordUC.ReleaseExpiredReservations(context.Background())
+			if err != nil {
+				log.Error().Msg("StartReservationSweeper.err : " + err.Error())
+				continue
+			}
+
+			if released > 0 {
+				log.Info().Int64("released", released).Msg("expired stock reservations released")
+			}
+		}
+	}()
+}
+
+func (du *medicalRecordUsecase) checkLowStock(ctx context.Context, mds []medicalRecordDTO.Medical_Record_Medicine_Details) {
+	var medicineIDs []string
+	for _, md := range mds {
+		medicineIDs = append(medicineIDs, md.Medicine_ID)
+	}
+	du.inventoryUC.CheckLowStock(ctx, medicineIDs)
+}
@@ -1,12 +1,20 @@
 package medicalRecordUsecase
 
 import (
+	"avengers-clinic/model/dto/allergyDto"
 	"avengers-clinic/model/dto/medicalRecordDTO"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/booking"
+	"avengers-clinic/src/inventory"
 	"avengers-clinic/src/medicalRecord"
+	"context"
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -15,51 +23,143 @@ type mockMedicalRecordRepository struct {
 	mock.Mock
 }
 
-func (m *mockMedicalRecordRepository) AddMedicalRecord(req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
+func (m *mockMedicalRecordRepository) AddMedicalRecord(ctx context.Context, req medicalRecordDTO.Medical_Record_Request) (medicalRecordDTO.Medical_Record, error) {
 	args := m.Called(req)
 	// TODO: find out what to returns
 	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
 }
 
-func (m *mockMedicalRecordRepository) RetrieveMedicalRecords() ([]medicalRecordDTO.Medical_Record, error) {
-	args := m.Called()
-	return args.Get(0).([]medicalRecordDTO.Medical_Record), args.Error(1)
+func (m *mockMedicalRecordRepository) RetrieveMedicalRecords(ctx context.Context, query queryDto.Query) ([]medicalRecordDTO.Medical_Record, int, error) {
+	args := m.Called(query)
+	return args.Get(0).([]medicalRecordDTO.Medical_Record), args.Int(1), args.Error(2)
 }
 
-func (m *mockMedicalRecordRepository) RetrieveMedicalRecordByID(id string) (medicalRecordDTO.Medical_Record, error) {
+func (m *mockMedicalRecordRepository) RetrieveMedicalRecordByID(ctx context.Context, id string) (medicalRecordDTO.Medical_Record, error) {
 	args := m.Called(id)
 	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
 }
 
-func (m *mockMedicalRecordRepository) GetMedicineDetails(db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Medicine_Details, error) {
+func (m *mockMedicalRecordRepository) GetMedicineDetails(ctx context.Context, db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Medicine_Details, error) {
 	args := m.Called(db, mrID)
 	return args.Get(0).([]medicalRecordDTO.Medical_Record_Medicine_Details), args.Error(1)
 }
 
-func (m *mockMedicalRecordRepository) GetActionDetails(db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Action_Details, error) {
+func (m *mockMedicalRecordRepository) GetActionDetails(ctx context.Context, db *sql.Tx, mrID string) ([]medicalRecordDTO.Medical_Record_Action_Details, error) {
 	args := m.Called(db, mrID)
 	return args.Get(0).([]medicalRecordDTO.Medical_Record_Action_Details), args.Error(1)
 }
 
-func (m *mockMedicalRecordRepository) UpdatePaymentToDone(id string) (medicalRecordDTO.Medical_Record, error) {
-	args := m.Called(id)
+func (m *mockMedicalRecordRepository) UpdatePaymentToDone(ctx context.Context, id, userID string) (medicalRecordDTO.Medical_Record, error) {
+	args := m.Called(id, userID)
 	return args.Get(0).(medicalRecordDTO.Medical_Record), args.Error(1)
 }
 
-func (m *mockMedicalRecordRepository) UpdateMedicineStock(tx *sql.Tx, stock, quantity int, medicineID string) (int, error) {
-	args := m.Called(tx, stock, quantity, medicineID)
+func (m *mockMedicalRecordRepository) CancelMedicalRecord(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockMedicalRecordRepository) ReleaseExpiredReservations(ctx context.Context) (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockMedicalRecordRepository) UpdateMedicineStock(ctx context.Context, tx *sql.Tx, stock, quantity int, medicineID, medicalRecordID, userID string) (int, error) {
+	args := m.Called(tx, stock, quantity, medicineID, medicalRecordID, userID)
 	return args.Int(0), args.Error(1)
 }
 
+type mockAllergyUsecase struct {
+	mock.Mock
+}
+
+func (m *mockAllergyUsecase) GetByPatientID(ctx context.Context, patientID string) ([]allergyDto.Allergy, error) {
+	args := m.Called(patientID)
+	return args.Get(0).([]allergyDto.Allergy), args.Error(1)
+}
+
+func (m *mockAllergyUsecase) Create(ctx context.Context, req allergyDto.CreateRequest) (allergyDto.Allergy, error) {
+	args := m.Called(req)
+	return args.Get(0).(allergyDto.Allergy), args.Error(1)
+}
+
+func (m *mockAllergyUsecase) Delete(ctx context.Context, allergyID string) error {
+	args := m.Called(allergyID)
+	return args.Error(0)
+}
+
+func (m *mockAllergyUsecase) CheckPrescription(ctx context.Context, req allergyDto.CheckRequest) (allergyDto.CheckResult, error) {
+	args := m.Called(req)
+	return args.Get(0).(allergyDto.CheckResult), args.Error(1)
+}
+
+func (m *mockAllergyUsecase) ValidatePrescription(ctx context.Context, req allergyDto.CheckRequest, acks []allergyDto.Acknowledgement) error {
+	args := m.Called(req, acks)
+	return args.Error(0)
+}
+
+func (m *mockAllergyUsecase) SaveOverrides(ctx context.Context, medicalRecordID, acknowledgedBy string, req allergyDto.CheckRequest, acks []allergyDto.Acknowledgement) error {
+	args := m.Called(medicalRecordID, acknowledgedBy, req, acks)
+	return args.Error(0)
+}
+
+// mockInventoryUsecase only implements what the medical record usecase calls,
+// the embedded interface panics on anything else.
+type mockInventoryUsecase struct {
+	mock.Mock
+	inventory.InventoryUsecase
+}
+
+func (m *mockInventoryUsecase) CheckLowStock(ctx context.Context, medicineIDs []string) {
+	m.Called(medicineIDs)
+}
+
+// mockBookingRepository only implements what the medical record usecase calls,
+// the embedded interface panics on anything else.
+type mockBookingRepository struct {
+	mock.Mock
+	booking.BookingRepository
+}
+
+func (m *mockBookingRepository) FinishBooking(ctx context.Context, id uuid.UUID, version int) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
+// mockTxManager runs the work without a database and keeps the error it ended
+// with, the one a real transaction would be rolled back on.
+type mockTxManager struct {
+	calls int
+	err   error
+}
+
+func (m *mockTxManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.calls++
+	m.err = fn(ctx)
+	return m.err
+}
+
 type MedicalRecordUsecaseSuite struct {
 	suite.Suite
 	medicalRecordUsecase  medicalRecord.MedicalRecordUsecase
 	medicalRecordRepoMock *mockMedicalRecordRepository
+	bookingRepoMock       *mockBookingRepository
+	allergyUCMock         *mockAllergyUsecase
+	inventoryUCMock       *mockInventoryUsecase
+	txManager             *mockTxManager
 }
 
 func (suite *MedicalRecordUsecaseSuite) SetupTest() {
 	suite.medicalRecordRepoMock = new(mockMedicalRecordRepository)
-	suite.medicalRecordUsecase = NewMedicalRecordUsecase(suite.medicalRecordRepoMock)
+	suite.bookingRepoMock = new(mockBookingRepository)
+	suite.bookingRepoMock.On("FinishBooking", mock.Anything, mock.Anything).Return(nil).Maybe()
+	suite.txManager = new(mockTxManager)
+	suite.allergyUCMock = new(mockAllergyUsecase)
+	suite.allergyUCMock.On("ValidatePrescription", mock.Anything, mock.Anything).Return(nil).Maybe()
+	suite.allergyUCMock.On("SaveOverrides", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	suite.inventoryUCMock = new(mockInventoryUsecase)
+	suite.inventoryUCMock.On("CheckLowStock", mock.Anything).Maybe()
+	suite.medicalRecordUsecase = NewMedicalRecordUsecase(suite.medicalRecordRepoMock, suite.bookingRepoMock, suite.allergyUCMock, suite.inventoryUCMock, suite.txManager, time.Hour)
 }
 
 func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_Success() {
@@ -114,7 +214,7 @@ func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_Success() {
 
 	suite.medicalRecordRepoMock.On("AddMedicalRecord", mockRequest).Return(expectedMedicalRecord, nil)
 
-	createdMedicalRecord, err := suite.medicalRecordUsecase.CreateMedicalRecord(mockRequest)
+	createdMedicalRecord, err := suite.medicalRecordUsecase.CreateMedicalRecord(context.Background(), mockRequest)
 
 	suite.NoError(err)
 
@@ -151,7 +251,7 @@ func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_Error() {
 	expectedError := errors.New("repository error")
 	suite.medicalRecordRepoMock.On("AddMedicalRecord", mockRequest).Return(medicalRecordDTO.Medical_Record{}, expectedError)
 
-	createdMedicalRecord, err := suite.medicalRecordUsecase.CreateMedicalRecord(mockRequest)
+	createdMedicalRecord, err := suite.medicalRecordUsecase.CreateMedicalRecord(context.Background(), mockRequest)
 
 	suite.EqualError(err, expectedError.Error())
 
@@ -160,6 +260,67 @@ func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_Error() {
 	//suite.medicalRecordRepoMock.AssertCalled(suite.T(), "AddMedicalRecord", mockRequest)
 }
 
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_PrescriptionBlocked() {
+	allergyUCMock := new(mockAllergyUsecase)
+	usecase := NewMedicalRecordUsecase(suite.medicalRecordRepoMock, suite.bookingRepoMock, allergyUCMock, suite.inventoryUCMock, suite.txManager, time.Hour)
+
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Created_At:       "2022-03-10 12:00:00",
+		Updated_At:       "2022-03-10 12:00:00",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{
+			{Medicine_ID: "5ad34dce-d1bc-408e-9f82-e5c370cc01f5", Quantity: 1},
+		},
+	}
+
+	checkReq := allergyDto.CheckRequest{
+		BookingID:   mockRequest.Booking_ID,
+		MedicineIDs: []string{"5ad34dce-d1bc-408e-9f82-e5c370cc01f5"},
+	}
+	expectedError := allergyDto.PrescriptionError{
+		Message: constants.ErrPrescriptionBlocked,
+		Issues:  []allergyDto.Issue{{Code: "ALLERGY:5ad34dce-d1bc-408e-9f82-e5c370cc01f5:1", Kind: "ALLERGY", Severity: "SEVERE"}},
+	}
+	allergyUCMock.On("ValidatePrescription", checkReq, mockRequest.Acknowledgements).Return(expectedError)
+
+	createdMedicalRecord, err := usecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	suite.EqualError(err, constants.ErrPrescriptionBlocked)
+	suite.Empty(createdMedicalRecord)
+	suite.medicalRecordRepoMock.AssertNotCalled(suite.T(), "AddMedicalRecord", mock.Anything)
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_SaveOverrides() {
+	allergyUCMock := new(mockAllergyUsecase)
+	usecase := NewMedicalRecordUsecase(suite.medicalRecordRepoMock, suite.bookingRepoMock, allergyUCMock, suite.inventoryUCMock, suite.txManager, time.Hour)
+
+	acks := []allergyDto.Acknowledgement{{WarningCode: "INTERACTION:a:b:DDI-0001", Reason: "benefit outweighs risk"}}
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Created_At:       "2022-03-10 12:00:00",
+		Updated_At:       "2022-03-10 12:00:00",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{
+			{Medicine_ID: "a", Quantity: 1},
+			{Medicine_ID: "b", Quantity: 1},
+		},
+		Acknowledgements: acks,
+		Created_By:       "doctor1",
+	}
+
+	allergyUCMock.On("ValidatePrescription", mock.Anything, acks).Return(nil)
+	suite.medicalRecordRepoMock.On("AddMedicalRecord", mock.AnythingOfType("medicalRecordDTO.Medical_Record_Request")).Return(medicalRecordDTO.Medical_Record{ID: "mr1"}, nil)
+	checkReq := allergyDto.CheckRequest{BookingID: mockRequest.Booking_ID, MedicineIDs: []string{"a", "b"}}
+	allergyUCMock.On("SaveOverrides", "mr1", "doctor1", checkReq, acks).Return(nil)
+
+	createdMedicalRecord, err := usecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	suite.NoError(err)
+	suite.Equal("mr1", createdMedicalRecord.ID)
+	allergyUCMock.AssertExpectations(suite.T())
+}
+
 func (suite *MedicalRecordUsecaseSuite) TestGetMedicalRecords_Success() {
 	expectedMedicalRecords := []medicalRecordDTO.Medical_Record{
 		{
@@ -210,11 +371,13 @@ func (suite *MedicalRecordUsecaseSuite) TestGetMedicalRecords_Success() {
 		},
 	}
 
-	suite.medicalRecordRepoMock.On("RetrieveMedicalRecords").Return(expectedMedicalRecords, nil)
+	query := queryDto.Query{Page: 1, Size: 10}
+	suite.medicalRecordRepoMock.On("RetrieveMedicalRecords", query).Return(expectedMedicalRecords, 11, nil)
 
-	medicalRecords, err := suite.medicalRecordUsecase.GetMedicalRecords()
+	medicalRecords, paging, err := suite.medicalRecordUsecase.GetMedicalRecords(context.Background(), query)
 
 	suite.NoError(err)
+	suite.Equal(queryDto.Paging{Page: 1, Size: 10, TotalRows: 11, TotalPages: 2}, paging)
 
 	suite.Equal(expectedMedicalRecords, medicalRecords)
 
@@ -224,9 +387,10 @@ func (suite *MedicalRecordUsecaseSuite) TestGetMedicalRecords_Success() {
 func (suite *MedicalRecordUsecaseSuite) TestGetMedicalRecords_Error() {
 	expectedError := errors.New("repository error")
 
-	suite.medicalRecordRepoMock.On("RetrieveMedicalRecords").Return([]medicalRecordDTO.Medical_Record{}, expectedError)
+	query := queryDto.Query{Page: 1, Size: 10}
+	suite.medicalRecordRepoMock.On("RetrieveMedicalRecords", query).Return([]medicalRecordDTO.Medical_Record{}, 0, expectedError)
 
-	medicalRecords, err := suite.medicalRecordUsecase.GetMedicalRecords()
+	medicalRecords, _, err := suite.medicalRecordUsecase.GetMedicalRecords(context.Background(), query)
 
 	suite.EqualError(err, expectedError.Error())
 
@@ -264,7 +428,7 @@ func (suite *MedicalRecordUsecaseSuite) TestGetMedicalRecordByID_Success() {
 
 	suite.medicalRecordRepoMock.On("RetrieveMedicalRecordByID", id).Return(expectedMedicalRecord, nil).Once()
 
-	medicalRecord, err := suite.medicalRecordUsecase.GetMedicalRecordByID(id)
+	medicalRecord, err := suite.medicalRecordUsecase.GetMedicalRecordByID(context.Background(), id)
 
 	suite.NoError(err)
 
@@ -280,7 +444,7 @@ func (suite *MedicalRecordUsecaseSuite) TestGetMedicalRecordByID_Error() {
 
 	suite.medicalRecordRepoMock.On("RetrieveMedicalRecordByID", id).Return(medicalRecordDTO.Medical_Record{}, expectedError)
 
-	medicalRecord, err := suite.medicalRecordUsecase.GetMedicalRecordByID(id)
+	medicalRecord, err := suite.medicalRecordUsecase.GetMedicalRecordByID(context.Background(), id)
 
 	suite.EqualError(err, expectedError.Error())
 
@@ -316,13 +480,14 @@ func (suite *MedicalRecordUsecaseSuite) TestUpdatePaymentStatus_Success() {
 		},
 	}
 
-	suite.medicalRecordRepoMock.On("UpdatePaymentToDone", id).Return(expectedMedicalRecord, nil)
+	suite.medicalRecordRepoMock.On("UpdatePaymentToDone", id, "cashier1").Return(expectedMedicalRecord, nil)
 
-	updatedMedicalRecord, err := suite.medicalRecordUsecase.UpdatePaymentStatus(id)
+	updatedMedicalRecord, err := suite.medicalRecordUsecase.UpdatePaymentStatus(context.Background(), id, "cashier1")
 
 	suite.NoError(err)
 
 	suite.Equal(expectedMedicalRecord, updatedMedicalRecord)
+	suite.inventoryUCMock.AssertCalled(suite.T(), "CheckLowStock", []string{"5ad34dce-d1bc-408e-9f82-e5c370cc01f5", "83803a11-1388-4beb-b06b-b22f1c98edaf"})
 
 	//suite.medicalRecordRepoMock.AssertCalled(suite.T(), "UpdatePaymentToDone", id)
 }
@@ -332,17 +497,130 @@ func (suite *MedicalRecordUsecaseSuite) TestUpdatePaymentStatus_Error() {
 
 	expectedError := errors.New("repository error")
 
-	suite.medicalRecordRepoMock.On("UpdatePaymentToDone", id).Return(medicalRecordDTO.Medical_Record{}, expectedError)
+	suite.medicalRecordRepoMock.On("UpdatePaymentToDone", id, "cashier1").Return(medicalRecordDTO.Medical_Record{}, expectedError)
 
-	updatedMedicalRecord, err := suite.medicalRecordUsecase.UpdatePaymentStatus(id)
+	updatedMedicalRecord, err := suite.medicalRecordUsecase.UpdatePaymentStatus(context.Background(), id, "cashier1")
 
 	suite.EqualError(err, expectedError.Error())
 
 	suite.Empty(updatedMedicalRecord)
+	suite.inventoryUCMock.AssertNotCalled(suite.T(), "CheckLowStock", mock.Anything)
 
 	//suite.medicalRecordRepoMock.AssertCalled(suite.T(), "UpdatePaymentToDone", id)
 }
 
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_ReservesUnpaid() {
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{
+			{Medicine_ID: "5ad34dce-d1bc-408e-9f82-e5c370cc01f5", Quantity: 1},
+		},
+	}
+
+	suite.medicalRecordRepoMock.On("AddMedicalRecord", mock.MatchedBy(func(req medicalRecordDTO.Medical_Record_Request) bool {
+		reservedUntil, err := time.ParseInLocation("2006-01-02 15:04:05", req.Reserved_Until, time.Local)
+		return err == nil && reservedUntil.After(time.Now().Add(59*time.Minute))
+	})).Return(medicalRecordDTO.Medical_Record{ID: "mr1"}, nil)
+
+	createdMedicalRecord, err := suite.medicalRecordUsecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	suite.NoError(err)
+	suite.Equal("mr1", createdMedicalRecord.ID)
+	suite.inventoryUCMock.AssertNotCalled(suite.T(), "CheckLowStock", mock.Anything)
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_FinishesBooking() {
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Payment_Status:   true,
+	}
+
+	bookingRepoMock := new(mockBookingRepository)
+	usecase := NewMedicalRecordUsecase(suite.medicalRecordRepoMock, bookingRepoMock, suite.allergyUCMock, suite.inventoryUCMock, suite.txManager, time.Hour)
+	suite.medicalRecordRepoMock.On("AddMedicalRecord", mock.AnythingOfType("medicalRecordDTO.Medical_Record_Request")).Return(medicalRecordDTO.Medical_Record{ID: "mr1"}, nil)
+	bookingRepoMock.On("FinishBooking", uuid.MustParse(mockRequest.Booking_ID), 0).Return(nil)
+
+	createdMedicalRecord, err := usecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	suite.NoError(err)
+	suite.Equal("mr1", createdMedicalRecord.ID)
+	suite.Equal(1, suite.txManager.calls)
+	bookingRepoMock.AssertExpectations(suite.T())
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_FinishBookingFailed() {
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+		Payment_Status:   true,
+		Medicine_Details: []medicalRecordDTO.Medicine_Details_Request{
+			{Medicine_ID: "5ad34dce-d1bc-408e-9f82-e5c370cc01f5", Quantity: 1},
+		},
+	}
+
+	expectedError := errors.New("repository error")
+	bookingRepoMock := new(mockBookingRepository)
+	usecase := NewMedicalRecordUsecase(suite.medicalRecordRepoMock, bookingRepoMock, suite.allergyUCMock, suite.inventoryUCMock, suite.txManager, time.Hour)
+	suite.medicalRecordRepoMock.On("AddMedicalRecord", mock.AnythingOfType("medicalRecordDTO.Medical_Record_Request")).Return(medicalRecordDTO.Medical_Record{ID: "mr1"}, nil)
+	bookingRepoMock.On("FinishBooking", mock.Anything, mock.Anything).Return(expectedError)
+
+	createdMedicalRecord, err := usecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	// The record and its stock movements are rolled back with the booking
+	suite.EqualError(err, expectedError.Error())
+	suite.EqualError(suite.txManager.err, expectedError.Error())
+	suite.Empty(createdMedicalRecord)
+	suite.inventoryUCMock.AssertNotCalled(suite.T(), "CheckLowStock", mock.Anything)
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_AddFailed() {
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "ea1c7e2c-3799-4ef7-a8e7-4ecf6c413151",
+		Diagnosis_Result: "Test diagnosis",
+	}
+
+	bookingRepoMock := new(mockBookingRepository)
+	usecase := NewMedicalRecordUsecase(suite.medicalRecordRepoMock, bookingRepoMock, suite.allergyUCMock, suite.inventoryUCMock, suite.txManager, time.Hour)
+	suite.medicalRecordRepoMock.On("AddMedicalRecord", mock.AnythingOfType("medicalRecordDTO.Medical_Record_Request")).Return(medicalRecordDTO.Medical_Record{}, errors.New(constants.ErrNoStockAvailable))
+
+	_, err := usecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	suite.EqualError(err, constants.ErrNoStockAvailable)
+	bookingRepoMock.AssertNotCalled(suite.T(), "FinishBooking", mock.Anything, mock.Anything)
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestCreateMedicalRecord_InvalidBooking() {
+	mockRequest := medicalRecordDTO.Medical_Record_Request{
+		Booking_ID:       "not-a-booking",
+		Diagnosis_Result: "Test diagnosis",
+	}
+
+	_, err := suite.medicalRecordUsecase.CreateMedicalRecord(context.Background(), mockRequest)
+
+	suite.Error(err)
+	suite.Zero(suite.txManager.calls)
+	suite.medicalRecordRepoMock.AssertNotCalled(suite.T(), "AddMedicalRecord", mock.Anything)
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestCancelMedicalRecord_AlreadyPaid() {
+	suite.medicalRecordRepoMock.On("CancelMedicalRecord", "mr1").Return(errors.New(constants.ErrPaymentAlreadyTrue))
+
+	err := suite.medicalRecordUsecase.CancelMedicalRecord(context.Background(), "mr1")
+
+	suite.EqualError(err, constants.ErrPaymentAlreadyTrue)
+}
+
+func (suite *MedicalRecordUsecaseSuite) TestReleaseExpiredReservations() {
+	suite.medicalRecordRepoMock.On("ReleaseExpiredReservations").Return(int64(3), nil)
+
+	released, err := suite.medicalRecordUsecase.ReleaseExpiredReservations(context.Background())
+
+	suite.NoError(err)
+	suite.Equal(int64(3), released)
+}
+
 func TestCheckHealthUsecaseSuite(t *testing.T) {
 	suite.Run(t, new(MedicalRecordUsecaseSuite))
 }
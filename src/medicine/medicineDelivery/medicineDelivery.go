@@ -3,11 +3,13 @@ package medicineDelivery
 import (
 	"avengers-clinic/model/dto"
 	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/priceDto"
 	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/middleware"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/medicine"
 	"database/sql"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,12 +28,23 @@ func NewMedicineDelivery(v1Group *gin.RouterGroup, medicineUC medicine.MedicineU
 		medicineGroup.PUT("/:id", middleware.JwtAuth("ADMIN"), handler.update)
 		medicineGroup.DELETE("/:id", middleware.JwtAuth("ADMIN"), handler.delete)
 		medicineGroup.GET("/trash", middleware.JwtAuth("ADMIN"), handler.trash)
+		medicineGroup.POST("/import", middleware.JwtAuth("ADMIN"), handler.importRecords)
+		medicineGroup.GET("/export", middleware.JwtAuth("ADMIN"), handler.exportRecords)
 		medicineGroup.PUT("/:id/restore", middleware.JwtAuth("ADMIN"), handler.restore)
+		medicineGroup.GET("/:id/prices", middleware.JwtAuth("ADMIN"), handler.getPrices)
+		medicineGroup.POST("/:id/prices", middleware.JwtAuth("ADMIN"), handler.schedulePrice)
+		medicineGroup.DELETE("/:id/prices/:priceId", middleware.JwtAuth("ADMIN"), handler.cancelPrice)
 	}
 }
 
 func (m *medicineDelivery) getAll(ctx *gin.Context) {
-	getAll, err := m.medicineUC.GetAll()
+	query, invalid := utils.ParseQuery(ctx, medicine.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(ctx, invalid, "bad request", constants.MedicineService, "01")
+		return
+	}
+
+	getAll, paging, err := m.medicineUC.GetAll(ctx.Request.Context(), query)
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
@@ -41,12 +54,12 @@ func (m *medicineDelivery) getAll(ctx *gin.Context) {
 		json.NewResponseForbidden(ctx, "medicines not found", constants.MedicineService, "01")
 		return
 	}
-	json.NewResponseSuccess(ctx, getAll, "success", constants.MedicineService, "01")
+	json.NewResponsePaging(ctx, getAll, paging, "success", constants.MedicineService, "01")
 }
 
 func (m *medicineDelivery) getById(ctx *gin.Context) {
 	id := (ctx.Param("id"))
-	getById, err := m.medicineUC.GetById(id)
+	getById, err := m.medicineUC.GetById(ctx.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(ctx, "medicine not found", constants.MedicineService, "01")
@@ -55,6 +68,7 @@ func (m *medicineDelivery) getById(ctx *gin.Context) {
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
 	}
+	utils.SetETag(ctx, getById.Version)
 	json.NewResponseSuccess(ctx, getById, "success", constants.MedicineService, "01")
 }
 
@@ -68,7 +82,8 @@ func (m *medicineDelivery) create(ctx *gin.Context) {
 		json.NewResponseBadRequest(ctx, err, "bad request", constants.MedicineService, "01")
 		return
 	}
-	insert, err := m.medicineUC.CreateRecord(medicine)
+	medicine.CreatedBy = utils.GetJWT(ctx).ID
+	insert, err := m.medicineUC.CreateRecord(ctx.Request.Context(), medicine)
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
@@ -84,27 +99,49 @@ func (m *medicineDelivery) update(ctx *gin.Context) {
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
 	}
-	insert, err := m.medicineUC.UpdateRecord(medicine)
+	if medicine.Stock != nil {
+		json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: "stock", Message: constants.ErrStockNotUpdatable}}, "bad request", constants.MedicineService, "10")
+		return
+	}
+	version, ok := m.ifMatch(ctx)
+	if !ok {
+		return
+	}
+	medicine.UpdatedBy, medicine.Version = utils.GetJWT(ctx).ID, version
+	insert, err := m.medicineUC.UpdateRecord(ctx.Request.Context(), medicine)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(ctx, "medicine not found", constants.MedicineService, "01")
 			return
 		}
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(ctx, err.Error(), constants.MedicineService, "09")
+			return
+		}
 
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
 	}
+	utils.SetETag(ctx, insert.Version)
 	json.NewResponseSuccess(ctx, insert, "success update medicine", constants.MedicineService, "01")
 }
 
 func (m *medicineDelivery) delete(ctx *gin.Context) {
 	id := ctx.Param("id")
-	err := m.medicineUC.DeleteRecord(id)
+	version, ok := m.ifMatch(ctx)
+	if !ok {
+		return
+	}
+	err := m.medicineUC.DeleteRecord(ctx.Request.Context(), id, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(ctx, "medicine not found", constants.MedicineService, "01")
 			return
 		}
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(ctx, err.Error(), constants.MedicineService, "09")
+			return
+		}
 		
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
@@ -114,7 +151,7 @@ func (m *medicineDelivery) delete(ctx *gin.Context) {
 }
 
 func (m *medicineDelivery) trash(ctx *gin.Context) {
-	getAll, err := m.medicineUC.TrashRecord()
+	getAll, err := m.medicineUC.TrashRecord(ctx.Request.Context())
 	if err != nil {
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
@@ -130,11 +167,157 @@ func (m *medicineDelivery) trash(ctx *gin.Context) {
 
 func (m *medicineDelivery) restore(ctx *gin.Context) {
 	id := ctx.Param("id")
-	err := m.medicineUC.RestoreRecord(id)
+	version, ok := m.ifMatch(ctx)
+	if !ok {
+		return
+	}
+	err := m.medicineUC.RestoreRecord(ctx.Request.Context(), id, version)
 	if err != nil {
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(ctx, err.Error(), constants.MedicineService, "09")
+			return
+		}
 		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
 		return
 	}
 
 	json.NewResponseSuccess(ctx, id, "success restore medicine", constants.MedicineService, "01")
 }
+
+// importRecords creates and updates medicines from an uploaded CSV or XLSX
+// file. With dry_run=true it only reports what the import would do.
+func (m *medicineDelivery) importRecords(ctx *gin.Context) {
+	records, err := utils.ReadUpload(ctx, "file")
+	if err != nil {
+		json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: "file", Message: err.Error()}}, "bad request", constants.MedicineService, "05")
+		return
+	}
+
+	dryRun := ctx.Query("dry_run") == "true"
+	result, err := m.medicineUC.Import(ctx.Request.Context(), records, utils.GetJWT(ctx).ID, dryRun)
+	if err != nil {
+		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
+		return
+	}
+
+	if result.Failed > 0 {
+		json.NewResponseBadRequest(ctx, utils.ImportErrors(result), "the file has invalid rows, nothing was imported", constants.MedicineService, "06")
+		return
+	}
+	if dryRun {
+		json.NewResponseSuccess(ctx, result, "the file can be imported", constants.MedicineService, "02")
+		return
+	}
+	json.NewResponseCreated(ctx, result, "success import medicines", constants.MedicineService, "02")
+}
+
+// exportRecords sends the medicines matching the filters of the list as a CSV
+// or XLSX file, csv unless the format query asks for xlsx.
+func (m *medicineDelivery) exportRecords(ctx *gin.Context) {
+	query, invalid := utils.ParseQuery(ctx, medicine.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(ctx, invalid, "bad request", constants.MedicineService, "01")
+		return
+	}
+
+	file, err := m.medicineUC.Export(ctx.Request.Context(), query, ctx.DefaultQuery("format", constants.ReportFormatCSV))
+	if err != nil {
+		if err.Error() == constants.ErrInvalidFileFormat {
+			json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: "format", Message: err.Error()}}, "bad request", constants.MedicineService, "07")
+			return
+		}
+		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="`+file.FileName+`"`)
+	ctx.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
+func (m *medicineDelivery) getPrices(ctx *gin.Context) {
+	prices, err := m.medicineUC.GetPrices(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		m.priceError(ctx, err)
+		return
+	}
+
+	json.NewResponseSuccess(ctx, prices, "success", constants.MedicineService, "01")
+}
+
+func (m *medicineDelivery) schedulePrice(ctx *gin.Context) {
+	var request priceDto.PriceRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(ctx, err, "bad request", constants.MedicineService, "01")
+		return
+	}
+	request.ItemID, request.CreatedBy = ctx.Param("id"), utils.GetJWT(ctx).ID
+
+	var ok bool
+	if request.Version, ok = m.ifMatch(ctx); !ok {
+		return
+	}
+
+	prices, err := m.medicineUC.SchedulePrice(ctx.Request.Context(), request)
+	if err != nil {
+		m.priceError(ctx, err)
+		return
+	}
+
+	json.NewResponseCreated(ctx, prices, "success schedule medicine price", constants.MedicineService, "01")
+}
+
+func (m *medicineDelivery) cancelPrice(ctx *gin.Context) {
+	priceID := ctx.Param("priceId")
+	version, ok := m.ifMatch(ctx)
+	if !ok {
+		return
+	}
+	if err := m.medicineUC.CancelPrice(ctx.Request.Context(), ctx.Param("id"), priceID, version); err != nil {
+		m.priceError(ctx, err)
+		return
+	}
+
+	json.NewResponseSuccess(ctx, priceID, "success cancel medicine price", constants.MedicineService, "01")
+}
+
+func (m *medicineDelivery) priceError(ctx *gin.Context, err error) {
+	if err == sql.ErrNoRows {
+		json.NewResponseNotFound(ctx, "medicine price not found", constants.MedicineService, "02")
+		return
+	}
+
+	switch err.Error() {
+	case constants.ErrDateTimeFormat, constants.ErrPriceEffectiveInPast:
+		json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: "effective_from", Message: err.Error()}}, "bad request", constants.MedicineService, "03")
+		return
+	case constants.ErrPriceNotScheduled:
+		json.NewResponseBadRequest(ctx, []json.ValidationField{}, err.Error(), constants.MedicineService, "04")
+		return
+	case constants.ErrVersionConflict:
+		json.NewResponsePreconditionFailed(ctx, err.Error(), constants.MedicineService, "09")
+		return
+	}
+
+	json.NewResponseError(ctx, err.Error(), constants.MedicineService, "01")
+}
+
+// ifMatch reads the version the change is made against from the If-Match
+// header, answering the request itself when the header is missing or invalid.
+func (m *medicineDelivery) ifMatch(ctx *gin.Context) (int, bool) {
+	version, err := utils.IfMatch(ctx)
+	if err == nil {
+		return version, true
+	}
+
+	if err.Error() == constants.ErrIfMatchRequired {
+		json.NewResponsePreconditionRequired(ctx, err.Error(), constants.MedicineService, "08")
+		return 0, false
+	}
+	json.NewResponseBadRequest(ctx, []json.ValidationField{{FieldName: constants.IfMatchHeader, Message: err.Error()}}, "bad request", constants.MedicineService, "08")
+	return 0, false
+}
@@ -2,9 +2,17 @@ package medicineDelivery
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"bytes"
+	"context"
 	"database/sql"
+	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -14,45 +22,77 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
+var defaultQuery = queryDto.Query{Page: 1, Size: 10, Sort: []queryDto.Sort{{Field: "name"}}}
+
 type mockMedicineUsecase struct {
 	mock.Mock
 }
 
-func (mock *mockMedicineUsecase) GetAll() ([]dto.MedicineResponse, error) {
-	args := mock.Called()
-	return args.Get(0).([]dto.MedicineResponse), args.Error(1)
+func (mock *mockMedicineUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]dto.MedicineResponse, queryDto.Paging, error) {
+	args := mock.Called(query)
+	return args.Get(0).([]dto.MedicineResponse), args.Get(1).(queryDto.Paging), args.Error(2)
 }
 
-func (mock *mockMedicineUsecase) GetById(id string) (dto.MedicineResponse, error) {
+func (mock *mockMedicineUsecase) GetById(ctx context.Context, id string) (dto.MedicineResponse, error) {
 	args := mock.Called(id)
 	return args.Get(0).(dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineUsecase) CreateRecord(medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
+func (mock *mockMedicineUsecase) CreateRecord(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
 	args := mock.Called(medicine)
 	return args.Get(0).(dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineUsecase) UpdateRecord(medicine dto.UpdateRequest) (dto.MedicineResponse, error) {
+func (mock *mockMedicineUsecase) UpdateRecord(ctx context.Context, medicine dto.UpdateRequest) (dto.MedicineResponse, error) {
 	args := mock.Called(medicine)
 	return args.Get(0).(dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineUsecase) DeleteRecord(id string) error {
-	args := mock.Called(id)
+func (mock *mockMedicineUsecase) DeleteRecord(ctx context.Context, id string, version int) error {
+	args := mock.Called(id, version)
 	return args.Error(0)
 }
 
-func (mock *mockMedicineUsecase) TrashRecord() ([]dto.MedicineResponse, error) {
+func (mock *mockMedicineUsecase) TrashRecord(ctx context.Context) ([]dto.MedicineResponse, error) {
 	args := mock.Called()
 	return args.Get(0).([]dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineUsecase) RestoreRecord(id string) error {
-	args := mock.Called(id)
+func (mock *mockMedicineUsecase) RestoreRecord(ctx context.Context, id string, version int) error {
+	args := mock.Called(id, version)
 	return args.Error(0)
 }
 
+func (mock *mockMedicineUsecase) GetPrices(ctx context.Context, medicineID string) ([]priceDto.Price, error) {
+	args := mock.Called(medicineID)
+	return args.Get(0).([]priceDto.Price), args.Error(1)
+}
+
+func (mock *mockMedicineUsecase) SchedulePrice(ctx context.Context, req priceDto.PriceRequest) ([]priceDto.Price, error) {
+	args := mock.Called(req)
+	return args.Get(0).([]priceDto.Price), args.Error(1)
+}
+
+func (mock *mockMedicineUsecase) CancelPrice(ctx context.Context, medicineID, priceID string, version int) error {
+	args := mock.Called(medicineID, priceID, version)
+	return args.Error(0)
+}
+
+func (mock *mockMedicineUsecase) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	args := mock.Called(retentionDays)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
+func (mock *mockMedicineUsecase) Import(ctx context.Context, records []sheetDto.Record, createdBy string, dryRun bool) (sheetDto.ImportResult, error) {
+	args := mock.Called(records, createdBy, dryRun)
+	return args.Get(0).(sheetDto.ImportResult), args.Error(1)
+}
+
+func (mock *mockMedicineUsecase) Export(ctx context.Context, query queryDto.Query, format string) (sheetDto.File, error) {
+	args := mock.Called(query, format)
+	return args.Get(0).(sheetDto.File), args.Error(1)
+}
+
 type medicineDeliveryTestSuite struct {
 	suite.Suite
 	medicineUC *mockMedicineUsecase
@@ -71,7 +111,7 @@ func (suite *medicineDeliveryTestSuite) SetupTest() {
 func (suite *medicineDeliveryTestSuite) TestGetAllSuccess() {
 	medicines := []dto.MedicineResponse{{Id: "1", Name: "Komik", MedicineType: "CAIR"}}
 
-	suite.medicineUC.On("GetAll").Return(medicines, nil)
+	suite.medicineUC.On("GetAll", defaultQuery).Return(medicines, queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/medicines", nil)
@@ -80,7 +120,7 @@ func (suite *medicineDeliveryTestSuite) TestGetAllSuccess() {
 	req.Header.Set("Authorization", "Bearer "+token)
 	suite.router.ServeHTTP(res, req)
 
-	expected := `{"responseCode":"2000301","responseMessage":"success","data":[{"id":"1","name":"Komik","medicine_type":"CAIR","price":0}]}`
+	expected := `{"responseCode":"2000301","responseMessage":"success","data":[{"id":"1","name":"Komik","medicine_type":"CAIR","price":0}],"paging":{"page":1,"size":10,"total_rows":1,"total_pages":1}}`
 
 	suite.Equal(http.StatusOK, res.Code)
 	suite.JSONEq(expected, res.Body.String())
@@ -89,7 +129,7 @@ func (suite *medicineDeliveryTestSuite) TestGetAllSuccess() {
 func (suite *medicineDeliveryTestSuite) TestGetAllErrorNotFound() {
 	medicines := []dto.MedicineResponse{}
 
-	suite.medicineUC.On("GetAll").Return(medicines, nil)
+	suite.medicineUC.On("GetAll", defaultQuery).Return(medicines, queryDto.Paging{}, nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/medicines", nil)
@@ -107,7 +147,7 @@ func (suite *medicineDeliveryTestSuite) TestGetAllErrorNotFound() {
 func (suite *medicineDeliveryTestSuite) TestGetAllInternalServerError() {
 	medicines := []dto.MedicineResponse{}
 
-	suite.medicineUC.On("GetAll").Return(medicines, sql.ErrConnDone)
+	suite.medicineUC.On("GetAll", defaultQuery).Return(medicines, queryDto.Paging{}, sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/medicines", nil)
@@ -121,11 +161,27 @@ func (suite *medicineDeliveryTestSuite) TestGetAllInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expected, res.Body.String())
 }
+func (suite *medicineDeliveryTestSuite) TestGetAllByType() {
+	medicines := []dto.MedicineResponse{{Id: "1", Name: "Komik", MedicineType: "CAIR"}}
+	query := queryDto.Query{Page: 1, Size: 10, Sort: []queryDto.Sort{{Field: "name"}}, Filters: []queryDto.Filter{{Field: "medicine_type", Value: "CAIR"}}}
+
+	suite.medicineUC.On("GetAll", query).Return(medicines, queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/medicines?medicine_type=CAIR", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.medicineUC.AssertExpectations(suite.T())
+}
 // End Get All
 
 // Start Get By Id
 func (suite *medicineDeliveryTestSuite) TestGetByIdSuccess() {
-	medicine := dto.MedicineResponse{Id: "1", Name: "Komik", MedicineType: "CAIR"}
+	medicine := dto.MedicineResponse{Id: "1", Name: "Komik", MedicineType: "CAIR", Version: 2}
 
 	suite.medicineUC.On("GetById", mock.Anything).Return(medicine, nil)
 
@@ -136,9 +192,10 @@ func (suite *medicineDeliveryTestSuite) TestGetByIdSuccess() {
 	req.Header.Set("Authorization", "Bearer "+token)
 	suite.router.ServeHTTP(res, req)
 
-	expected := `{"responseCode":"2000301","responseMessage":"success","data":{"id":"1","name":"Komik","medicine_type":"CAIR","price":0}}`
+	expected := `{"responseCode":"2000301","responseMessage":"success","data":{"id":"1","name":"Komik","medicine_type":"CAIR","price":0,"version":2}}`
 
 	suite.Equal(http.StatusOK, res.Code)
+	suite.Equal(`"2"`, res.Header().Get("ETag"))
 	suite.JSONEq(expected, res.Body.String())
 }
 
@@ -266,6 +323,7 @@ func (suite *medicineDeliveryTestSuite) TestUpdateSuccess() {
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"2000301","responseMessage":"success update medicine","data":{"id":"1","name":"Komik","medicine_type":"CAIR","price":5000,"stock":200}}`
@@ -274,6 +332,24 @@ func (suite *medicineDeliveryTestSuite) TestUpdateSuccess() {
 	suite.JSONEq(expected, res.Body.String())
 }
 
+func (suite *medicineDeliveryTestSuite) TestUpdateStock() {
+	requestBody := []byte(`{"name":"komik","stock":300}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/medicines/1", bytes.NewBuffer(requestBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000310","responseMessage":"bad request","error_description":[{"field":"stock","message":"the stock is changed through POST /api/v1/inventory/adjustments"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+	suite.medicineUC.AssertNotCalled(suite.T(), "UpdateRecord", mock.Anything)
+}
+
 func (suite *medicineDeliveryTestSuite) TestUpdateErrorJSON() {
 	requestBody := []byte(`{"name":"komik",}`)
 
@@ -282,6 +358,7 @@ func (suite *medicineDeliveryTestSuite) TestUpdateErrorJSON() {
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"5000301","responseMessage":"internal server error","error":"invalid character '}' looking for beginning of object key string"}`
@@ -302,6 +379,7 @@ func (suite *medicineDeliveryTestSuite) TestUpdateErrorNotFound() {
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"4030301","responseMessage":"medicine not found"}`
@@ -322,6 +400,7 @@ func (suite *medicineDeliveryTestSuite) TestUpdateInternalServerError() {
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"5000301","responseMessage":"internal server error","error":"sql: connection is already closed"}`
@@ -329,17 +408,55 @@ func (suite *medicineDeliveryTestSuite) TestUpdateInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expected, res.Body.String())
 }
+func (suite *medicineDeliveryTestSuite) TestUpdateVersionConflict() {
+	requestBody := []byte(`{"name":"komik"}`)
+
+	suite.medicineUC.On("UpdateRecord", mock.MatchedBy(func(req dto.UpdateRequest) bool {
+		return req.Version == 1
+	})).Return(dto.MedicineResponse{}, errors.New(constants.ErrVersionConflict))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/medicines/1", bytes.NewBuffer(requestBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `W/"1"`)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4120309","responseMessage":"` + constants.ErrVersionConflict + `"}`
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestUpdateWithoutIfMatch() {
+	requestBody := []byte(`{"name":"komik"}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/medicines/1", bytes.NewBuffer(requestBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4280308","responseMessage":"` + constants.ErrIfMatchRequired + `"}`
+
+	suite.Equal(http.StatusPreconditionRequired, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+	suite.medicineUC.AssertNotCalled(suite.T(), "UpdateRecord", mock.Anything)
+}
 // End Update
 
 // Start Delete
 func (suite *medicineDeliveryTestSuite) TestDeleteSuccess() {
-	suite.medicineUC.On("DeleteRecord", mock.Anything).Return(nil)
+	suite.medicineUC.On("DeleteRecord", mock.Anything, mock.Anything).Return(nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/medicines/1", nil)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"2000301","responseMessage":"success delete medicine","data":"1"}`
@@ -349,13 +466,14 @@ func (suite *medicineDeliveryTestSuite) TestDeleteSuccess() {
 }
 
 func (suite *medicineDeliveryTestSuite) TestDeleteErrorNotFound() {
-	suite.medicineUC.On("DeleteRecord", mock.Anything).Return(sql.ErrNoRows)
+	suite.medicineUC.On("DeleteRecord", mock.Anything, mock.Anything).Return(sql.ErrNoRows)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/medicines/1", nil)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"4030301","responseMessage":"medicine not found"}`
@@ -365,13 +483,14 @@ func (suite *medicineDeliveryTestSuite) TestDeleteErrorNotFound() {
 }
 
 func (suite *medicineDeliveryTestSuite) TestDeleteInternalServerError() {
-	suite.medicineUC.On("DeleteRecord", mock.Anything).Return(sql.ErrConnDone)
+	suite.medicineUC.On("DeleteRecord", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/medicines/1", nil)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"1"`)
 	suite.router.ServeHTTP(res, req)
 
 	expected := `{"responseCode":"5000301","responseMessage":"internal server error","error":"sql: connection is already closed"}`
@@ -379,6 +498,18 @@ func (suite *medicineDeliveryTestSuite) TestDeleteInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expected, res.Body.String())
 }
+func (suite *medicineDeliveryTestSuite) TestDeleteInvalidIfMatch() {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/medicines/1", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", "1")
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.medicineUC.AssertNotCalled(suite.T(), "DeleteRecord", mock.Anything, mock.Anything)
+}
 // End Delete
 
 // Start Trash
@@ -439,10 +570,11 @@ func (suite *medicineDeliveryTestSuite) TestTrashInternalServerError() {
 
 // Start Restore
 func (suite *medicineDeliveryTestSuite) TestRestoreSuccess() {
-	suite.medicineUC.On("RestoreRecord", mock.Anything).Return(nil)
+	suite.medicineUC.On("RestoreRecord", "1", 2).Return(nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/medicines/1/restore", nil)
+	req.Header.Set("If-Match", `"2"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -455,10 +587,11 @@ func (suite *medicineDeliveryTestSuite) TestRestoreSuccess() {
 }
 
 func (suite *medicineDeliveryTestSuite) TestRestoreInternalServerError() {
-	suite.medicineUC.On("RestoreRecord", mock.Anything).Return(sql.ErrConnDone)
+	suite.medicineUC.On("RestoreRecord", "1", 2).Return(sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/medicines/1/restore", nil)
+	req.Header.Set("If-Match", `"2"`)
 
 	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -469,8 +602,196 @@ func (suite *medicineDeliveryTestSuite) TestRestoreInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expected, res.Body.String())
 }
+
+func (suite *medicineDeliveryTestSuite) TestRestoreVersionConflict() {
+	suite.medicineUC.On("RestoreRecord", "1", 2).Return(errors.New(constants.ErrVersionConflict))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/medicines/1/restore", nil)
+	req.Header.Set("If-Match", `"2"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4120309","responseMessage":"` + constants.ErrVersionConflict + `"}`
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
 // End Restore
 
+// Start Price
+func (suite *medicineDeliveryTestSuite) TestSchedulePriceSuccess() {
+	requestBody := []byte(`{"price":6000,"effective_from":"2999-01-01"}`)
+	prices := []priceDto.Price{{ID: "p2", Price: 6000, EffectiveFrom: "2999-01-01 00:00:00", Status: "SCHEDULED", CreatedAt: "2024-03-12 16:06:00"}}
+
+	suite.medicineUC.On("SchedulePrice", priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "2999-01-01", CreatedBy: "1", Version: 1}).Return(prices, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/medicines/1/prices", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"2010301","responseMessage":"success schedule medicine price","data":[{"id":"p2","price":6000,"effective_from":"2999-01-01 00:00:00","status":"SCHEDULED","created_at":"2024-03-12 16:06:00"}]}`
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestSchedulePriceErrorBadRequest() {
+	requestBody := []byte(`{"effective_from":"2999-01-01"}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/medicines/1/prices", bytes.NewBuffer(requestBody))
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000301","responseMessage":"bad request","error_description":[{"field":"Price","message":"Field is required"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestCancelPriceErrorNotScheduled() {
+	suite.medicineUC.On("CancelPrice", "1", "p1", 1).Return(errors.New(constants.ErrPriceNotScheduled))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/medicines/1/prices/p1", nil)
+	req.Header.Set("If-Match", `"1"`)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000304","responseMessage":"only price changes that have not taken effect can be canceled"}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestCancelPriceWithoutIfMatch() {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/medicines/1/prices/p1", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4280308","responseMessage":"` + constants.ErrIfMatchRequired + `"}`
+
+	suite.Equal(http.StatusPreconditionRequired, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+	suite.medicineUC.AssertNotCalled(suite.T(), "CancelPrice", mock.Anything, mock.Anything, mock.Anything)
+}
+// End Price
+
+// Start Import
+func upload(fileName, content string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", fileName)
+	part.Write([]byte(content))
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+func (suite *medicineDeliveryTestSuite) TestImportSuccess() {
+	records := []sheetDto.Record{{Line: 2, Values: map[string]string{"name": "Paracetamol", "medicine_type": "TABLET", "price": "5000"}}}
+	result := sheetDto.ImportResult{Created: 1, Rows: []sheetDto.ImportRow{{Line: 2, Name: "Paracetamol", Action: constants.ImportCreate, ID: "m1"}}}
+	suite.medicineUC.On("Import", records, "1", false).Return(result, nil)
+
+	body, contentType := upload("formulary.csv", "Name,Medicine_Type,Price\nParacetamol,TABLET,5000\n")
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/medicines/import", body)
+	req.Header.Set("Content-Type", contentType)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"2010302","responseMessage":"success import medicines","data":{"dry_run":false,"created":1,"updated":0,"failed":0,"rows":[{"line":2,"name":"Paracetamol","action":"create","id":"m1"}]}}`
+
+	suite.Equal(http.StatusCreated, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestImportInvalidRows() {
+	result := sheetDto.ImportResult{DryRun: true, Failed: 1, Rows: []sheetDto.ImportRow{{Line: 2, Name: "Paracetamol", Errors: []json.ValidationField{{FieldName: "price", Message: constants.ErrSheetNumber}}}}}
+	suite.medicineUC.On("Import", mock.Anything, "1", true).Return(result, nil)
+
+	body, contentType := upload("formulary.csv", "name,medicine_type,price\nParacetamol,TABLET,lima ribu\n")
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/medicines/import?dry_run=true", body)
+	req.Header.Set("Content-Type", contentType)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000306","responseMessage":"the file has invalid rows, nothing was imported","error_description":[{"field":"line 2 price","message":"must be a whole number"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestImportInvalidFile() {
+	body, contentType := upload("formulary.pdf", "%PDF")
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/medicines/import", body)
+	req.Header.Set("Content-Type", contentType)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000305","responseMessage":"bad request","error_description":[{"field":"file","message":"the file must be a csv or xlsx file"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+	suite.medicineUC.AssertNotCalled(suite.T(), "Import", mock.Anything, mock.Anything, mock.Anything)
+}
+// End Import
+
+// Start Export
+func (suite *medicineDeliveryTestSuite) TestExportSuccess() {
+	file := sheetDto.File{FileName: "medicines.xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Content: []byte("xlsx")}
+	suite.medicineUC.On("Export", defaultQuery, "xlsx").Return(file, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/medicines/export?format=xlsx", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.Equal(`attachment; filename="medicines.xlsx"`, res.Header().Get("Content-Disposition"))
+	suite.Equal("xlsx", res.Body.String())
+}
+
+func (suite *medicineDeliveryTestSuite) TestExportInvalidFormat() {
+	suite.medicineUC.On("Export", defaultQuery, "pdf").Return(sheetDto.File{}, errors.New(constants.ErrInvalidFileFormat))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/medicines/export?format=pdf", nil)
+
+	token, _ := utils.GenerateJWT("1", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expected := `{"responseCode":"4000307","responseMessage":"bad request","error_description":[{"field":"format","message":"the file must be a csv or xlsx file"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expected, res.Body.String())
+}
+// End Export
+
 func (suite *medicineDeliveryTestSuite) SetupSuite() {
 	gin.SetMode(gin.TestMode)
 }
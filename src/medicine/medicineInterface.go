@@ -2,24 +2,39 @@ package medicine
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"context"
 )
 
 type MedicineRepository interface {
-	RetrieveAll() ([]dto.MedicineResponse, error)
-	RetrieveById(id string) (dto.MedicineResponse, error)
-	Create(medicine dto.MedicineRequest) (dto.MedicineResponse, error)
-	Update(medicine dto.MedicineRequest) (dto.MedicineResponse, error)
-	Delete(id string, deletedAt string) error
-	Trash() ([]dto.MedicineResponse, error)
-	Restore(id string) error
+	RetrieveAll(ctx context.Context, query queryDto.Query) ([]dto.MedicineResponse, int, error)
+	RetrieveById(ctx context.Context, id string) (dto.MedicineResponse, error)
+	RetrieveByName(ctx context.Context, name string) (dto.MedicineResponse, error)
+	Create(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error)
+	Update(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error)
+	Delete(ctx context.Context, id string, deletedAt string, version int) error
+	Trash(ctx context.Context) ([]dto.MedicineResponse, error)
+	Restore(ctx context.Context, id string, version int) error
+	RetrievePrices(ctx context.Context, medicineID string) ([]priceDto.Price, error)
+	InsertPrice(ctx context.Context, req priceDto.PriceRequest) (string, error)
+	DeletePrice(ctx context.Context, medicineID, priceID, now string, version int) error
+	PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error)
 }
 
 type MedicineUsecase interface {
-	GetAll() ([]dto.MedicineResponse, error)
-	GetById(id string) (dto.MedicineResponse, error)
-	CreateRecord(medicine dto.MedicineRequest) (dto.MedicineResponse, error)
-	UpdateRecord(medicine dto.UpdateRequest) (dto.MedicineResponse, error)
-	DeleteRecord(id string) error
-	TrashRecord() ([]dto.MedicineResponse, error)
-	RestoreRecord(id string) error
+	GetAll(ctx context.Context, query queryDto.Query) ([]dto.MedicineResponse, queryDto.Paging, error)
+	GetById(ctx context.Context, id string) (dto.MedicineResponse, error)
+	CreateRecord(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error)
+	UpdateRecord(ctx context.Context, medicine dto.UpdateRequest) (dto.MedicineResponse, error)
+	DeleteRecord(ctx context.Context, id string, version int) error
+	TrashRecord(ctx context.Context) ([]dto.MedicineResponse, error)
+	RestoreRecord(ctx context.Context, id string, version int) error
+	GetPrices(ctx context.Context, medicineID string) ([]priceDto.Price, error)
+	SchedulePrice(ctx context.Context, req priceDto.PriceRequest) ([]priceDto.Price, error)
+	CancelPrice(ctx context.Context, medicineID, priceID string, version int) error
+	PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error)
+	Import(ctx context.Context, records []sheetDto.Record, createdBy string, dryRun bool) (sheetDto.ImportResult, error)
+	Export(ctx context.Context, query queryDto.Query, format string) (sheetDto.File, error)
 }
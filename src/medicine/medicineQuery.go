@@ -0,0 +1,20 @@
+package medicine
+
+import "avengers-clinic/model/dto/queryDto"
+
+// ListResource is what the list of medicines can be filtered and sorted by.
+// Price sorts by the price in effect now.
+var ListResource = queryDto.Resource{
+	Filters: map[string]string{
+		"medicine_type": "medicine_type",
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"price":      "price",
+		"stock":      "stock",
+		"created_at": "created_at",
+	},
+	DateColumn:  "created_at",
+	DefaultSort: []queryDto.Sort{{Field: "name"}},
+	Key:         "id",
+}
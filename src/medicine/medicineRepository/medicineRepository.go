@@ -2,10 +2,24 @@ package medicineRepository
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/inventory/inventoryRepository"
 	"avengers-clinic/src/medicine"
+	"context"
 	"database/sql"
+	"errors"
 )
 
+// currentPrice is the price of the medicine in effect right now. Medicines
+// without a price list keep the price they were created with.
+const currentPrice = `COALESCE((SELECT p.price FROM medicine_prices p WHERE p.medicine_id = medicines.id AND p.effective_from <= LOCALTIMESTAMP
+	ORDER BY p.effective_from DESC LIMIT 1), medicines.price)`
+
 type medicineRepository struct {
 	db *sql.DB
 }
@@ -14,58 +28,166 @@ func NewMedicineRepository(db *sql.DB) medicine.MedicineRepository {
 	return &medicineRepository{db}
 }
 
-func (m *medicineRepository) Create(medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
-	queryStatement := "INSERT INTO medicines (name,medicine_type,price,stock,description,created_at,updated_at)VALUES($1,$2,$3,$4,$5,$6,$7) returning id"
+// conn returns the transaction of the unit of work ctx belongs to, or the
+// database outside of one.
+func (m *medicineRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.Executor(ctx, m.db)
+}
 
+// Create stores the medicine with an empty stock and books the initial stock
+// as an opening balance on the ledger.
+func (m *medicineRepository) Create(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
 	var returning dto.MedicineRequest
-	err := m.db.QueryRow(queryStatement, medicine.Name, medicine.MedicineType, medicine.Price, medicine.Stock, medicine.Description, medicine.CreatedAt, medicine.UpdatedAt).Scan(&returning.Id)
+	err := transaction.Run(ctx, m.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		queryStatement := "INSERT INTO medicines (name,medicine_type,price,stock,description,created_at,updated_at)VALUES($1,$2,$3,$4,$5,$6,$7) returning id"
+		if err := tx.QueryRowContext(ctx, queryStatement, medicine.Name, medicine.MedicineType, medicine.Price, 0, medicine.Description, medicine.CreatedAt, medicine.UpdatedAt).Scan(&returning.Id); err != nil {
+			return err
+		}
+
+		query := "INSERT INTO medicine_prices (medicine_id, price, effective_from, created_by, created_at) VALUES ($1, $2, $3, $4, $3);"
+		if _, err := tx.ExecContext(ctx, query, returning.Id, medicine.Price, medicine.CreatedAt, nullable(medicine.CreatedBy)); err != nil {
+			return err
+		}
+
+		if medicine.Stock == 0 {
+			return nil
+		}
+		_, err := inventoryRepository.ApplyMovement(ctx, tx, inventoryDto.Movement{MedicineID: returning.Id, MovementType: constants.MovementOpeningBalance, Quantity: medicine.Stock, CreatedBy: medicine.CreatedBy, CreatedAt: medicine.CreatedAt})
+		return err
+	})
+	if err != nil {
+		return dto.MedicineResponse{}, err
+	}
 
 	newMedicine := dto.MedicineResponse{Id: returning.Id, Name: medicine.Name, MedicineType: medicine.MedicineType, Price: medicine.Price, Stock: medicine.Stock, Description: medicine.Description, CreatedAt: medicine.CreatedAt}
-	return newMedicine, err
+	return newMedicine, nil
 }
 
-func (m *medicineRepository) Update(medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
-	sqlstament := "UPDATE medicines SET name=$2,price=$3,stock=$4,description=$5,updated_at=$6,medicine_type=$7 where id=$1 and deleted_at is null returning id,name,medicine_type,price,stock,description,created_at,updated_at;"
+// Update changes the details of the medicine. A price is added to its price
+// list from UpdatedAt on, so records made before keep the old price. With a
+// version given the medicine is only changed while it is still at it.
+func (m *medicineRepository) Update(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
 	var out dto.MedicineResponse
-	err := m.db.QueryRow(sqlstament, medicine.Id, medicine.Name, medicine.Price, medicine.Stock, medicine.Description, medicine.UpdatedAt, medicine.MedicineType).Scan(&out.Id, &out.Name, &out.MedicineType, &out.Price, &out.Stock, &out.Description, &out.CreatedAt, &out.UpdatedAt)
-	return out, err
+	err := transaction.Run(ctx, m.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		// Stock only changes through the inventory ledger
+		sqlstament := "UPDATE medicines SET name=$2,description=$3,updated_at=$4,medicine_type=$5,version=version+1 where id=$1 and deleted_at is null and ($6 = 0 or version=$6) returning id,name,medicine_type,stock,description,created_at,updated_at,version;"
+		err := tx.QueryRowContext(ctx, sqlstament, medicine.Id, medicine.Name, medicine.Description, medicine.UpdatedAt, medicine.MedicineType, medicine.Version).Scan(&out.Id, &out.Name, &out.MedicineType, &out.Stock, &out.Description, &out.CreatedAt, &out.UpdatedAt, &out.Version)
+		if err == sql.ErrNoRows && medicine.Version != 0 {
+			return errors.New(constants.ErrVersionConflict)
+		}
+		if err != nil {
+			return err
+		}
+
+		if medicine.Price != 0 {
+			req := priceDto.PriceRequest{ItemID: medicine.Id, Price: medicine.Price, EffectiveFrom: medicine.UpdatedAt, CreatedBy: medicine.CreatedBy}
+			if _, err := insertPrice(ctx, tx, req); err != nil {
+				return err
+			}
+		}
+
+		out.Price, err = PriceAt(ctx, tx, medicine.Id, medicine.UpdatedAt)
+		return err
+	})
+	if err != nil {
+		return dto.MedicineResponse{}, err
+	}
+
+	return out, nil
 }
 
-func (m *medicineRepository) Delete(id string, deletedAt string) error {
-	sqlstament := "UPDATE medicines SET deleted_at=$1 where id=$2;"
-	_, err := m.db.Exec(sqlstament, deletedAt, id)
+// Delete moves the medicine to the trash. With a version given the medicine is
+// only deleted while it is still at it.
+func (m *medicineRepository) Delete(ctx context.Context, id string, deletedAt string, version int) error {
+	sqlstament := "UPDATE medicines SET deleted_at=$1,version=version+1 where id=$2 and ($3 = 0 or version=$3);"
+	result, err := m.conn(ctx).ExecContext(ctx, sqlstament, deletedAt, id, version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
 	return err
 }
 
-func (m *medicineRepository) Restore(id string) error {
-	sqlstament := "UPDATE medicines SET deleted_at=null where id=$1;"
-	_, err := m.db.Exec(sqlstament, id)
+// Restore takes the medicine out of the trash. With a version given the
+// medicine is only restored while it is still at it.
+func (m *medicineRepository) Restore(ctx context.Context, id string, version int) error {
+	sqlstament := "UPDATE medicines SET deleted_at=null,version=version+1 where id=$1 and ($2 = 0 or version=$2);"
+	result, err := m.conn(ctx).ExecContext(ctx, sqlstament, id, version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
 	return err
 }
 
-func (m *medicineRepository) RetrieveAll() ([]dto.MedicineResponse, error) {
-	sqlstatement := "SELECT id, name, medicine_type, price, stock, description,created_at,updated_at,COALESCE(TO_CHAR(deleted_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS formatted_deleted_at FROM medicines WHERE deleted_at IS NULL;"
-	rows, err := m.db.Query(sqlstatement)
+// RetrieveAll returns a page of medicines and how many medicines match the
+// query in all. Sorting by price sorts by the current price, selected as price.
+func (m *medicineRepository) RetrieveAll(ctx context.Context, query queryDto.Query) ([]dto.MedicineResponse, int, error) {
+	conditions, args := utils.ListConditions(query, medicine.ListResource, nil)
+
+	var total int
+	if err := m.conn(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM medicines WHERE deleted_at IS NULL"+conditions+";", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order, args := utils.ListOrder(query, medicine.ListResource, args)
+	sqlstatement := "SELECT id, name, medicine_type, " + currentPrice + " AS price, stock, description,created_at,updated_at,COALESCE(TO_CHAR(deleted_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS formatted_deleted_at,version FROM medicines WHERE deleted_at IS NULL" + conditions + order + ";"
+	rows, err := m.conn(ctx).QueryContext(ctx, sqlstatement, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
-	expenses, err := scan(rows)
-	return expenses, err
+	medicines, err := scan(rows)
+	return medicines, total, err
 }
 
-func (m *medicineRepository) RetrieveById(id string) (dto.MedicineResponse, error) {
-	queryStatement := "SELECT id,name,medicine_type,price,stock,description,created_at,COALESCE(TO_CHAR(updated_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS formatted_updated_at from medicines where id=$1 and deleted_at is null"
+func (m *medicineRepository) RetrieveById(ctx context.Context, id string) (dto.MedicineResponse, error) {
+	queryStatement := "SELECT id,name,medicine_type," + currentPrice + ",stock,description,created_at,COALESCE(TO_CHAR(updated_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS formatted_updated_at,version from medicines where id=$1 and deleted_at is null"
 	var medicine dto.MedicineResponse
-	rows := m.db.QueryRow(queryStatement, id)
-	err := rows.Scan(&medicine.Id, &medicine.Name, &medicine.MedicineType, &medicine.Price, &medicine.Stock, &medicine.Description, &medicine.CreatedAt, &medicine.UpdatedAt)
+	rows := m.conn(ctx).QueryRowContext(ctx, queryStatement, id)
+	err := rows.Scan(&medicine.Id, &medicine.Name, &medicine.MedicineType, &medicine.Price, &medicine.Stock, &medicine.Description, &medicine.CreatedAt, &medicine.UpdatedAt, &medicine.Version)
 
 	return medicine, err
 }
 
-func (m *medicineRepository) Trash() ([]dto.MedicineResponse, error) {
-	sqlstatement := "SELECT id, name, medicine_type, price, stock, description,created_at,updated_at,COALESCE(TO_CHAR(deleted_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS formatted_deleted_at FROM medicines WHERE deleted_at IS NOT NULL;"
-	rows, err := m.db.Query(sqlstatement)
+// RetrieveByName finds the medicine with the name in the catalogue. Names are
+// not unique, so it fails when more than one medicine has it.
+func (m *medicineRepository) RetrieveByName(ctx context.Context, name string) (dto.MedicineResponse, error) {
+	sqlstatement := "SELECT id, name, medicine_type, " + currentPrice + ", stock, description,created_at,updated_at,'',version FROM medicines WHERE name = $1 AND deleted_at IS NULL LIMIT 2;"
+	rows, err := m.conn(ctx).QueryContext(ctx, sqlstatement, name)
+	if err != nil {
+		return dto.MedicineResponse{}, err
+	}
+	defer rows.Close()
+
+	medicines, err := scan(rows)
+	if err != nil {
+		return dto.MedicineResponse{}, err
+	}
+	switch len(medicines) {
+	case 0:
+		return dto.MedicineResponse{}, sql.ErrNoRows
+	case 1:
+		return medicines[0], nil
+	}
+	return dto.MedicineResponse{}, errors.New(constants.ErrMedicineNameAmbiguous)
+}
+
+func (m *medicineRepository) Trash(ctx context.Context) ([]dto.MedicineResponse, error) {
+	sqlstatement := "SELECT id, name, medicine_type, " + currentPrice + ", stock, description,created_at,updated_at,COALESCE(TO_CHAR(deleted_at, 'YYYY-MM-DD HH24:MI:SS'), '') AS formatted_deleted_at,version FROM medicines WHERE deleted_at IS NOT NULL;"
+	rows, err := m.conn(ctx).QueryContext(ctx, sqlstatement)
 	if err != nil {
 		return nil, err
 	}
@@ -74,12 +196,128 @@ func (m *medicineRepository) Trash() ([]dto.MedicineResponse, error) {
 	return expenses, err
 }
 
+func (m *medicineRepository) RetrievePrices(ctx context.Context, medicineID string) ([]priceDto.Price, error) {
+	query := `
+		SELECT id, price, TO_CHAR(effective_from, 'YYYY-MM-DD HH24:MI:SS'), COALESCE(created_by::text, ''),
+			TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM medicine_prices WHERE medicine_id = $1 ORDER BY effective_from DESC;`
+	rows, err := m.conn(ctx).QueryContext(ctx, query, medicineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []priceDto.Price
+	for rows.Next() {
+		var price priceDto.Price
+		if err := rows.Scan(&price.ID, &price.Price, &price.EffectiveFrom, &price.CreatedBy, &price.CreatedAt); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// InsertPrice adds a price to the price list and moves the medicine to a new
+// version, its ETag changes with the price it is sold at. With a version given
+// the price is only added while the medicine is still at it.
+func (m *medicineRepository) InsertPrice(ctx context.Context, req priceDto.PriceRequest) (string, error) {
+	var id string
+	err := transaction.Run(ctx, m.db, func(ctx context.Context) error {
+		var err error
+		id, err = insertPrice(ctx, transaction.Tx(ctx), req)
+		if err != nil {
+			return err
+		}
+
+		return bumpVersion(ctx, req.ItemID, req.Version)
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// DeletePrice cancels a price change that has not taken effect at now yet and
+// moves the medicine to a new version. With a version given the price is only
+// canceled while the medicine is still at it.
+func (m *medicineRepository) DeletePrice(ctx context.Context, medicineID, priceID, now string, version int) error {
+	return transaction.Run(ctx, m.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		var scheduled bool
+		query := "SELECT effective_from > $3 FROM medicine_prices WHERE id = $1 AND medicine_id = $2;"
+		if err := tx.QueryRowContext(ctx, query, priceID, medicineID, now).Scan(&scheduled); err != nil {
+			return err
+		}
+
+		if !scheduled {
+			return errors.New(constants.ErrPriceNotScheduled)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM medicine_prices WHERE id = $1;", priceID); err != nil {
+			return err
+		}
+		return bumpVersion(ctx, medicineID, version)
+	})
+}
+
+func (m *medicineRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	return utils.PurgeTrash(ctx, m.db, "medicines", deletedBefore)
+}
+
+// PriceAt returns the price of the medicine in effect at the given time within
+// the transaction of the caller.
+func PriceAt(ctx context.Context, tx *sql.Tx, medicineID, at string) (int, error) {
+	var price int
+	query := `
+		SELECT COALESCE((SELECT price FROM medicine_prices WHERE medicine_id = $1 AND effective_from <= $2 ORDER BY effective_from DESC LIMIT 1),
+			(SELECT price FROM medicines WHERE id = $1));`
+	err := tx.QueryRowContext(ctx, query, medicineID, at).Scan(&price)
+	return price, err
+}
+
+// insertPrice adds the price to the price list of the medicine. A price set
+// for the same moment replaces the earlier one.
+func insertPrice(ctx context.Context, tx *sql.Tx, req priceDto.PriceRequest) (string, error) {
+	var id string
+	query := `
+		INSERT INTO medicine_prices (medicine_id, price, effective_from, created_by, created_at) VALUES ($1, $2, $3, $4, LOCALTIMESTAMP)
+		ON CONFLICT (medicine_id, effective_from) DO UPDATE SET price = EXCLUDED.price, created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at
+		RETURNING id;`
+	err := tx.QueryRowContext(ctx, query, req.ItemID, req.Price, req.EffectiveFrom, nullable(req.CreatedBy)).Scan(&id)
+	return id, err
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// bumpVersion moves the medicine to a new version within the transaction of
+// ctx, failing with a version conflict when a version is given and the
+// medicine is no longer at it.
+func bumpVersion(ctx context.Context, medicineID string, version int) error {
+	result, err := transaction.Tx(ctx).ExecContext(ctx, "UPDATE medicines SET version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2);", medicineID, version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err == nil && affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	return err
+}
+
 func scan(rows *sql.Rows) ([]dto.MedicineResponse, error) {
 	Exp := []dto.MedicineResponse{}
 	var err error
 	for rows.Next() {
 		medicine := dto.MedicineResponse{}
-		err := rows.Scan(&medicine.Id, &medicine.Name, &medicine.MedicineType, &medicine.Price, &medicine.Stock, &medicine.Description, &medicine.CreatedAt, &medicine.UpdatedAt, &medicine.DeletedAt)
+		err := rows.Scan(&medicine.Id, &medicine.Name, &medicine.MedicineType, &medicine.Price, &medicine.Stock, &medicine.Description, &medicine.CreatedAt, &medicine.UpdatedAt, &medicine.DeletedAt, &medicine.Version)
 		if err != nil {
 			return nil, err
 
@@ -2,7 +2,11 @@ package medicineRepository
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/src/medicine"
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"testing"
@@ -26,23 +30,45 @@ func (suite *medicineRepositoryTestSuite) SetupTest() {
 
 // Start Get All
 func (suite *medicineRepositoryTestSuite) TestRetrieveAllSuccess() {
-	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at"})
-	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", ""}
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"})
+	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", "", 1}
 
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	suite.mock.ExpectQuery("SELECT").
+		WithArgs(10, 0).
 		WillReturnRows(rows.AddRow(row...))
 		
-	actual, err := suite.medicineRepo.RetrieveAll()
+	actual, total, err := suite.medicineRepo.RetrieveAll(context.Background(), queryDto.Query{Page: 1, Size: 10})
 
 	suite.Nil(err)
 	suite.NotEmpty(actual)
+	suite.Equal(1, total)
+}
+
+func (suite *medicineRepositoryTestSuite) TestRetrieveAllByType() {
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"})
+	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", "", 1}
+
+	suite.mock.ExpectQuery(`SELECT COUNT\(\*\) FROM medicines WHERE deleted_at IS NULL AND medicine_type::text = \$1;`).
+		WithArgs("CAIR").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	suite.mock.ExpectQuery(`AND medicine_type::text = \$1 ORDER BY stock, id LIMIT \$2 OFFSET \$3;`).
+		WithArgs("CAIR", 10, 0).
+		WillReturnRows(rows.AddRow(row...))
+
+	query := queryDto.Query{Page: 1, Size: 10, Sort: []queryDto.Sort{{Field: "stock"}}, Filters: []queryDto.Filter{{Field: "medicine_type", Value: "CAIR"}}}
+	actual, _, err := suite.medicineRepo.RetrieveAll(context.Background(), query)
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+	suite.Nil(suite.mock.ExpectationsWereMet())
 }
 
 func (suite *medicineRepositoryTestSuite) TestRetrieveAllError() {
 	suite.mock.ExpectQuery("SELECT").
 		WillReturnError(sql.ErrConnDone)
 		
-	actual, err := suite.medicineRepo.RetrieveAll()
+	actual, _, err := suite.medicineRepo.RetrieveAll(context.Background(), queryDto.Query{Page: 1, Size: 10})
 
 	suite.Error(err)
 	suite.Empty(actual)
@@ -51,23 +77,23 @@ func (suite *medicineRepositoryTestSuite) TestRetrieveAllError() {
 
 // Start Trash
 func (suite *medicineRepositoryTestSuite) TestTrashSuccess() {
-	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at"})
-	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", ""}
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"})
+	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", "2024-03-13 08:00:00", 3}
 
 	suite.mock.ExpectQuery("SELECT").
 		WillReturnRows(rows.AddRow(row...))
 		
-	actual, err := suite.medicineRepo.Trash()
+	actual, err := suite.medicineRepo.Trash(context.Background())
 
 	suite.Nil(err)
-	suite.NotEmpty(actual)
+	suite.Equal(3, actual[0].Version)
 }
 
 func (suite *medicineRepositoryTestSuite) TestTrashError() {
 	suite.mock.ExpectQuery("SELECT").
 		WillReturnError(sql.ErrConnDone)
 		
-	actual, err := suite.medicineRepo.Trash()
+	actual, err := suite.medicineRepo.Trash(context.Background())
 
 	suite.Error(err)
 	suite.Empty(actual)
@@ -75,27 +101,86 @@ func (suite *medicineRepositoryTestSuite) TestTrashError() {
 // End Trash
 
 func (suite *medicineRepositoryTestSuite) TestRetrieveByIdSuccess() {
-	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at"})
-	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06"}
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "version"})
+	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", 3}
 
 	suite.mock.ExpectQuery("SELECT").
 		WithArgs("1").
 		WillReturnRows(rows.AddRow(row...))
 		
-	actual, err := suite.medicineRepo.RetrieveById("1")
+	actual, err := suite.medicineRepo.RetrieveById(context.Background(), "1")
 
 	suite.Nil(err)
 	suite.NotEmpty(actual)
+	suite.Equal(3, actual.Version)
+}
+
+func (suite *medicineRepositoryTestSuite) TestRetrieveAllWithoutLimit() {
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"})
+
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	suite.mock.ExpectQuery(`LIMIT \$1 OFFSET \$2;`).
+		WithArgs(nil, 0).
+		WillReturnRows(rows)
+
+	_, _, err := suite.medicineRepo.RetrieveAll(context.Background(), queryDto.Query{Page: 1})
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestRetrieveByNameSuccess() {
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"})
+
+	suite.mock.ExpectQuery("FROM medicines WHERE name = (.+) AND deleted_at IS NULL LIMIT 2;").
+		WithArgs("Komik").
+		WillReturnRows(rows.AddRow("1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", "", 1))
+
+	actual, err := suite.medicineRepo.RetrieveByName(context.Background(), "Komik")
+
+	suite.Nil(err)
+	suite.Equal("1", actual.Id)
+}
+
+func (suite *medicineRepositoryTestSuite) TestRetrieveByNameNotFound() {
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"})
+	suite.mock.ExpectQuery("FROM medicines WHERE name").WithArgs("Komik").WillReturnRows(rows)
+
+	_, err := suite.medicineRepo.RetrieveByName(context.Background(), "Komik")
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *medicineRepositoryTestSuite) TestRetrieveByNameAmbiguous() {
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at", "deleted_at", "version"}).
+		AddRow("1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", "", 1).
+		AddRow("2", "Komik", "TABLET", 4000, 100, nil, "2024-03-12 16:06", "2024-03-12 16:06", "", 1)
+	suite.mock.ExpectQuery("FROM medicines WHERE name").WithArgs("Komik").WillReturnRows(rows)
+
+	_, err := suite.medicineRepo.RetrieveByName(context.Background(), "Komik")
+
+	suite.EqualError(err, constants.ErrMedicineNameAmbiguous)
 }
 
 func (suite *medicineRepositoryTestSuite) TestCreateSuccess() {
 	rows := sqlmock.NewRows([]string{"id"})
-	args := []driver.Value{"Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06"}
+	args := []driver.Value{"Komik", "CAIR", 5000, 0, nil, "2024-03-12 16:06", "2024-03-12 16:06"}
 	row := []driver.Value{"1"}
 
+	suite.mock.ExpectBegin()
 	suite.mock.ExpectQuery("INSERT INTO medicines").
 		WithArgs(args...).
 		WillReturnRows(rows.AddRow(row...))
+	suite.mock.ExpectExec("INSERT INTO medicine_prices").
+		WithArgs("1", 5000, "2024-03-12 16:06", "u1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	suite.mock.ExpectQuery("UPDATE medicines SET stock").
+		WithArgs(200, "2024-03-12 16:06", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(200))
+	suite.mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("1", nil, "OPENING_BALANCE", 200, 200, nil, nil, nil, "u1", "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	suite.mock.ExpectCommit()
 		
 	request := dto.MedicineRequest{
 		Id: "1",
@@ -103,24 +188,36 @@ func (suite *medicineRepositoryTestSuite) TestCreateSuccess() {
 		MedicineType: "CAIR",
 		Price: 5000,
 		Stock: 200,
+		CreatedBy: "u1",
 		CreatedAt: "2024-03-12 16:06",
 		UpdatedAt: "2024-03-12 16:06",
 	}
 	
-	actual, err := suite.medicineRepo.Create(request)
+	actual, err := suite.medicineRepo.Create(context.Background(), request)
 
 	suite.Nil(err)
 	suite.NotEmpty(actual)
+	suite.Nil(suite.mock.ExpectationsWereMet())
 }
 
 func (suite *medicineRepositoryTestSuite) TestUpdateSuccess() {
-	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "price", "stock", "description", "created_at", "updated_at"})
-	args := []driver.Value{"1", "Komik", 5000, 200, nil, "2024-03-12 16:06", "CAIR"}
-	row := []driver.Value{"1", "Komik", "CAIR", 5000, 200, nil, "2024-03-12 16:06", "2024-03-12 16:06"}
+	rows := sqlmock.NewRows([]string{"id", "name", "medicine_type", "stock", "description", "created_at", "updated_at", "version"})
+	args := []driver.Value{"1", "Komik", nil, "2024-03-12 16:06", "CAIR", 2}
+	row := []driver.Value{"1", "Komik", "CAIR", 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", 3}
 
+	suite.mock.ExpectBegin()
 	suite.mock.ExpectQuery("UPDATE medicines").
 		WithArgs(args...).
 		WillReturnRows(rows.AddRow(row...))
+
+	// The new price applies from the update on
+	suite.mock.ExpectQuery("INSERT INTO medicine_prices").
+		WithArgs("1", 5000, "2024-03-12 16:06", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p2"))
+	suite.mock.ExpectQuery("SELECT COALESCE(.+) FROM medicine_prices").
+		WithArgs("1", "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(5000))
+	suite.mock.ExpectCommit()
 		
 	request := dto.MedicineRequest{
 		Id: "1",
@@ -128,40 +225,175 @@ func (suite *medicineRepositoryTestSuite) TestUpdateSuccess() {
 		MedicineType: "CAIR",
 		Price: 5000,
 		Stock: 200,
+		CreatedBy: "u1",
 		CreatedAt: "2024-03-12 16:06",
 		UpdatedAt: "2024-03-12 16:06",
+		Version: 2,
 	}
 	
-	actual, err := suite.medicineRepo.Update(request)
+	actual, err := suite.medicineRepo.Update(context.Background(), request)
 
 	suite.Nil(err)
-	suite.NotEmpty(actual)
+	suite.Equal(5000, actual.Price)
+	suite.Equal(3, actual.Version)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestUpdateWithoutPrice() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("UPDATE medicines").
+		WithArgs("1", "Komik", nil, "2024-03-12 16:06", "CAIR", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "medicine_type", "stock", "description", "created_at", "updated_at", "version"}).
+			AddRow("1", "Komik", "CAIR", 200, nil, "2024-03-12 16:06", "2024-03-12 16:06", 2))
+	suite.mock.ExpectQuery("SELECT COALESCE(.+) FROM medicine_prices").
+		WithArgs("1", "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(4500))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.medicineRepo.Update(context.Background(), dto.MedicineRequest{Id: "1", Name: "Komik", MedicineType: "CAIR", UpdatedAt: "2024-03-12 16:06"})
+
+	suite.Nil(err)
+	suite.Equal(4500, actual.Price)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestUpdateVersionConflict() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`UPDATE medicines (.+) \(\$6 = 0 or version=\$6\)`).
+		WithArgs("1", "Komik", nil, "2024-03-12 16:06", "CAIR", 2).
+		WillReturnError(sql.ErrNoRows)
+	suite.mock.ExpectRollback()
+
+	_, err := suite.medicineRepo.Update(context.Background(), dto.MedicineRequest{Id: "1", Name: "Komik", MedicineType: "CAIR", UpdatedAt: "2024-03-12 16:06", Version: 2})
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestRetrievePricesSuccess() {
+	rows := sqlmock.NewRows([]string{"id", "price", "effective_from", "created_by", "created_at"}).
+		AddRow("p2", 6000, "2024-04-01 00:00:00", "u1", "2024-03-12 16:06:00").
+		AddRow("p1", 5000, "2024-01-01 00:00:00", "", "2024-01-01 00:00:00")
+
+	suite.mock.ExpectQuery("FROM medicine_prices WHERE medicine_id = (.+) ORDER BY effective_from DESC").
+		WithArgs("1").
+		WillReturnRows(rows)
+
+	actual, err := suite.medicineRepo.RetrievePrices(context.Background(), "1")
+
+	suite.Nil(err)
+	suite.Len(actual, 2)
+	suite.Equal(6000, actual[0].Price)
+}
+
+func (suite *medicineRepositoryTestSuite) TestInsertPriceBumpsVersion() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medicine_prices").
+		WithArgs("1", 6000, "2024-04-01 00:00:00", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p3"))
+	suite.mock.ExpectExec(`UPDATE medicines SET version = version \+ 1`).
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	id, err := suite.medicineRepo.InsertPrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "2024-04-01 00:00:00", CreatedBy: "u1", Version: 2})
+
+	suite.Nil(err)
+	suite.Equal("p3", id)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestInsertPriceVersionConflict() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medicine_prices").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("p3"))
+	suite.mock.ExpectExec(`UPDATE medicines SET version = version \+ 1`).
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectRollback()
+
+	_, err := suite.medicineRepo.InsertPrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "2024-04-01 00:00:00", CreatedBy: "u1", Version: 2})
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestDeletePriceSuccess() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT effective_from > (.+) FROM medicine_prices").
+		WithArgs("p2", "1", "2024-03-12 16:06:00").
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled"}).AddRow(true))
+	suite.mock.ExpectExec("DELETE FROM medicine_prices").
+		WithArgs("p2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE medicines SET version = version \+ 1`).
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	err := suite.medicineRepo.DeletePrice(context.Background(), "1", "p2", "2024-03-12 16:06:00", 2)
+
+	suite.Nil(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineRepositoryTestSuite) TestDeletePriceNotScheduled() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("SELECT effective_from > (.+) FROM medicine_prices").
+		WithArgs("p1", "1", "2024-03-12 16:06:00").
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled"}).AddRow(false))
+	suite.mock.ExpectRollback()
+
+	err := suite.medicineRepo.DeletePrice(context.Background(), "1", "p1", "2024-03-12 16:06:00", 2)
+
+	suite.EqualError(err, constants.ErrPriceNotScheduled)
+	suite.Nil(suite.mock.ExpectationsWereMet())
 }
 
 func (suite *medicineRepositoryTestSuite) TestDeleteSuccess() {
-	args := []driver.Value{"2024-03-12 16:06", "1"}
+	args := []driver.Value{"2024-03-12 16:06", "1", 2}
 
 	suite.mock.ExpectExec("UPDATE medicines").
 		WithArgs(args...).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	
-	err := suite.medicineRepo.Delete("1", "2024-03-12 16:06")
+	err := suite.medicineRepo.Delete(context.Background(), "1", "2024-03-12 16:06", 2)
 
 	suite.Nil(err)
 }
 
+func (suite *medicineRepositoryTestSuite) TestDeleteVersionConflict() {
+	suite.mock.ExpectExec("UPDATE medicines SET deleted_at").
+		WithArgs("2024-03-12 16:06", "1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.medicineRepo.Delete(context.Background(), "1", "2024-03-12 16:06", 2)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 func (suite *medicineRepositoryTestSuite) TestRestoreSuccess() {
-	args := []driver.Value{"1"}
+	args := []driver.Value{"1", 2}
 
 	suite.mock.ExpectExec("UPDATE medicines").
 		WithArgs(args...).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	
-	err := suite.medicineRepo.Restore("1")
+	err := suite.medicineRepo.Restore(context.Background(), "1", 2)
 
 	suite.Nil(err)
 }
 
+func (suite *medicineRepositoryTestSuite) TestRestoreVersionConflict() {
+	suite.mock.ExpectExec("UPDATE medicines SET deleted_at=null").
+		WithArgs("1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.medicineRepo.Restore(context.Background(), "1", 2)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 func TestMedicineRepositoryTestSuite(t *testing.T) {
 	suite.Run(t, new(medicineRepositoryTestSuite))
 }
\ No newline at end of file
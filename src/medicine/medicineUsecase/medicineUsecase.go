@@ -2,7 +2,13 @@ package medicineUsecase
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/medicine"
+	"context"
+	"errors"
 	"time"
 )
 
@@ -14,32 +20,39 @@ func NewMedicineUsecase(medicineRepo medicine.MedicineRepository) medicine.Medic
 	return &medicineUC{medicineRepo}
 }
 
-func (m *medicineUC) GetAll() ([]dto.MedicineResponse, error) {
-	all, err := m.medicineRepo.RetrieveAll()
-	return all, err
+func (m *medicineUC) GetAll(ctx context.Context, query queryDto.Query) ([]dto.MedicineResponse, queryDto.Paging, error) {
+	all, total, err := m.medicineRepo.RetrieveAll(ctx, query)
+	return all, utils.NewPaging(query, total), err
 }
 
-func (m *medicineUC) GetById(id string) (dto.MedicineResponse, error) {
-	all, err := m.medicineRepo.RetrieveById(id)
+func (m *medicineUC) GetById(ctx context.Context, id string) (dto.MedicineResponse, error) {
+	all, err := m.medicineRepo.RetrieveById(ctx, id)
 	return all, err
 }
 
-func (m *medicineUC) CreateRecord(medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
+func (m *medicineUC) CreateRecord(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
 	var new dto.MedicineResponse
 	var err error
 	newCreatedAt := time.Now().Format("2006-01-02 15:04:05")
 	newUpdatedAt := time.Now().Format("2006-01-02 15:04:05")
-	newMedicine := dto.MedicineRequest{Name: medicine.Name, MedicineType: medicine.MedicineType, Price: medicine.Price, Stock: medicine.Stock, Description: medicine.Description, CreatedAt: newCreatedAt, UpdatedAt: newUpdatedAt}
-	new, err = m.medicineRepo.Create(newMedicine)
+	newMedicine := dto.MedicineRequest{Name: medicine.Name, MedicineType: medicine.MedicineType, Price: medicine.Price, Stock: medicine.Stock, Description: medicine.Description, CreatedBy: medicine.CreatedBy, CreatedAt: newCreatedAt, UpdatedAt: newUpdatedAt}
+	new, err = m.medicineRepo.Create(ctx, newMedicine)
 	return new, err
 }
 
-func (m *medicineUC) UpdateRecord(Updated dto.UpdateRequest) (dto.MedicineResponse, error) {
-	action, err := m.medicineRepo.RetrieveById(Updated.Id)
+// UpdateRecord changes the medicine. A version given in the request has to be
+// the current one, the change is then made against the version read here so
+// concurrent changes cannot overwrite each other.
+func (m *medicineUC) UpdateRecord(ctx context.Context, Updated dto.UpdateRequest) (dto.MedicineResponse, error) {
+	action, err := m.medicineRepo.RetrieveById(ctx, Updated.Id)
 	if err != nil {
 		return dto.MedicineResponse{}, err
 	}
 
+	if Updated.Version != 0 && Updated.Version != action.Version {
+		return dto.MedicineResponse{}, errors.New(constants.ErrVersionConflict)
+	}
+
 	if Updated.Name == "" {
 		Updated.Name = action.Name
 	}
@@ -48,12 +61,9 @@ func (m *medicineUC) UpdateRecord(Updated dto.UpdateRequest) (dto.MedicineRespon
 		Updated.MedicineType = action.MedicineType
 	}
 
-	if Updated.Price == 0 {
-		Updated.Price = action.Price
-	}
-
-	if Updated.Stock == 0 {
-		Updated.Stock = action.Stock
+	// Only a new price is added to the price list
+	if Updated.Price == action.Price {
+		Updated.Price = 0
 	}
 
 	if Updated.Description == nil {
@@ -61,29 +71,91 @@ func (m *medicineUC) UpdateRecord(Updated dto.UpdateRequest) (dto.MedicineRespon
 	}
 	var all dto.MedicineResponse
 	Updated.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
-	product := dto.MedicineRequest{Id: Updated.Id, Name: Updated.Name, MedicineType: Updated.MedicineType, Price: Updated.Price, Stock: Updated.Stock, Description: Updated.Description, UpdatedAt: Updated.UpdatedAt}
+	product := dto.MedicineRequest{Id: Updated.Id, Name: Updated.Name, MedicineType: Updated.MedicineType, Price: Updated.Price, Description: Updated.Description, CreatedBy: Updated.UpdatedBy, UpdatedAt: Updated.UpdatedAt, Version: action.Version}
 	
-	all, err = m.medicineRepo.Update(product)
+	all, err = m.medicineRepo.Update(ctx, product)
 	return all, err
 }
 
-func (m *medicineUC) DeleteRecord(id string) error {
-	_, err :=  m.medicineRepo.RetrieveById(id)
+// DeleteRecord moves the medicine to the trash, only while it is at the given
+// version unless the version is 0.
+func (m *medicineUC) DeleteRecord(ctx context.Context, id string, version int) error {
+	current, err :=  m.medicineRepo.RetrieveById(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	if version != 0 && version != current.Version {
+		return errors.New(constants.ErrVersionConflict)
+	}
+
 	deletedAt := time.Now().Format("2006-01-02 15:04:05")
-	err = m.medicineRepo.Delete(id, deletedAt)
+	err = m.medicineRepo.Delete(ctx, id, deletedAt, current.Version)
 	return err
 }
 
-func (m *medicineUC) TrashRecord() ([]dto.MedicineResponse, error) {
-	all, err := m.medicineRepo.Trash()
+func (m *medicineUC) TrashRecord(ctx context.Context) ([]dto.MedicineResponse, error) {
+	all, err := m.medicineRepo.Trash(ctx)
 	return all, err
 }
 
-func (m *medicineUC) RestoreRecord(id string) error {
-	err := m.medicineRepo.Restore(id)
+// RestoreRecord takes the medicine out of the trash, only while it is at the
+// given version unless the version is 0.
+func (m *medicineUC) RestoreRecord(ctx context.Context, id string, version int) error {
+	err := m.medicineRepo.Restore(ctx, id, version)
 	return err
 }
+
+func (m *medicineUC) GetPrices(ctx context.Context, medicineID string) ([]priceDto.Price, error) {
+	if _, err := m.medicineRepo.RetrieveById(ctx, medicineID); err != nil {
+		return nil, err
+	}
+
+	prices, err := m.medicineRepo.RetrievePrices(ctx, medicineID)
+	if err != nil {
+		return nil, err
+	}
+	utils.MarkPriceStatus(prices, utils.GetNow())
+	return prices, nil
+}
+
+// SchedulePrice adds a price to the price list of the medicine, effective
+// immediately or from a later date. A version given in the request has to be
+// the current one.
+func (m *medicineUC) SchedulePrice(ctx context.Context, req priceDto.PriceRequest) ([]priceDto.Price, error) {
+	var err error
+	if req.EffectiveFrom, err = utils.EffectiveFrom(req.EffectiveFrom, utils.GetNow()); err != nil {
+		return nil, err
+	}
+
+	current, err := m.medicineRepo.RetrieveById(ctx, req.ItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Version != 0 && req.Version != current.Version {
+		return nil, errors.New(constants.ErrVersionConflict)
+	}
+	req.Version = current.Version
+
+	if _, err := m.medicineRepo.InsertPrice(ctx, req); err != nil {
+		return nil, err
+	}
+	return m.GetPrices(ctx, req.ItemID)
+}
+
+// CancelPrice removes a price change that has not taken effect yet, only while
+// the medicine is at the given version unless the version is 0.
+func (m *medicineUC) CancelPrice(ctx context.Context, medicineID, priceID string, version int) error {
+	return m.medicineRepo.DeletePrice(ctx, medicineID, priceID, utils.GetNow(), version)
+}
+
+// PurgeTrash deletes the medicines that have been in the trash for more than
+// retentionDays. Those still referenced by other rows stay in the trash.
+func (m *medicineUC) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	deletedBefore, err := utils.RetentionCutoff(retentionDays)
+	if err != nil {
+		return dto.Purged{}, err
+	}
+	return m.medicineRepo.PurgeTrash(ctx, deletedBefore)
+}
@@ -2,8 +2,15 @@ package medicineUsecase
 
 import (
 	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/priceDto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/src/medicine"
+	"context"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -15,41 +22,66 @@ type mockMedicineRepository struct {
 	mock.Mock
 }
 
-func (mock *mockMedicineRepository) RetrieveAll() ([]dto.MedicineResponse, error) {
-	args := mock.Called()
-	return args.Get(0).([]dto.MedicineResponse), args.Error(1)
+func (mock *mockMedicineRepository) RetrieveAll(ctx context.Context, query queryDto.Query) ([]dto.MedicineResponse, int, error) {
+	args := mock.Called(query)
+	return args.Get(0).([]dto.MedicineResponse), args.Int(1), args.Error(2)
 }
 
-func (mock *mockMedicineRepository) RetrieveById(id string) (dto.MedicineResponse, error) {
+func (mock *mockMedicineRepository) RetrieveById(ctx context.Context, id string) (dto.MedicineResponse, error) {
 	args := mock.Called(id)
 	return args.Get(0).(dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineRepository) Create(medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
+func (mock *mockMedicineRepository) RetrieveByName(ctx context.Context, name string) (dto.MedicineResponse, error) {
+	args := mock.Called(name)
+	return args.Get(0).(dto.MedicineResponse), args.Error(1)
+}
+
+func (mock *mockMedicineRepository) Create(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
 	args := mock.Called(medicine)
 	return args.Get(0).(dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineRepository) Update(medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
+func (mock *mockMedicineRepository) Update(ctx context.Context, medicine dto.MedicineRequest) (dto.MedicineResponse, error) {
 	args := mock.Called(medicine)
 	return args.Get(0).(dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineRepository) Delete(id string, deletedAt string) error {
-	args := mock.Called(id, deletedAt)
+func (mock *mockMedicineRepository) Delete(ctx context.Context, id string, deletedAt string, version int) error {
+	args := mock.Called(id, deletedAt, version)
 	return args.Error(0)
 }
 
-func (mock *mockMedicineRepository) Trash() ([]dto.MedicineResponse, error) {
+func (mock *mockMedicineRepository) Trash(ctx context.Context) ([]dto.MedicineResponse, error) {
 	args := mock.Called()
 	return args.Get(0).([]dto.MedicineResponse), args.Error(1)
 }
 
-func (mock *mockMedicineRepository) Restore(id string) error {
-	args := mock.Called(id)
+func (mock *mockMedicineRepository) Restore(ctx context.Context, id string, version int) error {
+	args := mock.Called(id, version)
+	return args.Error(0)
+}
+
+func (mock *mockMedicineRepository) RetrievePrices(ctx context.Context, medicineID string) ([]priceDto.Price, error) {
+	args := mock.Called(medicineID)
+	return args.Get(0).([]priceDto.Price), args.Error(1)
+}
+
+func (mock *mockMedicineRepository) InsertPrice(ctx context.Context, req priceDto.PriceRequest) (string, error) {
+	args := mock.Called(req)
+	return args.String(0), args.Error(1)
+}
+
+func (mock *mockMedicineRepository) DeletePrice(ctx context.Context, medicineID, priceID, now string, version int) error {
+	args := mock.Called(medicineID, priceID, now, version)
 	return args.Error(0)
 }
 
+func (mock *mockMedicineRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	args := mock.Called(deletedBefore)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
 type medicineUsecaseTestSuite struct {
 	suite.Suite
 	medicineRepo *mockMedicineRepository
@@ -66,18 +98,20 @@ func (suite *medicineUsecaseTestSuite) TestGetAllSuccess() {
 		{Id: "1", Name: "Komik", MedicineType: "CAIR"},
 	}
 
-	suite.medicineRepo.On("RetrieveAll").Return(expected, nil)
-	actual, err := suite.medicineUC.GetAll()
+	query := queryDto.Query{Page: 1, Size: 10}
+	suite.medicineRepo.On("RetrieveAll", query).Return(expected, 1, nil)
+	actual, paging, err := suite.medicineUC.GetAll(context.Background(), query)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
+	suite.Equal(queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, paging)
 }
 
 func (suite *medicineUsecaseTestSuite) TestGetByIdSuccess() {
 	expected := dto.MedicineResponse{Id: "1", Name: "Komik", MedicineType: "CAIR"}
 
 	suite.medicineRepo.On("RetrieveById", mock.Anything).Return(expected, nil)
-	actual, err := suite.medicineUC.GetById("1")
+	actual, err := suite.medicineUC.GetById(context.Background(), "1")
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
@@ -93,7 +127,7 @@ func (suite *medicineUsecaseTestSuite) TestCreateRecordSuccess() {
 	}
 
 	suite.medicineRepo.On("Create", mock.Anything).Return(expected, nil)
-	actual, err := suite.medicineUC.CreateRecord(request)
+	actual, err := suite.medicineUC.CreateRecord(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
@@ -110,12 +144,49 @@ func (suite *medicineUsecaseTestSuite) TestUpdateRecordSuccess() {
 
 	suite.medicineRepo.On("RetrieveById", mock.Anything).Return(expected, nil)
 	suite.medicineRepo.On("Update", mock.Anything).Return(expected, nil)
-	actual, err := suite.medicineUC.UpdateRecord(request)
+	actual, err := suite.medicineUC.UpdateRecord(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
 }
 
+func (suite *medicineUsecaseTestSuite) TestUpdateRecordSamePrice() {
+	current := dto.MedicineResponse{Id: "1", Name: "Komik", MedicineType: "CAIR", Price: 5000}
+
+	suite.medicineRepo.On("RetrieveById", "1").Return(current, nil)
+	suite.medicineRepo.On("Update", mock.MatchedBy(func(req dto.MedicineRequest) bool {
+		return req.Price == 0 && req.Name == "Komik 1" && req.CreatedBy == "u1"
+	})).Return(current, nil)
+
+	_, err := suite.medicineUC.UpdateRecord(context.Background(), dto.UpdateRequest{Id: "1", Name: "Komik 1", Price: 5000, UpdatedBy: "u1"})
+
+	suite.Nil(err)
+	suite.medicineRepo.AssertExpectations(suite.T())
+}
+
+func (suite *medicineUsecaseTestSuite) TestUpdateRecordAgainstReadVersion() {
+	current := dto.MedicineResponse{Id: "1", Name: "Komik", MedicineType: "CAIR", Price: 5000, Version: 3}
+
+	suite.medicineRepo.On("RetrieveById", "1").Return(current, nil)
+	suite.medicineRepo.On("Update", mock.MatchedBy(func(req dto.MedicineRequest) bool {
+		return req.Version == 3
+	})).Return(current, nil)
+
+	_, err := suite.medicineUC.UpdateRecord(context.Background(), dto.UpdateRequest{Id: "1", Name: "Komik 1", Version: 3})
+
+	suite.Nil(err)
+	suite.medicineRepo.AssertExpectations(suite.T())
+}
+
+func (suite *medicineUsecaseTestSuite) TestUpdateRecordVersionConflict() {
+	suite.medicineRepo.On("RetrieveById", "1").Return(dto.MedicineResponse{Id: "1", Name: "Komik", Version: 3}, nil)
+
+	_, err := suite.medicineUC.UpdateRecord(context.Background(), dto.UpdateRequest{Id: "1", Name: "Komik 1", Version: 2})
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.medicineRepo.AssertNotCalled(suite.T(), "Update", mock.Anything)
+}
+
 func (suite *medicineUsecaseTestSuite) TestUpdateRecordErrorNotFound() {
 	expected := dto.MedicineResponse{}
 
@@ -124,7 +195,7 @@ func (suite *medicineUsecaseTestSuite) TestUpdateRecordErrorNotFound() {
 	}
 
 	suite.medicineRepo.On("RetrieveById", mock.Anything).Return(expected, sql.ErrNoRows)
-	actual, err := suite.medicineUC.UpdateRecord(request)
+	actual, err := suite.medicineUC.UpdateRecord(context.Background(), request)
 
 	suite.Error(err)
 	suite.Equal(expected, actual)
@@ -136,17 +207,26 @@ func (suite *medicineUsecaseTestSuite) TestDeleteRecordSuccess() {
 	expected := dto.MedicineResponse{Id: "1", Name: "Komik 1", MedicineType: "CAIR"}
 
 	suite.medicineRepo.On("RetrieveById", mock.Anything).Return(expected, nil)
-	suite.medicineRepo.On("Delete", mock.Anything, mock.Anything).Return(nil)
-	err := suite.medicineUC.DeleteRecord("1")
+	suite.medicineRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	err := suite.medicineUC.DeleteRecord(context.Background(), "1", 0)
 
 	suite.Nil(err)
 }
 
+func (suite *medicineUsecaseTestSuite) TestDeleteRecordVersionConflict() {
+	suite.medicineRepo.On("RetrieveById", "1").Return(dto.MedicineResponse{Id: "1", Version: 3}, nil)
+
+	err := suite.medicineUC.DeleteRecord(context.Background(), "1", 2)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.medicineRepo.AssertNotCalled(suite.T(), "Delete", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func (suite *medicineUsecaseTestSuite) TestDeleteRecordErrorNotFound() {
 	expected := dto.MedicineResponse{}
 
 	suite.medicineRepo.On("RetrieveById", mock.Anything).Return(expected, sql.ErrNoRows)
-	err := suite.medicineUC.DeleteRecord("1")
+	err := suite.medicineUC.DeleteRecord(context.Background(), "1", 0)
 
 	suite.Error(err)
 }
@@ -156,17 +236,189 @@ func (suite *medicineUsecaseTestSuite) TestTrashRecordSuccess() {
 	expected := []dto.MedicineResponse{{Id: "1", Name: "Komik 1", MedicineType: "CAIR"}}
 
 	suite.medicineRepo.On("Trash").Return(expected, nil)
-	actual, err := suite.medicineUC.TrashRecord()
+	actual, err := suite.medicineUC.TrashRecord(context.Background())
 
 	suite.Nil(err)
 	suite.Equal(expected, actual)
 }
 
 func (suite *medicineUsecaseTestSuite) TestRestoreRecordSuccess() {
-	suite.medicineRepo.On("Restore", mock.Anything).Return(nil)
-	err := suite.medicineUC.RestoreRecord("1")
+	suite.medicineRepo.On("Restore", "1", 2).Return(nil)
+	err := suite.medicineUC.RestoreRecord(context.Background(), "1", 2)
+
+	suite.Nil(err)
+}
+
+func (suite *medicineUsecaseTestSuite) TestGetPricesMarksStatus() {
+	prices := []priceDto.Price{
+		{ID: "p3", Price: 7000, EffectiveFrom: "2999-01-01 00:00:00"},
+		{ID: "p2", Price: 6000, EffectiveFrom: "2024-04-01 00:00:00"},
+		{ID: "p1", Price: 5000, EffectiveFrom: "2024-01-01 00:00:00"},
+	}
+
+	suite.medicineRepo.On("RetrieveById", "1").Return(dto.MedicineResponse{Id: "1"}, nil)
+	suite.medicineRepo.On("RetrievePrices", "1").Return(prices, nil)
+	actual, err := suite.medicineUC.GetPrices(context.Background(), "1")
+
+	suite.Nil(err)
+	suite.Equal(constants.PriceScheduled, actual[0].Status)
+	suite.Equal(constants.PriceCurrent, actual[1].Status)
+	suite.Equal(constants.PricePast, actual[2].Status)
+}
+
+func (suite *medicineUsecaseTestSuite) TestSchedulePriceImmediately() {
+	suite.medicineRepo.On("RetrieveById", "1").Return(dto.MedicineResponse{Id: "1"}, nil)
+	suite.medicineRepo.On("InsertPrice", mock.MatchedBy(func(req priceDto.PriceRequest) bool {
+		return req.ItemID == "1" && req.Price == 6000 && req.EffectiveFrom != ""
+	})).Return("p2", nil)
+	suite.medicineRepo.On("RetrievePrices", "1").Return([]priceDto.Price{{ID: "p2", Price: 6000}}, nil)
+
+	actual, err := suite.medicineUC.SchedulePrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000})
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+	suite.medicineRepo.AssertExpectations(suite.T())
+}
+
+func (suite *medicineUsecaseTestSuite) TestSchedulePriceFromDate() {
+	suite.medicineRepo.On("RetrieveById", "1").Return(dto.MedicineResponse{Id: "1", Version: 3}, nil)
+	suite.medicineRepo.On("InsertPrice", priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "2999-01-01 00:00:00", Version: 3}).Return("p2", nil)
+	suite.medicineRepo.On("RetrievePrices", "1").Return([]priceDto.Price{}, nil)
+
+	_, err := suite.medicineUC.SchedulePrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "2999-01-01"})
 
 	suite.Nil(err)
+	suite.medicineRepo.AssertExpectations(suite.T())
+}
+
+func (suite *medicineUsecaseTestSuite) TestSchedulePriceVersionConflict() {
+	suite.medicineRepo.On("RetrieveById", "1").Return(dto.MedicineResponse{Id: "1", Version: 3}, nil)
+
+	_, err := suite.medicineUC.SchedulePrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000, Version: 2})
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.medicineRepo.AssertNotCalled(suite.T(), "InsertPrice", mock.Anything)
+}
+
+func (suite *medicineUsecaseTestSuite) TestSchedulePriceInPast() {
+	_, err := suite.medicineUC.SchedulePrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "2024-01-01"})
+
+	suite.EqualError(err, constants.ErrPriceEffectiveInPast)
+	suite.medicineRepo.AssertNotCalled(suite.T(), "InsertPrice", mock.Anything)
+}
+
+func (suite *medicineUsecaseTestSuite) TestSchedulePriceInvalidDate() {
+	_, err := suite.medicineUC.SchedulePrice(context.Background(), priceDto.PriceRequest{ItemID: "1", Price: 6000, EffectiveFrom: "01-01-2999"})
+
+	suite.EqualError(err, constants.ErrDateTimeFormat)
+}
+
+func (suite *medicineUsecaseTestSuite) TestPurgeTrash() {
+	suite.medicineRepo.On("PurgeTrash", mock.MatchedBy(func(deletedBefore string) bool {
+		return deletedBefore <= time.Now().AddDate(0, 0, -30).Format("2006-01-02 15:04:05")
+	})).Return(dto.Purged{Deleted: 3}, nil)
+
+	purged, err := suite.medicineUC.PurgeTrash(context.Background(), 30)
+
+	suite.Nil(err)
+	suite.Equal(3, purged.Deleted)
+}
+
+func (suite *medicineUsecaseTestSuite) TestPurgeTrashInvalidRetention() {
+	_, err := suite.medicineUC.PurgeTrash(context.Background(), 0)
+
+	suite.EqualError(err, constants.ErrInvalidRetention)
+	suite.medicineRepo.AssertNotCalled(suite.T(), "PurgeTrash", mock.Anything)
+}
+
+// Start Import
+func (suite *medicineUsecaseTestSuite) TestImportCreatesAndUpdates() {
+	records := []sheetDto.Record{
+		{Line: 2, Values: map[string]string{"name": "Paracetamol", "medicine_type": "TABLET", "price": "5000", "stock": "10"}},
+		{Line: 3, Values: map[string]string{"name": "Amoxicillin", "medicine_type": "KAPSUL", "price": "8000", "description": "antibiotik"}},
+	}
+	existing := dto.MedicineResponse{Id: "m2", Name: "Amoxicillin", MedicineType: "KAPSUL", Price: 7500}
+
+	suite.medicineRepo.On("RetrieveByName", "Paracetamol").Return(dto.MedicineResponse{}, sql.ErrNoRows)
+	suite.medicineRepo.On("RetrieveByName", "Amoxicillin").Return(existing, nil)
+	suite.medicineRepo.On("Create", mock.MatchedBy(func(req dto.MedicineRequest) bool {
+		return req.Name == "Paracetamol" && req.Price == 5000 && req.Stock == 10 && req.CreatedBy == "u1"
+	})).Return(dto.MedicineResponse{Id: "m1"}, nil)
+	suite.medicineRepo.On("RetrieveById", "m2").Return(existing, nil)
+	suite.medicineRepo.On("Update", mock.MatchedBy(func(req dto.MedicineRequest) bool {
+		return req.Id == "m2" && req.Price == 8000 && req.Description == "antibiotik" && req.CreatedBy == "u1"
+	})).Return(existing, nil)
+
+	result, err := suite.medicineUC.Import(context.Background(), records, "u1", false)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Created)
+	suite.Equal(1, result.Updated)
+	suite.Equal(sheetDto.ImportRow{Line: 2, Name: "Paracetamol", Action: constants.ImportCreate, ID: "m1"}, result.Rows[0])
+	suite.Equal(sheetDto.ImportRow{Line: 3, Name: "Amoxicillin", Action: constants.ImportUpdate, ID: "m2"}, result.Rows[1])
+	suite.medicineRepo.AssertExpectations(suite.T())
+}
+
+func (suite *medicineUsecaseTestSuite) TestImportRejectsInvalidRows() {
+	records := []sheetDto.Record{
+		{Line: 2, Values: map[string]string{"name": "Paracetamol", "medicine_type": "TABLET", "price": "5000"}},
+		{Line: 3, Values: map[string]string{"name": "Paracetamol", "medicine_type": "PIL", "price": "lima ribu"}},
+	}
+	suite.medicineRepo.On("RetrieveByName", "Paracetamol").Return(dto.MedicineResponse{}, sql.ErrNoRows)
+
+	result, err := suite.medicineUC.Import(context.Background(), records, "u1", false)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Failed)
+	suite.Equal(constants.ImportCreate, result.Rows[0].Action)
+	suite.Empty(result.Rows[1].Action)
+	suite.Contains(result.Rows[1].Errors, json.ValidationField{FieldName: "price", Message: constants.ErrSheetNumber})
+	suite.Contains(result.Rows[1].Errors, json.ValidationField{FieldName: "name", Message: constants.ErrImportDuplicateName})
+	suite.medicineRepo.AssertNotCalled(suite.T(), "Create", mock.Anything)
+}
+
+func (suite *medicineUsecaseTestSuite) TestImportAmbiguousName() {
+	records := []sheetDto.Record{{Line: 2, Values: map[string]string{"name": "Paracetamol", "medicine_type": "TABLET", "price": "5000"}}}
+	suite.medicineRepo.On("RetrieveByName", "Paracetamol").Return(dto.MedicineResponse{}, errors.New(constants.ErrMedicineNameAmbiguous))
+
+	result, err := suite.medicineUC.Import(context.Background(), records, "u1", false)
+
+	suite.Nil(err)
+	suite.Equal(1, result.Failed)
+	suite.Equal([]json.ValidationField{{FieldName: "name", Message: constants.ErrMedicineNameAmbiguous}}, result.Rows[0].Errors)
+}
+
+func (suite *medicineUsecaseTestSuite) TestImportDryRun() {
+	records := []sheetDto.Record{{Line: 2, Values: map[string]string{"name": "Paracetamol", "medicine_type": "TABLET", "price": "5000"}}}
+	suite.medicineRepo.On("RetrieveByName", "Paracetamol").Return(dto.MedicineResponse{}, sql.ErrNoRows)
+
+	result, err := suite.medicineUC.Import(context.Background(), records, "u1", true)
+
+	suite.Nil(err)
+	suite.True(result.DryRun)
+	suite.Equal(1, result.Created)
+	suite.medicineRepo.AssertNotCalled(suite.T(), "Create", mock.Anything)
+}
+// End Import
+
+func (suite *medicineUsecaseTestSuite) TestExportEveryPage() {
+	query := queryDto.Query{Page: 3, Size: 10, Filters: []queryDto.Filter{{Field: "medicine_type", Value: "TABLET"}}}
+	medicines := []dto.MedicineResponse{{Name: "Paracetamol", MedicineType: "TABLET", Price: 5000, Stock: 10}}
+	suite.medicineRepo.On("RetrieveAll", queryDto.Query{Page: 1, Filters: query.Filters}).Return(medicines, 1, nil)
+
+	file, err := suite.medicineUC.Export(context.Background(), query, "csv")
+
+	suite.Nil(err)
+	suite.Equal("medicines.csv", file.FileName)
+	suite.Equal("name,medicine_type,price,stock,description\nParacetamol,TABLET,5000,10,\n", string(file.Content))
+}
+
+func (suite *medicineUsecaseTestSuite) TestExportInvalidFormat() {
+	suite.medicineRepo.On("RetrieveAll", mock.Anything).Return([]dto.MedicineResponse{}, 0, nil)
+
+	_, err := suite.medicineUC.Export(context.Background(), queryDto.Query{}, "pdf")
+
+	suite.EqualError(err, constants.ErrInvalidFileFormat)
 }
 
 func TestMedicineUsecaseTestSuite(t *testing.T) {
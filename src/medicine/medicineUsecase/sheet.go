@@ -0,0 +1,119 @@
+package medicineUsecase
+
+import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/sheetDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"context"
+	"database/sql"
+)
+
+// sheetColumns are the columns of an exported catalogue, which can be
+// imported again as it is.
+var sheetColumns = []string{"name", "medicine_type", "price", "stock", "description"}
+
+// Import creates the medicines of the records and updates the ones whose name
+// is already in the catalogue. Every record is checked first and nothing is
+// written when one of them fails, or on a dry run. The stock of a record only
+// opens the stock of a new medicine, stock changes of existing medicines go
+// through the inventory.
+func (m *medicineUC) Import(ctx context.Context, records []sheetDto.Record, createdBy string, dryRun bool) (sheetDto.ImportResult, error) {
+	result := sheetDto.ImportResult{DryRun: dryRun}
+	requests := make([]dto.MedicineRequest, len(records))
+	names := map[string]bool{}
+	for i, record := range records {
+		requests[i] = medicineRequest(record)
+		row := sheetDto.ImportRow{Line: record.Line, Name: requests[i].Name, Action: constants.ImportCreate}
+
+		for _, column := range []string{"price", "stock"} {
+			if _, err := utils.SheetInt(record, column); err != nil {
+				row.Errors = append(row.Errors, json.ValidationField{FieldName: column, Message: err.Error()})
+			}
+		}
+		row.Errors = append(row.Errors, utils.Validated(requests[i])...)
+		if names[row.Name] {
+			row.Errors = append(row.Errors, json.ValidationField{FieldName: "name", Message: constants.ErrImportDuplicateName})
+		}
+		names[row.Name] = true
+
+		if len(row.Errors) == 0 {
+			existing, err := m.medicineRepo.RetrieveByName(ctx, row.Name)
+			switch {
+			case err == nil:
+				row.Action, row.ID = constants.ImportUpdate, existing.Id
+			case err == sql.ErrNoRows:
+			case err.Error() == constants.ErrMedicineNameAmbiguous:
+				row.Errors = append(row.Errors, json.ValidationField{FieldName: "name", Message: err.Error()})
+			default:
+				return sheetDto.ImportResult{}, err
+			}
+		}
+
+		switch {
+		case len(row.Errors) > 0:
+			row.Action = ""
+			result.Failed++
+		case row.Action == constants.ImportUpdate:
+			result.Updated++
+		default:
+			result.Created++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	if result.Failed > 0 || dryRun {
+		return result, nil
+	}
+
+	for i, row := range result.Rows {
+		req := requests[i]
+		req.CreatedBy = createdBy
+		if row.Action == constants.ImportUpdate {
+			update := dto.UpdateRequest{Id: row.ID, Name: req.Name, MedicineType: req.MedicineType, Price: req.Price, Description: req.Description, UpdatedBy: createdBy}
+			if _, err := m.UpdateRecord(ctx, update); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		created, err := m.CreateRecord(ctx, req)
+		if err != nil {
+			return result, err
+		}
+		result.Rows[i].ID = created.Id
+	}
+	return result, nil
+}
+
+// Export writes the medicines of the query, on every page, as a CSV or XLSX
+// file.
+func (m *medicineUC) Export(ctx context.Context, query queryDto.Query, format string) (sheetDto.File, error) {
+	query.Page, query.Size = 1, 0
+	medicines, _, err := m.medicineRepo.RetrieveAll(ctx, query)
+	if err != nil {
+		return sheetDto.File{}, err
+	}
+
+	rows := make([][]interface{}, len(medicines))
+	for i, medicine := range medicines {
+		description := medicine.Description
+		if description == nil {
+			description = ""
+		}
+		rows[i] = []interface{}{medicine.Name, medicine.MedicineType, medicine.Price, medicine.Stock, description}
+	}
+	return utils.WriteSheet("medicines", format, sheetColumns, rows)
+}
+
+func medicineRequest(record sheetDto.Record) dto.MedicineRequest {
+	price, _ := utils.SheetInt(record, "price")
+	stock, _ := utils.SheetInt(record, "stock")
+	req := dto.MedicineRequest{Name: record.Values["name"], MedicineType: record.Values["medicine_type"], Price: price, Stock: stock}
+	if description := record.Values["description"]; description != "" {
+		req.Description = description
+	}
+	return req
+}
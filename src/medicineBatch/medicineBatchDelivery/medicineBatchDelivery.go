@@ -0,0 +1,100 @@
+package medicineBatchDelivery
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/medicineBatch"
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+)
+
+type medicineBatchDelivery struct {
+	batchUC medicineBatch.MedicineBatchUsecase
+}
+
+func NewMedicineBatchDelivery(v1Group *gin.RouterGroup, batchUC medicineBatch.MedicineBatchUsecase) {
+	handler := medicineBatchDelivery{batchUC}
+
+	batchGroup := v1Group.Group("/medicine-batches")
+	{
+		batchGroup.GET("/expiring", middleware.JwtAuth("ADMIN"), handler.GetExpiring)
+		batchGroup.GET("/medicine/:medicine-id", middleware.JwtAuth("ADMIN"), handler.GetByMedicineID)
+		batchGroup.GET("/:id", middleware.JwtAuth("ADMIN"), handler.GetByID)
+		batchGroup.POST("", middleware.JwtAuth("ADMIN"), handler.Create)
+	}
+}
+
+func (delivery *medicineBatchDelivery) GetExpiring(c *gin.Context) {
+	batches, err := delivery.batchUC.GetExpiring(c.Request.Context(), c.Query("days"))
+	if err != nil {
+		if err.Error() == constants.ErrInvalidDays {
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "days", Message: err.Error()}}, "Bad request", constants.MedicineBatchService, "01")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.MedicineBatchService, "02")
+		return
+	}
+
+	json.NewResponseSuccess(c, batches, "Expiring batches retrieved successfully", constants.MedicineBatchService, "01")
+}
+
+func (delivery *medicineBatchDelivery) GetByMedicineID(c *gin.Context) {
+	batches, err := delivery.batchUC.GetByMedicineID(c.Request.Context(), c.Param("medicine-id"))
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.MedicineBatchService, "01")
+		return
+	}
+
+	json.NewResponseSuccess(c, batches, "Batches retrieved successfully", constants.MedicineBatchService, "01")
+}
+
+func (delivery *medicineBatchDelivery) GetByID(c *gin.Context) {
+	batch, err := delivery.batchUC.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Batch not found", constants.MedicineBatchService, "01")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.MedicineBatchService, "02")
+		return
+	}
+
+	json.NewResponseSuccess(c, batch, "Batch retrieved successfully", constants.MedicineBatchService, "01")
+}
+
+func (delivery *medicineBatchDelivery) Create(c *gin.Context) {
+	var request medicineBatchDto.CreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.MedicineBatchService, "01")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.MedicineBatchService, "02")
+		return
+	}
+
+	request.ReceivedBy = utils.GetJWT(c).ID
+	batch, err := delivery.batchUC.Create(c.Request.Context(), request)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrDateFormat, constants.ErrBatchAlreadyExpired:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "expiry_date", Message: err.Error()}}, "Bad request", constants.MedicineBatchService, "03")
+			return
+		case constants.ErrMedicineNotExist:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "medicine_id", Message: err.Error()}}, "Bad request", constants.MedicineBatchService, "04")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.MedicineBatchService, "05")
+		return
+	}
+
+	json.NewResponseCreated(c, batch, "Batch received successfully", constants.MedicineBatchService, "01")
+}
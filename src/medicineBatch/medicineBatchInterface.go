@@ -0,0 +1,21 @@
+package medicineBatch
+
+import (
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"context"
+)
+
+type MedicineBatchRepository interface {
+	RetrieveByMedicineID(ctx context.Context, medicineID string) ([]medicineBatchDto.Batch, error)
+	RetrieveByID(ctx context.Context, id string) (medicineBatchDto.Batch, error)
+	RetrieveExpiring(ctx context.Context, days int) ([]medicineBatchDto.ExpiringBatch, error)
+	Insert(ctx context.Context, batch medicineBatchDto.Batch) (string, error)
+	IsMedicineExist(ctx context.Context, medicineID string) bool
+}
+
+type MedicineBatchUsecase interface {
+	GetByMedicineID(ctx context.Context, medicineID string) ([]medicineBatchDto.Batch, error)
+	GetByID(ctx context.Context, id string) (medicineBatchDto.Batch, error)
+	GetExpiring(ctx context.Context, days string) ([]medicineBatchDto.ExpiringBatch, error)
+	Create(ctx context.Context, req medicineBatchDto.CreateRequest) (medicineBatchDto.Batch, error)
+}
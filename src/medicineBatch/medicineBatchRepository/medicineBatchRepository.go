@@ -0,0 +1,165 @@
+package medicineBatchRepository
+
+import (
+	"avengers-clinic/model/dto/inventoryDto"
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/inventory/inventoryRepository"
+	"avengers-clinic/src/medicineBatch"
+	"context"
+	"database/sql"
+)
+
+type medicineBatchRepository struct {
+	db *sql.DB
+}
+
+func NewMedicineBatchRepository(db *sql.DB) medicineBatch.MedicineBatchRepository {
+	return &medicineBatchRepository{db}
+}
+
+func (repository *medicineBatchRepository) RetrieveByMedicineID(ctx context.Context, medicineID string) ([]medicineBatchDto.Batch, error) {
+	query := `
+		SELECT b.id, b.medicine_id, m.name, b.lot_number, to_char(b.expiry_date, 'YYYY-MM-DD'), b.supplier,
+			b.purchase_cost, b.initial_quantity, b.quantity, b.created_at
+		FROM medicine_batches b JOIN medicines m ON m.id = b.medicine_id
+		WHERE b.medicine_id = $1 AND b.deleted_at IS NULL ORDER BY b.expiry_date, b.created_at;
+	`
+	rows, err := repository.db.QueryContext(ctx, query, medicineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []medicineBatchDto.Batch
+	for rows.Next() {
+		batch, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+func (repository *medicineBatchRepository) RetrieveByID(ctx context.Context, id string) (medicineBatchDto.Batch, error) {
+	query := `
+		SELECT b.id, b.medicine_id, m.name, b.lot_number, to_char(b.expiry_date, 'YYYY-MM-DD'), b.supplier,
+			b.purchase_cost, b.initial_quantity, b.quantity, b.created_at
+		FROM medicine_batches b JOIN medicines m ON m.id = b.medicine_id
+		WHERE b.id = $1 AND b.deleted_at IS NULL;
+	`
+	return scanBatch(repository.db.QueryRowContext(ctx, query, id))
+}
+
+// RetrieveExpiring returns batches still holding stock that expire within the
+// given number of days, including those already expired.
+func (repository *medicineBatchRepository) RetrieveExpiring(ctx context.Context, days int) ([]medicineBatchDto.ExpiringBatch, error) {
+	query := `
+		SELECT b.id, b.medicine_id, m.name, b.lot_number, to_char(b.expiry_date, 'YYYY-MM-DD'), b.supplier,
+			b.purchase_cost, b.initial_quantity, b.quantity, b.created_at,
+			b.expiry_date - CURRENT_DATE, b.expiry_date < CURRENT_DATE
+		FROM medicine_batches b JOIN medicines m ON m.id = b.medicine_id
+		WHERE b.deleted_at IS NULL AND m.deleted_at IS NULL AND b.quantity > 0
+			AND b.expiry_date <= CURRENT_DATE + $1::int
+		ORDER BY b.expiry_date, m.name;
+	`
+	rows, err := repository.db.QueryContext(ctx, query, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []medicineBatchDto.ExpiringBatch
+	for rows.Next() {
+		var batch medicineBatchDto.ExpiringBatch
+		err := rows.Scan(
+			&batch.ID,
+			&batch.MedicineID,
+			&batch.MedicineName,
+			&batch.LotNumber,
+			&batch.ExpiryDate,
+			&batch.Supplier,
+			&batch.PurchaseCost,
+			&batch.InitialQuantity,
+			&batch.Quantity,
+			&batch.CreatedAt,
+			&batch.DaysLeft,
+			&batch.Expired,
+		)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// Insert stores the received batch and posts its quantity to the stock ledger
+// in one transaction.
+func (repository *medicineBatchRepository) Insert(ctx context.Context, batch medicineBatchDto.Batch) (string, error) {
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		query := `
+			INSERT INTO medicine_batches (medicine_id, lot_number, expiry_date, supplier, purchase_cost, initial_quantity, quantity, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, 0, $7, $8) RETURNING id;
+		`
+		err := tx.QueryRowContext(ctx,
+			query,
+			batch.MedicineID,
+			batch.LotNumber,
+			batch.ExpiryDate,
+			batch.Supplier,
+			batch.PurchaseCost,
+			batch.Quantity,
+			batch.CreatedAt,
+			batch.UpdatedAt,
+		).Scan(&batch.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = inventoryRepository.ApplyMovement(ctx, tx, inventoryDto.Movement{
+			MedicineID:   batch.MedicineID,
+			BatchID:      batch.ID,
+			MovementType: constants.MovementReceipt,
+			Quantity:     batch.Quantity,
+			CreatedBy:    batch.CreatedBy,
+			CreatedAt:    batch.CreatedAt,
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+func (repository *medicineBatchRepository) IsMedicineExist(ctx context.Context, medicineID string) bool {
+	count, query := 0, "SELECT COUNT(*) FROM medicines WHERE id = $1 AND deleted_at IS NULL;"
+	repository.db.QueryRowContext(ctx, query, medicineID).Scan(&count)
+	return count > 0
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBatch(row scanner) (medicineBatchDto.Batch, error) {
+	var batch medicineBatchDto.Batch
+	err := row.Scan(
+		&batch.ID,
+		&batch.MedicineID,
+		&batch.MedicineName,
+		&batch.LotNumber,
+		&batch.ExpiryDate,
+		&batch.Supplier,
+		&batch.PurchaseCost,
+		&batch.InitialQuantity,
+		&batch.Quantity,
+		&batch.CreatedAt,
+	)
+	return batch, err
+}
@@ -0,0 +1,98 @@
+package medicineBatchRepository
+
+import (
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/src/medicineBatch"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type medicineBatchRepositoryTestSuite struct {
+	suite.Suite
+	batchRepo medicineBatch.MedicineBatchRepository
+	mock      sqlmock.Sqlmock
+}
+
+func (suite *medicineBatchRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.batchRepo = NewMedicineBatchRepository(db)
+	suite.mock = mock
+}
+
+func (suite *medicineBatchRepositoryTestSuite) TestInsertSuccess() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medicine_batches").
+		WithArgs("m1", "LOT-1", "2030-01-01", nil, 1500, 20, "2024-03-12 16:06", "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("b1"))
+	suite.mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").
+		WithArgs("b1").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("m1", 0))
+	suite.mock.ExpectExec("UPDATE medicine_batches SET quantity").
+		WithArgs(20, "2024-03-12 16:06", "b1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("UPDATE medicines SET stock").
+		WithArgs(20, "2024-03-12 16:06", "m1").
+		WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(20))
+	suite.mock.ExpectQuery("INSERT INTO stock_movements").
+		WithArgs("m1", "b1", "RECEIPT", 20, 20, nil, nil, nil, "u1", "2024-03-12 16:06").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("s1"))
+	suite.mock.ExpectCommit()
+
+	actual, err := suite.batchRepo.Insert(context.Background(), medicineBatchDto.Batch{
+		MedicineID:   "m1",
+		LotNumber:    "LOT-1",
+		ExpiryDate:   "2030-01-01",
+		PurchaseCost: 1500,
+		Quantity:     20,
+		CreatedBy:    "u1",
+		CreatedAt:    "2024-03-12 16:06",
+		UpdatedAt:    "2024-03-12 16:06",
+	})
+
+	suite.Nil(err)
+	suite.Equal("b1", actual)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineBatchRepositoryTestSuite) TestInsertRollback() {
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery("INSERT INTO medicine_batches").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("b1"))
+	suite.mock.ExpectQuery("SELECT medicine_id, quantity FROM medicine_batches").
+		WillReturnRows(sqlmock.NewRows([]string{"medicine_id", "quantity"}).AddRow("m1", 0))
+	suite.mock.ExpectExec("UPDATE medicine_batches SET quantity").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectQuery("UPDATE medicines SET stock").
+		WillReturnError(sql.ErrConnDone)
+	suite.mock.ExpectRollback()
+
+	_, err := suite.batchRepo.Insert(context.Background(), medicineBatchDto.Batch{MedicineID: "m1", Quantity: 20})
+
+	suite.Error(err)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *medicineBatchRepositoryTestSuite) TestRetrieveExpiringSuccess() {
+	rows := sqlmock.NewRows([]string{"id", "medicine_id", "name", "lot_number", "expiry_date", "supplier", "purchase_cost", "initial_quantity", "quantity", "created_at", "days_left", "expired"}).
+		AddRow("b1", "m1", "Panadol", "LOT-1", "2024-03-01", nil, 1500, 20, 5, "2024-01-01 10:00:00", -2, true)
+
+	suite.mock.ExpectQuery("SELECT (.+) FROM medicine_batches").
+		WithArgs(30).
+		WillReturnRows(rows)
+
+	actual, err := suite.batchRepo.RetrieveExpiring(context.Background(), 30)
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+	suite.True(actual[0].Expired)
+	suite.Equal(-2, actual[0].DaysLeft)
+}
+
+func TestMedicineBatchRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(medicineBatchRepositoryTestSuite))
+}
@@ -0,0 +1,82 @@
+package medicineBatchUsecase
+
+import (
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/medicineBatch"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultExpiringDays = 30
+
+type medicineBatchUsecase struct {
+	batchRepo medicineBatch.MedicineBatchRepository
+}
+
+func NewMedicineBatchUsecase(batchRepo medicineBatch.MedicineBatchRepository) medicineBatch.MedicineBatchUsecase {
+	return &medicineBatchUsecase{batchRepo}
+}
+
+func (usecase *medicineBatchUsecase) GetByMedicineID(ctx context.Context, medicineID string) ([]medicineBatchDto.Batch, error) {
+	batches, err := usecase.batchRepo.RetrieveByMedicineID(ctx, medicineID)
+	return batches, err
+}
+
+func (usecase *medicineBatchUsecase) GetByID(ctx context.Context, id string) (medicineBatchDto.Batch, error) {
+	batch, err := usecase.batchRepo.RetrieveByID(ctx, id)
+	return batch, err
+}
+
+func (usecase *medicineBatchUsecase) GetExpiring(ctx context.Context, days string) ([]medicineBatchDto.ExpiringBatch, error) {
+	withinDays := defaultExpiringDays
+	if days != "" {
+		var err error
+		withinDays, err = strconv.Atoi(days)
+		if err != nil || withinDays < 0 {
+			return nil, errors.New(constants.ErrInvalidDays)
+		}
+	}
+
+	batches, err := usecase.batchRepo.RetrieveExpiring(ctx, withinDays)
+	return batches, err
+}
+
+func (usecase *medicineBatchUsecase) Create(ctx context.Context, req medicineBatchDto.CreateRequest) (medicineBatchDto.Batch, error) {
+	expiryDate, err := utils.FormatDate(req.ExpiryDate)
+	if err != nil {
+		return medicineBatchDto.Batch{}, err
+	}
+
+	if expiryDate < time.Now().Format("2006-01-02") {
+		return medicineBatchDto.Batch{}, errors.New(constants.ErrBatchAlreadyExpired)
+	}
+
+	if !usecase.batchRepo.IsMedicineExist(ctx, req.MedicineID) {
+		return medicineBatchDto.Batch{}, errors.New(constants.ErrMedicineNotExist)
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	batch := medicineBatchDto.Batch{
+		MedicineID:      req.MedicineID,
+		LotNumber:       strings.TrimSpace(req.LotNumber),
+		ExpiryDate:      expiryDate,
+		Supplier:        req.Supplier,
+		PurchaseCost:    req.PurchaseCost,
+		InitialQuantity: req.Quantity,
+		Quantity:        req.Quantity,
+		CreatedBy:       req.ReceivedBy,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	batch.ID, err = usecase.batchRepo.Insert(ctx, batch)
+	if err != nil {
+		return medicineBatchDto.Batch{}, err
+	}
+	return batch, nil
+}
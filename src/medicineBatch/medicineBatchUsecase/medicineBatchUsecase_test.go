@@ -0,0 +1,104 @@
+package medicineBatchUsecase
+
+import (
+	"avengers-clinic/model/dto/medicineBatchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/medicineBatch"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockMedicineBatchRepository struct {
+	mock.Mock
+}
+
+func (m *mockMedicineBatchRepository) RetrieveByMedicineID(ctx context.Context, medicineID string) ([]medicineBatchDto.Batch, error) {
+	args := m.Called(medicineID)
+	return args.Get(0).([]medicineBatchDto.Batch), args.Error(1)
+}
+
+func (m *mockMedicineBatchRepository) RetrieveByID(ctx context.Context, id string) (medicineBatchDto.Batch, error) {
+	args := m.Called(id)
+	return args.Get(0).(medicineBatchDto.Batch), args.Error(1)
+}
+
+func (m *mockMedicineBatchRepository) RetrieveExpiring(ctx context.Context, days int) ([]medicineBatchDto.ExpiringBatch, error) {
+	args := m.Called(days)
+	return args.Get(0).([]medicineBatchDto.ExpiringBatch), args.Error(1)
+}
+
+func (m *mockMedicineBatchRepository) Insert(ctx context.Context, batch medicineBatchDto.Batch) (string, error) {
+	args := m.Called(batch)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockMedicineBatchRepository) IsMedicineExist(ctx context.Context, medicineID string) bool {
+	args := m.Called(medicineID)
+	return args.Bool(0)
+}
+
+type medicineBatchUsecaseTestSuite struct {
+	suite.Suite
+	batchRepo *mockMedicineBatchRepository
+	batchUC   medicineBatch.MedicineBatchUsecase
+}
+
+func (suite *medicineBatchUsecaseTestSuite) SetupTest() {
+	suite.batchRepo = new(mockMedicineBatchRepository)
+	suite.batchUC = NewMedicineBatchUsecase(suite.batchRepo)
+}
+
+func (suite *medicineBatchUsecaseTestSuite) TestCreateSuccess() {
+	expiry := time.Now().AddDate(1, 0, 0).Format("2006-01-02")
+	suite.batchRepo.On("IsMedicineExist", "m1").Return(true)
+	suite.batchRepo.On("Insert", mock.Anything).Return("b1", nil)
+
+	actual, err := suite.batchUC.Create(context.Background(), medicineBatchDto.CreateRequest{MedicineID: "m1", LotNumber: " LOT-1 ", ExpiryDate: expiry, Quantity: 20})
+
+	suite.Nil(err)
+	suite.Equal("b1", actual.ID)
+	suite.Equal("LOT-1", actual.LotNumber)
+	suite.Equal(20, actual.InitialQuantity)
+	suite.Equal(20, actual.Quantity)
+}
+
+func (suite *medicineBatchUsecaseTestSuite) TestCreateAlreadyExpired() {
+	expiry := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+
+	_, err := suite.batchUC.Create(context.Background(), medicineBatchDto.CreateRequest{MedicineID: "m1", LotNumber: "LOT-1", ExpiryDate: expiry, Quantity: 20})
+
+	suite.EqualError(err, constants.ErrBatchAlreadyExpired)
+	suite.batchRepo.AssertNotCalled(suite.T(), "Insert", mock.Anything)
+}
+
+func (suite *medicineBatchUsecaseTestSuite) TestCreateMedicineNotExist() {
+	expiry := time.Now().AddDate(1, 0, 0).Format("2006-01-02")
+	suite.batchRepo.On("IsMedicineExist", "m1").Return(false)
+
+	_, err := suite.batchUC.Create(context.Background(), medicineBatchDto.CreateRequest{MedicineID: "m1", LotNumber: "LOT-1", ExpiryDate: expiry, Quantity: 20})
+
+	suite.EqualError(err, constants.ErrMedicineNotExist)
+}
+
+func (suite *medicineBatchUsecaseTestSuite) TestGetExpiringDefaultDays() {
+	suite.batchRepo.On("RetrieveExpiring", 30).Return([]medicineBatchDto.ExpiringBatch{{DaysLeft: 3}}, nil)
+
+	actual, err := suite.batchUC.GetExpiring(context.Background(), "")
+
+	suite.Nil(err)
+	suite.Len(actual, 1)
+}
+
+func (suite *medicineBatchUsecaseTestSuite) TestGetExpiringInvalidDays() {
+	_, err := suite.batchUC.GetExpiring(context.Background(), "abc")
+
+	suite.EqualError(err, constants.ErrInvalidDays)
+}
+
+func TestMedicineBatchUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(medicineBatchUsecaseTestSuite))
+}
@@ -0,0 +1,120 @@
+package onlinePaymentDelivery
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/onlinePaymentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/gateway"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/onlinePayment"
+	"database/sql"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type onlinePaymentDelivery struct {
+	onlinePaymentUC onlinePayment.OnlinePaymentUsecase
+}
+
+func NewOnlinePaymentDelivery(v1Group *gin.RouterGroup, onlinePaymentUC onlinePayment.OnlinePaymentUsecase) {
+	handler := onlinePaymentDelivery{onlinePaymentUC}
+
+	paymentGroup := v1Group.Group("/online-payments")
+	{
+		paymentGroup.POST("/charges", middleware.JwtAuth("ADMIN", "PATIENT"), handler.CreateCharge)
+		paymentGroup.GET("/charges/:id", middleware.JwtAuth("ADMIN", "PATIENT"), handler.GetChargeByID)
+		paymentGroup.POST("/reconcile", middleware.JwtAuth("ADMIN"), handler.Reconcile)
+		// Called by the provider, trusted through the signature of the body
+		paymentGroup.POST("/webhooks/:provider", handler.HandleCallback)
+	}
+}
+
+func (delivery *onlinePaymentDelivery) CreateCharge(c *gin.Context) {
+	var request onlinePaymentDto.ChargeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.OnlinePaymentService, "01")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.OnlinePaymentService, "01")
+		return
+	}
+
+	claims := utils.GetJWT(c)
+	request.CreatedBy, request.Role = claims.ID, claims.Role
+	charge, err := delivery.onlinePaymentUC.CreateCharge(c.Request.Context(), request)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			json.NewResponseNotFound(c, "Invoice not found", constants.OnlinePaymentService, "01")
+		case err == gateway.ErrDisabled:
+			json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.OnlinePaymentService, "02")
+		case err.Error() == constants.ErrInvoiceNotPayable:
+			json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.OnlinePaymentService, "03")
+		default:
+			json.NewResponseError(c, err.Error(), constants.OnlinePaymentService, "02")
+		}
+		return
+	}
+
+	json.NewResponseCreated(c, charge, "Charge created successfully", constants.OnlinePaymentService, "01")
+}
+
+func (delivery *onlinePaymentDelivery) GetChargeByID(c *gin.Context) {
+	claims := utils.GetJWT(c)
+	charge, err := delivery.onlinePaymentUC.GetChargeByID(c.Request.Context(), c.Param("id"), claims.ID, claims.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			json.NewResponseNotFound(c, "Charge not found", constants.OnlinePaymentService, "02")
+			return
+		}
+
+		json.NewResponseError(c, err.Error(), constants.OnlinePaymentService, "03")
+		return
+	}
+
+	json.NewResponseSuccess(c, charge, "Charge retrieved successfully", constants.OnlinePaymentService, "01")
+}
+
+func (delivery *onlinePaymentDelivery) Reconcile(c *gin.Context) {
+	result, err := delivery.onlinePaymentUC.Reconcile(c.Request.Context())
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.OnlinePaymentService, "04")
+		return
+	}
+
+	json.NewResponseSuccess(c, result, "Charges reconciled successfully", constants.OnlinePaymentService, "02")
+}
+
+// HandleCallback answers 2xx only once the callback is stored, anything else
+// makes the provider retry it later.
+func (delivery *onlinePaymentDelivery) HandleCallback(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		json.NewResponseError(c, err.Error(), constants.OnlinePaymentService, "05")
+		return
+	}
+
+	err = delivery.onlinePaymentUC.HandleCallback(c.Request.Context(), c.Param("provider"), c.Request.Header, body)
+	if err != nil {
+		log.Warn().Str("provider", c.Param("provider")).Msg("HandleCallback.err : " + err.Error())
+
+		switch {
+		case err == gateway.ErrInvalidSignature:
+			json.NewResponseUnauthorized(c, "Invalid signature", constants.OnlinePaymentService, "01")
+		case err == sql.ErrNoRows:
+			json.NewResponseNotFound(c, "Charge not found", constants.OnlinePaymentService, "02")
+		case err.Error() == constants.ErrUnknownPaymentProvider:
+			json.NewResponseNotFound(c, err.Error(), constants.OnlinePaymentService, "03")
+		default:
+			json.NewResponseError(c, err.Error(), constants.OnlinePaymentService, "06")
+		}
+		return
+	}
+
+	json.NewResponseSuccess(c, nil, "Callback received", constants.OnlinePaymentService, "03")
+}
@@ -0,0 +1,25 @@
+package onlinePayment
+
+import (
+	"avengers-clinic/model/dto/onlinePaymentDto"
+	"avengers-clinic/pkg/gateway"
+	"context"
+	"net/http"
+)
+
+type OnlinePaymentRepository interface {
+	RetrievePayableInvoice(ctx context.Context, invoiceID string) (onlinePaymentDto.PayableInvoice, error)
+	RetrieveOpenCharge(ctx context.Context, invoiceID string, amount int) (onlinePaymentDto.Charge, error)
+	RetrieveChargeByID(ctx context.Context, id string) (onlinePaymentDto.Charge, error)
+	RetrievePendingCharges(ctx context.Context, createdBefore string) ([]onlinePaymentDto.Charge, error)
+	InsertCharge(ctx context.Context, charge onlinePaymentDto.Charge) (string, error)
+	UpdateCharge(ctx context.Context, charge onlinePaymentDto.Charge) error
+	ApplyNotification(ctx context.Context, provider, source string, notification gateway.Notification) (bool, error)
+}
+
+type OnlinePaymentUsecase interface {
+	CreateCharge(ctx context.Context, req onlinePaymentDto.ChargeRequest) (onlinePaymentDto.Charge, error)
+	GetChargeByID(ctx context.Context, id, userID, role string) (onlinePaymentDto.Charge, error)
+	HandleCallback(ctx context.Context, provider string, header http.Header, body []byte) error
+	Reconcile(ctx context.Context) (onlinePaymentDto.ReconcileResult, error)
+}
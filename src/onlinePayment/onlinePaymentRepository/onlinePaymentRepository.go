@@ -0,0 +1,233 @@
+package onlinePaymentRepository
+
+import (
+	"avengers-clinic/model/dto/invoiceDto"
+	"avengers-clinic/model/dto/onlinePaymentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/gateway"
+	"avengers-clinic/pkg/transaction"
+	"avengers-clinic/src/invoice/invoiceRepository"
+	"avengers-clinic/src/onlinePayment"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const chargeColumns = `
+	c.id, c.invoice_id, c.provider, c.amount, c.status, COALESCE(c.provider_ref, ''), COALESCE(c.payment_url, ''),
+	COALESCE(c.method, ''), COALESCE(c.note, ''), COALESCE(c.created_by::text, ''), c.expires_at::text, c.created_at,
+	COALESCE(c.updated_at::text, ''), COALESCE(c.paid_at::text, ''), b.patient_id
+	FROM online_charges c
+	JOIN invoices i ON i.id = c.invoice_id
+	JOIN medical_records r ON r.id = i.medical_record_id
+	JOIN bookings b ON b.id = r.booking_id`
+
+type onlinePaymentRepository struct {
+	db *sql.DB
+}
+
+func NewOnlinePaymentRepository(db *sql.DB) onlinePayment.OnlinePaymentRepository {
+	return &onlinePaymentRepository{db}
+}
+
+// conn returns the transaction of the unit of work ctx belongs to, or the
+// database outside of one.
+func (repository *onlinePaymentRepository) conn(ctx context.Context) transaction.Querier {
+	return transaction.Executor(ctx, repository.db)
+}
+
+func (repository *onlinePaymentRepository) RetrievePayableInvoice(ctx context.Context, invoiceID string) (onlinePaymentDto.PayableInvoice, error) {
+	query := `
+		SELECT i.id, COALESCE(i.invoice_number, ''), i.status, i.total - i.paid_amount, b.patient_id, u.username
+		FROM invoices i
+		JOIN medical_records r ON r.id = i.medical_record_id
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN users u ON u.id = b.patient_id
+		WHERE i.id = $1;`
+
+	var invoice onlinePaymentDto.PayableInvoice
+	err := repository.conn(ctx).QueryRowContext(ctx, query, invoiceID).
+		Scan(&invoice.ID, &invoice.InvoiceNumber, &invoice.Status, &invoice.Balance, &invoice.PatientID, &invoice.PatientName)
+	return invoice, err
+}
+
+// RetrieveOpenCharge returns a pending charge of the invoice for the amount
+// that can still be paid, so asking to pay twice reuses the same payment page.
+func (repository *onlinePaymentRepository) RetrieveOpenCharge(ctx context.Context, invoiceID string, amount int) (onlinePaymentDto.Charge, error) {
+	query := "SELECT " + chargeColumns + `
+		WHERE c.invoice_id = $1 AND c.amount = $2 AND c.status = $3 AND c.payment_url IS NOT NULL AND c.expires_at > $4
+		ORDER BY c.created_at DESC LIMIT 1;`
+	return scanCharge(repository.conn(ctx).QueryRowContext(ctx, query, invoiceID, amount, constants.ChargePending, time.Now().Format("2006-01-02 15:04:05")))
+}
+
+func (repository *onlinePaymentRepository) RetrieveChargeByID(ctx context.Context, id string) (onlinePaymentDto.Charge, error) {
+	query := "SELECT " + chargeColumns + " WHERE c.id = $1;"
+	return scanCharge(repository.conn(ctx).QueryRowContext(ctx, query, id))
+}
+
+func (repository *onlinePaymentRepository) RetrievePendingCharges(ctx context.Context, createdBefore string) ([]onlinePaymentDto.Charge, error) {
+	query := "SELECT " + chargeColumns + " WHERE c.status = $1 AND c.created_at <= $2 ORDER BY c.created_at;"
+	rows, err := repository.conn(ctx).QueryContext(ctx, query, constants.ChargePending, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var charges []onlinePaymentDto.Charge
+	for rows.Next() {
+		charge, err := scanCharge(rows)
+		if err != nil {
+			return nil, err
+		}
+		charges = append(charges, charge)
+	}
+	return charges, nil
+}
+
+func (repository *onlinePaymentRepository) InsertCharge(ctx context.Context, charge onlinePaymentDto.Charge) (string, error) {
+	query := `
+		INSERT INTO online_charges (invoice_id, provider, amount, status, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+	err := repository.conn(ctx).QueryRowContext(ctx, query, charge.InvoiceID, charge.Provider, charge.Amount, charge.Status,
+		nullable(charge.CreatedBy), charge.ExpiresAt, charge.CreatedAt).Scan(&charge.ID)
+	return charge.ID, err
+}
+
+func (repository *onlinePaymentRepository) UpdateCharge(ctx context.Context, charge onlinePaymentDto.Charge) error {
+	query := "UPDATE online_charges SET status = $1, provider_ref = $2, payment_url = $3, note = $4, updated_at = $5 WHERE id = $6;"
+	_, err := repository.conn(ctx).ExecContext(ctx, query, charge.Status, nullable(charge.ProviderRef), nullable(charge.PaymentURL),
+		nullable(charge.Note), time.Now().Format("2006-01-02 15:04:05"), charge.ID)
+	return err
+}
+
+// ApplyNotification moves a pending charge to the state reported by the
+// provider and, once paid, records the payment on the invoice in the same
+// transaction. Every state change is applied once no matter how often the
+// provider repeats it: the callback log is keyed by the event and the charge
+// row is locked while it changes. It reports whether the charge changed.
+func (repository *onlinePaymentRepository) ApplyNotification(ctx context.Context, provider, source string, notification gateway.Notification) (bool, error) {
+	var changed bool
+	err := transaction.Run(ctx, repository.db, func(ctx context.Context) error {
+		tx := transaction.Tx(ctx)
+
+		now := time.Now().Format("2006-01-02 15:04:05")
+
+		var callbackID string
+		query := `
+			INSERT INTO online_payment_callbacks (provider, event_key, order_id, status, amount, source, payload, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (provider, event_key) DO NOTHING RETURNING id;`
+		err := tx.QueryRowContext(ctx, query, provider, notification.EventKey, notification.OrderID, notification.Status, notification.Amount,
+			source, nullablePayload(notification.Payload), now).Scan(&callbackID)
+		if err == sql.ErrNoRows {
+			// Seen before
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var invoiceID, status string
+		var amount int
+		query = "SELECT invoice_id, amount, status FROM online_charges WHERE id::text = $1 AND provider = $2 FOR UPDATE;"
+		if err := tx.QueryRowContext(ctx, query, notification.OrderID, provider).Scan(&invoiceID, &amount, &status); err != nil {
+			return err
+		}
+
+		// Money arriving late still has to reach the invoice
+		open := status == constants.ChargePending ||
+			(notification.Status == gateway.StatusPaid && (status == constants.ChargeFailed || status == constants.ChargeExpired))
+		if !open || notification.Status == gateway.StatusPending {
+			return nil
+		}
+
+		var note string
+		var paidAt interface{}
+		status = notification.Status
+		if status == gateway.StatusPaid {
+			paidAt = now
+			note, err = recordPayment(ctx, tx, invoiceID, amount, provider, notification)
+			if err != nil {
+				return err
+			}
+
+			if note != "" {
+				status = constants.ChargeUnapplied
+			}
+		}
+
+		query = `
+			UPDATE online_charges SET status = $1, provider_ref = COALESCE($2, provider_ref), method = $3, note = $4, paid_at = $5, updated_at = $6
+			WHERE id = $7;`
+		_, err = tx.ExecContext(ctx, query, status, nullable(notification.ProviderRef), nullable(notification.Method), nullable(note), paidAt, now, notification.OrderID)
+		if err != nil {
+			return err
+		}
+
+		changed = true
+		return nil
+	})
+	return changed, err
+}
+
+// recordPayment pays the invoice with the money the gateway collected. When
+// the invoice cannot take the payment, e.g. it was settled at the cashier in
+// the meantime, the money is kept on the charge for the staff to resolve and
+// the reason is returned.
+func recordPayment(ctx context.Context, tx *sql.Tx, invoiceID string, amount int, provider string, notification gateway.Notification) (string, error) {
+	if notification.Amount != amount {
+		return fmt.Sprintf("paid amount %d does not match the charge", notification.Amount), nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT online_payment;"); err != nil {
+		return "", err
+	}
+
+	payment := invoiceDto.Payment{
+		Method:         constants.PaymentOnline,
+		Amount:         amount,
+		TenderedAmount: amount,
+		Reference:      provider + ":" + notification.ProviderRef,
+	}
+	err := invoiceRepository.RecordPayments(ctx, tx, invoiceID, []invoiceDto.Payment{payment})
+	if err == nil {
+		return "", nil
+	}
+
+	switch err.Error() {
+	case constants.ErrInvoiceNotPayable, constants.ErrPaymentExceedsBalance, constants.ErrMedicalRecordAlreadyPaid,
+		constants.ErrNoStockAvailable, constants.ErrQuantityGreaterThanStock, constants.ErrExpiredStock:
+		if _, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT online_payment;"); rollbackErr != nil {
+			return "", rollbackErr
+		}
+		return err.Error(), nil
+	}
+	return "", err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCharge(row scanner) (onlinePaymentDto.Charge, error) {
+	var charge onlinePaymentDto.Charge
+	err := row.Scan(&charge.ID, &charge.InvoiceID, &charge.Provider, &charge.Amount, &charge.Status, &charge.ProviderRef,
+		&charge.PaymentURL, &charge.Method, &charge.Note, &charge.CreatedBy, &charge.ExpiresAt, &charge.CreatedAt,
+		&charge.UpdatedAt, &charge.PaidAt, &charge.PatientID)
+	return charge, err
+}
+
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func nullablePayload(payload []byte) interface{} {
+	if len(payload) == 0 {
+		return nil
+	}
+	return string(payload)
+}
@@ -0,0 +1,142 @@
+package onlinePaymentRepository
+
+import (
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/gateway"
+	"avengers-clinic/src/onlinePayment"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type onlinePaymentRepositoryTestSuite struct {
+	suite.Suite
+	onlinePaymentRepo onlinePayment.OnlinePaymentRepository
+	mock              sqlmock.Sqlmock
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.onlinePaymentRepo = NewOnlinePaymentRepository(db)
+	suite.mock = mock
+}
+
+var settlement = gateway.Notification{
+	OrderID:     "c1",
+	ProviderRef: "trx-1",
+	Status:      gateway.StatusPaid,
+	Amount:      100000,
+	Method:      "qris",
+	EventKey:    "c1:trx-1:settlement",
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) expectCallback(inserted bool) {
+	rows := sqlmock.NewRows([]string{"id"})
+	if inserted {
+		rows.AddRow("cb1")
+	}
+
+	suite.mock.ExpectBegin()
+	suite.mock.ExpectQuery(`INSERT INTO online_payment_callbacks`).
+		WithArgs("midtrans", settlement.EventKey, "c1", gateway.StatusPaid, 100000, constants.CallbackSourceWebhook, nil, sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) expectCharge(amount int, status string) {
+	suite.mock.ExpectQuery(`SELECT invoice_id, amount, status FROM online_charges WHERE id::text = \$1 AND provider = \$2 FOR UPDATE`).
+		WithArgs("c1", "midtrans").
+		WillReturnRows(sqlmock.NewRows([]string{"invoice_id", "amount", "status"}).AddRow("i1", amount, status))
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) TestApplyNotificationDuplicate() {
+	suite.expectCallback(false)
+	suite.mock.ExpectCommit()
+
+	updated, err := suite.onlinePaymentRepo.ApplyNotification(context.Background(), "midtrans", constants.CallbackSourceWebhook, settlement)
+
+	suite.Nil(err)
+	suite.False(updated)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) TestApplyNotificationPaid() {
+	suite.expectCallback(true)
+	suite.expectCharge(100000, constants.ChargePending)
+	suite.mock.ExpectExec(`SAVEPOINT online_payment`).WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`SELECT medical_record_id, status, total, paid_amount, refunded_amount FROM invoices WHERE id = \$1 FOR UPDATE`).
+		WithArgs("i1").
+		WillReturnRows(sqlmock.NewRows([]string{"medical_record_id", "status", "total", "paid_amount", "refunded_amount"}).
+			AddRow("m1", constants.InvoiceIssued, 250000, 0, 0))
+	suite.mock.ExpectQuery(`INSERT INTO invoice_payments`).
+		WithArgs("i1", constants.PaymentOnline, 100000, 100000, 0, "midtrans:trx-1", nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("pay1"))
+	suite.mock.ExpectExec(`INSERT INTO invoice_events`).WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE invoices SET paid_amount = \$1`).
+		WithArgs(100000, 0, constants.InvoicePartiallyPaid, nil, sqlmock.AnyArg(), "i1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec(`UPDATE online_charges SET status = \$1`).
+		WithArgs(constants.ChargePaid, "trx-1", "qris", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), "c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	updated, err := suite.onlinePaymentRepo.ApplyNotification(context.Background(), "midtrans", constants.CallbackSourceWebhook, settlement)
+
+	suite.Nil(err)
+	suite.True(updated)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) TestApplyNotificationInvoiceAlreadyPaid() {
+	suite.expectCallback(true)
+	suite.expectCharge(100000, constants.ChargeExpired)
+	suite.mock.ExpectExec(`SAVEPOINT online_payment`).WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectQuery(`SELECT medical_record_id, status, total, paid_amount, refunded_amount FROM invoices`).
+		WithArgs("i1").
+		WillReturnRows(sqlmock.NewRows([]string{"medical_record_id", "status", "total", "paid_amount", "refunded_amount"}).
+			AddRow("m1", constants.InvoicePaid, 250000, 250000, 0))
+	suite.mock.ExpectExec(`ROLLBACK TO SAVEPOINT online_payment`).WillReturnResult(sqlmock.NewResult(0, 0))
+	suite.mock.ExpectExec(`UPDATE online_charges SET status = \$1`).
+		WithArgs(constants.ChargeUnapplied, "trx-1", "qris", constants.ErrInvoiceNotPayable, sqlmock.AnyArg(), sqlmock.AnyArg(), "c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	updated, err := suite.onlinePaymentRepo.ApplyNotification(context.Background(), "midtrans", constants.CallbackSourceWebhook, settlement)
+
+	suite.Nil(err)
+	suite.True(updated)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) TestApplyNotificationAmountMismatch() {
+	suite.expectCallback(true)
+	suite.expectCharge(90000, constants.ChargePending)
+	suite.mock.ExpectExec(`UPDATE online_charges SET status = \$1`).
+		WithArgs(constants.ChargeUnapplied, "trx-1", "qris", "paid amount 100000 does not match the charge", sqlmock.AnyArg(), sqlmock.AnyArg(), "c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectCommit()
+
+	updated, err := suite.onlinePaymentRepo.ApplyNotification(context.Background(), "midtrans", constants.CallbackSourceWebhook, settlement)
+
+	suite.Nil(err)
+	suite.True(updated)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *onlinePaymentRepositoryTestSuite) TestApplyNotificationChargeSettled() {
+	suite.expectCallback(true)
+	suite.expectCharge(100000, constants.ChargePaid)
+	suite.mock.ExpectCommit()
+
+	updated, err := suite.onlinePaymentRepo.ApplyNotification(context.Background(), "midtrans", constants.CallbackSourceWebhook, settlement)
+
+	suite.Nil(err)
+	suite.False(updated)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func TestOnlinePaymentRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(onlinePaymentRepositoryTestSuite))
+}
@@ -0,0 +1,199 @@
+package onlinePaymentUsecase
+
+import (
+	"avengers-clinic/model/dto/onlinePaymentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/gateway"
+	"avengers-clinic/src/onlinePayment"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// callbackGrace is how long a new charge is left to its callback before the
+// reconciliation starts asking the provider about it.
+const callbackGrace = 5 * time.Minute
+
+type onlinePaymentUsecase struct {
+	onlinePaymentRepo onlinePayment.OnlinePaymentRepository
+	provider          gateway.Provider
+	chargeTTL         time.Duration
+}
+
+// NewOnlinePaymentUsecase takes the configured payment provider and how long
+// its payment pages stay valid.
+func NewOnlinePaymentUsecase(onlinePaymentRepo onlinePayment.OnlinePaymentRepository, provider gateway.Provider, chargeTTL time.Duration) onlinePayment.OnlinePaymentUsecase {
+	return &onlinePaymentUsecase{onlinePaymentRepo, provider, chargeTTL}
+}
+
+// CreateCharge opens a payment page for the balance of an issued invoice.
+// Patients can only pay their own invoices.
+func (usecase *onlinePaymentUsecase) CreateCharge(ctx context.Context, req onlinePaymentDto.ChargeRequest) (onlinePaymentDto.Charge, error) {
+	if usecase.provider.Name() == "" {
+		return onlinePaymentDto.Charge{}, gateway.ErrDisabled
+	}
+
+	invoice, err := usecase.onlinePaymentRepo.RetrievePayableInvoice(ctx, req.InvoiceID)
+	if err != nil {
+		return onlinePaymentDto.Charge{}, err
+	}
+
+	if req.Role == "PATIENT" && invoice.PatientID != req.CreatedBy {
+		return onlinePaymentDto.Charge{}, sql.ErrNoRows
+	}
+
+	if (invoice.Status != constants.InvoiceIssued && invoice.Status != constants.InvoicePartiallyPaid) || invoice.Balance <= 0 {
+		return onlinePaymentDto.Charge{}, errors.New(constants.ErrInvoiceNotPayable)
+	}
+
+	open, err := usecase.onlinePaymentRepo.RetrieveOpenCharge(ctx, invoice.ID, invoice.Balance)
+	if err == nil {
+		return open, nil
+	}
+	if err != sql.ErrNoRows {
+		return onlinePaymentDto.Charge{}, err
+	}
+
+	now := time.Now()
+	charge := onlinePaymentDto.Charge{
+		InvoiceID: invoice.ID,
+		Provider:  usecase.provider.Name(),
+		Amount:    invoice.Balance,
+		Status:    constants.ChargePending,
+		CreatedBy: req.CreatedBy,
+		ExpiresAt: now.Add(usecase.chargeTTL).Format("2006-01-02 15:04:05"),
+		CreatedAt: now.Format("2006-01-02 15:04:05"),
+	}
+	charge.ID, err = usecase.onlinePaymentRepo.InsertCharge(ctx, charge)
+	if err != nil {
+		return onlinePaymentDto.Charge{}, err
+	}
+
+	// The provider is called outside of any transaction, the charge row
+	// already exists so a callback can never arrive for an unknown order
+	created, err := usecase.provider.CreateCharge(gateway.ChargeRequest{
+		OrderID:      charge.ID,
+		Amount:       charge.Amount,
+		Description:  "Invoice " + invoice.InvoiceNumber,
+		CustomerName: invoice.PatientName,
+		ExpiresIn:    usecase.chargeTTL,
+	})
+	if err != nil {
+		charge.Status, charge.Note = constants.ChargeFailed, err.Error()
+		if updateErr := usecase.onlinePaymentRepo.UpdateCharge(ctx, charge); updateErr != nil {
+			log.Error().Msg("CreateCharge.UpdateCharge.err : " + updateErr.Error())
+		}
+		return onlinePaymentDto.Charge{}, err
+	}
+
+	charge.ProviderRef, charge.PaymentURL = created.ProviderRef, created.PaymentURL
+	if err := usecase.onlinePaymentRepo.UpdateCharge(ctx, charge); err != nil {
+		return onlinePaymentDto.Charge{}, err
+	}
+	return usecase.onlinePaymentRepo.RetrieveChargeByID(ctx, charge.ID)
+}
+
+func (usecase *onlinePaymentUsecase) GetChargeByID(ctx context.Context, id, userID, role string) (onlinePaymentDto.Charge, error) {
+	charge, err := usecase.onlinePaymentRepo.RetrieveChargeByID(ctx, id)
+	if err != nil {
+		return onlinePaymentDto.Charge{}, err
+	}
+
+	if role == "PATIENT" && charge.PatientID != userID {
+		return onlinePaymentDto.Charge{}, sql.ErrNoRows
+	}
+	return charge, nil
+}
+
+// HandleCallback applies a callback after checking its signature. Callbacks
+// repeated by the provider are accepted without changing anything.
+func (usecase *onlinePaymentUsecase) HandleCallback(ctx context.Context, provider string, header http.Header, body []byte) error {
+	if provider == "" || provider != usecase.provider.Name() {
+		return errors.New(constants.ErrUnknownPaymentProvider)
+	}
+
+	notification, err := usecase.provider.ParseCallback(header, body)
+	if err != nil {
+		return err
+	}
+
+	_, err = usecase.onlinePaymentRepo.ApplyNotification(ctx, provider, constants.CallbackSourceWebhook, notification)
+	return err
+}
+
+// Reconcile asks the provider about the charges still pending after their
+// callback should have arrived, catching up on callbacks that never did.
+// Charges the provider has no transaction for are expired once their payment
+// page is.
+func (usecase *onlinePaymentUsecase) Reconcile(ctx context.Context) (onlinePaymentDto.ReconcileResult, error) {
+	var result onlinePaymentDto.ReconcileResult
+	if usecase.provider.Name() == "" {
+		return result, nil
+	}
+
+	now := time.Now()
+	charges, err := usecase.onlinePaymentRepo.RetrievePendingCharges(ctx, now.Add(-callbackGrace).Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return result, err
+	}
+
+	for _, charge := range charges {
+		result.Checked++
+
+		notification, err := usecase.provider.Status(charge.ID)
+		if err != nil {
+			log.Error().Str("charge_id", charge.ID).Msg("Reconcile.Status.err : " + err.Error())
+			continue
+		}
+
+		expiresAt, _ := time.ParseInLocation("2006-01-02 15:04:05", charge.ExpiresAt, time.Local)
+		if notification.Status == gateway.StatusPending && notification.EventKey == "" && now.After(expiresAt) {
+			notification = gateway.Notification{
+				OrderID:  charge.ID,
+				Status:   gateway.StatusExpired,
+				Amount:   charge.Amount,
+				EventKey: charge.ID + ":expired-unused",
+			}
+		}
+
+		if notification.Status == gateway.StatusPending {
+			continue
+		}
+
+		updated, err := usecase.onlinePaymentRepo.ApplyNotification(ctx, charge.Provider, constants.CallbackSourceReconciliation, notification)
+		if err != nil {
+			log.Error().Str("charge_id", charge.ID).Msg("Reconcile.ApplyNotification.err : " + err.Error())
+			continue
+		}
+
+		if updated {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+// StartReconciler periodically reconciles pending charges with the payment
+// provider until the application stops.
+func StartReconciler(onlinePaymentUC onlinePayment.OnlinePaymentUsecase, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			result, err := onlinePaymentUC.Reconcile(context.Background())
+			if err != nil {
+				log.Error().Msg("StartReconciler.err : " + err.Error())
+				continue
+			}
+
+			if result.Updated > 0 {
+				log.Info().Int("checked", result.Checked).Int("updated", result.Updated).Msg("online charges reconciled")
+			}
+		}
+	}()
+}
@@ -0,0 +1,213 @@
+package onlinePaymentUsecase
+
+import (
+	"avengers-clinic/model/dto/onlinePaymentDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/gateway"
+	"avengers-clinic/src/onlinePayment"
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockOnlinePaymentRepository struct {
+	mock.Mock
+}
+
+func (m *mockOnlinePaymentRepository) RetrievePayableInvoice(ctx context.Context, invoiceID string) (onlinePaymentDto.PayableInvoice, error) {
+	args := m.Called(invoiceID)
+	return args.Get(0).(onlinePaymentDto.PayableInvoice), args.Error(1)
+}
+
+func (m *mockOnlinePaymentRepository) RetrieveOpenCharge(ctx context.Context, invoiceID string, amount int) (onlinePaymentDto.Charge, error) {
+	args := m.Called(invoiceID, amount)
+	return args.Get(0).(onlinePaymentDto.Charge), args.Error(1)
+}
+
+func (m *mockOnlinePaymentRepository) RetrieveChargeByID(ctx context.Context, id string) (onlinePaymentDto.Charge, error) {
+	args := m.Called(id)
+	return args.Get(0).(onlinePaymentDto.Charge), args.Error(1)
+}
+
+func (m *mockOnlinePaymentRepository) RetrievePendingCharges(ctx context.Context, createdBefore string) ([]onlinePaymentDto.Charge, error) {
+	args := m.Called(createdBefore)
+	return args.Get(0).([]onlinePaymentDto.Charge), args.Error(1)
+}
+
+func (m *mockOnlinePaymentRepository) InsertCharge(ctx context.Context, charge onlinePaymentDto.Charge) (string, error) {
+	args := m.Called(charge)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockOnlinePaymentRepository) UpdateCharge(ctx context.Context, charge onlinePaymentDto.Charge) error {
+	args := m.Called(charge)
+	return args.Error(0)
+}
+
+func (m *mockOnlinePaymentRepository) ApplyNotification(ctx context.Context, provider, source string, notification gateway.Notification) (bool, error) {
+	args := m.Called(provider, source, notification)
+	return args.Bool(0), args.Error(1)
+}
+
+type onlinePaymentUsecaseTestSuite struct {
+	suite.Suite
+	repo            *mockOnlinePaymentRepository
+	fake            *gateway.FakeMidtrans
+	onlinePaymentUC onlinePayment.OnlinePaymentUsecase
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) SetupTest() {
+	suite.repo = new(mockOnlinePaymentRepository)
+	suite.fake = gateway.NewFakeMidtrans("server-key")
+	suite.onlinePaymentUC = NewOnlinePaymentUsecase(suite.repo, suite.fake.Provider(), time.Hour)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TearDownTest() {
+	suite.fake.Close()
+}
+
+var payable = onlinePaymentDto.PayableInvoice{
+	ID:            "i1",
+	InvoiceNumber: "INV/202610/00001",
+	Status:        constants.InvoiceIssued,
+	Balance:       150000,
+	PatientID:     "p1",
+	PatientName:   "patient",
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestCreateCharge() {
+	suite.repo.On("RetrievePayableInvoice", "i1").Return(payable, nil)
+	suite.repo.On("RetrieveOpenCharge", "i1", 150000).Return(onlinePaymentDto.Charge{}, sql.ErrNoRows)
+	suite.repo.On("InsertCharge", mock.MatchedBy(func(charge onlinePaymentDto.Charge) bool {
+		return charge.Amount == 150000 && charge.Provider == "midtrans" && charge.Status == constants.ChargePending
+	})).Return("c1", nil)
+	suite.repo.On("UpdateCharge", mock.MatchedBy(func(charge onlinePaymentDto.Charge) bool {
+		return charge.ID == "c1" && charge.Status == constants.ChargePending && strings.HasSuffix(charge.PaymentURL, "token-c1")
+	})).Return(nil)
+	suite.repo.On("RetrieveChargeByID", "c1").Return(onlinePaymentDto.Charge{ID: "c1", PaymentURL: "url"}, nil)
+
+	charge, err := suite.onlinePaymentUC.CreateCharge(context.Background(), onlinePaymentDto.ChargeRequest{InvoiceID: "i1", CreatedBy: "p1", Role: "PATIENT"})
+
+	suite.Nil(err)
+	suite.Equal("c1", charge.ID)
+	suite.Equal(1, suite.fake.Created())
+	suite.repo.AssertExpectations(suite.T())
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestCreateChargeReusesOpenCharge() {
+	suite.repo.On("RetrievePayableInvoice", "i1").Return(payable, nil)
+	suite.repo.On("RetrieveOpenCharge", "i1", 150000).Return(onlinePaymentDto.Charge{ID: "c1"}, nil)
+
+	charge, err := suite.onlinePaymentUC.CreateCharge(context.Background(), onlinePaymentDto.ChargeRequest{InvoiceID: "i1", CreatedBy: "a1", Role: "ADMIN"})
+
+	suite.Nil(err)
+	suite.Equal("c1", charge.ID)
+	suite.Equal(0, suite.fake.Created())
+	suite.repo.AssertNotCalled(suite.T(), "InsertCharge", mock.Anything)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestCreateChargeOtherPatient() {
+	suite.repo.On("RetrievePayableInvoice", "i1").Return(payable, nil)
+
+	_, err := suite.onlinePaymentUC.CreateCharge(context.Background(), onlinePaymentDto.ChargeRequest{InvoiceID: "i1", CreatedBy: "p2", Role: "PATIENT"})
+
+	suite.Equal(sql.ErrNoRows, err)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestCreateChargeNotPayable() {
+	paid := payable
+	paid.Status, paid.Balance = constants.InvoicePaid, 0
+	suite.repo.On("RetrievePayableInvoice", "i1").Return(paid, nil)
+
+	_, err := suite.onlinePaymentUC.CreateCharge(context.Background(), onlinePaymentDto.ChargeRequest{InvoiceID: "i1", CreatedBy: "a1", Role: "ADMIN"})
+
+	suite.EqualError(err, constants.ErrInvoiceNotPayable)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestCreateChargeDisabled() {
+	onlinePaymentUC := NewOnlinePaymentUsecase(suite.repo, gateway.Disabled{}, time.Hour)
+
+	_, err := onlinePaymentUC.CreateCharge(context.Background(), onlinePaymentDto.ChargeRequest{InvoiceID: "i1"})
+
+	suite.Equal(gateway.ErrDisabled, err)
+	suite.repo.AssertNotCalled(suite.T(), "RetrievePayableInvoice", mock.Anything)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) createCharge(id string) {
+	suite.repo.On("RetrievePayableInvoice", "i1").Return(payable, nil).Once()
+	suite.repo.On("RetrieveOpenCharge", "i1", 150000).Return(onlinePaymentDto.Charge{}, sql.ErrNoRows).Once()
+	suite.repo.On("InsertCharge", mock.Anything).Return(id, nil).Once()
+	suite.repo.On("UpdateCharge", mock.Anything).Return(nil).Once()
+	suite.repo.On("RetrieveChargeByID", id).Return(onlinePaymentDto.Charge{ID: id}, nil).Once()
+
+	_, err := suite.onlinePaymentUC.CreateCharge(context.Background(), onlinePaymentDto.ChargeRequest{InvoiceID: "i1", CreatedBy: "a1", Role: "ADMIN"})
+	suite.Require().Nil(err)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestHandleCallback() {
+	suite.createCharge("c1")
+	suite.fake.SetStatus("c1", "settlement", "qris")
+
+	suite.repo.On("ApplyNotification", "midtrans", constants.CallbackSourceWebhook, mock.MatchedBy(func(n gateway.Notification) bool {
+		return n.OrderID == "c1" && n.Status == gateway.StatusPaid && n.Amount == 150000 && n.Method == "qris"
+	})).Return(true, nil)
+
+	err := suite.onlinePaymentUC.HandleCallback(context.Background(), "midtrans", http.Header{}, suite.fake.Callback("c1"))
+
+	suite.Nil(err)
+	suite.repo.AssertExpectations(suite.T())
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestHandleCallbackInvalidSignature() {
+	suite.createCharge("c1")
+	suite.fake.SetStatus("c1", "settlement", "qris")
+	body := strings.Replace(string(suite.fake.Callback("c1")), "150000.00", "1.00", 1)
+
+	err := suite.onlinePaymentUC.HandleCallback(context.Background(), "midtrans", http.Header{}, []byte(body))
+
+	suite.Equal(gateway.ErrInvalidSignature, err)
+	suite.repo.AssertNotCalled(suite.T(), "ApplyNotification", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestHandleCallbackUnknownProvider() {
+	err := suite.onlinePaymentUC.HandleCallback(context.Background(), "xendit", http.Header{}, []byte("{}"))
+
+	suite.EqualError(err, constants.ErrUnknownPaymentProvider)
+}
+
+func (suite *onlinePaymentUsecaseTestSuite) TestReconcile() {
+	suite.createCharge("c1")
+	suite.createCharge("c2")
+	suite.createCharge("c3")
+	suite.fake.SetStatus("c1", "settlement", "bank_transfer")
+
+	future := time.Now().Add(time.Hour).Format("2006-01-02 15:04:05")
+	past := time.Now().Add(-time.Minute).Format("2006-01-02 15:04:05")
+	suite.repo.On("RetrievePendingCharges", mock.Anything).Return([]onlinePaymentDto.Charge{
+		{ID: "c1", Provider: "midtrans", Amount: 150000, ExpiresAt: future},
+		{ID: "c2", Provider: "midtrans", Amount: 150000, ExpiresAt: future},
+		{ID: "c3", Provider: "midtrans", Amount: 150000, ExpiresAt: past},
+	}, nil)
+	suite.repo.On("ApplyNotification", "midtrans", constants.CallbackSourceReconciliation, mock.MatchedBy(func(n gateway.Notification) bool {
+		return n.OrderID == "c1" && n.Status == gateway.StatusPaid
+	})).Return(true, nil)
+	suite.repo.On("ApplyNotification", "midtrans", constants.CallbackSourceReconciliation, mock.MatchedBy(func(n gateway.Notification) bool {
+		return n.OrderID == "c3" && n.Status == gateway.StatusExpired && n.EventKey == "c3:expired-unused"
+	})).Return(true, nil)
+
+	result, err := suite.onlinePaymentUC.Reconcile(context.Background())
+
+	suite.Nil(err)
+	suite.Equal(onlinePaymentDto.ReconcileResult{Checked: 3, Updated: 2}, result)
+	suite.repo.AssertNumberOfCalls(suite.T(), "ApplyNotification", 2)
+}
+
+func TestOnlinePaymentUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(onlinePaymentUsecaseTestSuite))
+}
@@ -0,0 +1,131 @@
+package reportDelivery
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/report"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type reportDelivery struct {
+	reportUC report.ReportUsecase
+}
+
+func NewReportDelivery(v1Group *gin.RouterGroup, reportUC report.ReportUsecase) {
+	handler := reportDelivery{reportUC}
+
+	reportGroup := v1Group.Group("/reports")
+	{
+		reportGroup.GET("/cashier-closings", middleware.JwtAuth("ADMIN"), handler.GetCashierClosings)
+		reportGroup.POST("/cashier-closings", middleware.JwtAuth("ADMIN"), handler.CloseCashier)
+		reportGroup.GET("/revenue", middleware.JwtAuth("ADMIN"), handler.GetRevenue)
+		reportGroup.GET("/outstanding", middleware.JwtAuth("ADMIN"), handler.GetOutstanding)
+	}
+}
+
+func (delivery *reportDelivery) GetCashierClosings(c *gin.Context) {
+	filter := reportDto.ClosingFilter{
+		BusinessDate: c.Query("date"),
+		CashierID:    c.Query("cashier_id"),
+	}
+
+	closings, err := delivery.reportUC.GetCashierClosings(c.Request.Context(), filter)
+	if err != nil {
+		delivery.reportError(c, err, "01")
+		return
+	}
+
+	delivery.respond(c, "cashier-closing", closings, "Cashier closings retrieved successfully", "01")
+}
+
+func (delivery *reportDelivery) CloseCashier(c *gin.Context) {
+	var request reportDto.ClosingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		json.NewResponseError(c, err.Error(), constants.ReportService, "02")
+		return
+	}
+
+	if err := utils.Validated(request); err != nil {
+		json.NewResponseBadRequest(c, err, "Bad request", constants.ReportService, "01")
+		return
+	}
+
+	request.ClosedBy = utils.GetJWT(c).ID
+	closing, err := delivery.reportUC.CloseCashier(c.Request.Context(), request)
+	if err != nil {
+		delivery.reportError(c, err, "03")
+		return
+	}
+
+	json.NewResponseCreated(c, closing, "Cashier closed successfully", constants.ReportService, "01")
+}
+
+func (delivery *reportDelivery) GetRevenue(c *gin.Context) {
+	filter := reportDto.RevenueFilter{
+		GroupBy:   c.Query("group_by"),
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+	}
+
+	revenue, err := delivery.reportUC.GetRevenue(c.Request.Context(), filter)
+	if err != nil {
+		delivery.reportError(c, err, "04")
+		return
+	}
+
+	delivery.respond(c, "revenue", revenue, "Revenue retrieved successfully", "02")
+}
+
+func (delivery *reportDelivery) GetOutstanding(c *gin.Context) {
+	records, err := delivery.reportUC.GetOutstanding(c.Request.Context())
+	if err != nil {
+		delivery.reportError(c, err, "05")
+		return
+	}
+
+	delivery.respond(c, "outstanding", records, "Outstanding medical records retrieved successfully", "03")
+}
+
+// respond sends the report as JSON, or as a file download when the format
+// query asks for csv or xlsx.
+func (delivery *reportDelivery) respond(c *gin.Context, name string, report interface{}, message, responseCode string) {
+	format := strings.ToLower(c.DefaultQuery("format", constants.ReportFormatJSON))
+	if format == constants.ReportFormatJSON {
+		json.NewResponseSuccess(c, report, message, constants.ReportService, responseCode)
+		return
+	}
+
+	export, err := delivery.reportUC.Export(c.Request.Context(), name, format, report)
+	if err != nil {
+		delivery.reportError(c, err, "06")
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName+`"`)
+	c.Data(http.StatusOK, export.ContentType, export.Content)
+}
+
+func (delivery *reportDelivery) reportError(c *gin.Context, err error, errorCode string) {
+	switch err.Error() {
+	case constants.ErrDateFormat, constants.ErrInvalidDateRange:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "date", Message: err.Error()}}, "Bad request", constants.ReportService, "02")
+		return
+	case constants.ErrInvalidRevenueGroup:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "group_by", Message: err.Error()}}, "Bad request", constants.ReportService, "03")
+		return
+	case constants.ErrInvalidReportFormat:
+		json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "format", Message: err.Error()}}, "Bad request", constants.ReportService, "04")
+		return
+	case constants.ErrCashierAlreadyClosed:
+		json.NewResponseBadRequest(c, []json.ValidationField{}, err.Error(), constants.ReportService, "05")
+		return
+	}
+
+	json.NewResponseError(c, err.Error(), constants.ReportService, errorCode)
+}
@@ -0,0 +1,22 @@
+package report
+
+import (
+	"avengers-clinic/model/dto/reportDto"
+	"context"
+)
+
+type ReportRepository interface {
+	RetrieveCashierSummaries(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.CashierClosing, error)
+	RetrieveClosings(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.Closing, error)
+	InsertClosing(ctx context.Context, closing reportDto.Closing) (string, error)
+	RetrieveRevenue(ctx context.Context, filter reportDto.RevenueFilter) ([]reportDto.Revenue, error)
+	RetrieveOutstanding(ctx context.Context) ([]reportDto.Outstanding, error)
+}
+
+type ReportUsecase interface {
+	GetCashierClosings(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.CashierClosing, error)
+	CloseCashier(ctx context.Context, req reportDto.ClosingRequest) (reportDto.CashierClosing, error)
+	GetRevenue(ctx context.Context, filter reportDto.RevenueFilter) ([]reportDto.Revenue, error)
+	GetOutstanding(ctx context.Context) ([]reportDto.Outstanding, error)
+	Export(ctx context.Context, name, format string, report interface{}) (reportDto.Export, error)
+}
@@ -0,0 +1,218 @@
+package reportRepository
+
+import (
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/report"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type reportRepository struct {
+	db *sql.DB
+}
+
+func NewReportRepository(db *sql.DB) report.ReportRepository {
+	return &reportRepository{db}
+}
+
+// paidRecords restricts a revenue query to the paid medical records created
+// in the period of the filter.
+const paidRecords = `
+	r.payment_status = true AND r.deleted_at IS NULL AND r.created_at >= $1::date AND r.created_at < $2::date + 1`
+
+var revenueQueries = map[string]string{
+	constants.RevenueByDoctor: `
+		SELECT d.id::text, d.username, COUNT(*), COUNT(*), COALESCE(SUM(r.total_amount), 0)
+		FROM medical_records r
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users d ON d.id = s.doctor_id
+		WHERE ` + paidRecords + `
+		GROUP BY d.id, d.username ORDER BY 5 DESC, 2;`,
+	constants.RevenueByAction: `
+		SELECT a.id::text, a.name, COUNT(DISTINCT r.id), COUNT(*), COALESCE(SUM(ad.action_price), 0)
+		FROM medical_record_action_details ad
+		JOIN medical_records r ON r.id = ad.medical_record_id
+		JOIN actions a ON a.id = ad.action_id
+		WHERE ad.deleted_at IS NULL AND ` + paidRecords + `
+		GROUP BY a.id, a.name ORDER BY 5 DESC, 2;`,
+	constants.RevenueByMedicine: `
+		SELECT m.id::text, m.name, COUNT(DISTINCT r.id), COALESCE(SUM(md.quantity), 0), COALESCE(SUM(md.medicine_price * md.quantity), 0)
+		FROM medical_record_medicine_details md
+		JOIN medical_records r ON r.id = md.medical_record_id
+		JOIN medicines m ON m.id = md.medicine_id
+		WHERE md.deleted_at IS NULL AND ` + paidRecords + `
+		GROUP BY m.id, m.name ORDER BY 5 DESC, 2;`,
+	constants.RevenueByDay: `
+		SELECT TO_CHAR(r.created_at, 'YYYY-MM-DD') AS period, TO_CHAR(r.created_at, 'YYYY-MM-DD'), COUNT(*), COUNT(*),
+			COALESCE(SUM(r.total_amount), 0)
+		FROM medical_records r
+		WHERE ` + paidRecords + `
+		GROUP BY 1, 2 ORDER BY 1;`,
+	constants.RevenueByMonth: `
+		SELECT TO_CHAR(r.created_at, 'YYYY-MM') AS period, TO_CHAR(r.created_at, 'YYYY-MM'), COUNT(*), COUNT(*),
+			COALESCE(SUM(r.total_amount), 0)
+		FROM medical_records r
+		WHERE ` + paidRecords + `
+		GROUP BY 1, 2 ORDER BY 1;`,
+}
+
+// RetrieveCashierSummaries sums the payments taken and the refunds given by
+// each cashier on a day, per payment method. Voided payments are left out.
+func (repository *reportRepository) RetrieveCashierSummaries(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.CashierClosing, error) {
+	query := `
+		SELECT t.cashier_id, u.username, t.method, SUM(t.payments), SUM(t.paid), SUM(t.refunds), SUM(t.refunded)
+		FROM (
+			SELECT cashier_id, method, COUNT(*) AS payments, SUM(amount) AS paid, 0 AS refunds, 0 AS refunded
+			FROM invoice_payments WHERE status = $3 AND cashier_id IS NOT NULL AND created_at::date = $1::date
+			GROUP BY cashier_id, method
+			UNION ALL
+			SELECT created_by, method, 0, 0, COUNT(*), SUM(amount)
+			FROM invoice_refunds WHERE created_by IS NOT NULL AND created_at::date = $1::date
+			GROUP BY created_by, method
+		) t
+		JOIN users u ON u.id = t.cashier_id
+		WHERE $2 = '' OR t.cashier_id::text = $2
+		GROUP BY t.cashier_id, u.username, t.method
+		ORDER BY u.username, t.method;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.BusinessDate, filter.CashierID, constants.PaymentCompleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []reportDto.CashierClosing
+	for rows.Next() {
+		var cashierID, cashierName string
+		var method reportDto.MethodSummary
+		err := rows.Scan(&cashierID, &cashierName, &method.Method, &method.Payments, &method.Paid, &method.Refunds, &method.Refunded)
+		if err != nil {
+			return nil, err
+		}
+
+		last := len(summaries) - 1
+		if last < 0 || summaries[last].CashierID != cashierID {
+			summaries = append(summaries, reportDto.CashierClosing{
+				CashierID:    cashierID,
+				CashierName:  cashierName,
+				BusinessDate: filter.BusinessDate,
+			})
+			last++
+		}
+		summaries[last].Methods = append(summaries[last].Methods, method)
+	}
+	return summaries, nil
+}
+
+func (repository *reportRepository) RetrieveClosings(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.Closing, error) {
+	query := `
+		SELECT id, cashier_id, business_date::text, expected_cash, counted_cash, difference, COALESCE(note, ''),
+			COALESCE(closed_by::text, ''), TO_CHAR(created_at, 'YYYY-MM-DD HH24:MI:SS')
+		FROM cashier_closings
+		WHERE business_date = $1::date AND ($2 = '' OR cashier_id::text = $2)
+		ORDER BY created_at;`
+	rows, err := repository.db.QueryContext(ctx, query, filter.BusinessDate, filter.CashierID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var closings []reportDto.Closing
+	for rows.Next() {
+		var closing reportDto.Closing
+		err := rows.Scan(&closing.ID, &closing.CashierID, &closing.BusinessDate, &closing.ExpectedCash, &closing.CountedCash,
+			&closing.Difference, &closing.Note, &closing.ClosedBy, &closing.ClosedAt)
+		if err != nil {
+			return nil, err
+		}
+		closings = append(closings, closing)
+	}
+	return closings, nil
+}
+
+func (repository *reportRepository) InsertClosing(ctx context.Context, closing reportDto.Closing) (string, error) {
+	var id string
+	query := `
+		INSERT INTO cashier_closings (cashier_id, business_date, expected_cash, counted_cash, difference, note, closed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+	err := repository.db.QueryRowContext(ctx, query, closing.CashierID, closing.BusinessDate, closing.ExpectedCash, closing.CountedCash,
+		closing.Difference, nullable(closing.Note), nullable(closing.ClosedBy)).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return "", errors.New(constants.ErrCashierAlreadyClosed)
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+func (repository *reportRepository) RetrieveRevenue(ctx context.Context, filter reportDto.RevenueFilter) ([]reportDto.Revenue, error) {
+	query, ok := revenueQueries[filter.GroupBy]
+	if !ok {
+		return nil, errors.New(constants.ErrInvalidRevenueGroup)
+	}
+
+	rows, err := repository.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revenue []reportDto.Revenue
+	for rows.Next() {
+		var row reportDto.Revenue
+		if err := rows.Scan(&row.Key, &row.Name, &row.Records, &row.Quantity, &row.Amount); err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, row)
+	}
+	return revenue, nil
+}
+
+// RetrieveOutstanding lists the medical records that are not paid yet. When
+// the record has an active invoice its total and payments are used, since the
+// invoice adds the consultation fee, discounts and tax.
+func (repository *reportRepository) RetrieveOutstanding(ctx context.Context) ([]reportDto.Outstanding, error) {
+	query := `
+		SELECT r.id, p.username, d.username, TO_CHAR(r.created_at, 'YYYY-MM-DD HH24:MI:SS'), COALESCE(i.invoice_number, ''),
+			COALESCE(i.status::text, ''), COALESCE(i.total, r.total_amount, 0), COALESCE(i.paid_amount, 0)
+		FROM medical_records r
+		JOIN bookings b ON b.id = r.booking_id
+		JOIN users p ON p.id = b.patient_id
+		JOIN doctor_schedules s ON s.id = b.doctor_schedule_id
+		JOIN users d ON d.id = s.doctor_id
+		LEFT JOIN invoices i ON i.medical_record_id = r.id AND i.status <> $1
+		WHERE COALESCE(r.payment_status, false) = false AND r.deleted_at IS NULL
+		ORDER BY r.created_at;`
+	rows, err := repository.db.QueryContext(ctx, query, constants.InvoiceVoid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []reportDto.Outstanding
+	for rows.Next() {
+		var record reportDto.Outstanding
+		err := rows.Scan(&record.MedicalRecordID, &record.PatientName, &record.DoctorName, &record.CreatedAt,
+			&record.InvoiceNumber, &record.InvoiceStatus, &record.Amount, &record.Paid)
+		if err != nil {
+			return nil, err
+		}
+		record.Balance = record.Amount - record.Paid
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// nullable stores empty strings as NULL for the optional columns.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package reportRepository
+
+import (
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/report"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type reportRepositoryTestSuite struct {
+	suite.Suite
+	reportRepo report.ReportRepository
+	mock       sqlmock.Sqlmock
+}
+
+func (suite *reportRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.reportRepo = NewReportRepository(db)
+	suite.mock = mock
+}
+
+func (suite *reportRepositoryTestSuite) TestRetrieveCashierSummaries() {
+	rows := sqlmock.NewRows([]string{"cashier_id", "username", "method", "payments", "paid", "refunds", "refunded"}).
+		AddRow("u1", "anna", "CASH", 3, 450000, 1, 50000).
+		AddRow("u1", "anna", "QRIS", 2, 200000, 0, 0).
+		AddRow("u2", "budi", "CASH", 1, 75000, 0, 0)
+	suite.mock.ExpectQuery(`FROM invoice_payments WHERE status = \$3`).
+		WithArgs("2024-03-01", "", constants.PaymentCompleted).
+		WillReturnRows(rows)
+
+	summaries, err := suite.reportRepo.RetrieveCashierSummaries(context.Background(), reportDto.ClosingFilter{BusinessDate: "2024-03-01"})
+
+	suite.Nil(err)
+	suite.Len(summaries, 2)
+	suite.Len(summaries[0].Methods, 2)
+	suite.Equal("2024-03-01", summaries[0].BusinessDate)
+	suite.Equal("budi", summaries[1].CashierName)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *reportRepositoryTestSuite) TestInsertClosing() {
+	suite.mock.ExpectQuery(`INSERT INTO cashier_closings`).
+		WithArgs("u1", "2024-03-01", 400000, 390000, -10000, nil, "a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("cl1"))
+
+	id, err := suite.reportRepo.InsertClosing(context.Background(), reportDto.Closing{
+		CashierID: "u1", BusinessDate: "2024-03-01", ExpectedCash: 400000, CountedCash: 390000, Difference: -10000, ClosedBy: "a1",
+	})
+
+	suite.Nil(err)
+	suite.Equal("cl1", id)
+}
+
+func (suite *reportRepositoryTestSuite) TestInsertClosingAlreadyClosed() {
+	suite.mock.ExpectQuery(`INSERT INTO cashier_closings`).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err := suite.reportRepo.InsertClosing(context.Background(), reportDto.Closing{CashierID: "u1", BusinessDate: "2024-03-01"})
+
+	suite.EqualError(err, constants.ErrCashierAlreadyClosed)
+}
+
+func (suite *reportRepositoryTestSuite) TestRetrieveRevenueByMedicine() {
+	rows := sqlmock.NewRows([]string{"id", "name", "records", "quantity", "amount"}).
+		AddRow("m1", "Paracetamol", 4, 12, 60000)
+	suite.mock.ExpectQuery(`FROM medical_record_medicine_details md`).
+		WithArgs("2024-03-01", "2024-03-31").
+		WillReturnRows(rows)
+
+	revenue, err := suite.reportRepo.RetrieveRevenue(context.Background(), reportDto.RevenueFilter{
+		GroupBy: constants.RevenueByMedicine, StartDate: "2024-03-01", EndDate: "2024-03-31",
+	})
+
+	suite.Nil(err)
+	suite.Equal([]reportDto.Revenue{{Key: "m1", Name: "Paracetamol", Records: 4, Quantity: 12, Amount: 60000}}, revenue)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *reportRepositoryTestSuite) TestRetrieveRevenueInvalidGroup() {
+	_, err := suite.reportRepo.RetrieveRevenue(context.Background(), reportDto.RevenueFilter{GroupBy: "patient"})
+
+	suite.EqualError(err, constants.ErrInvalidRevenueGroup)
+}
+
+func (suite *reportRepositoryTestSuite) TestRetrieveOutstanding() {
+	rows := sqlmock.NewRows([]string{"id", "patient", "doctor", "created_at", "invoice_number", "status", "amount", "paid"}).
+		AddRow("r1", "pasien", "dokter", "2024-03-01 10:00:00", "INV/202403/0001", "PARTIALLY_PAID", 250000, 100000).
+		AddRow("r2", "pasien", "dokter", "2024-03-02 10:00:00", "", "", 80000, 0)
+	suite.mock.ExpectQuery(`LEFT JOIN invoices i ON i.medical_record_id = r.id AND i.status <> \$1`).
+		WithArgs(constants.InvoiceVoid).
+		WillReturnRows(rows)
+
+	records, err := suite.reportRepo.RetrieveOutstanding(context.Background())
+
+	suite.Nil(err)
+	suite.Len(records, 2)
+	suite.Equal(150000, records[0].Balance)
+	suite.Equal(80000, records[1].Balance)
+}
+
+func TestReportRepository(t *testing.T) {
+	suite.Run(t, new(reportRepositoryTestSuite))
+}
@@ -0,0 +1,80 @@
+package reportUsecase
+
+import (
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// table is a report laid out in rows, the way it is written to a file.
+type table struct {
+	header []string
+	rows   [][]interface{}
+}
+
+// Export writes a report as a CSV or XLSX file named after the report.
+func (usecase *reportUsecase) Export(ctx context.Context, name, format string, report interface{}) (reportDto.Export, error) {
+	data, err := toTable(report)
+	if err != nil {
+		return reportDto.Export{}, err
+	}
+
+	file, err := utils.WriteSheet(name, format, data.header, data.rows)
+	if err != nil {
+		if err.Error() == constants.ErrInvalidFileFormat {
+			return reportDto.Export{}, errors.New(constants.ErrInvalidReportFormat)
+		}
+		return reportDto.Export{}, err
+	}
+	return reportDto.Export{FileName: file.FileName, ContentType: file.ContentType, Content: file.Content}, nil
+}
+
+func toTable(report interface{}) (table, error) {
+	switch report := report.(type) {
+	case []reportDto.CashierClosing:
+		data := table{header: []string{
+			"business_date", "cashier_id", "cashier_name", "method", "payments", "paid", "refunds", "refunded", "net",
+			"expected_cash", "counted_cash", "difference",
+		}}
+		for _, summary := range report {
+			var counted, difference interface{} = "", ""
+			if summary.Closing != nil {
+				counted, difference = summary.Closing.CountedCash, summary.Closing.Difference
+			}
+
+			methods := summary.Methods
+			if len(methods) == 0 {
+				methods = []reportDto.MethodSummary{{}}
+			}
+			for _, method := range methods {
+				data.rows = append(data.rows, []interface{}{
+					summary.BusinessDate, summary.CashierID, summary.CashierName, method.Method, method.Payments, method.Paid,
+					method.Refunds, method.Refunded, method.Net, summary.ExpectedCash, counted, difference,
+				})
+			}
+		}
+		return data, nil
+	case []reportDto.Revenue:
+		data := table{header: []string{"key", "name", "records", "quantity", "amount"}}
+		for _, row := range report {
+			data.rows = append(data.rows, []interface{}{row.Key, row.Name, row.Records, row.Quantity, row.Amount})
+		}
+		return data, nil
+	case []reportDto.Outstanding:
+		data := table{header: []string{
+			"medical_record_id", "patient_name", "doctor_name", "created_at", "invoice_number", "invoice_status",
+			"amount", "paid", "balance",
+		}}
+		for _, record := range report {
+			data.rows = append(data.rows, []interface{}{
+				record.MedicalRecordID, record.PatientName, record.DoctorName, record.CreatedAt, record.InvoiceNumber,
+				record.InvoiceStatus, record.Amount, record.Paid, record.Balance,
+			})
+		}
+		return data, nil
+	}
+	return table{}, fmt.Errorf("unsupported report %T", report)
+}
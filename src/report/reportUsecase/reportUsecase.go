@@ -0,0 +1,144 @@
+package reportUsecase
+
+import (
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/report"
+	"context"
+	"strings"
+	"time"
+)
+
+type reportUsecase struct {
+	reportRepo report.ReportRepository
+}
+
+func NewReportUsecase(reportRepo report.ReportRepository) report.ReportUsecase {
+	return &reportUsecase{reportRepo}
+}
+
+// GetCashierClosings sums up the day of every cashier that took a payment or
+// gave a refund, together with the closing when the drawer was counted.
+func (usecase *reportUsecase) GetCashierClosings(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.CashierClosing, error) {
+	businessDate, err := businessDate(filter.BusinessDate)
+	if err != nil {
+		return nil, err
+	}
+	filter.BusinessDate = businessDate
+
+	summaries, err := usecase.reportRepo.RetrieveCashierSummaries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	closings, err := usecase.reportRepo.RetrieveClosings(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		summarize(&summaries[i])
+	}
+
+	for i := range closings {
+		found := false
+		for j := range summaries {
+			if summaries[j].CashierID == closings[i].CashierID {
+				summaries[j].Closing, found = &closings[i], true
+				break
+			}
+		}
+
+		// a drawer counted on a day without any transaction is still shown
+		if !found {
+			summaries = append(summaries, reportDto.CashierClosing{
+				CashierID:    closings[i].CashierID,
+				BusinessDate: filter.BusinessDate,
+				Closing:      &closings[i],
+			})
+		}
+	}
+	return summaries, nil
+}
+
+// CloseCashier records the cash counted in the drawer against the cash the
+// day of the cashier should have left in it. A day is closed only once.
+func (usecase *reportUsecase) CloseCashier(ctx context.Context, req reportDto.ClosingRequest) (reportDto.CashierClosing, error) {
+	businessDate, err := businessDate(req.BusinessDate)
+	if err != nil {
+		return reportDto.CashierClosing{}, err
+	}
+
+	filter := reportDto.ClosingFilter{BusinessDate: businessDate, CashierID: req.CashierID}
+	summaries, err := usecase.reportRepo.RetrieveCashierSummaries(ctx, filter)
+	if err != nil {
+		return reportDto.CashierClosing{}, err
+	}
+
+	summary := reportDto.CashierClosing{CashierID: req.CashierID, BusinessDate: businessDate}
+	if len(summaries) > 0 {
+		summary = summaries[0]
+	}
+	summarize(&summary)
+
+	closing := reportDto.Closing{
+		CashierID:    req.CashierID,
+		BusinessDate: businessDate,
+		ExpectedCash: summary.ExpectedCash,
+		CountedCash:  req.CountedCash,
+		Difference:   req.CountedCash - summary.ExpectedCash,
+		Note:         strings.TrimSpace(req.Note),
+		ClosedBy:     req.ClosedBy,
+	}
+	if closing.ID, err = usecase.reportRepo.InsertClosing(ctx, closing); err != nil {
+		return reportDto.CashierClosing{}, err
+	}
+
+	closing.ClosedAt = utils.GetNow()
+	summary.Closing = &closing
+	return summary, nil
+}
+
+// GetRevenue groups the revenue of paid medical records. Without dates the
+// report covers the current month up to today.
+func (usecase *reportUsecase) GetRevenue(ctx context.Context, filter reportDto.RevenueFilter) ([]reportDto.Revenue, error) {
+	filter.GroupBy = strings.ToLower(filter.GroupBy)
+	if filter.GroupBy == "" {
+		filter.GroupBy = constants.RevenueByDay
+	}
+
+	var err error
+	filter.StartDate, filter.EndDate, err = utils.DateRange(filter.StartDate, filter.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return usecase.reportRepo.RetrieveRevenue(ctx, filter)
+}
+
+func (usecase *reportUsecase) GetOutstanding(ctx context.Context) ([]reportDto.Outstanding, error) {
+	return usecase.reportRepo.RetrieveOutstanding(ctx)
+}
+
+// summarize adds up the methods of a cashier. Only cash goes through the
+// drawer, so the other methods do not change the expected cash.
+func summarize(summary *reportDto.CashierClosing) {
+	summary.TotalPaid, summary.TotalRefunded, summary.ExpectedCash = 0, 0, 0
+	for i, method := range summary.Methods {
+		summary.Methods[i].Net = method.Paid - method.Refunded
+		summary.TotalPaid += method.Paid
+		summary.TotalRefunded += method.Refunded
+		if method.Method == constants.PaymentCash {
+			summary.ExpectedCash += method.Paid - method.Refunded
+		}
+	}
+}
+
+// businessDate defaults to today.
+func businessDate(value string) (string, error) {
+	if value == "" {
+		return time.Now().Format("2006-01-02"), nil
+	}
+	return utils.FormatDate(value)
+}
@@ -0,0 +1,211 @@
+package reportUsecase
+
+import (
+	"avengers-clinic/model/dto/reportDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/report"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockReportRepository struct {
+	mock.Mock
+}
+
+func (m *mockReportRepository) RetrieveCashierSummaries(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.CashierClosing, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]reportDto.CashierClosing), args.Error(1)
+}
+
+func (m *mockReportRepository) RetrieveClosings(ctx context.Context, filter reportDto.ClosingFilter) ([]reportDto.Closing, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]reportDto.Closing), args.Error(1)
+}
+
+func (m *mockReportRepository) InsertClosing(ctx context.Context, closing reportDto.Closing) (string, error) {
+	args := m.Called(closing)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockReportRepository) RetrieveRevenue(ctx context.Context, filter reportDto.RevenueFilter) ([]reportDto.Revenue, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]reportDto.Revenue), args.Error(1)
+}
+
+func (m *mockReportRepository) RetrieveOutstanding(ctx context.Context) ([]reportDto.Outstanding, error) {
+	args := m.Called()
+	return args.Get(0).([]reportDto.Outstanding), args.Error(1)
+}
+
+type reportUsecaseTestSuite struct {
+	suite.Suite
+	reportRepo *mockReportRepository
+	reportUC   report.ReportUsecase
+}
+
+func (suite *reportUsecaseTestSuite) SetupTest() {
+	suite.reportRepo = new(mockReportRepository)
+	suite.reportUC = NewReportUsecase(suite.reportRepo)
+}
+
+func cashierDay() []reportDto.CashierClosing {
+	return []reportDto.CashierClosing{
+		{
+			CashierID:    "u1",
+			CashierName:  "cashier",
+			BusinessDate: "2024-03-01",
+			Methods: []reportDto.MethodSummary{
+				{Method: constants.PaymentCash, Payments: 3, Paid: 450000, Refunds: 1, Refunded: 50000},
+				{Method: constants.PaymentQRIS, Payments: 2, Paid: 200000},
+			},
+		},
+	}
+}
+
+func (suite *reportUsecaseTestSuite) TestGetCashierClosings() {
+	filter := reportDto.ClosingFilter{BusinessDate: "2024-03-01"}
+	suite.reportRepo.On("RetrieveCashierSummaries", filter).Return(cashierDay(), nil)
+	suite.reportRepo.On("RetrieveClosings", filter).Return([]reportDto.Closing{
+		{ID: "cl1", CashierID: "u1", CountedCash: 390000, Difference: -10000},
+		{ID: "cl2", CashierID: "u2", CountedCash: 0},
+	}, nil)
+
+	closings, err := suite.reportUC.GetCashierClosings(context.Background(), filter)
+
+	suite.Nil(err)
+	suite.Len(closings, 2)
+	suite.Equal(650000, closings[0].TotalPaid)
+	suite.Equal(50000, closings[0].TotalRefunded)
+	suite.Equal(400000, closings[0].ExpectedCash)
+	suite.Equal(400000, closings[0].Methods[0].Net)
+	suite.Equal("cl1", closings[0].Closing.ID)
+	suite.Equal("u2", closings[1].CashierID)
+	suite.Equal("cl2", closings[1].Closing.ID)
+}
+
+func (suite *reportUsecaseTestSuite) TestGetCashierClosingsDefaultsToToday() {
+	filter := reportDto.ClosingFilter{BusinessDate: time.Now().Format("2006-01-02")}
+	suite.reportRepo.On("RetrieveCashierSummaries", filter).Return([]reportDto.CashierClosing{}, nil)
+	suite.reportRepo.On("RetrieveClosings", filter).Return([]reportDto.Closing{}, nil)
+
+	_, err := suite.reportUC.GetCashierClosings(context.Background(), reportDto.ClosingFilter{})
+
+	suite.Nil(err)
+	suite.reportRepo.AssertExpectations(suite.T())
+}
+
+func (suite *reportUsecaseTestSuite) TestGetCashierClosingsInvalidDate() {
+	_, err := suite.reportUC.GetCashierClosings(context.Background(), reportDto.ClosingFilter{BusinessDate: "01-03-2024"})
+
+	suite.EqualError(err, constants.ErrDateFormat)
+}
+
+func (suite *reportUsecaseTestSuite) TestCloseCashier() {
+	filter := reportDto.ClosingFilter{BusinessDate: "2024-03-01", CashierID: "u1"}
+	suite.reportRepo.On("RetrieveCashierSummaries", filter).Return(cashierDay(), nil)
+	suite.reportRepo.On("InsertClosing", reportDto.Closing{
+		CashierID:    "u1",
+		BusinessDate: "2024-03-01",
+		ExpectedCash: 400000,
+		CountedCash:  390000,
+		Difference:   -10000,
+		Note:         "short",
+		ClosedBy:     "a1",
+	}).Return("cl1", nil)
+
+	closing, err := suite.reportUC.CloseCashier(context.Background(), reportDto.ClosingRequest{
+		CashierID: "u1", BusinessDate: "2024-03-01", CountedCash: 390000, Note: " short ", ClosedBy: "a1",
+	})
+
+	suite.Nil(err)
+	suite.Equal(400000, closing.ExpectedCash)
+	suite.Equal("cl1", closing.Closing.ID)
+	suite.Equal(-10000, closing.Closing.Difference)
+}
+
+func (suite *reportUsecaseTestSuite) TestCloseCashierAlreadyClosed() {
+	filter := reportDto.ClosingFilter{BusinessDate: "2024-03-01", CashierID: "u1"}
+	suite.reportRepo.On("RetrieveCashierSummaries", filter).Return([]reportDto.CashierClosing{}, nil)
+	suite.reportRepo.On("InsertClosing", mock.Anything).Return("", errors.New(constants.ErrCashierAlreadyClosed))
+
+	_, err := suite.reportUC.CloseCashier(context.Background(), reportDto.ClosingRequest{CashierID: "u1", BusinessDate: "2024-03-01"})
+
+	suite.EqualError(err, constants.ErrCashierAlreadyClosed)
+}
+
+func (suite *reportUsecaseTestSuite) TestGetRevenue() {
+	filter := reportDto.RevenueFilter{GroupBy: constants.RevenueByDoctor, StartDate: "2024-03-01", EndDate: "2024-03-31"}
+	suite.reportRepo.On("RetrieveRevenue", filter).Return([]reportDto.Revenue{{Key: "d1", Name: "doctor", Records: 2, Amount: 300000}}, nil)
+
+	revenue, err := suite.reportUC.GetRevenue(context.Background(), reportDto.RevenueFilter{GroupBy: "Doctor", StartDate: "2024-03-01", EndDate: "2024-03-31"})
+
+	suite.Nil(err)
+	suite.Len(revenue, 1)
+}
+
+func (suite *reportUsecaseTestSuite) TestGetRevenueDefaultsToCurrentMonth() {
+	now := time.Now()
+	filter := reportDto.RevenueFilter{
+		GroupBy:   constants.RevenueByDay,
+		StartDate: now.Format("2006-01") + "-01",
+		EndDate:   now.Format("2006-01-02"),
+	}
+	suite.reportRepo.On("RetrieveRevenue", filter).Return([]reportDto.Revenue{}, nil)
+
+	_, err := suite.reportUC.GetRevenue(context.Background(), reportDto.RevenueFilter{})
+
+	suite.Nil(err)
+	suite.reportRepo.AssertExpectations(suite.T())
+}
+
+func (suite *reportUsecaseTestSuite) TestGetRevenueInvalidRange() {
+	_, err := suite.reportUC.GetRevenue(context.Background(), reportDto.RevenueFilter{StartDate: "2024-03-31", EndDate: "2024-03-01"})
+
+	suite.EqualError(err, constants.ErrInvalidDateRange)
+}
+
+func (suite *reportUsecaseTestSuite) TestExportCSV() {
+	export, err := suite.reportUC.Export(context.Background(), "revenue", "csv", []reportDto.Revenue{{Key: "d1", Name: "doctor", Records: 2, Quantity: 2, Amount: 300000}})
+
+	suite.Nil(err)
+	suite.Equal("revenue.csv", export.FileName)
+	suite.Equal("text/csv", export.ContentType)
+	suite.Equal("key,name,records,quantity,amount\nd1,doctor,2,2,300000\n", string(export.Content))
+}
+
+func (suite *reportUsecaseTestSuite) TestExportCashierClosingCSV() {
+	closings := cashierDay()
+	closings[0].ExpectedCash = 400000
+	closings[0].Closing = &reportDto.Closing{CountedCash: 390000, Difference: -10000}
+
+	export, err := suite.reportUC.Export(context.Background(), "cashier-closing", "csv", closings)
+
+	suite.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(export.Content)), "\n")
+	suite.Len(lines, 3)
+	suite.Equal("2024-03-01,u1,cashier,CASH,3,450000,1,50000,0,400000,390000,-10000", lines[1])
+}
+
+func (suite *reportUsecaseTestSuite) TestExportXLSX() {
+	export, err := suite.reportUC.Export(context.Background(), "outstanding", "XLSX", []reportDto.Outstanding{{MedicalRecordID: "r1", Amount: 100000, Balance: 100000}})
+
+	suite.Nil(err)
+	suite.Equal("outstanding.xlsx", export.FileName)
+	suite.Equal("PK", string(export.Content[:2]))
+}
+
+func (suite *reportUsecaseTestSuite) TestExportInvalidFormat() {
+	_, err := suite.reportUC.Export(context.Background(), "revenue", "pdf", []reportDto.Revenue{})
+
+	suite.EqualError(err, constants.ErrInvalidReportFormat)
+}
+
+func TestReportUsecase(t *testing.T) {
+	suite.Run(t, new(reportUsecaseTestSuite))
+}
@@ -0,0 +1,62 @@
+package searchDelivery
+
+import (
+	"avengers-clinic/model/dto/json"
+	"avengers-clinic/model/dto/searchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/middleware"
+	"avengers-clinic/pkg/utils"
+	"avengers-clinic/src/search"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type searchDelivery struct {
+	searchUC search.SearchUsecase
+}
+
+func NewSearchDelivery(v1Group *gin.RouterGroup, searchUC search.SearchUsecase) {
+	handler := searchDelivery{searchUC}
+
+	v1Group.GET("/search", middleware.JwtAuth("ADMIN", "DOCTOR", "PATIENT"), handler.Search)
+}
+
+func (delivery *searchDelivery) Search(c *gin.Context) {
+	claims := utils.GetJWT(c)
+	req := searchDto.Request{
+		Query:  c.Query("q"),
+		UserID: claims.ID,
+		Role:   claims.Role,
+	}
+	if types := c.Query("type"); types != "" {
+		req.Types = strings.Split(strings.ToLower(types), ",")
+	}
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if req.Limit, err = strconv.Atoi(limit); err != nil || req.Limit == 0 {
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "limit", Message: constants.ErrInvalidLimit}}, "Bad request", constants.SearchService, "01")
+			return
+		}
+	}
+
+	results, err := delivery.searchUC.Search(c.Request.Context(), req)
+	if err != nil {
+		switch err.Error() {
+		case constants.ErrSearchQueryTooShort:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "q", Message: err.Error()}}, "Bad request", constants.SearchService, "02")
+		case constants.ErrInvalidSearchType:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "type", Message: err.Error()}}, "Bad request", constants.SearchService, "03")
+		case constants.ErrInvalidLimit:
+			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: "limit", Message: err.Error()}}, "Bad request", constants.SearchService, "01")
+		case constants.ErrSearchTypeForbidden:
+			json.NewResponseForbidden(c, err.Error(), constants.SearchService, "01")
+		default:
+			json.NewResponseError(c, err.Error(), constants.SearchService, "01")
+		}
+		return
+	}
+
+	json.NewResponseSuccess(c, results, "Search results retrieved successfully", constants.SearchService, "01")
+}
@@ -0,0 +1,17 @@
+package search
+
+import (
+	"avengers-clinic/model/dto/searchDto"
+	"context"
+)
+
+type SearchRepository interface {
+	SearchUsers(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error)
+	SearchMedicines(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error)
+	SearchActions(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error)
+	SearchDiagnoses(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error)
+}
+
+type SearchUsecase interface {
+	Search(ctx context.Context, req searchDto.Request) (searchDto.Results, error)
+}
@@ -0,0 +1,94 @@
+package searchRepository
+
+import (
+	"avengers-clinic/model/dto/searchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/search"
+	"context"
+	"database/sql"
+)
+
+type searchRepository struct {
+	db *sql.DB
+}
+
+func NewSearchRepository(db *sql.DB) search.SearchRepository {
+	return &searchRepository{db}
+}
+
+// headline marks the matching words of a result and keeps long texts such as
+// diagnoses to a short fragment around them.
+const headline = `'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5'`
+
+func (repository *searchRepository) SearchUsers(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	query := `
+		SELECT u.id, COALESCE(p.full_name, u.username), u.role::text,
+			ts_headline('simple', CONCAT_WS(' ', p.full_name, u.username, p.nik, u.specialization), q, ` + headline + `),
+			GREATEST(ts_rank(u.search_vector || COALESCE(p.search_vector, ''::tsvector), q), word_similarity($2, CONCAT_WS(' ', p.full_name, u.username)))
+		FROM users u
+		LEFT JOIN patient_profiles p ON p.user_id = u.id,
+			to_tsquery('simple', $1) q
+		WHERE u.deleted_at IS NULL AND ($3 = '' OR u.role::text = $3)
+			AND (u.search_vector @@ q OR p.search_vector @@ q OR $2 <% u.username OR $2 <% p.full_name)
+		ORDER BY 5 DESC, 2 LIMIT $4;`
+	return repository.search(ctx, constants.SearchUser, query, criteria.TsQuery, criteria.Term, criteria.Role, criteria.Limit)
+}
+
+func (repository *searchRepository) SearchMedicines(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	query := `
+		SELECT m.id, m.name, m.medicine_type::text,
+			ts_headline('simple', CONCAT_WS(' ', m.name, m.description), q, ` + headline + `),
+			GREATEST(ts_rank(m.search_vector, q), word_similarity($2, m.name))
+		FROM medicines m, to_tsquery('simple', $1) q
+		WHERE m.deleted_at IS NULL AND (m.search_vector @@ q OR $2 <% m.name)
+		ORDER BY 5 DESC, 2 LIMIT $3;`
+	return repository.search(ctx, constants.SearchMedicine, query, criteria.TsQuery, criteria.Term, criteria.Limit)
+}
+
+func (repository *searchRepository) SearchActions(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	query := `
+		SELECT a.id, a.name, '',
+			ts_headline('simple', CONCAT_WS(' ', a.name, a.description), q, ` + headline + `),
+			GREATEST(ts_rank(a.search_vector, q), word_similarity($2, a.name))
+		FROM actions a, to_tsquery('simple', $1) q
+		WHERE a.deleted_at IS NULL AND (a.search_vector @@ q OR $2 <% a.name)
+		ORDER BY 5 DESC, 2 LIMIT $3;`
+	return repository.search(ctx, constants.SearchAction, query, criteria.TsQuery, criteria.Term, criteria.Limit)
+}
+
+// SearchDiagnoses titles each diagnosis with the patient it was made for and
+// details it with the day of the medical record.
+func (repository *searchRepository) SearchDiagnoses(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	query := `
+		SELECT mr.id, COALESCE(p.full_name, u.username), TO_CHAR(mr.created_at, 'YYYY-MM-DD'),
+			ts_headline('simple', mr.diagnosis_results, q, ` + headline + `),
+			GREATEST(ts_rank(mr.search_vector, q), word_similarity($2, mr.diagnosis_results))
+		FROM medical_records mr
+		JOIN bookings b ON b.id = mr.booking_id
+		JOIN doctor_schedules ds ON ds.id = b.doctor_schedule_id
+		JOIN users u ON u.id = b.patient_id
+		LEFT JOIN patient_profiles p ON p.user_id = u.id,
+			to_tsquery('simple', $1) q
+		WHERE mr.deleted_at IS NULL AND ($3 = '' OR b.patient_id::text = $3) AND ($4 = '' OR ds.doctor_id::text = $4)
+			AND (mr.search_vector @@ q OR $2 <% mr.diagnosis_results)
+		ORDER BY 5 DESC, mr.created_at DESC LIMIT $5;`
+	return repository.search(ctx, constants.SearchDiagnosis, query, criteria.TsQuery, criteria.Term, criteria.PatientID, criteria.DoctorID, criteria.Limit)
+}
+
+func (repository *searchRepository) search(ctx context.Context, resultType, query string, args ...interface{}) ([]searchDto.Result, error) {
+	rows, err := repository.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []searchDto.Result
+	for rows.Next() {
+		result := searchDto.Result{Type: resultType}
+		if err := rows.Scan(&result.ID, &result.Title, &result.Detail, &result.Highlight, &result.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,80 @@
+package searchRepository
+
+import (
+	"avengers-clinic/model/dto/searchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/search"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type searchRepositoryTestSuite struct {
+	suite.Suite
+	searchRepo search.SearchRepository
+	mock       sqlmock.Sqlmock
+}
+
+func (suite *searchRepositoryTestSuite) SetupTest() {
+	db, mock, _ := sqlmock.New()
+
+	suite.searchRepo = NewSearchRepository(db)
+	suite.mock = mock
+}
+
+var columns = []string{"id", "title", "detail", "highlight", "rank"}
+
+func (suite *searchRepositoryTestSuite) TestSearchUsersOfRole() {
+	rows := sqlmock.NewRows(columns).AddRow("p1", "Budi Santoso", "PATIENT", "<mark>Budi</mark> Santoso budi", 0.75)
+	suite.mock.ExpectQuery(`FROM users u\s+LEFT JOIN patient_profiles p`).
+		WithArgs("budi:*", "budi", "PATIENT", 10).
+		WillReturnRows(rows)
+
+	results, err := suite.searchRepo.SearchUsers(context.Background(), searchDto.Criteria{TsQuery: "budi:*", Term: "budi", Role: "PATIENT", Limit: 10})
+
+	suite.Nil(err)
+	suite.Equal([]searchDto.Result{{Type: constants.SearchUser, ID: "p1", Title: "Budi Santoso", Detail: "PATIENT", Highlight: "<mark>Budi</mark> Santoso budi", Rank: 0.75}}, results)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *searchRepositoryTestSuite) TestSearchMedicines() {
+	rows := sqlmock.NewRows(columns).AddRow("m1", "Paracetamol 500mg", "TABLET", "<mark>Paracetamol</mark> <mark>500mg</mark>", 0.6)
+	suite.mock.ExpectQuery(`FROM medicines m, to_tsquery\('simple', \$1\) q`).
+		WithArgs("paracetamol:* & 500:*", "paracetamol 500", 20).
+		WillReturnRows(rows)
+
+	results, err := suite.searchRepo.SearchMedicines(context.Background(), searchDto.Criteria{TsQuery: "paracetamol:* & 500:*", Term: "paracetamol 500", Limit: 20})
+
+	suite.Nil(err)
+	suite.Len(results, 1)
+	suite.Equal(constants.SearchMedicine, results[0].Type)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *searchRepositoryTestSuite) TestSearchActionsError() {
+	suite.mock.ExpectQuery(`FROM actions a`).WillReturnError(errors.New("connection lost"))
+
+	_, err := suite.searchRepo.SearchActions(context.Background(), searchDto.Criteria{TsQuery: "cabut:*", Term: "cabut", Limit: 20})
+
+	suite.EqualError(err, "connection lost")
+}
+
+func (suite *searchRepositoryTestSuite) TestSearchDiagnosesOfPatient() {
+	rows := sqlmock.NewRows(columns).AddRow("r1", "Budi Santoso", "2024-03-13", "demam <mark>flu</mark>", 0.3)
+	suite.mock.ExpectQuery(`FROM medical_records mr`).
+		WithArgs("flu:*", "flu", "p1", "", 20).
+		WillReturnRows(rows)
+
+	results, err := suite.searchRepo.SearchDiagnoses(context.Background(), searchDto.Criteria{TsQuery: "flu:*", Term: "flu", PatientID: "p1", Limit: 20})
+
+	suite.Nil(err)
+	suite.Equal("2024-03-13", results[0].Detail)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func TestSearchRepository(t *testing.T) {
+	suite.Run(t, new(searchRepositoryTestSuite))
+}
@@ -0,0 +1,146 @@
+package searchUsecase
+
+import (
+	"avengers-clinic/model/dto/searchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/search"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	minLength    = 2
+)
+
+// searchable is what each role may search, in the order results of the same
+// rank are listed. Patients only find their own diagnoses and doctors only
+// find patients and the diagnoses they made.
+var searchable = map[string][]string{
+	"ADMIN":   {constants.SearchUser, constants.SearchMedicine, constants.SearchAction, constants.SearchDiagnosis},
+	"DOCTOR":  {constants.SearchUser, constants.SearchMedicine, constants.SearchAction, constants.SearchDiagnosis},
+	"PATIENT": {constants.SearchMedicine, constants.SearchAction, constants.SearchDiagnosis},
+}
+
+type searchUsecase struct {
+	searchRepo search.SearchRepository
+}
+
+func NewSearchUsecase(searchRepo search.SearchRepository) search.SearchUsecase {
+	return &searchUsecase{searchRepo}
+}
+
+// Search looks the query up in every type the caller may search, or in the
+// requested ones, and ranks the matches of all types together.
+func (usecase *searchUsecase) Search(ctx context.Context, req searchDto.Request) (searchDto.Results, error) {
+	criteria, err := newCriteria(req)
+	if err != nil {
+		return searchDto.Results{}, err
+	}
+
+	types, err := searchTypes(req)
+	if err != nil {
+		return searchDto.Results{}, err
+	}
+
+	results := []searchDto.Result{}
+	for _, searchType := range types {
+		var found []searchDto.Result
+		switch searchType {
+		case constants.SearchUser:
+			found, err = usecase.searchRepo.SearchUsers(ctx, criteria)
+		case constants.SearchMedicine:
+			found, err = usecase.searchRepo.SearchMedicines(ctx, criteria)
+		case constants.SearchAction:
+			found, err = usecase.searchRepo.SearchActions(ctx, criteria)
+		case constants.SearchDiagnosis:
+			found, err = usecase.searchRepo.SearchDiagnoses(ctx, criteria)
+		}
+		if err != nil {
+			return searchDto.Results{}, err
+		}
+		results = append(results, found...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > criteria.Limit {
+		results = results[:criteria.Limit]
+	}
+	return searchDto.Results{Query: criteria.Term, Total: len(results), Results: results}, nil
+}
+
+// newCriteria turns the words of the query into a prefix match of all of them,
+// so "paracetamol 500" finds "Paracetamol 500mg", and restricts the search to
+// what the role of the caller may see.
+func newCriteria(req searchDto.Request) (searchDto.Criteria, error) {
+	criteria := searchDto.Criteria{Term: strings.TrimSpace(req.Query), Limit: req.Limit}
+
+	words := strings.FieldsFunc(strings.ToLower(criteria.Term), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len([]rune(strings.Join(words, ""))) < minLength {
+		return searchDto.Criteria{}, errors.New(constants.ErrSearchQueryTooShort)
+	}
+	for i := range words {
+		words[i] += ":*"
+	}
+	criteria.TsQuery = strings.Join(words, " & ")
+
+	if criteria.Limit == 0 {
+		criteria.Limit = defaultLimit
+	}
+	if criteria.Limit < 0 || criteria.Limit > maxLimit {
+		return searchDto.Criteria{}, errors.New(constants.ErrInvalidLimit)
+	}
+
+	switch req.Role {
+	case "DOCTOR":
+		criteria.Role = "PATIENT"
+		criteria.DoctorID = req.UserID
+	case "PATIENT":
+		criteria.PatientID = req.UserID
+	}
+	return criteria, nil
+}
+
+// searchTypes keeps the types the caller may search to the requested ones.
+func searchTypes(req searchDto.Request) ([]string, error) {
+	allowed := searchable[req.Role]
+	if len(req.Types) == 0 {
+		return allowed, nil
+	}
+
+	requested := map[string]bool{}
+	for _, searchType := range req.Types {
+		if !contains(searchable["ADMIN"], searchType) {
+			return nil, errors.New(constants.ErrInvalidSearchType)
+		}
+		if !contains(allowed, searchType) {
+			return nil, errors.New(constants.ErrSearchTypeForbidden)
+		}
+		requested[searchType] = true
+	}
+
+	var types []string
+	for _, searchType := range allowed {
+		if requested[searchType] {
+			types = append(types, searchType)
+		}
+	}
+	return types, nil
+}
+
+func contains(types []string, searchType string) bool {
+	for _, known := range types {
+		if known == searchType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package searchUsecase
+
+import (
+	"avengers-clinic/model/dto/searchDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/src/search"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockSearchRepository struct {
+	mock.Mock
+}
+
+func (m *mockSearchRepository) SearchUsers(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	args := m.Called(criteria)
+	return args.Get(0).([]searchDto.Result), args.Error(1)
+}
+
+func (m *mockSearchRepository) SearchMedicines(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	args := m.Called(criteria)
+	return args.Get(0).([]searchDto.Result), args.Error(1)
+}
+
+func (m *mockSearchRepository) SearchActions(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	args := m.Called(criteria)
+	return args.Get(0).([]searchDto.Result), args.Error(1)
+}
+
+func (m *mockSearchRepository) SearchDiagnoses(ctx context.Context, criteria searchDto.Criteria) ([]searchDto.Result, error) {
+	args := m.Called(criteria)
+	return args.Get(0).([]searchDto.Result), args.Error(1)
+}
+
+type searchUsecaseTestSuite struct {
+	suite.Suite
+	searchRepo *mockSearchRepository
+	searchUC   search.SearchUsecase
+}
+
+func (suite *searchUsecaseTestSuite) SetupTest() {
+	suite.searchRepo = new(mockSearchRepository)
+	suite.searchUC = NewSearchUsecase(suite.searchRepo)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchRanksAllTypes() {
+	criteria := searchDto.Criteria{TsQuery: "paracetamol:* & 500:*", Term: "Paracetamol 500", Limit: defaultLimit}
+	suite.searchRepo.On("SearchUsers", criteria).Return([]searchDto.Result{}, nil)
+	suite.searchRepo.On("SearchMedicines", criteria).Return([]searchDto.Result{
+		{Type: constants.SearchMedicine, ID: "m1", Title: "Paracetamol 500mg", Rank: 0.6},
+	}, nil)
+	suite.searchRepo.On("SearchActions", criteria).Return([]searchDto.Result{}, nil)
+	suite.searchRepo.On("SearchDiagnoses", criteria).Return([]searchDto.Result{
+		{Type: constants.SearchDiagnosis, ID: "r1", Title: "Budi", Rank: 0.1},
+		{Type: constants.SearchDiagnosis, ID: "r2", Title: "Sari", Rank: 0.9},
+	}, nil)
+
+	results, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "  Paracetamol 500 ", UserID: "a1", Role: "ADMIN"})
+
+	suite.Nil(err)
+	suite.Equal("Paracetamol 500", results.Query)
+	suite.Equal(3, results.Total)
+	suite.Equal("r2", results.Results[0].ID)
+	suite.Equal("m1", results.Results[1].ID)
+	suite.Equal("r1", results.Results[2].ID)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchAsDoctorFindsOnlyPatients() {
+	criteria := searchDto.Criteria{TsQuery: "budi:*", Term: "budi", Limit: 5, Role: "PATIENT", DoctorID: "d1"}
+	suite.searchRepo.On("SearchUsers", criteria).Return([]searchDto.Result{{Type: constants.SearchUser, ID: "p1", Rank: 1}}, nil)
+
+	results, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "budi", Types: []string{constants.SearchUser}, Limit: 5, UserID: "d1", Role: "DOCTOR"})
+
+	suite.Nil(err)
+	suite.Equal(1, results.Total)
+	suite.searchRepo.AssertNotCalled(suite.T(), "SearchMedicines", mock.Anything)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchAsPatientSkipsUsers() {
+	criteria := searchDto.Criteria{TsQuery: "flu:*", Term: "flu", Limit: defaultLimit, PatientID: "p1"}
+	suite.searchRepo.On("SearchMedicines", criteria).Return([]searchDto.Result{}, nil)
+	suite.searchRepo.On("SearchActions", criteria).Return([]searchDto.Result{}, nil)
+	suite.searchRepo.On("SearchDiagnoses", criteria).Return([]searchDto.Result{}, nil)
+
+	results, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "flu", UserID: "p1", Role: "PATIENT"})
+
+	suite.Nil(err)
+	suite.Equal(0, results.Total)
+	suite.NotNil(results.Results)
+	suite.searchRepo.AssertNotCalled(suite.T(), "SearchUsers", mock.Anything)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchCutsToLimit() {
+	criteria := searchDto.Criteria{TsQuery: "obat:*", Term: "obat", Limit: 1}
+	suite.searchRepo.On("SearchMedicines", criteria).Return([]searchDto.Result{{ID: "m1", Rank: 0.2}}, nil)
+	suite.searchRepo.On("SearchActions", criteria).Return([]searchDto.Result{{ID: "a1", Rank: 0.5}}, nil)
+
+	results, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "obat", Types: []string{constants.SearchAction, constants.SearchMedicine}, Limit: 1, Role: "ADMIN"})
+
+	suite.Nil(err)
+	suite.Equal([]searchDto.Result{{ID: "a1", Rank: 0.5}}, results.Results)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchQueryTooShort() {
+	_, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: " b% ", Role: "ADMIN"})
+
+	suite.EqualError(err, constants.ErrSearchQueryTooShort)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchInvalidLimit() {
+	_, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "budi", Limit: 500, Role: "ADMIN"})
+
+	suite.EqualError(err, constants.ErrInvalidLimit)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchInvalidType() {
+	_, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "budi", Types: []string{"invoice"}, Role: "ADMIN"})
+
+	suite.EqualError(err, constants.ErrInvalidSearchType)
+}
+
+func (suite *searchUsecaseTestSuite) TestSearchTypeForbidden() {
+	_, err := suite.searchUC.Search(context.Background(), searchDto.Request{Query: "budi", Types: []string{constants.SearchUser}, Role: "PATIENT"})
+
+	suite.EqualError(err, constants.ErrSearchTypeForbidden)
+}
+
+func TestSearchUsecase(t *testing.T) {
+	suite.Run(t, new(searchUsecaseTestSuite))
+}
@@ -36,7 +36,7 @@ func NewUserDelivery(v1Group *gin.RouterGroup, userUC user.UserUsecase) {
 }
 
 func (delivery *userDelivery) GetAllTrash(c *gin.Context) {
-	users, err := delivery.userUC.GetAllTrash()
+	users, err := delivery.userUC.GetAllTrash(c.Request.Context())
 	if err != nil {
 		json.NewResponseError(c, err.Error(), constants.UserService, "01")
 		return
@@ -51,7 +51,13 @@ func (delivery *userDelivery) GetAllTrash(c *gin.Context) {
 }
 
 func (delivery *userDelivery) GetAll(c *gin.Context) {
-	users, err := delivery.userUC.GetAll()
+	query, invalid := utils.ParseQuery(c, user.ListResource)
+	if len(invalid) > 0 {
+		json.NewResponseBadRequest(c, invalid, "Bad request", constants.UserService, "02")
+		return
+	}
+
+	users, paging, err := delivery.userUC.GetAll(c.Request.Context(), query)
 	if err != nil {
 		json.NewResponseError(c, err.Error(), constants.UserService, "01")
 		return
@@ -62,12 +68,12 @@ func (delivery *userDelivery) GetAll(c *gin.Context) {
 		return
 	}
 
-	json.NewResponseSuccess(c, users, "Users retrieved successfully", constants.UserService, "01")
+	json.NewResponsePaging(c, users, paging, "Users retrieved successfully", constants.UserService, "01")
 }
 
 func (delivery *userDelivery) GetByID(c *gin.Context) {
 	userID := c.Param("id")
-	user, err := delivery.userUC.GetByID(userID)
+	user, err := delivery.userUC.GetByID(c.Request.Context(), userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "User not found", constants.UserService, "01")
@@ -78,6 +84,7 @@ func (delivery *userDelivery) GetByID(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, user.Version)
 	json.NewResponseSuccess(c, user, "User retrieved successfully", constants.UserService, "01")
 }
 
@@ -93,7 +100,7 @@ func (delivery *userDelivery) PatientRegister(c *gin.Context) {
 		return
 	}
 
-	user, err := delivery.userUC.PatientRegister(request)
+	user, err := delivery.userUC.PatientRegister(c.Request.Context(), request)
 	if err != nil {
 		if err.Error() == "1" {
 			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName:"username", Message:"Username is already registered"}}, "Bad request", constants.UserService, "03")
@@ -119,7 +126,7 @@ func (delivery *userDelivery) UserRegister(c *gin.Context) {
 		return
 	}
 
-	user, err := delivery.userUC.UserRegister(request)
+	user, err := delivery.userUC.UserRegister(c.Request.Context(), request)
 	if err != nil {
 		if err.Error() == "1" {
 			json.NewResponseBadRequest(c, []json.ValidationField{{FieldName:"username", Message:"Username is already registered"}}, "Bad request", constants.UserService, "03")
@@ -150,7 +157,7 @@ func (delivery *userDelivery) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := delivery.userUC.Login(request)
+	response, err := delivery.userUC.Login(c.Request.Context(), request)
 	if err != nil {
 		if err.Error() == "1" {
 			json.NewResponseError(c, "Incorrect username or password", constants.UserService, "03")
@@ -172,7 +179,12 @@ func (delivery *userDelivery) Update(c *gin.Context) {
 	}
 	request.ID = c.Param("id")
 
-	response, err := delivery.userUC.Update(request)
+	var ok bool
+	if request.Version, ok = delivery.ifMatch(c); !ok {
+		return
+	}
+
+	response, err := delivery.userUC.Update(c.Request.Context(), request)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "User not found", constants.UserService, "03")
@@ -184,10 +196,16 @@ func (delivery *userDelivery) Update(c *gin.Context) {
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.UserService, "08")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.UserService, "05")
 		return
 	}
 
+	utils.SetETag(c, response.Version)
 	json.NewResponseSuccess(c, response, "User updeted successfully", constants.UserService, "01")
 }
 
@@ -204,7 +222,7 @@ func (delivery *userDelivery) UpdatePassword(c *gin.Context) {
 	}
 	request.ID = c.Param("id")
 
-	err := delivery.userUC.UpdatePassword(request)
+	err := delivery.userUC.UpdatePassword(c.Request.Context(), request)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "User not found", constants.UserService, "03")
@@ -230,13 +248,22 @@ func (delivery *userDelivery) UpdatePassword(c *gin.Context) {
 
 func (delivery *userDelivery) Delete(c *gin.Context) {
 	userID := c.Param("id")
-	err := delivery.userUC.Delete(userID)
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	err := delivery.userUC.Delete(c.Request.Context(), userID, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "User not found", constants.UserService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.UserService, "08")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.UserService, "02")
 		return
 	}
@@ -246,32 +273,67 @@ func (delivery *userDelivery) Delete(c *gin.Context) {
 
 func (delivery *userDelivery) SoftDelete(c *gin.Context) {
 	userID := c.Param("id")
-	err := delivery.userUC.SoftDelete(userID)
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	version, err := delivery.userUC.SoftDelete(c.Request.Context(), userID, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "User not found", constants.UserService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.UserService, "08")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.UserService, "02")
 		return
 	}
 
+	utils.SetETag(c, version)
 	json.NewResponseSuccess(c, nil, "User deleted successfully", constants.UserService, "01")
 }
 
 func (delivery *userDelivery) Restore(c *gin.Context) {
 	userID := c.Param("id")
-	err := delivery.userUC.Restore(userID)
+	version, ok := delivery.ifMatch(c)
+	if !ok {
+		return
+	}
+	err := delivery.userUC.Restore(c.Request.Context(), userID, version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			json.NewResponseForbidden(c, "User not found", constants.UserService, "01")
 			return
 		}
 
+		if err.Error() == constants.ErrVersionConflict {
+			json.NewResponsePreconditionFailed(c, err.Error(), constants.UserService, "08")
+			return
+		}
+
 		json.NewResponseError(c, err.Error(), constants.UserService, "02")
 		return
 	}
 
 	json.NewResponseSuccess(c, nil, "User restored successfully", constants.UserService, "01")
+}
+
+// ifMatch reads the version the change is made against from the If-Match
+// header, answering the request itself when the header is missing or invalid.
+func (delivery *userDelivery) ifMatch(c *gin.Context) (int, bool) {
+	version, err := utils.IfMatch(c)
+	if err == nil {
+		return version, true
+	}
+
+	if err.Error() == constants.ErrIfMatchRequired {
+		json.NewResponsePreconditionRequired(c, err.Error(), constants.UserService, "07")
+		return 0, false
+	}
+	json.NewResponseBadRequest(c, []json.ValidationField{{FieldName: constants.IfMatchHeader, Message: err.Error()}}, "Bad request", constants.UserService, "07")
+	return 0, false
 }
\ No newline at end of file
@@ -1,9 +1,13 @@
 package userDelivery
 
 import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/dto/userDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -20,61 +24,73 @@ type mockUserUsecase struct {
 	mock.Mock
 }
 
-func (mock *mockUserUsecase)GetAllTrash() ([]userDto.User, error) {
+func (mock *mockUserUsecase)GetAllTrash(ctx context.Context) ([]userDto.User, error) {
 	args := mock.Called()
 	return args.Get(0).([]userDto.User), args.Error(1)
 }
 
-func (mock *mockUserUsecase)GetAll() ([]userDto.User, error) {
-	args := mock.Called()
-	return args.Get(0).([]userDto.User), args.Error(1)
+func (mock *mockUserUsecase)GetAll(ctx context.Context, query queryDto.Query) ([]userDto.User, queryDto.Paging, error) {
+	args := mock.Called(query)
+	return args.Get(0).([]userDto.User), args.Get(1).(queryDto.Paging), args.Error(2)
 }
 
-func (mock *mockUserUsecase)GetByID(userID string) (userDto.User, error) {
+func (mock *mockUserUsecase)GetByID(ctx context.Context, userID string) (userDto.User, error) {
 	args := mock.Called(userID)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserUsecase)PatientRegister(req userDto.AuthRequest) (userDto.User, error) {
+func (mock *mockUserUsecase)PatientRegister(ctx context.Context, req userDto.AuthRequest) (userDto.User, error) {
 	args := mock.Called(req)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserUsecase)UserRegister(req userDto.RegisterRequest) (userDto.User, error) {
+func (mock *mockUserUsecase)UserRegister(ctx context.Context, req userDto.RegisterRequest) (userDto.User, error) {
 	args := mock.Called(req)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserUsecase)Login(req userDto.AuthRequest) (string, error) {
+func (mock *mockUserUsecase)Login(ctx context.Context, req userDto.AuthRequest) (string, error) {
 	args := mock.Called(req)
 	return args.String(0), args.Error(1)
 }
 
-func (mock *mockUserUsecase)Update(req userDto.UpdateRequest) (userDto.User, error) {
+func (mock *mockUserUsecase)Update(ctx context.Context, req userDto.UpdateRequest) (userDto.User, error) {
 	args := mock.Called(req)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserUsecase)UpdatePassword(req userDto.UpdatePasswordRequest) error {
+func (mock *mockUserUsecase)UpdatePassword(ctx context.Context, req userDto.UpdatePasswordRequest) error {
 	args := mock.Called(req)
 	return args.Error(0)
 }
 
-func (mock *mockUserUsecase)Delete(userID string) error {
-	args := mock.Called(userID)
+func (mock *mockUserUsecase)Delete(ctx context.Context, userID string, version int) error {
+	args := mock.Called(userID, version)
 	return args.Error(0)
 }
 
-func (mock *mockUserUsecase)SoftDelete(userID string) error {
-	args := mock.Called(userID)
+func (mock *mockUserUsecase)SoftDelete(ctx context.Context, userID string, version int) (int, error) {
+	args := mock.Called(userID, version)
+	return args.Int(0), args.Error(1)
+}
+
+func (mock *mockUserUsecase)Restore(ctx context.Context, userID string, version int) error {
+	args := mock.Called(userID, version)
 	return args.Error(0)
 }
 
-func (mock *mockUserUsecase)Restore(userID string) error {
-	args := mock.Called(userID)
+var defaultQuery = queryDto.Query{Page: 1, Size: 10, Sort: []queryDto.Sort{{Field: "created_at", Desc: true}}}
+
+func (mock *mockUserUsecase) ResetPassword(ctx context.Context, username, newPassword string) error {
+	args := mock.Called(username, newPassword)
 	return args.Error(0)
 }
 
+func (mock *mockUserUsecase) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	args := mock.Called(retentionDays)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
 type userDeliveryTestSuite struct {
 	suite.Suite
 	router *gin.Engine
@@ -161,7 +177,7 @@ func (suite *userDeliveryTestSuite) TestGetAllSuccess() {
 			Role: "ADMIN",
 		},
 	}
-	suite.userUC.On("GetAll").Return(users, nil)
+	suite.userUC.On("GetAll", defaultQuery).Return(users, queryDto.Paging{Page: 1, Size: 10, TotalRows: 1, TotalPages: 1}, nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users", nil)
@@ -170,7 +186,7 @@ func (suite *userDeliveryTestSuite) TestGetAllSuccess() {
 	req.Header.Set("Authorization", "Bearer "+token)
 	suite.router.ServeHTTP(res, req)
 
-	expectedResponse := `{"responseCode":"2000101","responseMessage":"Users retrieved successfully","data":[{"id":"9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5","username":"admin","role":"ADMIN"}]}`
+	expectedResponse := `{"responseCode":"2000101","responseMessage":"Users retrieved successfully","data":[{"id":"9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5","username":"admin","role":"ADMIN"}],"paging":{"page":1,"size":10,"total_rows":1,"total_pages":1}}`
 	
 	suite.Equal(http.StatusOK, res.Code)
 	suite.JSONEq(expectedResponse, res.Body.String())
@@ -178,7 +194,7 @@ func (suite *userDeliveryTestSuite) TestGetAllSuccess() {
 
 func (suite *userDeliveryTestSuite) TestGetAllErrorUserNotFound() {
 	users := []userDto.User{}
-	suite.userUC.On("GetAll").Return(users, nil)
+	suite.userUC.On("GetAll", defaultQuery).Return(users, queryDto.Paging{}, nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users", nil)
@@ -195,7 +211,7 @@ func (suite *userDeliveryTestSuite) TestGetAllErrorUserNotFound() {
 
 func (suite *userDeliveryTestSuite) TestGetAllInternalServerError() {
 	users := []userDto.User{}
-	suite.userUC.On("GetAll").Return(users, sql.ErrConnDone)
+	suite.userUC.On("GetAll", defaultQuery).Return(users, queryDto.Paging{}, sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users", nil)
@@ -209,6 +225,42 @@ func (suite *userDeliveryTestSuite) TestGetAllInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expectedResponse, res.Body.String())
 }
+func (suite *userDeliveryTestSuite) TestGetAllQuery() {
+	query := queryDto.Query{
+		Page:    2,
+		Size:    5,
+		Sort:    []queryDto.Sort{{Field: "username", Desc: true}},
+		Filters: []queryDto.Filter{{Field: "role", Value: "DOCTOR"}},
+	}
+	suite.userUC.On("GetAll", query).Return([]userDto.User{{ID: "1", Username: "joko", Role: "DOCTOR"}}, queryDto.Paging{Page: 2, Size: 5, TotalRows: 6, TotalPages: 2}, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users?page=2&size=5&sort=-username&role=DOCTOR&password=x", nil)
+
+	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expectedResponse := `{"responseCode":"2000101","responseMessage":"Users retrieved successfully","data":[{"id":"1","username":"joko","role":"DOCTOR"}],"paging":{"page":2,"size":5,"total_rows":6,"total_pages":2}}`
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.JSONEq(expectedResponse, res.Body.String())
+}
+
+func (suite *userDeliveryTestSuite) TestGetAllInvalidQuery() {
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users?page=0&size=500&sort=password", nil)
+
+	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expectedResponse := `{"responseCode":"4000102","responseMessage":"Bad request","error_description":[{"field":"page","message":"the page must be a positive number"},{"field":"size","message":"the size must be a number between 1 and 100"},{"field":"sort","message":"the list cannot be sorted by that field: password"}]}`
+
+	suite.Equal(http.StatusBadRequest, res.Code)
+	suite.JSONEq(expectedResponse, res.Body.String())
+	suite.userUC.AssertNotCalled(suite.T(), "GetAll", mock.Anything)
+}
 // End Get All
 
 // Start Get By ID
@@ -267,6 +319,21 @@ func (suite *userDeliveryTestSuite) TestGetByIDInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expectedResponse, res.Body.String())
 }
+
+func (suite *userDeliveryTestSuite) TestGetByIDSetsETag() {
+	user := userDto.User{ID: "9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", Username: "admin", Role: "ADMIN", Version: 3}
+	suite.userUC.On("GetByID", mock.Anything).Return(user, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", nil)
+
+	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	suite.Equal(http.StatusOK, res.Code)
+	suite.Equal(`"3"`, res.Header().Get("ETag"))
+}
 // End Get By ID
 
 // Start Patient Register
@@ -550,6 +617,7 @@ func (suite *userDeliveryTestSuite) TestUpdateSuccess() {
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+ token)
@@ -566,6 +634,7 @@ func (suite *userDeliveryTestSuite) TestUpdateErrorJSON() {
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+ token)
@@ -585,6 +654,7 @@ func (suite *userDeliveryTestSuite) TestUpdateErrorUserNotFound() {
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+ token)
@@ -604,6 +674,7 @@ func (suite *userDeliveryTestSuite) TestUpdateErrorUsernameExists() {
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+ token)
@@ -623,6 +694,7 @@ func (suite *userDeliveryTestSuite) TestUpdateInternalServerError() {
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+ token)
@@ -633,6 +705,42 @@ func (suite *userDeliveryTestSuite) TestUpdateInternalServerError() {
 	suite.Equal(http.StatusInternalServerError, res.Code)
 	suite.JSONEq(expectedResponse, res.Body.String())
 }
+
+func (suite *userDeliveryTestSuite) TestUpdateWithoutIfMatch() {
+	requestBody := []byte(`{"username":"user"}`)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+
+	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expectedResponse := `{"responseCode":"4280107","responseMessage":"` + constants.ErrIfMatchRequired + `"}`
+
+	suite.Equal(http.StatusPreconditionRequired, res.Code)
+	suite.JSONEq(expectedResponse, res.Body.String())
+	suite.userUC.AssertNotCalled(suite.T(), "Update", mock.Anything)
+}
+
+func (suite *userDeliveryTestSuite) TestUpdateVersionConflict() {
+	requestBody := []byte(`{"username":"user"}`)
+
+	suite.userUC.On("Update", userDto.UpdateRequest{ID: "9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", Username: "user", Version: 2}).Return(userDto.User{}, errors.New(constants.ErrVersionConflict))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", bytes.NewBuffer(requestBody))
+	req.Header.Set("If-Match", `"2"`)
+
+	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
+	req.Header.Set("Authorization", "Bearer "+token)
+	suite.router.ServeHTTP(res, req)
+
+	expectedResponse := `{"responseCode":"4120108","responseMessage":"` + constants.ErrVersionConflict + `"}`
+
+	suite.Equal(http.StatusPreconditionFailed, res.Code)
+	suite.JSONEq(expectedResponse, res.Body.String())
+}
 // End Update
 
 // Start Update Password
@@ -761,10 +869,11 @@ func (suite *userDeliveryTestSuite) TestUpdatePasswordInternalServerError() {
 
 // Start Delete
 func (suite *userDeliveryTestSuite) TestDeleteSuccess() {
-	suite.userUC.On("Delete", mock.Anything).Return(nil)
+	suite.userUC.On("Delete", mock.Anything, mock.Anything).Return(nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -777,10 +886,11 @@ func (suite *userDeliveryTestSuite) TestDeleteSuccess() {
 }
 
 func (suite *userDeliveryTestSuite) TestDeleteErrorUserNotFound() {
-	suite.userUC.On("Delete", mock.Anything).Return(sql.ErrNoRows)
+	suite.userUC.On("Delete", mock.Anything, mock.Anything).Return(sql.ErrNoRows)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -793,10 +903,11 @@ func (suite *userDeliveryTestSuite) TestDeleteErrorUserNotFound() {
 }
 
 func (suite *userDeliveryTestSuite) TestDeleteInternalServerError() {
-	suite.userUC.On("Delete", mock.Anything).Return(sql.ErrConnDone)
+	suite.userUC.On("Delete", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -811,10 +922,11 @@ func (suite *userDeliveryTestSuite) TestDeleteInternalServerError() {
 
 // Start Soft Delete
 func (suite *userDeliveryTestSuite) TestSoftDeleteSuccess() {
-	suite.userUC.On("SoftDelete", mock.Anything).Return(nil)
+	suite.userUC.On("SoftDelete", mock.Anything, mock.Anything).Return(2, nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5/trash", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -827,10 +939,11 @@ func (suite *userDeliveryTestSuite) TestSoftDeleteSuccess() {
 }
 
 func (suite *userDeliveryTestSuite) TestSoftDeleteErrorUserNotFound() {
-	suite.userUC.On("SoftDelete", mock.Anything).Return(sql.ErrNoRows)
+	suite.userUC.On("SoftDelete", mock.Anything, mock.Anything).Return(0, sql.ErrNoRows)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5/trash", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -843,10 +956,11 @@ func (suite *userDeliveryTestSuite) TestSoftDeleteErrorUserNotFound() {
 }
 
 func (suite *userDeliveryTestSuite) TestSoftDeleteInternalServerError() {
-	suite.userUC.On("SoftDelete", mock.Anything).Return(sql.ErrConnDone)
+	suite.userUC.On("SoftDelete", mock.Anything, mock.Anything).Return(0, sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodDelete, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5/trash", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -861,10 +975,11 @@ func (suite *userDeliveryTestSuite) TestSoftDeleteInternalServerError() {
 
 // Start Restore
 func (suite *userDeliveryTestSuite) TestRestoreSuccess() {
-	suite.userUC.On("Restore", mock.Anything).Return(nil)
+	suite.userUC.On("Restore", mock.Anything, mock.Anything).Return(nil)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -877,10 +992,11 @@ func (suite *userDeliveryTestSuite) TestRestoreSuccess() {
 }
 
 func (suite *userDeliveryTestSuite) TestRestoreErrorUserNotFound() {
-	suite.userUC.On("Restore", mock.Anything).Return(sql.ErrNoRows)
+	suite.userUC.On("Restore", mock.Anything, mock.Anything).Return(sql.ErrNoRows)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -893,10 +1009,11 @@ func (suite *userDeliveryTestSuite) TestRestoreErrorUserNotFound() {
 }
 
 func (suite *userDeliveryTestSuite) TestRestoreInternalServerError() {
-	suite.userUC.On("Restore", mock.Anything).Return(sql.ErrConnDone)
+	suite.userUC.On("Restore", mock.Anything, mock.Anything).Return(sql.ErrConnDone)
 
 	res := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPut, "/api/v1/users/9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5/restore", nil)
+	req.Header.Set("If-Match", `"1"`)
 
 	token, _ := utils.GenerateJWT("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", "admin", "ADMIN")
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -1,33 +1,41 @@
 package user
 
-import "avengers-clinic/model/dto/userDto"
+import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
+	"avengers-clinic/model/dto/userDto"
+	"context"
+)
 
 type UserRepository interface {
-	GetAllTrash() ([]userDto.User, error)
-	GetAll() ([]userDto.User, error)
-	GetByID(userID string) (userDto.User, error)
-	GetUserByID(userID string) (userDto.User, error)
-	GetTrashByID(userID string) (userDto.User, error)
-	GetByUsername(username string) (userDto.User, error)
-	Insert(user userDto.User) (string, error)
-	Update(user userDto.User) error
-	UpdatePassword(userId, hashPassword string) error
-	Delete(userID string) error
-	SoftDelete(userID string) error
-	Restore(userID string) error
-	IsUsernameExists(username string) bool
+	GetAllTrash(ctx context.Context) ([]userDto.User, error)
+	GetAll(ctx context.Context, query queryDto.Query) ([]userDto.User, int, error)
+	GetByID(ctx context.Context, userID string) (userDto.User, error)
+	GetUserByID(ctx context.Context, userID string) (userDto.User, error)
+	GetTrashByID(ctx context.Context, userID string) (userDto.User, error)
+	GetByUsername(ctx context.Context, username string) (userDto.User, error)
+	Insert(ctx context.Context, user userDto.User) (string, error)
+	Update(ctx context.Context, user userDto.User) (int, error)
+	UpdatePassword(ctx context.Context, userId, hashPassword string) error
+	Delete(ctx context.Context, userID string, version int) error
+	SoftDelete(ctx context.Context, userID string, version int) error
+	Restore(ctx context.Context, userID string, version int) error
+	IsUsernameExists(ctx context.Context, username string) bool
+	PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error)
 }
 
 type UserUsecase interface {
-	GetAllTrash() ([]userDto.User, error)
-	GetAll() ([]userDto.User, error)
-	GetByID(userID string) (userDto.User, error)
-	PatientRegister(req userDto.AuthRequest) (userDto.User, error)
-	UserRegister(req userDto.RegisterRequest) (userDto.User, error)
-	Login(req userDto.AuthRequest) (string, error)
-	Update(req userDto.UpdateRequest) (userDto.User, error)
-	UpdatePassword(req userDto.UpdatePasswordRequest) error
-	Delete(userID string) error
-	SoftDelete(userID string) error
-	Restore(userID string) error
+	GetAllTrash(ctx context.Context) ([]userDto.User, error)
+	GetAll(ctx context.Context, query queryDto.Query) ([]userDto.User, queryDto.Paging, error)
+	GetByID(ctx context.Context, userID string) (userDto.User, error)
+	PatientRegister(ctx context.Context, req userDto.AuthRequest) (userDto.User, error)
+	UserRegister(ctx context.Context, req userDto.RegisterRequest) (userDto.User, error)
+	Login(ctx context.Context, req userDto.AuthRequest) (string, error)
+	Update(ctx context.Context, req userDto.UpdateRequest) (userDto.User, error)
+	UpdatePassword(ctx context.Context, req userDto.UpdatePasswordRequest) error
+	Delete(ctx context.Context, userID string, version int) error
+	SoftDelete(ctx context.Context, userID string, version int) (int, error)
+	Restore(ctx context.Context, userID string, version int) error
+	ResetPassword(ctx context.Context, username, newPassword string) error
+	PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error)
 }
\ No newline at end of file
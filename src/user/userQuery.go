@@ -0,0 +1,19 @@
+package user
+
+import "avengers-clinic/model/dto/queryDto"
+
+// ListResource is what the list of users can be filtered and sorted by.
+var ListResource = queryDto.Resource{
+	Filters: map[string]string{
+		"role":           "role",
+		"specialization": "specialization",
+	},
+	Sorts: map[string]string{
+		"username":   "username",
+		"role":       "role",
+		"created_at": "created_at",
+	},
+	DateColumn:  "created_at",
+	DefaultSort: []queryDto.Sort{{Field: "created_at", Desc: true}},
+	Key:         "id",
+}
@@ -1,9 +1,15 @@
 package userRepository
 
 import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/dto/userDto"
+	"avengers-clinic/pkg/constants"
+	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/user"
+	"context"
 	"database/sql"
+	"errors"
 )
 
 type userRepository struct {
@@ -14,12 +20,12 @@ func NewUserRepository(db *sql.DB) user.UserRepository {
 	return &userRepository{db}
 }
 
-func (repository *userRepository) GetAllTrash() ([]userDto.User, error) {
+func (repository *userRepository) GetAllTrash(ctx context.Context) ([]userDto.User, error) {
 	query := `
-		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at
+		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at, version
 		FROM users WHERE deleted_at IS NOT NULL ORDER BY created_at DESC;
 	`
-	rows, err := repository.db.Query(query)
+	rows, err := repository.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -28,62 +34,69 @@ func (repository *userRepository) GetAllTrash() ([]userDto.User, error) {
 	return actions, err
 }
 
-func (repository *userRepository) GetAll() ([]userDto.User, error) {
-	query := `
-		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at
-		FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC;
-	`
-	rows, err := repository.db.Query(query)
+// GetAll returns a page of users and how many users match the query in all.
+func (repository *userRepository) GetAll(ctx context.Context, query queryDto.Query) ([]userDto.User, int, error) {
+	conditions, args := utils.ListConditions(query, user.ListResource, nil)
+
+	var total int
+	if err := repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE deleted_at IS NULL"+conditions+";", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order, args := utils.ListOrder(query, user.ListResource, args)
+	rows, err := repository.db.QueryContext(ctx, `
+		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at, version
+		FROM users WHERE deleted_at IS NULL`+conditions+order+";", args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
-	actions, err := scanUsers(rows)
-	return actions, err
+	users, err := scanUsers(rows)
+	return users, total, err
 }
 
-func (repository *userRepository) GetUserByID(userID string) (userDto.User, error) {
+func (repository *userRepository) GetUserByID(ctx context.Context, userID string) (userDto.User, error) {
 	query := `
-		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at
+		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at, version
 		FROM users WHERE id = $1 LIMIT 1;
 	`
-	user, err := scanUser(repository.db.QueryRow(query, userID))
+	user, err := scanUser(repository.db.QueryRowContext(ctx, query, userID))
 	return user, err
 }
 
-func (repository *userRepository) GetTrashByID(userID string) (userDto.User, error) {
+func (repository *userRepository) GetTrashByID(ctx context.Context, userID string) (userDto.User, error) {
 	query := `
-		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at
+		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at, version
 		FROM users WHERE id = $1 AND deleted_at IS NOT NULL LIMIT 1;
 	`
-	user, err := scanUser(repository.db.QueryRow(query, userID))
+	user, err := scanUser(repository.db.QueryRowContext(ctx, query, userID))
 	return user, err
 }
 
-func (repository *userRepository) GetByID(userID string) (userDto.User, error) {
+func (repository *userRepository) GetByID(ctx context.Context, userID string) (userDto.User, error) {
 	query := `
-		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at
+		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at, version
 		FROM users WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
 	`
-	user, err := scanUser(repository.db.QueryRow(query, userID))
+	user, err := scanUser(repository.db.QueryRowContext(ctx, query, userID))
 	return user, err
 }
 
-func (repository *userRepository) GetByUsername(username string) (userDto.User, error) {
+func (repository *userRepository) GetByUsername(ctx context.Context, username string) (userDto.User, error) {
 	query := `
-		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at
+		SELECT id, username, password, role, specialization, created_at, updated_at, deleted_at, version
 		FROM users WHERE username = $1 AND deleted_at IS NULL LIMIT 1;
 	`
-	user, err := scanUser(repository.db.QueryRow(query, username))
+	user, err := scanUser(repository.db.QueryRowContext(ctx, query, username))
 	return user, err
 }
 
-func (repository *userRepository) Insert(user userDto.User) (string, error) {
+func (repository *userRepository) Insert(ctx context.Context, user userDto.User) (string, error) {
 	query := `
 		INSERT INTO users (username, password, role, specialization, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;
 	`
-	err := repository.db.QueryRow(
+	err := repository.db.QueryRowContext(ctx,
 		query,
 		user.Username,
 		user.Password,
@@ -95,51 +108,91 @@ func (repository *userRepository) Insert(user userDto.User) (string, error) {
 	return user.ID, err
 }
 
-func (repository *userRepository) Update(user userDto.User) error {
+// Update changes the user and returns its new version. With a version given
+// the user is only changed while it is still at it.
+func (repository *userRepository) Update(ctx context.Context, user userDto.User) (int, error) {
 	query := `
-		UPDATE users SET username = $2, specialization = $3, updated_at = $4
-		WHERE id = $1;
+		UPDATE users SET username = $2, specialization = $3, updated_at = $4, version = version + 1
+		WHERE id = $1 AND ($5 = 0 OR version = $5) RETURNING version;
 	`
-	_, err := repository.db.Exec(
+	var version int
+	err := repository.db.QueryRowContext(ctx,
 		query,
 		user.ID,
 		user.Username,
 		user.Specialization,
 		user.UpdatedAt,
-	)
-	return err
+		user.Version,
+	).Scan(&version)
+	if err == sql.ErrNoRows && user.Version != 0 {
+		return 0, errors.New(constants.ErrVersionConflict)
+	}
+	return version, err
 }
 
-func (repository *userRepository) UpdatePassword(userId, hashPassword string) error {
+func (repository *userRepository) UpdatePassword(ctx context.Context, userId, hashPassword string) error {
 	query := "UPDATE users SET password = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1;"
-	_, err := repository.db.Exec(query, userId, hashPassword)
+	_, err := repository.db.ExecContext(ctx, query, userId, hashPassword)
 	return err
 }
 
-func (repository *userRepository) Delete(userID string) error {
-	query := "DELETE FROM users WHERE id = $1;"
-	_, err := repository.db.Exec(query, userID)
-	return err
+// Delete deletes the user while it is still at the version, a version of 0
+// deletes any version.
+func (repository *userRepository) Delete(ctx context.Context, userID string, version int) error {
+	query := "DELETE FROM users WHERE id = $1 AND ($2 = 0 OR version = $2);"
+	result, err := repository.db.ExecContext(ctx, query, userID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
 }
 
-func (repository *userRepository) SoftDelete(userID string) error {
-	query := "UPDATE users SET updated_at = CURRENT_TIMESTAMP, deleted_at = CURRENT_TIMESTAMP WHERE id = $1;"
-	_, err := repository.db.Exec(query, userID)
-	return err
+// SoftDelete moves the user to the trash while it is still at the version, a
+// version of 0 moves any version.
+func (repository *userRepository) SoftDelete(ctx context.Context, userID string, version int) error {
+	query := "UPDATE users SET updated_at = CURRENT_TIMESTAMP, deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2);"
+	result, err := repository.db.ExecContext(ctx, query, userID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
 }
 
-func (repository *userRepository) Restore(userID string) error {
-	query := "UPDATE users SET updated_at = CURRENT_TIMESTAMP, deleted_at = NULL WHERE id = $1;"
-	_, err := repository.db.Exec(query, userID)
-	return err
+// Restore takes the user out of the trash while it is still at the version, a
+// version of 0 restores any version.
+func (repository *userRepository) Restore(ctx context.Context, userID string, version int) error {
+	query := "UPDATE users SET updated_at = CURRENT_TIMESTAMP, deleted_at = NULL, version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $2);"
+	result, err := repository.db.ExecContext(ctx, query, userID, version)
+	if err != nil {
+		return err
+	}
+	return versionChecked(result, version)
 }
 
-func (repository *userRepository) IsUsernameExists(username string) bool {
+func (repository *userRepository) IsUsernameExists(ctx context.Context, username string) bool {
 	count, query := 0, "SELECT COUNT(*) FROM users WHERE username = $1;"
-	repository.db.QueryRow(query, username).Scan(&count)
+	repository.db.QueryRowContext(ctx, query, username).Scan(&count)
 	return count > 0
 }
 
+func (repository *userRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	return utils.PurgeTrash(ctx, repository.db, "users", deletedBefore)
+}
+
+// versionChecked fails when a change expecting a version did not find the row
+// at it anymore.
+func versionChecked(result sql.Result, version int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 && version != 0 {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	return nil
+}
+
 func scanUser(row *sql.Row) (userDto.User, error) {
 	var user userDto.User
 	err := row.Scan(
@@ -151,6 +204,7 @@ func scanUser(row *sql.Row) (userDto.User, error) {
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.DeletedAt,
+		&user.Version,
 	)
 	return user, err
 }
@@ -168,6 +222,7 @@ func scanUsers(rows *sql.Rows) ([]userDto.User, error) {
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.DeletedAt,
+			&user.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -1,13 +1,17 @@
 package userRepository
 
 import (
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/dto/userDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/src/user"
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -26,22 +30,22 @@ func (suite *userRepositoryTestSuite) SetupTest() {
 
 // Start Get ALl Trash
 func (suite *userRepositoryTestSuite) TestGetAllTrashSuccess() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
 	
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at"})
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"})
 
-	suite.mock.ExpectQuery(query).WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z"))
-	actialUsers, err := suite.userRepo.GetAllTrash()
+	suite.mock.ExpectQuery(query).WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", 1))
+	actialUsers, err := suite.userRepo.GetAllTrash(context.Background())
 	
 	suite.Nil(err)
 	suite.NotEmpty(actialUsers)
 }
 
 func (suite *userRepositoryTestSuite) TestGetAllTrashError() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
 
 	suite.mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
-	expectedUser, err := suite.userRepo.GetAllTrash()
+	expectedUser, err := suite.userRepo.GetAllTrash(context.Background())
 	
 	suite.Error(err)
 	suite.Empty(expectedUser)
@@ -50,22 +54,46 @@ func (suite *userRepositoryTestSuite) TestGetAllTrashError() {
 
 // Start Get ALl
 func (suite *userRepositoryTestSuite) TestGetAllSuccess() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
 	
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at"})
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"})
 
-	suite.mock.ExpectQuery(query).WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil))
-	actialUsers, err := suite.userRepo.GetAll()
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	suite.mock.ExpectQuery(query).WithArgs(10, 0).WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil, 1))
+	actialUsers, total, err := suite.userRepo.GetAll(context.Background(), queryDto.Query{Page: 1, Size: 10})
 	
 	suite.Nil(err)
 	suite.NotEmpty(actialUsers)
+	suite.Equal(1, total)
 }
 
-func (suite *userRepositoryTestSuite) TestGetAllError() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+)"
+func (suite *userRepositoryTestSuite) TestGetAllFilteredAndSorted() {
+	query := queryDto.Query{
+		Page:      3,
+		Size:      5,
+		Sort:      []queryDto.Sort{{Field: "username"}, {Field: "created_at", Desc: true}},
+		Filters:   []queryDto.Filter{{Field: "role", Value: "DOCTOR"}},
+		StartDate: "2024-03-01",
+	}
 
-	suite.mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
-	expectedUser, err := suite.userRepo.GetAll()
+	suite.mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE deleted_at IS NULL AND role::text = \$1 AND created_at::date >= \$2;`).
+		WithArgs("DOCTOR", "2024-03-01").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	suite.mock.ExpectQuery(`AND role::text = \$1 AND created_at::date >= \$2 ORDER BY username, created_at DESC, id LIMIT \$3 OFFSET \$4;`).
+		WithArgs("DOCTOR", "2024-03-01", 5, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"}).
+			AddRow("1", "joko", "secret", "DOCTOR", "General", "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil, 1))
+	users, total, err := suite.userRepo.GetAll(context.Background(), query)
+
+	suite.Nil(err)
+	suite.Len(users, 1)
+	suite.Equal(12, total)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
+func (suite *userRepositoryTestSuite) TestGetAllError() {
+	suite.mock.ExpectQuery("SELECT COUNT").WillReturnError(sql.ErrConnDone)
+	expectedUser, _, err := suite.userRepo.GetAll(context.Background(), queryDto.Query{Page: 1, Size: 10})
 	
 	suite.Error(err)
 	suite.Empty(expectedUser)
@@ -73,48 +101,48 @@ func (suite *userRepositoryTestSuite) TestGetAllError() {
 // End Get ALl
 
 func (suite *userRepositoryTestSuite) TestGetUserByID() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
 
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at"})
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"})
 
-	suite.mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil))
-	actialUser, err := suite.userRepo.GetUserByID("1")
+	suite.mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil, 1))
+	actialUser, err := suite.userRepo.GetUserByID(context.Background(), "1")
 
 	suite.Nil(err)
 	suite.NotEmpty(actialUser)
 }
 
 func (suite *userRepositoryTestSuite) TestGetTrashByID() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
 
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at"})
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"})
 
-	suite.mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil))
-	actialUser, err := suite.userRepo.GetTrashByID("1")
+	suite.mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil, 1))
+	actialUser, err := suite.userRepo.GetTrashByID(context.Background(), "1")
 
 	suite.Nil(err)
 	suite.NotEmpty(actialUser)
 }
 
 func (suite *userRepositoryTestSuite) TestGetByID() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
 	
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at"})
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"})
 
-	suite.mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil))
-	actialUser, err := suite.userRepo.GetByID("1")
+	suite.mock.ExpectQuery(query).WithArgs("1").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil, 1))
+	actialUser, err := suite.userRepo.GetByID(context.Background(), "1")
 
 	suite.Nil(err)
 	suite.NotEmpty(actialUser)
 }
 
 func (suite *userRepositoryTestSuite) TestGetByUsername() {
-	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
+	query := "SELECT (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+), (.+) FROM users"
 	
-	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at"})
+	rows := sqlmock.NewRows([]string{"id", "username", "password", "role", "specialization", "created_at", "updated_at", "deleted_at", "version"})
 
-	suite.mock.ExpectQuery(query).WithArgs("admin").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil))
-	actialUser, err := suite.userRepo.GetByUsername("admin")
+	suite.mock.ExpectQuery(query).WithArgs("admin").WillReturnRows(rows.AddRow("1", "admin", "admin", "ADMIN", nil, "2024-03-12T23:00:00Z", "2024-03-12T23:00:00Z", nil, 1))
+	actialUser, err := suite.userRepo.GetByUsername(context.Background(), "admin")
 
 	suite.Nil(err)
 	suite.NotEmpty(actialUser)
@@ -135,28 +163,41 @@ func (suite *userRepositoryTestSuite) TestInsert() {
 		CreatedAt: "2024-03-12T23:00:00Z",
 		UpdatedAt: "2024-03-12T23:00:00Z",
 	}
-	userID, err := suite.userRepo.Insert(user)
+	userID, err := suite.userRepo.Insert(context.Background(), user)
 
 	suite.Nil(err)
 	suite.Equal("1", userID)
 }
 
 func (suite *userRepositoryTestSuite) TestUpdate() {
-	args := []driver.Value{"1", "admin", nil, "2024-03-12T23:00:00Z"}
+	args := []driver.Value{"1", "admin", nil, "2024-03-12T23:00:00Z", 1}
 	
-	suite.mock.ExpectExec("UPDATE users").
+	suite.mock.ExpectQuery("UPDATE users").
 		WithArgs(args...).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
 
 	user := userDto.User{
 		ID: "1",
 		Username: "admin",
 		Specialization: nil,
 		UpdatedAt: "2024-03-12T23:00:00Z",
+		Version: 1,
 	}
-	err := suite.userRepo.Update(user)
+	version, err := suite.userRepo.Update(context.Background(), user)
 
 	suite.Nil(err)
+	suite.Equal(2, version)
+}
+
+func (suite *userRepositoryTestSuite) TestUpdateVersionConflict() {
+	suite.mock.ExpectQuery("UPDATE users").
+		WithArgs("1", "admin", nil, "2024-03-12T23:00:00Z", 1).
+		WillReturnError(sql.ErrNoRows)
+
+	user := userDto.User{ID: "1", Username: "admin", UpdatedAt: "2024-03-12T23:00:00Z", Version: 1}
+	_, err := suite.userRepo.Update(context.Background(), user)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
 }
 
 func (suite *userRepositoryTestSuite) TestUpdatePassword() {
@@ -166,7 +207,7 @@ func (suite *userRepositoryTestSuite) TestUpdatePassword() {
 		WithArgs(args...).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.userRepo.UpdatePassword("1", "secret")
+	err := suite.userRepo.UpdatePassword(context.Background(), "1", "secret")
 
 	suite.Nil(err)
 }
@@ -175,10 +216,10 @@ func (suite *userRepositoryTestSuite) TestDelete() {
 	userID := "1"
 	
 	suite.mock.ExpectExec("DELETE FROM users").
-		WithArgs(userID).
+		WithArgs(userID, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.userRepo.Delete(userID)
+	err := suite.userRepo.Delete(context.Background(), userID, 1)
 
 	suite.Nil(err)
 }
@@ -187,10 +228,10 @@ func (suite *userRepositoryTestSuite) TestSoftDelete() {
 	userID := "1"
 
 	suite.mock.ExpectExec("UPDATE users").
-		WithArgs(userID).
+		WithArgs(userID, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.userRepo.SoftDelete(userID)
+	err := suite.userRepo.SoftDelete(context.Background(), userID, 1)
 
 	suite.Nil(err)
 }
@@ -199,14 +240,24 @@ func (suite *userRepositoryTestSuite) TestRestore() {
 	userID := "1"
 
 	suite.mock.ExpectExec("UPDATE users").
-		WithArgs(userID).
+		WithArgs(userID, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := suite.userRepo.Restore(userID)
+	err := suite.userRepo.Restore(context.Background(), userID, 1)
 
 	suite.Nil(err)
 }
 
+func (suite *userRepositoryTestSuite) TestRestoreVersionConflict() {
+	suite.mock.ExpectExec("UPDATE users").
+		WithArgs("1", 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := suite.userRepo.Restore(context.Background(), "1", 1)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+}
+
 func (suite *userRepositoryTestSuite) TestIsUsernameExists() {
 	username := "admin"
 
@@ -214,11 +265,32 @@ func (suite *userRepositoryTestSuite) TestIsUsernameExists() {
 		WithArgs(username).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
-	exists := suite.userRepo.IsUsernameExists(username)
+	exists := suite.userRepo.IsUsernameExists(context.Background(), username)
 
 	suite.True(exists)
 }
 
+func (suite *userRepositoryTestSuite) TestPurgeTrashKeepsReferencedUsers() {
+	deletedBefore := "2024-01-01 00:00:00"
+
+	suite.mock.ExpectQuery("SELECT id FROM users WHERE deleted_at < ").
+		WithArgs(deletedBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("2"))
+	suite.mock.ExpectExec("DELETE FROM users WHERE id = ").
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	suite.mock.ExpectExec("DELETE FROM users WHERE id = ").
+		WithArgs("2").
+		WillReturnError(&pq.Error{Code: "23503", Table: "bookings"})
+
+	purged, err := suite.userRepo.PurgeTrash(context.Background(), deletedBefore)
+
+	suite.Nil(err)
+	suite.Equal(1, purged.Deleted)
+	suite.Equal(map[string]int{"bookings": 1}, purged.Kept)
+	suite.Nil(suite.mock.ExpectationsWereMet())
+}
+
 func TestUserDeliveryTestSuite(t *testing.T) {
 	suite.Run(t, new(userRepositoryTestSuite))
 }
\ No newline at end of file
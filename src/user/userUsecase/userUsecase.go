@@ -1,9 +1,13 @@
 package userUsecase
 
 import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/dto/userDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/user"
+	"context"
 	"errors"
 	"time"
 )
@@ -16,23 +20,23 @@ func NewUserUsecase(userRepo user.UserRepository) user.UserUsecase {
 	return &userUsecase{userRepo}
 }
 
-func (usecase *userUsecase) GetAllTrash() ([]userDto.User, error) {
-	users, err := usecase.userRepo.GetAllTrash()
+func (usecase *userUsecase) GetAllTrash(ctx context.Context) ([]userDto.User, error) {
+	users, err := usecase.userRepo.GetAllTrash(ctx)
 	return users, err
 }
 
-func (usecase *userUsecase) GetAll() ([]userDto.User, error) {
-	users, err := usecase.userRepo.GetAll()
-	return users, err
+func (usecase *userUsecase) GetAll(ctx context.Context, query queryDto.Query) ([]userDto.User, queryDto.Paging, error) {
+	users, total, err := usecase.userRepo.GetAll(ctx, query)
+	return users, utils.NewPaging(query, total), err
 }
 
-func (usecase *userUsecase) GetByID(userID string) (userDto.User, error) {
-	user, err := usecase.userRepo.GetByID(userID)
+func (usecase *userUsecase) GetByID(ctx context.Context, userID string) (userDto.User, error) {
+	user, err := usecase.userRepo.GetByID(ctx, userID)
 	return user, err
 }
 
-func (usecase *userUsecase) PatientRegister(req userDto.AuthRequest) (userDto.User, error) {
-	if usecase.userRepo.IsUsernameExists(req.Username) {
+func (usecase *userUsecase) PatientRegister(ctx context.Context, req userDto.AuthRequest) (userDto.User, error) {
+	if usecase.userRepo.IsUsernameExists(ctx, req.Username) {
 		return userDto.User{}, errors.New("1")
 	}
 
@@ -47,15 +51,15 @@ func (usecase *userUsecase) PatientRegister(req userDto.AuthRequest) (userDto.Us
 	}
 
 	var err error
-	newUser.ID, err = usecase.userRepo.Insert(newUser)
+	newUser.ID, err = usecase.userRepo.Insert(ctx, newUser)
 	if err != nil {
 		return userDto.User{}, err
 	}
 	return newUser, nil
 }
 
-func (usecase *userUsecase) UserRegister(req userDto.RegisterRequest) (userDto.User, error) {
-	if usecase.userRepo.IsUsernameExists(req.Username) {
+func (usecase *userUsecase) UserRegister(ctx context.Context, req userDto.RegisterRequest) (userDto.User, error) {
+	if usecase.userRepo.IsUsernameExists(ctx, req.Username) {
 		return userDto.User{}, errors.New("1")
 	}
 
@@ -77,19 +81,19 @@ func (usecase *userUsecase) UserRegister(req userDto.RegisterRequest) (userDto.U
 	}
 
 	var err error
-	newUser.ID, err = usecase.userRepo.Insert(newUser)
+	newUser.ID, err = usecase.userRepo.Insert(ctx, newUser)
 	if err != nil {
 		return userDto.User{}, err
 	}
 	return newUser, nil
 }
 
-func (usecase *userUsecase) Login(req userDto.AuthRequest) (string, error) {
-	if !usecase.userRepo.IsUsernameExists(req.Username) {
+func (usecase *userUsecase) Login(ctx context.Context, req userDto.AuthRequest) (string, error) {
+	if !usecase.userRepo.IsUsernameExists(ctx, req.Username) {
 		return "", errors.New("1")
 	}
 
-	user, err := usecase.userRepo.GetByUsername(req.Username)
+	user, err := usecase.userRepo.GetByUsername(ctx, req.Username)
 	if err != nil {
 		return "", err
 	}
@@ -102,14 +106,21 @@ func (usecase *userUsecase) Login(req userDto.AuthRequest) (string, error) {
 	return token, nil
 }
 
-func (usecase *userUsecase) Update(req userDto.UpdateRequest) (userDto.User, error) {
-	user, err := usecase.userRepo.GetByID(req.ID)
+// Update changes the user. A version given in the request has to be the
+// current one, the change is then made against the version read here so
+// concurrent changes cannot overwrite each other.
+func (usecase *userUsecase) Update(ctx context.Context, req userDto.UpdateRequest) (userDto.User, error) {
+	user, err := usecase.userRepo.GetByID(ctx, req.ID)
 	if err != nil {
 		return userDto.User{}, err
 	}
 
+	if req.Version != 0 && req.Version != user.Version {
+		return userDto.User{}, errors.New(constants.ErrVersionConflict)
+	}
+
 	if req.Username != "" {
-		if usecase.userRepo.IsUsernameExists(req.Username) && user.Username != req.Username {
+		if usecase.userRepo.IsUsernameExists(ctx, req.Username) && user.Username != req.Username {
 			return userDto.User{}, errors.New("1")
 		}
 		user.Username = req.Username
@@ -124,15 +135,15 @@ func (usecase *userUsecase) Update(req userDto.UpdateRequest) (userDto.User, err
 	}
 	user.UpdatedAt = time.Now().Format("2006-01-02T15:04:05Z")
 
-	err = usecase.userRepo.Update(user)
+	user.Version, err = usecase.userRepo.Update(ctx, user)
 	if err != nil {
 		return userDto.User{}, err
 	}
 	return user, nil
 }
 
-func (usecase *userUsecase) UpdatePassword(req userDto.UpdatePasswordRequest) error {
-	user, err := usecase.userRepo.GetByID(req.ID)
+func (usecase *userUsecase) UpdatePassword(ctx context.Context, req userDto.UpdatePasswordRequest) error {
+	user, err := usecase.userRepo.GetByID(ctx, req.ID)
 	if err != nil {
 		return err
 	}
@@ -146,36 +157,79 @@ func (usecase *userUsecase) UpdatePassword(req userDto.UpdatePasswordRequest) er
 	}
 
 	hashPassword, _ := utils.GenerateHashPassword(req.NewPassword)
-	err = usecase.userRepo.UpdatePassword(req.ID, hashPassword)
+	err = usecase.userRepo.UpdatePassword(ctx, req.ID, hashPassword)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (usecase *userUsecase) Delete(userID string) error {
-	_, err := usecase.userRepo.GetUserByID(userID)
+// Delete deletes the user, only while it is at the given version unless the
+// version is 0.
+func (usecase *userUsecase) Delete(ctx context.Context, userID string, version int) error {
+	user, err := usecase.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	err = usecase.userRepo.Delete(userID)
+	if version != 0 && version != user.Version {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	err = usecase.userRepo.Delete(ctx, userID, user.Version)
 	return err
 }
 
-func (usecase *userUsecase) SoftDelete(userID string) error {
-	_, err := usecase.userRepo.GetByID(userID)
+// SoftDelete moves the user to the trash, only while it is at the given
+// version unless the version is 0. It returns the version the user is in the
+// trash at, which restoring it expects.
+func (usecase *userUsecase) SoftDelete(ctx context.Context, userID string, version int) (int, error) {
+	user, err := usecase.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if version != 0 && version != user.Version {
+		return 0, errors.New(constants.ErrVersionConflict)
+	}
+	err = usecase.userRepo.SoftDelete(ctx, userID, user.Version)
+	if err != nil {
+		return 0, err
+	}
+	return user.Version + 1, nil
+}
+
+// Restore takes the user out of the trash, only while it is at the given
+// version unless the version is 0.
+func (usecase *userUsecase) Restore(ctx context.Context, userID string, version int) error {
+	user, err := usecase.userRepo.GetTrashByID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	err = usecase.userRepo.SoftDelete(userID)
+	if version != 0 && version != user.Version {
+		return errors.New(constants.ErrVersionConflict)
+	}
+	err = usecase.userRepo.Restore(ctx, userID, user.Version)
 	return err
 }
+// ResetPassword sets a new password without knowing the current one, for
+// administrators locked out of their account.
+func (usecase *userUsecase) ResetPassword(ctx context.Context, username, newPassword string) error {
+	user, err := usecase.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
 
-func (usecase *userUsecase) Restore(userID string) error {
-	_, err := usecase.userRepo.GetTrashByID(userID)
+	hashPassword, err := utils.GenerateHashPassword(newPassword)
 	if err != nil {
 		return err
 	}
-	err = usecase.userRepo.Restore(userID)
-	return err
-}
\ No newline at end of file
+	return usecase.userRepo.UpdatePassword(ctx, user.ID, hashPassword)
+}
+
+// PurgeTrash deletes the users that have been in the trash for more than
+// retentionDays. Those still referenced by other rows stay in the trash.
+func (usecase *userUsecase) PurgeTrash(ctx context.Context, retentionDays int) (dto.Purged, error) {
+	deletedBefore, err := utils.RetentionCutoff(retentionDays)
+	if err != nil {
+		return dto.Purged{}, err
+	}
+	return usecase.userRepo.PurgeTrash(ctx, deletedBefore)
+}
@@ -1,11 +1,16 @@
 package userUsecase
 
 import (
+	"avengers-clinic/model/dto"
+	"avengers-clinic/model/dto/queryDto"
 	"avengers-clinic/model/dto/userDto"
+	"avengers-clinic/pkg/constants"
 	"avengers-clinic/pkg/utils"
 	"avengers-clinic/src/user"
+	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -15,71 +20,76 @@ type mockUserRepository struct {
 	mock.Mock
 }
 
-func (mock *mockUserRepository) GetAllTrash() ([]userDto.User, error) {
+func (mock *mockUserRepository) GetAllTrash(ctx context.Context) ([]userDto.User, error) {
 	args := mock.Called()
 	return args.Get(0).([]userDto.User), args.Error(1)
 }
 
-func (mock *mockUserRepository) GetAll() ([]userDto.User, error) {
-	args := mock.Called()
-	return args.Get(0).([]userDto.User), args.Error(1)
+func (mock *mockUserRepository) GetAll(ctx context.Context, query queryDto.Query) ([]userDto.User, int, error) {
+	args := mock.Called(query)
+	return args.Get(0).([]userDto.User), args.Int(1), args.Error(2)
 }
 
-func (mock *mockUserRepository) GetByID(userID string) (userDto.User, error) {
+func (mock *mockUserRepository) GetByID(ctx context.Context, userID string) (userDto.User, error) {
 	args := mock.Called(userID)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserRepository) GetUserByID(userID string) (userDto.User, error) {
+func (mock *mockUserRepository) GetUserByID(ctx context.Context, userID string) (userDto.User, error) {
 	args := mock.Called(userID)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserRepository) GetTrashByID(userID string) (userDto.User, error) {
+func (mock *mockUserRepository) GetTrashByID(ctx context.Context, userID string) (userDto.User, error) {
 	args := mock.Called(userID)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserRepository) GetByUsername(username string) (userDto.User, error) {
+func (mock *mockUserRepository) GetByUsername(ctx context.Context, username string) (userDto.User, error) {
 	args := mock.Called(username)
 	return args.Get(0).(userDto.User), args.Error(1)
 }
 
-func (mock *mockUserRepository) Insert(user userDto.User) (string, error) {
+func (mock *mockUserRepository) Insert(ctx context.Context, user userDto.User) (string, error) {
 	args := mock.Called(user)
 	return args.String(0), args.Error(1)
 }
 
-func (mock *mockUserRepository) Update(user userDto.User) error {
+func (mock *mockUserRepository) Update(ctx context.Context, user userDto.User) (int, error) {
 	args := mock.Called(user)
-	return args.Error(0)
+	return args.Int(0), args.Error(1)
 }
 
-func (mock *mockUserRepository) UpdatePassword(userId, hashPassword string) error {
+func (mock *mockUserRepository) UpdatePassword(ctx context.Context, userId, hashPassword string) error {
 	args := mock.Called(userId, hashPassword)
 	return args.Error(0)
 }
 
-func (mock *mockUserRepository) Delete(userID string) error {
-	args := mock.Called(userID)
+func (mock *mockUserRepository) Delete(ctx context.Context, userID string, version int) error {
+	args := mock.Called(userID, version)
 	return args.Error(0)
 }
 
-func (mock *mockUserRepository) SoftDelete(userID string) error {
-	args := mock.Called(userID)
+func (mock *mockUserRepository) SoftDelete(ctx context.Context, userID string, version int) error {
+	args := mock.Called(userID, version)
 	return args.Error(0)
 }
 
-func (mock *mockUserRepository) Restore(userID string) error {
-	args := mock.Called(userID)
+func (mock *mockUserRepository) Restore(ctx context.Context, userID string, version int) error {
+	args := mock.Called(userID, version)
 	return args.Error(0)
 }
 
-func (mock *mockUserRepository) IsUsernameExists(username string) bool {
+func (mock *mockUserRepository) IsUsernameExists(ctx context.Context, username string) bool {
 	args := mock.Called(username)
 	return args.Bool(0)
 }
 
+func (mock *mockUserRepository) PurgeTrash(ctx context.Context, deletedBefore string) (dto.Purged, error) {
+	args := mock.Called(deletedBefore)
+	return args.Get(0).(dto.Purged), args.Error(1)
+}
+
 type userUsecaseTestSuite struct {
 	suite.Suite
 	userRepo *mockUserRepository
@@ -102,7 +112,7 @@ func (suite *userUsecaseTestSuite) TestGetAllTrashSuccess() {
 	}
 
 	suite.userRepo.On("GetAllTrash").Return(expectedUsers, nil)
-	actualUsers, err := suite.userUC.GetAllTrash()
+	actualUsers, err := suite.userUC.GetAllTrash(context.Background())
 	
 	suite.Nil(err)
 	suite.Equal(expectedUsers, actualUsers)
@@ -118,11 +128,13 @@ func (suite *userUsecaseTestSuite) TestGetAllSuccess() {
 		},
 	}
 
-	suite.userRepo.On("GetAll").Return(expectedUsers, nil)
-	actualUsers, err := suite.userUC.GetAll()
+	query := queryDto.Query{Page: 2, Size: 10}
+	suite.userRepo.On("GetAll", query).Return(expectedUsers, 11, nil)
+	actualUsers, paging, err := suite.userUC.GetAll(context.Background(), query)
 	
 	suite.Nil(err)
 	suite.Equal(expectedUsers, actualUsers)
+	suite.Equal(queryDto.Paging{Page: 2, Size: 10, TotalRows: 11, TotalPages: 2}, paging)
 }
 
 func (suite *userUsecaseTestSuite) TestGetByIDSuccess() {
@@ -134,7 +146,7 @@ func (suite *userUsecaseTestSuite) TestGetByIDSuccess() {
 	}
 
 	suite.userRepo.On("GetByID", expectedUsers.ID).Return(expectedUsers, nil)
-	actualUsers, err := suite.userUC.GetByID(expectedUsers.ID)
+	actualUsers, err := suite.userUC.GetByID(context.Background(), expectedUsers.ID)
 	
 	suite.Nil(err)
 	suite.Equal(expectedUsers, actualUsers)
@@ -149,7 +161,7 @@ func (suite *userUsecaseTestSuite) TestPatientRegisterSuccess() {
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
 	suite.userRepo.On("Insert", mock.Anything).Return("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", nil)
-	actualUser, err := suite.userUC.PatientRegister(request)
+	actualUser, err := suite.userUC.PatientRegister(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(request.Username, actualUser.Username)
@@ -162,7 +174,7 @@ func (suite *userUsecaseTestSuite) TestPatientRegisterErrorUsernameExists() {
 	}
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(true)
-	actualUser, err := suite.userUC.PatientRegister(request)
+	actualUser, err := suite.userUC.PatientRegister(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -176,7 +188,7 @@ func (suite *userUsecaseTestSuite) TestPatientRegisterInternalServerError() {
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
 	suite.userRepo.On("Insert", mock.Anything).Return("", sql.ErrConnDone)
-	actualUser, err := suite.userUC.PatientRegister(request)
+	actualUser, err := suite.userUC.PatientRegister(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -194,7 +206,7 @@ func (suite *userUsecaseTestSuite) TestUserRegisterSuccess() {
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
 	suite.userRepo.On("Insert", mock.Anything).Return("9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", nil)
-	actualUser, err := suite.userUC.UserRegister(request)
+	actualUser, err := suite.userUC.UserRegister(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(request.Username, actualUser.Username)
@@ -209,7 +221,7 @@ func (suite *userUsecaseTestSuite) TestUserRegisterErrorUsernameExists() {
 	}
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(true)
-	actualUser, err := suite.userUC.UserRegister(request)
+	actualUser, err := suite.userUC.UserRegister(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -223,7 +235,7 @@ func (suite *userUsecaseTestSuite) TestUserRegisterErrorBadRequest() {
 	}
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
-	actualUser, err := suite.userUC.UserRegister(request)
+	actualUser, err := suite.userUC.UserRegister(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -239,7 +251,7 @@ func (suite *userUsecaseTestSuite) TestUserRegisterInternalServerError() {
 
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
 	suite.userRepo.On("Insert", mock.Anything).Return("", sql.ErrConnDone)
-	actualUser, err := suite.userUC.UserRegister(request)
+	actualUser, err := suite.userUC.UserRegister(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -262,7 +274,7 @@ func (suite *userUsecaseTestSuite) TestLoginSuccess() {
 	}
 	suite.userRepo.On("GetByUsername", request.Username).Return(expectedUser, nil)
 	expectedToken, _ := utils.GenerateJWT(expectedUser.ID, expectedUser.Username, expectedUser.Role)
-	actualToken, err := suite.userUC.Login(request)
+	actualToken, err := suite.userUC.Login(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(expectedToken, actualToken)
@@ -275,7 +287,7 @@ func (suite *userUsecaseTestSuite) TestLoginErrorUsernameNotExists() {
 		Username: "user",
 		Password: "rahasia",
 	}
-	actualToken, err := suite.userUC.Login(request)
+	actualToken, err := suite.userUC.Login(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualToken)
@@ -296,7 +308,7 @@ func (suite *userUsecaseTestSuite) TestLoginErrorWrongPassword() {
 		Username: "admin",
 		Password: "rahasia",
 	}
-	actualToken, err := suite.userUC.Login(request)
+	actualToken, err := suite.userUC.Login(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualToken)
@@ -318,11 +330,22 @@ func (suite *userUsecaseTestSuite) TestUpdateSuccess() {
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectUser, nil)
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
-	suite.userRepo.On("Update", mock.Anything).Return(nil)
-	actualUser, err := suite.userUC.Update(request)
+	suite.userRepo.On("Update", mock.Anything).Return(2, nil)
+	actualUser, err := suite.userUC.Update(context.Background(), request)
 
 	suite.Nil(err)
 	suite.Equal(request.Username, actualUser.Username)
+	suite.Equal(2, actualUser.Version)
+}
+
+func (suite *userUsecaseTestSuite) TestUpdateVersionConflict() {
+	request := userDto.UpdateRequest{ID: "9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", Username: "user", Version: 1}
+
+	suite.userRepo.On("GetByID", request.ID).Return(userDto.User{ID: request.ID, Username: "admin", Role: "ADMIN", Version: 2}, nil)
+	_, err := suite.userUC.Update(context.Background(), request)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.userRepo.AssertNotCalled(suite.T(), "Update", mock.Anything)
 }
 
 func (suite *userUsecaseTestSuite) TestUpdateErrorUserNotFound() {
@@ -332,7 +355,7 @@ func (suite *userUsecaseTestSuite) TestUpdateErrorUserNotFound() {
 	}
 
 	suite.userRepo.On("GetByID", request.ID).Return(userDto.User{}, sql.ErrNoRows)
-	actualUser, err := suite.userUC.Update(request)
+	actualUser, err := suite.userUC.Update(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -352,7 +375,7 @@ func (suite *userUsecaseTestSuite) TestUpdateErrorUsernameExists() {
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectUser, nil)
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(true)
-	actualUser, err := suite.userUC.Update(request)
+	actualUser, err := suite.userUC.Update(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -372,8 +395,8 @@ func (suite *userUsecaseTestSuite) TestUpdateInternalServerError() {
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectUser, nil)
 	suite.userRepo.On("IsUsernameExists", request.Username).Return(false)
-	suite.userRepo.On("Update", mock.Anything).Return(sql.ErrConnDone)
-	actualUser, err := suite.userUC.Update(request)
+	suite.userRepo.On("Update", mock.Anything).Return(0, sql.ErrConnDone)
+	actualUser, err := suite.userUC.Update(context.Background(), request)
 
 	suite.Error(err)
 	suite.Empty(actualUser)
@@ -398,7 +421,7 @@ func (suite *userUsecaseTestSuite) TestUpdatePasswordSuccess() {
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectedUser, nil)
 	suite.userRepo.On("UpdatePassword", request.ID, mock.Anything).Return(nil)
-	err := suite.userUC.UpdatePassword(request)
+	err := suite.userUC.UpdatePassword(context.Background(), request)
 
 	suite.Nil(err)
 }
@@ -412,7 +435,7 @@ func (suite *userUsecaseTestSuite) TestUpdatePasswordErrorUserNotFound() {
 	}
 
 	suite.userRepo.On("GetByID", request.ID).Return(userDto.User{}, sql.ErrNoRows)
-	err := suite.userUC.UpdatePassword(request)
+	err := suite.userUC.UpdatePassword(context.Background(), request)
 
 	suite.Error(err)
 }
@@ -433,7 +456,7 @@ func (suite *userUsecaseTestSuite) TestUpdatePasswordErrorWrongPassword() {
 	}
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectedUser, nil)
-	err := suite.userUC.UpdatePassword(request)
+	err := suite.userUC.UpdatePassword(context.Background(), request)
 
 	suite.Error(err)
 }
@@ -454,7 +477,7 @@ func (suite *userUsecaseTestSuite) TestUpdatePasswordErrorWrongConfirmation() {
 	}
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectedUser, nil)
-	err := suite.userUC.UpdatePassword(request)
+	err := suite.userUC.UpdatePassword(context.Background(), request)
 
 	suite.Error(err)
 }
@@ -476,7 +499,7 @@ func (suite *userUsecaseTestSuite) TestUpdatePasswordInternalServerError() {
 
 	suite.userRepo.On("GetByID", request.ID).Return(expectedUser, nil)
 	suite.userRepo.On("UpdatePassword", request.ID, mock.Anything).Return(sql.ErrConnDone)
-	err := suite.userUC.UpdatePassword(request)
+	err := suite.userUC.UpdatePassword(context.Background(), request)
 
 	suite.Error(err)
 }
@@ -491,8 +514,8 @@ func (suite *userUsecaseTestSuite) TestDeleteSuccess() {
 	}
 
 	suite.userRepo.On("GetUserByID", userID).Return(expectUser, nil)
-	suite.userRepo.On("Delete", userID).Return(nil)
-	err := suite.userUC.Delete(userID)
+	suite.userRepo.On("Delete", userID, 0).Return(nil)
+	err := suite.userUC.Delete(context.Background(), userID, 0)
 
 	suite.Nil(err)
 }
@@ -503,7 +526,7 @@ func (suite *userUsecaseTestSuite) TestDeleteErrorUserNotFound() {
 	expectUser := userDto.User{}
 
 	suite.userRepo.On("GetUserByID", userID).Return(expectUser, sql.ErrNoRows)
-	err := suite.userUC.Delete(userID)
+	err := suite.userUC.Delete(context.Background(), userID, 0)
 
 	suite.Error(err)
 }
@@ -516,8 +539,8 @@ func (suite *userUsecaseTestSuite) TestDeleteInternalServerError() {
 	}
 
 	suite.userRepo.On("GetUserByID", userID).Return(expectUser, nil)
-	suite.userRepo.On("Delete", userID).Return(sql.ErrConnDone)
-	err := suite.userUC.Delete(userID)
+	suite.userRepo.On("Delete", userID, 0).Return(sql.ErrConnDone)
+	err := suite.userUC.Delete(context.Background(), userID, 0)
 
 	suite.Error(err)
 }
@@ -532,8 +555,8 @@ func (suite *userUsecaseTestSuite) TestSoftDeleteSuccess() {
 	}
 
 	suite.userRepo.On("GetByID", userID).Return(expectUser, nil)
-	suite.userRepo.On("SoftDelete", userID).Return(nil)
-	err := suite.userUC.SoftDelete(userID)
+	suite.userRepo.On("SoftDelete", userID, 0).Return(nil)
+	_, err := suite.userUC.SoftDelete(context.Background(), userID, 0)
 
 	suite.Nil(err)
 }
@@ -544,7 +567,7 @@ func (suite *userUsecaseTestSuite) TestSoftDeleteErrorUserNotFound() {
 	expectUser := userDto.User{}
 
 	suite.userRepo.On("GetByID", userID).Return(expectUser, sql.ErrNoRows)
-	err := suite.userUC.SoftDelete(userID)
+	_, err := suite.userUC.SoftDelete(context.Background(), userID, 0)
 
 	suite.Error(err)
 }
@@ -557,11 +580,22 @@ func (suite *userUsecaseTestSuite) TestSoftDeleteInternalServerError() {
 	}
 
 	suite.userRepo.On("GetByID", userID).Return(expectUser, nil)
-	suite.userRepo.On("SoftDelete", userID).Return(sql.ErrConnDone)
-	err := suite.userUC.SoftDelete(userID)
+	suite.userRepo.On("SoftDelete", userID, 0).Return(sql.ErrConnDone)
+	_, err := suite.userUC.SoftDelete(context.Background(), userID, 0)
 
 	suite.Error(err)
 }
+
+func (suite *userUsecaseTestSuite) TestSoftDeleteReturnsTrashVersion() {
+	userID := "9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5"
+
+	suite.userRepo.On("GetByID", userID).Return(userDto.User{ID: userID, Version: 3}, nil)
+	suite.userRepo.On("SoftDelete", userID, 3).Return(nil)
+	version, err := suite.userUC.SoftDelete(context.Background(), userID, 3)
+
+	suite.Nil(err)
+	suite.Equal(4, version)
+}
 // End Soft Delete
 
 // Start Restore
@@ -573,8 +607,8 @@ func (suite *userUsecaseTestSuite) TestRestoreSuccess() {
 	}
 
 	suite.userRepo.On("GetTrashByID", userID).Return(expectUser, nil)
-	suite.userRepo.On("Restore", userID).Return(nil)
-	err := suite.userUC.Restore(userID)
+	suite.userRepo.On("Restore", userID, 0).Return(nil)
+	err := suite.userUC.Restore(context.Background(), userID, 0)
 
 	suite.Nil(err)
 }
@@ -585,7 +619,7 @@ func (suite *userUsecaseTestSuite) TestRestoreErrorUserNotFound() {
 	expectUser := userDto.User{}
 
 	suite.userRepo.On("GetTrashByID", userID).Return(expectUser, sql.ErrNoRows)
-	err := suite.userUC.Restore(userID)
+	err := suite.userUC.Restore(context.Background(), userID, 0)
 
 	suite.Error(err)
 }
@@ -598,13 +632,59 @@ func (suite *userUsecaseTestSuite) TestRestoreInternalServerError() {
 	}
 
 	suite.userRepo.On("GetTrashByID", userID).Return(expectUser, nil)
-	suite.userRepo.On("Restore", userID).Return(sql.ErrConnDone)
-	err := suite.userUC.Restore(userID)
+	suite.userRepo.On("Restore", userID, 0).Return(sql.ErrConnDone)
+	err := suite.userUC.Restore(context.Background(), userID, 0)
 
 	suite.Error(err)
 }
+
+func (suite *userUsecaseTestSuite) TestRestoreVersionConflict() {
+	userID := "9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5"
+
+	suite.userRepo.On("GetTrashByID", userID).Return(userDto.User{ID: userID, Version: 4}, nil)
+	err := suite.userUC.Restore(context.Background(), userID, 3)
+
+	suite.EqualError(err, constants.ErrVersionConflict)
+	suite.userRepo.AssertNotCalled(suite.T(), "Restore", mock.Anything, mock.Anything)
+}
 // End Restore
 
+// Start Reset Password
+func (suite *userUsecaseTestSuite) TestResetPasswordSuccess() {
+	expectedUser := userDto.User{ID: "9d3cd7b1-ade2-4f8c-b215-9e74f0c87bf5", Username: "admin", Role: "ADMIN"}
+
+	suite.userRepo.On("GetByUsername", "admin").Return(expectedUser, nil)
+	suite.userRepo.On("UpdatePassword", expectedUser.ID, mock.MatchedBy(func(hash string) bool {
+		return !utils.VerifyHashPassword(hash, "secret")
+	})).Return(nil)
+	err := suite.userUC.ResetPassword(context.Background(), "admin", "secret")
+
+	suite.Nil(err)
+	suite.userRepo.AssertExpectations(suite.T())
+}
+
+func (suite *userUsecaseTestSuite) TestResetPasswordErrorUserNotFound() {
+	suite.userRepo.On("GetByUsername", "nobody").Return(userDto.User{}, sql.ErrNoRows)
+	err := suite.userUC.ResetPassword(context.Background(), "nobody", "secret")
+
+	suite.Equal(sql.ErrNoRows, err)
+	suite.userRepo.AssertNotCalled(suite.T(), "UpdatePassword", mock.Anything, mock.Anything)
+}
+// End Reset Password
+
+// Start Purge Trash
+func (suite *userUsecaseTestSuite) TestPurgeTrashSuccess() {
+	suite.userRepo.On("PurgeTrash", mock.MatchedBy(func(deletedBefore string) bool {
+		return deletedBefore <= time.Now().AddDate(0, 0, -30).Format("2006-01-02 15:04:05")
+	})).Return(dto.Purged{Deleted: 1}, nil)
+	purged, err := suite.userUC.PurgeTrash(context.Background(), 30)
+
+	suite.Nil(err)
+	suite.Equal(1, purged.Deleted)
+}
+// End Purge Trash
+
+
 func TestUserUsecase(t *testing.T) {
 	suite.Run(t, new(userUsecaseTestSuite))
 }
\ No newline at end of file